@@ -0,0 +1,31 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Annotate attaches a note to an applied version, e.g. Annotate(ctx, 42,
+// "reverted", "manually on 2024-07-02"), or deletes the note if value is
+// empty. Notes appear in Status. Like Status it does not take the lock. It
+// fails with errors.ErrUnsupported if the store is not an Annotator, and
+// with ErrNotApplied if version is not applied.
+func (m *Migrator) Annotate(ctx context.Context, version int64, key, value string) error {
+	if key == "" {
+		return errors.New("empty annotation key")
+	}
+	store := m.store()
+	annotator, ok := store.(Annotator)
+	if !ok {
+		return fmt.Errorf("failed to annotate version %d: %w", version, errors.ErrUnsupported)
+	}
+	if err := store.Init(ctx); err != nil {
+		return fmt.Errorf("failed to init version store: %w", err)
+	}
+	if err := annotator.Annotate(ctx, version, key, value); err != nil {
+		return fmt.Errorf("failed to annotate version %d: %w", version, err)
+	}
+	m.logger().Infof("annotated version %d: %s = %q", version, key, value)
+	return nil
+}
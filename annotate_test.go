@@ -0,0 +1,58 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestMigrator_Annotate(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: createMigrations(1, 2)}
+	if _, err := migrator.Up(ctx, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := migrator.Annotate(ctx, 1, "note", "superseded by 2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := migrator.Annotate(ctx, 2, "note", "x"); !errors.Is(err, golumn.ErrNotApplied) {
+		t.Errorf("expected ErrNotApplied, got %v", err)
+	}
+	if err := migrator.Annotate(ctx, 1, "", "x"); err == nil {
+		t.Error("expected error for empty key")
+	}
+
+	st, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := st.Annotations[1]["note"]; got != "superseded by 2" || len(st.Annotations) != 1 {
+		t.Errorf("expected note on version 1, got %v", st.Annotations)
+	}
+
+	if _, err := migrator.Down(ctx, golumn.Initial); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if st, err := migrator.Status(ctx); err != nil || st.Annotations != nil {
+		t.Errorf("expected annotations removed with their version, got %v (err %v)", st.Annotations, err)
+	}
+}
+
+func TestMigrator_AnnotateUnsupported(t *testing.T) {
+	migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1)}
+	if err := migrator.Annotate(context.Background(), 1, "note", "x"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+package golumn
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// TagAnonymize tags migrations that anonymize data, e.g. to produce a
+// staging copy of a production database. They form their own lineage: a
+// Migrator must not mix them with untagged migrations, so that they are
+// recorded in a version store of their own (see the Lineage field of the
+// stores), and runs refuse to apply or revert them against a store reporting
+// IsProduction, even with an approval.
+const TagAnonymize = "anonymize"
+
+// ErrAnonymizeProduction is returned by runs of a Migrator with TagAnonymize
+// migrations against a production store.
+var ErrAnonymizeProduction = errors.New("anonymization migrations may not run against a production store")
+
+// isAnonymization reports whether migration is tagged TagAnonymize.
+func isAnonymization(migration *Migration) bool {
+	for _, tag := range migration.Tags {
+		if tag == TagAnonymize {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAnonymizeLineage checks that TagAnonymize migrations are not mixed
+// with others.
+func (m *Migrator) checkAnonymizeLineage() error {
+	anonymizing, other := 0, 0
+	for _, migration := range m.migrations() {
+		if isAnonymization(migration) {
+			anonymizing++
+		} else {
+			other++
+		}
+	}
+	if anonymizing > 0 && other > 0 {
+		return fmt.Errorf("%d migrations tagged %q mixed with %d others; keep them in a lineage of their own", anonymizing, TagAnonymize, other)
+	}
+	return nil
+}
+
+// refuseAnonymizeProduction fails if the store is flagged as production and
+// any migration is tagged TagAnonymize.
+func (m *Migrator) refuseAnonymizeProduction() error {
+	ps, ok := m.Store.(ProductionStore)
+	if !ok || !ps.IsProduction() {
+		return nil
+	}
+	for _, migration := range m.migrations() {
+		if isAnonymization(migration) {
+			return fmt.Errorf("%w: migration %d is tagged %q", ErrAnonymizeProduction, migration.Version, TagAnonymize)
+		}
+	}
+	return nil
+}
+
+// Anonymizer returns the replacement for a column value. A nil result sets
+// the column to NULL.
+type Anonymizer func(value string) (any, error)
+
+// HashAnonymizer replaces values with the first length hex digits of their
+// salted SHA-256, or all 64 if length is not positive. Equal values map to
+// equal hashes, so joins on the column keep working.
+func HashAnonymizer(salt string, length int) Anonymizer {
+	return func(value string) (any, error) {
+		return anonymizeHash(salt, value, length), nil
+	}
+}
+
+// MaskAnonymizer replaces all but the last keep characters of values with
+// '*'. Values no longer than keep are masked entirely.
+func MaskAnonymizer(keep int) Anonymizer {
+	return func(value string) (any, error) {
+		return anonymizeMask(value, keep), nil
+	}
+}
+
+// EmailAnonymizer replaces values with addresses at example.invalid whose
+// local part is a salted hash of the value, keeping them unique.
+func EmailAnonymizer(salt string) Anonymizer {
+	return func(value string) (any, error) {
+		return "user_" + anonymizeHash(salt, value, 16) + "@example.invalid", nil
+	}
+}
+
+// NullAnonymizer sets values to NULL.
+func NullAnonymizer() Anonymizer {
+	return func(string) (any, error) { return nil, nil }
+}
+
+func anonymizeHash(salt, value string, length int) string {
+	sum := sha256.Sum256([]byte(salt + value))
+	h := hex.EncodeToString(sum[:])
+	if length > 0 && length < len(h) {
+		h = h[:length]
+	}
+	return h
+}
+
+func anonymizeMask(value string, keep int) string {
+	runes := []rune(value)
+	masked := len(runes) - max(keep, 0)
+	if masked <= 0 {
+		masked = len(runes)
+	}
+	for i := range masked {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+// AnonymizeOptions selects the column AnonymizeColumn rewrites.
+type AnonymizeOptions struct {
+	Table, Column string
+	// Key is a unique column to page through the table by. Empty means
+	// "id".
+	Key string
+	// BatchSize is the number of rows read and updated per transaction.
+	// Zero means 1000.
+	BatchSize int
+	// Numbered uses $1-style placeholders, e.g. for PostgreSQL, instead of ?.
+	Numbered bool
+}
+
+// AnonymizeColumn rewrites each non-NULL value of a column with fn, in
+// batches of rows ordered by a key column, each updated in a transaction of
+// its own so that large tables neither hold long locks nor need to fit in
+// memory. It returns the number of rows rewritten; after an error, the
+// batches before the failing one stay rewritten. Identifiers are quoted
+// with double quotes.
+func AnonymizeColumn(ctx context.Context, db *sql.DB, opts AnonymizeOptions, fn Anonymizer) (int64, error) {
+	key := cmp.Or(opts.Key, "id")
+	batchSize := opts.BatchSize
+	if batchSize == 0 {
+		batchSize = 1000
+	}
+	if batchSize < 0 {
+		return 0, fmt.Errorf("negative batch size: %d", batchSize)
+	}
+	placeholder := func(n int) string {
+		if opts.Numbered {
+			return "$" + strconv.Itoa(n)
+		}
+		return "?"
+	}
+	table, column, keyCol := quoteIdent(opts.Table, '"'), quoteIdent(opts.Column, '"'), quoteIdent(key, '"')
+
+	type row struct {
+		key   any
+		value string
+	}
+	var total int64
+	var last any
+	for {
+		q := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s IS NOT NULL", keyCol, column, table, column)
+		var args []any
+		if last != nil {
+			q += fmt.Sprintf(" AND %s > %s", keyCol, placeholder(1))
+			args = append(args, last)
+		}
+		q += fmt.Sprintf(" ORDER BY %s LIMIT %d", keyCol, batchSize)
+
+		rows, err := db.QueryContext(ctx, q, args...)
+		if err != nil {
+			return total, err
+		}
+		var batch []row
+		for rows.Next() {
+			var r row
+			var value any
+			if err := rows.Scan(&r.key, &value); err != nil {
+				rows.Close()
+				return total, err
+			}
+			r.value = anonymizeString(value)
+			batch = append(batch, r)
+		}
+		if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+			return total, err
+		}
+		if len(batch) == 0 {
+			return total, nil
+		}
+
+		update := fmt.Sprintf("UPDATE %s SET %s = %s WHERE %s = %s", table, column, placeholder(1), keyCol, placeholder(2))
+		if err := anonymizeBatch(ctx, db, update, func(exec func(value, key any) error) error {
+			for _, r := range batch {
+				replacement, err := fn(r.value)
+				if err != nil {
+					return fmt.Errorf("row %v: %w", r.key, err)
+				}
+				if err := exec(replacement, r.key); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return total, err
+		}
+
+		total += int64(len(batch))
+		last = batch[len(batch)-1].key
+		if len(batch) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// anonymizeBatch runs fn in a transaction, giving it a function executing
+// the prepared update with a value and key.
+func anonymizeBatch(ctx context.Context, db *sql.DB, update string, fn func(exec func(value, key any) error) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, tx.Rollback())
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, update)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return fn(func(value, key any) error {
+		_, err := stmt.ExecContext(ctx, value, key)
+		return err
+	})
+}
+
+// anonymizeString converts a scanned column value to the string passed to
+// an Anonymizer.
+func anonymizeString(v any) string {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// luaAnonymizeFunc implements db.anonymize(table, column, options), where
+// options.strategy is "hash", "mask", "email", "null" or a function from
+// the old value to the new one.
+func luaAnonymizeFunc(sess *luaSession) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		table, column := l.CheckString(1), l.CheckString(2)
+		opts := l.OptTable(3, l.NewTable())
+		if sess.db == nil {
+			l.RaiseError("anonymize is not available while parsing")
+			return 0
+		}
+
+		fn, err := luaAnonymizer(l, opts)
+		if err != nil {
+			l.ArgError(3, err.Error())
+			return 0
+		}
+		n, err := AnonymizeColumn(luaContext(l), sess.db, AnonymizeOptions{
+			Table:     table,
+			Column:    column,
+			Key:       luaOptString(opts, "key"),
+			BatchSize: int(luaOptNumber(opts, "batch")),
+			Numbered:  lua.LVAsBool(opts.RawGetString("numbered")),
+		}, fn)
+		if err != nil {
+			l.Push(lua.LNil)
+			l.Push(lua.LString(fmt.Sprintf("anonymize: %v", err)))
+			return 2
+		}
+		l.Push(lua.LNumber(n))
+		return 1
+	}
+}
+
+// luaAnonymizer returns the Anonymizer selected by options.strategy,
+// defaulting to "hash". salt, length and keep configure the named
+// strategies.
+func luaAnonymizer(l *lua.LState, opts *lua.LTable) (Anonymizer, error) {
+	salt := luaOptString(opts, "salt")
+	switch strategy := opts.RawGetString("strategy").(type) {
+	case *lua.LNilType:
+		return HashAnonymizer(salt, int(luaOptNumber(opts, "length"))), nil
+	case lua.LString:
+		switch strategy {
+		case "hash":
+			return HashAnonymizer(salt, int(luaOptNumber(opts, "length"))), nil
+		case "mask":
+			keep := 4
+			if v, ok := opts.RawGetString("keep").(lua.LNumber); ok {
+				keep = int(v)
+			}
+			return MaskAnonymizer(keep), nil
+		case "email":
+			return EmailAnonymizer(salt), nil
+		case "null":
+			return NullAnonymizer(), nil
+		}
+		return nil, fmt.Errorf("unknown strategy %q", string(strategy))
+	case *lua.LFunction:
+		return func(value string) (any, error) {
+			if err := l.CallByParam(lua.P{Fn: strategy, NRet: 1, Protect: true}, lua.LString(value)); err != nil {
+				return nil, err
+			}
+			ret := l.Get(-1)
+			l.Pop(1)
+			switch ret := ret.(type) {
+			case *lua.LNilType:
+				return nil, nil
+			case lua.LString:
+				return string(ret), nil
+			case lua.LNumber:
+				return float64(ret), nil
+			}
+			return nil, fmt.Errorf("strategy returned a %s", ret.Type())
+		}, nil
+	default:
+		return nil, fmt.Errorf("strategy must be a string or function, got %s", strategy.Type())
+	}
+}
+
+// luaAnonymizeHashFunc implements db.hash(value, salt, length).
+func luaAnonymizeHashFunc(l *lua.LState) int {
+	l.Push(lua.LString(anonymizeHash(l.OptString(2, ""), l.CheckString(1), l.OptInt(3, 0))))
+	return 1
+}
+
+// luaAnonymizeMaskFunc implements db.mask(value, keep).
+func luaAnonymizeMaskFunc(l *lua.LState) int {
+	l.Push(lua.LString(anonymizeMask(l.CheckString(1), l.OptInt(2, 4))))
+	return 1
+}
+
+func luaOptString(t *lua.LTable, key string) string {
+	if s, ok := t.RawGetString(key).(lua.LString); ok {
+		return string(s)
+	}
+	return ""
+}
+
+func luaOptNumber(t *lua.LTable, key string) lua.LNumber {
+	if n, ok := t.RawGetString(key).(lua.LNumber); ok {
+		return n
+	}
+	return 0
+}
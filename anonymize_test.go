@@ -0,0 +1,166 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestAnonymizers(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   golumn.Anonymizer
+		in   string
+		want any
+	}{
+		{"hash", golumn.HashAnonymizer("", 8), "", "e3b0c442"},
+		{"hash_full", golumn.HashAnonymizer("", 0), "", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"mask", golumn.MaskAnonymizer(4), "4111111111111111", "************1111"},
+		{"mask_short", golumn.MaskAnonymizer(4), "äbc", "***"},
+		{"email", golumn.EmailAnonymizer(""), "", "user_e3b0c44298fc1c14@example.invalid"},
+		{"null", golumn.NullAnonymizer(), "alice", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn(tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnonymizeColumn(t *testing.T) {
+	db := openLuaTestDB(t, 25)
+	if _, err := db.Exec("UPDATE items SET name = NULL WHERE id = 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := golumn.AnonymizeColumn(context.Background(), db, golumn.AnonymizeOptions{Table: "items", Column: "name", BatchSize: 10}, golumn.MaskAnonymizer(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 24 {
+		t.Errorf("rewrote %d rows, want 24", n)
+	}
+
+	var masked, nulls int
+	if err := db.QueryRow("SELECT COUNT(*) FROM items WHERE name LIKE '%*%'").Scan(&masked); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM items WHERE name IS NULL").Scan(&nulls); err != nil {
+		t.Fatal(err)
+	}
+	if masked != 24 || nulls != 1 {
+		t.Errorf("got %d masked and %d NULL names, want 24 and 1", masked, nulls)
+	}
+
+	var last string
+	if err := db.QueryRow("SELECT name FROM items WHERE id = 25").Scan(&last); err != nil {
+		t.Fatal(err)
+	}
+	if last != "*****24" {
+		t.Errorf("got %q, want %q", last, "*****24")
+	}
+}
+
+func TestAnonymizeColumn_Error(t *testing.T) {
+	db := openLuaTestDB(t, 5)
+	fail := func(v string) (any, error) {
+		if v == "item-3" {
+			return nil, errors.New("boom")
+		}
+		return "x", nil
+	}
+
+	n, err := golumn.AnonymizeColumn(context.Background(), db, golumn.AnonymizeOptions{Table: "items", Column: "name", BatchSize: 2}, fail)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected boom error, got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("rewrote %d rows, want the first batch of 2", n)
+	}
+
+	var rewritten int
+	if err := db.QueryRow("SELECT COUNT(*) FROM items WHERE name = 'x'").Scan(&rewritten); err != nil {
+		t.Fatal(err)
+	}
+	if rewritten != 2 {
+		t.Errorf("got %d rewritten rows, want the failing batch rolled back", rewritten)
+	}
+}
+
+func TestLuaAnonymize(t *testing.T) {
+	db := openLuaTestDB(t, 5)
+	m := mustParse(t, `local db = require "db"
+Version = 1
+Tags = {"anonymize"}
+function Up()
+	assert(db.anonymize("items", "name", {strategy = "email", salt = "s", batch = 2}) == 5)
+	assert(db.anonymize("items", "price", {strategy = function(v) return tonumber(v) * 0 end}) == 5)
+	assert(db.hash("a", "", 4) == "ca97")
+	assert(db.mask("secret") == "**cret")
+	local n, err = db.anonymize("missing", "name")
+	assert(n == nil and err:find("^anonymize: "), err)
+	db.anonymize("items", "name", {strategy = "scramble"})
+end
+function Down() end
+`)
+	if err := m.UpFunc(context.Background(), db); err == nil || !strings.Contains(err.Error(), `unknown strategy "scramble"`) {
+		t.Fatalf("expected unknown strategy error, got %v", err)
+	}
+
+	var emails int
+	if err := db.QueryRow("SELECT COUNT(*) FROM items WHERE name LIKE 'user\\_%@example.invalid' ESCAPE '\\'").Scan(&emails); err != nil {
+		t.Fatal(err)
+	}
+	if emails != 5 {
+		t.Errorf("got %d anonymized emails, want 5", emails)
+	}
+}
+
+func TestMigrator_AnonymizeLineage(t *testing.T) {
+	tagged := createMigrations(1, 2)
+	tagged[0].Tags = []string{golumn.TagAnonymize}
+
+	migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: tagged}
+	if err := migrator.Validate(); err == nil || !strings.Contains(err.Error(), "lineage of their own") {
+		t.Errorf("expected mixed lineage error, got %v", err)
+	}
+
+	tagged[1].Tags = []string{golumn.TagAnonymize}
+	if err := migrator.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestMigrator_AnonymizeProduction(t *testing.T) {
+	sources := createMigrations(1)
+	sources[0].Tags = []string{golumn.TagAnonymize}
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{
+		Store:          productionStore{fakeStore: store, production: true},
+		Sources:        sources,
+		ApprovalToken:  "approved",
+		VerifyApproval: func(context.Context, golumn.Approval) error { return nil },
+	}
+
+	_, err := migrator.Up(context.Background(), 1)
+	if !errors.Is(err, golumn.ErrAnonymizeProduction) {
+		t.Fatalf("expected ErrAnonymizeProduction, got %v", err)
+	}
+	if store.initCalls != 0 || store.lockCalls != 0 {
+		t.Errorf("expected store to be untouched, got %d init and %d lock calls", store.initCalls, store.lockCalls)
+	}
+
+	migrator.Store = productionStore{fakeStore: store}
+	if _, err := migrator.Up(context.Background(), 1); err != nil {
+		t.Errorf("unexpected error against a non-production store: %v", err)
+	}
+}
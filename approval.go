@@ -0,0 +1,47 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrApprovalRequired = errors.New("approval required for production store")
+
+// ProductionStore is implemented by stores that can be flagged as production.
+// Up and Down refuse to run against a store reporting IsProduction unless
+// Migrator.VerifyApproval accepts Migrator.ApprovalToken.
+type ProductionStore interface {
+	IsProduction() bool
+}
+
+// Approval describes a run awaiting verification, so that a verifier can
+// check that the token was issued for this direction and target, e.g. by a
+// second operator holding a release role.
+type Approval struct {
+	Direction Direction
+	Target    int64
+	Token     string
+}
+
+// approve verifies the run's approval if the store is flagged as production.
+// It fails closed: a production store with no token or no verifier is an
+// error.
+func (m *Migrator) approve(ctx context.Context, dir Direction, to int64) error {
+	ps, ok := m.Store.(ProductionStore)
+	if !ok || !ps.IsProduction() {
+		return nil
+	}
+	token := m.config(ctx).approvalToken
+	if token == "" {
+		return ErrApprovalRequired
+	}
+	if m.VerifyApproval == nil {
+		return fmt.Errorf("%w: no approval verifier configured", ErrApprovalRequired)
+	}
+	if err := m.VerifyApproval(ctx, Approval{Direction: dir, Target: to, Token: token}); err != nil {
+		return fmt.Errorf("approval rejected: %w", err)
+	}
+	m.logger().Verbosef("%s run to %d approved", dir, to)
+	return nil
+}
@@ -0,0 +1,34 @@
+package golumn
+
+import (
+	"context"
+	"time"
+)
+
+// AuditEvent records a single version change made by a Migrator.
+type AuditEvent struct {
+	Direction Direction
+	Version   int64
+	Name      string
+	// Owner is the migration's Owner.
+	Owner string
+	// Time is when the change was recorded, or zero for a Deterministic
+	// Migrator.
+	Time time.Time
+	// Stats counts the statements the migration ran for the change.
+	Stats StatementStats
+}
+
+// AuditSink receives a record of every version inserted into or removed from
+// the version store, for environments where the store alone is not an
+// acceptable audit record.
+type AuditSink interface {
+	Record(context.Context, AuditEvent) error
+}
+
+// AuditFunc adapts a function to an AuditSink.
+type AuditFunc func(context.Context, AuditEvent) error
+
+func (f AuditFunc) Record(ctx context.Context, ev AuditEvent) error {
+	return f(ctx, ev)
+}
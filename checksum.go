@@ -0,0 +1,164 @@
+package golumn
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/bits"
+)
+
+// ChecksumAlgorithm is a hash function used to compute Migration.Checksum,
+// which is Name, a colon and the hex digest.
+type ChecksumAlgorithm struct {
+	Name string
+	Sum  func(src []byte) []byte
+}
+
+var (
+	// SHA256 is the default checksum algorithm.
+	SHA256 = ChecksumAlgorithm{Name: "sha256", Sum: func(src []byte) []byte {
+		sum := sha256.Sum256(src)
+		return sum[:]
+	}}
+
+	// XXH64 is the 64-bit xxHash with a zero seed. It is much faster than
+	// SHA256 on large sources but not collision resistant, so it only
+	// guards against accidental edits.
+	XXH64 = ChecksumAlgorithm{Name: "xxh64", Sum: func(src []byte) []byte {
+		return binary.BigEndian.AppendUint64(nil, xxh64(src))
+	}}
+)
+
+// Canonicalizer rewrites a source before it is checksummed, so that
+// reformatting it changes nothing its checksum is compared against.
+type Canonicalizer func(src []byte) []byte
+
+// CanonicalLineEndings replaces CRLF and lone CR line endings with LF, so
+// that checking sources out on another OS does not change their checksums.
+func CanonicalLineEndings(src []byte) []byte {
+	if !bytes.ContainsRune(src, '\r') {
+		return src
+	}
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(src, []byte("\r"), []byte("\n"))
+}
+
+// CanonicalWhitespace applies CanonicalLineEndings, strips trailing spaces
+// and tabs from each line and ends the source with exactly one newline.
+// Whitespace inside string literals is stripped too, so a migration whose
+// behaviour depends on it should not be checksummed this way.
+func CanonicalWhitespace(src []byte) []byte {
+	lines := bytes.Split(CanonicalLineEndings(src), []byte("\n"))
+	for i, line := range lines {
+		lines[i] = bytes.TrimRight(line, " \t")
+	}
+	out := bytes.TrimRight(bytes.Join(lines, []byte("\n")), "\n")
+	if len(out) == 0 {
+		return out
+	}
+	return append(out, '\n')
+}
+
+// Checksum configures how Migration.Checksum is computed. The zero value
+// hashes the source as is with SHA256.
+type Checksum struct {
+	// Algorithm is the hash function; the zero value means SHA256.
+	Algorithm ChecksumAlgorithm
+	// Canonicalize, if set, rewrites the source before it is hashed.
+	Canonicalize Canonicalizer
+}
+
+// Sum returns the checksum of src.
+func (c Checksum) Sum(src []byte) string {
+	alg := c.Algorithm
+	if alg.Sum == nil {
+		alg = SHA256
+	}
+	if c.Canonicalize != nil {
+		src = c.Canonicalize(src)
+	}
+	return alg.Name + ":" + hex.EncodeToString(alg.Sum(src))
+}
+
+type checksumContextKey struct{}
+
+// WithChecksum returns a context whose migrations are checksummed as c
+// configures when parsed by Parse, GlobLoader, MigrationSource or GenEmbed.
+func WithChecksum(ctx context.Context, c Checksum) context.Context {
+	return context.WithValue(ctx, checksumContextKey{}, c)
+}
+
+func checksumFromContext(ctx context.Context) Checksum {
+	c, _ := ctx.Value(checksumContextKey{}).(Checksum)
+	return c
+}
+
+// The XXH64 primes are variables so that the seed arithmetic below wraps
+// instead of overflowing as constant expressions.
+var (
+	xxhPrime1 uint64 = 11400714785074694791
+	xxhPrime2 uint64 = 14029467366897019727
+	xxhPrime3 uint64 = 1609587929392839161
+	xxhPrime4 uint64 = 9650029242287828579
+	xxhPrime5 uint64 = 2870177450012600261
+)
+
+// xxh64 returns the XXH64 digest of b with a zero seed.
+func xxh64(b []byte) uint64 {
+	n := uint64(len(b))
+	var h uint64
+	if len(b) >= 32 {
+		v1 := xxhPrime1 + xxhPrime2
+		v2 := xxhPrime2
+		v3 := uint64(0)
+		v4 := -xxhPrime1
+		for ; len(b) >= 32; b = b[32:] {
+			v1 = xxhRound(v1, binary.LittleEndian.Uint64(b[0:8]))
+			v2 = xxhRound(v2, binary.LittleEndian.Uint64(b[8:16]))
+			v3 = xxhRound(v3, binary.LittleEndian.Uint64(b[16:24]))
+			v4 = xxhRound(v4, binary.LittleEndian.Uint64(b[24:32]))
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxhMergeRound(h, v1)
+		h = xxhMergeRound(h, v2)
+		h = xxhMergeRound(h, v3)
+		h = xxhMergeRound(h, v4)
+	} else {
+		h = xxhPrime5
+	}
+	h += n
+
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxhRound(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*xxhPrime1 + xxhPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * xxhPrime1
+		h = bits.RotateLeft64(h, 23)*xxhPrime2 + xxhPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxhPrime5
+		h = bits.RotateLeft64(h, 11) * xxhPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxhPrime2
+	h ^= h >> 29
+	h *= xxhPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxhRound(acc, input uint64) uint64 {
+	acc += input * xxhPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime1
+}
+
+func xxhMergeRound(acc, val uint64) uint64 {
+	acc ^= xxhRound(0, val)
+	return acc*xxhPrime1 + xxhPrime4
+}
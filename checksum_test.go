@@ -0,0 +1,91 @@
+package golumn_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestChecksum_Algorithms(t *testing.T) {
+	for _, tt := range []struct {
+		c    golumn.Checksum
+		src  string
+		want string
+	}{
+		{golumn.Checksum{}, "", "sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{golumn.Checksum{Algorithm: golumn.XXH64}, "", "xxh64:ef46db3751d8e999"},
+		{golumn.Checksum{Algorithm: golumn.XXH64}, "abc", "xxh64:44bc2cf5ad770999"},
+		{golumn.Checksum{Algorithm: golumn.XXH64}, "The quick brown fox jumps over the lazy dog", "xxh64:0b242d361fda71bc"},
+	} {
+		if got := tt.c.Sum([]byte(tt.src)); got != tt.want {
+			t.Errorf("%s sum of %q = %s, want %s", tt.c.Algorithm.Name, tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestChecksum_Canonicalize(t *testing.T) {
+	unix := "-- +golumn Up\nSELECT 1;\n"
+	for _, tt := range []struct {
+		name  string
+		canon golumn.Canonicalizer
+		src   string
+		same  bool
+	}{
+		{"crlf", golumn.CanonicalLineEndings, "-- +golumn Up\r\nSELECT 1;\r\n", true},
+		{"cr", golumn.CanonicalLineEndings, "-- +golumn Up\rSELECT 1;\r", true},
+		{"trailing space kept", golumn.CanonicalLineEndings, "-- +golumn Up \nSELECT 1;\n", false},
+		{"trailing space", golumn.CanonicalWhitespace, "-- +golumn Up \t\r\nSELECT 1;  \r\n\r\n\n", true},
+		{"missing newline", golumn.CanonicalWhitespace, "-- +golumn Up\nSELECT 1;", true},
+		{"edit", golumn.CanonicalWhitespace, "-- +golumn Up\nSELECT 2;\n", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := golumn.Checksum{Canonicalize: tt.canon}
+			if same := c.Sum([]byte(tt.src)) == c.Sum([]byte(unix)); same != tt.same {
+				t.Errorf("expected same checksum to be %v", tt.same)
+			}
+		})
+	}
+}
+
+func TestWithChecksum(t *testing.T) {
+	ctx := golumn.WithChecksum(context.Background(), golumn.Checksum{Algorithm: golumn.XXH64, Canonicalize: golumn.CanonicalLineEndings})
+
+	luaSrc := "Version=1\r\nfunction Up() end\r\nfunction Down() end\r\n"
+	lua, err := golumn.Parse(ctx, strings.NewReader(luaSrc), "0001_init.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := golumn.XXH64.Name + ":"; !strings.HasPrefix(lua.Checksum, want) {
+		t.Errorf("expected an %s checksum, got %q", want, lua.Checksum)
+	}
+	unix, err := golumn.Parse(ctx, strings.NewReader(strings.ReplaceAll(luaSrc, "\r\n", "\n")), "0001_init.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lua.Checksum != unix.Checksum {
+		t.Errorf("expected line endings not to change the checksum, got %q and %q", lua.Checksum, unix.Checksum)
+	}
+
+	sqlSrc := "-- +golumn Up\r\nSELECT 1;\r\n"
+	sql, err := golumn.MigrationSource{
+		Version: 2,
+		Name:    "0002_select.sql",
+		Open:    func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(sqlSrc)), nil },
+	}.Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := golumn.XXH64.Name + ":"; !strings.HasPrefix(sql.Checksum, want) {
+		t.Errorf("expected an %s checksum, got %q", want, sql.Checksum)
+	}
+	parsed, err := golumn.ParseSQL(ctx, strings.NewReader(sqlSrc), "0002_select.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Checksum != sql.Checksum {
+		t.Errorf("expected ParseSQL to checksum as configured, got %q, want %q", parsed.Checksum, sql.Checksum)
+	}
+}
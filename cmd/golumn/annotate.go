@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// annotateDefault reports whether to emit GitHub Actions annotations when
+// -annotate is not given: when running under GitHub Actions.
+func annotateDefault() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+type annotateKey struct{}
+
+func withAnnotate(ctx context.Context, annotate bool) context.Context {
+	return context.WithValue(ctx, annotateKey{}, annotate)
+}
+
+func annotating(ctx context.Context) bool {
+	annotate, _ := ctx.Value(annotateKey{}).(bool)
+	return annotate
+}
+
+// dirError records the migrations directory err arose in, so annotations
+// can give paths to source files. It does not change err's message.
+type dirError struct {
+	dir string
+	err error
+}
+
+func (e *dirError) Error() string {
+	return e.err.Error()
+}
+
+func (e *dirError) Unwrap() error {
+	return e.err
+}
+
+func inDir(dir string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &dirError{dir: dir, err: err}
+}
+
+// annotation is a GitHub Actions workflow command. File and Line are
+// omitted when empty.
+type annotation struct {
+	Level   string
+	File    string
+	Line    int
+	Message string
+}
+
+func (a annotation) String() string {
+	var props []string
+	if a.File != "" {
+		props = append(props, "file="+escapeProperty(a.File))
+	}
+	if a.Line > 0 {
+		props = append(props, fmt.Sprintf("line=%d", a.Line))
+	}
+	props = append(props, "title=golumn")
+	return fmt.Sprintf("::%s %s::%s", a.Level, strings.Join(props, ","), escapeData(a.Message))
+}
+
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// errorAnnotations returns an ::error annotation for each failed step in
+// err, or for err itself if it has none, attributed to the source file and
+// line where known.
+func errorAnnotations(err error) []annotation {
+	var dir string
+	if de := (*dirError)(nil); errors.As(err, &de) {
+		dir = de.dir
+	}
+	path := func(name string) string {
+		if name == "" || dir == "" {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+
+	var steps []*golumn.StepError
+	walkErrors(err, func(err error) {
+		if step, ok := err.(*golumn.StepError); ok {
+			steps = append(steps, step)
+		}
+	})
+
+	if len(steps) == 0 {
+		a := annotation{Level: "error", Message: err.Error()}
+		if se := (*golumn.SourceError)(nil); errors.As(err, &se) {
+			a.File, a.Line = path(se.File), se.Line
+		}
+		return []annotation{a}
+	}
+
+	annotations := make([]annotation, len(steps))
+	for i, step := range steps {
+		a := annotation{Level: "error", File: path(step.Name), Message: step.Error()}
+		if se := (*golumn.SourceError)(nil); errors.As(step, &se) {
+			a.File, a.Line = path(se.File), se.Line
+		}
+		annotations[i] = a
+	}
+	return annotations
+}
+
+// walkErrors calls fn for err and every error it wraps, depth first.
+func walkErrors(err error, fn func(error)) {
+	if err == nil {
+		return
+	}
+	fn(err)
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		walkErrors(u.Unwrap(), fn)
+	case interface{ Unwrap() []error }:
+		for _, err := range u.Unwrap() {
+			walkErrors(err, fn)
+		}
+	}
+}
+
+func writeAnnotations(w io.Writer, annotations []annotation) {
+	for _, a := range annotations {
+		fmt.Fprintln(w, a)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestErrorAnnotations(t *testing.T) {
+	err := inDir("migrations", &golumn.MultiError{Steps: []*golumn.StepError{
+		{Version: 2, Name: "0002_b.lua", Phase: golumn.PhaseApply, Err: &golumn.SourceError{File: "0002_b.lua", Line: 7, Err: errors.New("boom")}},
+		{Version: 1, Name: "0001_a.sql", Phase: golumn.PhaseAutoRevert, Err: errors.New("down error")},
+	}})
+
+	got := errorAnnotations(err)
+	want := []annotation{
+		{Level: "error", File: filepath.Join("migrations", "0002_b.lua"), Line: 7, Message: "failed to apply migration 2: 0002_b.lua:7: boom"},
+		{Level: "error", File: filepath.Join("migrations", "0001_a.sql"), Message: "failed to auto-revert migration 1: down error"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d annotations, want %d: %v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("annotation %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	got = errorAnnotations(errors.New("database unavailable"))
+	if len(got) != 1 || got[0].File != "" || got[0].Message != "database unavailable" {
+		t.Errorf("unexpected annotations: %+v", got)
+	}
+}
+
+func TestAnnotation_String(t *testing.T) {
+	a := annotation{Level: "error", File: "a,b:c.sql", Line: 3, Message: "100% broken\nsee above"}
+	want := "::error file=a%2Cb%3Ac.sql,line=3,title=golumn::100%25 broken%0Asee above"
+	if got := a.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRun_Annotate(t *testing.T) {
+	dir := writeMigrations(t)
+	src := "Version=4\nfunction Up()\n  error(\"boom\")\nend\nfunction Down() end\n"
+	if err := os.WriteFile(filepath.Join(dir, "0004_fail.lua"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dsn := filepath.Join(t.TempDir(), "db.sqlite")
+
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), []string{"-annotate", "run", "-dir", dir, "-db", dsn, "up", "latest"}, &stdout, &stderr)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	out := stdout.String()
+	wantError := "::error file=" + escapeProperty(filepath.Join(dir, "0004_fail.lua")) + ",line=3,title=golumn::failed to apply migration 4: 0004_fail.lua:3: boom"
+	if !strings.Contains(out, wantError) {
+		t.Errorf("output missing %q:\n%s", wantError, out)
+	}
+	if !strings.Contains(out, "::notice title=golumn::up: 3 applied") {
+		t.Errorf("output missing result notice:\n%s", out)
+	}
+}
+
+func TestRun_AnnotateParseError(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "0001_a.sql"), []byte("-- +golumn Up\n-- +golumn Sideways\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"-annotate", "versions", "-dir", dir}, &stdout, &stderr); err == nil {
+		t.Fatal("expected error")
+	}
+	want := "::error file=" + escapeProperty(filepath.Join(dir, "0001_a.sql")) + ",line=2,title=golumn::"
+	if !strings.HasPrefix(stdout.String(), want) {
+		t.Errorf("got %q, want prefix %q", stdout.String(), want)
+	}
+
+	stdout.Reset()
+	if err := run(context.Background(), []string{"-annotate=false", "versions", "-dir", dir}, &stdout, &stderr); err == nil {
+		t.Fatal("expected error")
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("unexpected output with -annotate=false: %q", stdout.String())
+	}
+}
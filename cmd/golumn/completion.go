@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Completion scripts complete subcommands and shell names statically and
+// take describe's and run's versions from "golumn versions", which reads $GOLUMN_DIR.
+var completions = map[string]string{
+	"bash": `_golumn() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "gen describe versions fmt completion run" -- "$cur"))
+		return
+	fi
+	case ${COMP_WORDS[1]} in
+	gen)
+		if [ "$COMP_CWORD" -eq 2 ]; then
+			COMPREPLY=($(compgen -W "embed" -- "$cur"))
+		else
+			COMPREPLY=($(compgen -d -- "$cur"))
+		fi
+		;;
+	describe)
+		COMPREPLY=($(compgen -W "$(golumn versions 2>/dev/null | cut -f1)" -- "$cur"))
+		;;
+	completion)
+		COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+		;;
+	run)
+		COMPREPLY=($(compgen -W "up down latest pinned initial $(golumn versions 2>/dev/null | cut -f1)" -- "$cur"))
+		;;
+	esac
+}
+complete -F _golumn golumn
+`,
+	"zsh": `#compdef golumn
+_golumn() {
+	local -a versions
+	if (( CURRENT == 2 )); then
+		_values command gen describe versions fmt completion run
+		return
+	fi
+	case $words[2] in
+	gen)
+		if (( CURRENT == 3 )); then
+			_values command embed
+		else
+			_files -/
+		fi
+		;;
+	describe)
+		versions=(${(f)"$(golumn versions 2>/dev/null | tr '\t' ':')"})
+		_describe version versions
+		;;
+	completion)
+		_values shell bash zsh fish
+		;;
+	run)
+		versions=(up down latest pinned initial ${(f)"$(golumn versions 2>/dev/null | tr '\t' ':')"})
+		_describe target versions
+		;;
+	esac
+}
+compdef _golumn golumn
+`,
+	"fish": `complete -c golumn -f
+complete -c golumn -n __fish_use_subcommand -a 'gen describe versions fmt completion run'
+complete -c golumn -n '__fish_seen_subcommand_from gen' -a embed
+complete -c golumn -n '__fish_seen_subcommand_from describe' -a '(golumn versions 2>/dev/null)'
+complete -c golumn -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+complete -c golumn -n '__fish_seen_subcommand_from run' -a 'up down latest pinned initial (golumn versions 2>/dev/null)'
+`,
+}
+
+func completion(args []string, stdout, stderr io.Writer) error {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageCompletion))
+		return errUsage
+	}
+	script, ok := completions[args[0]]
+	if !ok {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUnknownShell, args[0]))
+		return errUsage
+	}
+	_, err := io.WriteString(stdout, script)
+	return err
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// previewLines bounds the source shown by describe.
+const previewLines = 20
+
+// dirEnv names the environment variable giving the default migrations
+// directory, so completion scripts can discover versions without flags.
+const dirEnv = "GOLUMN_DIR"
+
+func defaultDir() string {
+	if dir := os.Getenv(dirEnv); dir != "" {
+		return dir
+	}
+	return "."
+}
+
+// dirMigration is a migration parsed from a file in a migrations directory.
+type dirMigration struct {
+	*golumn.Migration
+	Path   string
+	Source []byte
+}
+
+// readDir parses the .lua and .sql files at the top level of dir, sorted by
+// version. Templated files are expanded with the process environment.
+func readDir(ctx context.Context, dir string) ([]dirMigration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []dirMigration
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || (ext != ".lua" && ext != ".sql") {
+			continue
+		}
+
+		p := filepath.Join(dir, name)
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if src, err = golumn.Preprocess(src, name, nil); err != nil {
+			return nil, inDir(dir, err)
+		}
+
+		var m *golumn.Migration
+		if ext == ".sql" {
+			m, err = golumn.ParseSQL(ctx, bytes.NewReader(src), name)
+		} else {
+			m, err = golumn.Parse(ctx, bytes.NewReader(src), name)
+		}
+		if err != nil {
+			return nil, inDir(dir, err)
+		}
+		migrations = append(migrations, dirMigration{Migration: m, Path: p, Source: src})
+	}
+
+	slices.SortStableFunc(migrations, func(a, b dirMigration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+func versions(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("versions", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", defaultDir(), messages.Sprintf(msgFlagDir))
+	asJSON := fs.Bool("json", false, messages.Sprintf(msgFlagJSON))
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageVersions))
+		return errUsage
+	}
+
+	migrations, err := readDir(ctx, *dir)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		sources := make([]*golumn.Migration, len(migrations))
+		for i, m := range migrations {
+			sources[i] = m.Migration
+		}
+		data, err := golumn.MarshalMetadata(sources)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", data)
+		return err
+	}
+	for _, m := range migrations {
+		fmt.Fprintf(stdout, "%d\t%s\n", m.Version, m.Name)
+	}
+	return nil
+}
+
+func describe(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("describe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", defaultDir(), messages.Sprintf(msgFlagDir))
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageDescribe))
+		return errUsage
+	}
+	version, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageDescribe))
+		return errUsage
+	}
+
+	migrations, err := readDir(ctx, *dir)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(migrations, func(m dirMigration) bool { return m.Version == version })
+	if i < 0 {
+		return fmt.Errorf("missing migration for version: %d", version)
+	}
+	m := migrations[i]
+
+	fmt.Fprintln(stdout, messages.Sprintf(msgDescribeVersion, m.Version))
+	fmt.Fprintln(stdout, messages.Sprintf(msgDescribeName, m.Name))
+	fmt.Fprintln(stdout, messages.Sprintf(msgDescribePath, m.Path))
+	if len(m.DependsOn) > 0 {
+		deps := make([]string, len(m.DependsOn))
+		for i, v := range m.DependsOn {
+			deps[i] = strconv.FormatInt(v, 10)
+		}
+		fmt.Fprintln(stdout, messages.Sprintf(msgDescribeDependsOn, strings.Join(deps, ", ")))
+	}
+	fmt.Fprintln(stdout)
+
+	sc := bufio.NewScanner(bytes.NewReader(m.Source))
+	n := 0
+	for sc.Scan() {
+		if n < previewLines {
+			fmt.Fprintln(stdout, sc.Text())
+		}
+		n++
+	}
+	if n > previewLines {
+		fmt.Fprintln(stdout, messages.Sprintf(msgDescribeTruncated, n-previewLines))
+	}
+	return sc.Err()
+}
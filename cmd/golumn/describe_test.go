@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func writeMigrations(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"0001_init.sql":  "-- +golumn Up\nCREATE TABLE a (id INTEGER);\n-- +golumn Down\nDROP TABLE a;\n",
+		"0002_index.sql": "-- +golumn DependsOn 1\n-- +golumn Up\n" + strings.Repeat("SELECT 1;\n", 30),
+		"0003_seed.lua":  "Version=3\nfunction Up() end\nfunction Down() end\n",
+		"README.md":      "not a migration\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestVersions(t *testing.T) {
+	dir := writeMigrations(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"versions", "-dir", dir}, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "1\t0001_init.sql\n2\t0002_index.sql\n3\t0003_seed.lua\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestVersions_JSON(t *testing.T) {
+	dir := writeMigrations(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"versions", "-dir", dir, "-json"}, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	metadata, err := golumn.UnmarshalMetadata(stdout.Bytes())
+	if err != nil {
+		t.Fatalf("invalid output %q: %v", stdout.String(), err)
+	}
+	if len(metadata) != 3 || metadata[1].Name != "0002_index.sql" || !slices.Equal(metadata[1].DependsOn, []int64{1}) || metadata[1].Checksum == "" {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+}
+
+func TestVersions_DirEnv(t *testing.T) {
+	t.Setenv(dirEnv, writeMigrations(t))
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"versions"}, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := strings.Count(stdout.String(), "\n"); n != 3 {
+		t.Errorf("got %d versions, want 3", n)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	dir := writeMigrations(t)
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"describe", "-dir", dir, "2"}, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := stdout.String()
+	for _, want := range []string{
+		"version:    2\n",
+		"name:       0002_index.sql\n",
+		"path:       " + filepath.Join(dir, "0002_index.sql") + "\n",
+		"depends on: 1\n",
+		"-- +golumn Up\n",
+		"... (12 more lines)\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "SELECT 1;"); n != 18 {
+		t.Errorf("previewed %d statements, want 18", n)
+	}
+}
+
+func TestDescribe_Errors(t *testing.T) {
+	dir := writeMigrations(t)
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantUsage bool
+	}{
+		{"missing_version", []string{"describe", "-dir", dir}, true},
+		{"invalid_version", []string{"describe", "-dir", dir, "one"}, true},
+		{"unknown_version", []string{"describe", "-dir", dir, "9"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(context.Background(), tt.args, &stdout, &stderr)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got := errors.Is(err, errUsage); got != tt.wantUsage {
+				t.Errorf("usage error = %v, want %v: %v", got, tt.wantUsage, err)
+			}
+		})
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		t.Run(shell, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(context.Background(), []string{"completion", shell}, &stdout, &stderr); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.Contains(stdout.String(), "golumn versions") {
+				t.Errorf("script does not complete versions:\n%s", stdout.String())
+			}
+		})
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"completion", "tcsh"}, &stdout, &stderr); !errors.Is(err, errUsage) {
+		t.Errorf("got %v, want usage error", err)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// formatDir formats the .sql files and checks the structure of the .lua
+// files at the top level of dir, printing the path of each .sql file that
+// is not canonical and each .lua structure issue. With write, it rewrites
+// the .sql files instead of counting them as failures.
+func formatDir(dir string, write bool, stdout io.Writer) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	unformatted, issues := 0, 0
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || (ext != ".lua" && ext != ".sql") {
+			continue
+		}
+
+		p := filepath.Join(dir, name)
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		if ext == ".sql" {
+			formatted, err := golumn.FormatSQL(src, name)
+			if err != nil {
+				return inDir(dir, err)
+			}
+			if bytes.Equal(formatted, src) {
+				continue
+			}
+			fmt.Fprintln(stdout, p)
+			if !write {
+				unformatted++
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(p, formatted, info.Mode().Perm()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if src, err = golumn.Preprocess(src, name, nil); err != nil {
+			return inDir(dir, err)
+		}
+		found, err := golumn.CheckLuaStructure(src, name)
+		if err != nil {
+			return inDir(dir, err)
+		}
+		for _, issue := range found {
+			issue.File = p
+			fmt.Fprintln(stdout, issue)
+		}
+		issues += len(found)
+	}
+
+	switch {
+	case unformatted > 0 && issues > 0:
+		return fmt.Errorf("%d files not formatted, %d structure issues", unformatted, issues)
+	case unformatted > 0:
+		return fmt.Errorf("%d files not formatted", unformatted)
+	case issues > 0:
+		return fmt.Errorf("%d structure issues", issues)
+	}
+	return nil
+}
+
+func format(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", defaultDir(), messages.Sprintf(msgFlagDir))
+	write := fs.Bool("w", false, messages.Sprintf(msgFlagWrite))
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageFmt))
+		return errUsage
+	}
+	return formatDir(*dir, *write, stdout)
+}
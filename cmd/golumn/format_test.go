@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFmt(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"0001_init.sql":  "-- +golumn Up\ncreate table a (id integer)\n",
+		"0002_index.sql": "-- +golumn Up\nCREATE INDEX a_id ON a (id);\n",
+		"0003_seed.lua":  "Version=3\nfunction Up() end\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), []string{"-annotate=false", "fmt", "-dir", dir}, &stdout, &stderr)
+	if err == nil || err.Error() != "1 files not formatted, 1 structure issues" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	want := filepath.Join(dir, "0001_init.sql") + "\n" + filepath.Join(dir, "0003_seed.lua") + ":1: missing Down function (structure)\n"
+	if got := stdout.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	stdout.Reset()
+	err = run(context.Background(), []string{"-annotate=false", "fmt", "-dir", dir, "-w"}, &stdout, &stderr)
+	if err == nil || err.Error() != "1 structure issues" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	src, err := os.ReadFile(filepath.Join(dir, "0001_init.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "-- +golumn Up\nCREATE TABLE a (id INTEGER);\n"; string(src) != want {
+		t.Errorf("got %q, want %q", src, want)
+	}
+	if !strings.HasPrefix(stdout.String(), filepath.Join(dir, "0001_init.sql")+"\n") {
+		t.Errorf("rewritten file not listed: %q", stdout.String())
+	}
+}
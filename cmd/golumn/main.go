@@ -0,0 +1,302 @@
+// Command golumn manages golumn migration sources.
+//
+// Usage:
+//
+//	golumn [-annotate] [-deterministic] <command> [arguments]
+//
+//	golumn gen embed [-pkg name] [-var name] <dir>
+//	golumn versions [-dir dir] [-json]
+//	golumn describe [-dir dir] <version>
+//	golumn fmt [-dir dir] [-w]
+//	golumn completion bash|zsh|fish
+//	golumn run [-dir dir] [-db dsn] [-wait-for-db] [-timeout d] [-state file] [-pin file] [-env name] [-identity id] [-lua-profile file] [-dry-run [-explain]] up|down <target>
+//
+// run migrates the SQLite database at -db, defaulting to $GOLUMN_DB, to a
+// version, "latest" or "initial", and is meant for one-shot use such as an
+// init container. With -wait-for-db it first pings the database with backoff
+// until it responds or -timeout expires. With -state it writes the applied
+// versions and a fingerprint of their sources to a JSON file after a
+// successful run, and exits without touching the database when the file
+// already matches the requested target. With -dry-run it prints the plan
+// instead of migrating, and with -explain also the database's query plan for
+// each SQL statement. -env, defaulting to $GOLUMN_ENV, names the environment
+// of the run, which decides whether migrations restricted by a
+// "-- +golumn Env=" comment run; without one, runs with such migrations
+// fail. -identity, defaulting to $GOLUMN_IDENTITY,
+// makes the run fail unless the database's identity, the UUID in its
+// schema_identity table, matches, so a job cannot migrate the wrong
+// database. -lua-profile writes the time spent in
+// each Lua function and db call of the Lua migrations run to a file, in the
+// folded stack format of flame graph tools.
+//
+// With -annotate, which defaults to true under GitHub Actions, failures are
+// also written to stdout as ::error workflow commands giving the source file
+// and line, and run results as ::notice commands, so they appear on the
+// checks of a pull request.
+//
+// With -deterministic, which defaults to $GOLUMN_DETERMINISTIC, output
+// depends only on the migrations and the database, as validated
+// environments require: run summaries report no durations, and -lua-profile
+// is refused. golumn makes no network calls besides opening the database.
+//
+// fmt prints the paths of .sql migrations that are not in the canonical
+// form of golumn.FormatSQL, rewriting them with -w, and the issues
+// golumn.CheckLuaStructure finds in .lua migrations. It fails if it printed
+// anything other than rewritten paths.
+//
+// versions, describe, fmt and run read the migrations directory given by -dir, which
+// defaults to $GOLUMN_DIR or the current directory. Completion scripts use
+// it to offer the available versions.
+//
+// Exit codes:
+//
+//	0  success
+//	1  usage error
+//	2  migration failure, and any other error
+//	3  version store locked by another run
+//	4  version store left locked by a failed run (dirty)
+//	5  version store records versions with no source (drift)
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+var errUsage = errors.New("usage")
+
+const (
+	exitOK = iota
+	exitUsage
+	exitFailure
+	exitLocked
+	exitDirty
+	exitDrift
+)
+
+// exitCode classifies err for the process exit status.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, golumn.ErrDirty):
+		return exitDirty
+	case errors.Is(err, golumn.ErrLocked):
+		return exitLocked
+	case errors.Is(err, golumn.ErrDrift):
+		return exitDrift
+	default:
+		return exitFailure
+	}
+}
+
+const (
+	msgError         golumn.MessageID = "cli.error"
+	msgUsage         golumn.MessageID = "cli.usage"
+	msgUsageGenEmbed golumn.MessageID = "cli.usage.gen_embed"
+	msgUnknownGen    golumn.MessageID = "cli.unknown_gen"
+	msgFlagPkg       golumn.MessageID = "cli.flag.pkg"
+	msgFlagVar       golumn.MessageID = "cli.flag.var"
+
+	msgUsageVersions     golumn.MessageID = "cli.usage.versions"
+	msgUsageDescribe     golumn.MessageID = "cli.usage.describe"
+	msgUsageCompletion   golumn.MessageID = "cli.usage.completion"
+	msgUsageFmt          golumn.MessageID = "cli.usage.fmt"
+	msgFlagWrite         golumn.MessageID = "cli.flag.write"
+	msgUnknownShell      golumn.MessageID = "cli.unknown_shell"
+	msgFlagDir           golumn.MessageID = "cli.flag.dir"
+	msgFlagJSON          golumn.MessageID = "cli.flag.json"
+	msgDescribeVersion   golumn.MessageID = "cli.describe.version"
+	msgDescribeName      golumn.MessageID = "cli.describe.name"
+	msgDescribePath      golumn.MessageID = "cli.describe.path"
+	msgDescribeDependsOn golumn.MessageID = "cli.describe.depends_on"
+	msgDescribeTruncated golumn.MessageID = "cli.describe.truncated"
+
+	msgUsageRun          golumn.MessageID = "cli.usage.run"
+	msgFlagDB            golumn.MessageID = "cli.flag.db"
+	msgFlagWaitForDB     golumn.MessageID = "cli.flag.wait_for_db"
+	msgFlagTimeout       golumn.MessageID = "cli.flag.timeout"
+	msgFlagState         golumn.MessageID = "cli.flag.state"
+	msgFlagAnnotate      golumn.MessageID = "cli.flag.annotate"
+	msgFlagDeterministic golumn.MessageID = "cli.flag.deterministic"
+	msgFlagDryRun        golumn.MessageID = "cli.flag.dry_run"
+	msgFlagExplain       golumn.MessageID = "cli.flag.explain"
+	msgFlagPin           golumn.MessageID = "cli.flag.pin"
+	msgFlagEnv           golumn.MessageID = "cli.flag.env"
+	msgFlagIdentity      golumn.MessageID = "cli.flag.identity"
+	msgFlagLuaProfile    golumn.MessageID = "cli.flag.lua_profile"
+)
+
+// messages holds the CLI's user-facing text.
+var messages = golumn.Catalog{
+	msgError: "golumn: %v",
+	msgUsage: `usage: golumn [-annotate] [-deterministic] <command> [arguments]
+
+commands:
+  golumn gen embed [-pkg name] [-var name] <dir>
+  golumn versions [-dir dir] [-json]
+  golumn describe [-dir dir] <version>
+  golumn fmt [-dir dir] [-w]
+  golumn completion bash|zsh|fish
+  golumn run [-dir dir] [-db dsn] [-wait-for-db] [-timeout d] [-state file] [-pin file] [-env name] [-identity id] [-lua-profile file] [-dry-run [-explain]] up|down <target>`,
+	msgUsageGenEmbed: "usage: golumn gen embed [-pkg name] [-var name] <dir>",
+	msgUnknownGen:    "golumn: unknown gen command %q",
+	msgFlagPkg:       "package name of the generated file (default: directory name)",
+	msgFlagVar:       "name of the generated variable",
+
+	msgUsageVersions:     "usage: golumn versions [-dir dir] [-json]",
+	msgUsageDescribe:     "usage: golumn describe [-dir dir] <version>",
+	msgUsageCompletion:   "usage: golumn completion bash|zsh|fish",
+	msgUsageFmt:          "usage: golumn fmt [-dir dir] [-w]",
+	msgFlagWrite:         "rewrite .sql files that are not formatted",
+	msgUnknownShell:      "golumn: unknown shell %q",
+	msgFlagDir:           "migrations directory (default: $GOLUMN_DIR or .)",
+	msgFlagJSON:          "print migration metadata as JSON (schema " + golumn.MetadataSchema + ")",
+	msgDescribeVersion:   "version:    %d",
+	msgDescribeName:      "name:       %s",
+	msgDescribePath:      "path:       %s",
+	msgDescribeDependsOn: "depends on: %s",
+	msgDescribeTruncated: "... (%d more lines)",
+
+	msgUsageRun:          "usage: golumn run [-dir dir] [-db dsn] [-wait-for-db] [-timeout d] [-state file] [-pin file] [-env name] [-identity id] [-lua-profile file] [-dry-run [-explain]] up|down <version|latest|pinned|initial>",
+	msgFlagDB:            "SQLite database DSN (default: $GOLUMN_DB)",
+	msgFlagWaitForDB:     "wait for the database to respond before migrating",
+	msgFlagTimeout:       "limit on the whole run, including waiting (0 for none)",
+	msgFlagState:         "state file to write after a run, and to skip the run when it matches",
+	msgFlagAnnotate:      "write GitHub Actions annotations to stdout (default: true under GitHub Actions)",
+	msgFlagDeterministic: "make output reproducible, without durations or profiles (default: $GOLUMN_DETERMINISTIC)",
+	msgFlagDryRun:        "print the plan instead of migrating",
+	msgFlagExplain:       "with -dry-run, include the query plan of each SQL statement",
+	msgFlagPin:           "pin file holding the highest version up may apply; a missing file pins nothing",
+	msgFlagEnv:           "environment of the run, for migrations restricted to environments (default: $GOLUMN_ENV)",
+	msgFlagIdentity:      "identity the database must have for the run to proceed (default: $GOLUMN_IDENTITY)",
+	msgFlagLuaProfile:    "file to write a folded-stack profile of the Lua migrations run to",
+}
+
+func main() {
+	if err := run(context.Background(), os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, messages.Sprintf(msgError, err))
+		}
+		os.Exit(exitCode(err))
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("golumn", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprintln(stderr, messages.Sprintf(msgUsage)) }
+	annotate := fs.Bool("annotate", annotateDefault(), messages.Sprintf(msgFlagAnnotate))
+	deterministic := fs.Bool("deterministic", deterministicDefault(), messages.Sprintf(msgFlagDeterministic))
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	ctx = withAnnotate(ctx, *annotate)
+	ctx = withDeterministic(ctx, *deterministic)
+
+	err := command(ctx, fs.Args(), stdout, stderr)
+	if err != nil && *annotate && !errors.Is(err, errUsage) {
+		writeAnnotations(stdout, errorAnnotations(err))
+	}
+	return err
+}
+
+// deterministicEnv names the environment variable enabling -deterministic
+// by default.
+const deterministicEnv = "GOLUMN_DETERMINISTIC"
+
+func deterministicDefault() bool {
+	on, _ := strconv.ParseBool(os.Getenv(deterministicEnv))
+	return on
+}
+
+type deterministicKey struct{}
+
+func withDeterministic(ctx context.Context, deterministic bool) context.Context {
+	return context.WithValue(ctx, deterministicKey{}, deterministic)
+}
+
+func isDeterministic(ctx context.Context) bool {
+	deterministic, _ := ctx.Value(deterministicKey{}).(bool)
+	return deterministic
+}
+
+func command(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsage))
+		return errUsage
+	}
+
+	switch args[0] {
+	case "gen":
+		return gen(ctx, args[1:], stdout, stderr)
+	case "versions":
+		return versions(ctx, args[1:], stdout, stderr)
+	case "describe":
+		return describe(ctx, args[1:], stdout, stderr)
+	case "fmt":
+		return format(args[1:], stdout, stderr)
+	case "completion":
+		return completion(args[1:], stdout, stderr)
+	case "run":
+		return runMigrations(ctx, args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsage))
+		return errUsage
+	}
+}
+
+func gen(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsage))
+		return errUsage
+	}
+
+	switch args[0] {
+	case "embed":
+		return genEmbed(ctx, args[1:], stdout, stderr)
+	default:
+		fmt.Fprintln(stderr, messages.Sprintf(msgUnknownGen, args[0]))
+		return errUsage
+	}
+}
+
+func genEmbed(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("gen embed", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	pkg := fs.String("pkg", "", messages.Sprintf(msgFlagPkg))
+	varName := fs.String("var", "Migrations", messages.Sprintf(msgFlagVar))
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageGenEmbed))
+		return errUsage
+	}
+
+	dir := fs.Arg(0)
+	if *pkg == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		*pkg = filepath.Base(abs)
+	}
+
+	src, err := golumn.GenEmbed(ctx, os.DirFS(dir), *pkg, *varName)
+	if err != nil {
+		return inDir(dir, err)
+	}
+	_, err = stdout.Write(src)
+	return err
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errUsage, exitUsage},
+		{errors.New("parse error"), exitFailure},
+		{&golumn.MultiError{Steps: []*golumn.StepError{{Version: 1, Phase: golumn.PhaseApply, Err: errors.New("boom")}}}, exitFailure},
+		{fmt.Errorf("failed to get version store lock: %w", golumn.ErrLocked), exitLocked},
+		{errors.Join(errors.New("boom"), golumn.ErrDirty), exitDirty},
+		{fmt.Errorf("run: %w", golumn.ErrDrift), exitDrift},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
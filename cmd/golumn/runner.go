@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+// dbEnv names the environment variable giving the default database DSN.
+const dbEnv = "GOLUMN_DB"
+
+// envEnv names the environment variable giving the default run environment.
+const envEnv = "GOLUMN_ENV"
+
+// identityEnv names the environment variable giving the default expected
+// database identity.
+const identityEnv = "GOLUMN_IDENTITY"
+
+// Ping backoff bounds for -wait-for-db.
+var (
+	pingBackoffMin = 100 * time.Millisecond
+	pingBackoffMax = 5 * time.Second
+)
+
+// runMigrations implements "golumn run", a one-shot runner suited to init
+// containers: it optionally waits for the database, migrates, and exits.
+func runMigrations(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	dir := fs.String("dir", defaultDir(), messages.Sprintf(msgFlagDir))
+	dsn := fs.String("db", os.Getenv(dbEnv), messages.Sprintf(msgFlagDB))
+	wait := fs.Bool("wait-for-db", false, messages.Sprintf(msgFlagWaitForDB))
+	timeout := fs.Duration("timeout", 0, messages.Sprintf(msgFlagTimeout))
+	statePath := fs.String("state", "", messages.Sprintf(msgFlagState))
+	dryRun := fs.Bool("dry-run", false, messages.Sprintf(msgFlagDryRun))
+	explain := fs.Bool("explain", false, messages.Sprintf(msgFlagExplain))
+	pin := fs.String("pin", golumn.DefaultPinFile, messages.Sprintf(msgFlagPin))
+	env := fs.String("env", os.Getenv(envEnv), messages.Sprintf(msgFlagEnv))
+	identity := fs.String("identity", os.Getenv(identityEnv), messages.Sprintf(msgFlagIdentity))
+	luaProfile := fs.String("lua-profile", "", messages.Sprintf(msgFlagLuaProfile))
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 2 || *dsn == "" {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageRun))
+		return errUsage
+	}
+	dirn := fs.Arg(0)
+	if dirn != "up" && dirn != "down" {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageRun))
+		return errUsage
+	}
+	to, err := parseTarget(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageRun))
+		return errUsage
+	}
+	if *luaProfile != "" && isDeterministic(ctx) {
+		fmt.Fprintln(stderr, messages.Sprintf(msgUsageRun))
+		return errUsage
+	}
+
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	log := golumn.NewLogger(stderr, golumn.LogInfo)
+
+	migrations, err := readDir(ctx, *dir)
+	if err != nil {
+		return err
+	}
+	sources := make([]*golumn.Migration, len(migrations))
+	for i, m := range migrations {
+		sources[i] = m.Migration
+	}
+
+	var opts []golumn.RunOption
+	if *env != "" {
+		opts = append(opts, golumn.WithEnv(*env))
+	}
+	if *dryRun {
+		opts = append(opts, golumn.WithDryRun())
+	}
+	if *explain {
+		opts = append(opts, golumn.WithExplain())
+	}
+	if *luaProfile != "" {
+		f, err := os.Create(*luaProfile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		opts = append(opts, golumn.WithLuaProfile(f))
+	}
+
+	if *statePath != "" && !*dryRun {
+		stateTo := to
+		if to == golumn.Pinned {
+			if stateTo, err = golumn.ReadPinFile(*pin); err != nil {
+				return err
+			}
+		}
+		want, err := wantState(migrations, stateTo)
+		if err != nil {
+			return err
+		}
+		current, err := readState(*statePath)
+		if err != nil {
+			return err
+		}
+		if want.equal(current) {
+			log.Infof("state %s is up to date", *statePath)
+			return nil
+		}
+	}
+
+	db, err := sql.Open("sqlite3", *dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *wait {
+		if err := waitForDB(ctx, db, log); err != nil {
+			return err
+		}
+	}
+
+	store := sqlite3store.New(db)
+	store.Log = log
+	m := &golumn.Migrator{Store: store, Sources: sources, Log: log, PinFile: *pin, ExpectedIdentity: *identity, Deterministic: isDeterministic(ctx)}
+
+	res, err := m.RunWithSignals(ctx, func(ctx context.Context) (*golumn.Result, error) {
+		if dirn == "up" {
+			return m.Up(ctx, to, opts...)
+		}
+		return m.Down(ctx, to, opts...)
+	})
+	if err == nil && *dryRun {
+		fmt.Fprintln(stdout, res.Plan)
+		return nil
+	}
+	if res != nil && annotating(ctx) {
+		writeAnnotations(stdout, []annotation{{Level: "notice", Message: res.String()}})
+	}
+	if err != nil || *statePath == "" {
+		return inDir(*dir, err)
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	st, err := newState(migrations, status.Applied)
+	if err != nil {
+		return err
+	}
+	return writeState(*statePath, st)
+}
+
+// parseTarget parses a run target: a version, "latest", "pinned" or
+// "initial".
+func parseTarget(s string) (int64, error) {
+	switch s {
+	case "latest":
+		return golumn.Latest, nil
+	case "pinned":
+		return golumn.Pinned, nil
+	case "initial":
+		return golumn.Initial, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// waitForDB pings db with exponential backoff until it responds or ctx is
+// done.
+func waitForDB(ctx context.Context, db *sql.DB, log *golumn.Logger) error {
+	backoff := pingBackoffMin
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		log.Infof("database unavailable, retrying in %s: %v", backoff, err)
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("database unavailable: %w", err)
+		case <-t.C:
+		}
+		backoff = min(2*backoff, pingBackoffMax)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestRunMigrations(t *testing.T) {
+	dir := writeMigrations(t)
+	dsn := filepath.Join(t.TempDir(), "db.sqlite")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"run", "-dir", dir, "-db", dsn, "--wait-for-db", "--timeout", "1m", "up", "latest"}
+	if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("applied %d versions, want 3", n)
+	}
+
+	args = []string{"run", "-dir", dir, "-db", dsn, "down", "initial"}
+	if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d versions remain, want 0", n)
+	}
+}
+
+func TestRunMigrations_LuaProfile(t *testing.T) {
+	dir := writeMigrations(t)
+	dsn := filepath.Join(t.TempDir(), "db.sqlite")
+	profile := filepath.Join(t.TempDir(), "profile.folded")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"run", "-dir", dir, "-db", dsn, "-lua-profile", profile, "up", "latest"}
+	if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+	b, err := os.ReadFile(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "0003_seed.lua ") || !strings.Contains(string(b), "\n0003_seed.lua;Up ") {
+		t.Errorf("unexpected profile %q", b)
+	}
+}
+
+func TestRunMigrations_Deterministic(t *testing.T) {
+	dir := writeMigrations(t)
+
+	var outputs []string
+	for range 2 {
+		dsn := filepath.Join(t.TempDir(), "db.sqlite")
+		var stdout, stderr bytes.Buffer
+		args := []string{"-annotate", "-deterministic", "run", "-dir", dir, "-db", dsn, "up", "latest"}
+		if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+		}
+		outputs = append(outputs, stdout.String()+stderr.String())
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("expected identical output, got\n%s\nand\n%s", outputs[0], outputs[1])
+	}
+	if !strings.Contains(outputs[0], "3 applied, 0 skipped in 0s") {
+		t.Errorf("expected no duration in the summary, got\n%s", outputs[0])
+	}
+}
+
+func TestRunMigrations_Identity(t *testing.T) {
+	dir := writeMigrations(t)
+	dsn := filepath.Join(t.TempDir(), "db.sqlite")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"run", "-dir", dir, "-db", dsn, "-identity", golumn.NewIdentity(), "up", "latest"}
+	if err := run(context.Background(), args, &stdout, &stderr); !errors.Is(err, golumn.ErrIdentityMismatch) {
+		t.Fatalf("expected ErrIdentityMismatch, got %v\n%s", err, stderr.String())
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var id string
+	if err := db.QueryRow("SELECT identity FROM schema_identity").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	args = []string{"run", "-dir", dir, "-db", dsn, "-identity", id, "up", "latest"}
+	if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+}
+
+func TestRunMigrations_Pinned(t *testing.T) {
+	dir := writeMigrations(t)
+	dsn := filepath.Join(t.TempDir(), "db.sqlite")
+	pin := filepath.Join(t.TempDir(), golumn.DefaultPinFile)
+	if err := os.WriteFile(pin, []byte("2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"run", "-dir", dir, "-db", dsn, "-pin", pin, "up", "latest"}
+	if err := run(context.Background(), args, &stdout, &stderr); !errors.Is(err, golumn.ErrBeyondPin) {
+		t.Fatalf("expected ErrBeyondPin, got %v", err)
+	}
+
+	state := filepath.Join(t.TempDir(), "state.json")
+	args = []string{"run", "-dir", dir, "-db", dsn, "-pin", pin, "-state", state, "up", "pinned"}
+	if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+	st, err := readState(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Version != 2 {
+		t.Errorf("state version %d, want 2", st.Version)
+	}
+}
+
+func TestRunMigrations_Usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"missing_db", []string{"run", "up", "latest"}},
+		{"missing_target", []string{"run", "-db", "x", "up"}},
+		{"bad_direction", []string{"run", "-db", "x", "sideways", "latest"}},
+		{"bad_target", []string{"run", "-db", "x", "up", "newest"}},
+		{"deterministic_profile", []string{"-deterministic", "run", "-db", "x", "-lua-profile", "p", "up", "latest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(dbEnv, "")
+			var stdout, stderr bytes.Buffer
+			if err := run(context.Background(), tt.args, &stdout, &stderr); !errors.Is(err, errUsage) {
+				t.Errorf("got %v, want usage error", err)
+			}
+		})
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"latest", golumn.Latest},
+		{"initial", golumn.Initial},
+		{"42", 42},
+	}
+	for _, tt := range tests {
+		got, err := parseTarget(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseTarget(%q) = %d, %v, want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestWaitForDB_Timeout(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "missing", "db.sqlite")+"?mode=ro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := waitForDB(ctx, db, nil); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestRunMigrations_DryRunExplain(t *testing.T) {
+	dir := writeMigrations(t)
+	dsn := filepath.Join(t.TempDir(), "db.sqlite")
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"run", "-dir", dir, "-db", dsn, "up", "1"}, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+
+	stdout.Reset()
+	args := []string{"-annotate=false", "run", "-dir", dir, "-db", dsn, "-dry-run", "-explain", "up", "latest"}
+	if err := run(context.Background(), args, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"up from 1 to 3: 2 steps", "statement 1: SELECT 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&n); err != nil || n != 1 {
+		t.Errorf("dry run applied migrations: %d, %v", n, err)
+	}
+}
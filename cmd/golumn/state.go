@@ -0,0 +1,97 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// state is the machine-readable record written by "golumn run -state". Its
+// fingerprint hashes the name and source of each applied migration, so it
+// changes whenever the applied set or an applied file does.
+type state struct {
+	Version     int64   `json:"version"`
+	Applied     []int64 `json:"applied"`
+	Fingerprint string  `json:"fingerprint"`
+}
+
+// newState returns the state with the given versions of migrations applied.
+func newState(migrations []dirMigration, applied []int64) (*state, error) {
+	st := &state{Version: golumn.Initial, Applied: []int64{}}
+	h := sha256.New()
+	for _, v := range applied {
+		i := slices.IndexFunc(migrations, func(m dirMigration) bool { return m.Version == v })
+		if i < 0 {
+			return nil, fmt.Errorf("missing migration for version: %d", v)
+		}
+		m := migrations[i]
+		fmt.Fprintf(h, "%d %s %d\n", m.Version, m.Name, len(m.Source))
+		h.Write(m.Source)
+		st.Applied = append(st.Applied, v)
+		st.Version = max(st.Version, v)
+	}
+	st.Fingerprint = "sha256:" + hex.EncodeToString(h.Sum(nil))
+	return st, nil
+}
+
+// wantState returns the state a run to target to would leave: every
+// migration at or below it applied.
+func wantState(migrations []dirMigration, to int64) (*state, error) {
+	var applied []int64
+	for _, m := range migrations {
+		if m.Version <= to {
+			applied = append(applied, m.Version)
+		}
+	}
+	return newState(migrations, applied)
+}
+
+func (st *state) equal(other *state) bool {
+	return other != nil && st.Version == other.Version && st.Fingerprint == other.Fingerprint && slices.Equal(st.Applied, other.Applied)
+}
+
+// readState reads the state file at p, returning nil if it does not exist.
+func readState(p string) (*state, error) {
+	b, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st state
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", p, err)
+	}
+	return &st, nil
+}
+
+// writeState replaces the state file at p, so readers never see a partial
+// file.
+func writeState(p string, st *state) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
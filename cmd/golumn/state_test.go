@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunMigrations_State(t *testing.T) {
+	dir := writeMigrations(t)
+	tmp := t.TempDir()
+	dsn := filepath.Join(tmp, "db.sqlite")
+	statePath := filepath.Join(tmp, "state.json")
+
+	var stdout, stderr bytes.Buffer
+	if err := run(context.Background(), []string{"run", "-dir", dir, "-db", dsn, "-state", statePath, "up", "2"}, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+	st, err := readState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st == nil || st.Version != 2 || len(st.Applied) != 2 || st.Fingerprint == "" {
+		t.Fatalf("unexpected state: %+v", st)
+	}
+
+	// A matching state skips the run, so an unusable database is never
+	// opened.
+	unusable := "file:" + filepath.Join(tmp, "missing", "db.sqlite") + "?mode=ro"
+	if err := run(context.Background(), []string{"run", "-dir", dir, "-db", unusable, "-state", statePath, "up", "2"}, &stdout, &stderr); err != nil {
+		t.Fatalf("expected no-op, got %v", err)
+	}
+
+	// Changing an applied source changes the fingerprint.
+	if err := os.WriteFile(filepath.Join(dir, "0001_init.sql"), []byte("-- +golumn Up\nCREATE TABLE b (id INTEGER);\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(context.Background(), []string{"run", "-dir", dir, "-db", unusable, "-state", statePath, "up", "2"}, &stdout, &stderr); err == nil {
+		t.Fatal("expected run against unusable database")
+	}
+
+	if err := run(context.Background(), []string{"run", "-dir", dir, "-db", dsn, "-state", statePath, "up", "latest"}, &stdout, &stderr); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, stderr.String())
+	}
+	next, err := readState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Version != 3 || next.Fingerprint == st.Fingerprint {
+		t.Errorf("state not updated: %+v", next)
+	}
+}
+
+func TestWantState(t *testing.T) {
+	dir := writeMigrations(t)
+	migrations, err := readDir(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	none, err := wantState(migrations, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if none.Version != -1 || len(none.Applied) != 0 {
+		t.Errorf("unexpected initial state: %+v", none)
+	}
+
+	a, err := wantState(migrations, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := newState(migrations, []int64{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.equal(b) {
+		t.Errorf("%+v != %+v", a, b)
+	}
+	if _, err := newState(migrations, []int64{4}); err == nil {
+		t.Error("expected error for unknown version")
+	}
+}
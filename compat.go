@@ -0,0 +1,109 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrIncompatible is returned by runs that would move the store outside the
+// schema versions a registered application is compatible with.
+var ErrIncompatible = errors.New("run is incompatible with a registered application")
+
+// Compat records the range of schema versions a running application works
+// with. Registered is set by the store.
+type Compat struct {
+	// App identifies the application, e.g. "api@1.4.2". Instances sharing a
+	// name share one registration.
+	App string `json:"app"`
+	// MinVersion is the lowest version whose schema the application needs:
+	// it and every version below it must stay applied.
+	MinVersion int64 `json:"min_version"`
+	// MaxVersion is the highest version the application tolerates, or
+	// Latest if it tolerates any later schema.
+	MaxVersion int64     `json:"max_version"`
+	Registered time.Time `json:"registered,omitzero"`
+}
+
+// RegisterCompat records that app, typically the calling binary at startup,
+// works with schema versions from minVersion to maxVersion. While
+// registered, Down and DownOnly refuse to revert minVersion or anything below
+// it, and Up and UpOnly refuse to apply versions above maxVersion, failing
+// with ErrIncompatible. Pass Latest as maxVersion to allow any later schema. Registering app again replaces
+// its range. The store must implement CompatRegistry.
+func (m *Migrator) RegisterCompat(ctx context.Context, app string, minVersion, maxVersion int64) error {
+	if app == "" {
+		return errors.New("empty application name")
+	}
+	if minVersion < Initial || maxVersion < minVersion {
+		return fmt.Errorf("invalid compatible range %d to %d", minVersion, maxVersion)
+	}
+	registry, err := m.compatRegistry(ctx)
+	if err != nil {
+		return err
+	}
+	if err := registry.RegisterCompat(ctx, Compat{App: app, MinVersion: minVersion, MaxVersion: maxVersion}); err != nil {
+		return fmt.Errorf("failed to register %s: %w", app, err)
+	}
+	m.logger().Infof("registered %s as compatible with versions %d to %d", app, minVersion, maxVersion)
+	return nil
+}
+
+// UnregisterCompat removes the registration of app, e.g. at shutdown.
+func (m *Migrator) UnregisterCompat(ctx context.Context, app string) error {
+	registry, err := m.compatRegistry(ctx)
+	if err != nil {
+		return err
+	}
+	if err := registry.UnregisterCompat(ctx, app); err != nil {
+		return fmt.Errorf("failed to unregister %s: %w", app, err)
+	}
+	m.logger().Infof("unregistered %s", app)
+	return nil
+}
+
+func (m *Migrator) compatRegistry(ctx context.Context) (CompatRegistry, error) {
+	store := m.store()
+	registry, ok := store.(CompatRegistry)
+	if !ok {
+		return nil, fmt.Errorf("version store is not a CompatRegistry: %w", errors.ErrUnsupported)
+	}
+	if err := store.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to init version store: %w", err)
+	}
+	return registry, nil
+}
+
+// checkCompat fails with ErrIncompatible if running p would break a
+// registered application. Stores that do not implement CompatRegistry have
+// no registrations.
+func (m *Migrator) checkCompat(ctx context.Context, p *Plan) error {
+	if len(p.Steps) == 0 || m.config(ctx).ignoreCompat {
+		return nil
+	}
+	registry, ok := m.store().(CompatRegistry)
+	if !ok {
+		return nil
+	}
+	compats, err := registry.Compats(ctx)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list registered applications: %w", err)
+	}
+	lo, hi := p.Steps[0].Version, p.Steps[0].Version
+	for _, step := range p.Steps[1:] {
+		lo, hi = min(lo, step.Version), max(hi, step.Version)
+	}
+	for _, c := range compats {
+		switch {
+		case p.Direction == DirectionDown && lo <= c.MinVersion:
+			return fmt.Errorf("%w: reverting %d breaks %s, which needs version %d", ErrIncompatible, lo, c.App, c.MinVersion)
+		case p.Direction == DirectionUp && hi > c.MaxVersion:
+			return fmt.Errorf("%w: applying %d breaks %s, which supports up to version %d", ErrIncompatible, hi, c.App, c.MaxVersion)
+		}
+	}
+	return nil
+}
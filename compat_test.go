@@ -0,0 +1,72 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestMigrator_Compat(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: createMigrations(1, 2, 3, 4)}
+	if _, err := migrator.Up(ctx, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := migrator.RegisterCompat(ctx, "api", 2, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := migrator.Up(ctx, golumn.Latest); !errors.Is(err, golumn.ErrIncompatible) {
+		t.Errorf("expected ErrIncompatible applying above max, got %v", err)
+	}
+	if _, err := migrator.Down(ctx, 1); !errors.Is(err, golumn.ErrIncompatible) {
+		t.Errorf("expected ErrIncompatible reverting min, got %v", err)
+	}
+	if _, err := migrator.DownOnly(ctx, 2); !errors.Is(err, golumn.ErrIncompatible) {
+		t.Errorf("expected ErrIncompatible reverting min alone, got %v", err)
+	}
+	if _, err := migrator.Plan(ctx, golumn.DirectionDown, 1); !errors.Is(err, golumn.ErrIncompatible) {
+		t.Errorf("expected ErrIncompatible from a dry run, got %v", err)
+	}
+	if v, err := migrator.CachedVersion(ctx, 0); err != nil || v != 3 {
+		t.Errorf("expected refused runs to leave version 3, got %d (err %v)", v, err)
+	}
+
+	if res, err := migrator.Down(ctx, 2); err != nil || res.Version != 2 {
+		t.Errorf("expected revert within range to succeed, got %+v (err %v)", res, err)
+	}
+	if res, err := migrator.Down(ctx, 1, golumn.WithIgnoreCompat()); err != nil || res.Version != 1 {
+		t.Errorf("expected WithIgnoreCompat to allow revert, got %+v (err %v)", res, err)
+	}
+
+	if err := migrator.UnregisterCompat(ctx, "api"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res, err := migrator.Up(ctx, golumn.Latest); err != nil || res.Version != 4 {
+		t.Errorf("expected Up after unregistering to succeed, got %+v (err %v)", res, err)
+	}
+}
+
+func TestMigrator_RegisterCompatErrors(t *testing.T) {
+	migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1)}
+	if err := migrator.RegisterCompat(context.Background(), "api", 1, golumn.Latest); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if err := migrator.RegisterCompat(context.Background(), "api", 2, 1); err == nil {
+		t.Error("expected error for an inverted range")
+	}
+	if err := migrator.RegisterCompat(context.Background(), "", 1, 2); err == nil {
+		t.Error("expected error for an empty name")
+	}
+}
@@ -0,0 +1,117 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConnConfig tunes the store's database for the duration of a run, so that
+// migration traffic is identifiable and runs on a single session. The
+// previous pool limit is restored when the run ends.
+type ConnConfig struct {
+	// MaxOpenConns, if positive, limits the pool during the run. Setup
+	// requires 1, which pins every statement of the run, including those of
+	// migrations, to one connection; a migration must then not use the db
+	// while it holds a transaction or an open cursor. A store that is a
+	// ConnReserver gets one connection more, for the session holding its
+	// lock.
+	MaxOpenConns int
+	// Setup statements run at the start of the run, e.g. SET commands.
+	Setup []string
+	// Reset statements run at the end of the run to undo Setup before the
+	// connection returns to general use.
+	Reset []string
+}
+
+// PostgresConnConfig returns a ConnConfig pinning the run to one connection
+// with the given application_name and, if not empty, search_path, reset
+// afterward.
+func PostgresConnConfig(applicationName, searchPath string) *ConnConfig {
+	c := &ConnConfig{
+		MaxOpenConns: 1,
+		Setup:        []string{"SET application_name = " + quoteLiteral(applicationName)},
+		Reset:        []string{"RESET application_name"},
+	}
+	if searchPath != "" {
+		c.Setup = append(c.Setup, "SET search_path = "+searchPath)
+		c.Reset = append(c.Reset, "RESET search_path")
+	}
+	return c
+}
+
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (c *ConnConfig) validate() error {
+	if c.MaxOpenConns < 0 {
+		return fmt.Errorf("negative connection limit: %d", c.MaxOpenConns)
+	}
+	if (len(c.Setup) > 0 || len(c.Reset) > 0) && c.MaxOpenConns != 1 {
+		return errors.New("connection setup requires MaxOpenConns of 1")
+	}
+	return nil
+}
+
+// configureConn applies Migrator.Conn to the store's database, returning a
+// func that restores it. Restoring ignores cancellation of ctx, like lock
+// release, so that a canceled run does not leave the pool reconfigured.
+func (m *Migrator) configureConn(ctx context.Context) (restore func() error, err error) {
+	c := m.Conn
+	if c == nil {
+		return func() error { return nil }, nil
+	}
+	db := m.store().DB()
+	if db == nil {
+		return func() error { return nil }, nil
+	}
+
+	prevMax := db.Stats().MaxOpenConnections
+	unreserve := func() error { return nil }
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+		if reserver, ok := m.store().(ConnReserver); ok {
+			// The lock's session needs a connection of its own for the
+			// whole run, which must neither count against the limit nor
+			// be the one Setup configures.
+			db.SetMaxOpenConns(c.MaxOpenConns + 1)
+			release, err := reserver.ReserveConn(ctx)
+			switch {
+			case err == nil:
+				unreserve = release
+			case !errors.Is(err, errors.ErrUnsupported):
+				db.SetMaxOpenConns(prevMax)
+				return nil, fmt.Errorf("failed to reserve lock connection: %w", err)
+			default:
+				db.SetMaxOpenConns(c.MaxOpenConns)
+			}
+		}
+	}
+	restore = func() error {
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockReleaseTimeout)
+		defer cancel()
+		var errs []error
+		for _, stmt := range c.Reset {
+			if _, err := db.ExecContext(rctx, stmt); err != nil {
+				errs = append(errs, fmt.Errorf("failed to reset connection: %w", err))
+			}
+		}
+		if c.MaxOpenConns > 0 {
+			db.SetMaxOpenConns(prevMax)
+		}
+		if err := unreserve(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to return lock connection: %w", err))
+		}
+		return errors.Join(errs...)
+	}
+
+	for _, stmt := range c.Setup {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to configure connection: %w", err), restore())
+		}
+	}
+	m.logger().Debugf("configured connection: max open %d, %d setup statements", c.MaxOpenConns, len(c.Setup))
+	return restore, nil
+}
@@ -0,0 +1,82 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func cacheSize(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	err := db.QueryRowContext(ctx, "PRAGMA cache_size").Scan(&n)
+	return n, err
+}
+
+func TestMigrator_Conn(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(5)
+
+	migration := &golumn.Migration{
+		Version: 1,
+		UpFunc: func(ctx context.Context, db *sql.DB) error {
+			if n := db.Stats().MaxOpenConnections; n != 1 {
+				return fmt.Errorf("max open connections %d, want 1", n)
+			}
+			if n, err := cacheSize(ctx, db); err != nil || n != -1234 {
+				return fmt.Errorf("cache size %d, %v, want -1234", n, err)
+			}
+			return nil
+		},
+	}
+	migrator := &golumn.Migrator{
+		Store:   sqlite3store.New(db),
+		Sources: []*golumn.Migration{migration},
+		Conn: &golumn.ConnConfig{
+			MaxOpenConns: 1,
+			Setup:        []string{"PRAGMA cache_size = -1234"},
+			Reset:        []string{"PRAGMA cache_size = -2000"},
+		},
+	}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := db.Stats().MaxOpenConnections; n != 5 {
+		t.Errorf("max open connections %d after run, want 5", n)
+	}
+	if n, err := cacheSize(context.Background(), db); err != nil || n != -2000 {
+		t.Errorf("cache size %d, %v after run, want -2000", n, err)
+	}
+
+	migrator.Conn.Setup = []string{"NOT SQL"}
+	if _, err := migrator.Down(context.Background(), golumn.Initial); err == nil || !strings.Contains(err.Error(), "failed to configure connection") {
+		t.Errorf("expected setup error, got %v", err)
+	}
+	if n := db.Stats().MaxOpenConnections; n != 5 {
+		t.Errorf("max open connections %d after failed setup, want 5", n)
+	}
+}
+
+func TestPostgresConnConfig(t *testing.T) {
+	c := golumn.PostgresConnConfig("golumn's", "app, public")
+	if c.MaxOpenConns != 1 {
+		t.Errorf("MaxOpenConns = %d, want 1", c.MaxOpenConns)
+	}
+	wantSetup := []string{"SET application_name = 'golumn''s'", "SET search_path = app, public"}
+	if strings.Join(c.Setup, ";") != strings.Join(wantSetup, ";") {
+		t.Errorf("Setup = %q, want %q", c.Setup, wantSetup)
+	}
+	wantReset := []string{"RESET application_name", "RESET search_path"}
+	if strings.Join(c.Reset, ";") != strings.Join(wantReset, ";") {
+		t.Errorf("Reset = %q, want %q", c.Reset, wantReset)
+	}
+}
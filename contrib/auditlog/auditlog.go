@@ -0,0 +1,72 @@
+// Package auditlog provides a golumn.AuditSink that appends events as JSON
+// lines to an io.Writer, such as an append-only file or a log shipper's
+// input.
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type entry struct {
+	Direction golumn.Direction `json:"direction"`
+	Version   int64            `json:"version"`
+	Name      string           `json:"name,omitempty"`
+	Owner     string           `json:"owner,omitempty"`
+	// Time is omitted when zero, as for a Deterministic migrator.
+	Time string `json:"time,omitempty"`
+	// Statements and RowsAffected are omitted when no statements were
+	// counted, e.g. for Go migrations.
+	Statements   int64 `json:"statements,omitempty"`
+	RowsAffected int64 `json:"rows_affected,omitempty"`
+}
+
+// Writer is an AuditSink writing one JSON object per event to W. If W
+// implements Sync, as *os.File does, it is called after each event so that a
+// recorded event survives a crash.
+type Writer struct {
+	W io.Writer
+
+	mu sync.Mutex
+}
+
+var _ golumn.AuditSink = (*Writer)(nil)
+
+func New(w io.Writer) *Writer {
+	return &Writer{W: w}
+}
+
+func (w *Writer) Record(_ context.Context, ev golumn.AuditEvent) error {
+	e := entry{
+		Direction: ev.Direction,
+		Version:   ev.Version,
+		Name:      ev.Name,
+		Owner:     ev.Owner,
+
+		Statements:   ev.Stats.Statements,
+		RowsAffected: ev.Stats.RowsAffected,
+	}
+	if !ev.Time.IsZero() {
+		e.Time = ev.Time.UTC().Format(time.RFC3339Nano)
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.W.Write(line); err != nil {
+		return err
+	}
+	if s, ok := w.W.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
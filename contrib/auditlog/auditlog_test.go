@@ -0,0 +1,37 @@
+package auditlog_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/contrib/auditlog"
+)
+
+func TestWriter_Record(t *testing.T) {
+	var buf bytes.Buffer
+	w := auditlog.New(&buf)
+
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	events := []golumn.AuditEvent{
+		{Direction: golumn.DirectionUp, Version: 1, Name: "1_init.lua", Time: at, Stats: golumn.StatementStats{Statements: 2, RowsAffected: 10}},
+		{Direction: golumn.DirectionDown, Version: 1, Name: "1_init.lua", Time: at},
+		{Direction: golumn.DirectionUp, Version: 1, Name: "1_init.lua"},
+	}
+	for _, ev := range events {
+		if err := w.Record(context.Background(), ev); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+	}
+
+	want := `{"direction":"up","version":1,"name":"1_init.lua","time":"2024-06-01T12:00:00Z","statements":2,"rows_affected":10}
+{"direction":"down","version":1,"name":"1_init.lua","time":"2024-06-01T12:00:00Z"}
+{"direction":"up","version":1,"name":"1_init.lua"}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("unexpected output\nwant: %s\ngot:  %s", strings.TrimSpace(want), strings.TrimSpace(got))
+	}
+}
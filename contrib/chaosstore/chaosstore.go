@@ -0,0 +1,236 @@
+// Package chaosstore provides a golumn.Store decorator that injects
+// failures, for testing that deploy tooling and the migrator's LockWait,
+// HoldLockOnFailure and AutoRevertOnFailure policies behave as intended
+// when the version store misbehaves:
+//
+//	m.Store = chaosstore.New(store, chaosstore.Config{ErrorRate: 0.1, LockFlapRate: 0.5, Seed: 1})
+//
+// It is meant for test environments only.
+package chaosstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// ErrInjected is the error of calls failed by ErrorRate.
+var ErrInjected = errors.New("chaosstore: injected failure")
+
+// Op names a store operation, for Config.Ops.
+type Op string
+
+const (
+	OpInit     Op = "init"
+	OpLock     Op = "lock"
+	OpRelease  Op = "release"
+	OpVersion  Op = "version"
+	OpInsert   Op = "insert"
+	OpRemove   Op = "remove"
+	OpVersions Op = "versions"
+)
+
+// Config controls which failures Store injects. The zero Config injects
+// none.
+type Config struct {
+	// ErrorRate is the probability, from 0 to 1, that a call fails with
+	// ErrInjected instead of reaching the inner store.
+	ErrorRate float64
+	// LockFlapRate is the probability that Lock fails with golumn.ErrLocked,
+	// as if another run held the lock for a moment.
+	LockFlapRate float64
+	// MinLatency and MaxLatency bound a random delay added before each
+	// call. The delay is cut short by the cancellation of the call's
+	// context.
+	MinLatency, MaxLatency time.Duration
+	// Ops, if set, restricts the failures and latency to these operations.
+	Ops []Op
+	// Seed seeds the random source, so that a failing sequence can be
+	// replayed. Zero picks a random seed.
+	Seed uint64
+	// Log, if set, logs each injected failure at LogVerbose.
+	Log *golumn.Logger
+}
+
+// Store wraps another golumn.Store, injecting the failures of Config.
+type Store struct {
+	Inner  golumn.Store
+	Config Config
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	injected int
+}
+
+var (
+	_ golumn.Store         = (*Store)(nil)
+	_ golumn.ForceUnlocker = (*Store)(nil)
+	_ golumn.VersionLister = (*listingStore)(nil)
+)
+
+// New wraps inner. Like logstore.New, the result implements
+// golumn.VersionLister only if inner does.
+func New(inner golumn.Store, cfg Config) golumn.Store {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	s := &Store{Inner: inner, Config: cfg, rand: rand.New(rand.NewPCG(seed, seed))}
+	if _, ok := inner.(golumn.VersionLister); ok {
+		return &listingStore{s}
+	}
+	return s
+}
+
+// Middleware returns a golumn.StoreMiddleware that wraps stores with New.
+func Middleware(cfg Config) golumn.StoreMiddleware {
+	return func(inner golumn.Store) golumn.Store {
+		return New(inner, cfg)
+	}
+}
+
+// Injected returns the number of failures injected so far, not counting
+// latency. Stores returned by New provide it through an
+// interface{ Injected() int } assertion.
+func (s *Store) Injected() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.injected
+}
+
+// chance reports whether an event of probability p happens.
+func (s *Store) chance(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.source().Float64() < p
+}
+
+func (s *Store) latency() time.Duration {
+	lo, hi := s.Config.MinLatency, s.Config.MaxLatency
+	if hi <= lo {
+		return lo
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lo + time.Duration(s.source().Int64N(int64(hi-lo)))
+}
+
+// source returns the random source, seeding it from Config.Seed for a Store
+// not created by New. It is called with mu held.
+func (s *Store) source() *rand.Rand {
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewPCG(s.Config.Seed, s.Config.Seed))
+	}
+	return s.rand
+}
+
+// inject delays the call op and decides whether it fails, returning the
+// error to fail it with or nil to let it through.
+func (s *Store) inject(ctx context.Context, op Op) error {
+	if len(s.Config.Ops) > 0 && !slices.Contains(s.Config.Ops, op) {
+		return nil
+	}
+
+	if d := s.latency(); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	var err error
+	switch {
+	case op == OpLock && s.chance(s.Config.LockFlapRate):
+		err = golumn.ErrLocked
+	case s.chance(s.Config.ErrorRate):
+		err = fmt.Errorf("%s: %w", op, ErrInjected)
+	default:
+		return nil
+	}
+	s.mu.Lock()
+	s.injected++
+	s.mu.Unlock()
+	s.Config.Log.Verbosef("chaosstore: %s: injected %v", op, err)
+	return err
+}
+
+func (s *Store) DB() *sql.DB {
+	return s.Inner.DB()
+}
+
+func (s *Store) Init(ctx context.Context) error {
+	if err := s.inject(ctx, OpInit); err != nil {
+		return err
+	}
+	return s.Inner.Init(ctx)
+}
+
+func (s *Store) Lock(ctx context.Context) error {
+	if err := s.inject(ctx, OpLock); err != nil {
+		return err
+	}
+	return s.Inner.Lock(ctx)
+}
+
+func (s *Store) Release(ctx context.Context) error {
+	if err := s.inject(ctx, OpRelease); err != nil {
+		return err
+	}
+	return s.Inner.Release(ctx)
+}
+
+func (s *Store) Version(ctx context.Context) (int64, error) {
+	if err := s.inject(ctx, OpVersion); err != nil {
+		return 0, err
+	}
+	return s.Inner.Version(ctx)
+}
+
+func (s *Store) Insert(ctx context.Context, v int64) error {
+	if err := s.inject(ctx, OpInsert); err != nil {
+		return err
+	}
+	return s.Inner.Insert(ctx, v)
+}
+
+func (s *Store) Remove(ctx context.Context, v int64) error {
+	if err := s.inject(ctx, OpRemove); err != nil {
+		return err
+	}
+	return s.Inner.Remove(ctx, v)
+}
+
+// ForceUnlock forwards to the inner store without injecting failures, so
+// that tests can always recover a lock held after an injected failure. It
+// fails with errors.ErrUnsupported if the inner store is not a
+// golumn.ForceUnlocker.
+func (s *Store) ForceUnlock(ctx context.Context) error {
+	if u, ok := s.Inner.(golumn.ForceUnlocker); ok {
+		return u.ForceUnlock(ctx)
+	}
+	return errors.ErrUnsupported
+}
+
+type listingStore struct {
+	*Store
+}
+
+func (s *listingStore) Versions(ctx context.Context) ([]int64, error) {
+	if err := s.inject(ctx, OpVersions); err != nil {
+		return nil, err
+	}
+	return s.Inner.(golumn.VersionLister).Versions(ctx)
+}
@@ -0,0 +1,97 @@
+package chaosstore_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/contrib/chaosstore"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+	"github.com/jonathonwebb/golumn/storetest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func migrations(versions ...int64) []*golumn.Migration {
+	noop := func(context.Context, *sql.DB) error { return nil }
+	var ms []*golumn.Migration
+	for _, v := range versions {
+		ms = append(ms, &golumn.Migration{Version: v, UpFunc: noop, DownFunc: noop})
+	}
+	return ms
+}
+
+func TestChaosstore_Conformance(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		return chaosstore.New(sqlite3store.New(openDB(t)), chaosstore.Config{})
+	})
+}
+
+func TestChaosstore_ErrorRate(t *testing.T) {
+	inner := sqlite3store.New(openDB(t))
+	store := chaosstore.New(inner, chaosstore.Config{ErrorRate: 1, Ops: []chaosstore.Op{chaosstore.OpInsert}})
+	m := &golumn.Migrator{Store: store, Sources: migrations(1, 2), HoldLockOnFailure: true}
+
+	_, err := m.Up(context.Background(), golumn.Latest)
+	if !errors.Is(err, chaosstore.ErrInjected) {
+		t.Fatalf("expected ErrInjected, got %v", err)
+	}
+	if n := store.(interface{ Injected() int }).Injected(); n != 1 {
+		t.Errorf("got %d injected failures, want 1", n)
+	}
+	if err := inner.Lock(context.Background()); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected the failed run to hold the lock, got %v", err)
+	}
+	if err := store.(golumn.ForceUnlocker).ForceUnlock(context.Background()); err != nil {
+		t.Errorf("force unlock failed: %v", err)
+	}
+}
+
+func TestChaosstore_LockFlap(t *testing.T) {
+	store := chaosstore.New(sqlite3store.New(openDB(t)), chaosstore.Config{LockFlapRate: 1})
+	m := &golumn.Migrator{Store: store, Sources: migrations(1)}
+	if _, err := m.Up(context.Background(), golumn.Latest); !errors.Is(err, golumn.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	// With this seed some lock attempts flap, which LockWait rides out.
+	store = chaosstore.New(sqlite3store.New(openDB(t)), chaosstore.Config{LockFlapRate: 0.5, Seed: 3})
+	m = &golumn.Migrator{Store: store, Sources: migrations(1), LockWait: 5 * time.Second}
+	if _, err := m.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := store.(interface{ Injected() int }).Injected(); n == 0 {
+		t.Error("expected a lock flap to be injected")
+	}
+}
+
+func TestChaosstore_Latency(t *testing.T) {
+	store := chaosstore.New(sqlite3store.New(openDB(t)), chaosstore.Config{MinLatency: time.Hour, MaxLatency: 2 * time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := store.Init(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the delay to end with the context, got %v", err)
+	}
+
+	store = chaosstore.New(sqlite3store.New(openDB(t)), chaosstore.Config{MinLatency: 20 * time.Millisecond})
+	start := time.Now()
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("init took %s, want at least 20ms", elapsed)
+	}
+}
@@ -0,0 +1,69 @@
+// Package healthz exposes migration readiness as an HTTP health endpoint,
+// e.g. for load balancer readiness checks:
+//
+//	http.Handle("/healthz/migrations", healthz.Handler(m, healthz.Options{}))
+//
+// The handler reads through golumn.Migrator.Status, so it never takes the
+// store lock and uses Migrator.StatusStore if set.
+package healthz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// Response is the JSON body written by Handler. Dirty is true while the
+// store lock is held, which covers a run in progress as well as one that
+// failed with HoldLockOnFailure; it is always false for stores that do not
+// implement golumn.LockInspector.
+type Response struct {
+	CurrentVersion int64  `json:"current_version"`
+	PendingCount   int    `json:"pending_count"`
+	Dirty          bool   `json:"dirty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// Options controls when Handler reports ready.
+type Options struct {
+	// AllowPending reports ready with pending migrations, e.g. for services
+	// deployed ahead of their migrations.
+	AllowPending bool
+	// Timeout, if positive, limits each status read.
+	Timeout time.Duration
+}
+
+// Handler returns a handler that writes m's Response with status 200 when
+// the store is ready, and 503 when migrations are pending, the store is
+// dirty or its status cannot be read.
+func Handler(m *golumn.Migrator, opts Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if opts.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+
+		code := http.StatusOK
+		var resp Response
+		st, err := m.Status(ctx)
+		if err != nil {
+			code = http.StatusServiceUnavailable
+			resp = Response{CurrentVersion: golumn.Initial, Error: err.Error()}
+		} else {
+			resp = Response{CurrentVersion: st.Version, PendingCount: len(st.Pending), Dirty: st.Locked}
+			if resp.Dirty || resp.PendingCount > 0 && !opts.AllowPending {
+				code = http.StatusServiceUnavailable
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
@@ -0,0 +1,77 @@
+package healthz_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/contrib/healthz"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func noop(context.Context, *sql.DB) error { return nil }
+
+func get(t *testing.T, h http.Handler) (int, healthz.Response) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var resp healthz.Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid body %q: %v", rec.Body.String(), err)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	return rec.Code, resp
+}
+
+func TestHandler(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	store := sqlite3store.New(db)
+	m := &golumn.Migrator{
+		Store: store,
+		Sources: []*golumn.Migration{
+			{Version: 1, UpFunc: noop, DownFunc: noop},
+			{Version: 2, UpFunc: noop, DownFunc: noop},
+		},
+	}
+	h := healthz.Handler(m, healthz.Options{})
+
+	if code, resp := get(t, h); code != http.StatusServiceUnavailable || resp.Error == "" {
+		t.Errorf("uninitialized store: got %d %+v, want 503 with error", code, resp)
+	}
+
+	if _, err := m.Up(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := get(t, h); code != http.StatusServiceUnavailable || resp != (healthz.Response{CurrentVersion: 1, PendingCount: 1}) {
+		t.Errorf("pending: got %d %+v", code, resp)
+	}
+	if code, _ := get(t, healthz.Handler(m, healthz.Options{AllowPending: true})); code != http.StatusOK {
+		t.Errorf("pending with AllowPending: got %d, want 200", code)
+	}
+
+	if _, err := m.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := get(t, h); code != http.StatusOK || resp != (healthz.Response{CurrentVersion: 2}) {
+		t.Errorf("up to date: got %d %+v", code, resp)
+	}
+
+	if err := store.Lock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if code, resp := get(t, h); code != http.StatusServiceUnavailable || !resp.Dirty {
+		t.Errorf("locked: got %d %+v, want 503 and dirty", code, resp)
+	}
+}
@@ -0,0 +1,131 @@
+// Package lagprobe provides golumn.LagProbe implementations for PostgreSQL
+// and MySQL replication. It issues plain SQL through database/sql and does
+// not import a driver.
+package lagprobe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+var (
+	_ golumn.LagProbe = Postgres{}
+	_ golumn.LagProbe = MySQL{}
+)
+
+// PostgresQuery selects the largest replay lag, in seconds, of the standbys
+// streaming from the primary it runs on.
+const PostgresQuery = "SELECT COALESCE(EXTRACT(EPOCH FROM MAX(replay_lag)), 0) FROM pg_stat_replication"
+
+// Postgres probes a PostgreSQL primary via pg_stat_replication. With no
+// connected standbys the lag is zero.
+type Postgres struct {
+	DB *sql.DB
+	// Query overrides PostgresQuery. It must return a single number of
+	// seconds.
+	Query string
+}
+
+func (p Postgres) Lag(ctx context.Context) (time.Duration, error) {
+	q := p.Query
+	if q == "" {
+		q = PostgresQuery
+	}
+
+	var seconds float64
+	if err := p.DB.QueryRowContext(ctx, q).Scan(&seconds); err != nil {
+		return 0, err
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// MySQLQuery is the replica status statement for MySQL 8.0.22 and later.
+// Older servers need "SHOW SLAVE STATUS".
+const MySQLQuery = "SHOW REPLICA STATUS"
+
+var ErrReplicationStopped = errors.New("replication is not running")
+
+// MySQL probes MySQL replicas by reading Seconds_Behind_Source (or
+// Seconds_Behind_Master on older servers) from each of Replicas, reporting
+// the largest value across all replicas and channels.
+type MySQL struct {
+	Replicas []*sql.DB
+	// Query overrides MySQLQuery.
+	Query string
+}
+
+func (p MySQL) Lag(ctx context.Context) (time.Duration, error) {
+	q := p.Query
+	if q == "" {
+		q = MySQLQuery
+	}
+
+	var worst time.Duration
+	for i, db := range p.Replicas {
+		lag, err := mysqlReplicaLag(ctx, db, q)
+		if err != nil {
+			return 0, fmt.Errorf("replica %d: %w", i, err)
+		}
+		if lag > worst {
+			worst = lag
+		}
+	}
+	return worst, nil
+}
+
+func mysqlReplicaLag(ctx context.Context, db *sql.DB, q string) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	col := -1
+	for i, name := range cols {
+		if strings.EqualFold(name, "Seconds_Behind_Source") || strings.EqualFold(name, "Seconds_Behind_Master") {
+			col = i
+			break
+		}
+	}
+	if col == -1 {
+		return 0, errors.New("replica status has no Seconds_Behind_Source column")
+	}
+
+	var worst time.Duration
+	found := false
+	dest := make([]any, len(cols))
+	for i := range dest {
+		dest[i] = new(sql.RawBytes)
+	}
+	seconds := new(sql.NullInt64)
+	dest[col] = seconds
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return 0, err
+		}
+		if !seconds.Valid {
+			return 0, ErrReplicationStopped
+		}
+		found = true
+		if lag := time.Duration(seconds.Int64) * time.Second; lag > worst {
+			worst = lag
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, errors.New("not configured as a replica")
+	}
+	return worst, nil
+}
@@ -0,0 +1,100 @@
+package lagprobe_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn/contrib/lagprobe"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPostgres_Query(t *testing.T) {
+	p := lagprobe.Postgres{DB: openDB(t), Query: "SELECT 1.5"}
+	lag, err := p.Lag(context.Background())
+	if err != nil {
+		t.Fatalf("lag failed: %v", err)
+	}
+	if lag != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s, got %s", lag)
+	}
+}
+
+func replica(t *testing.T, schema string, rows ...string) *sql.DB {
+	t.Helper()
+	db := openDB(t)
+	if _, err := db.Exec("CREATE TABLE replica_status (" + schema + ")"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	for _, row := range rows {
+		if _, err := db.Exec("INSERT INTO replica_status VALUES (" + row + ")"); err != nil {
+			t.Fatalf("failed to insert: %v", err)
+		}
+	}
+	return db
+}
+
+func TestMySQL(t *testing.T) {
+	const q = "SELECT * FROM replica_status"
+
+	tests := []struct {
+		name     string
+		replicas func(t *testing.T) []*sql.DB
+		want     time.Duration
+		wantErr  error
+	}{
+		{
+			name: "max_across_replicas_and_channels",
+			replicas: func(t *testing.T) []*sql.DB {
+				return []*sql.DB{
+					replica(t, "Channel_Name TEXT, Seconds_Behind_Source INTEGER", "'a', 3", "'b', 7"),
+					replica(t, "Seconds_Behind_Master INTEGER", "5"),
+				}
+			},
+			want: 7 * time.Second,
+		},
+		{
+			name: "stopped",
+			replicas: func(t *testing.T) []*sql.DB {
+				return []*sql.DB{replica(t, "Seconds_Behind_Source INTEGER", "NULL")}
+			},
+			wantErr: lagprobe.ErrReplicationStopped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lag, err := lagprobe.MySQL{Replicas: tt.replicas(t), Query: q}.Lag(context.Background())
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lag failed: %v", err)
+			}
+			if lag != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, lag)
+			}
+		})
+	}
+}
+
+func TestMySQL_NotReplica(t *testing.T) {
+	p := lagprobe.MySQL{Replicas: []*sql.DB{replica(t, "Seconds_Behind_Source INTEGER")}, Query: "SELECT * FROM replica_status"}
+	if _, err := p.Lag(context.Background()); err == nil {
+		t.Fatal("expected error for empty replica status")
+	}
+}
@@ -0,0 +1,94 @@
+// Package schemaevent publishes a schema-changed event to a message bus after
+// a successful migration run. Its hooks are meant for
+// golumn.Migrator.AfterSuccess.
+//
+// The package does not depend on any bus client. NATS takes anything with the
+// Publish method of *nats.Conn; other buses, such as Kafka, can be wired up
+// through Publish:
+//
+//	w := &kafka.Writer{Addr: kafka.TCP("localhost:9092"), Topic: "schema"}
+//	m.AfterSuccess = schemaevent.Publish(func(ctx context.Context, data []byte) error {
+//		return w.WriteMessages(ctx, kafka.Message{Value: data})
+//	})
+package schemaevent
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// Event is the JSON payload published for a run. Time is omitted when zero.
+type Event struct {
+	Direction golumn.Direction `json:"direction"`
+	Applied   []int64          `json:"applied,omitempty"`
+	Reverted  []int64          `json:"reverted,omitempty"`
+	Version   int64            `json:"version"`
+	Time      time.Time        `json:"time,omitzero"`
+}
+
+// NewEvent returns the Event for res, stamped with t, which may be zero.
+func NewEvent(res *golumn.Result, t time.Time) Event {
+	ev := Event{
+		Direction: res.Direction,
+		Applied:   res.Applied,
+		Reverted:  res.Reverted,
+		Version:   res.Version,
+	}
+	if !t.IsZero() {
+		ev.Time = t.UTC()
+	}
+	return ev
+}
+
+// Option configures the hooks returned by Publish and NATS.
+type Option func(*options)
+
+type options struct {
+	now func() time.Time
+}
+
+// WithClock stamps each Event with the time now returns instead of
+// time.Now. A nil now leaves events without a time, as a
+// golumn.Migrator.Deterministic run needs.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) { o.now = now }
+}
+
+// Publish returns an AfterSuccess hook that passes each run's Event, encoded
+// as JSON, to publish. Runs that applied and reverted nothing are skipped.
+func Publish(publish func(ctx context.Context, data []byte) error, opts ...Option) func(context.Context, *golumn.Result) error {
+	o := options{now: time.Now}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return func(ctx context.Context, res *golumn.Result) error {
+		if len(res.Applied) == 0 && len(res.Reverted) == 0 {
+			return nil
+		}
+		var t time.Time
+		if o.now != nil {
+			t = o.now()
+		}
+		data, err := json.Marshal(NewEvent(res, t))
+		if err != nil {
+			return err
+		}
+		return publish(ctx, data)
+	}
+}
+
+// NATSConn is the subset of *nats.Conn used by NATS.
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATS returns an AfterSuccess hook that publishes each run's Event to
+// subject.
+func NATS(nc NATSConn, subject string, opts ...Option) func(context.Context, *golumn.Result) error {
+	return Publish(func(_ context.Context, data []byte) error {
+		return nc.Publish(subject, data)
+	}, opts...)
+}
@@ -0,0 +1,97 @@
+package schemaevent_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/contrib/schemaevent"
+)
+
+type fakeConn struct {
+	subjects []string
+	payloads [][]byte
+	err      error
+}
+
+func (c *fakeConn) Publish(subject string, data []byte) error {
+	c.subjects = append(c.subjects, subject)
+	c.payloads = append(c.payloads, data)
+	return c.err
+}
+
+func TestNATS(t *testing.T) {
+	nc := &fakeConn{}
+	hook := schemaevent.NATS(nc, "schema.changed")
+
+	res := &golumn.Result{Direction: golumn.DirectionUp, Applied: []int64{1, 2}, Version: 2}
+	if err := hook(context.Background(), res); err != nil {
+		t.Fatalf("hook failed: %v", err)
+	}
+	if !slices.Equal(nc.subjects, []string{"schema.changed"}) {
+		t.Fatalf("expected one publish to schema.changed, got %v", nc.subjects)
+	}
+
+	var ev schemaevent.Event
+	if err := json.Unmarshal(nc.payloads[0], &ev); err != nil {
+		t.Fatalf("failed to decode event: %v", err)
+	}
+	if ev.Direction != golumn.DirectionUp || !slices.Equal(ev.Applied, []int64{1, 2}) || ev.Version != 2 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+	if ev.Time.IsZero() {
+		t.Error("expected event time")
+	}
+}
+
+func TestPublish_Clock(t *testing.T) {
+	res := &golumn.Result{Direction: golumn.DirectionUp, Applied: []int64{1}, Version: 1}
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		now  func() time.Time
+		want string
+	}{
+		"fixed":   {func() time.Time { return at }, `{"direction":"up","applied":[1],"version":1,"time":"2024-06-01T12:00:00Z"}`},
+		"without": {nil, `{"direction":"up","applied":[1],"version":1}`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got []byte
+			hook := schemaevent.Publish(func(_ context.Context, data []byte) error {
+				got = data
+				return nil
+			}, schemaevent.WithClock(tc.now))
+			if err := hook(context.Background(), res); err != nil {
+				t.Fatalf("hook failed: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("unexpected event\nwant: %s\ngot:  %s", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNATS_SkipsNoopRuns(t *testing.T) {
+	nc := &fakeConn{}
+	hook := schemaevent.NATS(nc, "schema.changed")
+
+	if err := hook(context.Background(), &golumn.Result{Direction: golumn.DirectionUp, Version: 2}); err != nil {
+		t.Fatalf("hook failed: %v", err)
+	}
+	if len(nc.subjects) != 0 {
+		t.Errorf("expected no publish, got %v", nc.subjects)
+	}
+}
+
+func TestPublish_Error(t *testing.T) {
+	want := errors.New("bus down")
+	hook := schemaevent.Publish(func(context.Context, []byte) error { return want })
+
+	err := hook(context.Background(), &golumn.Result{Direction: golumn.DirectionDown, Reverted: []int64{3}})
+	if !errors.Is(err, want) {
+		t.Fatalf("expected %v, got %v", want, err)
+	}
+}
@@ -0,0 +1,275 @@
+// Package statusview renders golumn.Status and golumn.Plan as aligned,
+// optionally colored tables for terminals and admin consoles.
+package statusview
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// Color selects whether output is colored.
+type Color int
+
+const (
+	// ColorAuto colors output written to a terminal, unless the NO_COLOR
+	// environment variable is set or TERM is "dumb".
+	ColorAuto Color = iota
+	ColorAlways
+	ColorNever
+)
+
+// Messages used for table headers and states.
+const (
+	MsgVersion golumn.MessageID = "statusview.version"
+	MsgState   golumn.MessageID = "statusview.state"
+	MsgName    golumn.MessageID = "statusview.name"
+	MsgApplied golumn.MessageID = "statusview.applied"
+	MsgPending golumn.MessageID = "statusview.pending"
+	MsgFailed  golumn.MessageID = "statusview.failed"
+	MsgApply   golumn.MessageID = "statusview.apply"
+	MsgRevert  golumn.MessageID = "statusview.revert"
+)
+
+var defaultMessages = golumn.Catalog{
+	MsgVersion: "VERSION",
+	MsgState:   "STATE",
+	MsgName:    "NAME",
+	MsgApplied: "applied",
+	MsgPending: "pending",
+	MsgFailed:  "failed",
+	MsgApply:   "apply",
+	MsgRevert:  "revert",
+}
+
+// Options controls rendering. The zero value colors output only for
+// terminals and uses English text.
+type Options struct {
+	Color Color
+	// Sources, if set, supplies migration names, and for stores that cannot
+	// list applied versions, the versions assumed applied.
+	Sources []*golumn.Migration
+	// Err, if set, is a run error whose StepErrors mark versions as failed.
+	Err error
+	// Messages translates the summary line, headers and states.
+	Messages golumn.Catalog
+}
+
+type state int
+
+const (
+	stateApplied state = iota
+	statePending
+	stateFailed
+)
+
+var stateColors = map[state]string{
+	stateApplied: "\x1b[32m",
+	statePending: "\x1b[33m",
+	stateFailed:  "\x1b[31m",
+}
+
+const (
+	bold  = "\x1b[1m"
+	reset = "\x1b[0m"
+)
+
+type row struct {
+	version int64
+	name    string
+	state   state
+	label   golumn.MessageID
+	// notes are rendered on lines of their own below the row.
+	notes []string
+}
+
+// Write renders st as a summary line followed by one row per known
+// version, each followed by its annotations.
+func Write(w io.Writer, st *golumn.Status, opts Options) error {
+	failed := failedVersions(opts.Err)
+	pending := map[int64]bool{}
+	for _, v := range st.Pending {
+		pending[v] = true
+	}
+
+	var versions []int64
+	switch {
+	case st.Applied != nil:
+		versions = append(slices.Clone(st.Applied), st.Pending...)
+	case opts.Sources != nil:
+		for _, migration := range opts.Sources {
+			versions = append(versions, migration.Version)
+		}
+	default:
+		versions = slices.Clone(st.Pending)
+	}
+	for v := range failed {
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+	versions = slices.Compact(versions)
+
+	rows := make([]row, len(versions))
+	for i, v := range versions {
+		r := row{version: v, name: nameOf(opts.Sources, v), state: stateApplied, label: MsgApplied, notes: notesOf(st.Annotations[v])}
+		switch {
+		case failed[v]:
+			r.state, r.label = stateFailed, MsgFailed
+		case pending[v]:
+			r.state, r.label = statePending, MsgPending
+			r.notes = append(r.notes, st.Impact[v].Lines(opts.Messages)...)
+		}
+		rows[i] = r
+	}
+	return render(w, st.Format(opts.Messages), rows, opts)
+}
+
+// WritePlan renders p as a summary line followed by one row per step.
+func WritePlan(w io.Writer, p *golumn.Plan, opts Options) error {
+	failed := failedVersions(opts.Err)
+	action := MsgApply
+	if p.Direction == golumn.DirectionDown {
+		action = MsgRevert
+	}
+
+	rows := make([]row, len(p.Steps))
+	for i, step := range p.Steps {
+		name := step.Name
+		if name == "" {
+			name = nameOf(opts.Sources, step.Version)
+		}
+		r := row{version: step.Version, name: name, state: statePending, label: action, notes: step.Impact.Lines(opts.Messages)}
+		if failed[step.Version] {
+			r.state, r.label = stateFailed, MsgFailed
+		}
+		rows[i] = r
+	}
+
+	c := opts.Messages
+	summary := c.Sprintf(golumn.MsgPlan, c.Direction(p.Direction), p.From, p.Target, len(p.Steps))
+	return render(w, summary, rows, opts)
+}
+
+func render(w io.Writer, summary string, rows []row, opts Options) error {
+	c := opts.Messages
+	color := useColor(w, opts.Color)
+
+	header := [3]string{text(c, MsgVersion), text(c, MsgState), text(c, MsgName)}
+	cells := make([][3]string, len(rows))
+	widths := [2]int{utf8.RuneCountInString(header[0]), utf8.RuneCountInString(header[1])}
+	named := false
+	for i, r := range rows {
+		cells[i] = [3]string{fmt.Sprint(r.version), text(c, r.label), r.name}
+		widths[0] = max(widths[0], utf8.RuneCountInString(cells[i][0]))
+		widths[1] = max(widths[1], utf8.RuneCountInString(cells[i][1]))
+		named = named || r.name != ""
+	}
+	if !named {
+		header[2] = ""
+	}
+
+	var b strings.Builder
+	b.WriteString(summary)
+	b.WriteByte('\n')
+	if len(rows) > 0 {
+		line := formatRow(header, widths)
+		if color {
+			line = bold + line + reset
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	for i, r := range rows {
+		cell := cells[i]
+		if color {
+			// Pad before coloring so escape codes do not skew alignment.
+			cell[1] = stateColors[r.state] + pad(cell[1], widths[1]) + reset
+		}
+		b.WriteString(formatRow(cell, widths))
+		b.WriteByte('\n')
+		for _, note := range r.notes {
+			b.WriteString(formatRow([3]string{"", "", note}, widths))
+			b.WriteByte('\n')
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatRow(cells [3]string, widths [2]int) string {
+	return strings.TrimRight(pad(cells[0], widths[0])+"  "+pad(cells[1], widths[1])+"  "+cells[2], " ")
+}
+
+func pad(s string, width int) string {
+	if n := width - utf8.RuneCountInString(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+func nameOf(sources []*golumn.Migration, v int64) string {
+	for _, migration := range sources {
+		if migration.Version == v {
+			return migration.Name
+		}
+	}
+	return ""
+}
+
+// notesOf returns a version's annotations as "key: value", sorted by key.
+func notesOf(annotations map[string]string) []string {
+	var notes []string
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		notes = append(notes, key+": "+annotations[key])
+	}
+	return notes
+}
+
+func failedVersions(err error) map[int64]bool {
+	failed := map[int64]bool{}
+	var me *golumn.MultiError
+	var step *golumn.StepError
+	switch {
+	case errors.As(err, &me):
+		for _, step := range me.Steps {
+			failed[step.Version] = true
+		}
+	case errors.As(err, &step):
+		failed[step.Version] = true
+	}
+	return failed
+}
+
+func useColor(w io.Writer, mode Color) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// text returns the message id from c, falling back to the package defaults.
+func text(c golumn.Catalog, id golumn.MessageID) string {
+	if s, ok := c[id]; ok {
+		return s
+	}
+	return defaultMessages[id]
+}
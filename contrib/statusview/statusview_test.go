@@ -0,0 +1,147 @@
+package statusview_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/contrib/statusview"
+)
+
+var sources = []*golumn.Migration{
+	{Version: 1, Name: "0001_init.sql"},
+	{Version: 2, Name: "0002_users.lua"},
+	{Version: 10, Name: "0010_index.sql"},
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		status *golumn.Status
+		opts   statusview.Options
+		want   string
+	}{
+		{
+			name:   "listed",
+			status: &golumn.Status{Version: 2, Applied: []int64{1, 2}, Pending: []int64{10}},
+			opts:   statusview.Options{Color: statusview.ColorNever, Sources: sources},
+			want: `version 2, 1 pending
+VERSION  STATE    NAME
+1        applied  0001_init.sql
+2        applied  0002_users.lua
+10       pending  0010_index.sql
+`,
+		},
+		{
+			name: "annotated",
+			status: &golumn.Status{
+				Version:     2,
+				Applied:     []int64{1, 2},
+				Annotations: map[int64]map[string]string{1: {"ticket": "OPS-7", "note": "superseded by 2"}},
+			},
+			opts: statusview.Options{Color: statusview.ColorNever, Sources: sources},
+			want: `version 2, 0 pending
+VERSION  STATE    NAME
+1        applied  0001_init.sql
+                  note: superseded by 2
+                  ticket: OPS-7
+2        applied  0002_users.lua
+`,
+		},
+		{
+			name:   "unlisted_with_sources",
+			status: &golumn.Status{Version: 1, Pending: []int64{2, 10}},
+			opts: statusview.Options{
+				Color:   statusview.ColorNever,
+				Sources: sources,
+				Err:     &golumn.MultiError{Steps: []*golumn.StepError{{Version: 2, Phase: golumn.PhaseApply, Err: errors.New("boom")}}},
+			},
+			want: `version 1, 2 pending
+VERSION  STATE    NAME
+1        applied  0001_init.sql
+2        failed   0002_users.lua
+10       pending  0010_index.sql
+`,
+		},
+		{
+			name:   "unlisted_without_sources",
+			status: &golumn.Status{Version: golumn.Initial, Pending: []int64{1}},
+			opts:   statusview.Options{Color: statusview.ColorNever},
+			want: `version -1, 1 pending
+VERSION  STATE
+1        pending
+`,
+		},
+		{
+			name:   "empty",
+			status: &golumn.Status{Version: golumn.Initial},
+			opts:   statusview.Options{Color: statusview.ColorNever},
+			want:   "version -1, 0 pending\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := statusview.Write(&buf, tt.status, tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("output mismatch\nwant:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWrite_Color(t *testing.T) {
+	st := &golumn.Status{Version: 1, Applied: []int64{1}, Pending: []int64{2}}
+
+	var buf bytes.Buffer
+	if err := statusview.Write(&buf, st, statusview.Options{Color: statusview.ColorAlways}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"\x1b[32mapplied\x1b[0m", "\x1b[33mpending\x1b[0m"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in %q", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := statusview.Write(&buf, st, statusview.Options{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Errorf("expected no color for a non-terminal writer, got %q", buf.String())
+	}
+}
+
+func TestWritePlan(t *testing.T) {
+	plan := &golumn.Plan{
+		Direction: golumn.DirectionDown,
+		From:      10,
+		Target:    1,
+		Steps:     []golumn.PlanStep{{Version: 10, Impact: &golumn.Impact{Tables: []string{"users"}, Indexes: []string{"users_email"}}}, {Version: 2, Name: "0002_users.lua"}},
+	}
+	opts := statusview.Options{
+		Color:    statusview.ColorNever,
+		Sources:  sources,
+		Messages: golumn.Catalog{statusview.MsgRevert: "rückgängig", golumn.MsgDirectionDown: "runter"},
+	}
+
+	var buf bytes.Buffer
+	if err := statusview.WritePlan(&buf, plan, opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `runter from 10 to 1: 2 steps
+VERSION  STATE       NAME
+10       rückgängig  0010_index.sql
+                     tables: users
+                     indexes: users_email
+2        rückgängig  0002_users.lua
+`
+	if got := buf.String(); got != want {
+		t.Errorf("output mismatch\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
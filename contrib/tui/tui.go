@@ -0,0 +1,279 @@
+// Package tui provides an interactive terminal UI over a golumn.Migrator
+// for operators: it lists migrations with their state, previews their SQL,
+// and applies or reverts them after showing the plan and asking for the
+// target version to be typed back. It reads commands line by line, so it
+// works over any terminal, including a remote shell, without putting it in
+// raw mode.
+package tui
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/contrib/statusview"
+)
+
+// Messages shown by the UI. MsgNothingTo takes a direction, and MsgConfirm
+// a target version and a direction.
+const (
+	MsgHelp            golumn.MessageID = "tui.help"
+	MsgNoSQL           golumn.MessageID = "tui.no_sql"
+	MsgNothingTo       golumn.MessageID = "tui.nothing_to"
+	MsgConfirm         golumn.MessageID = "tui.confirm"
+	MsgCanceled        golumn.MessageID = "tui.canceled"
+	MsgNothingToRevert golumn.MessageID = "tui.nothing_to_revert"
+)
+
+var defaultMessages = golumn.Catalog{
+	MsgHelp: `commands:
+  l, list           show migrations and their state
+  s, show VERSION   preview the SQL of a migration
+  u, up [VERSION]   apply migrations up to VERSION, or all pending
+  d, down VERSION   revert migrations above VERSION, -1 for all
+  t, targets        list the versions down can revert to
+  h, help           show this help
+  q, quit           exit
+`,
+	MsgNoSQL:           "(no SQL; Lua or Go migration)",
+	MsgNothingTo:       "nothing to %s",
+	MsgConfirm:         "type %d to %s, anything else cancels: ",
+	MsgCanceled:        "canceled",
+	MsgNothingToRevert: "nothing to revert",
+}
+
+// UI is an interactive session over Migrator, reading commands from In and
+// writing to Out.
+type UI struct {
+	Migrator *golumn.Migrator
+	In       io.Reader
+	Out      io.Writer
+	// View controls how states and plans are rendered. Its Sources
+	// defaults to the Migrator's migrations, as loaded by its Loader if it
+	// has one. Its Messages also translates the UI's own text.
+	View statusview.Options
+}
+
+// Run shows the migrations and then runs commands until quit or the end of
+// In. Failed commands are reported and the session goes on; only failures
+// to write to Out and cancellation of ctx end it with an error.
+func (u *UI) Run(ctx context.Context) error {
+	in := bufio.NewScanner(u.In)
+	if err := u.report(ctx, u.list(ctx)); err != nil {
+		return err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(u.Out, "golumn> "); err != nil {
+			return err
+		}
+		if !in.Scan() {
+			fmt.Fprintln(u.Out)
+			return in.Err()
+		}
+		fields := strings.Fields(in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		cmd, args := fields[0], fields[1:]
+		var err error
+		switch cmd {
+		case "l", "list":
+			err = u.list(ctx)
+		case "s", "show":
+			err = u.show(ctx, args)
+		case "u", "up":
+			err = u.run(ctx, in, golumn.DirectionUp, args)
+		case "d", "down":
+			err = u.run(ctx, in, golumn.DirectionDown, args)
+		case "t", "targets":
+			err = u.targets(ctx)
+		case "h", "help", "?":
+			_, err = fmt.Fprint(u.Out, u.text(MsgHelp))
+		case "q", "quit", "exit":
+			return nil
+		default:
+			err = fmt.Errorf("unknown command %q, try help", cmd)
+		}
+		if err := u.report(ctx, err); err != nil {
+			return err
+		}
+	}
+}
+
+// report writes a command's error, returning an error only if the session
+// must end.
+func (u *UI) report(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	_, werr := fmt.Fprintf(u.Out, "error: %v\n", err)
+	return werr
+}
+
+// text formats the message id from View.Messages, falling back to the
+// package defaults.
+func (u *UI) text(id golumn.MessageID, args ...any) string {
+	f, ok := u.View.Messages[id]
+	if !ok {
+		f = defaultMessages[id]
+	}
+	return fmt.Sprintf(f, args...)
+}
+
+// view returns View with its Sources defaulted. The migrations are looked
+// up for each command, since a Loader's may change on Reload.
+func (u *UI) view(ctx context.Context) (statusview.Options, error) {
+	opts := u.View
+	if opts.Sources == nil {
+		sources, err := u.Migrator.Migrations(ctx)
+		if err != nil {
+			return opts, err
+		}
+		opts.Sources = sources
+	}
+	return opts, nil
+}
+
+func (u *UI) list(ctx context.Context) error {
+	st, err := u.Migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	opts, err := u.view(ctx)
+	if err != nil {
+		return err
+	}
+	return statusview.Write(u.Out, st, opts)
+}
+
+func (u *UI) show(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: show VERSION")
+	}
+	v, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid version %q", args[0])
+	}
+	opts, err := u.view(ctx)
+	if err != nil {
+		return err
+	}
+	var migration *golumn.Migration
+	for _, m := range opts.Sources {
+		if m.Version == v {
+			migration = m
+		}
+	}
+	if migration == nil {
+		return fmt.Errorf("no source for version %d", v)
+	}
+	fmt.Fprintf(u.Out, "%d %s\n", migration.Version, migration.Name)
+	for _, dir := range []golumn.Direction{golumn.DirectionUp, golumn.DirectionDown} {
+		stmts, err := migration.Preview(ctx, dir)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(u.Out, "-- %s\n", dir)
+		if len(stmts) == 0 {
+			fmt.Fprintln(u.Out, u.text(MsgNoSQL))
+		}
+		for _, stmt := range stmts {
+			fmt.Fprintf(u.Out, "%s;\n", strings.TrimSuffix(strings.TrimSpace(stmt), ";"))
+		}
+	}
+	return nil
+}
+
+// run plans a run in direction dir, shows the plan and makes it once the
+// operator types its target back.
+func (u *UI) run(ctx context.Context, in *bufio.Scanner, dir golumn.Direction, args []string) error {
+	to := int64(golumn.Latest)
+	switch {
+	case len(args) == 1:
+		v, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		to = v
+	case len(args) > 1, dir == golumn.DirectionDown && len(args) == 0:
+		return fmt.Errorf("usage: %s VERSION", dir)
+	}
+
+	plan, err := u.Migrator.Plan(ctx, dir, to)
+	if err != nil {
+		return err
+	}
+	if plan == nil {
+		_, err := fmt.Fprintln(u.Out, u.text(MsgNothingTo, u.View.Messages.Direction(dir)))
+		return err
+	}
+	opts, err := u.view(ctx)
+	if err != nil {
+		return err
+	}
+	if err := statusview.WritePlan(u.Out, plan, opts); err != nil {
+		return err
+	}
+	if len(plan.Steps) == 0 {
+		return nil
+	}
+
+	fmt.Fprint(u.Out, u.text(MsgConfirm, plan.Target, u.View.Messages.Direction(dir)))
+	if !in.Scan() {
+		fmt.Fprintln(u.Out)
+		return errors.New(u.text(MsgCanceled))
+	}
+	if strings.TrimSpace(in.Text()) != strconv.FormatInt(plan.Target, 10) {
+		_, err := fmt.Fprintln(u.Out, u.text(MsgCanceled))
+		return err
+	}
+
+	var res *golumn.Result
+	if dir == golumn.DirectionUp {
+		res, err = u.Migrator.Up(ctx, plan.Target)
+	} else {
+		res, err = u.Migrator.Down(ctx, plan.Target)
+	}
+	if res != nil {
+		fmt.Fprintln(u.Out, res.Format(u.View.Messages))
+	}
+	if err != nil {
+		return err
+	}
+	return u.list(ctx)
+}
+
+func (u *UI) targets(ctx context.Context) error {
+	targets, err := u.Migrator.ListDownTargets(ctx)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		_, err := fmt.Fprintln(u.Out, u.text(MsgNothingToRevert))
+		return err
+	}
+	for _, t := range targets {
+		line := fmt.Sprintf("%d", t.Version)
+		if t.Name != "" {
+			line += " " + t.Name
+		}
+		if !t.AppliedAt.IsZero() {
+			line += " applied " + t.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		if _, err := fmt.Fprintf(u.Out, "%s (reverts %d)\n", line, len(t.Reverts)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
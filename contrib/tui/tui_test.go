@@ -0,0 +1,146 @@
+package tui_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/contrib/statusview"
+	"github.com/jonathonwebb/golumn/contrib/tui"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func newUI(t *testing.T, input string) (*tui.UI, *bytes.Buffer) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var sources []*golumn.Migration
+	for _, src := range []struct{ name, sql string }{
+		{"0001_items.sql", "-- +golumn Up\nCREATE TABLE items (id INTEGER);\n-- +golumn Down\nDROP TABLE items;\n"},
+		{"0002_index.sql", "-- +golumn Up\nCREATE INDEX items_id ON items (id);\n-- +golumn Down\nDROP INDEX items_id;\n"},
+	} {
+		m, err := golumn.ParseSQL(context.Background(), strings.NewReader(src.sql), src.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, m)
+	}
+
+	store := sqlite3store.New(db)
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	return &tui.UI{
+		Migrator: &golumn.Migrator{Store: store, Sources: sources},
+		In:       strings.NewReader(input),
+		Out:      &out,
+		View:     statusview.Options{Color: statusview.ColorNever},
+	}, &out
+}
+
+func TestUI_ApplyAndRevert(t *testing.T) {
+	ui, out := newUI(t, "up\n2\nshow 2\ndown -1\nno\nq\n")
+	if err := ui.Run(context.Background()); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	for _, want := range []string{
+		"version -1, 2 pending",
+		"type 2 to up",
+		"version 2, 0 pending",
+		"CREATE INDEX items_id ON items (id);",
+		"DROP INDEX items_id;",
+		"type -1 to down",
+		"canceled",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	st, err := ui.Migrator.Status(context.Background())
+	if err != nil || st.Version != 2 {
+		t.Errorf("expected the canceled down to leave version 2, got %v (err %v)", st, err)
+	}
+}
+
+func TestUI_CancelUp(t *testing.T) {
+	ui, out := newUI(t, "up 1\n2\n")
+	if err := ui.Run(context.Background()); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "canceled") {
+		t.Errorf("expected a mistyped target to cancel, got:\n%s", out)
+	}
+	if st, err := ui.Migrator.Status(context.Background()); err != nil || st.Version != golumn.Initial {
+		t.Errorf("expected nothing applied, got %v (err %v)", st, err)
+	}
+}
+
+func TestUI_Errors(t *testing.T) {
+	ui, out := newUI(t, "bogus\nshow 9\ndown\ntargets\n")
+	if err := ui.Run(context.Background()); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	for _, want := range []string{
+		`error: unknown command "bogus"`,
+		"error: no source for version 9",
+		"error: usage: down VERSION",
+		"nothing to revert",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestUI_Messages(t *testing.T) {
+	ui, out := newUI(t, "targets\nup 1\nnein\n")
+	ui.View.Messages = golumn.Catalog{
+		golumn.MsgDirectionUp:  "hoch",
+		tui.MsgConfirm:         "%[2]s bis %[1]d? ",
+		tui.MsgCanceled:        "abgebrochen",
+		tui.MsgNothingToRevert: "nichts zurückzusetzen",
+	}
+	if err := ui.Run(context.Background()); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	for _, want := range []string{"nichts zurückzusetzen", "hoch bis 1? ", "abgebrochen"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+// loader loads a fixed set of migrations.
+type loader []*golumn.Migration
+
+func (l loader) Load(context.Context) ([]*golumn.Migration, error) { return l, nil }
+
+func TestUI_Loader(t *testing.T) {
+	ui, out := newUI(t, "down -1\nshow 1\n")
+	ui.Migrator = &golumn.Migrator{Store: ui.Migrator.Store, Loader: loader(ui.Migrator.Sources)}
+	if err := ui.Run(context.Background()); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if strings.Contains(out.String(), "error:") {
+		t.Errorf("expected no errors, got:\n%s", out)
+	}
+	for _, want := range []string{
+		"version -1, 2 pending",
+		"0001_items.sql",
+		"CREATE TABLE items (id INTEGER);",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package golumn
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// redacted replaces secrets in Debug output.
+const redacted = "xxxxx"
+
+var (
+	// dsnUserinfo matches the password of user:password@host DSNs, e.g.
+	// postgres://u:p@h/db, and dsnBareUserinfo those without a URL scheme,
+	// e.g. MySQL's u:p@tcp(h)/db. They run to the last @, so a password
+	// containing one is redacted whole.
+	dsnUserinfo     = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*://)([^:@/\s]+):(.*)@`)
+	dsnBareUserinfo = regexp.MustCompile(`^([^:@/\s]+):(.*)@`)
+	// dsnSecretParam matches key=value parameters whose key names a secret,
+	// in URL queries and keyword DSNs such as "host=h password=p" and
+	// "Server=h;Password=p".
+	dsnSecretParam = regexp.MustCompile(`(?i)([\w.-]*(?:pass|pwd|secret|token|key)[\w.-]*)\s*=\s*('[^']*'|"[^"]*"|[^\s;&]*)`)
+	// secretEnvName matches the names of environment variables holding
+	// secrets outright.
+	secretEnvName = regexp.MustCompile(`(?i)pass|pwd|secret|token|key|credential`)
+)
+
+// RedactDSN returns dsn with its password and any parameters naming
+// secrets replaced, so that it can be logged or shared. It errs on the side
+// of redacting too much, and returns strings that are not DSNs unchanged.
+func RedactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		dsn = dsnUserinfo.ReplaceAllString(dsn, "${1}${2}:"+redacted+"@")
+	} else {
+		dsn = dsnBareUserinfo.ReplaceAllString(dsn, "${1}:"+redacted+"@")
+	}
+	return dsnSecretParam.ReplaceAllString(dsn, "${1}="+redacted)
+}
+
+// Debug writes a diagnostics report to w for support tickets: the runtime,
+// the GOLUMN_* environment, the store's type, capabilities and tables, its
+// state as Status reports it, and the Migrator's options. Passwords in DSNs
+// and secret values are redacted, see RedactDSN, and approval tokens are
+// only reported as set. Like Status it neither initializes nor locks the
+// store; failures to read it are reported inline rather than returned.
+func (m *Migrator) Debug(ctx context.Context, w io.Writer) error {
+	var b bytes.Buffer
+	line := func(format string, args ...any) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+
+	line("golumn diagnostics")
+	line("runtime: %s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	line("environment:")
+	var env []string
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, "GOLUMN_") {
+			env = append(env, name+"="+redactEnv(name, value))
+		}
+	}
+	slices.Sort(env)
+	if len(env) == 0 {
+		line("  (none)")
+	}
+	for _, kv := range env {
+		line("  %s", kv)
+	}
+
+	line("store: %T", m.Store)
+	line("  interfaces: %s", strings.Join(storeInterfaces(m.Store), ", "))
+	if tl, ok := m.Store.(TableLister); ok {
+		line("  tables: %s", strings.Join(tl.Tables(), ", "))
+	}
+	if m.StatusStore != nil {
+		line("  status store: %T", m.StatusStore)
+	}
+	if len(m.Middleware) > 0 {
+		line("  middleware: %d", len(m.Middleware))
+	}
+	if is, ok := m.store().(IdentityStore); ok {
+		if id, err := is.Identity(ctx); err != nil {
+			line("  identity: error: %v", err)
+		} else {
+			line("  identity: %s", id)
+		}
+	}
+
+	if st, err := m.Status(ctx); err != nil {
+		line("state: error: %v", err)
+	} else {
+		line("state:")
+		line("  version: %d", st.Version)
+		if st.Applied != nil {
+			line("  applied: %v", st.Applied)
+		}
+		line("  pending: %v", st.Pending)
+		line("  locked: %t", st.Locked)
+		sources := m.migrations()
+		line("  sources: %d, up to version %d", len(sources), MaxVersion(sources))
+	}
+
+	line("options:")
+	line("  loader: %s", typeOrNone(m.Loader))
+	line("  lock wait: %s", m.LockWait)
+	line("  shared reads: %t", m.SharedReads)
+	line("  hold lock on failure: %t", m.HoldLockOnFailure)
+	line("  auto revert on failure: %t", m.AutoRevertOnFailure)
+	line("  verify after run: %t", m.VerifyAfterRun)
+	line("  record stats: %t", m.RecordStats)
+	line("  refresh statistics: %s", typeOrNone(m.RefreshStatistics))
+	line("  allow mixed versions: %t", m.AllowMixedVersions)
+	line("  pin file: %q", m.PinFile)
+	line("  expected identity: %q", m.ExpectedIdentity)
+	line("  approval token: %s", setOrUnset(m.ApprovalToken != ""))
+	line("  approval verifier: %s", setOrUnset(m.VerifyApproval != nil))
+	line("  schedule: %s", typeOrNone(m.Schedule))
+	line("  flags: %s", typeOrNone(m.Flags))
+	line("  pacer: %s", typeOrNone(m.Pacer))
+	line("  lag probe: %s", typeOrNone(m.LagProbe))
+	line("  audit: %s", typeOrNone(m.Audit))
+	line("  deterministic: %t", m.Deterministic)
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// redactEnv redacts the value of the environment variable name.
+func redactEnv(name, value string) string {
+	if secretEnvName.MatchString(name) {
+		return redacted
+	}
+	return RedactDSN(value)
+}
+
+// storeInterfaces names the optional interfaces s implements.
+func storeInterfaces(s Store) []string {
+	var names []string
+	for _, i := range []struct {
+		name string
+		ok   bool
+	}{
+		{"VersionLister", implements[VersionLister](s)},
+		{"ForceUnlocker", implements[ForceUnlocker](s)},
+		{"ConnReserver", implements[ConnReserver](s)},
+		{"Targeter", implements[Targeter](s)},
+		{"LockInspector", implements[LockInspector](s)},
+		{"SharedLocker", implements[SharedLocker](s)},
+		{"Explainer", implements[Explainer](s)},
+		{"Annotator", implements[Annotator](s)},
+		{"CompatRegistry", implements[CompatRegistry](s)},
+		{"Exporter", implements[Exporter](s)},
+		{"IdentityStore", implements[IdentityStore](s)},
+		{"TableLister", implements[TableLister](s)},
+		{"ProductionStore", implements[ProductionStore](s)},
+	} {
+		if i.ok {
+			names = append(names, i.name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{"(none)"}
+	}
+	return names
+}
+
+func implements[T any](v any) bool {
+	_, ok := v.(T)
+	return ok
+}
+
+func typeOrNone(v any) string {
+	if v == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func setOrUnset(set bool) string {
+	if set {
+		return "set"
+	}
+	return "unset"
+}
@@ -0,0 +1,85 @@
+package golumn_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestRedactDSN(t *testing.T) {
+	for _, tt := range []struct{ dsn, want string }{
+		{"postgres://app:hunter2@db:5432/app?sslmode=disable", "postgres://app:xxxxx@db:5432/app?sslmode=disable"},
+		{"postgres://app@db/app?password=hunter2&sslmode=disable", "postgres://app@db/app?password=xxxxx&sslmode=disable"},
+		{"app:p@ss@tcp(db:3306)/app", "app:xxxxx@tcp(db:3306)/app"},
+		{"host=db user=app password='hun ter2' dbname=app", "host=db user=app password=xxxxx dbname=app"},
+		{"Server=db;User Id=sa;Password=hunter2;Database=app", "Server=db;User Id=sa;Password=xxxxx;Database=app"},
+		{"file:app.db?_auth_user=app&_auth_pass=hunter2", "file:app.db?_auth_user=app&_auth_pass=xxxxx"},
+		{"/var/lib/app.db", "/var/lib/app.db"},
+		{"prod", "prod"},
+	} {
+		if got := golumn.RedactDSN(tt.dsn); got != tt.want {
+			t.Errorf("RedactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+	}
+}
+
+func TestMigrator_Debug(t *testing.T) {
+	t.Setenv("GOLUMN_DB", "postgres://app:hunter2@db/app")
+	t.Setenv("GOLUMN_API_TOKEN", "s3cret")
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	store := sqlite3store.New(db)
+	store.Lineage = "reporting"
+
+	ctx := context.Background()
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2, 3), ApprovalToken: "tok-hunter2"}
+	if _, err := migrator.Up(ctx, 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := migrator.Debug(ctx, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"GOLUMN_DB=postgres://app:xxxxx@db/app",
+		"GOLUMN_API_TOKEN=xxxxx",
+		"store: *sqlitestore.SqliteStore",
+		"IdentityStore",
+		"tables: schema_lock_reporting, schema_shared_locks_reporting, schema_migrations_reporting, schema_annotations_reporting, schema_compat_reporting, schema_identity",
+		"applied: [1 2]",
+		"pending: [3]",
+		"approval token: set",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q\n%s", want, out)
+		}
+	}
+	for _, secret := range []string{"hunter2", "s3cret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("expected %q to be redacted\n%s", secret, out)
+		}
+	}
+
+	// A store that cannot be read is reported, not returned.
+	broken := &golumn.Migrator{Store: sqlite3store.New(db), Sources: createMigrations(1)}
+	db.Close()
+	buf.Reset()
+	if err := broken.Debug(ctx, &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "state: error:") {
+		t.Errorf("expected the state error to be reported\n%s", buf.String())
+	}
+}
@@ -3,6 +3,8 @@
 ---@class Result
 local Result = {}
 
+---Raises an error with drivers that cannot report it, such as remote libSQL
+---drivers; use RETURNING instead.
 ---@return number
 function Result:last_insert_id() end
 
@@ -11,6 +13,22 @@ function Result:rows_affected() end
 
 ---@alias Rows fun(): table<string, any>, string?
 
+---@class Cursor
+local Cursor = {}
+
+---@return table<string, any>?
+function Cursor:next() end
+
+---@param n integer
+---@return table<string, any>[]
+function Cursor:fetch(n) end
+
+---@return Rows
+function Cursor:rows() end
+
+---@return boolean
+function Cursor:close() end
+
 ---@class Transaction
 local Transaction = {}
 
@@ -19,17 +37,52 @@ local Transaction = {}
 ---@return Result
 function Transaction:exec(q, ...) end
 
+---Like exec, but returns the rows affected and last insert id directly,
+---each nil if the driver cannot report it.
 ---@param q string
 ---@param ... any?
----@return Rows
+---@return number? rows_affected
+---@return number? last_insert_id
+function Transaction:exec_affected(q, ...) end
+
+---Like exec, but raises an error unless exactly n rows are affected.
+---@param n integer
+---@param q string
+---@param ... any?
+---@return number rows_affected
+function Transaction:exec_expect(n, q, ...) end
+
+---Like exec, but raises an error unless at least n rows are affected.
+---@param n integer
+---@param q string
+---@param ... any?
+---@return number rows_affected
+function Transaction:exec_at_least(n, q, ...) end
+
+---@param q string
+---@param ... any?
+---@return Rows, Cursor
 function Transaction:query(q, ...) end
 
+---@param q string
+---@param ... any?
+---@return table<string, any>[]
+function Transaction:query_all(q, ...) end
+
+---@param q string
+---@param ... any?
+---@return Cursor
+function Transaction:cursor(q, ...) end
+
 ---@return boolean
 function Transaction:commit() end
 
 ---@return boolean
 function Transaction:rollback() end
 
+---@return boolean
+function Transaction:is_open() end
+
 ---@module 'transaction'
 local M = {}
 
@@ -52,9 +105,80 @@ function M.begin(options) end
 ---@return Result
 function M.exec(q, ...) end
 
+---Like exec, but returns the rows affected and last insert id directly,
+---each nil if the driver cannot report it.
 ---@param q string
 ---@param ... any?
----@return Rows
+---@return number? rows_affected
+---@return number? last_insert_id
+---@return string? err
+function M.exec_affected(q, ...) end
+
+---Like exec, but raises an error unless exactly n rows are affected, so
+---that a wrong WHERE clause fails the migration.
+---@param n integer
+---@param q string
+---@param ... any?
+---@return number rows_affected
+function M.exec_expect(n, q, ...) end
+
+---Like exec, but raises an error unless at least n rows are affected.
+---@param n integer
+---@param q string
+---@param ... any?
+---@return number rows_affected
+function M.exec_at_least(n, q, ...) end
+
+---@param q string
+---@param ... any?
+---@return Rows, Cursor
 function M.query(q, ...) end
 
+---@param q string
+---@param ... any?
+---@return table<string, any>[]
+function M.query_all(q, ...) end
+
+---@param q string
+---@param ... any?
+---@return Cursor
+function M.cursor(q, ...) end
+
+---@param table string
+---@param from string YYYY-MM-DD or RFC 3339
+---@param to string YYYY-MM-DD or RFC 3339
+---@return string? name
+---@return string? err
+function M.create_partition(table, from, to) end
+
+---@param table string
+---@param from string YYYY-MM-DD or RFC 3339
+---@return boolean? ok
+---@return string? err
+function M.drop_partition(table, from) end
+
+---@param table string
+---@param options? { interval?: '"daily"'|'"monthly"', ahead?: integer, keep?: integer, now?: string }
+---@return { created: string[], dropped: string[] }? result
+---@return string? err
+function M.rotate_partitions(table, options) end
+
+---@param table string
+---@param column string
+---@param options? { strategy?: '"hash"'|'"mask"'|'"email"'|'"null"'|fun(value: string): (string|number|nil), salt?: string, length?: integer, keep?: integer, key?: string, batch?: integer, numbered?: boolean }
+---@return integer? count
+---@return string? err
+function M.anonymize(table, column, options) end
+
+---@param value string
+---@param salt? string
+---@param length? integer
+---@return string
+function M.hash(value, salt, length) end
+
+---@param value string
+---@param keep? integer
+---@return string
+function M.mask(value, keep) end
+
 return M
@@ -0,0 +1,15 @@
+---@meta
+
+---@module 'hooks'
+local M = {}
+
+---@param name string
+---@param ... any?
+---@return any
+function M.call(name, ...) end
+
+---@param name string
+---@return boolean
+function M.has(name) end
+
+return M
@@ -0,0 +1,103 @@
+package golumn
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// DownTarget is a version Down can revert the store to, for pickers in CLIs
+// and admin UIs.
+type DownTarget struct {
+	// Version is the target, an applied version or Initial.
+	Version int64
+	// Name is the name of the target's migration, or empty for Initial.
+	Name string
+	// AppliedAt is when the target was applied, or zero for Initial or if
+	// the store does not record it.
+	AppliedAt time.Time
+	// Annotations are the notes attached to the target with
+	// Migrator.Annotate.
+	Annotations map[string]string
+	// Reverts lists the versions Down to the target reverts, in the order
+	// it reverts them.
+	Reverts []int64
+}
+
+// ListDownTargets returns the versions Down can revert to from the store's
+// current state, nearest first and ending with Initial. Down needs the
+// source of every version it reverts, so the list stops above the first
+// applied version missing from Sources. Like Status it neither initializes
+// nor locks the store, and reads from StatusStore if set; application
+// times are read if the store is an Exporter.
+func (m *Migrator) ListDownTargets(ctx context.Context) ([]DownTarget, error) {
+	st, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sources := map[int64]*Migration{}
+	for _, migration := range m.migrations() {
+		sources[migration.Version] = migration
+	}
+	applied := st.Applied
+	if applied == nil {
+		for _, migration := range m.migrations() {
+			if migration.Version <= st.Version {
+				applied = append(applied, migration.Version)
+			}
+		}
+	}
+	appliedAt, err := m.appliedTimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []DownTarget
+	for i := len(applied) - 1; i >= 0; i-- {
+		if sources[applied[i]] == nil {
+			break
+		}
+		reverts := slices.Clone(applied[i:])
+		slices.Reverse(reverts)
+		target := DownTarget{Version: Initial, Reverts: reverts}
+		if i > 0 {
+			v := applied[i-1]
+			target.Version = v
+			target.AppliedAt = appliedAt[v]
+			target.Annotations = st.Annotations[v]
+			if migration := sources[v]; migration != nil {
+				target.Name = migration.Name
+			}
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// appliedTimes returns when each applied version was applied, or nil if
+// the status store is not an Exporter.
+func (m *Migrator) appliedTimes(ctx context.Context) (map[int64]time.Time, error) {
+	exporter, ok := m.statusStore().(Exporter)
+	if !ok {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := exporter.Export(ctx, &buf); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to export version store: %w", err)
+	}
+	h, err := ReadHistory(&buf)
+	if err != nil {
+		return nil, err
+	}
+	times := make(map[int64]time.Time, len(h.Versions))
+	for _, v := range h.Versions {
+		times[v.Version] = v.AppliedAt
+	}
+	return times, nil
+}
@@ -0,0 +1,89 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestMigrator_ListDownTargets(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	sources := createMigrations(1, 2, 3)
+	for _, s := range sources {
+		s.Name = fmt.Sprintf("%d_step.lua", s.Version)
+	}
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: sources}
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := migrator.Annotate(ctx, 1, "note", "baseline"); err != nil {
+		t.Fatalf("annotate failed: %v", err)
+	}
+
+	targets, err := migrator.ListDownTargets(ctx)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	want := []struct {
+		version int64
+		name    string
+		reverts []int64
+	}{
+		{2, "2_step.lua", []int64{3}},
+		{1, "1_step.lua", []int64{3, 2}},
+		{golumn.Initial, "", []int64{3, 2, 1}},
+	}
+	if len(targets) != len(want) {
+		t.Fatalf("expected %d targets, got %+v", len(want), targets)
+	}
+	for i, w := range want {
+		got := targets[i]
+		if got.Version != w.version || got.Name != w.name || !slices.Equal(got.Reverts, w.reverts) {
+			t.Errorf("target %d: expected %d %q reverting %v, got %+v", i, w.version, w.name, w.reverts, got)
+		}
+		if applied := got.Version != golumn.Initial; got.AppliedAt.IsZero() == applied {
+			t.Errorf("target %d: unexpected applied time %v", i, got.AppliedAt)
+		}
+	}
+	if targets[1].Annotations["note"] != "baseline" {
+		t.Errorf("expected annotations on version 1, got %v", targets[1].Annotations)
+	}
+
+	// Down cannot revert past a version without a source.
+	migrator = &golumn.Migrator{Store: sqlite3store.New(db), Sources: createMigrations(1, 3)}
+	targets, err = migrator.ListDownTargets(ctx)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(targets) != 1 || targets[0].Version != 2 {
+		t.Errorf("expected only target 2, got %+v", targets)
+	}
+}
+
+func TestMigrator_ListDownTargetsWithoutLister(t *testing.T) {
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2)}
+	ctx := context.Background()
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	targets, err := migrator.ListDownTargets(ctx)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(targets) != 2 || targets[0].Version != 1 || targets[1].Version != golumn.Initial {
+		t.Errorf("expected targets 1 and Initial, got %+v", targets)
+	}
+}
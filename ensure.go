@@ -0,0 +1,68 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultEnsureLockWait is how long EnsureMigrated waits for another process
+// that is already migrating, unless EnsureOptions.LockWait is set.
+const DefaultEnsureLockWait = 5 * time.Minute
+
+// EnsureOptions configures EnsureMigrated. The zero value, or nil, suits most
+// services.
+type EnsureOptions struct {
+	Log *Logger
+	// LockWait is how long to wait for another process holding the store
+	// lock, e.g. a replica that booted first. DefaultEnsureLockWait if
+	// zero.
+	LockWait time.Duration
+	// PinFile, if set, limits the run to the pinned version. See
+	// Migrator.PinFile.
+	PinFile string
+	// AutoRevertOnFailure reverts the migrations applied by a failed run,
+	// so that the service fails to boot against the schema it started
+	// with.
+	AutoRevertOnFailure bool
+}
+
+// EnsureMigrated is a one-call startup helper for services that migrate
+// their own database in main: it loads migrations from loader, validates
+// them and applies any pending ones. If none are pending it returns without
+// taking the store lock, so booting replicas of an up-to-date service do
+// not queue behind each other. If another process is migrating, it waits up
+// to LockWait for that run and then applies whatever is still pending.
+func EnsureMigrated(ctx context.Context, store Store, loader Loader, opts *EnsureOptions) (*Result, error) {
+	if opts == nil {
+		opts = &EnsureOptions{}
+	}
+	if loader == nil {
+		return &Result{Direction: DirectionUp, Version: Initial}, errors.New("no loader configured")
+	}
+	m := &Migrator{
+		Store:               store,
+		Loader:              loader,
+		Log:                 opts.Log,
+		LockWait:            opts.LockWait,
+		PinFile:             opts.PinFile,
+		AutoRevertOnFailure: opts.AutoRevertOnFailure,
+	}
+	if m.LockWait == 0 {
+		m.LockWait = DefaultEnsureLockWait
+	}
+
+	// An uninitialized store cannot report its status; Up initializes it.
+	if st, err := m.Status(ctx); err == nil && len(st.Pending) == 0 {
+		m.logger().Infof("migrations up to date at version %d", st.Version)
+		return &Result{Direction: DirectionUp, Version: st.Version}, nil
+	}
+
+	var to int64 = Latest
+	if pin, pinned, err := m.pin(); err != nil {
+		return &Result{Direction: DirectionUp, Version: Initial}, err
+	} else if pinned {
+		to = pin
+	}
+	return m.Up(ctx, to)
+}
@@ -0,0 +1,84 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestEnsureMigrated(t *testing.T) {
+	t.Run("applies_pending", func(t *testing.T) {
+		store := &fakeStore{}
+		res, err := golumn.EnsureMigrated(context.Background(), store, &countingLoader{migrations: createMigrations(1, 2)}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(store.applied, []int64{1, 2}) || res.Version != 2 {
+			t.Errorf("expected [1 2] applied, got %v (version %d)", store.applied, res.Version)
+		}
+	})
+
+	t.Run("up_to_date_skips_lock", func(t *testing.T) {
+		store := &fakeStore{versions: []int64{1, 2}}
+		res, err := golumn.EnsureMigrated(context.Background(), store, &countingLoader{migrations: createMigrations(1, 2)}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if store.lockCalls != 0 || store.initCalls != 0 {
+			t.Errorf("expected no init or lock calls, got %d and %d", store.initCalls, store.lockCalls)
+		}
+		if res.Version != 2 || res.Skipped != 0 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+	})
+
+	t.Run("waits_for_other_runner", func(t *testing.T) {
+		store := &fakeStore{}
+		store.lockFunc = func(ctx context.Context, s *fakeStore) error {
+			switch s.lockCalls {
+			case 1:
+				return golumn.ErrLocked
+			case 2:
+				// The other runner finishes applying version 1.
+				s.versions = append(s.versions, 1)
+				return golumn.ErrLocked
+			}
+			return defaultLockFunc(ctx, s)
+		}
+		if _, err := golumn.EnsureMigrated(context.Background(), store, &countingLoader{migrations: createMigrations(1, 2)}, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(store.applied, []int64{2}) {
+			t.Errorf("expected only [2] applied, got %v", store.applied)
+		}
+	})
+
+	t.Run("pinned", func(t *testing.T) {
+		store := &fakeStore{}
+		opts := &golumn.EnsureOptions{PinFile: writePinFile(t, "1\n")}
+		if _, err := golumn.EnsureMigrated(context.Background(), store, &countingLoader{migrations: createMigrations(1, 2)}, opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(store.applied, []int64{1}) {
+			t.Errorf("expected [1] applied, got %v", store.applied)
+		}
+	})
+
+	t.Run("invalid_sources", func(t *testing.T) {
+		store := &fakeStore{}
+		_, err := golumn.EnsureMigrated(context.Background(), store, &countingLoader{migrations: createMigrations(2, 1)}, nil)
+		if err == nil || store.initCalls != 0 {
+			t.Errorf("expected validation error before init, got %v (%d init calls)", err, store.initCalls)
+		}
+	})
+
+	t.Run("load_error", func(t *testing.T) {
+		loadErr := errors.New("boom")
+		if _, err := golumn.EnsureMigrated(context.Background(), &fakeStore{}, &countingLoader{err: loadErr}, nil); !errors.Is(err, loadErr) {
+			t.Errorf("expected load error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,143 @@
+package golumn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrDirty matches the error of a run that failed with HoldLockOnFailure
+	// set, leaving the store locked for inspection.
+	ErrDirty = errors.New("version store left locked after failure")
+	// ErrDrift matches errors caused by the store recording a version that
+	// has no source.
+	ErrDrift = errors.New("version store has drifted from sources")
+)
+
+// Phase identifies the step of a run that failed for a migration.
+type Phase string
+
+const (
+	PhaseApply      Phase = "apply"
+	PhaseRevert     Phase = "revert"
+	PhaseAutoRevert Phase = "auto-revert"
+	PhaseInsert     Phase = "insert"
+	PhaseRemove     Phase = "remove"
+	PhaseAudit      Phase = "audit"
+)
+
+// StepError is the failure of a single step for a single migration.
+type StepError struct {
+	Version int64
+	// Name is the migration's name, typically its source file.
+	Name  string
+	Phase Phase
+	Err   error
+}
+
+func (e *StepError) Error() string {
+	switch e.Phase {
+	case PhaseApply:
+		return fmt.Sprintf("failed to apply migration %d: %v", e.Version, e.Err)
+	case PhaseRevert:
+		return fmt.Sprintf("failed to revert migration %d: %v", e.Version, e.Err)
+	case PhaseAutoRevert:
+		return fmt.Sprintf("failed to auto-revert migration %d: %v", e.Version, e.Err)
+	case PhaseInsert:
+		return fmt.Sprintf("failed to insert migration %d in version store: %v", e.Version, e.Err)
+	case PhaseRemove:
+		return fmt.Sprintf("failed to delete migration %d from version store: %v", e.Version, e.Err)
+	case PhaseAudit:
+		return fmt.Sprintf("failed to record migration %d in audit sink: %v", e.Version, e.Err)
+	default:
+		return fmt.Sprintf("migration %d: %s: %v", e.Version, e.Phase, e.Err)
+	}
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError holds the step failures of a run in the order they occurred,
+// e.g. a failed apply followed by a failed auto-revert. errors.Is and
+// errors.As see through it to each step and its wrapped error.
+type MultiError struct {
+	Steps []*StepError
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, len(e.Steps))
+	for i, step := range e.Steps {
+		msgs[i] = step.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Steps))
+	for i, step := range e.Steps {
+		errs[i] = step
+	}
+	return errs
+}
+
+// stepErrors returns a *MultiError of the non-nil steps, or nil if there are
+// none.
+func stepErrors(steps ...*StepError) error {
+	var me MultiError
+	for _, step := range steps {
+		if step != nil {
+			me.Steps = append(me.Steps, step)
+		}
+	}
+	if len(me.Steps) == 0 {
+		return nil
+	}
+	return &me
+}
+
+// SourceError attributes an error to a position in a migration source file.
+// Parse errors, Lua runtime errors and failed statements of parsed SQL files
+// carry one, so errors.As recovers the file and line from a run's error.
+type SourceError struct {
+	File string
+	// Line is the 1-based line of the error, or 0 if unknown.
+	Line int
+	Err  error
+}
+
+func (e *SourceError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.File, e.Err)
+}
+
+func (e *SourceError) Unwrap() error {
+	return e.Err
+}
+
+// dirtyError marks err as ErrDirty without changing its message.
+type dirtyError struct {
+	err error
+}
+
+func (e *dirtyError) Error() string {
+	return e.err.Error()
+}
+
+func (e *dirtyError) Unwrap() []error {
+	return []error{e.err, ErrDirty}
+}
+
+// driftError reports a store version with no source.
+type driftError int64
+
+func (e driftError) Error() string {
+	return fmt.Sprintf("missing remote version migration: %d", int64(e))
+}
+
+func (e driftError) Is(target error) bool {
+	return target == ErrDrift
+}
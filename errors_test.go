@@ -0,0 +1,160 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMultiError_AutoRevert(t *testing.T) {
+	errUp := errors.New("up error")
+	errDown := errors.New("down error")
+	migrator := &golumn.Migrator{
+		Store: &fakeStore{},
+		Sources: []*golumn.Migration{
+			{Version: 1, UpFunc: noopMigration, DownFunc: func(context.Context, *sql.DB) error { return errDown }},
+			{Version: 2, UpFunc: func(context.Context, *sql.DB) error { return errUp }, DownFunc: noopMigration},
+		},
+		AutoRevertOnFailure: true,
+	}
+
+	_, err := migrator.Up(context.Background(), 2)
+
+	var me *golumn.MultiError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected *MultiError, got %T: %v", err, err)
+	}
+	want := []golumn.StepError{
+		{Version: 2, Phase: golumn.PhaseApply, Err: errUp},
+		{Version: 1, Phase: golumn.PhaseAutoRevert, Err: errDown},
+	}
+	if len(me.Steps) != len(want) {
+		t.Fatalf("expected %d steps, got %d: %v", len(want), len(me.Steps), err)
+	}
+	for i, step := range me.Steps {
+		if *step != want[i] {
+			t.Errorf("step %d: want %+v, got %+v", i, want[i], *step)
+		}
+	}
+
+	if !errors.Is(err, errUp) || !errors.Is(err, errDown) {
+		t.Error("expected errors.Is to match both wrapped errors")
+	}
+	var step *golumn.StepError
+	if !errors.As(err, &step) || step.Version != 2 {
+		t.Errorf("expected errors.As to find the first step, got %+v", step)
+	}
+	if got, want := err.Error(), "failed to apply migration 2: up error\nfailed to auto-revert migration 1: down error"; got != want {
+		t.Errorf("message mismatch\nwant: %q\ngot:  %q", want, got)
+	}
+}
+
+func TestMultiError_Phases(t *testing.T) {
+	errStore := errors.New("store error")
+	tests := []struct {
+		name      string
+		migrator  func() *golumn.Migrator
+		down      bool
+		wantPhase golumn.Phase
+	}{
+		{
+			name: "insert",
+			migrator: func() *golumn.Migrator {
+				store := &fakeStore{insertFunc: func(context.Context, int64, *fakeStore) error { return errStore }}
+				return &golumn.Migrator{Store: store, Sources: createMigrations(1)}
+			},
+			wantPhase: golumn.PhaseInsert,
+		},
+		{
+			name: "revert",
+			migrator: func() *golumn.Migrator {
+				return &golumn.Migrator{
+					Store:   &fakeStore{versions: []int64{1}},
+					Sources: []*golumn.Migration{{Version: 1, UpFunc: noopMigration, DownFunc: func(context.Context, *sql.DB) error { return errStore }}},
+				}
+			},
+			down:      true,
+			wantPhase: golumn.PhaseRevert,
+		},
+		{
+			name: "remove",
+			migrator: func() *golumn.Migrator {
+				store := &fakeStore{versions: []int64{1}, removeFunc: func(context.Context, int64, *fakeStore) error { return errStore }}
+				return &golumn.Migrator{Store: store, Sources: createMigrations(1)}
+			},
+			down:      true,
+			wantPhase: golumn.PhaseRemove,
+		},
+		{
+			name: "audit",
+			migrator: func() *golumn.Migrator {
+				return &golumn.Migrator{
+					Store:   &fakeStore{},
+					Sources: createMigrations(1),
+					Audit:   golumn.AuditFunc(func(context.Context, golumn.AuditEvent) error { return errStore }),
+				}
+			},
+			wantPhase: golumn.PhaseAudit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.down {
+				_, err = tt.migrator().Down(context.Background(), golumn.DownTargetInitial)
+			} else {
+				_, err = tt.migrator().Up(context.Background(), 1)
+			}
+
+			var step *golumn.StepError
+			if !errors.As(err, &step) {
+				t.Fatalf("expected *StepError, got %v", err)
+			}
+			if step.Version != 1 || step.Phase != tt.wantPhase || !errors.Is(err, errStore) {
+				t.Errorf("unexpected step: %+v", step)
+			}
+		})
+	}
+}
+
+func TestRunErrors_DirtyAndDrift(t *testing.T) {
+	t.Run("dirty", func(t *testing.T) {
+		migrator := &golumn.Migrator{
+			Store:             &fakeStore{},
+			Sources:           []*golumn.Migration{{Version: 1, UpFunc: errorMigration("up error"), DownFunc: noopMigration}},
+			HoldLockOnFailure: true,
+		}
+		_, err := migrator.Up(context.Background(), golumn.Latest)
+		if !errors.Is(err, golumn.ErrDirty) {
+			t.Fatalf("expected ErrDirty, got %v", err)
+		}
+		var me *golumn.MultiError
+		if !errors.As(err, &me) || err.Error() != me.Error() {
+			t.Errorf("expected the step errors to be kept unchanged, got %v", err)
+		}
+	})
+
+	t.Run("not_dirty_when_released", func(t *testing.T) {
+		migrator := &golumn.Migrator{
+			Store:   &fakeStore{},
+			Sources: []*golumn.Migration{{Version: 1, UpFunc: errorMigration("up error"), DownFunc: noopMigration}},
+		}
+		if _, err := migrator.Up(context.Background(), golumn.Latest); err == nil || errors.Is(err, golumn.ErrDirty) {
+			t.Errorf("expected a failure without ErrDirty, got %v", err)
+		}
+	})
+
+	t.Run("drift", func(t *testing.T) {
+		migrator := &golumn.Migrator{Store: &fakeStore{versions: []int64{1, 5}}, Sources: createMigrations(1, 2)}
+		_, err := migrator.Down(context.Background(), golumn.Initial)
+		if !errors.Is(err, golumn.ErrDrift) {
+			t.Errorf("expected ErrDrift, got %v", err)
+		}
+		if want := "missing remote version migration: 5"; err == nil || err.Error() != want {
+			t.Errorf("expected %q, got %v", want, err)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// StatementPlan is the query plan of one statement of a planned SQL
+// migration, attached to a Plan by WithExplain.
+type StatementPlan struct {
+	Version int64 `json:"version"`
+	// Statement is the statement's 1-based index in the migration.
+	Statement int `json:"statement"`
+	// Line is the statement's first line in its source file, or 0 if
+	// unknown.
+	Line int    `json:"line,omitempty"`
+	SQL  string `json:"sql"`
+	Plan string `json:"plan,omitempty"`
+	// Error reports why the statement could not be explained, e.g. because
+	// it reads a table created by an earlier, unapplied statement.
+	Error string `json:"error,omitempty"`
+}
+
+// explain attaches the store's query plans for the statements of
+// migrations, the steps of p, to p.
+func (m *Migrator) explain(ctx context.Context, p *Plan, migrations []*Migration) error {
+	explainer, ok := m.store().(Explainer)
+	if !ok {
+		return errors.New("version store does not support explain")
+	}
+
+	for _, migration := range migrations {
+		stmts, err := migration.statements(ctx, p.Direction)
+		if err != nil {
+			return fmt.Errorf("failed to load migration %d for explain: %w", migration.Version, err)
+		}
+		for j, stmt := range stmts {
+			if stmt.body {
+				// Routine definitions have no query plan.
+				continue
+			}
+			plan, err := explainer.Explain(ctx, stmt.sql)
+			if errors.Is(err, errors.ErrUnsupported) {
+				continue
+			}
+			sp := StatementPlan{Version: migration.Version, Statement: j + 1, Line: stmt.line, SQL: stmt.sql, Plan: plan}
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				sp.Error = err.Error()
+			}
+			p.Statements = append(p.Statements, sp)
+		}
+	}
+	return nil
+}
+
+// Preview returns the SQL statements the migration runs in direction dir,
+// e.g. to show them to an operator before a run. Lua and Go migrations have
+// none.
+func (m *Migration) Preview(ctx context.Context, dir Direction) ([]string, error) {
+	stmts, err := m.statements(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	sqls := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		sqls[i] = stmt.sql
+	}
+	return sqls, nil
+}
+
+// statements returns the SQL statements the migration runs in direction
+// dir, loading it first if it is lazily loaded. Lua and Go migrations have
+// none.
+func (m *Migration) statements(ctx context.Context, dir Direction) ([]sqlStatement, error) {
+	if m.load != nil {
+		loaded, err := m.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m = loaded
+	}
+	if dir == DirectionDown {
+		return m.down, nil
+	}
+	return m.up, nil
+}
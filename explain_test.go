@@ -0,0 +1,114 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type explainStore struct {
+	*fakeStore
+}
+
+func (s *explainStore) Explain(_ context.Context, stmt string) (string, error) {
+	switch {
+	case strings.HasPrefix(stmt, "CREATE"):
+		return "", errors.ErrUnsupported
+	case strings.Contains(stmt, "missing"):
+		return "", errors.New("no such table: missing")
+	}
+	return "SCAN " + stmt, nil
+}
+
+func TestMigrator_Explain(t *testing.T) {
+	parsed, err := golumn.ParseSQL(context.Background(), strings.NewReader("-- +golumn Up\nCREATE TABLE a (id INTEGER);\n\nINSERT INTO a SELECT id FROM missing;\n-- +golumn Down\nDELETE FROM a;\n"), "0001_a.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy := golumn.MigrationSource{
+		Version: 3,
+		Name:    "0003_c.sql",
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("-- +golumn Up\nUPDATE a SET id = 1;\n-- +golumn StatementBegin\nUPDATE a SET id = 2;\n-- +golumn StatementEnd\n")), nil
+		},
+	}.Migration()
+	sources := []*golumn.Migration{
+		parsed,
+		golumn.LuaMigration(2, "0002_b.lua", "Version=2\nfunction Up() end\n"),
+		lazy,
+	}
+	migrator := &golumn.Migrator{Store: &explainStore{&fakeStore{}}, Sources: sources}
+
+	res, err := migrator.Up(context.Background(), golumn.Latest, golumn.WithDryRun(), golumn.WithExplain())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []golumn.StatementPlan{
+		{Version: 1, Statement: 2, Line: 4, SQL: "INSERT INTO a SELECT id FROM missing", Error: "no such table: missing"},
+		{Version: 3, Statement: 1, Line: 2, SQL: "UPDATE a SET id = 1", Plan: "SCAN UPDATE a SET id = 1"},
+	}
+	got := res.Plan.Statements
+	if len(got) != len(want) {
+		t.Fatalf("got %d statement plans, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("statement plan %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if s := res.Plan.String(); !strings.Contains(s, "statement 1: UPDATE a SET id = 1\n      SCAN UPDATE a SET id = 1") || !strings.Contains(s, "explain failed: no such table: missing") {
+		t.Errorf("unexpected plan output:\n%s", s)
+	}
+
+	migrator = &golumn.Migrator{Store: &explainStore{&fakeStore{}}, Sources: createMigrations(1)}
+	res, err = migrator.Up(context.Background(), golumn.Latest, golumn.WithExplain())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res.Plan.Statements) != 0 {
+		t.Errorf("expected no statement plans outside a dry run, got %+v", res.Plan.Statements)
+	}
+}
+
+func TestMigrator_ExplainDown(t *testing.T) {
+	parsed, err := golumn.ParseSQL(context.Background(), strings.NewReader("-- +golumn Up\nCREATE TABLE a (id INTEGER);\n-- +golumn Down\nDELETE FROM a;\n"), "0001_a.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator := &golumn.Migrator{Store: &explainStore{&fakeStore{versions: []int64{1}}}, Sources: []*golumn.Migration{parsed}}
+
+	plan, err := migrator.Plan(context.Background(), golumn.DirectionDown, golumn.Initial, golumn.WithExplain())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(plan.Statements) != 1 || plan.Statements[0].SQL != "DELETE FROM a" || plan.Statements[0].Line != 4 {
+		t.Errorf("unexpected statement plans: %+v", plan.Statements)
+	}
+}
+
+func TestMigrator_ExplainUnsupported(t *testing.T) {
+	migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1)}
+	if _, err := migrator.Up(context.Background(), golumn.Latest, golumn.WithDryRun(), golumn.WithExplain()); err == nil {
+		t.Error("expected error for a store without Explain")
+	}
+}
+
+func TestMigration_Preview(t *testing.T) {
+	parsed, err := golumn.ParseSQL(context.Background(), strings.NewReader("-- +golumn Up\nCREATE TABLE a (id INTEGER);\n\nINSERT INTO a VALUES (1);\n-- +golumn Down\nDROP TABLE a;\n"), "0001_a.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	up, err := parsed.Preview(ctx, golumn.DirectionUp)
+	if err != nil || len(up) != 2 || !strings.HasPrefix(up[1], "INSERT INTO a") {
+		t.Errorf("unexpected up preview %q (err %v)", up, err)
+	}
+	down, err := parsed.Preview(ctx, golumn.DirectionDown)
+	if err != nil || len(down) != 1 || !strings.HasPrefix(down[0], "DROP TABLE a") {
+		t.Errorf("unexpected down preview %q (err %v)", down, err)
+	}
+}
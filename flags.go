@@ -0,0 +1,75 @@
+package golumn
+
+import (
+	"context"
+	"fmt"
+)
+
+// FlagProvider reports whether feature flags are enabled, so that
+// migrations with RequiresFlag set roll out with the feature they serve.
+type FlagProvider interface {
+	FlagEnabled(ctx context.Context, name string) (bool, error)
+}
+
+// FlagProviderFunc adapts a function to a FlagProvider.
+type FlagProviderFunc func(ctx context.Context, name string) (bool, error)
+
+func (f FlagProviderFunc) FlagEnabled(ctx context.Context, name string) (bool, error) {
+	return f(ctx, name)
+}
+
+// StaticFlags is a FlagProvider enabling the flags mapped to true.
+type StaticFlags map[string]bool
+
+func (f StaticFlags) FlagEnabled(_ context.Context, name string) (bool, error) {
+	return f[name], nil
+}
+
+// checkFlags checks that a FlagProvider is configured if any migration
+// requires a flag.
+func (m *Migrator) checkFlags() error {
+	if m.Flags != nil {
+		return nil
+	}
+	for _, migration := range m.migrations() {
+		if migration.RequiresFlag != "" {
+			return fmt.Errorf("migration %d requires flag %q but no Flags provider is configured", migration.Version, migration.RequiresFlag)
+		}
+	}
+	return nil
+}
+
+// gate removes the migrations whose required flag is disabled from toApply,
+// leaving them unrecorded for a later run. Without a VersionLister a later
+// run would not find a skipped version below the store version, so the
+// migrations above the first skipped one are held back too.
+func (m *Migrator) gate(ctx context.Context, toApply []*Migration) ([]*Migration, error) {
+	_, listable := m.store().(VersionLister)
+	enabled := make(map[string]bool)
+	var gated []*Migration
+	for i, migration := range toApply {
+		flag := migration.RequiresFlag
+		if flag == "" {
+			gated = append(gated, migration)
+			continue
+		}
+		on, ok := enabled[flag]
+		if !ok {
+			var err error
+			if on, err = m.Flags.FlagEnabled(ctx, flag); err != nil {
+				return nil, fmt.Errorf("failed to check flag %q: %w", flag, err)
+			}
+			enabled[flag] = on
+		}
+		if on {
+			gated = append(gated, migration)
+			continue
+		}
+		if !listable {
+			m.logger().Infof("skipping migration %d and %d after it: flag %q is disabled", migration.Version, len(toApply)-i-1, flag)
+			return gated, nil
+		}
+		m.logger().Infof("skipping migration %d: flag %q is disabled", migration.Version, flag)
+	}
+	return gated, nil
+}
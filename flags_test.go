@@ -0,0 +1,139 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigrator_RequiresFlag(t *testing.T) {
+	sources := createMigrations(1, 2, 3)
+	sources[1].RequiresFlag = "new_billing"
+	flags := golumn.StaticFlags{}
+	store := newListingStore()
+	migrator := &golumn.Migrator{Store: store, Sources: sources, Flags: flags}
+
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if !slices.Equal(res.Applied, []int64{1, 3}) || res.Skipped != 1 {
+		t.Errorf("expected the gated migration to be skipped, got %+v", res)
+	}
+	if slices.Contains(store.versions, 2) {
+		t.Errorf("expected version 2 to stay unrecorded, got %v", store.versions)
+	}
+
+	flags["new_billing"] = true
+	res, err = migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if !slices.Equal(res.Applied, []int64{2}) {
+		t.Errorf("expected the gated migration once enabled, got %+v", res)
+	}
+}
+
+func TestMigrator_RequiresFlagWithoutLister(t *testing.T) {
+	sources := createMigrations(1, 2, 3)
+	sources[1].RequiresFlag = "new_billing"
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{Store: store, Sources: sources, Flags: golumn.StaticFlags{}}
+
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if !slices.Equal(res.Applied, []int64{1}) {
+		t.Errorf("expected migrations from the gated one on to be held back, got %+v", res)
+	}
+}
+
+func TestMigrator_RequiresFlagLazy(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"0001_billing.lua":   "Version=1\nRequiresFlag=\"new_billing\"\nfunction Up() end\nfunction Down() end\n",
+		"0002_billing.sql":   "-- +golumn RequiresFlag new_billing\n-- +golumn Up\nSELECT 1;\n",
+		"0003_anonymize.lua": "Version=3\nTags={\"anonymize\"}\nfunction Up() end\nfunction Down() end\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	store := newListingStore()
+	migrator := &golumn.Migrator{
+		Store:  store,
+		Loader: golumn.GlobLoader{Pattern: filepath.Join(dir, "*_billing.*"), Lazy: true},
+		Flags:  golumn.StaticFlags{},
+	}
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if len(res.Applied) != 0 || len(store.versions) != 0 {
+		t.Errorf("expected lazily loaded migrations behind a disabled flag to be skipped, got %+v", res)
+	}
+
+	migrator = &golumn.Migrator{
+		Store:  newListingStore(),
+		Loader: golumn.GlobLoader{Pattern: filepath.Join(dir, "*"), Lazy: true},
+		Flags:  golumn.StaticFlags{},
+	}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err == nil || !strings.Contains(err.Error(), "mixed with") {
+		t.Errorf("expected a lazily loaded anonymization migration to be kept out of the lineage, got %v", err)
+	}
+}
+
+func TestMigrator_RequiresFlagErrors(t *testing.T) {
+	sources := createMigrations(1)
+	sources[0].RequiresFlag = "new_billing"
+
+	migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: sources}
+	if err := migrator.Validate(); err == nil || !strings.Contains(err.Error(), "no Flags provider") {
+		t.Errorf("expected missing provider error, got %v", err)
+	}
+
+	store := &fakeStore{}
+	migrator = &golumn.Migrator{
+		Store:   store,
+		Sources: sources,
+		Flags: golumn.FlagProviderFunc(func(context.Context, string) (bool, error) {
+			return false, errors.New("flag service unavailable")
+		}),
+	}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err == nil || !strings.Contains(err.Error(), "flag service unavailable") {
+		t.Errorf("expected provider error, got %v", err)
+	}
+	if len(store.applied) != 0 {
+		t.Errorf("expected nothing applied, got %v", store.applied)
+	}
+}
+
+func TestRequiresFlag_Sources(t *testing.T) {
+	sqlMigration, err := golumn.ParseSQL(context.Background(), strings.NewReader("-- +golumn RequiresFlag new_billing\n-- +golumn Up\nSELECT 1;\n"), "0002_billing.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	luaMigration, err := golumn.Parse(context.Background(), strings.NewReader("Version=3\nRequiresFlag=\"new_billing\"\nfunction Up() end\nfunction Down() end\n"), "0003_billing.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlMigration.RequiresFlag != "new_billing" || luaMigration.RequiresFlag != "new_billing" {
+		t.Errorf("unexpected flags %q and %q", sqlMigration.RequiresFlag, luaMigration.RequiresFlag)
+	}
+	if got := golumn.Metadata(sqlMigration).RequiresFlag; got != "new_billing" {
+		t.Errorf("expected metadata to carry the flag, got %q", got)
+	}
+
+	if _, err := golumn.Parse(context.Background(), strings.NewReader("Version=3\nRequiresFlag=true\nfunction Up() end\n"), "0003_billing.lua"); err == nil {
+		t.Error("expected error for a non-string RequiresFlag")
+	}
+}
@@ -0,0 +1,230 @@
+package golumn
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/gopher-lua/ast"
+)
+
+// LintStructure is the check reported by CheckLuaStructure.
+const LintStructure = "structure"
+
+// sqlKeywords are the words FormatSQL uppercases.
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, kw := range strings.Fields(`
+		add all alter and as asc autoincrement begin between bigint blob boolean
+		by cascade case check collate column commit constraint create cross
+		default deferrable delete desc distinct drop else end exists foreign
+		from full group having if in index inner insert integer into is join
+		key left like limit not null numeric offset on or order outer primary
+		real references rename replace restrict returning right rollback
+		select set table temporary text then timestamp to transaction trigger
+		union unique update using values varchar view when where with without`) {
+		sqlKeywords[kw] = true
+	}
+}
+
+// FormatSQL returns the .sql migration src in canonical form: directives
+// are unindented and single-spaced, SQL keywords outside strings,
+// dollar-quoted bodies, comments and template actions are uppercased, the
+// last statement of each section ends in a semicolon unless the section
+// changes DELIMITER, trailing whitespace is removed and the file ends in a
+// single newline. Uppercasing also applies to unquoted identifiers that are
+// keywords, which leaves their meaning unchanged. The error is a
+// *SourceError if src does not parse.
+func FormatSQL(src []byte, name string) ([]byte, error) {
+	f, err := parseSQLFile(bytes.NewReader(src), name)
+	if err != nil {
+		return nil, err
+	}
+
+	var out, section strings.Builder
+	flush := func() {
+		out.WriteString(formatSQLSection(section.String(), f.mysql))
+		section.Reset()
+	}
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		if directive, ok := strings.CutPrefix(strings.TrimSpace(line), sqlDirectivePrefix); ok {
+			flush()
+			out.WriteString(sqlDirectivePrefix + strings.Join(strings.Fields(directive), " ") + "\n")
+			continue
+		}
+		section.WriteString(line)
+	}
+	flush()
+
+	lines := strings.Split(strings.TrimRight(out.String(), " \t\r\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
+
+// formatSQLSection uppercases keywords in src and terminates its last
+// statement, scanning it the way splitStatements does.
+func formatSQLSection(src string, mysql bool) string {
+	var b strings.Builder
+	// end is the length of b after the last code byte, and prev that byte.
+	end, prev := 0, byte(0)
+	code := func(chunk string) {
+		b.WriteString(chunk)
+		end, prev = b.Len(), chunk[len(chunk)-1]
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			j := quoteEnd(src, i, mysql)
+			code(src[i:min(j+1, len(src))])
+			i = j
+		case c == '$' && dollarQuoteAt(src, i):
+			j := dollarQuoteEnd(src, i)
+			code(src[i:j])
+			i = j - 1
+		case strings.HasPrefix(src[i:], "--"):
+			j := strings.IndexByte(src[i:], '\n')
+			if j == -1 {
+				j = len(src) - i
+			}
+			b.WriteString(src[i : i+j])
+			i += j - 1
+		case strings.HasPrefix(src[i:], "/*"), strings.HasPrefix(src[i:], "{{"):
+			closing := "*/"
+			if c == '{' {
+				closing = "}}"
+			}
+			j := strings.Index(src[i+2:], closing)
+			if j == -1 {
+				j = len(src) - i - 2
+			} else {
+				j += 2
+			}
+			if c == '{' {
+				code(src[i : i+2+j])
+			} else {
+				b.WriteString(src[i : i+2+j])
+			}
+			i += 1 + j
+		case isWordStart(c):
+			j := i + 1
+			for j < len(src) && isWordChar(src[j]) {
+				j++
+			}
+			word := src[i:j]
+			if prev != '.' && sqlKeywords[strings.ToLower(word)] {
+				word = strings.ToUpper(word)
+			}
+			code(word)
+			i = j - 1
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			b.WriteByte(c)
+		default:
+			code(src[i : i+1])
+		}
+	}
+
+	s := b.String()
+	if end > 0 && prev != ';' && !hasDelimiterLine(src) {
+		s = s[:end] + ";" + s[end:]
+	}
+	return s
+}
+
+// hasDelimiterLine reports whether src changes the statement terminator,
+// in which case formatSQLSection leaves its last statement alone.
+func hasDelimiterLine(src string) bool {
+	for line := range strings.Lines(src) {
+		if _, _, ok := delimiterLine(line); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckLuaStructure reports how the .lua migration src departs from the
+// layout GenScript generates: a literal Version matching the version in
+// name, set before Up and Down are defined as parameterless functions, and
+// no top-level code besides local declarations and the migration globals.
+// The error is a *SourceError if src does not parse.
+func CheckLuaStructure(src []byte, name string) ([]LintIssue, error) {
+	chunk, err := parseLua(bytes.NewReader(src), name)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	report := func(line int, format string, args ...any) {
+		issues = append(issues, LintIssue{File: name, Line: line, Check: LintStructure, Level: LintError, Message: fmt.Sprintf(format, args...)})
+	}
+	defined := map[string]int{}
+	versionLine := 0
+	for _, stmt := range chunk {
+		switch stmt := stmt.(type) {
+		case *ast.LocalAssignStmt:
+		case *ast.AssignStmt:
+			for i, lhs := range stmt.Lhs {
+				ident, ok := lhs.(*ast.IdentExpr)
+				switch {
+				case !ok || (ident.Value != "Version" && ident.Value != "DependsOn" && ident.Value != "Tags"):
+					report(stmt.Line(), "top-level assignment outside of Up and Down")
+				case ident.Value == "Version":
+					versionLine = stmt.Line()
+					if len(defined) > 0 {
+						report(stmt.Line(), "Version set after Up or Down")
+					}
+					var rhs ast.Expr
+					if i < len(stmt.Rhs) {
+						rhs = stmt.Rhs[i]
+					}
+					checkLuaVersion(rhs, name, stmt.Line(), report)
+				}
+			}
+		case *ast.FuncDefStmt:
+			ident, ok := stmt.Name.Func.(*ast.IdentExpr)
+			if !ok || stmt.Name.Receiver != nil || (ident.Value != "Up" && ident.Value != "Down") {
+				report(stmt.Line(), "top-level global function other than Up and Down")
+				continue
+			}
+			if line, ok := defined[ident.Value]; ok {
+				report(stmt.Line(), "%s already defined on line %d", ident.Value, line)
+			}
+			defined[ident.Value] = stmt.Line()
+			if pars := stmt.Func.ParList; len(pars.Names) > 0 || pars.HasVargs {
+				report(stmt.Line(), "%s takes no parameters", ident.Value)
+			}
+		default:
+			report(stmt.Line(), "top-level code outside of Up and Down")
+		}
+	}
+
+	if versionLine == 0 {
+		report(1, "missing Version")
+	}
+	for _, fn := range []string{"Up", "Down"} {
+		if _, ok := defined[fn]; !ok {
+			report(1, "missing %s function", fn)
+		}
+	}
+	return issues, nil
+}
+
+func checkLuaVersion(rhs ast.Expr, name string, line int, report func(int, string, ...any)) {
+	num, ok := rhs.(*ast.NumberExpr)
+	if !ok {
+		report(line, "Version is not a number literal")
+		return
+	}
+	want, err := versionFromName(name)
+	if err != nil {
+		return
+	}
+	if v, err := strconv.ParseInt(num.Value, 10, 64); err != nil || v != want {
+		report(line, "Version %s does not match file name version %d", num.Value, want)
+	}
+}
@@ -0,0 +1,97 @@
+package golumn_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestFormatSQL(t *testing.T) {
+	src := "-- header comment   \n  -- +golumn   Up  \n" +
+		"create table users (id integer primary key, name text not null);\n" +
+		"insert into users (name) values ('select me'), (\"from\"); -- create comment\n" +
+		"/* drop table */ select u.key, u.order from users u\n\n\n" +
+		"-- +golumn Down\n" +
+		"drop table users\n" +
+		"-- trailing\n\n\n"
+	want := "-- header comment\n-- +golumn Up\n" +
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL);\n" +
+		"INSERT INTO users (name) VALUES ('select me'), (\"from\"); -- create comment\n" +
+		"/* drop table */ SELECT u.key, u.order FROM users u;\n\n\n" +
+		"-- +golumn Down\n" +
+		"DROP TABLE users;\n" +
+		"-- trailing\n"
+
+	got, err := golumn.FormatSQL([]byte(src), "0001_users.sql")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	again, err := golumn.FormatSQL(got, "0001_users.sql")
+	if err != nil || string(again) != string(got) {
+		t.Errorf("formatting is not idempotent:\n%s (err %v)", again, err)
+	}
+}
+
+func TestFormatSQL_Template(t *testing.T) {
+	src := "-- +golumn Template\n-- +golumn Up\n{{if env \"SEED\"}}insert into t values (1){{end}}\n"
+	want := "-- +golumn Template\n-- +golumn Up\n{{if env \"SEED\"}}INSERT INTO t VALUES (1){{end}};\n"
+	got, err := golumn.FormatSQL([]byte(src), "0002_seed.sql")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatSQL_Invalid(t *testing.T) {
+	if _, err := golumn.FormatSQL([]byte("select 1;\n"), "0001_x.sql"); err == nil {
+		t.Error("expected error for statement outside of a section")
+	}
+}
+
+func TestCheckLuaStructure(t *testing.T) {
+	generated, err := golumn.GenScript(7, "0007_x.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues, err := golumn.CheckLuaStructure([]byte(generated), "0007_x.lua")
+	if err != nil || len(issues) != 0 {
+		t.Errorf("expected generated script to conform, got %v (err %v)", issues, err)
+	}
+
+	src := `local db = require "db"
+db.exec("SELECT 1")
+function Up(db) end
+Version = 8
+function Up() end
+function helper() end
+Other = 1
+`
+	issues, err = golumn.CheckLuaStructure([]byte(src), "0007_x.lua")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		"0007_x.lua:2: top-level code outside of Up and Down (structure)",
+		"0007_x.lua:3: Up takes no parameters (structure)",
+		"0007_x.lua:4: Version set after Up or Down (structure)",
+		"0007_x.lua:4: Version 8 does not match file name version 7 (structure)",
+		"0007_x.lua:5: Up already defined on line 3 (structure)",
+		"0007_x.lua:6: top-level global function other than Up and Down (structure)",
+		"0007_x.lua:7: top-level assignment outside of Up and Down (structure)",
+		"0007_x.lua:1: missing Down function (structure)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues mismatch\nwant:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -2,9 +2,16 @@ package golumn
 
 import (
 	"bytes"
+	"cmp"
+	"context"
 	"fmt"
+	"go/format"
+	"io/fs"
 	"os"
 	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"text/template"
 	"time"
 )
@@ -84,3 +91,155 @@ func WriteScriptTimestamp(name string, dir string) (version int64, outpath strin
 	}
 	return version, outpath, err
 }
+
+var embedTmpl = template.Must(template.New("embed").Funcs(template.FuncMap{
+	"quote": quoteGoString,
+}).Parse(`// Code generated by golumn gen embed; DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/jonathonwebb/golumn"
+
+var {{.Var}} = []*golumn.Migration{
+{{- range .Migrations}}
+{{- if or .DependsOn .Tags .Owner .Envs .RequiresFlag .NoTransaction .MaxDuration .Checksum}}
+	func() *golumn.Migration {
+		m := {{template "migration" .}}
+{{- if .DependsOn}}
+		m.DependsOn = []int64{ {{- range $i, $v := .DependsOn}}{{if $i}}, {{end}}{{$v}}{{end -}} }
+{{- end}}
+{{- if .Tags}}
+		m.Tags = []string{ {{- range $i, $v := .Tags}}{{if $i}}, {{end}}{{printf "%q" $v}}{{end -}} }
+{{- end}}
+{{- if .Owner}}
+		m.Owner = {{printf "%q" .Owner}}
+{{- end}}
+{{- if .Envs}}
+		m.Envs = []string{ {{- range $i, $v := .Envs}}{{if $i}}, {{end}}{{printf "%q" $v}}{{end -}} }
+{{- end}}
+{{- if .RequiresFlag}}
+		m.RequiresFlag = {{printf "%q" .RequiresFlag}}
+{{- end}}
+{{- if .NoTransaction}}
+		m.NoTransaction = true
+{{- end}}
+{{- if .MaxDuration}}
+		m.MaxDuration = {{.MaxDuration.Nanoseconds}} // {{.MaxDuration}}
+{{- end}}
+{{- if .Checksum}}
+		m.Checksum = {{printf "%q" .Checksum}}
+{{- end}}
+		return m
+	}(),
+{{- else}}
+	{{template "migration" .}},
+{{- end}}
+{{- end}}
+}
+
+{{- define "migration"}}
+{{- if .Lua -}}
+	golumn.LuaMigration({{.Version}}, {{printf "%q" .Name}}, {{quote .Lua}})
+{{- else -}}
+	golumn.SQLMigration({{.Version}}, {{printf "%q" .Name}}, []string{
+{{- range .Up}}
+		{{quote .}},
+{{- end}}
+	}, []string{
+{{- range .Down}}
+		{{quote .}},
+{{- end}}
+	})
+{{- end}}
+{{- end}}
+`))
+
+type embedMigration struct {
+	Version      int64
+	Name         string
+	Lua          string
+	Up, Down     []string
+	DependsOn    []int64
+	Tags         []string
+	Owner        string
+	RequiresFlag string
+	MaxDuration  time.Duration
+	// Envs and NoTransaction are only set for SQL migrations.
+	Envs          []string
+	NoTransaction bool
+	// Checksum is set for SQL migrations, and for Lua migrations whose
+	// checksum differs from the default one LuaMigration computes from the
+	// embedded source.
+	Checksum string
+}
+
+// GenEmbed generates Go source declaring a []*Migration variable named
+// varName in package pkg, compiled from the .lua and .sql files at the top
+// level of fsys. SQL files are split into statements and Lua versions are
+// resolved at generation time, so loading the result does no parsing.
+// Templated files are expanded with the environment of the generator, see
+// Preprocess, and checksums are computed as configured by WithChecksum.
+func GenEmbed(ctx context.Context, fsys fs.FS, pkg string, varName string) ([]byte, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []embedMigration
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := path.Ext(name)
+		if entry.IsDir() || (ext != ".lua" && ext != ".sql") {
+			continue
+		}
+
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if src, err = Preprocess(src, name, nil); err != nil {
+			return nil, err
+		}
+
+		switch ext {
+		case ".lua":
+			m, err := Parse(ctx, bytes.NewReader(src), name)
+			if err != nil {
+				return nil, err
+			}
+			em := embedMigration{Version: m.Version, Name: name, Lua: string(src), DependsOn: m.DependsOn, Tags: m.Tags, Owner: m.Owner, RequiresFlag: m.RequiresFlag, MaxDuration: m.MaxDuration}
+			if m.Checksum != LuaMigration(m.Version, name, string(src)).Checksum {
+				em.Checksum = m.Checksum
+			}
+			migrations = append(migrations, em)
+		case ".sql":
+			f, err := parseSQLFile(bytes.NewReader(src), name)
+			if err != nil {
+				return nil, err
+			}
+			migrations = append(migrations, embedMigration{Version: f.version, Name: name, Up: statementSQL(f.up), Down: statementSQL(f.down), DependsOn: f.dependsOn, Tags: f.tags, Owner: f.owner, Envs: f.envs, RequiresFlag: f.requiresFlag, NoTransaction: f.noTransaction, MaxDuration: f.maxDuration, Checksum: checksumFromContext(ctx).Sum(src)})
+		}
+	}
+
+	slices.SortStableFunc(migrations, func(a, b embedMigration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+
+	var buf bytes.Buffer
+	if err := embedTmpl.Execute(&buf, struct {
+		Package    string
+		Var        string
+		Migrations []embedMigration
+	}{pkg, varName, migrations}); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+func quoteGoString(s string) string {
+	if strconv.CanBackquote(strings.ReplaceAll(s, "\n", "")) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
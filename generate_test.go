@@ -0,0 +1,136 @@
+package golumn_test
+
+import (
+	"context"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestGenEmbed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_users.sql": {Data: []byte("-- +golumn Up\nCREATE TABLE users (id INTEGER);\n-- +golumn Down\nDROP TABLE users;\n")},
+		"0001_init.lua":  {Data: []byte("Version=1\nfunction Up() end\nfunction Down() end\n")},
+		"README.md":      {Data: []byte("ignored")},
+	}
+
+	src, err := golumn.GenEmbed(context.Background(), fsys, "migrations", "All")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "migrations_gen.go", src, 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+
+	out := string(src)
+	for _, want := range []string{
+		"package migrations",
+		"var All = []*golumn.Migration{",
+		`golumn.LuaMigration(1, "0001_init.lua",`,
+		`golumn.SQLMigration(2, "0002_users.sql",`,
+		"`CREATE TABLE users (id INTEGER)`",
+		"`DROP TABLE users`",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected generated source to contain %q\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "0001_init.lua") > strings.Index(out, "0002_users.sql") {
+		t.Error("expected migrations to be ordered by version")
+	}
+	if strings.Contains(out, "README") {
+		t.Error("expected non-migration files to be ignored")
+	}
+}
+
+func TestGenEmbed_DependsOn(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_init.lua":  {Data: []byte("Version=1\nfunction Up() end\nfunction Down() end\n")},
+		"0002_users.sql": {Data: []byte("-- +golumn DependsOn 1\n-- +golumn Up\nSELECT 1;\n-- +golumn Down\nSELECT 1;\n")},
+		"0003_index.lua": {Data: []byte("Version=3\nDependsOn={1, 2}\nfunction Up() end\nfunction Down() end\n")},
+	}
+
+	src, err := golumn.GenEmbed(context.Background(), fsys, "migrations", "All")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "migrations_gen.go", src, 0); err != nil {
+		t.Fatalf("generated source does not parse: %v\n%s", err, src)
+	}
+
+	out := string(src)
+	for _, want := range []string{"m.DependsOn = []int64{1}", "m.DependsOn = []int64{1, 2}"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected generated source to contain %q\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "m.DependsOn") != 2 {
+		t.Errorf("expected only migrations with dependencies to set DependsOn\n%s", out)
+	}
+}
+
+func TestGenEmbed_Metadata(t *testing.T) {
+	sqlSrc := "-- +golumn Tags billing\n-- +golumn Env=prod,staging\n-- +golumn RequiresFlag new_billing\n-- +golumn NoTransaction\n-- +golumn Up\nSELECT 1;\n"
+	fsys := fstest.MapFS{
+		"0001_init.lua":  {Data: []byte("Version=1\nTags={\"core\"}\nfunction Up() end\nfunction Down() end\n")},
+		"0002_users.sql": {Data: []byte(sqlSrc)},
+	}
+
+	src, err := golumn.GenEmbed(context.Background(), fsys, "migrations", "All")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out := string(src)
+	sqlMigration, err := golumn.ParseSQL(context.Background(), strings.NewReader(sqlSrc), "0002_users.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`m.Tags = []string{"core"}`, `m.Tags = []string{"billing"}`, `m.Envs = []string{"prod", "staging"}`, `m.RequiresFlag = "new_billing"`, "m.NoTransaction = true", `m.Checksum = "` + sqlMigration.Checksum + `"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected generated source to contain %q\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "m.Checksum") != 1 {
+		t.Errorf("expected only the SQL migration to set Checksum\n%s", out)
+	}
+}
+
+func TestGenEmbed_InvalidLua(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_init.lua": {Data: []byte("Version=")},
+	}
+	if _, err := golumn.GenEmbed(context.Background(), fsys, "migrations", "All"); err == nil {
+		t.Error("expected error but got none")
+	}
+}
+
+func TestLuaMigration_Lazy(t *testing.T) {
+	m := golumn.LuaMigration(1, "0001_bad.lua", "this is not lua")
+	if m.Version != 1 || m.Name != "0001_bad.lua" {
+		t.Errorf("unexpected migration metadata: %d %q", m.Version, m.Name)
+	}
+	if err := m.Up(context.Background(), nil); err == nil {
+		t.Error("expected compile error on first use")
+	}
+}
+
+func TestGenEmbed_Checksum(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_init.lua":  {Data: []byte("Version=1\nfunction Up() end\nfunction Down() end\n")},
+		"0002_users.sql": {Data: []byte("-- +golumn Up\nSELECT 1;\n")},
+	}
+	ctx := golumn.WithChecksum(context.Background(), golumn.Checksum{Algorithm: golumn.XXH64})
+
+	src, err := golumn.GenEmbed(ctx, fsys, "migrations", "All")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := strings.Count(string(src), `m.Checksum = "xxh64:`); n != 2 {
+		t.Errorf("expected both migrations to set an xxh64 Checksum, got %d\n%s", n, src)
+	}
+}
@@ -4,4 +4,17 @@ go 1.24.2
 
 require github.com/yuin/gopher-lua v1.1.1
 
-require github.com/mattn/go-sqlite3 v1.14.28
+require (
+	github.com/go-sql-driver/mysql v1.8.1
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.28
+	github.com/microsoft/go-mssqldb v1.7.2
+)
+
+require (
+	filippo.io/edwards25519 v1.1.0 // indirect
+	github.com/golang-sql/civil v0.0.0-20220223132316-b832511892a9 // indirect
+	github.com/golang-sql/sqlexp v0.1.0 // indirect
+	golang.org/x/crypto v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
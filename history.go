@@ -0,0 +1,142 @@
+package golumn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// HistorySchema identifies the JSON format written by WriteHistory. Like
+// MetadataSchema, fields may be added within a schema version.
+const HistorySchema = "golumn.history/v1"
+
+// History is a version store's bookkeeping in a form any store can import,
+// e.g. to move an application database to a new server or engine along
+// with its migration history.
+type History struct {
+	Versions []AppliedVersion `json:"versions"`
+	Compats  []Compat         `json:"compats,omitempty"`
+}
+
+// AppliedVersion is an applied version in a History. AppliedAt is zero if
+// the store does not record it.
+type AppliedVersion struct {
+	Version     int64             `json:"version"`
+	AppliedAt   time.Time         `json:"applied_at,omitzero"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// historyDocument is the top-level JSON object of HistorySchema.
+type historyDocument struct {
+	Schema string `json:"schema"`
+	History
+}
+
+// WriteHistory encodes h to w as a HistorySchema document, for
+// implementations of Exporter:
+//
+//	{"schema":"golumn.history/v1","versions":[{"version":1,"applied_at":"2024-07-02T10:00:00Z","annotations":{"ticket":"OPS-1"}}],"compats":[{"app":"api","min_version":1,"max_version":-1,"registered":"…"}]}
+func WriteHistory(w io.Writer, h *History) error {
+	return json.NewEncoder(w).Encode(historyDocument{Schema: HistorySchema, History: *h})
+}
+
+// ReadHistory decodes a document written by WriteHistory, checking that it
+// is of HistorySchema and that its versions are valid and distinct.
+func ReadHistory(r io.Reader) (*History, error) {
+	var doc historyDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid history: %w", err)
+	}
+	if doc.Schema != HistorySchema {
+		return nil, fmt.Errorf("unsupported history schema %q, want %q", doc.Schema, HistorySchema)
+	}
+	seen := map[int64]bool{}
+	for _, v := range doc.Versions {
+		if v.Version < 0 {
+			return nil, fmt.Errorf("invalid history: negative version %d", v.Version)
+		}
+		if seen[v.Version] {
+			return nil, fmt.Errorf("invalid history: duplicate version %d", v.Version)
+		}
+		seen[v.Version] = true
+	}
+	return &doc.History, nil
+}
+
+// Export writes the store's history to w, see Exporter. Like Status it does
+// not take the lock unless SharedReads is set. It fails with
+// errors.ErrUnsupported if the store is not an Exporter.
+func (m *Migrator) Export(ctx context.Context, w io.Writer) (err error) {
+	store := m.store()
+	exporter, ok := store.(Exporter)
+	if !ok {
+		return fmt.Errorf("failed to export version store: %w", errors.ErrUnsupported)
+	}
+	if err := store.Init(ctx); err != nil {
+		return fmt.Errorf("failed to init version store: %w", err)
+	}
+	release, err := m.lockShared(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rlErr := release(); rlErr != nil {
+			err = errors.Join(err, rlErr)
+		}
+	}()
+	if err := exporter.Export(ctx, w); err != nil {
+		return fmt.Errorf("failed to export version store: %w", err)
+	}
+	return nil
+}
+
+// Import replaces the store's history with one read from r, written by
+// Export from this or another store. It holds the store lock while doing
+// so, and fails with errors.ErrUnsupported if the store is not an Exporter.
+func (m *Migrator) Import(ctx context.Context, r io.Reader) (err error) {
+	store := m.store()
+	exporter, ok := store.(Exporter)
+	if !ok {
+		return fmt.Errorf("failed to import version store: %w", errors.ErrUnsupported)
+	}
+	if err := m.runLock.acquire(ctx); err != nil {
+		return err
+	}
+	defer m.runLock.release()
+	defer m.invalidateVersionCache()
+
+	if err := store.Init(ctx); err != nil {
+		return fmt.Errorf("failed to init version store: %w", err)
+	}
+	if err := m.lock(ctx); err != nil {
+		return fmt.Errorf("failed to get version store lock: %w", err)
+	}
+	defer func() {
+		if rlErr := m.release(ctx); rlErr != nil {
+			err = errors.Join(err, rlErr)
+		}
+	}()
+
+	if err := exporter.Import(ctx, r); err != nil {
+		return fmt.Errorf("failed to import version store: %w", err)
+	}
+	m.logger().Infof("imported version store history")
+	return nil
+}
+
+// TransferHistory replaces dst's store history with src's, e.g. to carry the
+// migration history of an application moving from SQLite to another engine
+// along with its data. The stores may be of different engines, as long as
+// both are Exporters. The history is exported in full before dst is
+// touched, so a failed export leaves dst unchanged.
+func TransferHistory(ctx context.Context, dst, src *Migrator) error {
+	var buf bytes.Buffer
+	if err := src.Export(ctx, &buf); err != nil {
+		return err
+	}
+	return dst.Import(ctx, &buf)
+}
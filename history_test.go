@@ -0,0 +1,118 @@
+package golumn_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestHistory_RoundTrip(t *testing.T) {
+	h := &golumn.History{
+		Versions: []golumn.AppliedVersion{
+			{Version: 1, AppliedAt: time.Date(2024, 7, 2, 10, 0, 0, 0, time.UTC), Annotations: map[string]string{"ticket": "OPS-1"}},
+			{Version: 2},
+		},
+		Compats: []golumn.Compat{{App: "api", MinVersion: 1, MaxVersion: golumn.Latest}},
+	}
+	var buf bytes.Buffer
+	if err := golumn.WriteHistory(&buf, h); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), `{"schema":"golumn.history/v1","versions":[{"version":1,"applied_at":"2024-07-02T10:00:00Z"`) {
+		t.Errorf("unexpected encoding %s", buf.String())
+	}
+	got, err := golumn.ReadHistory(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Versions) != 2 || !got.Versions[0].AppliedAt.Equal(h.Versions[0].AppliedAt) ||
+		got.Versions[0].Annotations["ticket"] != "OPS-1" || !got.Versions[1].AppliedAt.IsZero() ||
+		len(got.Compats) != 1 || got.Compats[0] != h.Compats[0] {
+		t.Errorf("got %+v, want %+v", got, h)
+	}
+}
+
+func TestReadHistory_Invalid(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{"syntax", `{`, "invalid history"},
+		{"schema", `{"schema":"golumn.history/v2","versions":[]}`, `unsupported history schema "golumn.history/v2"`},
+		{"negative", `{"schema":"golumn.history/v1","versions":[{"version":-1}]}`, "negative version -1"},
+		{"duplicate", `{"schema":"golumn.history/v1","versions":[{"version":1},{"version":1}]}`, "duplicate version 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := golumn.ReadHistory(strings.NewReader(tt.src))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestMigrator_ExportImport(t *testing.T) {
+	open := func(name string) *golumn.Migrator {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return &golumn.Migrator{Store: sqlite3store.New(db), Sources: createMigrations(1, 2, 3)}
+	}
+	src, dst := open("src.db"), open("dst.db")
+	if _, err := src.Up(context.Background(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := src.Export(context.Background(), &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := dst.Import(context.Background(), &buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, err := dst.Status(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Version != 2 {
+		t.Errorf("got version %d after import, want 2", status.Version)
+	}
+
+	if _, err := src.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := golumn.TransferHistory(context.Background(), dst, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	status, err = dst.Status(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status.Version != 3 {
+		t.Errorf("got version %d after transfer, want 3", status.Version)
+	}
+
+	m := &golumn.Migrator{Store: &fakeStore{}}
+	if err := m.Export(context.Background(), &buf); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if err := m.Import(context.Background(), &buf); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if err := golumn.TransferHistory(context.Background(), dst, m); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if status, err := dst.Status(context.Background()); err != nil || status.Version != 3 {
+		t.Errorf("failed transfer changed the destination: %v (err %v)", status, err)
+	}
+}
@@ -0,0 +1,80 @@
+package golumn
+
+import (
+	"context"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// HookFunc is an application callback that Lua migrations can invoke by name.
+// Arguments arrive as nil, bool, float64 or string; the result must be one of
+// the types a query column can hold, or nil.
+type HookFunc func(ctx context.Context, args ...any) (any, error)
+
+// Hooks maps names to the callbacks exposed to Lua as hooks.call(name, ...).
+type Hooks map[string]HookFunc
+
+type hooksContextKey struct{}
+
+// WithHooks returns a context that exposes hooks to Lua migrations run with
+// it. Hooks are not available while a script is being parsed, so top-level
+// code cannot trigger side effects.
+func WithHooks(ctx context.Context, hooks Hooks) context.Context {
+	return context.WithValue(ctx, hooksContextKey{}, hooks)
+}
+
+func hooksFromContext(ctx context.Context) Hooks {
+	hooks, _ := ctx.Value(hooksContextKey{}).(Hooks)
+	return hooks
+}
+
+func hooksLoaderFunc(hooks Hooks) func(*lua.LState) int {
+	exports := map[string]lua.LGFunction{
+		"call": luaHooksCall(hooks),
+		"has":  luaHooksHas(hooks),
+	}
+
+	return func(l *lua.LState) int {
+		l.Push(l.SetFuncs(l.NewTable(), exports))
+		return 1
+	}
+}
+
+func luaHooksCall(hooks Hooks) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		name := l.CheckString(1)
+		args := checkScalarArgs(l, 2, "hook arg")
+
+		fn, ok := hooks[name]
+		if !ok {
+			l.RaiseError("no hook registered as %q", name)
+			return 0
+		}
+
+		ctx := l.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		ret, err := fn(ctx, args...)
+		if err != nil {
+			l.RaiseError("hook %q: %v", name, err)
+			return 0
+		}
+		lv, ok := toLuaValue(ret)
+		if !ok {
+			l.RaiseError("hook %q: unsupported return type %T", name, ret)
+			return 0
+		}
+		l.Push(lv)
+		return 1
+	}
+}
+
+func luaHooksHas(hooks Hooks) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		_, ok := hooks[l.CheckString(1)]
+		l.Push(lua.LBool(ok))
+		return 1
+	}
+}
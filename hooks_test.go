@@ -0,0 +1,127 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestHooks_Call(t *testing.T) {
+	var got []any
+	ctx := golumn.WithHooks(context.Background(), golumn.Hooks{
+		"invalidate_cache": func(_ context.Context, args ...any) (any, error) {
+			got = args
+			return "ok", nil
+		},
+	})
+
+	db := openLuaTestDB(t, 0)
+	m := mustParse(t, `local db = require "db"
+local hooks = require "hooks"
+Version=1
+function Up()
+    if not hooks.has("invalidate_cache") then error("expected hook") end
+    if hooks.has("missing") then error("unexpected hook") end
+    local ret = hooks.call("invalidate_cache", "items", 42, true, nil)
+    if ret ~= "ok" then error("got " .. tostring(ret)) end
+end
+`)
+	if err := m.Up(ctx, db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+
+	want := []any{"items", float64(42), true, nil}
+	if len(got) != len(want) {
+		t.Fatalf("expected args %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("arg %d: expected %v, got %v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestHooks_Errors(t *testing.T) {
+	hooks := golumn.Hooks{
+		"fail": func(context.Context, ...any) (any, error) {
+			return nil, errors.New("boom")
+		},
+		"bad_return": func(context.Context, ...any) (any, error) {
+			return struct{}{}, nil
+		},
+	}
+
+	tests := map[string]struct {
+		ctx  context.Context
+		call string
+		want string
+	}{
+		"unregistered":      {golumn.WithHooks(context.Background(), hooks), `hooks.call("reindex")`, `no hook registered as "reindex"`},
+		"no hooks":          {context.Background(), `hooks.call("fail")`, `no hook registered as "fail"`},
+		"hook error":        {golumn.WithHooks(context.Background(), hooks), `hooks.call("fail")`, `hook "fail": boom`},
+		"unsupported arg":   {golumn.WithHooks(context.Background(), hooks), `hooks.call("fail", {})`, "Unsupported type for hook arg"},
+		"unsupported value": {golumn.WithHooks(context.Background(), hooks), `hooks.call("bad_return")`, "unsupported return type"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db := openLuaTestDB(t, 0)
+			m := mustParse(t, "local hooks = require \"hooks\"\nVersion=1\nfunction Up()\n"+tt.call+"\nend\n")
+			err := m.Up(tt.ctx, db)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestHooks_UnavailableWhileParsing(t *testing.T) {
+	called := false
+	ctx := golumn.WithHooks(context.Background(), golumn.Hooks{
+		"side_effect": func(context.Context, ...any) (any, error) {
+			called = true
+			return nil, nil
+		},
+	})
+
+	_, err := golumn.Parse(ctx, strings.NewReader(`local hooks = require "hooks"
+hooks.call("side_effect")
+Version=1
+`), "test.lua")
+	if err == nil {
+		t.Fatal("expected parse error")
+	}
+	if called {
+		t.Error("hook ran while parsing")
+	}
+}
+
+func TestMigrator_Hooks(t *testing.T) {
+	var calls []string
+	record := func(_ context.Context, args ...any) (any, error) {
+		calls = append(calls, args[0].(string))
+		return nil, nil
+	}
+
+	m := &golumn.Migrator{
+		Store: &fakeStore{},
+		Sources: []*golumn.Migration{golumn.LuaMigration(1, "1_hooks.lua", `local hooks = require "hooks"
+Version=1
+function Up() hooks.call("record", "up") end
+function Down() hooks.call("record", "down") end
+`)},
+		Hooks: golumn.Hooks{"record": record},
+	}
+
+	if _, err := m.Up(context.Background(), 1); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if _, err := m.Down(context.Background(), golumn.DownTargetInitial); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "up,down" {
+		t.Errorf("expected hook calls up,down, got %s", got)
+	}
+}
@@ -0,0 +1,68 @@
+package golumn
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+var ErrIdentityMismatch = errors.New("store identity mismatch")
+
+// IdentityStore is implemented by stores that record an identity for their
+// database: a random UUID generated the first time Identity is called after
+// Init and returned unchanged after that. Copying the database copies its
+// identity. See Migrator.ExpectedIdentity.
+type IdentityStore interface {
+	Identity(context.Context) (string, error)
+}
+
+// NewIdentity returns a random version 4 UUID, for IdentityStore
+// implementations.
+func NewIdentity() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// Identity returns the identity of the store's database, generating it if
+// the database has none yet, e.g. to configure ExpectedIdentity. Like
+// Status it does not take the lock. It fails with errors.ErrUnsupported if
+// the store is not an IdentityStore.
+func (m *Migrator) Identity(ctx context.Context) (string, error) {
+	store := m.store()
+	is, ok := store.(IdentityStore)
+	if !ok {
+		return "", fmt.Errorf("failed to get store identity: %w", errors.ErrUnsupported)
+	}
+	if err := store.Init(ctx); err != nil {
+		return "", fmt.Errorf("failed to init version store: %w", err)
+	}
+	id, err := is.Identity(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get store identity: %w", err)
+	}
+	return id, nil
+}
+
+// checkIdentity fails unless the initialized store reports
+// ExpectedIdentity, if one is set.
+func (m *Migrator) checkIdentity(ctx context.Context) error {
+	if m.ExpectedIdentity == "" {
+		return nil
+	}
+	is, ok := m.store().(IdentityStore)
+	if !ok {
+		return fmt.Errorf("ExpectedIdentity is set but the store has no identity: %w", errors.ErrUnsupported)
+	}
+	id, err := is.Identity(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get store identity: %w", err)
+	}
+	if id != m.ExpectedIdentity {
+		return fmt.Errorf("%w: store is %s, expected %s", ErrIdentityMismatch, id, m.ExpectedIdentity)
+	}
+	return nil
+}
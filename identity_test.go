@@ -0,0 +1,65 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestNewIdentity(t *testing.T) {
+	id := golumn.NewIdentity()
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("expected a version 4 UUID, got %q", id)
+	}
+	if golumn.NewIdentity() == id {
+		t.Error("expected identities to differ")
+	}
+}
+
+func TestMigrator_ExpectedIdentity(t *testing.T) {
+	open := func() *sql.DB {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	prod, staging := open(), open()
+	ctx := context.Background()
+
+	id, err := (&golumn.Migrator{Store: sqlite3store.New(prod)}).Identity(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	migrator := &golumn.Migrator{Store: sqlite3store.New(staging), Sources: createMigrations(1, 2), ExpectedIdentity: id}
+	if _, err := migrator.Up(ctx, golumn.Latest); !errors.Is(err, golumn.ErrIdentityMismatch) {
+		t.Fatalf("expected ErrIdentityMismatch against the wrong database, got %v", err)
+	}
+	if _, err := sqlite3store.New(staging).Version(ctx); !errors.Is(err, golumn.ErrInitialVersion) {
+		t.Errorf("expected nothing applied to the wrong database, got %v", err)
+	}
+
+	migrator.Store = sqlite3store.New(prod)
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := migrator.Down(ctx, golumn.Initial); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	migrator = &golumn.Migrator{Store: newListingStore(), Sources: createMigrations(1), ExpectedIdentity: id}
+	if _, err := migrator.Up(ctx, golumn.Latest); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported from a store without identity, got %v", err)
+	}
+	if _, err := migrator.Identity(ctx); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported from Identity, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package golumn
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Impact lists the tables and indexes a migration's statements touch, for
+// reviewers and tooling judging the blast radius of a run. Names are as
+// written in the statements, quotes and schema included.
+type Impact struct {
+	Tables  []string `json:"tables,omitempty"`
+	Indexes []string `json:"indexes,omitempty"`
+}
+
+// Impact returns what the migration's statements in direction dir touch:
+// the tables they create, alter, drop, rename or write, and the indexes
+// they create or drop. Only SQL migrations have statements to inspect;
+// Lua and Go migrations have no impact. A lazily loaded migration is
+// loaded to read its statements.
+func (m *Migration) Impact(ctx context.Context, dir Direction) (Impact, error) {
+	stmts, err := m.statements(ctx, dir)
+	if err != nil {
+		return Impact{}, err
+	}
+	return statementsImpact(stmts), nil
+}
+
+// Lines renders the impact with messages from c, a line for tables and one
+// for indexes, omitting those with no names. A nil Impact has no lines.
+func (i *Impact) Lines(c Catalog) []string {
+	if i == nil {
+		return nil
+	}
+	var lines []string
+	if len(i.Tables) > 0 {
+		lines = append(lines, c.Sprintf(MsgImpactTables, strings.Join(i.Tables, ", ")))
+	}
+	if len(i.Indexes) > 0 {
+		lines = append(lines, c.Sprintf(MsgImpactIndexes, strings.Join(i.Indexes, ", ")))
+	}
+	return lines
+}
+
+// knownImpact is Impact without loading a lazily loaded migration, or nil
+// if the migration touches nothing known.
+func (m *Migration) knownImpact(dir Direction) *Impact {
+	stmts := m.up
+	if dir == DirectionDown {
+		stmts = m.down
+	}
+	impact := statementsImpact(stmts)
+	if len(impact.Tables) == 0 && len(impact.Indexes) == 0 {
+		return nil
+	}
+	return &impact
+}
+
+func statementsImpact(stmts []sqlStatement) Impact {
+	var impact Impact
+	add := func(list *[]string, name string) {
+		if name != "" && !slices.ContainsFunc(*list, func(s string) bool { return sameTable(s, name) }) {
+			*list = append(*list, name)
+		}
+	}
+	for _, stmt := range stmts {
+		c, ok := parseTableChange(stmt.sql)
+		if !ok {
+			continue
+		}
+		add(&impact.Tables, c.table)
+		add(&impact.Tables, c.renamed)
+		add(&impact.Indexes, c.index)
+	}
+	return impact
+}
+
+// tableChange is what a statement does to a table, as found by
+// parseTableChange.
+type tableChange struct {
+	// table is the table changed, or empty for a DROP INDEX that does not
+	// name it.
+	table string
+	// renamed is the table's new name if it was renamed.
+	renamed string
+	// index is the index created or dropped, if any; dropped then refers
+	// to the index rather than the table.
+	index   string
+	dropped bool
+}
+
+// sqlIdent matches a possibly schema-qualified identifier, each part bare
+// or quoted in the style of any supported dialect.
+const sqlIdent = "(?:\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\]|[\\w$]+)(?:\\.(?:\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\]|[\\w$]+))*"
+
+var (
+	// writesTable matches the statements that change a table's rows or
+	// shape, capturing the table.
+	writesTable = regexp.MustCompile(`(?is)^\s*(?:` +
+		`CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?|` +
+		`ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?|` +
+		`(?:INSERT|REPLACE)\s+(?:OR\s+\w+\s+)?(?:IGNORE\s+)?INTO\s+|` +
+		`UPDATE\s+(?:ONLY\s+)?|` +
+		`DELETE\s+FROM\s+(?:ONLY\s+)?|` +
+		`TRUNCATE\s+(?:TABLE\s+)?(?:ONLY\s+)?` +
+		`)(` + sqlIdent + `)`)
+	// createsIndex matches CREATE INDEX, capturing the index, if named,
+	// and its table.
+	createsIndex = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:(` + sqlIdent + `)\s+)?ON\s+(?:ONLY\s+)?(` + sqlIdent + `)`)
+	// dropsIndex matches DROP INDEX, capturing the index and, as MySQL
+	// requires, its table.
+	dropsIndex = regexp.MustCompile(`(?is)^\s*DROP\s+INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?(` + sqlIdent + `)(?:\s+ON\s+(` + sqlIdent + `))?`)
+	// dropsTable matches DROP TABLE, capturing the first table dropped.
+	dropsTable = regexp.MustCompile(`(?is)^\s*DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(` + sqlIdent + `)`)
+	// renamesTable matches ALTER TABLE ... RENAME TO, capturing both names.
+	renamesTable = regexp.MustCompile(`(?is)^\s*ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?(` + sqlIdent + `)\s+RENAME\s+TO\s+(` + sqlIdent + `)`)
+)
+
+// parseTableChange reports what query does to a table, if it is one of the
+// statements that change tables or indexes.
+func parseTableChange(query string) (tableChange, bool) {
+	if m := renamesTable.FindStringSubmatch(query); m != nil {
+		return tableChange{table: m[1], renamed: m[2]}, true
+	}
+	if m := dropsTable.FindStringSubmatch(query); m != nil {
+		return tableChange{table: m[1], dropped: true}, true
+	}
+	if m := createsIndex.FindStringSubmatch(query); m != nil {
+		return tableChange{table: m[2], index: m[1]}, true
+	}
+	if m := dropsIndex.FindStringSubmatch(query); m != nil {
+		return tableChange{table: m[2], index: m[1], dropped: true}, true
+	}
+	if m := writesTable.FindStringSubmatch(query); m != nil {
+		return tableChange{table: m[1]}, true
+	}
+	return tableChange{}, false
+}
+
+// identQuotes strips the quotes of identifiers.
+var identQuotes = strings.NewReplacer(`"`, "", "`", "", "[", "", "]", "")
+
+// sameTable reports whether identifiers a and b name the same table or
+// index, as unquoted identifiers do regardless of case. Quoted identifiers
+// differing only in case are taken to be the same too, which at worst
+// merges two names in a report or refreshes one table's statistics twice.
+func sameTable(a, b string) bool {
+	return strings.EqualFold(identQuotes.Replace(a), identQuotes.Replace(b))
+}
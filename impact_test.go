@@ -0,0 +1,83 @@
+package golumn_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigration_Impact(t *testing.T) {
+	src := `-- +golumn Up
+CREATE TABLE IF NOT EXISTS "billing"."invoices" (id INTEGER PRIMARY KEY);
+CREATE UNIQUE INDEX CONCURRENTLY invoices_number ON billing.invoices (number);
+ALTER TABLE accounts ADD COLUMN plan TEXT;
+INSERT INTO accounts (id) VALUES (1);
+UPDATE ACCOUNTS SET plan = 'free';
+ALTER TABLE legacy RENAME TO legacy_old;
+DROP INDEX IF EXISTS accounts_plan;
+SELECT * FROM audit;
+-- +golumn Down
+DROP TABLE "billing"."invoices";
+`
+	m, err := golumn.ParseSQL(context.Background(), strings.NewReader(src), "0001_billing.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up, err := m.Impact(context.Background(), golumn.DirectionUp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := golumn.Impact{
+		Tables:  []string{`"billing"."invoices"`, "accounts", "legacy", "legacy_old"},
+		Indexes: []string{"invoices_number", "accounts_plan"},
+	}
+	if !reflect.DeepEqual(up, want) {
+		t.Errorf("up impact mismatch\nwant: %+v\ngot:  %+v", want, up)
+	}
+
+	down, err := m.Impact(context.Background(), golumn.DirectionDown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (golumn.Impact{Tables: []string{`"billing"."invoices"`}}); !reflect.DeepEqual(down, want) {
+		t.Errorf("down impact mismatch\nwant: %+v\ngot:  %+v", want, down)
+	}
+
+	lua, err := mustParse(t, "Version=2\nfunction Up() end\n").Impact(context.Background(), golumn.DirectionUp)
+	if err != nil || lua.Tables != nil || lua.Indexes != nil {
+		t.Errorf("expected no impact for a Lua migration, got %+v, %v", lua, err)
+	}
+}
+
+func TestMigrator_PlanImpact(t *testing.T) {
+	sources := []*golumn.Migration{
+		golumn.SQLMigration(1, "0001_users.sql", []string{"CREATE TABLE users (id INTEGER)", "CREATE INDEX users_id ON users (id)"}, []string{"DROP TABLE users"}),
+		mustParse(t, "Version=2\nfunction Up() end\nfunction Down() end\n"),
+	}
+	migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: sources}
+
+	plan, err := migrator.Plan(context.Background(), golumn.DirectionUp, golumn.Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &golumn.Impact{Tables: []string{"users"}, Indexes: []string{"users_id"}}
+	if !reflect.DeepEqual(plan.Steps[0].Impact, want) || plan.Steps[1].Impact != nil {
+		t.Errorf("unexpected step impacts: %+v, %+v", plan.Steps[0].Impact, plan.Steps[1].Impact)
+	}
+	wantText := "up from -1 to 2: 2 steps\n  1 0001_users.sql\n    tables: users\n    indexes: users_id\n  2"
+	if got := plan.String(); !strings.HasPrefix(got, wantText) {
+		t.Errorf("plan output mismatch\nwant prefix:\n%s\ngot:\n%s", wantText, got)
+	}
+
+	st, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(st.Impact, map[int64]*golumn.Impact{1: want}) {
+		t.Errorf("unexpected status impact: %+v", st.Impact)
+	}
+}
@@ -0,0 +1,118 @@
+package golumn
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ownerTagPrefix marks a tag naming a migration's owner, e.g. "owner:billing".
+const ownerTagPrefix = "owner:"
+
+// InventorySource is one migration set in an inventory, typically one
+// service's repository. Exactly one of Loader and Manifest must be set.
+type InventorySource struct {
+	// Service names the migration set.
+	Service string
+	// Owner is the default owner of the set's migrations. A migration's
+	// "owner:NAME" tag overrides it.
+	Owner string
+
+	Loader Loader
+	// Manifest is the path of a MetadataSchema document, e.g. written by
+	// "golumn versions -json" in the service's CI.
+	Manifest string
+}
+
+// InventoryEntry is a migration in an inventory.
+type InventoryEntry struct {
+	Service string `json:"service"`
+	Owner   string `json:"owner,omitempty"`
+	MigrationMetadata
+}
+
+// Inventory is a consolidated list of migrations across many sources.
+type Inventory struct {
+	// Entries are sorted by service, then version.
+	Entries []InventoryEntry `json:"entries"`
+}
+
+// BuildInventory loads every source and consolidates their migrations.
+// Sources that fail to load are left out, and their errors joined in the
+// returned error, so one broken repository does not hide the rest.
+func BuildInventory(ctx context.Context, sources []InventorySource) (*Inventory, error) {
+	inv := &Inventory{}
+	var errs []error
+	for _, src := range sources {
+		metadata, err := src.metadata(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("service %s: %w", src.Service, err))
+			continue
+		}
+		for _, md := range metadata {
+			inv.Entries = append(inv.Entries, InventoryEntry{Service: src.Service, Owner: ownerOf(md.Tags, src.Owner), MigrationMetadata: md})
+		}
+	}
+	slices.SortStableFunc(inv.Entries, func(a, b InventoryEntry) int {
+		return cmp.Or(cmp.Compare(a.Service, b.Service), cmp.Compare(a.Version, b.Version))
+	})
+	return inv, errors.Join(errs...)
+}
+
+func (src InventorySource) metadata(ctx context.Context) ([]MigrationMetadata, error) {
+	switch {
+	case src.Loader != nil && src.Manifest != "":
+		return nil, errors.New("Loader and Manifest are mutually exclusive")
+	case src.Loader != nil:
+		migrations, err := src.Loader.Load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		metadata := make([]MigrationMetadata, len(migrations))
+		for i, m := range migrations {
+			metadata[i] = Metadata(m)
+		}
+		return metadata, nil
+	case src.Manifest != "":
+		data, err := os.ReadFile(src.Manifest)
+		if err != nil {
+			return nil, err
+		}
+		return UnmarshalMetadata(data)
+	}
+	return nil, errors.New("no Loader or Manifest")
+}
+
+func ownerOf(tags []string, fallback string) string {
+	for _, tag := range tags {
+		if owner, ok := strings.CutPrefix(tag, ownerTagPrefix); ok && owner != "" {
+			return owner
+		}
+	}
+	return fallback
+}
+
+// Owners returns the inventory's owners with the number of migrations each
+// owns. Unowned migrations are counted under "".
+func (inv *Inventory) Owners() map[string]int {
+	owners := map[string]int{}
+	for _, e := range inv.Entries {
+		owners[e.Owner]++
+	}
+	return owners
+}
+
+// Tagged returns the entries carrying tag.
+func (inv *Inventory) Tagged(tag string) []InventoryEntry {
+	var entries []InventoryEntry
+	for _, e := range inv.Entries {
+		if slices.Contains(e.Tags, tag) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,58 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestBuildInventory(t *testing.T) {
+	billing := createMigrations(2, 1)
+	billing[0].Tags = []string{"owner:payments", "slow"}
+	orders := createMigrations(5)
+	orders[0].Tags = []string{"slow"}
+	data, err := golumn.MarshalMetadata(orders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(t.TempDir(), "orders.json")
+	if err := os.WriteFile(manifest, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loadErr := errors.New("boom")
+	inv, err := golumn.BuildInventory(context.Background(), []golumn.InventorySource{
+		{Service: "orders", Owner: "commerce", Manifest: manifest},
+		{Service: "billing", Owner: "finance", Loader: &countingLoader{migrations: billing}},
+		{Service: "broken", Loader: &countingLoader{err: loadErr}},
+		{Service: "empty"},
+	})
+	if !errors.Is(err, loadErr) || !strings.Contains(err.Error(), "service broken") || !strings.Contains(err.Error(), "service empty") {
+		t.Errorf("expected errors for broken and empty sources, got %v", err)
+	}
+
+	var got []string
+	for _, e := range inv.Entries {
+		got = append(got, e.Service+"/"+e.Owner)
+	}
+	want := []string{"billing/finance", "billing/payments", "orders/commerce"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	if inv.Entries[1].Version != 2 || inv.Entries[2].Version != 5 {
+		t.Errorf("unexpected versions: %+v", inv.Entries)
+	}
+
+	owners := inv.Owners()
+	if len(owners) != 3 || owners["payments"] != 1 || owners["commerce"] != 1 {
+		t.Errorf("unexpected owners: %v", owners)
+	}
+	if slow := inv.Tagged("slow"); len(slow) != 2 || slow[0].Service != "billing" || slow[1].Service != "orders" {
+		t.Errorf("unexpected tagged entries: %+v", slow)
+	}
+}
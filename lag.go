@@ -0,0 +1,46 @@
+package golumn
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultLagPollInterval = 5 * time.Second
+
+// LagProbe reports how far replicas are behind the database being migrated.
+type LagProbe interface {
+	Lag(context.Context) (time.Duration, error)
+}
+
+// awaitLag blocks while LagProbe reports more than MaxLag, polling every
+// LagPollInterval.
+func (m *Migrator) awaitLag(ctx context.Context) error {
+	if m.LagProbe == nil {
+		return nil
+	}
+	interval := m.LagPollInterval
+	if interval <= 0 {
+		interval = defaultLagPollInterval
+	}
+
+	for paused := false; ; paused = true {
+		lag, err := m.LagProbe.Lag(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to probe replication lag: %w", err)
+		}
+		if lag <= m.MaxLag {
+			if paused {
+				m.logger().Infof("replication lag %s within %s, resuming", lag, m.MaxLag)
+			}
+			return nil
+		}
+
+		m.logger().Infof("replication lag %s exceeds %s, pausing for %s", lag, m.MaxLag, interval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type fakeLagProbe struct {
+	lags  []time.Duration
+	calls int
+	err   error
+}
+
+func (p *fakeLagProbe) Lag(context.Context) (time.Duration, error) {
+	p.calls++
+	if p.err != nil {
+		return 0, p.err
+	}
+	lag := p.lags[0]
+	if len(p.lags) > 1 {
+		p.lags = p.lags[1:]
+	}
+	return lag, nil
+}
+
+func TestMigrator_LagProbe(t *testing.T) {
+	probe := &fakeLagProbe{lags: []time.Duration{0, 10 * time.Second, 8 * time.Second, time.Second}}
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{
+		Store:           store,
+		Sources:         createMigrations(1, 2),
+		LagProbe:        probe,
+		MaxLag:          2 * time.Second,
+		LagPollInterval: time.Millisecond,
+	}
+
+	if _, err := migrator.Up(context.Background(), 2); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	// One probe before the first migration, three before the second while
+	// lag drops back under the threshold.
+	if probe.calls != 4 {
+		t.Errorf("expected 4 probes, got %d", probe.calls)
+	}
+	if len(store.applied) != 2 {
+		t.Errorf("expected both migrations applied, got %v", store.applied)
+	}
+}
+
+func TestMigrator_LagProbeError(t *testing.T) {
+	want := errors.New("replica unreachable")
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1), LagProbe: &fakeLagProbe{err: want}}
+
+	if _, err := migrator.Up(context.Background(), 1); !errors.Is(err, want) {
+		t.Fatalf("expected probe error, got %v", err)
+	}
+	if len(store.applied) != 0 {
+		t.Errorf("expected nothing applied, got %v", store.applied)
+	}
+}
+
+func TestMigrator_LagProbeCanceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	migrator := &golumn.Migrator{
+		Store:           &fakeStore{},
+		Sources:         createMigrations(1),
+		LagProbe:        &fakeLagProbe{lags: []time.Duration{time.Minute}},
+		MaxLag:          time.Second,
+		LagPollInterval: time.Millisecond,
+	}
+	if _, err := migrator.Up(ctx, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package golumn
+
+import (
+	"context"
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// LuaLimits bounds what a single call of a Lua migration may load into
+// memory at once, db.query_all and cursor:fetch, so that a migration
+// naively loading a huge table fails with an error instead of having the
+// process killed for running out of memory. Zero fields are unlimited.
+type LuaLimits struct {
+	// MaxRows is the most rows one call may return.
+	MaxRows int
+	// MaxBytes is the most bytes of column data the rows of one call may
+	// hold, counting strings by their length and other values as 8 bytes.
+	MaxBytes int64
+}
+
+// DefaultLuaLimits are the limits of Lua migrations run without
+// WithLuaLimits.
+var DefaultLuaLimits = LuaLimits{MaxRows: 100_000, MaxBytes: 256 << 20}
+
+type luaLimitsContextKey struct{}
+
+// WithLuaLimits returns a context that applies limits to Lua migrations run
+// with it in place of DefaultLuaLimits.
+func WithLuaLimits(ctx context.Context, limits LuaLimits) context.Context {
+	return context.WithValue(ctx, luaLimitsContextKey{}, limits)
+}
+
+func luaLimitsFromContext(ctx context.Context) LuaLimits {
+	if limits, ok := ctx.Value(luaLimitsContextKey{}).(LuaLimits); ok {
+		return limits
+	}
+	return DefaultLuaLimits
+}
+
+// fetchRows reads up to n rows from c into an array, or all of them if n
+// is negative, failing once the rows exceed the session's limits. advice
+// completes the error message with what the script should do instead.
+func (s *luaSession) fetchRows(l *lua.LState, c *luaCursor, n int, advice string) (*lua.LTable, error) {
+	batch := l.NewTable()
+	var size int64
+	for i := 0; n < 0 || i < n; i++ {
+		row, err := c.next(l)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			break
+		}
+		if s.limits.MaxRows > 0 && i == s.limits.MaxRows {
+			return nil, fmt.Errorf("result exceeds %d rows; %s", s.limits.MaxRows, advice)
+		}
+		size += luaRowSize(row)
+		if s.limits.MaxBytes > 0 && size > s.limits.MaxBytes {
+			return nil, fmt.Errorf("result exceeds %d bytes; %s", s.limits.MaxBytes, advice)
+		}
+		batch.Append(row)
+	}
+	return batch, nil
+}
+
+// luaRowSize approximates the memory held by the column values of row.
+func luaRowSize(row *lua.LTable) int64 {
+	var size int64
+	row.ForEach(func(_, v lua.LValue) {
+		if s, ok := v.(lua.LString); ok {
+			size += int64(len(s))
+		} else {
+			size += 8
+		}
+	})
+	return size
+}
+
+// queryAll runs a query and returns all its rows as an array, for
+// db.query_all and transaction:query_all.
+func queryAll(l *lua.LState, sess *luaSession, q queryer, start int) int {
+	c := openLuaCursor(l, sess, q, start)
+	rows, err := sess.fetchRows(l, c, -1, "use the iterator of query or cursor")
+	if closeErr := c.close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		l.RaiseError("query_all: %v", err)
+		return 0
+	}
+	l.Push(rows)
+	return 1
+}
+
+func luaQueryAllFunc(sess *luaSession) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		return queryAll(l, sess, sess.db, 1)
+	}
+}
+
+func luaTransactionQueryAll(l *lua.LState) int {
+	tx := checkOpenTransaction(l, "query_all")
+	return queryAll(l, tx.sess, tx.tx, 2)
+}
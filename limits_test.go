@@ -0,0 +1,105 @@
+package golumn_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestLuaQueryAll(t *testing.T) {
+	db := openLuaTestDB(t, 5)
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    local rows = db.query_all("SELECT id FROM items WHERE id > ? ORDER BY id", 2)
+    if #rows ~= 3 or rows[1].id ~= 3 then error("unexpected rows") end
+    local tx = db.begin()
+    if #tx:query_all("SELECT id FROM items") ~= 5 then error("unexpected transaction rows") end
+    tx:commit()
+end
+`)
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("expected no connections in use, got %d", inUse)
+	}
+}
+
+func TestLuaLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits golumn.LuaLimits
+		body   string
+		want   string
+	}{
+		{
+			name:   "query_all rows",
+			limits: golumn.LuaLimits{MaxRows: 4},
+			body:   `db.query_all("SELECT * FROM items")`,
+			want:   "query_all: result exceeds 4 rows; use the iterator of query or cursor",
+		},
+		{
+			name:   "query_all bytes",
+			limits: golumn.LuaLimits{MaxBytes: 100},
+			body:   `db.query_all("SELECT * FROM items")`,
+			want:   "query_all: result exceeds 100 bytes; use the iterator of query or cursor",
+		},
+		{
+			name:   "fetch size",
+			limits: golumn.LuaLimits{MaxRows: 2},
+			body:   `db.cursor("SELECT * FROM items"):fetch(3)`,
+			want:   "fetch size 3 exceeds the limit of 2 rows",
+		},
+		{
+			name:   "fetch bytes",
+			limits: golumn.LuaLimits{MaxBytes: 50},
+			body:   `db.cursor("SELECT * FROM items"):fetch(2)`,
+			want:   "result exceeds 50 bytes; fetch smaller batches",
+		},
+		{
+			name:   "within limits",
+			limits: golumn.LuaLimits{MaxRows: 5, MaxBytes: 1000},
+			body:   `db.query_all("SELECT * FROM items")`,
+		},
+		{
+			name: "unlimited",
+			body: `db.cursor("SELECT * FROM items"):fetch(1000000)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openLuaTestDB(t, 5)
+			m := mustParse(t, "local db = require \"db\"\nVersion=1\nfunction Up()\n"+tt.body+"\nend\n")
+			err := m.Up(golumn.WithLuaLimits(context.Background(), tt.limits), db)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+			if inUse := db.Stats().InUse; inUse != 0 {
+				t.Errorf("expected no connections in use, got %d", inUse)
+			}
+		})
+	}
+}
+
+func TestMigrator_LuaLimits(t *testing.T) {
+	db := openLuaTestDB(t, 3)
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up() db.query_all("SELECT * FROM items") end
+function Down() end
+`)
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: []*golumn.Migration{m}, LuaLimits: &golumn.LuaLimits{MaxRows: 2}}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err == nil || !strings.Contains(err.Error(), "exceeds 2 rows") {
+		t.Errorf("expected the Migrator's limits to apply, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package golumn
+
+import "fmt"
+
+// CheckLineage returns an error unless lineage is empty or an identifier of
+// ASCII letters, digits and underscores not starting with a digit, which
+// stores can safely build into table and lock names.
+func CheckLineage(lineage string) error {
+	for i, r := range lineage {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return fmt.Errorf("invalid lineage %q", lineage)
+		}
+	}
+	return nil
+}
+
+// LineageTable names a store's table for lineage. A store with a Lineage
+// suffixes its tables with an underscore and the lineage, e.g.
+// schema_migrations_anonymize, so that migration sets sharing a database,
+// like TagAnonymize migrations, keep separate histories and locks. An empty
+// lineage leaves table as is. Stores check the lineage with CheckLineage
+// before using it.
+func LineageTable(table, lineage string) string {
+	if lineage == "" {
+		return table
+	}
+	return table + "_" + lineage
+}
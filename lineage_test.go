@@ -0,0 +1,29 @@
+package golumn_test
+
+import (
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestCheckLineage(t *testing.T) {
+	for _, lineage := range []string{"", "anonymize", "_x", "Set2"} {
+		if err := golumn.CheckLineage(lineage); err != nil {
+			t.Errorf("expected %q to be valid, got %v", lineage, err)
+		}
+	}
+	for _, lineage := range []string{"2x", "a-b", "a b", "x;DROP", "é"} {
+		if err := golumn.CheckLineage(lineage); err == nil {
+			t.Errorf("expected %q to be invalid", lineage)
+		}
+	}
+}
+
+func TestLineageTable(t *testing.T) {
+	if got := golumn.LineageTable("schema_migrations", ""); got != "schema_migrations" {
+		t.Errorf("expected the table unchanged without a lineage, got %q", got)
+	}
+	if got := golumn.LineageTable("schema_migrations", "anonymize"); got != "schema_migrations_anonymize" {
+		t.Errorf("expected a suffixed table, got %q", got)
+	}
+}
@@ -0,0 +1,293 @@
+package golumn
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/yuin/gopher-lua/ast"
+)
+
+// LintLevel is how a lint check's findings are reported.
+type LintLevel int
+
+const (
+	// LintOff disables a check.
+	LintOff LintLevel = iota
+	// LintWarn logs findings and loads the migration anyway.
+	LintWarn
+	// LintError fails the load.
+	LintError
+)
+
+// Lint checks, as reported in LintIssue.Check.
+const (
+	LintUndefinedGlobal = "undefined-global"
+	LintUnusedLocal     = "unused-local"
+	LintOSIO            = "os-io"
+)
+
+// LintConfig selects the lint checks run on Lua migrations by LintLua and
+// GlobLoader.Lint.
+type LintConfig struct {
+	// UndefinedGlobals reports reads of and assignments to globals other
+	// than the migration globals (Version, Up, Down, DependsOn and Tags),
+	// Lua's standard library and Globals, e.g. "function Dwon()".
+	UndefinedGlobals LintLevel
+	// UnusedLocals reports local variables that are never read. Names
+	// starting with "_", loop variables and parameters are exempt.
+	UnusedLocals LintLevel
+	// OSAndIO reports direct use of the os and io libraries, which bypass
+	// the db module and make migrations depend on the host they run on.
+	OSAndIO LintLevel
+	// Globals lists further globals to accept, e.g. ones set by hooks.
+	Globals []string
+}
+
+// DefaultLintConfig warns for every check.
+var DefaultLintConfig = LintConfig{
+	UndefinedGlobals: LintWarn,
+	UnusedLocals:     LintWarn,
+	OSAndIO:          LintWarn,
+}
+
+// LintIssue is a finding of a lint check.
+type LintIssue struct {
+	File    string
+	Line    int
+	Check   string
+	Level   LintLevel
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", i.File, i.Line, i.Message, i.Check)
+}
+
+var luaMigrationGlobals = []string{"Version", "Up", "Down", "DependsOn", "Tags"}
+
+var luaStandardGlobals = []string{
+	"_G", "_VERSION", "assert", "collectgarbage", "dofile", "error", "getfenv",
+	"getmetatable", "ipairs", "load", "loadfile", "loadstring", "module",
+	"newproxy", "next", "pairs", "pcall", "print", "rawequal", "rawget",
+	"rawset", "require", "select", "setfenv", "setmetatable", "tonumber",
+	"tostring", "type", "unpack", "xpcall",
+	"coroutine", "debug", "io", "math", "os", "package", "string", "table",
+}
+
+// LintLua runs the checks enabled in cfg on the Lua migration src, returning
+// its findings in source order. The error is a *SourceError if src does not
+// parse.
+func LintLua(src []byte, name string, cfg LintConfig) ([]LintIssue, error) {
+	chunk, err := parseLua(bytes.NewReader(src), name)
+	if err != nil {
+		return nil, err
+	}
+	l := &linter{cfg: cfg, name: name, known: map[string]bool{}}
+	for _, names := range [][]string{luaMigrationGlobals, luaStandardGlobals, cfg.Globals} {
+		for _, g := range names {
+			l.known[g] = true
+		}
+	}
+	l.block(chunk)
+	slices.SortStableFunc(l.issues, func(a, b LintIssue) int { return a.Line - b.Line })
+	return l.issues, nil
+}
+
+type lintLocal struct {
+	line int
+	used bool
+}
+
+type linter struct {
+	cfg    LintConfig
+	name   string
+	known  map[string]bool
+	scopes []map[string]*lintLocal
+	issues []LintIssue
+}
+
+func (l *linter) report(level LintLevel, check string, line int, format string, args ...any) {
+	if level == LintOff {
+		return
+	}
+	l.issues = append(l.issues, LintIssue{File: l.name, Line: line, Check: check, Level: level, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) push() {
+	l.scopes = append(l.scopes, map[string]*lintLocal{})
+}
+
+// pop closes the innermost scope, reporting its unused locals.
+func (l *linter) pop() {
+	scope := l.scopes[len(l.scopes)-1]
+	l.scopes = l.scopes[:len(l.scopes)-1]
+	for _, name := range slices.Sorted(maps.Keys(scope)) {
+		if local := scope[name]; !local.used && name[0] != '_' {
+			l.report(l.cfg.UnusedLocals, LintUnusedLocal, local.line, "unused local %q", name)
+		}
+	}
+}
+
+func (l *linter) declare(name string, line int, used bool) {
+	l.scopes[len(l.scopes)-1][name] = &lintLocal{line: line, used: used}
+}
+
+func (l *linter) lookup(name string) *lintLocal {
+	for i := len(l.scopes) - 1; i >= 0; i-- {
+		if local, ok := l.scopes[i][name]; ok {
+			return local
+		}
+	}
+	return nil
+}
+
+func (l *linter) block(stmts []ast.Stmt) {
+	l.push()
+	l.stmts(stmts)
+	l.pop()
+}
+
+func (l *linter) stmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		l.stmt(stmt)
+	}
+}
+
+func (l *linter) stmt(stmt ast.Stmt) {
+	switch s := stmt.(type) {
+	case *ast.AssignStmt:
+		l.exprs(s.Rhs)
+		for _, lhs := range s.Lhs {
+			l.target(lhs)
+		}
+	case *ast.LocalAssignStmt:
+		// "local function f" parses like "local f = function", but f is in
+		// scope in its own body.
+		if _, ok := singleFunc(s.Exprs); ok && len(s.Names) == 1 {
+			l.declare(s.Names[0], s.Line(), false)
+			l.exprs(s.Exprs)
+			return
+		}
+		l.exprs(s.Exprs)
+		for _, name := range s.Names {
+			l.declare(name, s.Line(), false)
+		}
+	case *ast.FuncCallStmt:
+		l.expr(s.Expr)
+	case *ast.DoBlockStmt:
+		l.block(s.Stmts)
+	case *ast.WhileStmt:
+		l.expr(s.Condition)
+		l.block(s.Stmts)
+	case *ast.RepeatStmt:
+		l.push()
+		l.stmts(s.Stmts)
+		l.expr(s.Condition)
+		l.pop()
+	case *ast.IfStmt:
+		l.expr(s.Condition)
+		l.block(s.Then)
+		l.block(s.Else)
+	case *ast.NumberForStmt:
+		l.exprs([]ast.Expr{s.Init, s.Limit, s.Step})
+		l.push()
+		l.declare(s.Name, s.Line(), true)
+		l.stmts(s.Stmts)
+		l.pop()
+	case *ast.GenericForStmt:
+		l.exprs(s.Exprs)
+		l.push()
+		for _, name := range s.Names {
+			l.declare(name, s.Line(), true)
+		}
+		l.stmts(s.Stmts)
+		l.pop()
+	case *ast.FuncDefStmt:
+		if s.Name.Method != "" {
+			l.expr(s.Name.Receiver)
+		} else {
+			l.target(s.Name.Func)
+		}
+		l.expr(s.Func)
+	case *ast.ReturnStmt:
+		l.exprs(s.Exprs)
+	}
+}
+
+func singleFunc(exprs []ast.Expr) (*ast.FunctionExpr, bool) {
+	if len(exprs) != 1 {
+		return nil, false
+	}
+	f, ok := exprs[0].(*ast.FunctionExpr)
+	return f, ok
+}
+
+// target checks the target of an assignment.
+func (l *linter) target(expr ast.Expr) {
+	ident, ok := expr.(*ast.IdentExpr)
+	if !ok {
+		l.expr(expr)
+		return
+	}
+	if l.lookup(ident.Value) == nil && !l.known[ident.Value] {
+		l.report(l.cfg.UndefinedGlobals, LintUndefinedGlobal, ident.Line(), "assignment to undefined global %q", ident.Value)
+	}
+}
+
+func (l *linter) exprs(exprs []ast.Expr) {
+	for _, expr := range exprs {
+		l.expr(expr)
+	}
+}
+
+func (l *linter) expr(expr ast.Expr) {
+	switch e := expr.(type) {
+	case nil:
+	case *ast.IdentExpr:
+		if local := l.lookup(e.Value); local != nil {
+			local.used = true
+			return
+		}
+		switch {
+		case e.Value == "os" || e.Value == "io":
+			l.report(l.cfg.OSAndIO, LintOSIO, e.Line(), "direct use of %s", e.Value)
+		case !l.known[e.Value]:
+			l.report(l.cfg.UndefinedGlobals, LintUndefinedGlobal, e.Line(), "undefined global %q", e.Value)
+		}
+	case *ast.AttrGetExpr:
+		l.expr(e.Object)
+		l.expr(e.Key)
+	case *ast.TableExpr:
+		for _, f := range e.Fields {
+			l.expr(f.Key)
+			l.expr(f.Value)
+		}
+	case *ast.FuncCallExpr:
+		l.expr(e.Func)
+		l.expr(e.Receiver)
+		l.exprs(e.Args)
+	case *ast.LogicalOpExpr:
+		l.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.RelationalOpExpr:
+		l.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.StringConcatOpExpr:
+		l.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.ArithmeticOpExpr:
+		l.exprs([]ast.Expr{e.Lhs, e.Rhs})
+	case *ast.UnaryMinusOpExpr:
+		l.expr(e.Expr)
+	case *ast.UnaryNotOpExpr:
+		l.expr(e.Expr)
+	case *ast.UnaryLenOpExpr:
+		l.expr(e.Expr)
+	case *ast.FunctionExpr:
+		l.push()
+		for _, name := range e.ParList.Names {
+			l.declare(name, e.Line(), true)
+		}
+		l.stmts(e.Stmts)
+		l.pop()
+	}
+}
@@ -0,0 +1,93 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestLintLua(t *testing.T) {
+	src := `Version = 3
+local db = require("db")
+local unused = 1
+local _ignored = 2
+
+local function helper(n)
+	if n > 0 then return helper(n - 1) end
+	return n
+end
+
+function Up()
+	for i, v in ipairs({}) do end
+	db.exec(string.format("SELECT %d", helper(1)))
+	local f = io.open("/tmp/x")
+	print(os.getenv("HOME"), undefinedThing)
+end
+
+function Dwon()
+	Globalvar = 1
+	custom_hook()
+end
+`
+	issues, err := golumn.LintLua([]byte(src), "0003_x.lua", golumn.DefaultLintConfig)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`0003_x.lua:3: unused local "unused" (unused-local)`,
+		`0003_x.lua:14: direct use of io (os-io)`,
+		`0003_x.lua:14: unused local "f" (unused-local)`,
+		`0003_x.lua:15: direct use of os (os-io)`,
+		`0003_x.lua:15: undefined global "undefinedThing" (undefined-global)`,
+		`0003_x.lua:18: assignment to undefined global "Dwon" (undefined-global)`,
+		`0003_x.lua:19: assignment to undefined global "Globalvar" (undefined-global)`,
+		`0003_x.lua:20: undefined global "custom_hook" (undefined-global)`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("issues mismatch\nwant:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	cfg := golumn.LintConfig{UndefinedGlobals: golumn.LintError, Globals: []string{"custom_hook", "Dwon", "Globalvar", "undefinedThing"}}
+	issues, err = golumn.LintLua([]byte(src), "0003_x.lua", cfg)
+	if err != nil || len(issues) != 0 {
+		t.Errorf("expected no issues with extra globals and other checks off, got %v (err %v)", issues, err)
+	}
+
+	if _, err := golumn.LintLua([]byte("functoin Down() end"), "0004_y.lua", golumn.DefaultLintConfig); err == nil {
+		t.Error("expected syntax error")
+	}
+}
+
+func TestGlobLoader_Lint(t *testing.T) {
+	dir := t.TempDir()
+	src := "Version=1\nfunction Up() end\nfunction Dwon() end\n"
+	if err := os.WriteFile(filepath.Join(dir, "0001_typo.lua"), []byte(src), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	var logs strings.Builder
+	warn := golumn.DefaultLintConfig
+	loader := golumn.GlobLoader{Pattern: filepath.Join(dir, "*.lua"), Lint: &warn, Log: &golumn.Logger{W: &logs, Level: golumn.LogInfo}}
+	if _, err := loader.Load(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(logs.String(), `0001_typo.lua:3: assignment to undefined global "Dwon"`) {
+		t.Errorf("expected lint warning, got log %q", logs.String())
+	}
+
+	loader.Lint = &golumn.LintConfig{UndefinedGlobals: golumn.LintError}
+	_, err := loader.Load(context.Background())
+	var se *golumn.SourceError
+	if !errors.As(err, &se) || se.Line != 3 {
+		t.Errorf("expected source error on line 3, got %v", err)
+	}
+}
@@ -2,9 +2,16 @@ package golumn
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 )
 
 type Loader interface {
@@ -13,6 +20,25 @@ type Loader interface {
 
 type GlobLoader struct {
 	Pattern string
+	Log     *Logger
+
+	// Lazy takes versions from file name prefixes instead of parsing each
+	// file, deferring parsing until a migration is run. Runs still parse
+	// each file once beforehand for its Tags, RequiresFlag and Envs, which
+	// they check before applying anything.
+	Lazy bool
+
+	// Vars holds variables for templated files, see Preprocess.
+	Vars map[string]string
+
+	// Lint, if set, runs LintLua on each Lua file, logging LintWarn
+	// findings to Log and failing the load on LintError ones. It is ignored
+	// when Lazy is set, since files are then not parsed at load time.
+	Lint *LintConfig
+
+	// Normalize applies NormalizeSource to each file before it is
+	// preprocessed, so that CRLF checkouts parse and checksum like LF ones.
+	Normalize bool
 }
 
 func (l GlobLoader) Load(ctx context.Context) ([]*Migration, error) {
@@ -20,8 +46,29 @@ func (l GlobLoader) Load(ctx context.Context) ([]*Migration, error) {
 	if err != nil {
 		return nil, err
 	}
+	l.Log.Debugf("pattern %q matched %d files", l.Pattern, len(matches))
 
 	migrations := make([]*Migration, len(matches))
+	if l.Lazy {
+		for i, p := range matches {
+			version, err := versionFromName(filepath.Base(p))
+			if err != nil {
+				return nil, err
+			}
+			migrations[i] = MigrationSource{
+				Version:   version,
+				Name:      filepath.Base(p),
+				Open:      func() (io.ReadCloser, error) { return os.Open(p) },
+				Vars:      l.Vars,
+				Normalize: l.Normalize,
+			}.Migration()
+		}
+		slices.SortStableFunc(migrations, func(a, b *Migration) int {
+			return cmp.Compare(a.Version, b.Version)
+		})
+		return migrations, nil
+	}
+
 	for i, p := range matches {
 		f, err := os.Open(p)
 		if err != nil {
@@ -29,12 +76,146 @@ func (l GlobLoader) Load(ctx context.Context) ([]*Migration, error) {
 		}
 		defer f.Close()
 
-		m, err := Parse(ctx, bufio.NewReader(f), filepath.Base(p))
+		m, err := parseFile(ctx, bufio.NewReader(f), filepath.Base(p), parseOptions{vars: l.Vars, lint: l.Lint, log: l.Log, normalize: l.Normalize})
 		if err != nil {
 			return nil, err
 		}
 
+		l.Log.Verbosef("loaded migration %d from %s", m.Version, p)
 		migrations[i] = m
 	}
 	return migrations, nil
 }
+
+// parseOptions configures parseFile.
+type parseOptions struct {
+	vars      map[string]string
+	lint      *LintConfig
+	log       *Logger
+	normalize bool
+}
+
+// parseFile preprocesses and parses a .sql or .lua source, normalizing it
+// first if opts.normalize is set and linting Lua sources if opts.lint is set.
+func parseFile(ctx context.Context, r io.Reader, name string, opts parseOptions) (*Migration, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &SourceError{File: name, Err: err}
+	}
+	if opts.normalize {
+		src = NormalizeSource(src)
+	}
+	if src, err = Preprocess(src, name, opts.vars); err != nil {
+		return nil, err
+	}
+	if filepath.Ext(name) == ".sql" {
+		return ParseSQL(ctx, bytes.NewReader(src), name)
+	}
+	if opts.lint != nil {
+		if err := lintFile(src, name, *opts.lint, opts.log); err != nil {
+			return nil, err
+		}
+	}
+	return Parse(ctx, bytes.NewReader(src), name)
+}
+
+// lintFile lints a Lua source, logging warnings and returning the errors
+// as joined *SourceError values.
+func lintFile(src []byte, name string, cfg LintConfig, log *Logger) error {
+	issues, err := LintLua(src, name, cfg)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, issue := range issues {
+		if issue.Level == LintError {
+			errs = append(errs, &SourceError{File: issue.File, Line: issue.Line, Err: fmt.Errorf("%s (%s)", issue.Message, issue.Check)})
+			continue
+		}
+		log.Infof("warning: %s", issue)
+	}
+	return errors.Join(errs...)
+}
+
+// loadedSources caches the result of Migrator.Loader.
+type loadedSources struct {
+	mu         sync.Mutex
+	loaded     bool
+	migrations []*Migration
+}
+
+// load calls Loader if it has not yet succeeded. It is a no-op without a
+// Loader.
+func (m *Migrator) load(ctx context.Context) error {
+	if m.Loader == nil {
+		return nil
+	}
+	m.loadedSources.mu.Lock()
+	defer m.loadedSources.mu.Unlock()
+	if m.loadedSources.loaded {
+		return nil
+	}
+	return m.loadLocked(ctx)
+}
+
+func (m *Migrator) loadLocked(ctx context.Context) error {
+	migrations, err := m.Loader.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	m.logger().Verbosef("loaded %d migrations", len(migrations))
+	m.loadedSources.migrations = migrations
+	m.loadedSources.loaded = true
+	return nil
+}
+
+// resolveLazy takes the metadata of the lazily loaded migrations, see
+// Migration.resolve.
+func (m *Migrator) resolveLazy(ctx context.Context) error {
+	for _, migration := range m.migrations() {
+		if migration == nil {
+			continue
+		}
+		if err := migration.resolve(ctx); err != nil {
+			return fmt.Errorf("failed to load migration %d: %w", migration.Version, err)
+		}
+	}
+	return nil
+}
+
+// Reload calls Loader again, replacing the migrations cached by earlier
+// runs, e.g. after a watcher reports new migration files. On error the
+// previously loaded migrations are kept. Reload waits for a run in progress
+// to finish, so that a run never sees its sources change.
+func (m *Migrator) Reload(ctx context.Context) error {
+	if m.Loader == nil {
+		return errors.New("no loader configured")
+	}
+	if err := m.runLock.acquire(ctx); err != nil {
+		return err
+	}
+	defer m.runLock.release()
+	m.loadedSources.mu.Lock()
+	defer m.loadedSources.mu.Unlock()
+	return m.loadLocked(ctx)
+}
+
+// Migrations returns the migrations the Migrator runs: those Loader loads,
+// loading them if no run has yet, or Sources without a Loader.
+func (m *Migrator) Migrations(ctx context.Context) ([]*Migration, error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	return m.migrations(), nil
+}
+
+// migrations returns the loaded migrations if Loader is set, and Sources
+// otherwise.
+func (m *Migrator) migrations() []*Migration {
+	if m.Loader == nil {
+		return m.Sources
+	}
+	m.loadedSources.mu.Lock()
+	defer m.loadedSources.mu.Unlock()
+	return m.loadedSources.migrations
+}
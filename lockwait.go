@@ -0,0 +1,46 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DefaultLockNoticeInterval is how often a wait for the store lock is
+// reported unless Migrator.LockNoticeInterval is set.
+const DefaultLockNoticeInterval = 10 * time.Second
+
+// LockWaitNotice reports an ongoing wait for the store lock to
+// Migrator.OnLockWait.
+type LockWaitNotice struct {
+	// Waited is how long the run has waited so far.
+	Waited time.Duration
+	// Holder is the lock's holder, or nil if the store is not a
+	// LockHolderInspector or the holder could not be read.
+	Holder *LockHolder
+}
+
+// noticeLockWait logs that the run has waited for the lock for waited, with
+// its holder if known, and passes the notice to OnLockWait.
+func (m *Migrator) noticeLockWait(ctx context.Context, waited time.Duration) {
+	notice := LockWaitNotice{Waited: waited.Round(time.Millisecond)}
+	if inspector, ok := m.store().(LockHolderInspector); ok {
+		holder, err := inspector.LockHolder(ctx)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			m.logger().Debugf("failed to read version store lock holder: %v", err)
+		}
+		notice.Holder = holder
+	}
+
+	switch h := notice.Holder; {
+	case h == nil:
+		m.logger().Infof("waited %s for version store lock", notice.Waited)
+	case h.Since.IsZero():
+		m.logger().Infof("waited %s for version store lock held by %s", notice.Waited, h.Owner)
+	default:
+		m.logger().Infof("waited %s for version store lock held by %s for %s", notice.Waited, h.Owner, time.Since(h.Since).Round(time.Second))
+	}
+	if m.OnLockWait != nil {
+		m.OnLockWait(ctx, notice)
+	}
+}
@@ -0,0 +1,86 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestMigrator_OnLockWait(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	holder := sqlite3store.New(db)
+	if err := holder.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var notices []golumn.LockWaitNotice
+	migrator := &golumn.Migrator{
+		Store:              sqlite3store.New(db),
+		Sources:            createMigrations(1),
+		LockWait:           300 * time.Millisecond,
+		LockNoticeInterval: 100 * time.Millisecond,
+		OnLockWait: func(_ context.Context, n golumn.LockWaitNotice) {
+			mu.Lock()
+			notices = append(notices, n)
+			mu.Unlock()
+		},
+	}
+	if _, err := migrator.Up(ctx, golumn.Latest); !errors.Is(err, golumn.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(notices) < 2 {
+		t.Fatalf("expected a notice every 100ms of a 300ms wait, got %d", len(notices))
+	}
+	for i, n := range notices {
+		if n.Holder == nil || n.Holder.Owner == "" || n.Holder.Since.IsZero() {
+			t.Errorf("notice %d: expected the holder, got %+v", i, n.Holder)
+		}
+		if n.Waited < 100*time.Millisecond {
+			t.Errorf("notice %d: expected at least 100ms waited, got %s", i, n.Waited)
+		}
+	}
+	if notices[1].Waited <= notices[0].Waited {
+		t.Errorf("expected waits to grow, got %s then %s", notices[0].Waited, notices[1].Waited)
+	}
+}
+
+func TestMigrator_OnLockWait_NoHolder(t *testing.T) {
+	store := &fakeStore{lockFunc: func(context.Context, *fakeStore) error { return golumn.ErrLocked }}
+	var notices []golumn.LockWaitNotice
+	migrator := &golumn.Migrator{
+		Store:              store,
+		Sources:            createMigrations(1),
+		LockWait:           150 * time.Millisecond,
+		LockNoticeInterval: 50 * time.Millisecond,
+		OnLockWait:         func(_ context.Context, n golumn.LockWaitNotice) { notices = append(notices, n) },
+	}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); !errors.Is(err, golumn.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if len(notices) == 0 {
+		t.Fatal("expected notices while waiting")
+	}
+	if notices[0].Holder != nil {
+		t.Errorf("expected no holder from a store that does not report it, got %+v", notices[0].Holder)
+	}
+}
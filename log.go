@@ -0,0 +1,92 @@
+package golumn
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+type LogLevel int
+
+const (
+	LogQuiet LogLevel = iota
+	LogInfo
+	LogVerbose
+	LogDebug
+)
+
+// Logger writes newline-terminated messages at or below Level to W. A nil
+// *Logger discards everything.
+type Logger struct {
+	W     io.Writer
+	Level LogLevel
+
+	// debugW, if split is set, receives LogDebug messages in place of W,
+	// for the deprecated Migrator.DebugW.
+	debugW io.Writer
+	split  bool
+
+	mu sync.Mutex
+}
+
+func NewLogger(w io.Writer, level LogLevel) *Logger {
+	return &Logger{W: w, Level: level}
+}
+
+func (l *Logger) Infof(f string, a ...any) {
+	l.logf(LogInfo, f, a...)
+}
+
+func (l *Logger) Verbosef(f string, a ...any) {
+	l.logf(LogVerbose, f, a...)
+}
+
+func (l *Logger) Debugf(f string, a ...any) {
+	l.logf(LogDebug, f, a...)
+}
+
+func (l *Logger) Enabled(level LogLevel) bool {
+	return l != nil && l.writer(level) != nil && level > LogQuiet && l.Level >= level
+}
+
+func (l *Logger) writer(level LogLevel) io.Writer {
+	if l.split && level == LogDebug {
+		return l.debugW
+	}
+	return l.W
+}
+
+func (l *Logger) logf(level LogLevel, f string, a ...any) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	msg := fmt.Sprintf(f, a...)
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.writer(level), msg)
+}
+
+// legacyLog holds the Logger built from a Migrator's deprecated LogW and
+// DebugW.
+type legacyLog struct {
+	once   sync.Once
+	logger *Logger
+}
+
+// logger returns Log, or if it is unset, a Logger writing to LogW and
+// DebugW.
+func (m *Migrator) logger() *Logger {
+	if m.Log != nil || m.LogW == nil && m.DebugW == nil {
+		return m.Log
+	}
+	m.legacyLog.once.Do(func() {
+		m.legacyLog.logger = &Logger{W: m.LogW, Level: LogDebug, debugW: m.DebugW, split: true}
+	})
+	return m.legacyLog.logger
+}
@@ -0,0 +1,93 @@
+package golumn_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestLogger_Levels(t *testing.T) {
+	tests := []struct {
+		name  string
+		level golumn.LogLevel
+		want  string
+	}{
+		{"quiet", golumn.LogQuiet, ""},
+		{"info", golumn.LogInfo, "info\n"},
+		{"verbose", golumn.LogVerbose, "info\nverbose\n"},
+		{"debug", golumn.LogDebug, "info\nverbose\ndebug\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := golumn.NewLogger(&buf, tt.level)
+			l.Infof("info")
+			l.Verbosef("verbose\n")
+			l.Debugf("debug")
+
+			if buf.String() != tt.want {
+				t.Errorf("want %q, got %q", tt.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestLogger_Nil(t *testing.T) {
+	var l *golumn.Logger
+	l.Infof("discarded")
+	if l.Enabled(golumn.LogInfo) {
+		t.Error("nil logger should not be enabled")
+	}
+}
+
+func TestMigrator_Logging(t *testing.T) {
+	var buf bytes.Buffer
+	migrator := &golumn.Migrator{
+		Store:   &fakeStore{},
+		Sources: createMigrations(1, 2),
+		Log:     golumn.NewLogger(&buf, golumn.LogInfo),
+	}
+
+	if _, err := migrator.Up(context.Background(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := []string{"applying migration: 1", "applying migration: 2", "up: 2 applied, 0 skipped in "}
+	if len(lines) != len(want) {
+		t.Fatalf("want %d lines, got %q", len(want), buf.String())
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, want[i]) {
+			t.Errorf("line %d: want prefix %q, got %q", i, want[i], line)
+		}
+	}
+}
+
+func TestMigrator_LegacyLogWriters(t *testing.T) {
+	var logW, debugW bytes.Buffer
+	migrator := &golumn.Migrator{
+		Store:   &fakeStore{},
+		Sources: createMigrations(1),
+		LogW:    &logW,
+		DebugW:  &debugW,
+		Pacer:   &countingPacer{},
+	}
+
+	if _, err := migrator.Up(context.Background(), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(logW.String(), "applying migration: 1\n") || !strings.Contains(logW.String(), "remote version: -1\n") {
+		t.Errorf("expected info and verbose messages in LogW, got %q", logW.String())
+	}
+	if strings.Contains(logW.String(), "pacing before next migration") {
+		t.Errorf("expected no debug messages in LogW, got %q", logW.String())
+	}
+	if !strings.Contains(debugW.String(), "pacing before next migration") || strings.Contains(debugW.String(), "applying") {
+		t.Errorf("expected only debug messages in DebugW, got %q", debugW.String())
+	}
+}
@@ -1,13 +1,19 @@
 package golumn
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/ast"
 	"github.com/yuin/gopher-lua/parse"
 )
 
@@ -15,10 +21,15 @@ const (
 	luaMigrateModuleName   = "migrate"
 	luaTransactionTypeName = "transaction"
 	luaResultTypeName      = "result"
+	luaCursorTypeName      = "cursor"
 )
 
 func Parse(ctx context.Context, r io.Reader, name string) (*Migration, error) {
-	proto, err := compileLua(r, name)
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &SourceError{File: name, Err: err}
+	}
+	proto, err := compileLua(bytes.NewReader(src), name)
 	if err != nil {
 		return nil, err
 	}
@@ -26,104 +37,374 @@ func Parse(ctx context.Context, r io.Reader, name string) (*Migration, error) {
 	l := lua.NewState()
 	defer l.Close()
 	l.SetContext(ctx)
-	l.PreloadModule("db", loaderFunc(nil))
+	l.PreloadModule("db", loaderFunc(newLuaSession(nil)))
+	l.PreloadModule("hooks", hooksLoaderFunc(nil))
 
 	if err := doCompiled(l, proto); err != nil {
-		return nil, err
+		return nil, luaSourceError(name, err)
 	}
 
 	lv := l.GetGlobal("Version")
 	version, ok := lv.(lua.LNumber)
 	if !ok {
-		return nil, fmt.Errorf("expected Version global to be a number, got %T", lv)
+		return nil, &SourceError{File: name, Err: fmt.Errorf("expected Version global to be a number, got %T", lv)}
+	}
+	if version < 0 || version != lua.LNumber(int64(version)) {
+		return nil, &SourceError{File: name, Err: fmt.Errorf("expected Version global to be a non-negative integer, got %v", version)}
+	}
+
+	dependsOn, err := luaDependsOn(l.GetGlobal("DependsOn"))
+	if err != nil {
+		return nil, &SourceError{File: name, Err: err}
+	}
+	tags, err := luaTags(l.GetGlobal("Tags"))
+	if err != nil {
+		return nil, &SourceError{File: name, Err: err}
+	}
+	var requiresFlag string
+	switch lv := l.GetGlobal("RequiresFlag").(type) {
+	case *lua.LNilType:
+	case lua.LString:
+		requiresFlag = string(lv)
+	default:
+		return nil, &SourceError{File: name, Err: fmt.Errorf("expected RequiresFlag global to be a string, got %s", lv.Type())}
+	}
+	var owner string
+	switch lv := l.GetGlobal("Owner").(type) {
+	case *lua.LNilType:
+	case lua.LString:
+		owner = string(lv)
+	default:
+		return nil, &SourceError{File: name, Err: fmt.Errorf("expected Owner global to be a string, got %s", lv.Type())}
+	}
+	var maxDuration time.Duration
+	switch lv := l.GetGlobal("MaxDuration").(type) {
+	case *lua.LNilType:
+	case lua.LString:
+		if maxDuration, err = parseMaxDuration(string(lv)); err != nil {
+			return nil, &SourceError{File: name, Err: err}
+		}
+	default:
+		return nil, &SourceError{File: name, Err: fmt.Errorf("expected MaxDuration global to be a string, got %s", lv.Type())}
 	}
 
 	return &Migration{
 		Version: int64(version),
 		Name:    name,
 		UpFunc: func(ctx context.Context, db *sql.DB) error {
-			l := lua.NewState()
-			defer l.Close()
-			l.SetContext(ctx)
-			l.PreloadModule("db", loaderFunc(db))
+			return runLua(ctx, db, proto, "Up")
+		},
+		DownFunc: func(ctx context.Context, db *sql.DB) error {
+			return runLua(ctx, db, proto, "Down")
+		},
+		DependsOn:    dependsOn,
+		Tags:         tags,
+		Owner:        owner,
+		RequiresFlag: requiresFlag,
+		MaxDuration:  maxDuration,
+		Checksum:     checksumFromContext(ctx).Sum(src),
+	}, nil
+}
 
-			if err := doCompiled(l, proto); err != nil {
-				return err
-			}
+// luaDependsOn reads the optional DependsOn global, an array of versions.
+func luaDependsOn(lv lua.LValue) ([]int64, error) {
+	if lv == lua.LNil {
+		return nil, nil
+	}
+	tbl, ok := lv.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("expected DependsOn global to be a table, got %s", lv.Type())
+	}
+
+	var versions []int64
+	for i := 1; i <= tbl.Len(); i++ {
+		v, ok := tbl.RawGetInt(i).(lua.LNumber)
+		if !ok {
+			return nil, fmt.Errorf("expected DependsOn[%d] to be a number, got %s", i, tbl.RawGetInt(i).Type())
+		}
+		versions = append(versions, int64(v))
+	}
+	return versions, nil
+}
 
-			if err := l.CallByParam(lua.P{
-				Fn:      l.GetGlobal("Up"),
-				NRet:    0,
-				Protect: true,
-			}); err != nil {
+// luaTags reads the optional Tags global, an array of strings.
+func luaTags(lv lua.LValue) ([]string, error) {
+	if lv == lua.LNil {
+		return nil, nil
+	}
+	tbl, ok := lv.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("expected Tags global to be a table, got %s", lv.Type())
+	}
+
+	var tags []string
+	for i := 1; i <= tbl.Len(); i++ {
+		tag, ok := tbl.RawGetInt(i).(lua.LString)
+		if !ok {
+			return nil, fmt.Errorf("expected Tags[%d] to be a string, got %s", i, tbl.RawGetInt(i).Type())
+		}
+		tags = append(tags, string(tag))
+	}
+	return tags, nil
+}
+
+// LuaMigration returns a migration for Lua source whose version is already
+// known, deferring compilation until the first Up or Down call. Having no
+// context, it computes the default Checksum; GenEmbed's output sets the
+// one configured by WithChecksum instead where they differ.
+func LuaMigration(version int64, name string, src string) *Migration {
+	compile := sync.OnceValues(func() (*lua.FunctionProto, error) {
+		return compileLua(strings.NewReader(src), name)
+	})
+
+	return &Migration{
+		Version:  version,
+		Name:     name,
+		Checksum: Checksum{}.Sum([]byte(src)),
+		UpFunc: func(ctx context.Context, db *sql.DB) error {
+			proto, err := compile()
+			if err != nil {
 				return err
 			}
-
-			return nil
+			return runLua(ctx, db, proto, "Up")
 		},
 		DownFunc: func(ctx context.Context, db *sql.DB) error {
-			l := lua.NewState()
-			defer l.Close()
-			l.SetContext(ctx)
-			l.PreloadModule("db", loaderFunc(db))
-
-			if err := doCompiled(l, proto); err != nil {
+			proto, err := compile()
+			if err != nil {
 				return err
 			}
+			return runLua(ctx, db, proto, "Down")
+		},
+	}
+}
 
-			if err := l.CallByParam(lua.P{
-				Fn:      l.GetGlobal("Down"),
-				NRet:    0,
-				Protect: true,
-			}); err != nil {
-				return err
-			}
+func runLua(ctx context.Context, db *sql.DB, proto *lua.FunctionProto, fn string) (err error) {
+	l := lua.NewState()
+	defer l.Close()
+	l.SetContext(ctx)
 
-			return nil
-		},
-	}, nil
+	sess := newLuaSession(db)
+	sess.partitions = partitionDialectFromContext(ctx)
+	sess.limits = luaLimitsFromContext(ctx)
+	defer func() {
+		if closeErr := sess.close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+
+	// Errors writing the profile are ignored so that profiling never fails
+	// a migration.
+	w := runOptionsFromContext(ctx).luaProfile
+	sess.profiler = newLuaProfiler(w)
+	sess.profiler.enter(proto.SourceName)
+	defer func() {
+		if sess.profiler != nil {
+			sess.profiler.exit()
+			_ = sess.profiler.writeTo(w)
+		}
+	}()
+
+	l.PreloadModule("db", loaderFunc(sess))
+	l.PreloadModule("hooks", hooksLoaderFunc(hooksFromContext(ctx)))
+
+	if err := doCompiled(l, proto); err != nil {
+		return luaSourceError(proto.SourceName, err)
+	}
+	sess.profiler.wrapGlobals(l)
+
+	if err := l.CallByParam(lua.P{
+		Fn:      l.GetGlobal(fn),
+		NRet:    0,
+		Protect: true,
+	}); err != nil {
+		return luaSourceError(proto.SourceName, err)
+	}
+
+	return nil
 }
 
 func compileLua(r io.Reader, name string) (*lua.FunctionProto, error) {
-	chunk, err := parse.Parse(r, name)
+	chunk, err := parseLua(r, name)
 	if err != nil {
 		return nil, err
 	}
 	proto, err := lua.Compile(chunk, name)
 	if err != nil {
-		return nil, err
+		return nil, &SourceError{File: name, Err: err}
 	}
 	return proto, nil
 }
 
+// parseLua parses a Lua chunk, skipping a leading byte order mark and
+// returning syntax errors as *SourceError.
+func parseLua(r io.Reader, name string) ([]ast.Stmt, error) {
+	chunk, err := parse.Parse(skipBOM(r), name)
+	if err != nil {
+		var perr *parse.Error
+		if !errors.As(err, &perr) {
+			return nil, &SourceError{File: name, Err: err}
+		}
+		if perr.Pos.Line == parse.EOF {
+			return nil, &SourceError{File: name, Err: fmt.Errorf("at end of file: %s", perr.Message)}
+		}
+		return nil, &SourceError{File: name, Line: perr.Pos.Line, Err: fmt.Errorf("near '%s': %s", perr.Token, perr.Message)}
+	}
+	return chunk, nil
+}
+
+// luaSourceError attributes a Lua runtime error raised in the chunk name to
+// the line in its "name:line: message" prefix. Errors without one are
+// returned unchanged.
+func luaSourceError(name string, err error) error {
+	var apiErr *lua.ApiError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	rest, ok := strings.CutPrefix(apiErr.Object.String(), name+":")
+	if !ok {
+		return err
+	}
+	lineStr, msg, ok := strings.Cut(rest, ": ")
+	line, convErr := strconv.Atoi(lineStr)
+	if !ok || convErr != nil {
+		return err
+	}
+	if apiErr.StackTrace != "" {
+		msg += "\n" + apiErr.StackTrace
+	}
+	return &SourceError{File: name, Line: line, Err: errors.New(msg)}
+}
+
 func doCompiled(L *lua.LState, proto *lua.FunctionProto) error {
 	lfunc := L.NewFunctionFromProto(proto)
 	L.Push(lfunc)
 	return L.PCall(0, lua.MultRet, nil)
 }
 
-func loaderFunc(db *sql.DB) func(L *lua.LState) int {
+// luaSession tracks the resources a single script run opens so that any left
+// open when the script returns or raises an error can be released.
+type luaSession struct {
+	db         *sql.DB
+	partitions PartitionDialect
+	profiler   *luaProfiler
+	limits     LuaLimits
+	cursors    map[*luaCursor]struct{}
+	txs        map[*luaTx]struct{}
+}
+
+func newLuaSession(db *sql.DB) *luaSession {
+	return &luaSession{
+		db:      db,
+		cursors: map[*luaCursor]struct{}{},
+		txs:     map[*luaTx]struct{}{},
+	}
+}
+
+// close releases abandoned cursors, then rolls back any transaction that was
+// never committed or rolled back. Cursors go first since they may hold rows
+// read through one of those transactions.
+func (s *luaSession) close() error {
+	var errs []error
+	for c := range s.cursors {
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close abandoned cursor: %w", err))
+		}
+	}
+	for tx := range s.txs {
+		if err := tx.rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+			errs = append(errs, fmt.Errorf("rollback abandoned transaction: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *luaSession) openTx(tx *sql.Tx) *luaTx {
+	t := &luaTx{tx: tx, sess: s}
+	s.txs[t] = struct{}{}
+	return t
+}
+
+func (s *luaSession) openCursor(rows *sql.Rows) *luaCursor {
+	c := &luaCursor{rows: rows, sess: s}
+	s.cursors[c] = struct{}{}
+	return c
+}
+
+type luaCursor struct {
+	rows    *sql.Rows
+	scanner rowScanner
+	sess    *luaSession
+	closed  bool
+}
+
+func (c *luaCursor) close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	delete(c.sess.cursors, c)
+	return c.rows.Close()
+}
+
+// next returns the next row, or nil once the cursor is exhausted or closed.
+// Exhausted cursors are closed immediately, returning their connection to
+// the pool.
+func (c *luaCursor) next(l *lua.LState) (*lua.LTable, error) {
+	if c.closed {
+		return nil, nil
+	}
+	if !c.rows.Next() {
+		err := c.rows.Err()
+		return nil, errors.Join(err, c.close())
+	}
+
+	row, err := c.scanner.scan(l, c.rows)
+	if err != nil {
+		return nil, errors.Join(err, c.close())
+	}
+	return row, nil
+}
+
+func loaderFunc(sess *luaSession) func(L *lua.LState) int {
 	exports := map[string]lua.LGFunction{
-		"begin": luaBeginFunc(db),
-		"exec":  luaExecFunc(db),
-		"query": luaQueryFunc(db),
+		"begin":         luaBeginFunc(sess),
+		"exec":          luaExecFunc(sess.db),
+		"exec_affected": luaExecAffectedFunc(sess.db),
+		"exec_expect":   luaExecExpectFunc(sess.db, "exec_expect", expectExactly),
+		"exec_at_least": luaExecExpectFunc(sess.db, "exec_at_least", expectAtLeast),
+		"query":         luaQueryFunc(sess),
+		"query_all":     luaQueryAllFunc(sess),
+		"cursor":        luaCursorFunc(sess),
+
+		"create_partition":  luaCreatePartitionFunc(sess),
+		"drop_partition":    luaDropPartitionFunc(sess),
+		"rotate_partitions": luaRotatePartitionsFunc(sess),
+
+		"anonymize": luaAnonymizeFunc(sess),
+		"hash":      luaAnonymizeHashFunc,
+		"mask":      luaAnonymizeMaskFunc,
 	}
 
+	p := sess.profiler
 	return func(l *lua.LState) int {
 		mtTransaction := l.NewTypeMetatable(luaTransactionTypeName)
-		l.SetField(mtTransaction, "__index", l.SetFuncs(l.NewTable(), transactionMethods))
+		l.SetField(mtTransaction, "__index", l.SetFuncs(l.NewTable(), p.wrapFuncs("transaction:", transactionMethods)))
 
 		mtResult := l.NewTypeMetatable(luaResultTypeName)
 		l.SetField(mtResult, "__index", l.SetFuncs(l.NewTable(), resultMethods))
 
-		moduleTable := l.SetFuncs(l.NewTable(), exports)
+		mtCursor := l.NewTypeMetatable(luaCursorTypeName)
+		l.SetField(mtCursor, "__index", l.SetFuncs(l.NewTable(), p.wrapFuncs("cursor:", cursorMethods)))
+
+		moduleTable := l.SetFuncs(l.NewTable(), p.wrapFuncs("db.", exports))
 		l.Push(moduleTable)
 		return 1
 	}
 }
 
-func luaBeginFunc(db *sql.DB) func(*lua.LState) int {
+func luaBeginFunc(sess *luaSession) func(*lua.LState) int {
 	return func(l *lua.LState) int {
+		db := sess.db
 		if db == nil {
 			l.RaiseError("DB connection (go *sql.DB) is nil")
 			return 0
@@ -187,7 +468,7 @@ func luaBeginFunc(db *sql.DB) func(*lua.LState) int {
 		}
 
 		ud := l.NewUserData()
-		ud.Value = tx
+		ud.Value = sess.openTx(tx)
 		l.SetMetatable(ud, l.GetTypeMetatable(luaTransactionTypeName))
 		l.Push(ud)
 		return 1
@@ -203,12 +484,18 @@ func luaExecFunc(db *sql.DB) func(*lua.LState) int {
 			ctx = context.Background()
 		}
 
+		if err := paceStatement(ctx); err != nil {
+			l.Push(lua.LNil)
+			l.Push(lua.LString(fmt.Sprintf("exec: %v", err)))
+			return 2
+		}
 		res, err := db.ExecContext(ctx, q, args...)
 		if err != nil {
 			l.Push(lua.LNil)
 			l.Push(lua.LString(fmt.Sprintf("exec: %v", err)))
 			return 2
 		}
+		recordStatement(ctx, q, res)
 
 		ud := l.NewUserData()
 		ud.Value = res
@@ -218,125 +505,450 @@ func luaExecFunc(db *sql.DB) func(*lua.LState) int {
 	}
 }
 
-func luaRowIterFunc(rows *sql.Rows) func(*lua.LState) int {
+// luaExecAffectedFunc is exec for the common case of checking what a
+// statement changed: it returns the rows affected and last insert id as
+// numbers, or nil, nil and an error.
+func luaExecAffectedFunc(db *sql.DB) func(*lua.LState) int {
 	return func(l *lua.LState) int {
-		if !rows.Next() {
-			rows.Close()
-			l.Push(lua.LNil)
-			return 1
+		q, args := checkQueryArgs(l, 1)
+
+		ctx := l.Context()
+		if ctx == nil {
+			ctx = context.Background()
 		}
 
-		columns, err := rows.Columns()
+		if err := paceStatement(ctx); err != nil {
+			l.Push(lua.LNil)
+			l.Push(lua.LNil)
+			l.Push(lua.LString(fmt.Sprintf("exec: %v", err)))
+			return 3
+		}
+		res, err := db.ExecContext(ctx, q, args...)
 		if err != nil {
-			rows.Close()
-			l.RaiseError("get row columns: %v", err)
+			l.Push(lua.LNil)
+			l.Push(lua.LNil)
+			l.Push(lua.LString(fmt.Sprintf("exec: %v", err)))
+			return 3
+		}
+		recordStatement(ctx, q, res)
+		return pushAffected(l, res)
+	}
+}
+
+// rowsExpectation reports whether n rows affected meets the expectation
+// of want, and describes the expectation otherwise.
+type rowsExpectation func(n, want int64) (bool, string)
+
+func expectExactly(n, want int64) (bool, string) {
+	return n == want, fmt.Sprintf("expected %d rows affected", want)
+}
+
+func expectAtLeast(n, want int64) (bool, string) {
+	return n >= want, fmt.Sprintf("expected at least %d rows affected", want)
+}
+
+// luaExecExpectFunc is exec for statements that verify their own effects:
+// it raises an error unless the rows affected meet expect, so that a wrong
+// WHERE clause fails the migration instead of silently changing nothing.
+// It returns the rows affected.
+func luaExecExpectFunc(db *sql.DB, name string, expect rowsExpectation) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		want := l.CheckInt64(1)
+		q, args := checkQueryArgs(l, 2)
+
+		ctx := l.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		if err := paceStatement(ctx); err != nil {
+			l.RaiseError("%s: %v", name, err)
 			return 0
 		}
+		res, err := db.ExecContext(ctx, q, args...)
+		if err == nil {
+			recordStatement(ctx, q, res)
+		}
+		return checkAffected(l, name, res, err, want, expect)
+	}
+}
+
+// checkAffected raises an error unless the exec that returned res and err
+// succeeded and affected rows meeting expect, and pushes the rows affected.
+func checkAffected(l *lua.LState, name string, res sql.Result, err error, want int64, expect rowsExpectation) int {
+	if err != nil {
+		l.RaiseError("%s: %v", name, err)
+		return 0
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		l.RaiseError("%s: get rows affected: %v", name, err)
+		return 0
+	}
+	if ok, msg := expect(n, want); !ok {
+		l.RaiseError("%s: %s, got %d", name, msg, n)
+		return 0
+	}
+	l.Push(lua.LNumber(n))
+	return 1
+}
 
-		values := make([]any, len(columns))
-		scanArgs := make([]any, len(values))
-		for i := range values {
-			scanArgs[i] = &values[i]
+// pushAffected pushes the rows affected and last insert id of res, each nil
+// if the driver cannot report it, e.g. last insert ids on PostgreSQL.
+func pushAffected(l *lua.LState, res sql.Result) int {
+	for _, f := range []func() (int64, error){res.RowsAffected, res.LastInsertId} {
+		if n, err := f(); err != nil {
+			l.Push(lua.LNil)
+		} else {
+			l.Push(lua.LNumber(n))
 		}
+	}
+	return 2
+}
 
-		err = rows.Scan(scanArgs...)
+func luaRowIterFunc(c *luaCursor) func(*lua.LState) int {
+	return c.sess.profiler.wrap("rows", func(l *lua.LState) int {
+		row, err := c.next(l)
 		if err != nil {
-			rows.Close()
-			l.RaiseError("scan row: %v", err)
+			l.RaiseError("%v", err)
 			return 0
 		}
-
-		rowTable := l.CreateTable(0, len(columns))
-		for i, name := range columns {
-			goValue := values[i]
-			var luaValue lua.LValue
-
-			if goValue == nil {
-				luaValue = lua.LNil
-			} else {
-				switch v := goValue.(type) {
-				case bool:
-					luaValue = lua.LBool(v)
-				case []byte:
-					luaValue = lua.LString(string(v))
-				case string:
-					luaValue = lua.LString(v)
-				case int:
-					luaValue = lua.LNumber(v)
-				case int8:
-					luaValue = lua.LNumber(v)
-				case int16:
-					luaValue = lua.LNumber(v)
-				case int32:
-					luaValue = lua.LNumber(v)
-				case int64:
-					luaValue = lua.LNumber(v)
-				case uint:
-					luaValue = lua.LNumber(v)
-				case uint8:
-					luaValue = lua.LNumber(v)
-				case uint16:
-					luaValue = lua.LNumber(v)
-				case uint32:
-					luaValue = lua.LNumber(v)
-				case uint64:
-					luaValue = lua.LNumber(v)
-				case float32:
-					luaValue = lua.LNumber(v)
-				case float64:
-					luaValue = lua.LNumber(v)
-				case time.Time:
-					luaValue = lua.LString(v.Format(time.RFC3339Nano))
-				default:
-					l.RaiseError("unsupported go type '%T' for column '%s'", v, name)
-					return 0
-				}
-			}
-			l.SetField(rowTable, name, luaValue)
+		if row == nil {
+			l.Push(lua.LNil)
+			return 1
 		}
-		l.Push(rowTable)
+		l.Push(row)
 		return 1
+	})
+}
+
+// rowScanner converts rows into Lua tables, reusing its scan buffers across
+// rows. After the first row, each column caches a converter for the Go type
+// it produced, so later rows take a single type assertion per value instead
+// of the full type switch in toLuaValue.
+type rowScanner struct {
+	columns    []lua.LString
+	values     []any
+	scanArgs   []any
+	converters []func(any) (lua.LValue, bool)
+}
+
+func (s *rowScanner) init(rows *sql.Rows) error {
+	names, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("get row columns: %w", err)
+	}
+
+	n := len(names)
+	s.columns = make([]lua.LString, n)
+	for i, name := range names {
+		s.columns[i] = lua.LString(name)
 	}
+	s.values = make([]any, n)
+	s.scanArgs = make([]any, n)
+	for i := range s.values {
+		s.scanArgs[i] = &s.values[i]
+	}
+	s.converters = make([]func(any) (lua.LValue, bool), n)
+	return nil
 }
 
-func luaQueryFunc(db *sql.DB) func(*lua.LState) int {
-	return func(l *lua.LState) int {
-		q, args := checkQueryArgs(l, 1)
+func (s *rowScanner) scan(l *lua.LState, rows *sql.Rows) (*lua.LTable, error) {
+	if s.columns == nil {
+		if err := s.init(rows); err != nil {
+			return nil, err
+		}
+	}
 
-		ctx := l.Context()
-		if ctx == nil {
-			ctx = context.Background()
+	if err := rows.Scan(s.scanArgs...); err != nil {
+		return nil, fmt.Errorf("scan row: %w", err)
+	}
+
+	rowTable := l.CreateTable(0, len(s.columns))
+	for i, name := range s.columns {
+		goValue := s.values[i]
+		s.values[i] = nil
+		if goValue == nil {
+			continue
 		}
 
-		rows, err := db.QueryContext(ctx, q, args...)
-		if err != nil {
-			l.RaiseError("query: %v", err)
-			return 0
+		if convert := s.converters[i]; convert != nil {
+			if luaValue, ok := convert(goValue); ok {
+				rowTable.RawSetH(name, luaValue)
+				continue
+			}
 		}
 
-		l.Push(l.NewFunction(luaRowIterFunc(rows)))
+		luaValue, ok := toLuaValue(goValue)
+		if !ok {
+			return nil, fmt.Errorf("unsupported go type '%T' for column '%s'", goValue, name)
+		}
+		s.converters[i] = converterFor(goValue)
+		rowTable.RawSetH(name, luaValue)
+	}
+	return rowTable, nil
+}
+
+func converterFor(goValue any) func(any) (lua.LValue, bool) {
+	switch goValue.(type) {
+	case int64:
+		return func(v any) (lua.LValue, bool) {
+			n, ok := v.(int64)
+			return lua.LNumber(n), ok
+		}
+	case float64:
+		return func(v any) (lua.LValue, bool) {
+			n, ok := v.(float64)
+			return lua.LNumber(n), ok
+		}
+	case string:
+		return func(v any) (lua.LValue, bool) {
+			str, ok := v.(string)
+			return lua.LString(str), ok
+		}
+	case []byte:
+		return func(v any) (lua.LValue, bool) {
+			b, ok := v.([]byte)
+			return lua.LString(b), ok
+		}
+	case bool:
+		return func(v any) (lua.LValue, bool) {
+			b, ok := v.(bool)
+			return lua.LBool(b), ok
+		}
+	default:
+		return nil
+	}
+}
+
+func toLuaValue(goValue any) (lua.LValue, bool) {
+	switch v := goValue.(type) {
+	case nil:
+		return lua.LNil, true
+	case bool:
+		return lua.LBool(v), true
+	case []byte:
+		return lua.LString(v), true
+	case string:
+		return lua.LString(v), true
+	case int:
+		return lua.LNumber(v), true
+	case int8:
+		return lua.LNumber(v), true
+	case int16:
+		return lua.LNumber(v), true
+	case int32:
+		return lua.LNumber(v), true
+	case int64:
+		return lua.LNumber(v), true
+	case uint:
+		return lua.LNumber(v), true
+	case uint8:
+		return lua.LNumber(v), true
+	case uint16:
+		return lua.LNumber(v), true
+	case uint32:
+		return lua.LNumber(v), true
+	case uint64:
+		return lua.LNumber(v), true
+	case float32:
+		return lua.LNumber(v), true
+	case float64:
+		return lua.LNumber(v), true
+	case time.Time:
+		return lua.LString(v.Format(time.RFC3339Nano)), true
+	default:
+		return lua.LNil, false
+	}
+}
+
+func luaQueryFunc(sess *luaSession) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		c := openLuaCursor(l, sess, sess.db, 1)
+		l.Push(l.NewFunction(luaRowIterFunc(c)))
+		l.Push(newCursorUserData(l, c))
+		return 2
+	}
+}
+
+func luaCursorFunc(sess *luaSession) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		c := openLuaCursor(l, sess, sess.db, 1)
+		l.Push(newCursorUserData(l, c))
 		return 1
 	}
 }
 
+type queryer interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}
+
+func openLuaCursor(l *lua.LState, sess *luaSession, q queryer, start int) *luaCursor {
+	query, args := checkQueryArgs(l, start)
+	if q == nil || q == (*sql.DB)(nil) {
+		l.RaiseError("DB connection (go *sql.DB) is nil")
+		return nil
+	}
+
+	ctx := l.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		l.RaiseError("query: %v", err)
+		return nil
+	}
+	recordStatement(ctx, query, nil)
+	return sess.openCursor(rows)
+}
+
+func newCursorUserData(l *lua.LState, c *luaCursor) *lua.LUserData {
+	ud := l.NewUserData()
+	ud.Value = c
+	l.SetMetatable(ud, l.GetTypeMetatable(luaCursorTypeName))
+	return ud
+}
+
+var cursorMethods = map[string]lua.LGFunction{
+	"next":  luaCursorNext,
+	"fetch": luaCursorFetch,
+	"rows":  luaCursorRows,
+	"close": luaCursorClose,
+}
+
+func checkCursor(l *lua.LState) *luaCursor {
+	ud := l.CheckUserData(1)
+	if v, ok := ud.Value.(*luaCursor); ok {
+		return v
+	}
+	l.ArgError(1, "Cursor expected")
+	return nil
+}
+
+func luaCursorNext(l *lua.LState) int {
+	c := checkCursor(l)
+	row, err := c.next(l)
+	if err != nil {
+		l.RaiseError("%v", err)
+		return 0
+	}
+	if row == nil {
+		l.Push(lua.LNil)
+		return 1
+	}
+	l.Push(row)
+	return 1
+}
+
+// luaCursorFetch returns up to n rows as an array, letting scripts process
+// large results in fixed-size batches. An empty array means the cursor is
+// exhausted. Batches are subject to the session's LuaLimits.
+func luaCursorFetch(l *lua.LState) int {
+	c := checkCursor(l)
+	n := l.CheckInt(2)
+	if n <= 0 {
+		l.ArgError(2, "fetch size must be positive")
+		return 0
+	}
+	if maxRows := c.sess.limits.MaxRows; maxRows > 0 && n > maxRows {
+		l.ArgError(2, fmt.Sprintf("fetch size %d exceeds the limit of %d rows", n, maxRows))
+		return 0
+	}
+
+	batch, err := c.sess.fetchRows(l, c, n, "fetch smaller batches")
+	if err != nil {
+		l.RaiseError("%v", err)
+		return 0
+	}
+	l.Push(batch)
+	return 1
+}
+
+func luaCursorRows(l *lua.LState) int {
+	c := checkCursor(l)
+	l.Push(l.NewFunction(luaRowIterFunc(c)))
+	return 1
+}
+
+func luaCursorClose(l *lua.LState) int {
+	c := checkCursor(l)
+	if err := c.close(); err != nil {
+		l.RaiseError("close cursor: %v", err)
+		return 0
+	}
+	l.Push(lua.LTrue)
+	return 1
+}
+
 var transactionMethods = map[string]lua.LGFunction{
-	"exec":     luaTransactionExec,
-	"query":    luaTransactionQuery,
-	"commit":   luaTransactionCommit,
-	"rollback": luaTransactionRollback,
+	"exec":          luaTransactionExec,
+	"exec_affected": luaTransactionExecAffected,
+	"exec_expect":   luaTransactionExecExpectFunc("exec_expect", expectExactly),
+	"exec_at_least": luaTransactionExecExpectFunc("exec_at_least", expectAtLeast),
+	"query":         luaTransactionQuery,
+	"query_all":     luaTransactionQueryAll,
+	"cursor":        luaTransactionCursor,
+	"commit":        luaTransactionCommit,
+	"rollback":      luaTransactionRollback,
+	"is_open":       luaTransactionIsOpen,
+}
+
+type luaTx struct {
+	tx   *sql.Tx
+	sess *luaSession
+	// done describes how the transaction ended, or is empty while it is
+	// still open.
+	done string
+}
+
+func (t *luaTx) commit() error {
+	delete(t.sess.txs, t)
+	err := t.tx.Commit()
+	t.finish("committed", err)
+	return err
+}
+
+func (t *luaTx) rollback() error {
+	delete(t.sess.txs, t)
+	err := t.tx.Rollback()
+	t.finish("rolled back", err)
+	return err
+}
+
+// finish records how the transaction ended. A failed commit or rollback still
+// ends the transaction, but claiming it was committed would be misleading.
+func (t *luaTx) finish(how string, err error) {
+	if err != nil {
+		how = "closed after a failed commit or rollback"
+	}
+	t.done = how
 }
 
-func checkTransaction(l *lua.LState) *sql.Tx {
+func checkTransaction(l *lua.LState) *luaTx {
 	ud := l.CheckUserData(1)
-	if v, ok := ud.Value.(*sql.Tx); ok {
+	if v, ok := ud.Value.(*luaTx); ok {
 		return v
 	}
 	l.ArgError(1, "Transaction expected")
 	return nil
 }
 
-func luaTransactionExec(l *lua.LState) int {
+// checkOpenTransaction is checkTransaction for methods that need a live
+// transaction, raising a descriptive error instead of letting the driver
+// report sql.ErrTxDone.
+func checkOpenTransaction(l *lua.LState, method string) *luaTx {
 	tx := checkTransaction(l)
+	if tx.done != "" {
+		l.RaiseError("%s: transaction already %s", method, tx.done)
+		return nil
+	}
+	return tx
+}
+
+func luaTransactionExec(l *lua.LState) int {
+	tx := checkOpenTransaction(l, "exec")
 	q, args := checkQueryArgs(l, 2)
 
 	ctx := l.Context()
@@ -344,11 +956,16 @@ func luaTransactionExec(l *lua.LState) int {
 		ctx = context.Background()
 	}
 
-	res, err := tx.ExecContext(ctx, q, args...)
+	if err := paceStatement(ctx); err != nil {
+		l.RaiseError("exec: %v", err)
+		return 0
+	}
+	res, err := tx.tx.ExecContext(ctx, q, args...)
 	if err != nil {
 		l.RaiseError("exec: %v", err)
 		return 0
 	}
+	recordStatement(ctx, q, res)
 
 	ud := l.NewUserData()
 	ud.Value = res
@@ -357,8 +974,8 @@ func luaTransactionExec(l *lua.LState) int {
 	return 1
 }
 
-func luaTransactionQuery(l *lua.LState) int {
-	tx := checkTransaction(l)
+func luaTransactionExecAffected(l *lua.LState) int {
+	tx := checkOpenTransaction(l, "exec_affected")
 	q, args := checkQueryArgs(l, 2)
 
 	ctx := l.Context()
@@ -366,19 +983,60 @@ func luaTransactionQuery(l *lua.LState) int {
 		ctx = context.Background()
 	}
 
-	rows, err := tx.QueryContext(ctx, q, args...)
+	if err := paceStatement(ctx); err != nil {
+		l.RaiseError("exec_affected: %v", err)
+		return 0
+	}
+	res, err := tx.tx.ExecContext(ctx, q, args...)
 	if err != nil {
-		l.RaiseError("query: %v", err)
+		l.RaiseError("exec_affected: %v", err)
 		return 0
 	}
+	recordStatement(ctx, q, res)
+	return pushAffected(l, res)
+}
+
+func luaTransactionExecExpectFunc(name string, expect rowsExpectation) lua.LGFunction {
+	return func(l *lua.LState) int {
+		tx := checkOpenTransaction(l, name)
+		want := l.CheckInt64(2)
+		q, args := checkQueryArgs(l, 3)
+
+		ctx := l.Context()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		if err := paceStatement(ctx); err != nil {
+			l.RaiseError("%s: %v", name, err)
+			return 0
+		}
+		res, err := tx.tx.ExecContext(ctx, q, args...)
+		if err == nil {
+			recordStatement(ctx, q, res)
+		}
+		return checkAffected(l, name, res, err, want, expect)
+	}
+}
 
-	l.Push(l.NewFunction(luaRowIterFunc(rows)))
+func luaTransactionQuery(l *lua.LState) int {
+	tx := checkOpenTransaction(l, "query")
+	c := openLuaCursor(l, tx.sess, tx.tx, 2)
+	l.Push(l.NewFunction(luaRowIterFunc(c)))
+	l.Push(newCursorUserData(l, c))
+	return 2
+}
+
+func luaTransactionCursor(l *lua.LState) int {
+	tx := checkOpenTransaction(l, "cursor")
+	c := openLuaCursor(l, tx.sess, tx.tx, 2)
+	l.Push(newCursorUserData(l, c))
 	return 1
 }
 
 func luaTransactionCommit(l *lua.LState) int {
-	tx := checkTransaction(l)
-	if err := tx.Commit(); err != nil {
+	tx := checkOpenTransaction(l, "commit")
+	if err := tx.commit(); err != nil {
 		l.RaiseError("commit transaction: %v", err)
 		return 0
 	}
@@ -387,8 +1045,8 @@ func luaTransactionCommit(l *lua.LState) int {
 }
 
 func luaTransactionRollback(l *lua.LState) int {
-	tx := checkTransaction(l)
-	if err := tx.Rollback(); err != nil {
+	tx := checkOpenTransaction(l, "rollback")
+	if err := tx.rollback(); err != nil {
 		l.RaiseError("rollback transaction: %v", err)
 		return 0
 	}
@@ -396,6 +1054,12 @@ func luaTransactionRollback(l *lua.LState) int {
 	return 1
 }
 
+func luaTransactionIsOpen(l *lua.LState) int {
+	tx := checkTransaction(l)
+	l.Push(lua.LBool(tx.done == ""))
+	return 1
+}
+
 var resultMethods = map[string]lua.LGFunction{
 	"last_insert_id": luaResultLastInsertId,
 	"rows_affected":  luaResultRowsAffected,
@@ -434,10 +1098,15 @@ func luaResultRowsAffected(l *lua.LState) int {
 
 func checkQueryArgs(l *lua.LState, start int) (string, []any) {
 	q := l.CheckString(start)
+	return q, checkScalarArgs(l, start+1, "query param")
+}
 
+// checkScalarArgs converts the arguments from start to the top of the stack
+// into Go values, raising an argument error for any non-scalar value.
+func checkScalarArgs(l *lua.LState, start int, what string) []any {
 	var args []any
 	top := l.GetTop()
-	for i := start + 1; i <= top; i++ {
+	for i := start; i <= top; i++ {
 		lv := l.Get(i)
 		switch lv.Type() {
 		case lua.LTNil:
@@ -449,9 +1118,8 @@ func checkQueryArgs(l *lua.LState, start int) (string, []any) {
 		case lua.LTString:
 			args = append(args, string(lv.(lua.LString)))
 		default:
-			l.ArgError(i, fmt.Sprintf("Unsupported type for query param: %s", lv.Type().String()))
+			l.ArgError(i, fmt.Sprintf("Unsupported type for %s: %s", what, lv.Type().String()))
 		}
 	}
-
-	return q, args
+	return args
 }
@@ -0,0 +1,537 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openLuaTestDB(tb testing.TB, rows int) *sql.DB {
+	tb.Helper()
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		tb.Fatalf("failed to open test database: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT, price REAL, active BOOLEAN)"); err != nil {
+		tb.Fatalf("failed to create table: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		tb.Fatalf("failed to begin: %v", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO items (name, price, active) VALUES (?, ?, ?)")
+	if err != nil {
+		tb.Fatalf("failed to prepare: %v", err)
+	}
+	for i := range rows {
+		if _, err := stmt.Exec(fmt.Sprintf("item-%d", i), float64(i)/10, i%2 == 0); err != nil {
+			tb.Fatalf("failed to insert: %v", err)
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		tb.Fatalf("failed to commit: %v", err)
+	}
+
+	return db
+}
+
+func mustParse(tb testing.TB, src string) *golumn.Migration {
+	tb.Helper()
+	m, err := golumn.Parse(context.Background(), strings.NewReader(src), "test.lua")
+	if err != nil {
+		tb.Fatalf("failed to parse: %v", err)
+	}
+	return m
+}
+
+const iterScript = `local db = require "db"
+Version=1
+function Up()
+    local n = 0
+    for row in db.query("SELECT id, name, price, active FROM items") do
+        n = n + 1
+    end
+    if n ~= Expected then
+        error("expected " .. Expected .. " rows, got " .. n)
+    end
+end
+`
+
+func TestLuaQuery_RowValues(t *testing.T) {
+	db := openLuaTestDB(t, 3)
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    local got = {}
+    for row in db.query("SELECT id, name, price, active, NULL AS missing FROM items ORDER BY id") do
+        if row.missing ~= nil then error("expected nil for NULL column") end
+        got[#got + 1] = row.id .. ":" .. row.name .. ":" .. row.price
+    end
+    local want = "1:item-0:0,2:item-1:0.1,3:item-2:0.2"
+    if table.concat(got, ",") ~= want then
+        error("got " .. table.concat(got, ","))
+    end
+end
+`)
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+}
+
+func TestLuaQuery_MixedColumnTypes(t *testing.T) {
+	db := openLuaTestDB(t, 0)
+	if _, err := db.Exec("CREATE TABLE mixed (id INTEGER PRIMARY KEY, v)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	if _, err := db.Exec("INSERT INTO mixed (v) VALUES (1), ('two'), (3.5), (NULL), (5)"); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    local got = {}
+    for row in db.query("SELECT v FROM mixed ORDER BY id") do
+        got[#got + 1] = type(row.v) .. "=" .. tostring(row.v)
+    end
+    local want = "number=1,string=two,number=3.5,nil=nil,number=5"
+    if table.concat(got, ",") ~= want then
+        error("got " .. table.concat(got, ","))
+    end
+end
+`)
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+}
+
+func TestLuaQuery_AllocsLinear(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping allocation budget in short mode")
+	}
+
+	allocsPerRow := func(rows int) float64 {
+		db := openLuaTestDB(t, rows)
+		m := mustParse(t, fmt.Sprintf("Expected=%d\n%s", rows, iterScript))
+		allocs := testing.AllocsPerRun(3, func() {
+			if err := m.Up(context.Background(), db); err != nil {
+				t.Fatalf("up failed: %v", err)
+			}
+		})
+		return allocs / float64(rows)
+	}
+
+	small, large := allocsPerRow(1000), allocsPerRow(8000)
+	// Per-row allocations must not grow with the result size.
+	if large > small*1.5 {
+		t.Errorf("allocations per row grew from %.1f to %.1f", small, large)
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	src := iterScript
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := golumn.Parse(context.Background(), strings.NewReader(src), "bench.lua"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUpFunc(b *testing.B) {
+	db := openLuaTestDB(b, 0)
+	m := mustParse(b, `local db = require "db"
+Version=1
+function Up()
+    db.exec("UPDATE items SET price = price + 1 WHERE id = ?", 1)
+end
+`)
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := m.Up(context.Background(), db); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRowIteration(b *testing.B) {
+	for _, rows := range []int{100, 10_000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			db := openLuaTestDB(b, rows)
+			m := mustParse(b, fmt.Sprintf("Expected=%d\n%s", rows, iterScript))
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := m.Up(context.Background(), db); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*rows), "ns/row")
+		})
+	}
+}
+
+func BenchmarkBackfill(b *testing.B) {
+	const rows = 1_000_000
+
+	db := openLuaTestDB(b, rows)
+	if _, err := db.Exec("ALTER TABLE items ADD COLUMN label TEXT"); err != nil {
+		b.Fatalf("failed to add column: %v", err)
+	}
+	m := mustParse(b, `local db = require "db"
+Version=1
+function Up()
+    local tx = db.begin()
+    for row in tx:query("SELECT id, name, price, active FROM items") do
+        if row.active then
+            tx:exec("UPDATE items SET label = ? WHERE id = ?", row.name .. "@" .. row.price, row.id)
+        end
+    end
+    tx:commit()
+end
+`)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := m.Up(context.Background(), db); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*rows), "ns/row")
+}
+
+func TestLuaCursor_Fetch(t *testing.T) {
+	db := openLuaTestDB(t, 5)
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    local c = db.cursor("SELECT id FROM items ORDER BY id")
+    local sizes = {}
+    while true do
+        local batch = c:fetch(2)
+        if #batch == 0 then break end
+        sizes[#sizes + 1] = #batch
+    end
+    if table.concat(sizes, ",") ~= "2,2,1" then
+        error("got batches " .. table.concat(sizes, ","))
+    end
+end
+`)
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("expected no connections in use, got %d", inUse)
+	}
+}
+
+func TestLuaCursor_Close(t *testing.T) {
+	db := openLuaTestDB(t, 3)
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    local rows, c = db.query("SELECT id FROM items ORDER BY id")
+    local first = rows()
+    if first.id ~= 1 then error("unexpected first row") end
+    c:close()
+    if rows() ~= nil then error("expected closed cursor to yield nil") end
+    db.exec("INSERT INTO items (name) VALUES ('after-close')")
+end
+`)
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+}
+
+func TestLuaCursor_AbandonedIteratorReleased(t *testing.T) {
+	for name, body := range map[string]string{
+		"query":  `for row in db.query("SELECT id FROM items") do break end`,
+		"cursor": `db.cursor("SELECT id FROM items"):next()`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			db := openLuaTestDB(t, 3)
+			m := mustParse(t, "local db = require \"db\"\nVersion=1\nfunction Up()\n"+body+"\nend\n")
+			if err := m.Up(context.Background(), db); err != nil {
+				t.Fatalf("up failed: %v", err)
+			}
+			if inUse := db.Stats().InUse; inUse != 0 {
+				t.Errorf("expected abandoned cursor to be closed, got %d connections in use", inUse)
+			}
+		})
+	}
+}
+
+func TestLuaSession_ReleasesResourcesOnError(t *testing.T) {
+	tests := map[string]string{
+		"mid-iteration": `for row in db.query("SELECT id FROM items") do
+    error("boom")
+end`,
+		"cursor": `local c = db.cursor("SELECT id FROM items")
+c:next()
+error("boom")`,
+		"before commit": `local tx = db.begin()
+tx:exec("INSERT INTO items (name) VALUES ('uncommitted')")
+error("boom")`,
+		"mid-iteration in transaction": `local tx = db.begin()
+for row in tx:query("SELECT id FROM items") do
+    error("boom")
+end`,
+	}
+	for name, body := range tests {
+		t.Run(name, func(t *testing.T) {
+			db := openLuaTestDB(t, 3)
+			baseline := db.Stats().InUse
+
+			m := mustParse(t, "local db = require \"db\"\nVersion=1\nfunction Up()\n"+body+"\nend\n")
+			if err := m.Up(context.Background(), db); err == nil || !strings.Contains(err.Error(), "boom") {
+				t.Fatalf("expected script error, got %v", err)
+			}
+			if inUse := db.Stats().InUse; inUse != baseline {
+				t.Errorf("expected %d connections in use, got %d", baseline, inUse)
+			}
+
+			var n int
+			if err := db.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil {
+				t.Fatalf("count after error: %v", err)
+			}
+			if n != 3 {
+				t.Errorf("expected uncommitted writes to be rolled back, got %d rows", n)
+			}
+		})
+	}
+}
+
+func TestLuaSession_RollsBackUnfinishedTransaction(t *testing.T) {
+	db := openLuaTestDB(t, 1)
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    local done = db.begin()
+    done:exec("INSERT INTO items (name) VALUES ('committed')")
+    done:commit()
+    local open = db.begin()
+    open:exec("INSERT INTO items (name) VALUES ('abandoned')")
+end
+`)
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("expected no connections in use, got %d", inUse)
+	}
+
+	var names []string
+	rows, err := db.Query("SELECT name FROM items ORDER BY id")
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		names = append(names, name)
+	}
+	if got := strings.Join(names, ","); got != "item-0,committed" {
+		t.Errorf("got rows %q", got)
+	}
+}
+
+func TestLuaTransaction_UseAfterFinish(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want string
+	}{
+		"exec after commit": {
+			body: `tx:commit()
+tx:exec("INSERT INTO items (name) VALUES ('late')")`,
+			want: "exec: transaction already committed",
+		},
+		"query after rollback": {
+			body: `tx:rollback()
+for row in tx:query("SELECT id FROM items") do end`,
+			want: "query: transaction already rolled back",
+		},
+		"commit twice": {
+			body: `tx:commit()
+tx:commit()`,
+			want: "commit: transaction already committed",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db := openLuaTestDB(t, 1)
+			m := mustParse(t, "local db = require \"db\"\nVersion=1\nfunction Up()\nlocal tx = db.begin()\n"+tt.body+"\nend\n")
+			err := m.Up(context.Background(), db)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLuaTransaction_IsOpen(t *testing.T) {
+	db := openLuaTestDB(t, 0)
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    local tx = db.begin()
+    if not tx:is_open() then error("expected open transaction") end
+    tx:commit()
+    if tx:is_open() then error("expected committed transaction to be closed") end
+end
+`)
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+}
+
+func TestParse_DependsOn(t *testing.T) {
+	m := mustParse(t, "Version=3\nDependsOn={1, 2}\n")
+	if !slices.Equal(m.DependsOn, []int64{1, 2}) {
+		t.Errorf("expected DependsOn [1 2], got %v", m.DependsOn)
+	}
+
+	for _, src := range []string{"Version=3\nDependsOn=1\n", "Version=3\nDependsOn={\"one\"}\n"} {
+		if _, err := golumn.Parse(context.Background(), strings.NewReader(src), "test.lua"); err == nil {
+			t.Errorf("expected error parsing %q", src)
+		}
+	}
+}
+
+func TestParse_Version(t *testing.T) {
+	if m := mustParse(t, "Version=0\n"); m.Version != 0 {
+		t.Errorf("expected version 0, got %d", m.Version)
+	}
+
+	for _, src := range []string{"Version=-1\n", "Version=1.5\n", "Version=\"1\"\n"} {
+		if _, err := golumn.Parse(context.Background(), strings.NewReader(src), "test.lua"); err == nil {
+			t.Errorf("expected error parsing %q", src)
+		}
+	}
+}
+
+func TestParse_SourceError(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantLine int
+	}{
+		{"syntax", "Version=1\n\nlocal = 2\n", 3},
+		{"syntax_eof", "Version=1\nfunction Up(\n", 0},
+		{"top_level_error", "Version=1\nerror(\"boom\")\n", 2},
+		{"missing_version", "Up=nil\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := golumn.Parse(context.Background(), strings.NewReader(tt.src), "test.lua")
+			var se *golumn.SourceError
+			if !errors.As(err, &se) {
+				t.Fatalf("expected *SourceError, got %v", err)
+			}
+			if se.File != "test.lua" || se.Line != tt.wantLine {
+				t.Errorf("got %s:%d, want test.lua:%d (%v)", se.File, se.Line, tt.wantLine, err)
+			}
+		})
+	}
+}
+
+func TestLuaMigration_RuntimeSourceError(t *testing.T) {
+	m := golumn.LuaMigration(1, "test.lua", "Version=1\nfunction Up()\n  local x = 1\n  error(\"boom\")\nend\n")
+	err := m.Up(context.Background(), nil)
+	var se *golumn.SourceError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected *SourceError, got %v", err)
+	}
+	if se.File != "test.lua" || se.Line != 4 || !strings.HasPrefix(err.Error(), "test.lua:4: boom") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLuaExecAffected(t *testing.T) {
+	db := openLuaTestDB(t, 3)
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    local n, id, err = db.exec_affected("UPDATE items SET active = ? WHERE id <= ?", false, 2)
+    if err then error(err) end
+    if n ~= 2 then error("expected 2 rows affected, got " .. tostring(n)) end
+
+    n, id = db.exec_affected("INSERT INTO items (name) VALUES ('added')")
+    if n ~= 1 or id ~= 4 then error("got " .. tostring(n) .. ", " .. tostring(id)) end
+
+    n, id, err = db.exec_affected("UPDATE missing SET x = 1")
+    if n ~= nil or id ~= nil or not err then error("expected an error") end
+
+    local tx = db.begin()
+    n = tx:exec_affected("DELETE FROM items WHERE name = ?", "added")
+    tx:commit()
+    if n ~= 1 then error("expected 1 row deleted, got " .. tostring(n)) end
+end
+`)
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+}
+
+func TestLuaExecExpect(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want string
+	}{
+		"exact": {
+			body: `if db.exec_expect(2, "UPDATE items SET active = ? WHERE id <= ?", false, 2) ~= 2 then error("bad count") end`,
+		},
+		"exact mismatch": {
+			body: `db.exec_expect(1, "UPDATE items SET active = 0 WHERE id > 5")`,
+			want: "exec_expect: expected 1 rows affected, got 0",
+		},
+		"at least": {
+			body: `db.exec_at_least(1, "UPDATE items SET active = 0")`,
+		},
+		"at least mismatch": {
+			body: `db.exec_at_least(4, "UPDATE items SET active = 0")`,
+			want: "exec_at_least: expected at least 4 rows affected, got 3",
+		},
+		"statement error": {
+			body: `db.exec_expect(1, "UPDATE missing SET x = 1")`,
+			want: "exec_expect: no such table",
+		},
+		"transaction": {
+			body: `local tx = db.begin()
+tx:exec_expect(1, "DELETE FROM items WHERE id = 1")
+tx:exec_at_least(2, "DELETE FROM items WHERE id = 1")
+tx:commit()`,
+			want: "exec_at_least: expected at least 2 rows affected, got 0",
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db := openLuaTestDB(t, 3)
+			m := mustParse(t, "local db = require \"db\"\nVersion=1\nfunction Up()\n"+tt.body+"\nend\n")
+			err := m.Up(context.Background(), db)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("up failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package golumn
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MessageID identifies a user-facing message in a Catalog.
+type MessageID string
+
+// Messages used by Result, Plan and Status, with the arguments each format
+// receives. Formats may use explicit argument indexes, e.g. %[2]d, to reorder
+// them.
+const (
+	// MsgResultUp: applied, skipped, duration, version.
+	MsgResultUp MessageID = "result.up"
+	// MsgResultUpReverted: applied, auto-reverted, skipped, duration, version.
+	MsgResultUpReverted MessageID = "result.up.reverted"
+	// MsgResultDown: reverted, skipped, duration, version.
+	MsgResultDown MessageID = "result.down"
+	// MsgResultDryRun: direction, planned, skipped, duration, version.
+	MsgResultDryRun MessageID = "result.dry_run"
+	// MsgPlan: direction, from, target, steps.
+	MsgPlan MessageID = "plan"
+	// MsgPlanStep: version, name.
+	MsgPlanStep MessageID = "plan.step"
+	// MsgPlanStatement: statement index, first line of SQL.
+	MsgPlanStatement MessageID = "plan.statement"
+	// MsgPlanExplainError: error.
+	MsgPlanExplainError MessageID = "plan.explain_error"
+	// MsgImpactTables and MsgImpactIndexes: comma-separated names.
+	MsgImpactTables  MessageID = "impact.tables"
+	MsgImpactIndexes MessageID = "impact.indexes"
+	// MsgStatus: version, pending.
+	MsgStatus MessageID = "status"
+	// MsgDirectionUp and MsgDirectionDown name a Direction and take no
+	// arguments.
+	MsgDirectionUp   MessageID = "direction.up"
+	MsgDirectionDown MessageID = "direction.down"
+
+	// Error messages replace the text of the matching sentinel error in
+	// Catalog.Error and take no arguments.
+	MsgErrLocked              MessageID = "error.locked"
+	MsgErrApprovalRequired    MessageID = "error.approval_required"
+	MsgErrOutsideWindow       MessageID = "error.outside_window"
+	MsgErrStopped             MessageID = "error.stopped"
+	MsgErrInvalidTarget       MessageID = "error.invalid_target"
+	MsgErrDirty               MessageID = "error.dirty"
+	MsgErrDrift               MessageID = "error.drift"
+	MsgErrBeyondPin           MessageID = "error.beyond_pin"
+	MsgErrIncompatible        MessageID = "error.incompatible"
+	MsgErrAnonymizeProduction MessageID = "error.anonymize_production"
+	MsgErrIdentityMismatch    MessageID = "error.identity_mismatch"
+)
+
+// Catalog maps message IDs to fmt format strings, so that applications can
+// translate the text golumn shows to users. Messages missing from a Catalog,
+// or a nil Catalog, fall back to DefaultCatalog.
+type Catalog map[MessageID]string
+
+// DefaultCatalog holds the English messages.
+var DefaultCatalog = Catalog{
+	MsgResultUp:         "up: %d applied, %d skipped in %s, version %d",
+	MsgResultUpReverted: "up: %d applied, %d auto-reverted, %d skipped in %s, version %d",
+	MsgResultDown:       "down: %d reverted, %d skipped in %s, version %d",
+	MsgResultDryRun:     "%s (dry run): %d planned, %d skipped in %s, version %d",
+	MsgPlan:             "%s from %d to %d: %d steps",
+	MsgPlanStep:         "%d %s",
+	MsgPlanStatement:    "statement %d: %s",
+	MsgPlanExplainError: "explain failed: %s",
+	MsgImpactTables:     "tables: %s",
+	MsgImpactIndexes:    "indexes: %s",
+	MsgStatus:           "version %d, %d pending",
+	MsgDirectionUp:      "up",
+	MsgDirectionDown:    "down",
+
+	MsgErrLocked:              "the version store is locked by another migration run",
+	MsgErrApprovalRequired:    "this production database requires an approved run",
+	MsgErrOutsideWindow:       "migrations may not run outside the maintenance window",
+	MsgErrStopped:             "the run was stopped before completion",
+	MsgErrInvalidTarget:       "the target version is out of range",
+	MsgErrDirty:               "a failed run left the version store locked",
+	MsgErrDrift:               "the version store records a migration that no longer exists",
+	MsgErrBeyondPin:           "the target version is above the pinned version",
+	MsgErrIncompatible:        "the run would break a running application",
+	MsgErrAnonymizeProduction: "anonymization migrations may not run against a production database",
+	MsgErrIdentityMismatch:    "the database is not the one this migrator expects",
+}
+
+var catalogErrors = []struct {
+	err error
+	id  MessageID
+}{
+	{ErrLocked, MsgErrLocked},
+	{ErrApprovalRequired, MsgErrApprovalRequired},
+	{ErrOutsideWindow, MsgErrOutsideWindow},
+	{ErrStopped, MsgErrStopped},
+	{ErrInvalidTarget, MsgErrInvalidTarget},
+	{ErrDirty, MsgErrDirty},
+	{ErrDrift, MsgErrDrift},
+	{ErrBeyondPin, MsgErrBeyondPin},
+	{ErrIncompatible, MsgErrIncompatible},
+	{ErrAnonymizeProduction, MsgErrAnonymizeProduction},
+	{ErrIdentityMismatch, MsgErrIdentityMismatch},
+}
+
+// Sprintf formats the message id with args.
+func (c Catalog) Sprintf(id MessageID, args ...any) string {
+	f, ok := c[id]
+	if !ok {
+		f = DefaultCatalog[id]
+	}
+	return fmt.Sprintf(f, args...)
+}
+
+// Direction returns the name of dir.
+func (c Catalog) Direction(dir Direction) string {
+	switch dir {
+	case DirectionUp:
+		return c.Sprintf(MsgDirectionUp)
+	case DirectionDown:
+		return c.Sprintf(MsgDirectionDown)
+	}
+	return string(dir)
+}
+
+// Error returns the message for the first sentinel error in err's chain that
+// the catalog covers, e.g. ErrLocked, and err.Error() otherwise. Wrapped
+// detail is dropped, so callers that need it, e.g. for logs, should keep
+// using err.Error().
+func (c Catalog) Error(err error) string {
+	for _, ce := range catalogErrors {
+		if errors.Is(err, ce.err) {
+			return c.Sprintf(ce.id)
+		}
+	}
+	return err.Error()
+}
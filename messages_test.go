@@ -0,0 +1,83 @@
+package golumn_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestResult_String(t *testing.T) {
+	tests := []struct {
+		res  *golumn.Result
+		want string
+	}{
+		{&golumn.Result{Direction: golumn.DirectionUp, Applied: []int64{1, 2}, Skipped: 1, Duration: time.Second, Version: 2}, "up: 2 applied, 1 skipped in 1s, version 2"},
+		{&golumn.Result{Direction: golumn.DirectionUp, Applied: []int64{1}, Reverted: []int64{1}, Version: -1}, "up: 1 applied, 1 auto-reverted, 0 skipped in 0s, version -1"},
+		{&golumn.Result{Direction: golumn.DirectionDown, Reverted: []int64{2}, Skipped: 1, Version: 1}, "down: 1 reverted, 1 skipped in 0s, version 1"},
+		{&golumn.Result{Direction: golumn.DirectionDown, DryRun: true, Plan: &golumn.Plan{Steps: []golumn.PlanStep{{Version: 2}}}, Version: 2}, "down (dry run): 1 planned, 0 skipped in 0s, version 2"},
+	}
+	for _, tt := range tests {
+		if got := tt.res.String(); got != tt.want {
+			t.Errorf("want %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	c := golumn.Catalog{
+		golumn.MsgStatus:        "%[2]d ausstehend, Version %[1]d",
+		golumn.MsgDirectionUp:   "hoch",
+		golumn.MsgPlan:          "%[1]s: %[4]d Schritte",
+		golumn.MsgErrLocked:     "gesperrt",
+		golumn.MsgResultDryRun:  "%[1]s (Probelauf): %[2]d geplant",
+		golumn.MsgResultUp:      "hoch: %[1]d angewendet",
+		golumn.MsgDirectionDown: "runter",
+	}
+
+	st := &golumn.Status{Version: 3, Pending: []int64{4, 5}}
+	if got, want := st.Format(c), "2 ausstehend, Version 3"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := st.String(), "version 3, 2 pending"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	plan := &golumn.Plan{Direction: golumn.DirectionUp, Steps: []golumn.PlanStep{{Version: 4, Name: "0004.sql"}, {Version: 5}}}
+	if got, want := plan.Format(c), "hoch: 2 Schritte\n  4 0004.sql\n  5"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	err := fmt.Errorf("failed to get version store lock: %w", golumn.ErrLocked)
+	if got := c.Error(err); got != "gesperrt" {
+		t.Errorf("want translated error, got %q", got)
+	}
+	if got := golumn.Catalog(nil).Error(golumn.ErrStopped); got != golumn.DefaultCatalog[golumn.MsgErrStopped] {
+		t.Errorf("want default message, got %q", got)
+	}
+	other := errors.New("boom")
+	if got := c.Error(other); got != "boom" {
+		t.Errorf("want untranslated error text, got %q", got)
+	}
+}
+
+func TestMigrator_Messages(t *testing.T) {
+	var buf bytes.Buffer
+	migrator := &golumn.Migrator{
+		Store:    &fakeStore{},
+		Sources:  createMigrations(1),
+		Log:      golumn.NewLogger(&buf, golumn.LogInfo),
+		Messages: golumn.Catalog{golumn.MsgResultUp: "hoch: %[1]d angewendet"},
+	}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "hoch: 1 angewendet\n") {
+		t.Errorf("expected translated summary, got %q", buf.String())
+	}
+}
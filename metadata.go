@@ -0,0 +1,74 @@
+package golumn
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MetadataSchema identifies the JSON format written by MarshalMetadata.
+// Fields may be added within a schema version; consumers should ignore
+// fields they do not know. Removing or changing a field bumps the version.
+const MetadataSchema = "golumn.migrations/v1"
+
+// MigrationMetadata is the wire form of a Migration's metadata, for
+// external tooling such as review bots and catalogs.
+type MigrationMetadata struct {
+	Version      int64    `json:"version"`
+	Name         string   `json:"name"`
+	Checksum     string   `json:"checksum,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	Owner        string   `json:"owner,omitempty"`
+	DependsOn    []int64  `json:"depends_on,omitempty"`
+	RequiresFlag string   `json:"requires_flag,omitempty"`
+	// MaxDuration is the migration's MaxDuration in time.Duration's
+	// string form, e.g. "5m0s".
+	MaxDuration string `json:"max_duration,omitempty"`
+}
+
+// metadataDocument is the top-level JSON object of MetadataSchema.
+type metadataDocument struct {
+	Schema     string              `json:"schema"`
+	Migrations []MigrationMetadata `json:"migrations"`
+}
+
+// Metadata returns the metadata of m.
+func Metadata(m *Migration) MigrationMetadata {
+	md := MigrationMetadata{
+		Version:      m.Version,
+		Name:         m.Name,
+		Checksum:     m.Checksum,
+		Tags:         m.Tags,
+		Owner:        m.Owner,
+		DependsOn:    m.DependsOn,
+		RequiresFlag: m.RequiresFlag,
+	}
+	if m.MaxDuration > 0 {
+		md.MaxDuration = m.MaxDuration.String()
+	}
+	return md
+}
+
+// MarshalMetadata encodes the metadata of migrations as a MetadataSchema
+// document:
+//
+//	{"schema":"golumn.migrations/v1","migrations":[{"version":2,"name":"0002_users.sql","checksum":"sha256:…","tags":["billing"],"depends_on":[1]}]}
+func MarshalMetadata(migrations []*Migration) ([]byte, error) {
+	doc := metadataDocument{Schema: MetadataSchema, Migrations: make([]MigrationMetadata, len(migrations))}
+	for i, m := range migrations {
+		doc.Migrations[i] = Metadata(m)
+	}
+	return json.Marshal(doc)
+}
+
+// UnmarshalMetadata decodes a document written by MarshalMetadata. It fails
+// for documents of another schema version.
+func UnmarshalMetadata(data []byte) ([]MigrationMetadata, error) {
+	var doc metadataDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid migration metadata: %w", err)
+	}
+	if doc.Schema != MetadataSchema {
+		return nil, fmt.Errorf("unsupported migration metadata schema %q, want %q", doc.Schema, MetadataSchema)
+	}
+	return doc.Migrations, nil
+}
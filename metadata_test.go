@@ -0,0 +1,65 @@
+package golumn_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigrationMetadata(t *testing.T) {
+	sqlMigration, err := golumn.ParseSQL(context.Background(), strings.NewReader("-- +golumn DependsOn 1\n-- +golumn Tags billing slow\n-- +golumn Up\nSELECT 1;\n"), "0002_users.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	luaSrc := "Version=3\nTags={\"core\"}\nfunction Up() end\nfunction Down() end\n"
+	luaMigration, err := golumn.Parse(context.Background(), strings.NewReader(luaSrc), "0003_seed.lua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMigration := &golumn.Migration{Version: 1, Name: "init"}
+
+	if !slices.Equal(sqlMigration.Tags, []string{"billing", "slow"}) || !slices.Equal(luaMigration.Tags, []string{"core"}) {
+		t.Errorf("unexpected tags: %q and %q", sqlMigration.Tags, luaMigration.Tags)
+	}
+	if !strings.HasPrefix(sqlMigration.Checksum, "sha256:") || len(sqlMigration.Checksum) != len("sha256:")+64 {
+		t.Errorf("unexpected checksum %q", sqlMigration.Checksum)
+	}
+	if lazy := golumn.LuaMigration(3, "0003_seed.lua", luaSrc); lazy.Checksum != luaMigration.Checksum {
+		t.Errorf("expected LuaMigration checksum %q to match Parse, got %q", luaMigration.Checksum, lazy.Checksum)
+	}
+
+	data, err := golumn.MarshalMetadata([]*golumn.Migration{goMigration, sqlMigration, luaMigration})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := `{"schema":"golumn.migrations/v1","migrations":[{"version":1,"name":"init"},{"version":2,"name":"0002_users.sql","checksum":"` + sqlMigration.Checksum + `","tags":["billing","slow"],"depends_on":[1]},`; !strings.HasPrefix(string(data), want) {
+		t.Errorf("unexpected encoding\nwant prefix: %s\ngot:         %s", want, data)
+	}
+
+	got, err := golumn.UnmarshalMetadata(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 3 || got[2].Version != 3 || got[2].Checksum != luaMigration.Checksum || !slices.Equal(got[1].DependsOn, []int64{1}) {
+		t.Errorf("round trip mismatch: %+v", got)
+	}
+}
+
+func TestUnmarshalMetadata_Errors(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"migrations":[]}`,
+		`{"schema":"golumn.migrations/v2","migrations":[]}`,
+	} {
+		if _, err := golumn.UnmarshalMetadata([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+	got, err := golumn.UnmarshalMetadata([]byte(`{"schema":"golumn.migrations/v1","migrations":[{"version":1,"name":"a","owner":"new field"}],"extra":true}`))
+	if err != nil || len(got) != 1 || got[0].Name != "a" {
+		t.Errorf("expected unknown fields to be ignored, got %+v (err %v)", got, err)
+	}
+}
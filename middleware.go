@@ -0,0 +1,38 @@
+package golumn
+
+import "sync"
+
+// StoreMiddleware wraps a Store, e.g. to log, time, retry or cache its
+// operations. Wrappers should forward the optional interfaces they can
+// support, such as VersionLister and ForceUnlocker, since Migrator detects
+// those on the wrapped store.
+type StoreMiddleware func(Store) Store
+
+// ChainStore applies mws to s so that mws[0] is the outermost wrapper and
+// sees each call first.
+func ChainStore(s Store, mws ...StoreMiddleware) Store {
+	for i := len(mws) - 1; i >= 0; i-- {
+		s = mws[i](s)
+	}
+	return s
+}
+
+// wrappedStore holds a store with middleware applied, built on first use so
+// that stateful middleware, e.g. a cache, is shared across runs.
+type wrappedStore struct {
+	once  sync.Once
+	store Store
+}
+
+func (w *wrappedStore) get(s Store, mws []StoreMiddleware) Store {
+	if len(mws) == 0 || s == nil {
+		return s
+	}
+	w.once.Do(func() { w.store = ChainStore(s, mws...) })
+	return w.store
+}
+
+// store returns Store wrapped in Middleware.
+func (m *Migrator) store() Store {
+	return m.wrappedStore.get(m.Store, m.Middleware)
+}
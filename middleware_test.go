@@ -0,0 +1,68 @@
+package golumn_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type tracingStore struct {
+	golumn.Store
+	name  string
+	trace *[]string
+}
+
+func (s *tracingStore) Insert(ctx context.Context, v int64) error {
+	*s.trace = append(*s.trace, s.name)
+	return s.Store.Insert(ctx, v)
+}
+
+func tracing(name string, trace *[]string) golumn.StoreMiddleware {
+	return func(inner golumn.Store) golumn.Store {
+		return &tracingStore{Store: inner, name: name, trace: trace}
+	}
+}
+
+func TestChainStore(t *testing.T) {
+	var trace []string
+	store := golumn.ChainStore(&fakeStore{}, tracing("outer", &trace), tracing("inner", &trace))
+	if err := store.Insert(context.Background(), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"outer", "inner"}; !slices.Equal(trace, want) {
+		t.Errorf("expected calls %v, got %v", want, trace)
+	}
+}
+
+func TestMigrator_Middleware(t *testing.T) {
+	var trace []string
+	wraps := 0
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{
+		Store:   store,
+		Sources: createMigrations(1, 2, 3),
+		Middleware: []golumn.StoreMiddleware{func(inner golumn.Store) golumn.Store {
+			wraps++
+			return tracing("mw", &trace)(inner)
+		}},
+	}
+
+	if _, err := migrator.Up(context.Background(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(trace) != 3 {
+		t.Errorf("expected 3 inserts through middleware, got %d", len(trace))
+	}
+	if wraps != 1 {
+		t.Errorf("expected middleware to be applied once, got %d", wraps)
+	}
+	if !slices.Equal(store.applied, []int64{1, 2, 3}) {
+		t.Errorf("expected applied [1 2 3], got %v", store.applied)
+	}
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"runtime/debug"
+	"slices"
+	"time"
 )
 
 type Migration struct {
@@ -11,9 +14,95 @@ type Migration struct {
 	Name     string
 	UpFunc   func(context.Context, *sql.DB) error
 	DownFunc func(context.Context, *sql.DB) error
+
+	// UpTarget and DownTarget are used in place of UpFunc and DownFunc
+	// against a store implementing Targeter, and are passed its Target,
+	// e.g. the database a mongostore.Store migrates.
+	UpTarget   func(context.Context, any) error
+	DownTarget func(context.Context, any) error
+
+	// DependsOn lists earlier versions this migration relies on, which
+	// Migrator.DownOnly refuses to revert while it is applied. Migrations
+	// built by LuaMigration are not parsed until run and so carry no
+	// dependencies unless set here.
+	DependsOn []int64
+
+	// Tags are free-form labels, e.g. owners or change categories, taken
+	// from a "-- +golumn Tags" comment or a Lua Tags global.
+	Tags []string
+
+	// Owner names the team accountable for the migration, taken from a
+	// "-- +golumn Owner team" comment or a Lua Owner global. It is required,
+	// and restricted, by Migrator.OwnerPolicy.
+	Owner string
+
+	// Envs, if set, lists the environments the migration applies in, taken
+	// from a "-- +golumn Env=prod" comment. In a run whose environment, set
+	// by WithEnv, is not listed, Up and Down do nothing, so the version is
+	// recorded without running and versions stay in step across
+	// environments. Runs that set no environment refuse to start.
+	Envs []string
+
+	// RequiresFlag, if set, names a feature flag that must be enabled, as
+	// reported by Migrator.Flags, for Up and UpOnly to apply the migration.
+	// Until it is, the migration is skipped and not recorded, so that it
+	// ships with the feature it serves. It is taken from a
+	// "-- +golumn RequiresFlag name" comment or a Lua RequiresFlag global.
+	RequiresFlag string
+
+	// NoTransaction makes a SQL migration run its statements one by one
+	// instead of in a transaction, for statements such as CREATE INDEX
+	// CONCURRENTLY that cannot run in one. A failed migration may then be
+	// partially applied. It is set by a "-- +golumn NoTransaction" comment
+	// and ignored by Lua and Go migrations.
+	NoTransaction bool
+
+	// MaxDuration, if positive, bounds how long Up or Down may run: the
+	// migrator cancels its context at the deadline and fails the run with
+	// an error matching ErrMigrationTimeout. It is taken from a
+	// "-- +golumn MaxDuration 5m" comment or a Lua MaxDuration global, a
+	// string parsed by time.ParseDuration. Go migrations must honor their
+	// context for the deadline to stop them.
+	MaxDuration time.Duration
+
+	// Checksum is "sha256:" followed by the hex SHA-256 of the source the
+	// migration was parsed from, after Preprocess, or empty for migrations
+	// defined in Go or loaded lazily by MigrationSource. WithChecksum
+	// chooses another algorithm or canonicalizes the source first.
+	Checksum string
+
+	// up and down are the statements of a SQL migration, and load parses a
+	// lazily loaded one, so that WithExplain can preview them. resolved is
+	// set once resolve has taken the lazily loaded one's metadata.
+	up, down []sqlStatement
+	load     func(context.Context) (*Migration, error)
+	resolved bool
+}
+
+// resolve parses a lazily loaded migration, once, to take the metadata its
+// directives set, so that the checks a run makes before applying it see
+// them. The parsed statements are not retained.
+func (m *Migration) resolve(ctx context.Context) error {
+	if m.load == nil || m.resolved {
+		return nil
+	}
+	loaded, err := m.load(ctx)
+	if err != nil {
+		return err
+	}
+	m.DependsOn, m.Tags, m.Owner = loaded.DependsOn, loaded.Tags, loaded.Owner
+	m.Envs, m.RequiresFlag, m.MaxDuration = loaded.Envs, loaded.RequiresFlag, loaded.MaxDuration
+	m.resolved = true
+	return nil
 }
 
 func (m *Migration) Up(ctx context.Context, db *sql.DB) error {
+	if !m.inEnv(ctx) {
+		return nil
+	}
+	if m.UpFunc == nil && m.UpTarget != nil {
+		return m.runTarget(ctx, m.UpTarget)
+	}
 	if m.UpFunc == nil {
 		return fmt.Errorf("migration %d: missing up func", m.Version)
 	}
@@ -21,8 +110,62 @@ func (m *Migration) Up(ctx context.Context, db *sql.DB) error {
 }
 
 func (m *Migration) Down(ctx context.Context, db *sql.DB) error {
+	if !m.inEnv(ctx) {
+		return nil
+	}
+	if m.DownFunc == nil && m.DownTarget != nil {
+		return m.runTarget(ctx, m.DownTarget)
+	}
 	if m.DownFunc == nil {
 		return fmt.Errorf("migration %d: missing down func", m.Version)
 	}
 	return m.DownFunc(ctx, db)
 }
+
+// inEnv reports whether m applies in the environment of the run carried by
+// ctx.
+func (m *Migration) inEnv(ctx context.Context) bool {
+	return len(m.Envs) == 0 || slices.Contains(m.Envs, runOptionsFromContext(ctx).env)
+}
+
+// checkEnv fails a run without an environment if any migration is
+// restricted to environments: it would record such a migration without
+// running it, and a later run in a listed environment would then never
+// apply it.
+func (m *Migrator) checkEnv(ctx context.Context) error {
+	if runOptionsFromContext(ctx).env != "" {
+		return nil
+	}
+	for _, migration := range m.migrations() {
+		if len(migration.Envs) > 0 {
+			return fmt.Errorf("migration %d is restricted to environments %v but the run sets none, see WithEnv", migration.Version, migration.Envs)
+		}
+	}
+	return nil
+}
+
+// PanicError is returned in place of a panic raised by a migration func.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// safeCall runs fn, converting a panic into a *PanicError.
+func safeCall(ctx context.Context, db *sql.DB, fn func(context.Context, *sql.DB) error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return fn(ctx, db)
+}
@@ -2,35 +2,260 @@ package golumn
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io"
 	"slices"
+	"sync"
+	"time"
 )
 
-const (
-	UpTargetLatest    = -1
-	DownTargetInitial = -1
-)
+var lockRetryInterval = 50 * time.Millisecond
+
+// mixedVersionDigits is the growth in decimal digits between consecutive
+// versions that is treated as a switch in versioning scheme, e.g. 42 followed
+// by 1718000000.
+const mixedVersionDigits = 4
+
+func digits(v int64) int {
+	n := 1
+	for v >= 10 {
+		v /= 10
+		n++
+	}
+	return n
+}
 
+// Migrator runs migrations against a Store. A configured Migrator is safe
+// for concurrent use: Status, CachedVersion and Validate may be called at any
+// time, while Up, UpOnly, Down, DownOnly and Reload are serialized, each
+// waiting for the one in progress to finish. Fields must not be changed once
+// the Migrator is in use; use RunOption to vary a single call.
 type Migrator struct {
 	Store   Store
 	Sources []*Migration
-	LogW    io.Writer
-	DebugW  io.Writer
+	Log     *Logger
+
+	// LogW receives Log's info and verbose messages if Log is unset.
+	//
+	// Deprecated: use Log.
+	LogW io.Writer
+	// DebugW receives Log's debug messages if Log is unset.
+	//
+	// Deprecated: use Log.
+	DebugW io.Writer
+
+	// Loader, if set, is used in place of Sources. It is called on first use
+	// and its result cached until Reload.
+	Loader Loader
+
+	// StatusStore, if set, is read by Status instead of Store, e.g. a store
+	// on a separate read connection so that status polling never competes
+	// with migration runs for Store's connections.
+	StatusStore Store
+
+	HoldLockOnFailure   bool
+	AutoRevertOnFailure bool
+
+	// VerifyAfterRun makes Up, UpOnly, Down and DownOnly check the store's
+	// applied versions against Sources once they have succeeded, still
+	// holding the lock, and report discrepancies in Result.Anomalies. It
+	// requires a store implementing VersionLister and is skipped otherwise.
+	VerifyAfterRun bool
+
+	// AllowMixedVersions acknowledges that Sources mix versioning schemes,
+	// e.g. sequential versions followed by timestamps, and disables the
+	// magnitude jump check.
+	AllowMixedVersions bool
+
+	// LockWait is how long Up and Down keep retrying when the store reports
+	// ErrLocked. Zero fails immediately.
+	LockWait time.Duration
+
+	// OnLockWait, if set, is called every LockNoticeInterval while Up or
+	// Down waits for the store lock, as the wait is also logged, so that
+	// operators can decide whether to keep waiting or force the lock open.
+	// The notice names the holder if the store is a LockHolderInspector.
+	OnLockWait func(context.Context, LockWaitNotice)
+	// LockNoticeInterval is how often a wait for the lock is reported, or
+	// DefaultLockNoticeInterval if zero.
+	LockNoticeInterval time.Duration
+
+	// SharedReads makes Status and Export hold the store's shared lock while
+	// they read, if it is a SharedLocker, so that they never see history a
+	// run or Import is midway through changing. They then wait for the lock
+	// as Up does, up to LockWait, and Status initializes the store, which it
+	// otherwise does not.
+	SharedReads bool
+
+	// Hooks, if set, are exposed to Lua migrations run by Up and Down. See
+	// WithHooks.
+	Hooks Hooks
+
+	// PinFile, if set, names a pin file, e.g. DefaultPinFile, holding the
+	// highest version Up may apply. Up fails with ErrBeyondPin for targets
+	// above it and accepts Pinned as a target. A missing file pins nothing.
+	PinFile string
+
+	// Conn, if set, tunes the store's database for each Up and Down run.
+	Conn *ConnConfig
+
+	// PartitionDialect, if set, gives Lua migrations run by Up and Down the
+	// partition helpers for its dialect. See WithPartitionDialect.
+	PartitionDialect PartitionDialect
+
+	// LuaLimits, if set, replaces DefaultLuaLimits for Lua migrations run
+	// by Up and Down. See WithLuaLimits.
+	LuaLimits *LuaLimits
+
+	// ExpectedIdentity, if set, is the Identity the store must report for
+	// Up and Down to run, so that a job pointed at the wrong database fails
+	// with ErrIdentityMismatch before changing it. The store must be an
+	// IdentityStore.
+	ExpectedIdentity string
+
+	// OwnerPolicy, if set, is checked against every migration's Owner
+	// before a run, see OwnerPolicy.
+	OwnerPolicy *OwnerPolicy
+
+	// Flags is consulted for migrations with RequiresFlag set. It is
+	// required if any migration requires a flag.
+	Flags FlagProvider
+
+	// AfterSuccess, if set, is called with the Result once an Up or Down run
+	// has succeeded and the store lock has been released, e.g. to invalidate
+	// caches or publish a schema-changed event. It is called even when the
+	// run changed nothing. An error is returned from the run, but the
+	// migrations it reports stay applied.
+	AfterSuccess func(context.Context, *Result) error
+
+	// Audit, if set, is sent an AuditEvent each time a version is inserted
+	// into or removed from the store. A failed write stops the run with an
+	// error; the version change it describes has already been recorded in
+	// the store and is not undone.
+	Audit AuditSink
+
+	// RecordStats annotates each applied version with its StatementStats,
+	// under AnnotationStatements and AnnotationRowsAffected, so that the
+	// store's history quantifies what each migration changed. It requires
+	// the store to be an Annotator, failing validation otherwise; failures
+	// to annotate are logged.
+	RecordStats bool
+
+	// RefreshStatistics, if set, refreshes the planner statistics of the
+	// tables a successful run changed, using its dialect's ANALYZE, so
+	// that queries are not planned with statistics from before the run.
+	// Tables are found in the statements run through the Lua db module and
+	// SQL files, as for StatementStats; failures to refresh are logged.
+	RefreshStatistics StatisticsDialect
+
+	// ApprovalToken and VerifyApproval gate runs against a ProductionStore:
+	// VerifyApproval must accept the token before anything is changed.
+	ApprovalToken  string
+	VerifyApproval func(context.Context, Approval) error
+
+	// Schedule, if set, is consulted before Up and Down start. Outside a
+	// window they fail with ErrOutsideWindow, or with WaitForWindow wait
+	// until one opens.
+	Schedule      Schedule
+	WaitForWindow bool
+
+	// Pacer, if set, is waited on before each migration, e.g. to limit
+	// replication lag while catching up many versions on a busy primary,
+	// and between the statements a migration runs through a SQL file or
+	// the Lua db module. A Go migration's own use of its *sql.DB is not
+	// paced.
+	Pacer Pacer
 
-	HoldLockOnFailure bool
+	// LagProbe, if set, is checked before each migration. While it reports
+	// more than MaxLag the run pauses, probing again every LagPollInterval
+	// (5s if zero).
+	LagProbe        LagProbe
+	MaxLag          time.Duration
+	LagPollInterval time.Duration
+
+	// Middleware wraps Store, and StatusStore if set, for every operation
+	// the Migrator performs. It is applied once, on first use, so changes to
+	// Store or Middleware after that have no effect. The production check
+	// is always made against the unwrapped Store.
+	Middleware []StoreMiddleware
+
+	// Messages, if set, translates the run summaries written to Log. See
+	// Catalog.
+	Messages Catalog
+
+	// Deterministic makes what runs report depend only on the sources and
+	// the store, e.g. for the audits of validated environments: Result
+	// durations and AuditEvent times are left zero, and outputs measuring
+	// time, WithLuaProfile and Soak, are refused. golumn itself makes no
+	// network calls other than through the Store and the hooks, probes and
+	// sinks configured on the Migrator.
+	Deterministic bool
+
+	wrappedStore       wrappedStore
+	wrappedStatusStore wrappedStore
+	versionCache       versionCache
+	loadedSources      loadedSources
+	runLock            runLock
+	legacyLog          legacyLog
 }
 
-func (m *Migrator) log(f string, a ...any) {
-	if m.LogW != nil {
-		fmt.Fprintf(m.LogW, f, a...)
+// runLock serializes the runs of a single Migrator. Unlike the store lock it
+// is waited on rather than failing, since runs from one process are expected
+// to queue behind each other.
+type runLock struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (l *runLock) acquire(ctx context.Context) error {
+	l.once.Do(func() { l.ch = make(chan struct{}, 1) })
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-func (m *Migrator) debug(f string, a ...any) {
-	if m.DebugW != nil {
-		fmt.Fprintf(m.DebugW, f, a...)
+func (l *runLock) release() {
+	<-l.ch
+}
+
+// lock acquires the store lock, retrying on ErrLocked until LockWait has
+// elapsed. The store version must only ever be read after lock returns nil;
+// this is what keeps concurrent migrators from applying a version twice.
+func (m *Migrator) lock(ctx context.Context) error {
+	return m.acquire(ctx, m.store().Lock)
+}
+
+// acquire calls lockFn, retrying on ErrLocked until LockWait has elapsed and
+// reporting the wait every LockNoticeInterval.
+func (m *Migrator) acquire(ctx context.Context, lockFn func(context.Context) error) error {
+	start := time.Now()
+	deadline := start.Add(m.config(ctx).lockWait)
+	interval := m.LockNoticeInterval
+	if interval <= 0 {
+		interval = DefaultLockNoticeInterval
+	}
+	nextNotice := start.Add(interval)
+	for {
+		err := lockFn(ctx)
+		if !errors.Is(err, ErrLocked) || !time.Now().Before(deadline) {
+			return err
+		}
+		if now := time.Now(); !now.Before(nextNotice) {
+			m.noticeLockWait(ctx, now.Sub(start))
+			nextNotice = now.Add(interval)
+		}
+
+		m.logger().Debugf("version store locked, retrying in %s", lockRetryInterval)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
 	}
 }
 
@@ -38,13 +263,16 @@ func (m *Migrator) check() error {
 	var prev int64 = -1
 	seen := map[int64]bool{}
 
-	for _, migration := range m.Sources {
+	for _, migration := range m.migrations() {
 		if migration.Version < 0 {
 			return fmt.Errorf("negative migration version: %d", migration.Version)
 		}
 		if migration.Version < prev {
 			return fmt.Errorf("migration order: %d found after %d", migration.Version, prev)
 		}
+		if !m.AllowMixedVersions && prev > 0 && digits(migration.Version)-digits(prev) >= mixedVersionDigits {
+			return fmt.Errorf("suspicious version jump: %d found after %d (set AllowMixedVersions if versioning schemes are mixed intentionally)", migration.Version, prev)
+		}
 		if _, ok := seen[migration.Version]; ok {
 			return fmt.Errorf("duplicate migration version: %d", migration.Version)
 		} else {
@@ -56,157 +284,608 @@ func (m *Migrator) check() error {
 	return nil
 }
 
-func (m *Migrator) Up(ctx context.Context, to int64) (err error) {
+// Up applies pending migrations up to and including version to, or all of
+// them if to is Latest, or those up to the PinFile version if to is Pinned.
+// The pending set is computed from the store version read while holding the
+// store lock, so concurrent migrators sharing a store never apply the same
+// version twice. If the store implements VersionLister, pending versions
+// below the store version, e.g. ones skipped by UpOnly, are applied as well.
+// A to of -1, the former value of UpTargetLatest, is still read as Latest,
+// with a warning, until the next release rejects it with ErrInvalidTarget.
+func (m *Migrator) Up(ctx context.Context, to int64, opts ...RunOption) (res *Result, err error) {
+	ctx = withRunOptions(ctx, opts)
+	if to == Initial {
+		m.logger().Infof("warning: Up target -1 is deprecated, use Latest")
+		to = Latest
+	}
+	return m.up(ctx, to, func(target int64, pending []*Migration) ([]*Migration, error) {
+		var toApply []*Migration
+		for _, migration := range pending {
+			if migration.Version <= target {
+				toApply = append(toApply, migration)
+			}
+		}
+		return toApply, nil
+	})
+}
+
+// UpOnly applies exactly the given pending versions in ascending order,
+// leaving other pending migrations for a later run, e.g. to ship a hotfix
+// ahead of migrations still awaiting approval. Every version must be a known,
+// unapplied source. The store must implement VersionLister so that a later
+// Up can still apply the versions skipped over.
+func (m *Migrator) UpOnly(ctx context.Context, versions []int64, opts ...RunOption) (res *Result, err error) {
+	ctx = withRunOptions(ctx, opts)
+	if err := m.load(ctx); err != nil {
+		return &Result{Direction: DirectionUp, Version: Initial}, err
+	}
+	if err := m.validate(ctx); err != nil {
+		return &Result{Direction: DirectionUp, Version: Initial}, err
+	}
+	if _, ok := m.store().(VersionLister); !ok {
+		return &Result{Direction: DirectionUp, Version: Initial}, errors.New("UpOnly requires a version store implementing VersionLister")
+	}
+	if len(versions) == 0 {
+		return &Result{Direction: DirectionUp, Version: Initial}, errors.New("UpOnly requires at least one version")
+	}
+	want := slices.Sorted(slices.Values(versions))
+	for _, v := range want {
+		if !slices.ContainsFunc(m.migrations(), func(s *Migration) bool { return s.Version == v }) {
+			return &Result{Direction: DirectionUp, Version: Initial}, fmt.Errorf("missing migration for version: %d", v)
+		}
+	}
+
+	return m.up(ctx, want[len(want)-1], func(_ int64, pending []*Migration) ([]*Migration, error) {
+		toApply := make([]*Migration, 0, len(want))
+		for i, v := range want {
+			if i > 0 && v == want[i-1] {
+				return nil, fmt.Errorf("duplicate version: %d", v)
+			}
+			idx := slices.IndexFunc(pending, func(s *Migration) bool { return s.Version == v })
+			if idx == -1 {
+				return nil, fmt.Errorf("migration %d is already applied", v)
+			}
+			toApply = append(toApply, pending[idx])
+		}
+		return toApply, nil
+	})
+}
+
+// up runs an Up-direction migration, applying the migrations selected from
+// the pending set while holding the store lock. target is the highest
+// version that may be applied, and is what a production approval is for;
+// selectFn is passed it once Latest and Pinned are resolved.
+func (m *Migrator) up(ctx context.Context, target int64, selectFn func(target int64, pending []*Migration) ([]*Migration, error)) (*Result, error) {
+	return m.run(ctx, DirectionUp, func(ctx context.Context) error {
+		if err := m.checkTarget(DirectionUp, target); err != nil {
+			return err
+		}
+		if target == Latest {
+			target = MaxVersion(m.migrations())
+		}
+		var err error
+		if target, err = m.pinTarget(target); err != nil {
+			return err
+		}
+		return m.preflight(ctx, DirectionUp, target)
+	}, func(ctx context.Context, res *Result, hold *bool) error {
+		cfg := m.config(ctx)
+		remoteVersion, err := m.store().Version(ctx)
+		if err != nil {
+			if !errors.Is(err, ErrInitialVersion) {
+				return fmt.Errorf("failed to get version store state: %w", err)
+			}
+			remoteVersion = Initial
+		} else {
+			res.Version = remoteVersion
+		}
+		m.logger().Verbosef("remote version: %d", remoteVersion)
+
+		pending, err := m.pending(ctx, remoteVersion)
+		if err != nil {
+			return err
+		}
+		toApply, err := selectFn(target, pending)
+		if err != nil {
+			return err
+		}
+		if toApply, err = m.gate(ctx, toApply); err != nil {
+			return err
+		}
+		res.Skipped = len(pending) - len(toApply)
+		res.Plan = newPlan(DirectionUp, remoteVersion, target, toApply)
+		if err := m.checkCompat(ctx, res.Plan); err != nil {
+			return err
+		}
+		if cfg.dryRun {
+			if cfg.explain {
+				return m.explain(ctx, res.Plan, toApply)
+			}
+			return nil
+		}
+
+		if len(toApply) == 0 {
+			return nil
+		}
+
+		*hold = cfg.holdLockOnFailure
+		if err := m.apply(ctx, res, toApply); err != nil {
+			if errors.Is(err, ErrStopped) {
+				*hold = false
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// run runs body as a run in direction dir. It prepares ctx, takes the run
+// lock, loads and validates the sources and calls check, then configures the
+// connection, inits and locks the store for body, and logs a summary once the
+// run is done. body sets hold to keep the store lock if it fails, for
+// HoldLockOnFailure; otherwise run verifies the store and releases the lock.
+func (m *Migrator) run(ctx context.Context, dir Direction, check func(ctx context.Context) error, body func(ctx context.Context, res *Result, hold *bool) error) (res *Result, err error) {
+	if m.Hooks != nil {
+		ctx = WithHooks(ctx, m.Hooks)
+	}
+	if m.PartitionDialect != nil {
+		ctx = WithPartitionDialect(ctx, m.PartitionDialect)
+	}
+	if m.LuaLimits != nil {
+		ctx = WithLuaLimits(ctx, *m.LuaLimits)
+	}
+	if m.RefreshStatistics != nil {
+		ctx = withTouchedTables(ctx)
+	}
+	res = &Result{Direction: dir, Version: Initial, DryRun: m.config(ctx).dryRun}
+	start := time.Now()
 	defer func() {
+		m.invalidateVersionCache()
+		res.Duration = m.since(start)
 		if err == nil {
-			m.log("done")
+			m.logger().Infof("%s", res.Format(m.Messages))
+			err = m.afterSuccess(ctx, res)
 		}
 	}()
 
-	if err := m.check(); err != nil {
-		return fmt.Errorf("invalid sources: %w", err)
+	if err := m.runLock.acquire(ctx); err != nil {
+		return res, err
 	}
+	defer m.runLock.release()
 
-	if err := m.Store.Init(ctx); err != nil {
-		return fmt.Errorf("failed to init version store: %w", err)
+	if err := m.load(ctx); err != nil {
+		return res, err
+	}
+	if err := m.resolveLazy(ctx); err != nil {
+		return res, err
+	}
+	if err := m.validate(ctx); err != nil {
+		return res, err
 	}
-	if err := m.Store.Lock(ctx); err != nil {
-		return fmt.Errorf("failed to get version store lock: %w", err)
+	if err := m.checkEnv(ctx); err != nil {
+		return res, err
+	}
+	if err := check(ctx); err != nil {
+		return res, err
+	}
+
+	restoreConn, err := m.configureConn(ctx)
+	if err != nil {
+		return res, err
 	}
-	shouldRelease := true
 	defer func() {
-		if shouldRelease {
-			if rlErr := m.Store.Release(ctx); rlErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to release version store lock: %w", rlErr))
-			}
+		if rsErr := restoreConn(); rsErr != nil {
+			err = errors.Join(err, rsErr)
 		}
 	}()
 
-	var remoteVersion int64 = -1
-	remoteVersion, err = m.Store.Version(ctx)
-	if err != nil {
-		if !errors.Is(err, ErrInitialVersion) {
-			return fmt.Errorf("failed to get version store state: %w", err)
+	if err := m.store().Init(ctx); err != nil {
+		return res, fmt.Errorf("failed to init version store: %w", err)
+	}
+	if err := m.checkIdentity(ctx); err != nil {
+		return res, err
+	}
+	if err := m.lock(ctx); err != nil {
+		return res, fmt.Errorf("failed to get version store lock: %w", err)
+	}
+	hold := false
+	defer func() {
+		if hold && err != nil {
+			err = &dirtyError{err}
+			return
+		}
+		if err == nil {
+			err = m.verify(ctx, res)
+		}
+		if rlErr := m.release(ctx); rlErr != nil {
+			err = errors.Join(err, rlErr)
 		}
+	}()
+
+	return res, body(ctx, res, &hold)
+}
 
+// preflight runs the checks a run must pass before it touches the store. A
+// dry run skips them since it changes nothing.
+func (m *Migrator) preflight(ctx context.Context, dir Direction, target int64) error {
+	if m.config(ctx).dryRun {
+		return nil
+	}
+	if err := m.refuseAnonymizeProduction(); err != nil {
+		return err
 	}
-	m.log("remote version: %d", remoteVersion)
+	if err := m.approve(ctx, dir, target); err != nil {
+		return err
+	}
+	return m.awaitWindow(ctx)
+}
 
-	var toApply []*Migration
-	for _, migration := range m.Sources {
-		if migration.Version > remoteVersion && migration.Version <= to {
-			toApply = append(toApply, migration)
+// pending returns the sources not yet applied. Without a VersionLister only
+// versions above the store version are known to be unapplied.
+func (m *Migrator) pending(ctx context.Context, remoteVersion int64) ([]*Migration, error) {
+	var pending []*Migration
+	lister, ok := m.store().(VersionLister)
+	if !ok {
+		for _, migration := range m.migrations() {
+			if migration.Version > remoteVersion {
+				pending = append(pending, migration)
+			}
 		}
+		return pending, nil
 	}
 
-	if len(toApply) == 0 {
-		return nil
+	versions, err := lister.Versions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied versions: %w", err)
 	}
-
-	if m.HoldLockOnFailure {
-		shouldRelease = false
+	applied := make(map[int64]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	for _, migration := range m.migrations() {
+		if !applied[migration.Version] {
+			pending = append(pending, migration)
+		}
 	}
-	for _, migration := range m.Sources {
-		if migration.Version > remoteVersion && migration.Version <= to {
-			m.log("applying migration: %d", migration.Version)
-			if err := migration.Up(ctx, m.Store.DB()); err != nil {
-				return fmt.Errorf("failed to apply migration %d: %w", migration.Version, err)
+	return pending, nil
+}
+
+// apply runs toApply in order, recording each in the store. res.Version must
+// hold the store version before the run.
+func (m *Migrator) apply(ctx context.Context, res *Result, toApply []*Migration) error {
+	cfg := m.config(ctx)
+	base := res.Version
+	var applied []*Migration
+	for _, migration := range toApply {
+		if stopRequested(ctx) {
+			return ErrStopped
+		}
+		if err := m.pace(ctx); err != nil {
+			return err
+		}
+		if err := m.awaitLag(ctx); err != nil {
+			return err
+		}
+		m.logger().Infof("applying migration: %d", migration.Version)
+		stats, err := m.runCounted(ctx, migration, migration.Up)
+		if err != nil {
+			step := &StepError{Version: migration.Version, Name: migration.Name, Phase: PhaseApply, Err: err}
+			if cfg.autoRevertOnFailure {
+				return stepErrors(step, m.revert(ctx, res, base, applied, nil))
 			}
-			if err := m.Store.Insert(ctx, migration.Version); err != nil {
-				return fmt.Errorf("failed to insert migration %d in version store: %w", migration.Version, err)
+			return stepErrors(step)
+		}
+		if err := m.store().Insert(ctx, migration.Version); err != nil {
+			step := &StepError{Version: migration.Version, Name: migration.Name, Phase: PhaseInsert, Err: err}
+			if cfg.autoRevertOnFailure {
+				return stepErrors(step, m.revert(ctx, res, base, applied, migration))
 			}
+			return stepErrors(step)
+		}
+		applied = append(applied, migration)
+		res.Applied = append(res.Applied, migration.Version)
+		res.Version = max(res.Version, migration.Version)
+		m.recordStats(ctx, migration, stats)
+		if step := m.audit(ctx, DirectionUp, migration, stats); step != nil {
+			return stepErrors(step)
 		}
 	}
-
-	shouldRelease = true
 	return nil
 }
 
-func (m *Migrator) Down(ctx context.Context, to int64) (err error) {
-	defer func() {
-		if err == nil {
-			m.log("done")
+// runCounted calls fn, migration's Up or Down, within its MaxDuration,
+// counting the statements it runs.
+func (m *Migrator) runCounted(ctx context.Context, migration *Migration, fn func(context.Context, *sql.DB) error) (StatementStats, error) {
+	ctx, stats := withStatementStats(ctx)
+	ctx = m.withStatementPacer(ctx)
+	if target := storeTarget(m.store()); target != nil {
+		ctx = withTarget(ctx, target)
+	}
+	err := withMaxDuration(ctx, migration, func(ctx context.Context) error {
+		return safeCall(ctx, m.store().DB(), fn)
+	})
+	return *stats, err
+}
+
+// recordStats annotates the applied migration with stats for RecordStats.
+// The stats are informational, so failing to store them only logs.
+func (m *Migrator) recordStats(ctx context.Context, migration *Migration, stats StatementStats) {
+	if !m.RecordStats {
+		return
+	}
+	annotator, ok := m.store().(Annotator)
+	if !ok {
+		return
+	}
+	for key, value := range stats.annotations() {
+		if err := annotator.Annotate(ctx, migration.Version, key, value); err != nil {
+			m.logger().Infof("failed to record statement stats for migration %d: %v", migration.Version, err)
+			return
 		}
-	}()
+	}
+}
 
-	if err := m.check(); err != nil {
-		return fmt.Errorf("invalid sources: %w", err)
+func (m *Migrator) audit(ctx context.Context, dir Direction, migration *Migration, stats StatementStats) *StepError {
+	if m.Audit == nil {
+		return nil
+	}
+	ev := AuditEvent{Direction: dir, Version: migration.Version, Name: migration.Name, Owner: migration.Owner, Stats: stats}
+	if !m.Deterministic {
+		ev.Time = time.Now()
 	}
+	if err := m.Audit.Record(ctx, ev); err != nil {
+		return &StepError{Version: migration.Version, Name: migration.Name, Phase: PhaseAudit, Err: err}
+	}
+	return nil
+}
 
-	migrationCmpFunc := func(s *Migration, t int64) int {
-		if s.Version < t {
-			return -1
-		}
-		if s.Version > t {
-			return 1
-		}
+// since returns the time elapsed since start, or zero for a Deterministic
+// Migrator.
+func (m *Migrator) since(start time.Time) time.Duration {
+	if m.Deterministic {
 		return 0
 	}
+	return time.Since(start)
+}
 
-	_, ok := slices.BinarySearchFunc(m.Sources, to, migrationCmpFunc)
-	if !ok {
-		if to != -1 {
-			return fmt.Errorf("missing target version migration: %d", to)
-		}
+func (m *Migrator) afterSuccess(ctx context.Context, res *Result) error {
+	if res.DryRun {
+		return nil
+	}
+	m.refreshStatistics(ctx)
+	if m.AfterSuccess == nil {
+		return nil
+	}
+	if err := m.AfterSuccess(ctx, res); err != nil {
+		return fmt.Errorf("after success hook: %w", err)
 	}
+	return nil
+}
 
-	if err := m.Store.Init(ctx); err != nil {
-		return fmt.Errorf("failed to init version store: %w", err)
+// revert runs the Down funcs of migrations applied during a failed Up run in
+// reverse order. unrecorded, if non-nil, was applied but never inserted into
+// the version store, so it is reverted without a matching Remove. base is the
+// store version before the run.
+func (m *Migrator) revert(ctx context.Context, res *Result, base int64, applied []*Migration, unrecorded *Migration) *StepError {
+	if unrecorded != nil {
+		m.logger().Infof("reverting unrecorded migration: %d", unrecorded.Version)
+		if _, err := m.runCounted(ctx, unrecorded, unrecorded.Down); err != nil {
+			return &StepError{Version: unrecorded.Version, Name: unrecorded.Name, Phase: PhaseAutoRevert, Err: err}
+		}
 	}
-	if err := m.Store.Lock(ctx); err != nil {
-		return fmt.Errorf("failed to get version store lock: %w", err)
+	for i := len(applied) - 1; i >= 0; i-- {
+		migration := applied[i]
+		m.logger().Infof("reverting migration: %d", migration.Version)
+		stats, err := m.runCounted(ctx, migration, migration.Down)
+		if err != nil {
+			return &StepError{Version: migration.Version, Name: migration.Name, Phase: PhaseAutoRevert, Err: err}
+		}
+		if err := m.store().Remove(ctx, migration.Version); err != nil {
+			return &StepError{Version: migration.Version, Name: migration.Name, Phase: PhaseRemove, Err: err}
+		}
+		res.Reverted = append(res.Reverted, migration.Version)
+		res.Version = base
+		for _, prev := range applied[:i] {
+			res.Version = max(res.Version, prev.Version)
+		}
+		if step := m.audit(ctx, DirectionDown, migration, stats); step != nil {
+			return step
+		}
 	}
-	shouldRelease := true
-	defer func() {
-		if shouldRelease {
-			if rlErr := m.Store.Release(ctx); rlErr != nil {
-				err = errors.Join(err, fmt.Errorf("failed to release version store lock: %w", rlErr))
+	return nil
+}
+
+// DownOnly reverts the single applied version, leaving later migrations in
+// place, e.g. to remove a bad index without rolling back everything after
+// it. It refuses if any later applied migration lists version in DependsOn.
+// The store must implement VersionLister.
+func (m *Migrator) DownOnly(ctx context.Context, version int64, opts ...RunOption) (*Result, error) {
+	var (
+		lister    VersionLister
+		sources   []*Migration
+		idx       int
+		migration *Migration
+	)
+	return m.run(withRunOptions(ctx, opts), DirectionDown, func(ctx context.Context) error {
+		var ok bool
+		if lister, ok = m.store().(VersionLister); !ok {
+			return errors.New("DownOnly requires a version store implementing VersionLister")
+		}
+		sources = m.migrations()
+		idx = slices.IndexFunc(sources, func(s *Migration) bool { return s.Version == version })
+		if idx == -1 {
+			return fmt.Errorf("missing migration for version: %d", version)
+		}
+		migration = sources[idx]
+		return m.preflight(ctx, DirectionDown, version)
+	}, func(ctx context.Context, res *Result, hold *bool) error {
+		cfg := m.config(ctx)
+		versions, err := lister.Versions(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list applied versions: %w", err)
+		}
+		if len(versions) > 0 {
+			res.Version = slices.Max(versions)
+		}
+		if !slices.Contains(versions, version) {
+			return fmt.Errorf("migration %d is not applied", version)
+		}
+		for _, later := range sources[idx+1:] {
+			if slices.Contains(versions, later.Version) && slices.Contains(later.DependsOn, version) {
+				return fmt.Errorf("migration %d depends on %d and is still applied", later.Version, version)
 			}
 		}
-	}()
-
-	var remoteVersion int64
-
-	remoteVersion, err = m.Store.Version(ctx)
-	if err != nil {
-		if errors.Is(err, ErrInitialVersion) {
+		for _, source := range sources {
+			if source != migration && slices.Contains(versions, source.Version) {
+				res.Skipped++
+			}
+		}
+		res.Plan = newPlan(DirectionDown, res.Version, version, []*Migration{migration})
+		if err := m.checkCompat(ctx, res.Plan); err != nil {
+			return err
+		}
+		if cfg.dryRun {
+			if cfg.explain {
+				return m.explain(ctx, res.Plan, []*Migration{migration})
+			}
 			return nil
 		}
-		return fmt.Errorf("failed to get version store state: %w", err)
-	}
-	m.log("remote version: %d", remoteVersion)
 
-	if m.HoldLockOnFailure {
-		shouldRelease = false
-	}
-	for {
-		if remoteVersion <= to {
-			break
+		if err := m.pace(ctx); err != nil {
+			return err
 		}
-
-		idx, ok := slices.BinarySearchFunc(m.Sources, remoteVersion, migrationCmpFunc)
-		if !ok {
-			return fmt.Errorf("missing remote version migration: %d", remoteVersion)
+		if err := m.awaitLag(ctx); err != nil {
+			return err
+		}
+		*hold = cfg.holdLockOnFailure
+		m.logger().Infof("reverting migration: %d", version)
+		stats, err := m.runCounted(ctx, migration, migration.Down)
+		if err != nil {
+			return stepErrors(&StepError{Version: version, Name: migration.Name, Phase: PhaseRevert, Err: err})
+		}
+		if err := m.store().Remove(ctx, version); err != nil {
+			return stepErrors(&StepError{Version: version, Name: migration.Name, Phase: PhaseRemove, Err: err})
+		}
+		res.Reverted = append(res.Reverted, version)
+		res.Version = Initial
+		for _, v := range versions {
+			if v != version {
+				res.Version = max(res.Version, v)
+			}
+		}
+		if step := m.audit(ctx, DirectionDown, migration, stats); step != nil {
+			return stepErrors(step)
 		}
 
-		migration := m.Sources[idx]
-		m.log("reverting migration: %d", migration.Version)
-		if err := migration.Down(ctx, m.Store.DB()); err != nil {
-			return fmt.Errorf("failed to revert migration %d: %w", migration.Version, err)
+		return nil
+	})
+}
+
+// Down reverts applied migrations above version to, or all of them if to is
+// Initial.
+func (m *Migrator) Down(ctx context.Context, to int64, opts ...RunOption) (*Result, error) {
+	return m.run(withRunOptions(ctx, opts), DirectionDown, func(ctx context.Context) error {
+		if err := m.checkTarget(DirectionDown, to); err != nil {
+			return err
 		}
-		if err := m.Store.Remove(ctx, migration.Version); err != nil {
-			return fmt.Errorf("failed to delete migration %d from version store: %w", migration.Version, err)
+		return m.preflight(ctx, DirectionDown, to)
+	}, func(ctx context.Context, res *Result, hold *bool) error {
+		cfg := m.config(ctx)
+		migrationCmpFunc := func(s *Migration, t int64) int {
+			if s.Version < t {
+				return -1
+			}
+			if s.Version > t {
+				return 1
+			}
+			return 0
 		}
+		sources := m.migrations()
 
-		remoteVersion, err = m.Store.Version(ctx)
+		remoteVersion, err := m.store().Version(ctx)
 		if err != nil {
 			if errors.Is(err, ErrInitialVersion) {
+				// Nothing is applied, so there is nothing to revert.
+				res.Plan = newPlan(DirectionDown, Initial, to, nil)
 				return nil
 			}
 			return fmt.Errorf("failed to get version store state: %w", err)
 		}
-	}
+		res.Version = remoteVersion
+		m.logger().Verbosef("remote version: %d", remoteVersion)
 
-	shouldRelease = true
-	return nil
+		if _, ok := slices.BinarySearchFunc(sources, remoteVersion, migrationCmpFunc); !ok && remoteVersion > to {
+			return driftError(remoteVersion)
+		}
+		pending, err := m.pending(ctx, remoteVersion)
+		if err != nil {
+			return err
+		}
+		var toRevert []*Migration
+		for i := len(sources) - 1; i >= 0; i-- {
+			if v := sources[i].Version; v > to && v <= remoteVersion && !slices.Contains(pending, sources[i]) {
+				toRevert = append(toRevert, sources[i])
+			}
+		}
+		res.Skipped = len(sources) - len(pending) - len(toRevert)
+		res.Plan = newPlan(DirectionDown, remoteVersion, to, toRevert)
+		if err := m.checkCompat(ctx, res.Plan); err != nil {
+			return err
+		}
+		if cfg.dryRun {
+			if cfg.explain {
+				return m.explain(ctx, res.Plan, toRevert)
+			}
+			return nil
+		}
+
+		*hold = cfg.holdLockOnFailure
+		for {
+			if remoteVersion <= to {
+				break
+			}
+
+			if stopRequested(ctx) {
+				*hold = false
+				return ErrStopped
+			}
+			idx, ok := slices.BinarySearchFunc(sources, remoteVersion, migrationCmpFunc)
+			if !ok {
+				return driftError(remoteVersion)
+			}
+			if err := m.pace(ctx); err != nil {
+				return err
+			}
+			if err := m.awaitLag(ctx); err != nil {
+				return err
+			}
+
+			migration := sources[idx]
+			m.logger().Infof("reverting migration: %d", migration.Version)
+			stats, err := m.runCounted(ctx, migration, migration.Down)
+			if err != nil {
+				return stepErrors(&StepError{Version: migration.Version, Name: migration.Name, Phase: PhaseRevert, Err: err})
+			}
+			if err := m.store().Remove(ctx, migration.Version); err != nil {
+				return stepErrors(&StepError{Version: migration.Version, Name: migration.Name, Phase: PhaseRemove, Err: err})
+			}
+			res.Reverted = append(res.Reverted, migration.Version)
+			if step := m.audit(ctx, DirectionDown, migration, stats); step != nil {
+				return stepErrors(step)
+			}
+
+			remoteVersion, err = m.store().Version(ctx)
+			if err != nil {
+				if errors.Is(err, ErrInitialVersion) {
+					res.Version = Initial
+					return nil
+				}
+				return fmt.Errorf("failed to get version store state: %w", err)
+			}
+			res.Version = remoteVersion
+		}
+
+		return nil
+	})
 }
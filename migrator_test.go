@@ -1,13 +1,17 @@
 package golumn_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"slices"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/jonathonwebb/golumn"
 )
@@ -39,12 +43,12 @@ func defaultInitFunc(_ context.Context, _ *fakeStore) error {
 }
 
 func defaultLockFunc(_ context.Context, s *fakeStore) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.locked {
 		return golumn.ErrLocked
 	}
-	s.mu.Lock()
 	s.locked = true
-	s.mu.Unlock()
 	return nil
 }
 
@@ -170,7 +174,7 @@ func TestMigrator_Up(t *testing.T) {
 			name:            "no_migrations_no_target",
 			initialVersions: []int64{},
 			migrations:      []*golumn.Migration{},
-			target:          0,
+			target:          golumn.Latest,
 			wantVersions:    []int64{},
 			wantApplied:     []int64{},
 		},
@@ -408,7 +412,7 @@ func TestMigrator_Up(t *testing.T) {
 				HoldLockOnFailure: tt.holdLockOnFailure,
 			}
 
-			err := migrator.Up(context.Background(), tt.target)
+			_, err := migrator.Up(context.Background(), tt.target)
 
 			if tt.wantErr && err == nil {
 				t.Errorf("expected error but got none")
@@ -485,10 +489,18 @@ func TestMigrator_Down(t *testing.T) {
 			wantReverted:    []int64{},
 		},
 		{
-			name:            "target_below_zero_but_not_negative_one",
+			name:            "target_zero_below_first_source",
 			initialVersions: []int64{1, 2, 3},
 			migrations:      createMigrations(1, 2, 3),
 			target:          0,
+			wantVersions:    []int64{},
+			wantReverted:    []int64{3, 2, 1},
+		},
+		{
+			name:            "target_below_initial",
+			initialVersions: []int64{1, 2, 3},
+			migrations:      createMigrations(1, 2, 3),
+			target:          -2,
 			wantErr:         true,
 			wantVersions:    []int64{1, 2, 3},
 			wantReverted:    []int64{},
@@ -587,6 +599,15 @@ func TestMigrator_Down(t *testing.T) {
 			wantReverted:      []int64{3},
 			wantLocked:        true,
 		},
+		{
+			name:              "revert_all_hold_lock",
+			initialVersions:   []int64{1, 2},
+			migrations:        createMigrations(1, 2),
+			target:            golumn.Initial,
+			holdLockOnFailure: true,
+			wantVersions:      []int64{},
+			wantReverted:      []int64{2, 1},
+		},
 	}
 
 	for _, tt := range tests {
@@ -604,7 +625,7 @@ func TestMigrator_Down(t *testing.T) {
 				HoldLockOnFailure: tt.holdLockOnFailure,
 			}
 
-			err := migrator.Down(context.Background(), tt.target)
+			_, err := migrator.Down(context.Background(), tt.target)
 
 			if tt.wantErr && err == nil {
 				t.Errorf("expected error but got none")
@@ -626,6 +647,795 @@ func TestMigrator_Down(t *testing.T) {
 	}
 }
 
+func TestMigrator_AutoRevertOnFailure(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialVersions []int64
+		migrations      []*golumn.Migration
+		storeConfig     func(*fakeStore)
+
+		wantVersions []int64
+		wantReverted []int64
+		wantDowns    []int64
+	}{
+		{
+			name:            "up_error_reverts_run",
+			initialVersions: []int64{1},
+			migrations: []*golumn.Migration{
+				{Version: 1, UpFunc: noopMigration, DownFunc: noopMigration},
+				{Version: 2, UpFunc: noopMigration, DownFunc: noopMigration},
+				{Version: 3, UpFunc: noopMigration, DownFunc: noopMigration},
+				{Version: 4, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+			},
+			wantVersions: []int64{1},
+			wantReverted: []int64{3, 2},
+			wantDowns:    []int64{3, 2},
+		},
+		{
+			name:            "first_migration_fails",
+			initialVersions: []int64{},
+			migrations: []*golumn.Migration{
+				{Version: 1, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+				{Version: 2, UpFunc: noopMigration, DownFunc: noopMigration},
+			},
+			wantVersions: []int64{},
+			wantReverted: []int64{},
+			wantDowns:    []int64{},
+		},
+		{
+			name:            "insert_error_reverts_unrecorded",
+			initialVersions: []int64{},
+			migrations:      createMigrations(1, 2, 3),
+			storeConfig: func(s *fakeStore) {
+				s.insertFunc = func(ctx context.Context, v int64, s *fakeStore) error {
+					if v == 2 {
+						return fmt.Errorf("insert error")
+					}
+					return defaultInsertFunc(ctx, v, s)
+				}
+			},
+			wantVersions: []int64{},
+			wantReverted: []int64{1},
+			wantDowns:    []int64{2, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{
+				versions: slices.Clone(tt.initialVersions),
+			}
+			if tt.storeConfig != nil {
+				tt.storeConfig(store)
+			}
+
+			downs := []int64{}
+			for _, migration := range tt.migrations {
+				down := migration.DownFunc
+				v := migration.Version
+				migration.DownFunc = func(ctx context.Context, db *sql.DB) error {
+					downs = append(downs, v)
+					return down(ctx, db)
+				}
+			}
+
+			migrator := &golumn.Migrator{
+				Store:               store,
+				Sources:             tt.migrations,
+				AutoRevertOnFailure: true,
+			}
+
+			if _, err := migrator.Up(context.Background(), golumn.Latest); err == nil {
+				t.Fatal("expected error but got none")
+			}
+
+			if !slices.Equal(tt.wantVersions, store.versions) {
+				t.Errorf("versions mismatch\nwant: %v\ngot:  %v", tt.wantVersions, store.versions)
+			}
+			if !slices.Equal(tt.wantReverted, store.reverted) {
+				t.Errorf("reverted mismatch\nwant: %v\ngot:  %v", tt.wantReverted, store.reverted)
+			}
+			if !slices.Equal(tt.wantDowns, downs) {
+				t.Errorf("down funcs mismatch\nwant: %v\ngot:  %v", tt.wantDowns, downs)
+			}
+			if store.locked {
+				t.Error("lock should be released after auto-revert")
+			}
+		})
+	}
+
+	t.Run("revert_error_is_joined", func(t *testing.T) {
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{
+			Store: store,
+			Sources: []*golumn.Migration{
+				{Version: 1, UpFunc: noopMigration, DownFunc: errorMigration("down error")},
+				{Version: 2, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+			},
+			AutoRevertOnFailure: true,
+		}
+
+		_, err := migrator.Up(context.Background(), 2)
+		if err == nil {
+			t.Fatal("expected error but got none")
+		}
+		for _, msg := range []string{"up error", "down error"} {
+			if !strings.Contains(err.Error(), msg) {
+				t.Errorf("expected error to contain %q, got: %v", msg, err)
+			}
+		}
+		if !slices.Equal([]int64{1}, store.versions) {
+			t.Errorf("versions mismatch\nwant: %v\ngot:  %v", []int64{1}, store.versions)
+		}
+	})
+}
+
+func TestMigrator_Result(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialVersions []int64
+		migrations      []*golumn.Migration
+		down            bool
+		target          int64
+		autoRevert      bool
+
+		wantErr      bool
+		wantApplied  []int64
+		wantReverted []int64
+		wantSkipped  int
+		wantVersion  int64
+	}{
+		{
+			name:        "up_fresh",
+			migrations:  createMigrations(1, 2, 3),
+			target:      2,
+			wantApplied: []int64{1, 2},
+			wantSkipped: 1,
+			wantVersion: 2,
+		},
+		{
+			name:            "up_nothing_pending",
+			initialVersions: []int64{1, 2},
+			migrations:      createMigrations(1, 2),
+			target:          2,
+			wantSkipped:     0,
+			wantVersion:     2,
+		},
+		{
+			name:            "up_above_applied",
+			initialVersions: []int64{1},
+			migrations:      createMigrations(1, 2, 3),
+			target:          2,
+			wantApplied:     []int64{2},
+			wantSkipped:     1,
+			wantVersion:     2,
+		},
+		{
+			name:        "up_empty",
+			migrations:  createMigrations(1),
+			target:      0,
+			wantSkipped: 1,
+			wantVersion: -1,
+		},
+		{
+			name: "up_partial_failure",
+			migrations: []*golumn.Migration{
+				{Version: 1, UpFunc: noopMigration, DownFunc: noopMigration},
+				{Version: 2, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+			},
+			target:      2,
+			wantErr:     true,
+			wantApplied: []int64{1},
+			wantSkipped: 0,
+			wantVersion: 1,
+		},
+		{
+			name: "up_auto_revert",
+			migrations: []*golumn.Migration{
+				{Version: 1, UpFunc: noopMigration, DownFunc: noopMigration},
+				{Version: 2, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+			},
+			target:       2,
+			autoRevert:   true,
+			wantErr:      true,
+			wantApplied:  []int64{1},
+			wantReverted: []int64{1},
+			wantSkipped:  0,
+			wantVersion:  -1,
+		},
+		{
+			name:            "down_partial",
+			initialVersions: []int64{1, 2, 3},
+			migrations:      createMigrations(1, 2, 3),
+			down:            true,
+			target:          1,
+			wantReverted:    []int64{3, 2},
+			wantSkipped:     1,
+			wantVersion:     1,
+		},
+		{
+			name:            "down_below_pending",
+			initialVersions: []int64{1, 2},
+			migrations:      createMigrations(1, 2, 3),
+			down:            true,
+			target:          1,
+			wantReverted:    []int64{2},
+			wantSkipped:     1,
+			wantVersion:     1,
+		},
+		{
+			name:            "down_all",
+			initialVersions: []int64{1, 2},
+			migrations:      createMigrations(1, 2),
+			down:            true,
+			target:          -1,
+			wantReverted:    []int64{2, 1},
+			wantSkipped:     0,
+			wantVersion:     -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{versions: slices.Clone(tt.initialVersions)}
+			migrator := &golumn.Migrator{
+				Store:               store,
+				Sources:             tt.migrations,
+				AutoRevertOnFailure: tt.autoRevert,
+			}
+
+			var res *golumn.Result
+			var err error
+			if tt.down {
+				res, err = migrator.Down(context.Background(), tt.target)
+			} else {
+				res, err = migrator.Up(context.Background(), tt.target)
+			}
+
+			if tt.wantErr && err == nil {
+				t.Errorf("expected error but got none")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected no error but got: %v", err)
+			}
+			if res == nil {
+				t.Fatal("expected result")
+			}
+
+			if !slices.Equal(tt.wantApplied, res.Applied) {
+				t.Errorf("applied mismatch\nwant: %v\ngot:  %v", tt.wantApplied, res.Applied)
+			}
+			if !slices.Equal(tt.wantReverted, res.Reverted) {
+				t.Errorf("reverted mismatch\nwant: %v\ngot:  %v", tt.wantReverted, res.Reverted)
+			}
+			if res.Skipped != tt.wantSkipped {
+				t.Errorf("skipped: want %d, got %d", tt.wantSkipped, res.Skipped)
+			}
+			if res.Version != tt.wantVersion {
+				t.Errorf("version: want %d, got %d", tt.wantVersion, res.Version)
+			}
+			if res.Duration <= 0 {
+				t.Errorf("expected positive duration, got %s", res.Duration)
+			}
+		})
+	}
+}
+
+func TestMigrator_AfterSuccess(t *testing.T) {
+	t.Run("called_after_release", func(t *testing.T) {
+		store := &fakeStore{}
+		var got []*golumn.Result
+		migrator := &golumn.Migrator{
+			Store:   store,
+			Sources: createMigrations(1, 2),
+			AfterSuccess: func(_ context.Context, res *golumn.Result) error {
+				if store.locked {
+					t.Error("expected lock to be released before AfterSuccess")
+				}
+				got = append(got, res)
+				return nil
+			},
+		}
+
+		upRes, err := migrator.Up(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+		downRes, err := migrator.Down(context.Background(), golumn.DownTargetInitial)
+		if err != nil {
+			t.Fatalf("down failed: %v", err)
+		}
+		if len(got) != 2 || got[0] != upRes || got[1] != downRes {
+			t.Fatalf("expected AfterSuccess with each run's result, got %v", got)
+		}
+	})
+
+	t.Run("not_called_on_failure", func(t *testing.T) {
+		called := false
+		migrator := &golumn.Migrator{
+			Store: &fakeStore{},
+			Sources: []*golumn.Migration{
+				{Version: 1, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+			},
+			AfterSuccess: func(context.Context, *golumn.Result) error {
+				called = true
+				return nil
+			},
+		}
+		if _, err := migrator.Up(context.Background(), 1); err == nil {
+			t.Fatal("expected error")
+		}
+		if called {
+			t.Error("AfterSuccess called for failed run")
+		}
+	})
+
+	t.Run("error_returned", func(t *testing.T) {
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{
+			Store:   store,
+			Sources: createMigrations(1),
+			AfterSuccess: func(context.Context, *golumn.Result) error {
+				return errors.New("publish failed")
+			},
+		}
+		res, err := migrator.Up(context.Background(), 1)
+		if err == nil || !strings.Contains(err.Error(), "publish failed") {
+			t.Fatalf("expected hook error, got %v", err)
+		}
+		if !slices.Equal(res.Applied, []int64{1}) || !slices.Equal(store.applied, []int64{1}) {
+			t.Errorf("expected migration to stay applied, got result %v and store %v", res.Applied, store.applied)
+		}
+	})
+}
+
+func TestMigrator_Audit(t *testing.T) {
+	t.Run("records_inserts_and_removes", func(t *testing.T) {
+		var events []golumn.AuditEvent
+		migrator := &golumn.Migrator{
+			Store:   &fakeStore{},
+			Sources: createMigrations(1, 2),
+			Audit: golumn.AuditFunc(func(_ context.Context, ev golumn.AuditEvent) error {
+				events = append(events, ev)
+				return nil
+			}),
+		}
+
+		if _, err := migrator.Up(context.Background(), 2); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+		if _, err := migrator.Down(context.Background(), golumn.DownTargetInitial); err != nil {
+			t.Fatalf("down failed: %v", err)
+		}
+
+		var got []string
+		for _, ev := range events {
+			if ev.Time.IsZero() {
+				t.Errorf("event for version %d has no time", ev.Version)
+			}
+			got = append(got, fmt.Sprintf("%s:%d", ev.Direction, ev.Version))
+		}
+		want := []string{"up:1", "up:2", "down:2", "down:1"}
+		if !slices.Equal(got, want) {
+			t.Errorf("events mismatch\nwant: %v\ngot:  %v", want, got)
+		}
+	})
+
+	t.Run("records_auto_revert", func(t *testing.T) {
+		var events []string
+		migrator := &golumn.Migrator{
+			Store: &fakeStore{},
+			Sources: []*golumn.Migration{
+				{Version: 1, UpFunc: noopMigration, DownFunc: noopMigration},
+				{Version: 2, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+			},
+			AutoRevertOnFailure: true,
+			Audit: golumn.AuditFunc(func(_ context.Context, ev golumn.AuditEvent) error {
+				events = append(events, fmt.Sprintf("%s:%d", ev.Direction, ev.Version))
+				return nil
+			}),
+		}
+		if _, err := migrator.Up(context.Background(), 2); err == nil {
+			t.Fatal("expected error")
+		}
+		if want := []string{"up:1", "down:1"}; !slices.Equal(events, want) {
+			t.Errorf("events mismatch\nwant: %v\ngot:  %v", want, events)
+		}
+	})
+
+	t.Run("failure_stops_run", func(t *testing.T) {
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{
+			Store:   store,
+			Sources: createMigrations(1, 2),
+			Audit: golumn.AuditFunc(func(context.Context, golumn.AuditEvent) error {
+				return errors.New("sink unavailable")
+			}),
+		}
+		res, err := migrator.Up(context.Background(), 2)
+		if err == nil || !strings.Contains(err.Error(), "sink unavailable") {
+			t.Fatalf("expected audit error, got %v", err)
+		}
+		if !slices.Equal(store.applied, []int64{1}) || !slices.Equal(res.Applied, []int64{1}) {
+			t.Errorf("expected run to stop after version 1, got store %v and result %v", store.applied, res.Applied)
+		}
+	})
+}
+
+func TestMigrator_Deterministic(t *testing.T) {
+	var events []golumn.AuditEvent
+	migrator := &golumn.Migrator{
+		Store:   &fakeStore{},
+		Sources: createMigrations(1, 2),
+		Audit: golumn.AuditFunc(func(_ context.Context, ev golumn.AuditEvent) error {
+			events = append(events, ev)
+			return nil
+		}),
+		Deterministic: true,
+	}
+
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if res.Duration != 0 || res.String() != "up: 2 applied, 0 skipped in 0s, version 2" {
+		t.Errorf("expected a result without duration, got %q", res)
+	}
+	for _, ev := range events {
+		if !ev.Time.IsZero() {
+			t.Errorf("event for version %d has time %s", ev.Version, ev.Time)
+		}
+	}
+
+	if _, err := migrator.Down(context.Background(), golumn.Initial, golumn.WithLuaProfile(io.Discard)); err == nil || !strings.Contains(err.Error(), "not deterministic") {
+		t.Errorf("expected WithLuaProfile to be refused, got %v", err)
+	}
+	if _, err := migrator.Soak(context.Background(), golumn.SoakOptions{Cycles: 1}); err == nil {
+		t.Error("expected Soak to be refused")
+	}
+}
+
+type productionStore struct {
+	*fakeStore
+	production bool
+}
+
+func (s productionStore) IsProduction() bool { return s.production }
+
+func TestMigrator_Approval(t *testing.T) {
+	const token = "approved-by-release-manager"
+	verify := func(_ context.Context, a golumn.Approval) error {
+		if a.Token != token {
+			return errors.New("unknown token")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name       string
+		production bool
+		token      string
+		verify     func(context.Context, golumn.Approval) error
+		wantErr    string
+	}{
+		{name: "not_production", token: "", verify: nil},
+		{name: "approved", production: true, token: token, verify: verify},
+		{name: "missing_token", production: true, verify: verify, wantErr: golumn.ErrApprovalRequired.Error()},
+		{name: "missing_verifier", production: true, token: token, wantErr: "no approval verifier configured"},
+		{name: "rejected", production: true, token: "forged", verify: verify, wantErr: "approval rejected: unknown token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{}
+			migrator := &golumn.Migrator{
+				Store:          productionStore{fakeStore: store, production: tt.production},
+				Sources:        createMigrations(1),
+				ApprovalToken:  tt.token,
+				VerifyApproval: tt.verify,
+			}
+
+			for _, run := range []func() error{
+				func() error { _, err := migrator.Up(context.Background(), 1); return err },
+				func() error { _, err := migrator.Down(context.Background(), golumn.DownTargetInitial); return err },
+			} {
+				err := run()
+				if tt.wantErr == "" {
+					if err != nil {
+						t.Fatalf("expected no error, got %v", err)
+					}
+					continue
+				}
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if store.initCalls != 0 || store.lockCalls != 0 {
+					t.Errorf("expected store to be untouched, got %d init and %d lock calls", store.initCalls, store.lockCalls)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrator_ApprovalDetails(t *testing.T) {
+	var got []golumn.Approval
+	migrator := &golumn.Migrator{
+		Store:         productionStore{fakeStore: &fakeStore{}, production: true},
+		Sources:       createMigrations(1, 2),
+		ApprovalToken: "t",
+		VerifyApproval: func(_ context.Context, a golumn.Approval) error {
+			got = append(got, a)
+			return nil
+		},
+	}
+	if _, err := migrator.Up(context.Background(), 2); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if _, err := migrator.Down(context.Background(), 1); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	want := []golumn.Approval{
+		{Direction: golumn.DirectionUp, Target: 2, Token: "t"},
+		{Direction: golumn.DirectionDown, Target: 1, Token: "t"},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("approvals mismatch\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+// listingStore is a fakeStore that tracks versions as a set, so that they can
+// be inserted out of order, and implements golumn.VersionLister.
+type listingStore struct {
+	*fakeStore
+}
+
+func newListingStore(versions ...int64) listingStore {
+	s := &fakeStore{versions: versions}
+	s.versionFunc = func(_ context.Context, s *fakeStore) (int64, error) {
+		if len(s.versions) == 0 {
+			return 0, golumn.ErrInitialVersion
+		}
+		return slices.Max(s.versions), nil
+	}
+	s.removeFunc = func(_ context.Context, v int64, s *fakeStore) error {
+		s.versions = slices.DeleteFunc(s.versions, func(x int64) bool { return x == v })
+		s.reverted = append(s.reverted, v)
+		return nil
+	}
+	return listingStore{s}
+}
+
+func (s listingStore) Versions(context.Context) ([]int64, error) {
+	return slices.Sorted(slices.Values(s.versions)), nil
+}
+
+func TestMigrator_UpOnly(t *testing.T) {
+	store := newListingStore(1, 2)
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2, 3, 4, 5)}
+
+	res, err := migrator.UpOnly(context.Background(), []int64{4})
+	if err != nil {
+		t.Fatalf("up only failed: %v", err)
+	}
+	if !slices.Equal(res.Applied, []int64{4}) || res.Version != 4 || res.Skipped != 2 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+
+	res, err = migrator.Up(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if !slices.Equal(res.Applied, []int64{3, 5}) || res.Version != 5 {
+		t.Errorf("expected up to fill the gap, got %+v", res)
+	}
+	if !slices.Equal(store.applied, []int64{4, 3, 5}) {
+		t.Errorf("unexpected apply order: %v", store.applied)
+	}
+}
+
+func TestMigrator_UpOnlyOrdering(t *testing.T) {
+	store := newListingStore()
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2, 3)}
+
+	if _, err := migrator.UpOnly(context.Background(), []int64{3, 1}); err != nil {
+		t.Fatalf("up only failed: %v", err)
+	}
+	if !slices.Equal(store.applied, []int64{1, 3}) {
+		t.Errorf("expected versions applied in ascending order, got %v", store.applied)
+	}
+}
+
+func TestMigrator_UpOnlyAutoRevert(t *testing.T) {
+	store := newListingStore(5)
+	migrator := &golumn.Migrator{
+		Store: store,
+		Sources: []*golumn.Migration{
+			{Version: 3, UpFunc: noopMigration, DownFunc: noopMigration},
+			{Version: 4, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+			{Version: 5, UpFunc: noopMigration, DownFunc: noopMigration},
+		},
+		AutoRevertOnFailure: true,
+	}
+
+	res, err := migrator.UpOnly(context.Background(), []int64{3, 4})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !slices.Equal(res.Reverted, []int64{3}) || res.Version != 5 {
+		t.Errorf("expected version 3 reverted back to version 5, got %+v", res)
+	}
+}
+
+func TestMigrator_UpOnlyErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		store    golumn.Store
+		versions []int64
+		wantErr  string
+	}{
+		{"not_lister", &fakeStore{}, []int64{3}, "requires a version store implementing VersionLister"},
+		{"empty", newListingStore(), nil, "at least one version"},
+		{"already_applied", newListingStore(1, 2), []int64{2, 3}, "migration 2 is already applied"},
+		{"unknown", newListingStore(), []int64{9}, "missing migration for version: 9"},
+		{"duplicate", newListingStore(), []int64{3, 3}, "duplicate version: 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator := &golumn.Migrator{Store: tt.store, Sources: createMigrations(1, 2, 3)}
+			res, err := migrator.UpOnly(context.Background(), tt.versions)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if len(res.Applied) != 0 {
+				t.Errorf("expected nothing applied, got %v", res.Applied)
+			}
+		})
+	}
+}
+
+func TestMigrator_DownOnly(t *testing.T) {
+	sources := func() []*golumn.Migration {
+		migrations := createMigrations(1, 2, 3, 4)
+		migrations[2].DependsOn = []int64{1}
+		return migrations
+	}
+
+	t.Run("reverts_single_version", func(t *testing.T) {
+		store := newListingStore(1, 2, 3, 4)
+		migrator := &golumn.Migrator{Store: store, Sources: sources()}
+
+		res, err := migrator.DownOnly(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("down only failed: %v", err)
+		}
+		if !slices.Equal(res.Reverted, []int64{2}) || res.Version != 4 || res.Skipped != 3 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		if want := []int64{1, 3, 4}; !slices.Equal(store.versions, want) {
+			t.Errorf("expected versions %v, got %v", want, store.versions)
+		}
+	})
+
+	t.Run("latest_version", func(t *testing.T) {
+		store := newListingStore(1, 2)
+		migrator := &golumn.Migrator{Store: store, Sources: sources()}
+
+		res, err := migrator.DownOnly(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("down only failed: %v", err)
+		}
+		if res.Version != 1 {
+			t.Errorf("expected version 1, got %d", res.Version)
+		}
+	})
+
+	t.Run("dependent_applied", func(t *testing.T) {
+		store := newListingStore(1, 2, 3)
+		migrator := &golumn.Migrator{Store: store, Sources: sources()}
+
+		_, err := migrator.DownOnly(context.Background(), 1)
+		if err == nil || !strings.Contains(err.Error(), "migration 3 depends on 1") {
+			t.Fatalf("expected dependency error, got %v", err)
+		}
+		if len(store.reverted) != 0 {
+			t.Errorf("expected nothing reverted, got %v", store.reverted)
+		}
+	})
+
+	t.Run("dependent_not_applied", func(t *testing.T) {
+		store := newListingStore(1, 2, 4)
+		migrator := &golumn.Migrator{Store: store, Sources: sources()}
+
+		if _, err := migrator.DownOnly(context.Background(), 1); err != nil {
+			t.Fatalf("down only failed: %v", err)
+		}
+	})
+
+	for _, tt := range []struct {
+		name    string
+		store   golumn.Store
+		version int64
+		wantErr string
+	}{
+		{"not_lister", &fakeStore{versions: []int64{1}}, 1, "requires a version store implementing VersionLister"},
+		{"not_applied", newListingStore(1), 2, "migration 2 is not applied"},
+		{"unknown", newListingStore(1), 9, "missing migration for version: 9"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator := &golumn.Migrator{Store: tt.store, Sources: sources()}
+			if _, err := migrator.DownOnly(context.Background(), tt.version); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestMigrator_PanicRecovery(t *testing.T) {
+	panicking := func(context.Context, *sql.DB) error { panic("boom") }
+	luaPanicking := golumn.LuaMigration(2, "2_panic.lua", `local hooks = require "hooks"
+Version=2
+function Up() hooks.call("explode") end
+function Down() hooks.call("explode") end
+`)
+	hooks := golumn.Hooks{"explode": func(context.Context, ...any) (any, error) { panic("boom") }}
+
+	tests := []struct {
+		name       string
+		sources    []*golumn.Migration
+		initial    []int64
+		down       bool
+		holdLock   bool
+		wantLocked bool
+	}{
+		{name: "go_up", sources: []*golumn.Migration{{Version: 1, UpFunc: panicking, DownFunc: noopMigration}}},
+		{name: "go_down", sources: []*golumn.Migration{{Version: 1, UpFunc: noopMigration, DownFunc: panicking}}, initial: []int64{1}, down: true},
+		{name: "go_up_hold_lock", sources: []*golumn.Migration{{Version: 1, UpFunc: panicking, DownFunc: noopMigration}}, holdLock: true, wantLocked: true},
+		{name: "lua_up", sources: []*golumn.Migration{luaPanicking}},
+		{name: "lua_down", sources: []*golumn.Migration{luaPanicking}, initial: []int64{2}, down: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{versions: slices.Clone(tt.initial)}
+			migrator := &golumn.Migrator{Store: store, Sources: tt.sources, HoldLockOnFailure: tt.holdLock, Hooks: hooks}
+
+			var err error
+			if tt.down {
+				_, err = migrator.Down(context.Background(), golumn.DownTargetInitial)
+			} else {
+				_, err = migrator.Up(context.Background(), golumn.Latest)
+			}
+			if err == nil || !strings.Contains(err.Error(), "boom") {
+				t.Fatalf("expected panic converted to error, got %v", err)
+			}
+			if store.locked != tt.wantLocked {
+				t.Errorf("locked: want %v, got %v", tt.wantLocked, store.locked)
+			}
+		})
+	}
+}
+
+func TestMigrator_PanicError(t *testing.T) {
+	errPanic := errors.New("panic value")
+	migrator := &golumn.Migrator{
+		Store: &fakeStore{},
+		Sources: []*golumn.Migration{
+			{Version: 1, UpFunc: func(context.Context, *sql.DB) error { panic(errPanic) }, DownFunc: noopMigration},
+		},
+	}
+
+	_, err := migrator.Up(context.Background(), 1)
+	var pe *golumn.PanicError
+	if !errors.As(err, &pe) {
+		t.Fatalf("expected *PanicError, got %v", err)
+	}
+	if !errors.Is(err, errPanic) {
+		t.Error("expected errors.Is to match the panic value")
+	}
+	if !strings.Contains(string(pe.Stack), "TestMigrator_PanicError") {
+		t.Errorf("expected stack trace to include the panicking test, got:\n%s", pe.Stack)
+	}
+}
+
 func TestMigrator_ValidationConsistency(t *testing.T) {
 	invalidMigrations := [][]*golumn.Migration{
 		{
@@ -650,8 +1460,8 @@ func TestMigrator_ValidationConsistency(t *testing.T) {
 			}
 
 			// Both Up and Down should fail validation consistently
-			upErr := migrator.Up(context.Background(), 1)
-			downErr := migrator.Down(context.Background(), 0)
+			_, upErr := migrator.Up(context.Background(), 1)
+			_, downErr := migrator.Down(context.Background(), 0)
 
 			if upErr == nil {
 				t.Error("Up should have failed validation")
@@ -668,6 +1478,45 @@ func TestMigrator_ValidationConsistency(t *testing.T) {
 	}
 }
 
+func TestMigrator_MixedVersionSchemes(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []int64
+		allow    bool
+		wantErr  bool
+	}{
+		{"sequential", []int64{1, 2, 3, 42}, false, false},
+		{"timestamps", []int64{1718000000, 1718000100, 1718100000}, false, false},
+		{"zero_then_timestamp", []int64{0, 1718000000}, false, false},
+		{"small_jump", []int64{9, 100, 1000}, false, false},
+		{"sequential_then_timestamp", []int64{1, 42, 1718000000}, false, true},
+		{"timestamp_then_datetime", []int64{1718000000, 20240610120000}, false, true},
+		{"acknowledged", []int64{1, 42, 1718000000}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{}
+			migrator := &golumn.Migrator{
+				Store:              store,
+				Sources:            createMigrations(tt.versions...),
+				AllowMixedVersions: tt.allow,
+			}
+
+			_, err := migrator.Up(context.Background(), tt.versions[len(tt.versions)-1])
+			if tt.wantErr && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("expected no error but got: %v", err)
+			}
+			if tt.wantErr && store.initCalls > 0 {
+				t.Error("store should not be accessed when validation fails")
+			}
+		})
+	}
+}
+
 func TestMigrator_InitialVersionHandling(t *testing.T) {
 	t.Run("up_from_initial_version", func(t *testing.T) {
 		store := &fakeStore{versions: []int64{}}
@@ -676,7 +1525,7 @@ func TestMigrator_InitialVersionHandling(t *testing.T) {
 			Sources: createMigrations(1, 2),
 		}
 
-		err := migrator.Up(context.Background(), 2)
+		_, err := migrator.Up(context.Background(), 2)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -694,7 +1543,7 @@ func TestMigrator_InitialVersionHandling(t *testing.T) {
 			Sources: createMigrations(1, 2),
 		}
 
-		err := migrator.Down(context.Background(), 1)
+		_, err := migrator.Down(context.Background(), 1)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -713,7 +1562,7 @@ func TestMigrator_LockBehavior(t *testing.T) {
 			Sources: createMigrations(1, 2),
 		}
 
-		err := migrator.Up(context.Background(), 2)
+		_, err := migrator.Up(context.Background(), 2)
 		if err != nil {
 			t.Fatalf("Up failed: %v", err)
 		}
@@ -725,7 +1574,7 @@ func TestMigrator_LockBehavior(t *testing.T) {
 			t.Error("lock should be released after successful operation")
 		}
 
-		err = migrator.Down(context.Background(), 1)
+		_, err = migrator.Down(context.Background(), 1)
 		if err != nil {
 			t.Fatalf("Down failed: %v", err)
 		}
@@ -759,7 +1608,7 @@ func TestMigrator_LockBehavior(t *testing.T) {
 					HoldLockOnFailure: tt.holdLock,
 				}
 
-				err := migrator.Up(context.Background(), 1)
+				_, err := migrator.Up(context.Background(), 1)
 				if err == nil {
 					t.Error("expected error from migration")
 				}
@@ -780,7 +1629,7 @@ func TestMigrator_StoreCallPatterns(t *testing.T) {
 			Sources: createMigrations(1, 2),
 		}
 
-		err := migrator.Up(context.Background(), 2)
+		_, err := migrator.Up(context.Background(), 2)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -811,7 +1660,7 @@ func TestMigrator_StoreCallPatterns(t *testing.T) {
 			Sources: createMigrations(1, 2, 3),
 		}
 
-		err := migrator.Down(context.Background(), 1)
+		_, err := migrator.Down(context.Background(), 1)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -834,7 +1683,7 @@ func TestMigrator_BoundaryConditions(t *testing.T) {
 			Sources: createMigrations(1, 2, 3),
 		}
 
-		err := migrator.Up(context.Background(), 0)
+		_, err := migrator.Up(context.Background(), 0)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -851,7 +1700,25 @@ func TestMigrator_BoundaryConditions(t *testing.T) {
 			Sources: createMigrations(1, 2, 3),
 		}
 
-		err := migrator.Up(context.Background(), 9999)
+		_, err := migrator.Up(context.Background(), 9999)
+		if !errors.Is(err, golumn.ErrInvalidTarget) {
+			t.Fatalf("expected ErrInvalidTarget, got %v", err)
+		}
+		if len(store.applied) != 0 || store.initCalls != 0 {
+			t.Errorf("expected store to be untouched, got applied %v", store.applied)
+		}
+	})
+
+	t.Run("deprecated_latest_target", func(t *testing.T) {
+		store := &fakeStore{}
+		var log bytes.Buffer
+		migrator := &golumn.Migrator{
+			Store:   store,
+			Sources: createMigrations(1, 2, 3),
+			Log:     golumn.NewLogger(&log, golumn.LogInfo),
+		}
+
+		_, err := migrator.Up(context.Background(), -1)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -860,6 +1727,9 @@ func TestMigrator_BoundaryConditions(t *testing.T) {
 		if !slices.Equal(want, store.applied) {
 			t.Errorf("want %v, got %v", want, store.applied)
 		}
+		if !strings.Contains(log.String(), "Up target -1 is deprecated") {
+			t.Errorf("expected deprecation warning, got %q", log.String())
+		}
 	})
 
 	t.Run("single_migration", func(t *testing.T) {
@@ -869,7 +1739,7 @@ func TestMigrator_BoundaryConditions(t *testing.T) {
 			Sources: createMigrations(1, 2, 3),
 		}
 
-		err := migrator.Up(context.Background(), 3)
+		_, err := migrator.Up(context.Background(), 3)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -894,7 +1764,7 @@ func TestMigrator_ConcurrentSafety(t *testing.T) {
 			t.Fatalf("failed to pre-lock store: %v", err)
 		}
 
-		err = migrator.Up(context.Background(), 1)
+		_, err = migrator.Up(context.Background(), 1)
 		if err == nil {
 			t.Error("expected error when store is already locked")
 		}
@@ -904,6 +1774,197 @@ func TestMigrator_ConcurrentSafety(t *testing.T) {
 	})
 }
 
+// syncStore is a Store safe for concurrent use by multiple migrators. Unlike
+// fakeStore, it does not track call counts.
+type syncStore struct {
+	mu       sync.Mutex
+	locked   bool
+	versions []int64
+}
+
+func (s *syncStore) DB() *sql.DB                  { return nil }
+func (s *syncStore) Init(_ context.Context) error { return nil }
+
+func (s *syncStore) Lock(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locked {
+		return golumn.ErrLocked
+	}
+	s.locked = true
+	return nil
+}
+
+func (s *syncStore) Release(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locked = false
+	return nil
+}
+
+func (s *syncStore) Version(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.versions) == 0 {
+		return 0, golumn.ErrInitialVersion
+	}
+	return slices.Max(s.versions), nil
+}
+
+func (s *syncStore) Insert(_ context.Context, v int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.versions, v) {
+		return fmt.Errorf("duplicate version: %d", v)
+	}
+	s.versions = append(s.versions, v)
+	return nil
+}
+
+func (s *syncStore) Remove(_ context.Context, v int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions = slices.DeleteFunc(s.versions, func(x int64) bool { return x == v })
+	return nil
+}
+
+func TestMigrator_ConcurrentMigrators(t *testing.T) {
+	const (
+		numMigrators  = 8
+		numMigrations = 20
+	)
+
+	store := &syncStore{}
+
+	var mu sync.Mutex
+	applyCounts := map[int64]int{}
+	migrations := make([]*golumn.Migration, numMigrations)
+	for i := range migrations {
+		v := int64(i + 1)
+		migrations[i] = &golumn.Migration{
+			Version: v,
+			UpFunc: func(_ context.Context, _ *sql.DB) error {
+				mu.Lock()
+				applyCounts[v]++
+				mu.Unlock()
+				return nil
+			},
+			DownFunc: noopMigration,
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, numMigrators)
+	for i := range numMigrators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			migrator := &golumn.Migrator{
+				Store:    store,
+				Sources:  migrations,
+				LockWait: 10 * time.Second,
+			}
+			// Stagger targets so migrators race on overlapping pending sets.
+			_, errs[i] = migrator.Up(context.Background(), int64((i%4+1)*numMigrations/4))
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("migrator %d failed: %v", i, err)
+		}
+	}
+	for v := int64(1); v <= numMigrations; v++ {
+		if applyCounts[v] != 1 {
+			t.Errorf("version %d applied %d times, want 1", v, applyCounts[v])
+		}
+	}
+	if store.locked {
+		t.Error("lock should be released after all migrators finish")
+	}
+}
+
+func TestMigrator_LockWait(t *testing.T) {
+	t.Run("fails_immediately_without_wait", func(t *testing.T) {
+		store := &fakeStore{locked: true}
+		migrator := &golumn.Migrator{
+			Store:   store,
+			Sources: createMigrations(1),
+		}
+
+		_, err := migrator.Up(context.Background(), 1)
+		if !errors.Is(err, golumn.ErrLocked) {
+			t.Errorf("expected ErrLocked, got %v", err)
+		}
+		if store.lockCalls != 1 {
+			t.Errorf("expected 1 lock call, got %d", store.lockCalls)
+		}
+	})
+
+	t.Run("retries_until_released", func(t *testing.T) {
+		store := &fakeStore{}
+		store.lockFunc = func(ctx context.Context, s *fakeStore) error {
+			if s.lockCalls < 3 {
+				return golumn.ErrLocked
+			}
+			return defaultLockFunc(ctx, s)
+		}
+		migrator := &golumn.Migrator{
+			Store:    store,
+			Sources:  createMigrations(1),
+			LockWait: 10 * time.Second,
+		}
+
+		if _, err := migrator.Up(context.Background(), 1); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if store.lockCalls != 3 {
+			t.Errorf("expected 3 lock calls, got %d", store.lockCalls)
+		}
+		if !slices.Equal([]int64{1}, store.applied) {
+			t.Errorf("want %v, got %v", []int64{1}, store.applied)
+		}
+	})
+
+	t.Run("gives_up_after_wait", func(t *testing.T) {
+		store := &fakeStore{locked: true}
+		migrator := &golumn.Migrator{
+			Store:    store,
+			Sources:  createMigrations(1),
+			LockWait: 120 * time.Millisecond,
+		}
+
+		_, err := migrator.Up(context.Background(), 1)
+		if !errors.Is(err, golumn.ErrLocked) {
+			t.Errorf("expected ErrLocked, got %v", err)
+		}
+		if store.lockCalls < 2 {
+			t.Errorf("expected lock to be retried, got %d calls", store.lockCalls)
+		}
+		if len(store.applied) > 0 {
+			t.Errorf("expected no migrations applied, got %v", store.applied)
+		}
+	})
+
+	t.Run("context_cancelled_while_waiting", func(t *testing.T) {
+		store := &fakeStore{locked: true}
+		migrator := &golumn.Migrator{
+			Store:    store,
+			Sources:  createMigrations(1),
+			LockWait: 10 * time.Second,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err := migrator.Up(ctx, 1)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
 func TestMigrator_Integration(t *testing.T) {
 	store := &fakeStore{}
 	migrations := createMigrations(1, 2, 3, 4, 5)
@@ -923,7 +1984,7 @@ func TestMigrator_Integration(t *testing.T) {
 
 	for i, step := range steps {
 		t.Run(fmt.Sprintf("step_%d_to_%d", i+1, step.target), func(t *testing.T) {
-			err := migrator.Up(context.Background(), step.target)
+			_, err := migrator.Up(context.Background(), step.target)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -945,7 +2006,7 @@ func TestMigrator_Integration(t *testing.T) {
 
 	for i, step := range rollbackSteps {
 		t.Run(fmt.Sprintf("rollback_%d_to_%d", i+1, step.target), func(t *testing.T) {
-			err := migrator.Down(context.Background(), step.target)
+			_, err := migrator.Down(context.Background(), step.target)
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -956,3 +2017,49 @@ func TestMigrator_Integration(t *testing.T) {
 		})
 	}
 }
+
+func TestMigrator_SharedInstance(t *testing.T) {
+	store := &syncStore{}
+	migrator := &golumn.Migrator{
+		Store:   store,
+		Sources: createMigrations(1, 2, 3, 4),
+		Log:     golumn.NewLogger(io.Discard, golumn.LogDebug),
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := range 8 {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = migrator.Up(context.Background(), 4)
+			} else {
+				_, err = migrator.Down(context.Background(), 2)
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := migrator.Status(context.Background())
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := migrator.CachedVersion(context.Background(), time.Millisecond)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if store.locked {
+		t.Error("lock should be released after all runs")
+	}
+}
@@ -0,0 +1,29 @@
+package golumn
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// utf8BOM is the byte order mark some Windows editors write at the start of
+// UTF-8 files.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// NormalizeSource strips a leading UTF-8 byte order mark from src and
+// replaces CRLF and lone CR line endings with LF, so that a migration
+// authored on Windows parses, and is checksummed, like its Unix checkout.
+// Line endings inside string literals are replaced too.
+func NormalizeSource(src []byte) []byte {
+	return CanonicalLineEndings(bytes.TrimPrefix(src, utf8BOM))
+}
+
+// skipBOM returns r without a leading UTF-8 byte order mark, which parsers
+// would otherwise read as part of the first token.
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if b, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(b, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	return br
+}
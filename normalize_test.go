@@ -0,0 +1,89 @@
+package golumn_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestNormalizeSource(t *testing.T) {
+	for _, tt := range []struct{ src, want string }{
+		{"Version=1\n", "Version=1\n"},
+		{"\xef\xbb\xbfVersion=1\r\n", "Version=1\n"},
+		{"a\rb\r\nc", "a\nb\nc"},
+		{"a\xef\xbb\xbf", "a\xef\xbb\xbf"},
+	} {
+		if got := string(golumn.NormalizeSource([]byte(tt.src))); got != tt.want {
+			t.Errorf("NormalizeSource(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParse_ByteOrderMark(t *testing.T) {
+	src := "\xef\xbb\xbfVersion=1\r\nfunction Up() end\r\nfunction Down() end\r\n"
+	m, err := golumn.Parse(context.Background(), strings.NewReader(src), "0001_init.lua")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Version != 1 {
+		t.Errorf("expected version 1, got %d", m.Version)
+	}
+	if err := golumn.LuaMigration(1, "0001_init.lua", src).Up(context.Background(), nil); err != nil {
+		t.Errorf("expected LuaMigration to skip the byte order mark, got %v", err)
+	}
+}
+
+func TestGlobLoader_Normalize(t *testing.T) {
+	files := map[string]string{
+		"0001_init.lua": "Version=1\nfunction Up() end\nfunction Down() end\n",
+		"0002_seed.sql": "-- +golumn Up\nSELECT 1;\n-- +golumn Down\nSELECT 2;\n",
+	}
+	load := func(crlf, normalize bool) []*golumn.Migration {
+		t.Helper()
+		dir := t.TempDir()
+		for name, src := range files {
+			if crlf {
+				src = "\xef\xbb\xbf" + strings.ReplaceAll(src, "\n", "\r\n")
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		migrations, err := golumn.GlobLoader{Pattern: filepath.Join(dir, "*"), Normalize: normalize}.Load(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return migrations
+	}
+
+	unix, windows := load(false, false), load(true, true)
+	for i := range unix {
+		if unix[i].Checksum != windows[i].Checksum {
+			t.Errorf("%s: expected normalized checksum %q, got %q", unix[i].Name, unix[i].Checksum, windows[i].Checksum)
+		}
+	}
+	if raw := load(true, false); raw[1].Checksum == unix[1].Checksum {
+		t.Error("expected the checksum of an unnormalized CRLF file to differ")
+	}
+
+	src := golumn.MigrationSource{
+		Version: 2,
+		Name:    "0002_seed.sql",
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("-- +golumn Up\r\nSELECT 1;\r\n-- +golumn Down\r\nSELECT 2;\r\n")), nil
+		},
+		Normalize: true,
+	}
+	m, err := src.Load(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Checksum != unix[1].Checksum {
+		t.Errorf("expected MigrationSource to normalize, got checksum %q", m.Checksum)
+	}
+}
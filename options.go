@@ -0,0 +1,162 @@
+package golumn
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// RunOption overrides Migrator configuration for a single Up, UpOnly, Down
+// or DownOnly call, so that one shared Migrator can serve different kinds of
+// invocation without its fields being mutated.
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	dryRun              bool
+	lockWait            *time.Duration
+	holdLockOnFailure   *bool
+	autoRevertOnFailure *bool
+	verifyAfterRun      *bool
+	approvalToken       *string
+	explain             bool
+	ignoreCompat        bool
+	env                 string
+	luaProfile          io.Writer
+}
+
+// WithDryRun makes the run compute which migrations it would apply or
+// revert, reported in Result.Plan, without running them. The store is
+// initialized and locked as usual so that the plan reflects a consistent
+// state, but the approval, schedule, pacing and lag checks are skipped and
+// AfterSuccess is not called.
+func WithDryRun() RunOption {
+	return func(o *runOptions) { o.dryRun = true }
+}
+
+// WithExplain makes a dry run attach the store's query plan for each
+// statement of the planned SQL migrations to Result.Plan, so that reviewers
+// can spot full-table scans before running them. It requires a store that
+// implements Explainer, and has no effect without WithDryRun. Statements
+// defining routines, and Lua and Go migrations, are not explained.
+func WithExplain() RunOption {
+	return func(o *runOptions) { o.explain = true }
+}
+
+// WithIgnoreCompat skips the check against applications registered with
+// Migrator.RegisterCompat, e.g. to roll back past a registration left by an
+// application that is known to be gone.
+func WithIgnoreCompat() RunOption {
+	return func(o *runOptions) { o.ignoreCompat = true }
+}
+
+// WithEnv sets the environment of the run, e.g. "prod", which decides
+// whether migrations with Envs set run. Without it, runs of a Migrator with
+// such migrations fail rather than record them without running them.
+func WithEnv(env string) RunOption {
+	return func(o *runOptions) { o.env = env }
+}
+
+// WithLuaProfile profiles each Lua migration the run applies or reverts,
+// appending to w the time spent in each global Lua function and each db
+// call, in the folded stack format read by flame graph tools such as
+// flamegraph.pl and speedscope:
+//
+//	0003_backfill.lua;Up;backfill;db.exec 812345
+//
+// Each line is a stack of frames, rooted at the migration's file, followed
+// by the microseconds spent in its last frame excluding the frames it
+// called. Local functions count towards the global function calling them.
+// Profiling adds overhead to every call it times, so it is meant for
+// optimizing long data migrations rather than for routine runs.
+func WithLuaProfile(w io.Writer) RunOption {
+	return func(o *runOptions) { o.luaProfile = w }
+}
+
+// WithLockWait overrides Migrator.LockWait.
+func WithLockWait(d time.Duration) RunOption {
+	return func(o *runOptions) { o.lockWait = &d }
+}
+
+// WithHoldLockOnFailure overrides Migrator.HoldLockOnFailure.
+func WithHoldLockOnFailure(hold bool) RunOption {
+	return func(o *runOptions) { o.holdLockOnFailure = &hold }
+}
+
+// WithAutoRevertOnFailure overrides Migrator.AutoRevertOnFailure.
+func WithAutoRevertOnFailure(revert bool) RunOption {
+	return func(o *runOptions) { o.autoRevertOnFailure = &revert }
+}
+
+// WithVerifyAfterRun overrides Migrator.VerifyAfterRun.
+func WithVerifyAfterRun(verify bool) RunOption {
+	return func(o *runOptions) { o.verifyAfterRun = &verify }
+}
+
+// WithApprovalToken overrides Migrator.ApprovalToken.
+func WithApprovalToken(token string) RunOption {
+	return func(o *runOptions) { o.approvalToken = &token }
+}
+
+type runOptionsContextKey struct{}
+
+// withRunOptions returns a context carrying opts, which are applied on top
+// of any options already carried by ctx.
+func withRunOptions(ctx context.Context, opts []RunOption) context.Context {
+	if len(opts) == 0 {
+		return ctx
+	}
+	o := runOptionsFromContext(ctx)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return context.WithValue(ctx, runOptionsContextKey{}, o)
+}
+
+func runOptionsFromContext(ctx context.Context) runOptions {
+	o, _ := ctx.Value(runOptionsContextKey{}).(runOptions)
+	return o
+}
+
+// runConfig is the Migrator configuration in effect for a run.
+type runConfig struct {
+	dryRun              bool
+	lockWait            time.Duration
+	holdLockOnFailure   bool
+	autoRevertOnFailure bool
+	verifyAfterRun      bool
+	approvalToken       string
+	explain             bool
+	ignoreCompat        bool
+}
+
+// config returns the Migrator's fields with the run options carried by ctx
+// applied.
+func (m *Migrator) config(ctx context.Context) runConfig {
+	o := runOptionsFromContext(ctx)
+	cfg := runConfig{
+		dryRun:              o.dryRun,
+		explain:             o.explain,
+		ignoreCompat:        o.ignoreCompat,
+		lockWait:            m.LockWait,
+		holdLockOnFailure:   m.HoldLockOnFailure,
+		autoRevertOnFailure: m.AutoRevertOnFailure,
+		verifyAfterRun:      m.VerifyAfterRun,
+		approvalToken:       m.ApprovalToken,
+	}
+	if o.lockWait != nil {
+		cfg.lockWait = *o.lockWait
+	}
+	if o.holdLockOnFailure != nil {
+		cfg.holdLockOnFailure = *o.holdLockOnFailure
+	}
+	if o.autoRevertOnFailure != nil {
+		cfg.autoRevertOnFailure = *o.autoRevertOnFailure
+	}
+	if o.verifyAfterRun != nil {
+		cfg.verifyAfterRun = *o.verifyAfterRun
+	}
+	if o.approvalToken != nil {
+		cfg.approvalToken = *o.approvalToken
+	}
+	return cfg
+}
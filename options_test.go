@@ -0,0 +1,126 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigrator_DryRun(t *testing.T) {
+	t.Run("up", func(t *testing.T) {
+		store := &fakeStore{versions: []int64{1}}
+		afterSuccess := false
+		migrator := &golumn.Migrator{
+			Store:        store,
+			Sources:      createMigrations(1, 2, 3),
+			AfterSuccess: func(context.Context, *golumn.Result) error { afterSuccess = true; return nil },
+		}
+
+		res, err := migrator.Up(context.Background(), 3, golumn.WithDryRun())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !res.DryRun || !slices.Equal(res.Plan.Versions(), []int64{2, 3}) || len(res.Applied) != 0 || res.Version != 1 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		if len(store.applied) != 0 {
+			t.Errorf("expected nothing applied, got %v", store.applied)
+		}
+		if store.locked {
+			t.Error("lock should be released after a dry run")
+		}
+		if afterSuccess {
+			t.Error("AfterSuccess should not be called for a dry run")
+		}
+	})
+
+	t.Run("down", func(t *testing.T) {
+		store := newListingStore(1, 3)
+		migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2, 3)}
+
+		res, err := migrator.Down(context.Background(), -1, golumn.WithDryRun())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(res.Plan.Versions(), []int64{3, 1}) || len(res.Reverted) != 0 {
+			t.Errorf("unexpected result: %+v", res)
+		}
+		if len(store.reverted) != 0 {
+			t.Errorf("expected nothing reverted, got %v", store.reverted)
+		}
+	})
+
+	t.Run("down_only", func(t *testing.T) {
+		store := newListingStore(1, 2)
+		migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2)}
+
+		res, err := migrator.DownOnly(context.Background(), 1, golumn.WithDryRun())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(res.Plan.Versions(), []int64{1}) || len(store.reverted) != 0 {
+			t.Errorf("unexpected result: %+v, reverted %v", res, store.reverted)
+		}
+	})
+
+	t.Run("skips_approval", func(t *testing.T) {
+		migrator := &golumn.Migrator{Store: productionStore{&fakeStore{}, true}, Sources: createMigrations(1)}
+		if _, err := migrator.Up(context.Background(), 1, golumn.WithDryRun()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if _, err := migrator.Up(context.Background(), 1); !errors.Is(err, golumn.ErrApprovalRequired) {
+			t.Errorf("expected ErrApprovalRequired without dry run, got %v", err)
+		}
+	})
+}
+
+func TestMigrator_RunOptions(t *testing.T) {
+	t.Run("lock_wait", func(t *testing.T) {
+		store := &fakeStore{locked: true}
+		migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1)}
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			store.Release(context.Background())
+		}()
+		if _, err := migrator.Up(context.Background(), 1, golumn.WithLockWait(time.Second)); err != nil {
+			t.Fatalf("expected lock to be acquired within wait, got %v", err)
+		}
+		if migrator.LockWait != 0 {
+			t.Error("run options should not modify the migrator")
+		}
+	})
+
+	t.Run("auto_revert", func(t *testing.T) {
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{
+			Store: store,
+			Sources: []*golumn.Migration{
+				{Version: 1, UpFunc: noopMigration, DownFunc: noopMigration},
+				{Version: 2, UpFunc: errorMigration("up error"), DownFunc: noopMigration},
+			},
+		}
+
+		res, err := migrator.Up(context.Background(), 2, golumn.WithAutoRevertOnFailure(true))
+		if err == nil {
+			t.Fatal("expected error but got none")
+		}
+		if !slices.Equal(res.Reverted, []int64{1}) {
+			t.Errorf("expected 1 to be auto-reverted, got %v", res.Reverted)
+		}
+	})
+
+	t.Run("conflicting_overrides", func(t *testing.T) {
+		migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1), HoldLockOnFailure: true}
+		if _, err := migrator.Up(context.Background(), 1, golumn.WithAutoRevertOnFailure(true)); err == nil {
+			t.Error("expected validation error")
+		}
+		if _, err := migrator.Up(context.Background(), 1, golumn.WithHoldLockOnFailure(false), golumn.WithAutoRevertOnFailure(true)); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
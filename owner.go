@@ -0,0 +1,43 @@
+package golumn
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// OwnerPolicy holds migrations accountable to the teams that own them, like
+// a CODEOWNERS file: with a policy set, Migrator runs refuse sources with a
+// migration that names no Owner, or an owner not in Teams. Migrations
+// loaded lazily, by MigrationSource, are checked once a run has parsed
+// them, before it touches the store.
+type OwnerPolicy struct {
+	// Teams, if not empty, lists the owners migrations may name.
+	Teams []string
+}
+
+// check returns an error for each migration violating the policy, passing
+// over lazily loaded ones not yet resolved.
+func (p *OwnerPolicy) check(migrations []*Migration) error {
+	var errs []error
+	for _, migration := range migrations {
+		if migration.load != nil && !migration.resolved {
+			continue
+		}
+		switch {
+		case migration.Owner == "":
+			errs = append(errs, fmt.Errorf("migration %d has no owner", migration.Version))
+		case len(p.Teams) > 0 && !slices.Contains(p.Teams, migration.Owner):
+			errs = append(errs, fmt.Errorf("migration %d is owned by %q, which is not an allowed team", migration.Version, migration.Owner))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkOwners checks the sources against OwnerPolicy, if set.
+func (m *Migrator) checkOwners() error {
+	if m.OwnerPolicy == nil {
+		return nil
+	}
+	return m.OwnerPolicy.check(m.migrations())
+}
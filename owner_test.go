@@ -0,0 +1,129 @@
+package golumn_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestParse_Owner(t *testing.T) {
+	sqlM, err := golumn.ParseSQL(context.Background(), strings.NewReader("-- +golumn Owner payments\n-- +golumn Up\nSELECT 1;\n"), "0001_a.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlM.Owner != "payments" {
+		t.Errorf("expected SQL owner payments, got %q", sqlM.Owner)
+	}
+
+	luaM := mustParse(t, "Version=1\nOwner=\"search\"\nfunction Up() end\n")
+	if luaM.Owner != "search" {
+		t.Errorf("expected Lua owner search, got %q", luaM.Owner)
+	}
+
+	if _, err := golumn.Parse(context.Background(), strings.NewReader("Version=1\nOwner=1\n"), "bad.lua"); err == nil || !strings.Contains(err.Error(), "Owner global") {
+		t.Errorf("expected an error for a non-string Owner, got %v", err)
+	}
+	if got := golumn.Metadata(luaM).Owner; got != "search" {
+		t.Errorf("expected owner in metadata, got %q", got)
+	}
+}
+
+func TestMigrator_OwnerPolicy(t *testing.T) {
+	owned := func(v int64, owner string) *golumn.Migration {
+		m := createMigrations(v)[0]
+		m.Owner = owner
+		return m
+	}
+	tests := []struct {
+		name    string
+		policy  *golumn.OwnerPolicy
+		sources []*golumn.Migration
+		want    []string
+	}{
+		{"no policy", nil, []*golumn.Migration{owned(1, "")}, nil},
+		{"owned", &golumn.OwnerPolicy{}, []*golumn.Migration{owned(1, "payments")}, nil},
+		{"unowned", &golumn.OwnerPolicy{}, []*golumn.Migration{owned(1, ""), owned(2, "")}, []string{"migration 1 has no owner", "migration 2 has no owner"}},
+		{"listed", &golumn.OwnerPolicy{Teams: []string{"payments", "search"}}, []*golumn.Migration{owned(1, "search")}, nil},
+		{"unlisted", &golumn.OwnerPolicy{Teams: []string{"payments"}}, []*golumn.Migration{owned(1, "growth")}, []string{`migration 1 is owned by "growth", which is not an allowed team`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: tt.sources, OwnerPolicy: tt.policy}
+			_, err := migrator.Up(context.Background(), golumn.Latest)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			for _, want := range tt.want {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error containing %q, got %v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrator_OwnerPolicyLazy(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"0001_a.sql": "-- +golumn Up\nSELECT 1;\n",
+		"0002_b.sql": "-- +golumn Owner payments\n-- +golumn DependsOn 1\n-- +golumn MaxDuration 5m\n-- +golumn Up\nSELECT 2;\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	store := newListingStore()
+	migrator := &golumn.Migrator{
+		Store:       store,
+		Loader:      golumn.GlobLoader{Pattern: filepath.Join(dir, "*.sql"), Lazy: true},
+		OwnerPolicy: &golumn.OwnerPolicy{Teams: []string{"other"}},
+	}
+	_, err := migrator.Up(context.Background(), golumn.Latest)
+	for _, want := range []string{"migration 1 has no owner", `migration 2 is owned by "payments"`} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got %v", want, err)
+		}
+	}
+	if len(store.versions) != 0 {
+		t.Errorf("expected nothing applied, got %v", store.versions)
+	}
+
+	migrations, err := migrator.Migrations(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m := migrations[1]; m.Owner != "payments" || !slices.Equal(m.DependsOn, []int64{1}) || m.MaxDuration != 5*time.Minute {
+		t.Errorf("expected the lazily loaded migration's metadata to be taken, got owner %q, depends on %v, max duration %v", m.Owner, m.DependsOn, m.MaxDuration)
+	}
+}
+
+func TestMigrator_AuditOwner(t *testing.T) {
+	m := createMigrations(1)[0]
+	m.Owner = "payments"
+	var got []golumn.AuditEvent
+	migrator := &golumn.Migrator{
+		Store:   &fakeStore{},
+		Sources: []*golumn.Migration{m},
+		Audit: golumn.AuditFunc(func(_ context.Context, ev golumn.AuditEvent) error {
+			got = append(got, ev)
+			return nil
+		}),
+	}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if len(got) != 1 || got[0].Owner != "payments" {
+		t.Errorf("expected an audit event owned by payments, got %+v", got)
+	}
+}
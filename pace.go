@@ -0,0 +1,90 @@
+package golumn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Pacer spaces out migrations during a run: it is waited on before each
+// one, the first included, so that a Pacer remembering its last call spaces
+// the first two like the rest, and between the statements a migration runs
+// through a SQL file or the Lua db module. *rate.Limiter from
+// golang.org/x/time/rate satisfies it, giving token bucket pacing.
+type Pacer interface {
+	Wait(context.Context) error
+}
+
+// Interval returns a Pacer that lets calls to Wait through at least d apart.
+// The first call returns at once.
+func Interval(d time.Duration) Pacer {
+	return &intervalPacer{d: d}
+}
+
+type intervalPacer struct {
+	d    time.Duration
+	mu   sync.Mutex
+	last time.Time
+}
+
+func (p *intervalPacer) Wait(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.last.IsZero() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(p.last.Add(p.d))):
+		}
+	}
+	p.last = time.Now()
+	return nil
+}
+
+// pace waits on m.Pacer before a migration of a run.
+func (m *Migrator) pace(ctx context.Context) error {
+	if m.Pacer == nil {
+		return nil
+	}
+	m.logger().Debugf("pacing before next migration")
+	if err := m.Pacer.Wait(ctx); err != nil {
+		return fmt.Errorf("pacing: %w", err)
+	}
+	return nil
+}
+
+type statementPacerKey struct{}
+
+// statementPacer waits on p between the statements of one migration.
+type statementPacer struct {
+	p       Pacer
+	started bool
+}
+
+// withStatementPacer returns ctx pacing the statements run under it with
+// m.Pacer. The first statement is not paced, pace having just waited
+// before the migration.
+func (m *Migrator) withStatementPacer(ctx context.Context) context.Context {
+	if m.Pacer == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, statementPacerKey{}, &statementPacer{p: m.Pacer})
+}
+
+// paceStatement waits on the Pacer of ctx, if any, before a statement.
+func paceStatement(ctx context.Context) error {
+	sp, _ := ctx.Value(statementPacerKey{}).(*statementPacer)
+	if sp == nil {
+		return nil
+	}
+	if !sp.started {
+		sp.started = true
+		return nil
+	}
+	if err := sp.p.Wait(ctx); err != nil {
+		return fmt.Errorf("pacing: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+type countingPacer struct {
+	calls int
+	// err is returned from the call after the first.
+	err error
+}
+
+func (p *countingPacer) Wait(context.Context) error {
+	p.calls++
+	if p.calls > 1 {
+		return p.err
+	}
+	return nil
+}
+
+func TestInterval(t *testing.T) {
+	p := golumn.Interval(20 * time.Millisecond)
+	start := time.Now()
+	for range 3 {
+		if err := p.Wait(context.Background()); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("expected three waits to take at least 40ms, took %s", elapsed)
+	}
+}
+
+func TestInterval_Canceled(t *testing.T) {
+	p := golumn.Interval(time.Hour)
+	if err := p.Wait(context.Background()); err != nil {
+		t.Fatalf("first wait failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestMigrator_Pacer(t *testing.T) {
+	pacer := &countingPacer{}
+	migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1, 2, 3), Pacer: pacer}
+
+	if _, err := migrator.Up(context.Background(), 3); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if pacer.calls != 3 {
+		t.Errorf("expected a wait before each of 3 migrations, got %d", pacer.calls)
+	}
+
+	pacer.calls = 0
+	if _, err := migrator.Down(context.Background(), golumn.DownTargetInitial); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if pacer.calls != 3 {
+		t.Errorf("expected a wait before each of 3 reverts, got %d", pacer.calls)
+	}
+
+	pacer.calls = 0
+	migrator.Store = newListingStore(1, 2, 3)
+	if _, err := migrator.DownOnly(context.Background(), 2); err != nil {
+		t.Fatalf("down only failed: %v", err)
+	}
+	if pacer.calls != 1 {
+		t.Errorf("expected a wait before the revert, got %d", pacer.calls)
+	}
+}
+
+func TestMigrator_PacerError(t *testing.T) {
+	store := &fakeStore{}
+	pacer := &countingPacer{err: context.DeadlineExceeded}
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2), Pacer: pacer}
+
+	res, err := migrator.Up(context.Background(), 2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected pacer error, got %v", err)
+	}
+	if len(res.Applied) != 1 || len(store.applied) != 1 {
+		t.Errorf("expected run to stop after first migration, got %v", res.Applied)
+	}
+}
+
+func TestMigrator_PacerInterval(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	var started []time.Time
+	sources := createMigrations(1, 2)
+	for _, migration := range sources {
+		migration.UpFunc = func(context.Context, *sql.DB) error {
+			started = append(started, time.Now())
+			return nil
+		}
+	}
+	migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: sources, Pacer: golumn.Interval(interval)}
+
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if len(started) != 2 {
+		t.Fatalf("expected 2 migrations to run, got %d", len(started))
+	}
+	if gap := started[1].Sub(started[0]); gap < interval {
+		t.Errorf("expected the first two migrations at least %s apart, got %s", interval, gap)
+	}
+}
+
+func TestMigrator_PacerStatements(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	src := "-- +golumn Up\nCREATE TABLE t (id int);\nINSERT INTO t VALUES (1);\nINSERT INTO t VALUES (2);\n\n-- +golumn Down\nDROP TABLE t;\n"
+	sqlMigration, err := golumn.ParseSQL(ctx, strings.NewReader(src), "1_t.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	luaMigration := golumn.LuaMigration(2, "2_u.lua", `
+local db = require("db")
+function Up()
+  db.exec("CREATE TABLE u (id int)")
+  local tx = db.begin()
+  tx:exec("INSERT INTO u VALUES (1)")
+  tx:commit()
+end
+function Down()
+  db.exec("DROP TABLE u")
+end
+`)
+
+	pacer := &countingPacer{}
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: []*golumn.Migration{sqlMigration, luaMigration}, Pacer: pacer}
+	if _, err := migrator.Up(ctx, 1); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if pacer.calls != 3 {
+		t.Errorf("expected a wait before the migration and between its 3 statements, got %d", pacer.calls)
+	}
+
+	pacer.calls = 0
+	if _, err := migrator.Up(ctx, 2); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if pacer.calls != 2 {
+		t.Errorf("expected a wait before the migration and between its 2 execs, got %d", pacer.calls)
+	}
+}
+
+func TestMigrator_PacerStatementError(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	src := "-- +golumn Up\nCREATE TABLE t (id int);\nCREATE TABLE u (id int);\n\n-- +golumn Down\nDROP TABLE t;\n"
+	migration, err := golumn.ParseSQL(ctx, strings.NewReader(src), "1_t.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first wait, before the migration, succeeds and the second, before
+	// its second statement, fails.
+	pacer := &countingPacer{err: errors.New("slow down")}
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: []*golumn.Migration{migration}, Pacer: pacer}
+	if _, err := migrator.Up(ctx, 1); err == nil || !strings.Contains(err.Error(), "slow down") {
+		t.Fatalf("expected pacing error, got %v", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'u'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("expected the second statement not to run, got %d (err %v)", n, err)
+	}
+}
@@ -0,0 +1,352 @@
+package golumn
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// PartitionDialect generates the DDL for time-partitioned tables in one SQL
+// dialect. Partitions are named by PartitionName, so that they can be found
+// again for rotation.
+type PartitionDialect interface {
+	// CreatePartition returns a statement creating partition of table for
+	// rows in [from, to).
+	CreatePartition(table, partition string, from, to time.Time) string
+	// DropPartition returns a statement dropping partition of table.
+	DropPartition(table, partition string) string
+	// ListPartitions returns a query whose single column lists the names of
+	// table's partitions. It may list other tables too.
+	ListPartitions(table string) (query string, args []any)
+}
+
+// PartitionInterval is the span of each partition created by
+// RotatePartitions.
+type PartitionInterval int
+
+const (
+	PartitionDaily PartitionInterval = iota
+	PartitionMonthly
+)
+
+// start returns the start of the interval containing t, in UTC.
+func (i PartitionInterval) start(t time.Time) time.Time {
+	t = t.UTC()
+	if i == PartitionMonthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// add returns t moved by n intervals.
+func (i PartitionInterval) add(t time.Time, n int) time.Time {
+	if i == PartitionMonthly {
+		return t.AddDate(0, n, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+func parsePartitionInterval(s string) (PartitionInterval, error) {
+	switch s {
+	case "daily":
+		return PartitionDaily, nil
+	case "monthly":
+		return PartitionMonthly, nil
+	default:
+		return 0, fmt.Errorf("unknown partition interval %q", s)
+	}
+}
+
+const partitionLayout = "20060102"
+
+// PartitionName returns the name of the partition of table starting at
+// from, e.g. events_p20240701.
+func PartitionName(table string, from time.Time) string {
+	return table + "_p" + from.UTC().Format(partitionLayout)
+}
+
+// partitionStart parses the start time from a partition name of table.
+func partitionStart(table, name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, table+"_p")
+	if !ok || len(suffix) != len(partitionLayout) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(partitionLayout, suffix)
+	return t, err == nil
+}
+
+// CreatePartition creates the partition of table for rows in [from, to),
+// named by PartitionName.
+func CreatePartition(ctx context.Context, db *sql.DB, d PartitionDialect, table string, from, to time.Time) error {
+	if !from.Before(to) {
+		return fmt.Errorf("partition of %s: start %s is not before end %s", table, from, to)
+	}
+	_, err := db.ExecContext(ctx, d.CreatePartition(table, PartitionName(table, from), from, to))
+	return err
+}
+
+// DropPartition drops the partition of table starting at from.
+func DropPartition(ctx context.Context, db *sql.DB, d PartitionDialect, table string, from time.Time) error {
+	_, err := db.ExecContext(ctx, d.DropPartition(table, PartitionName(table, from)))
+	return err
+}
+
+// RotatePartitions maintains a window of partitions of table around now:
+// it creates those for the current interval and the next ahead intervals
+// that do not exist, and drops those that ended more than keep intervals
+// before the current one. It returns the names of the partitions it created
+// and dropped. Tables not named by PartitionName are left alone.
+func RotatePartitions(ctx context.Context, db *sql.DB, d PartitionDialect, table string, interval PartitionInterval, now time.Time, ahead, keep int) (created, dropped []string, err error) {
+	if ahead < 0 || keep < 0 {
+		return nil, nil, fmt.Errorf("partition of %s: negative ahead or keep", table)
+	}
+
+	query, args := d.ListPartitions(table)
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list partitions of %s: %w", table, err)
+	}
+	var existing []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("list partitions of %s: %w", table, err)
+		}
+		existing = append(existing, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("list partitions of %s: %w", table, err)
+	}
+
+	current := interval.start(now)
+	for i := 0; i <= ahead; i++ {
+		from := interval.add(current, i)
+		name := PartitionName(table, from)
+		if slices.Contains(existing, name) {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, d.CreatePartition(table, name, from, interval.add(from, 1))); err != nil {
+			return created, dropped, fmt.Errorf("create partition %s: %w", name, err)
+		}
+		created = append(created, name)
+	}
+
+	cutoff := interval.add(current, -keep)
+	slices.Sort(existing)
+	for _, name := range existing {
+		from, ok := partitionStart(table, name)
+		if !ok || !from.Before(cutoff) {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, d.DropPartition(table, name)); err != nil {
+			return created, dropped, fmt.Errorf("drop partition %s: %w", name, err)
+		}
+		dropped = append(dropped, name)
+	}
+	return created, dropped, nil
+}
+
+// PostgresPartitions creates declarative partitions of a table declared
+// with PARTITION BY RANGE on a timestamp column.
+type PostgresPartitions struct{}
+
+func (PostgresPartitions) CreatePartition(table, partition string, from, to time.Time) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		quoteIdent(partition, '"'), quoteIdent(table, '"'), from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+}
+
+func (PostgresPartitions) DropPartition(table, partition string) string {
+	return "DROP TABLE IF EXISTS " + quoteIdent(partition, '"')
+}
+
+func (PostgresPartitions) ListPartitions(table string) (string, []any) {
+	return "SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid JOIN pg_class p ON p.oid = i.inhparent WHERE p.relname = $1", []any{table}
+}
+
+// MySQLPartitions adds partitions to a table declared with
+// PARTITION BY RANGE (TO_DAYS(column)). MySQL partitions are bounded above
+// only, so from is implied by the previous partition.
+type MySQLPartitions struct{}
+
+func (MySQLPartitions) CreatePartition(table, partition string, from, to time.Time) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD PARTITION (PARTITION %s VALUES LESS THAN (TO_DAYS('%s')))",
+		quoteIdent(table, '`'), quoteIdent(partition, '`'), to.UTC().Format(time.DateOnly))
+}
+
+func (MySQLPartitions) DropPartition(table, partition string) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s", quoteIdent(table, '`'), quoteIdent(partition, '`'))
+}
+
+func (MySQLPartitions) ListPartitions(table string) (string, []any) {
+	return "SELECT PARTITION_NAME FROM information_schema.PARTITIONS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND PARTITION_NAME IS NOT NULL", []any{table}
+}
+
+// SQLitePartitions emulates partitions, which SQLite lacks, with one table
+// per interval copying the columns of table. The range is recorded only in
+// the name; queries must select the tables to read.
+type SQLitePartitions struct{}
+
+func (SQLitePartitions) CreatePartition(table, partition string, from, to time.Time) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS SELECT * FROM %s WHERE 0", quoteIdent(partition, '"'), quoteIdent(table, '"'))
+}
+
+func (SQLitePartitions) DropPartition(table, partition string) string {
+	return "DROP TABLE IF EXISTS " + quoteIdent(partition, '"')
+}
+
+func (SQLitePartitions) ListPartitions(table string) (string, []any) {
+	return "SELECT name FROM sqlite_master WHERE type = 'table' AND substr(name, 1, ?) = ?", []any{len(table) + 2, table + "_p"}
+}
+
+func quoteIdent(name string, q byte) string {
+	s := string(q)
+	return s + strings.ReplaceAll(name, s, s+s) + s
+}
+
+type partitionDialectContextKey struct{}
+
+// WithPartitionDialect returns a context that gives Lua migrations run with
+// it the db.create_partition, db.drop_partition and db.rotate_partitions
+// helpers for dialect d.
+func WithPartitionDialect(ctx context.Context, d PartitionDialect) context.Context {
+	return context.WithValue(ctx, partitionDialectContextKey{}, d)
+}
+
+func partitionDialectFromContext(ctx context.Context) PartitionDialect {
+	d, _ := ctx.Value(partitionDialectContextKey{}).(PartitionDialect)
+	return d
+}
+
+// checkPartitionArgs checks the session's database and dialect and the
+// table argument shared by the Lua partition helpers.
+func checkPartitionArgs(l *lua.LState, sess *luaSession) (PartitionDialect, string, bool) {
+	table := l.CheckString(1)
+	if sess.db == nil {
+		l.RaiseError("partition helpers are not available while parsing")
+		return nil, "", false
+	}
+	if sess.partitions == nil {
+		l.RaiseError("no partition dialect configured")
+		return nil, "", false
+	}
+	return sess.partitions, table, true
+}
+
+// checkLuaTime parses argument n as a date or RFC 3339 timestamp.
+func checkLuaTime(l *lua.LState, n int) time.Time {
+	t, err := parseLuaTime(l.CheckString(n))
+	if err != nil {
+		l.ArgError(n, err.Error())
+	}
+	return t
+}
+
+func parseLuaTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected YYYY-MM-DD or RFC 3339", s)
+}
+
+func luaContext(l *lua.LState) context.Context {
+	if ctx := l.Context(); ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+func luaCreatePartitionFunc(sess *luaSession) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		d, table, ok := checkPartitionArgs(l, sess)
+		if !ok {
+			return 0
+		}
+		from, to := checkLuaTime(l, 2), checkLuaTime(l, 3)
+		if err := CreatePartition(luaContext(l), sess.db, d, table, from, to); err != nil {
+			l.Push(lua.LNil)
+			l.Push(lua.LString(fmt.Sprintf("create_partition: %v", err)))
+			return 2
+		}
+		l.Push(lua.LString(PartitionName(table, from)))
+		return 1
+	}
+}
+
+func luaDropPartitionFunc(sess *luaSession) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		d, table, ok := checkPartitionArgs(l, sess)
+		if !ok {
+			return 0
+		}
+		if err := DropPartition(luaContext(l), sess.db, d, table, checkLuaTime(l, 2)); err != nil {
+			l.Push(lua.LNil)
+			l.Push(lua.LString(fmt.Sprintf("drop_partition: %v", err)))
+			return 2
+		}
+		l.Push(lua.LTrue)
+		return 1
+	}
+}
+
+func luaRotatePartitionsFunc(sess *luaSession) func(*lua.LState) int {
+	return func(l *lua.LState) int {
+		d, table, ok := checkPartitionArgs(l, sess)
+		if !ok {
+			return 0
+		}
+		opts := l.OptTable(2, l.NewTable())
+
+		interval := PartitionDaily
+		if lv := opts.RawGetString("interval"); lv != lua.LNil {
+			var err error
+			if interval, err = parsePartitionInterval(lv.String()); err != nil {
+				l.ArgError(2, err.Error())
+				return 0
+			}
+		}
+		ahead, keep := 0, 0
+		if lv, ok := opts.RawGetString("ahead").(lua.LNumber); ok {
+			ahead = int(lv)
+		}
+		if lv, ok := opts.RawGetString("keep").(lua.LNumber); ok {
+			keep = int(lv)
+		}
+		now := time.Now()
+		if lv, ok := opts.RawGetString("now").(lua.LString); ok {
+			var err error
+			if now, err = parseLuaTime(string(lv)); err != nil {
+				l.ArgError(2, err.Error())
+				return 0
+			}
+		}
+
+		created, dropped, err := RotatePartitions(luaContext(l), sess.db, d, table, interval, now, ahead, keep)
+		if err != nil {
+			l.Push(lua.LNil)
+			l.Push(lua.LString(fmt.Sprintf("rotate_partitions: %v", err)))
+			return 2
+		}
+		res := l.NewTable()
+		res.RawSetString("created", luaStrings(l, created))
+		res.RawSetString("dropped", luaStrings(l, dropped))
+		l.Push(res)
+		return 1
+	}
+}
+
+func luaStrings(l *lua.LState, ss []string) *lua.LTable {
+	t := l.CreateTable(len(ss), 0)
+	for _, s := range ss {
+		t.Append(lua.LString(s))
+	}
+	return t
+}
@@ -0,0 +1,158 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func openPartitionTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("CREATE TABLE events (id INTEGER, at DATETIME); CREATE TABLE events_archive (id INTEGER)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	return db
+}
+
+func tableNames(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestRotatePartitions(t *testing.T) {
+	db := openPartitionTestDB(t)
+	ctx := context.Background()
+	d := golumn.SQLitePartitions{}
+	day := func(d int) time.Time { return time.Date(2024, 7, d, 0, 0, 0, 0, time.UTC) }
+
+	for _, from := range []time.Time{day(1), day(9)} {
+		if err := golumn.CreatePartition(ctx, db, d, "events", from, from.AddDate(0, 0, 1)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	now := time.Date(2024, 7, 10, 15, 30, 0, 0, time.UTC)
+	created, dropped, err := golumn.RotatePartitions(ctx, db, d, "events", golumn.PartitionDaily, now, 2, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"events_p20240710", "events_p20240711", "events_p20240712"}; !slices.Equal(created, want) {
+		t.Errorf("created %v, want %v", created, want)
+	}
+	if want := []string{"events_p20240701"}; !slices.Equal(dropped, want) {
+		t.Errorf("dropped %v, want %v", dropped, want)
+	}
+	want := []string{"events", "events_archive", "events_p20240709", "events_p20240710", "events_p20240711", "events_p20240712"}
+	if got := tableNames(t, db); !slices.Equal(got, want) {
+		t.Errorf("tables %v, want %v", got, want)
+	}
+
+	// Rotating again is a no-op.
+	created, dropped, err = golumn.RotatePartitions(ctx, db, d, "events", golumn.PartitionDaily, now, 2, 1)
+	if err != nil || len(created) != 0 || len(dropped) != 0 {
+		t.Errorf("expected no-op, got %v, %v, %v", created, dropped, err)
+	}
+
+	if err := golumn.CreatePartition(ctx, db, d, "events", day(2), day(1)); err == nil {
+		t.Error("expected error for an empty range")
+	}
+}
+
+func TestRotatePartitions_Monthly(t *testing.T) {
+	db := openPartitionTestDB(t)
+	created, _, err := golumn.RotatePartitions(context.Background(), db, golumn.SQLitePartitions{}, "events", golumn.PartitionMonthly, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 1, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"events_p20241201", "events_p20250101"}; !slices.Equal(created, want) {
+		t.Errorf("created %v, want %v", created, want)
+	}
+}
+
+func TestPartitionDialects(t *testing.T) {
+	from := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	tests := []struct {
+		dialect    golumn.PartitionDialect
+		wantCreate string
+		wantDrop   string
+	}{
+		{
+			golumn.PostgresPartitions{},
+			`CREATE TABLE IF NOT EXISTS "events_p20240701" PARTITION OF "events" FOR VALUES FROM ('2024-07-01T00:00:00Z') TO ('2024-08-01T00:00:00Z')`,
+			`DROP TABLE IF EXISTS "events_p20240701"`,
+		},
+		{
+			golumn.MySQLPartitions{},
+			"ALTER TABLE `events` ADD PARTITION (PARTITION `events_p20240701` VALUES LESS THAN (TO_DAYS('2024-08-01')))",
+			"ALTER TABLE `events` DROP PARTITION `events_p20240701`",
+		},
+	}
+	for _, tt := range tests {
+		name := golumn.PartitionName("events", from)
+		if got := tt.dialect.CreatePartition("events", name, from, to); got != tt.wantCreate {
+			t.Errorf("%T create:\n got %s\nwant %s", tt.dialect, got, tt.wantCreate)
+		}
+		if got := tt.dialect.DropPartition("events", name); got != tt.wantDrop {
+			t.Errorf("%T drop:\n got %s\nwant %s", tt.dialect, got, tt.wantDrop)
+		}
+	}
+}
+
+func TestLuaPartitions(t *testing.T) {
+	db := openPartitionTestDB(t)
+	src := `Version=1
+local db = require "db"
+function Up()
+  local name, err = db.create_partition("events", "2024-07-01", "2024-07-02")
+  if err then error(err) end
+  if name ~= "events_p20240701" then error("unexpected name " .. name) end
+  local res, err = db.rotate_partitions("events", { interval = "daily", ahead = 1, keep = 0, now = "2024-07-05" })
+  if err then error(err) end
+  if #res.created ~= 2 or res.dropped[1] ~= "events_p20240701" then error("unexpected rotation") end
+end
+function Down()
+  assert(db.drop_partition("events", "2024-07-05"))
+end
+`
+	m := golumn.LuaMigration(1, "0001_partitions.lua", src)
+	ctx := golumn.WithPartitionDialect(context.Background(), golumn.SQLitePartitions{})
+	if err := m.Up(ctx, db); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Down(ctx, db); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"events", "events_archive", "events_p20240706"}
+	if got := tableNames(t, db); !slices.Equal(got, want) {
+		t.Errorf("tables %v, want %v", got, want)
+	}
+
+	if err := m.Up(context.Background(), db); err == nil || !strings.Contains(err.Error(), "no partition dialect configured") {
+		t.Errorf("expected missing dialect error, got %v", err)
+	}
+}
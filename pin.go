@@ -0,0 +1,81 @@
+package golumn
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultPinFile is the conventional name of a pin file, committed alongside
+// the code that deploys the migrations.
+const DefaultPinFile = ".golumn-version"
+
+// ErrBeyondPin is returned by Up and UpOnly for a target above the version
+// in Migrator.PinFile.
+var ErrBeyondPin = errors.New("target is beyond the pinned version")
+
+// ReadPinFile reads the version from a pin file: the first line that is
+// neither blank nor a # comment.
+func ReadPinFile(name string) (int64, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		v, err := strconv.ParseInt(line, 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("%s: invalid pinned version %q", name, line)
+		}
+		return v, nil
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("%s: no pinned version", name)
+}
+
+// pin returns the version in PinFile, and false if PinFile is not set or
+// does not exist.
+func (m *Migrator) pin() (int64, bool, error) {
+	if m.PinFile == "" {
+		return 0, false, nil
+	}
+	v, err := ReadPinFile(m.PinFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read pin file: %w", err)
+	}
+	return v, true, nil
+}
+
+// pinTarget resolves a Pinned Up target to the pinned version, and checks
+// any other resolved target against the pin, if there is one.
+func (m *Migrator) pinTarget(target int64) (int64, error) {
+	pin, pinned, err := m.pin()
+	switch {
+	case err != nil:
+		return target, err
+	case target == Pinned && !pinned:
+		return target, fmt.Errorf("%w: Pinned without a pin file", ErrInvalidTarget)
+	case target == Pinned:
+		if hi := MaxVersion(m.migrations()); pin > hi {
+			return target, fmt.Errorf("%w: pinned version %d is above the highest source version %d", ErrInvalidTarget, pin, hi)
+		}
+		return pin, nil
+	case pinned && target > pin:
+		return target, fmt.Errorf("%w: %d is above pinned version %d", ErrBeyondPin, target, pin)
+	}
+	return target, nil
+}
@@ -0,0 +1,118 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func writePinFile(t *testing.T, content string) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), golumn.DefaultPinFile)
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReadPinFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int64
+		wantErr bool
+	}{
+		{name: "plain", content: "42\n", want: 42},
+		{name: "comments", content: "# pinned for the 1.x branch\n\n  7  \n", want: 7},
+		{name: "empty", content: "# nothing\n", wantErr: true},
+		{name: "negative", content: "-1\n", wantErr: true},
+		{name: "garbage", content: "latest\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := golumn.ReadPinFile(writePinFile(t, tt.content))
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected error, got version %d", v)
+				}
+				return
+			}
+			if err != nil || v != tt.want {
+				t.Errorf("got %d, %v, want %d", v, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrator_Pinned(t *testing.T) {
+	pin := writePinFile(t, "2\n")
+
+	t.Run("pinned_target", func(t *testing.T) {
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2, 3), PinFile: pin}
+		res, err := migrator.Up(context.Background(), golumn.Pinned)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(store.applied, []int64{1, 2}) || res.Version != 2 {
+			t.Errorf("expected [1 2] applied, got %v (version %d)", store.applied, res.Version)
+		}
+	})
+
+	t.Run("beyond_pin", func(t *testing.T) {
+		for _, to := range []int64{golumn.Latest, 3} {
+			store := &fakeStore{}
+			migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2, 3), PinFile: pin}
+			if _, err := migrator.Up(context.Background(), to); !errors.Is(err, golumn.ErrBeyondPin) {
+				t.Errorf("Up(%d): expected ErrBeyondPin, got %v", to, err)
+			}
+			if len(store.applied) != 0 {
+				t.Errorf("Up(%d): expected nothing applied, got %v", to, store.applied)
+			}
+		}
+	})
+
+	t.Run("up_only_beyond_pin", func(t *testing.T) {
+		store := newListingStore()
+		migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2, 3), PinFile: pin}
+		if _, err := migrator.UpOnly(context.Background(), []int64{3}); !errors.Is(err, golumn.ErrBeyondPin) {
+			t.Errorf("expected ErrBeyondPin, got %v", err)
+		}
+	})
+
+	t.Run("within_pin", func(t *testing.T) {
+		migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1, 2), PinFile: pin}
+		if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("missing_pin_file", func(t *testing.T) {
+		migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1, 2, 3), PinFile: filepath.Join(t.TempDir(), "missing")}
+		if _, err := migrator.Up(context.Background(), golumn.Pinned); !errors.Is(err, golumn.ErrInvalidTarget) {
+			t.Errorf("expected ErrInvalidTarget for Pinned without a pin file, got %v", err)
+		}
+		if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+			t.Errorf("a missing pin file should pin nothing: %v", err)
+		}
+	})
+
+	t.Run("pin_above_sources", func(t *testing.T) {
+		migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1), PinFile: pin}
+		if _, err := migrator.Up(context.Background(), golumn.Pinned); !errors.Is(err, golumn.ErrInvalidTarget) {
+			t.Errorf("expected ErrInvalidTarget, got %v", err)
+		}
+	})
+
+	t.Run("down", func(t *testing.T) {
+		migrator := &golumn.Migrator{Store: &fakeStore{versions: []int64{1}}, Sources: createMigrations(1), PinFile: pin}
+		if _, err := migrator.Down(context.Background(), golumn.Pinned); !errors.Is(err, golumn.ErrInvalidTarget) {
+			t.Errorf("expected ErrInvalidTarget for Down to Pinned, got %v", err)
+		}
+	})
+}
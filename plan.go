@@ -0,0 +1,106 @@
+package golumn
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Plan describes the migrations a run sets out to apply or revert, in the
+// order it runs them. Every run reports its plan in Result.Plan, a dry run
+// without running it, so that CLI output, JSON reports and AfterSuccess hooks
+// describe runs the same way.
+type Plan struct {
+	Direction Direction `json:"direction"`
+	// From is the store version when the plan was made, or Initial.
+	From int64 `json:"from"`
+	// Target is the run's target version, with Latest resolved to the
+	// highest source version.
+	Target int64      `json:"target"`
+	Steps  []PlanStep `json:"steps"`
+	// Statements holds the query plans of the steps' statements, in step
+	// order, for a dry run made WithExplain.
+	Statements []StatementPlan `json:"statements,omitempty"`
+}
+
+// PlanStep is a single migration in a Plan.
+type PlanStep struct {
+	Version int64  `json:"version"`
+	Name    string `json:"name,omitempty"`
+	// Impact is what the statements the step runs touch, or nil if they
+	// touch nothing or are unknown, as for Lua, Go and lazily loaded
+	// migrations.
+	Impact *Impact `json:"impact,omitempty"`
+}
+
+func newPlan(dir Direction, from, target int64, migrations []*Migration) *Plan {
+	p := &Plan{Direction: dir, From: from, Target: target, Steps: make([]PlanStep, len(migrations))}
+	for i, migration := range migrations {
+		p.Steps[i] = PlanStep{Version: migration.Version, Name: migration.Name, Impact: migration.knownImpact(dir)}
+	}
+	return p
+}
+
+// Versions returns the version of each step, in order.
+func (p *Plan) Versions() []int64 {
+	versions := make([]int64, len(p.Steps))
+	for i, step := range p.Steps {
+		versions[i] = step.Version
+	}
+	return versions
+}
+
+func (p *Plan) String() string {
+	return p.Format(DefaultCatalog)
+}
+
+// Format renders the plan with messages from c, one line per step.
+func (p *Plan) Format(c Catalog) string {
+	var b strings.Builder
+	b.WriteString(c.Sprintf(MsgPlan, c.Direction(p.Direction), p.From, p.Target, len(p.Steps)))
+	for _, step := range p.Steps {
+		b.WriteString("\n  ")
+		b.WriteString(strings.TrimSpace(c.Sprintf(MsgPlanStep, step.Version, step.Name)))
+		for _, line := range step.Impact.Lines(c) {
+			b.WriteString("\n    ")
+			b.WriteString(line)
+		}
+		for _, sp := range p.Statements {
+			if sp.Version != step.Version {
+				continue
+			}
+			b.WriteString("\n    ")
+			first, _, _ := strings.Cut(sp.SQL, "\n")
+			b.WriteString(c.Sprintf(MsgPlanStatement, sp.Statement, first))
+			detail := sp.Plan
+			if sp.Error != "" {
+				detail = c.Sprintf(MsgPlanExplainError, sp.Error)
+			}
+			for _, line := range strings.Split(detail, "\n") {
+				b.WriteString("\n      ")
+				b.WriteString(line)
+			}
+		}
+	}
+	return b.String()
+}
+
+// Plan returns the plan of an Up or Down run to version to, made as a dry
+// run with opts.
+func (m *Migrator) Plan(ctx context.Context, dir Direction, to int64, opts ...RunOption) (*Plan, error) {
+	opts = append(opts, WithDryRun())
+	var res *Result
+	var err error
+	switch dir {
+	case DirectionUp:
+		res, err = m.Up(ctx, to, opts...)
+	case DirectionDown:
+		res, err = m.Down(ctx, to, opts...)
+	default:
+		return nil, fmt.Errorf("unknown direction: %q", dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.Plan, nil
+}
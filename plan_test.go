@@ -0,0 +1,95 @@
+package golumn_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigrator_Plan(t *testing.T) {
+	store := &fakeStore{versions: []int64{1}}
+	sources := createMigrations(1, 2, 3)
+	sources[1].Name = "0002_add_users.sql"
+	migrator := &golumn.Migrator{Store: store, Sources: sources}
+
+	plan, err := migrator.Plan(context.Background(), golumn.DirectionUp, golumn.Latest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &golumn.Plan{
+		Direction: golumn.DirectionUp,
+		From:      1,
+		Target:    3,
+		Steps:     []golumn.PlanStep{{Version: 2, Name: "0002_add_users.sql"}, {Version: 3}},
+	}
+	if plan.Direction != want.Direction || plan.From != want.From || plan.Target != want.Target || !slices.Equal(plan.Steps, want.Steps) {
+		t.Errorf("plan mismatch\nwant: %+v\ngot:  %+v", want, plan)
+	}
+	if len(store.applied) != 0 {
+		t.Errorf("planning should not apply migrations, got %v", store.applied)
+	}
+
+	plan, err = migrator.Plan(context.Background(), golumn.DirectionDown, golumn.Initial)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(plan.Versions(), []int64{1}) || plan.From != 1 || plan.Target != golumn.Initial {
+		t.Errorf("unexpected down plan: %+v", plan)
+	}
+
+	empty := &golumn.Migrator{Store: &fakeStore{}, Sources: sources}
+	plan, err = empty.Plan(context.Background(), golumn.DirectionDown, golumn.Initial)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan == nil || len(plan.Steps) != 0 || plan.Direction != golumn.DirectionDown {
+		t.Errorf("expected an empty down plan for an empty store, got %+v", plan)
+	}
+
+	if _, err := migrator.Plan(context.Background(), "sideways", 1); err == nil {
+		t.Error("expected error for unknown direction")
+	}
+}
+
+func TestResult_Plan(t *testing.T) {
+	var got *golumn.Plan
+	migrator := &golumn.Migrator{
+		Store:   &fakeStore{},
+		Sources: createMigrations(1, 2),
+		AfterSuccess: func(_ context.Context, res *golumn.Result) error {
+			got = res.Plan
+			return nil
+		},
+	}
+
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Plan == nil || !slices.Equal(res.Plan.Versions(), res.Applied) {
+		t.Errorf("expected plan to match applied %v, got %+v", res.Applied, res.Plan)
+	}
+	if got != res.Plan {
+		t.Error("expected AfterSuccess to see the run's plan")
+	}
+}
+
+func TestPlan_JSON(t *testing.T) {
+	plan := &golumn.Plan{
+		Direction: golumn.DirectionDown,
+		From:      2,
+		Target:    golumn.Initial,
+		Steps:     []golumn.PlanStep{{Version: 2, Name: "0002.lua"}, {Version: 1}},
+	}
+	b, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"direction":"down","from":2,"target":-1,"steps":[{"version":2,"name":"0002.lua"},{"version":1}]}`
+	if string(b) != want {
+		t.Errorf("json mismatch\nwant: %s\ngot:  %s", want, b)
+	}
+}
@@ -0,0 +1,126 @@
+package golumn
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// luaProfiler times the frames of a Lua migration run for WithLuaProfile.
+// gopher-lua has no debug hooks, so frames are entered by wrappers: one
+// around each global Lua function, so the time of local functions counts
+// towards the global function calling them, and one around each db module
+// function and transaction and cursor method. A nil *luaProfiler wraps
+// nothing.
+type luaProfiler struct {
+	stack []profileFrame
+	// self is the time spent in each stack of frame names, joined by ";",
+	// excluding the time spent in frames called from it.
+	self map[string]time.Duration
+}
+
+type profileFrame struct {
+	name  string
+	start time.Time
+	// children is the time spent in frames called from this one.
+	children time.Duration
+}
+
+func newLuaProfiler(w io.Writer) *luaProfiler {
+	if w == nil {
+		return nil
+	}
+	return &luaProfiler{self: map[string]time.Duration{}}
+}
+
+func (p *luaProfiler) enter(name string) {
+	if p == nil {
+		return
+	}
+	p.stack = append(p.stack, profileFrame{name: name, start: time.Now()})
+}
+
+func (p *luaProfiler) exit() {
+	if p == nil || len(p.stack) == 0 {
+		return
+	}
+	top := p.stack[len(p.stack)-1]
+	elapsed := time.Since(top.start)
+
+	names := make([]string, len(p.stack))
+	for i, f := range p.stack {
+		names[i] = f.name
+	}
+	p.self[strings.Join(names, ";")] += elapsed - top.children
+
+	p.stack = p.stack[:len(p.stack)-1]
+	if len(p.stack) > 0 {
+		p.stack[len(p.stack)-1].children += elapsed
+	}
+}
+
+// wrap returns fn timed as a frame named name.
+func (p *luaProfiler) wrap(name string, fn lua.LGFunction) lua.LGFunction {
+	if p == nil {
+		return fn
+	}
+	return func(l *lua.LState) int {
+		p.enter(name)
+		defer p.exit()
+		return fn(l)
+	}
+}
+
+// wrapFuncs wraps each of fns as a frame named prefix followed by its key.
+func (p *luaProfiler) wrapFuncs(prefix string, fns map[string]lua.LGFunction) map[string]lua.LGFunction {
+	if p == nil {
+		return fns
+	}
+	wrapped := make(map[string]lua.LGFunction, len(fns))
+	for name, fn := range fns {
+		wrapped[name] = p.wrap(prefix+name, fn)
+	}
+	return wrapped
+}
+
+// wrapGlobals replaces each global Lua function with a wrapper timing it as
+// a frame named after the global.
+func (p *luaProfiler) wrapGlobals(l *lua.LState) {
+	if p == nil {
+		return
+	}
+	var names []string
+	l.G.Global.ForEach(func(k, v lua.LValue) {
+		name, ok := k.(lua.LString)
+		fn, isFn := v.(*lua.LFunction)
+		if ok && isFn && !fn.IsG {
+			names = append(names, string(name))
+		}
+	})
+	for _, name := range names {
+		fn := l.GetGlobal(name)
+		l.SetGlobal(name, l.NewFunction(p.wrap(name, func(l *lua.LState) int {
+			l.Insert(fn, 1)
+			l.Call(l.GetTop()-1, lua.MultRet)
+			return l.GetTop()
+		})))
+	}
+}
+
+// writeTo writes the profile in the folded stack format read by flame
+// graph tools, one "frame;frame;frame microseconds" line per stack, sorted
+// by stack.
+func (p *luaProfiler) writeTo(w io.Writer) error {
+	var buf bytes.Buffer
+	for _, stack := range slices.Sorted(maps.Keys(p.self)) {
+		fmt.Fprintf(&buf, "%s %d\n", stack, p.self[stack].Microseconds())
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
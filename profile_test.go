@@ -0,0 +1,75 @@
+package golumn_test
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestWithLuaProfile(t *testing.T) {
+	src := `
+Version = 1
+local db = require("db")
+
+local function insert(n)
+  db.exec("INSERT INTO t (n) VALUES (?)", n)
+end
+
+function fill()
+  for i = 1, 3 do insert(i) end
+end
+
+function Up()
+  db.exec("CREATE TABLE t (n INTEGER)")
+  fill()
+  for row in db.query("SELECT n FROM t") do end
+end
+
+function Down() end
+`
+	m, err := golumn.Parse(context.Background(), strings.NewReader(src), "0001_fill.lua")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	db := openLuaTestDB(t, 0)
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: []*golumn.Migration{m}}
+
+	var profile bytes.Buffer
+	if _, err := migrator.Up(context.Background(), golumn.Latest, golumn.WithLuaProfile(&profile)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var stacks []string
+	for line := range strings.Lines(profile.String()) {
+		stack, us, ok := strings.Cut(strings.TrimSuffix(line, "\n"), " ")
+		if _, err := strconv.ParseInt(us, 10, 64); !ok || err != nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		stacks = append(stacks, stack)
+	}
+	want := []string{
+		"0001_fill.lua",
+		"0001_fill.lua;Up",
+		"0001_fill.lua;Up;db.exec",
+		"0001_fill.lua;Up;db.query",
+		"0001_fill.lua;Up;fill",
+		"0001_fill.lua;Up;fill;db.exec",
+		"0001_fill.lua;Up;rows",
+	}
+	if strings.Join(stacks, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got stacks\n%s\nwant\n%s", strings.Join(stacks, "\n"), strings.Join(want, "\n"))
+	}
+
+	profile.Reset()
+	if _, err := migrator.Down(context.Background(), golumn.Initial); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profile.Len() != 0 {
+		t.Errorf("expected no profile without WithLuaProfile, got %q", profile.String())
+	}
+}
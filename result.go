@@ -0,0 +1,73 @@
+package golumn
+
+import "time"
+
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Result summarizes a single Up or Down run. It is returned alongside any
+// error, reflecting the work done before the failure.
+type Result struct {
+	Direction Direction
+	Applied   []int64
+	Reverted  []int64
+	// Skipped counts the migrations the run could have run but left out
+	// of its plan: for Up, pending migrations above the target or held
+	// back by a disabled RequiresFlag; for Down, applied migrations at or
+	// below the target, or other than the one DownOnly reverts.
+	// Migrations already applied, for Up, or not applied, for Down, are
+	// not counted, nor are those planned but not run after a failure.
+	Skipped  int
+	Duration time.Duration
+	// Version is the store version after the run, or Initial if no
+	// migrations are recorded.
+	Version int64
+
+	// Plan is what the run set out to do, or nil if it failed before
+	// reading the store. DryRun reports that the run was made with
+	// WithDryRun, and so stopped once its plan was made.
+	Plan   *Plan
+	DryRun bool
+
+	// Anomalies lists the discrepancies between the store and Sources found
+	// after the run with VerifyAfterRun.
+	Anomalies []Anomaly
+}
+
+// done returns the number of migrations the run applied, or reverted for
+// Down, or for a dry run planned to.
+func (r *Result) done() int {
+	if r.DryRun {
+		if r.Plan == nil {
+			return 0
+		}
+		return len(r.Plan.Steps)
+	}
+	if r.Direction == DirectionDown {
+		return len(r.Reverted)
+	}
+	return len(r.Applied)
+}
+
+func (r *Result) String() string {
+	return r.Format(DefaultCatalog)
+}
+
+// Format renders the result with messages from c.
+func (r *Result) Format(c Catalog) string {
+	if r.DryRun {
+		return c.Sprintf(MsgResultDryRun, c.Direction(r.Direction), r.done(), r.Skipped, r.Duration, r.Version)
+	}
+	switch {
+	case r.Direction == DirectionDown:
+		return c.Sprintf(MsgResultDown, len(r.Reverted), r.Skipped, r.Duration, r.Version)
+	case len(r.Reverted) > 0:
+		return c.Sprintf(MsgResultUpReverted, len(r.Applied), len(r.Reverted), r.Skipped, r.Duration, r.Version)
+	default:
+		return c.Sprintf(MsgResultUp, len(r.Applied), r.Skipped, r.Duration, r.Version)
+	}
+}
@@ -0,0 +1,130 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var ErrOutsideWindow = errors.New("outside maintenance window")
+
+// scheduleRetryInterval is how long WaitForWindow sleeps before consulting a
+// Schedule that could not say when its next window opens.
+var scheduleRetryInterval = time.Minute
+
+// Schedule decides when migration runs may start. Check reports whether t
+// falls inside a window and, if not, when the next one opens; a zero next
+// means it is unknown.
+type Schedule interface {
+	Check(t time.Time) (ok bool, next time.Time)
+}
+
+// ScheduleFunc adapts a function to a Schedule.
+type ScheduleFunc func(time.Time) (bool, time.Time)
+
+func (f ScheduleFunc) Check(t time.Time) (bool, time.Time) {
+	return f(t)
+}
+
+// Window is a recurring daily maintenance window from Start to End, both
+// offsets from midnight in Location (UTC if nil). An End at or before Start
+// makes the window run past midnight. Days limits the days on which the
+// window opens; empty means every day.
+type Window struct {
+	Days     []time.Weekday
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+func (w Window) opensOn(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, day)
+}
+
+// opening returns the time the window opens on the day offset days after
+// the one containing t.
+func (w Window) opening(t time.Time, offset int) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+offset, 0, 0, 0, 0, t.Location()).Add(w.Start)
+}
+
+func (w Window) Check(t time.Time) (bool, time.Time) {
+	loc := w.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+
+	length := w.End - w.Start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+
+	var next time.Time
+	// Yesterday's window may still be open if it runs past midnight.
+	for offset := -1; offset <= 7; offset++ {
+		open := w.opening(t, offset)
+		if !w.opensOn(open.Weekday()) {
+			continue
+		}
+		if !t.Before(open) && t.Before(open.Add(length)) {
+			return true, time.Time{}
+		}
+		if open.After(t) && (next.IsZero() || open.Before(next)) {
+			next = open
+		}
+	}
+	return false, next
+}
+
+// Windows is a Schedule open whenever any of its windows is.
+type Windows []Window
+
+func (ws Windows) Check(t time.Time) (bool, time.Time) {
+	var next time.Time
+	for _, w := range ws {
+		ok, n := w.Check(t)
+		if ok {
+			return true, time.Time{}
+		}
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return false, next
+}
+
+// awaitWindow returns nil once the run may start. Outside a window it fails
+// with ErrOutsideWindow, or with WaitForWindow blocks until one opens.
+func (m *Migrator) awaitWindow(ctx context.Context) error {
+	if m.Schedule == nil {
+		return nil
+	}
+	for {
+		ok, next := m.Schedule.Check(time.Now())
+		if ok {
+			return nil
+		}
+		if !m.WaitForWindow {
+			if next.IsZero() {
+				return ErrOutsideWindow
+			}
+			return fmt.Errorf("%w: next window opens at %s", ErrOutsideWindow, next.Format(time.RFC3339))
+		}
+
+		wait := scheduleRetryInterval
+		if !next.IsZero() {
+			wait = time.Until(next)
+			m.logger().Infof("outside maintenance window, waiting until %s", next.Format(time.RFC3339))
+		} else {
+			m.logger().Infof("outside maintenance window, checking again in %s", wait)
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(ErrOutsideWindow, ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
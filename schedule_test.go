@@ -0,0 +1,113 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestWindow_Check(t *testing.T) {
+	// 2024-06-01 is a Saturday.
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2024, 6, day, hour, min, 0, 0, time.UTC)
+	}
+
+	nightly := golumn.Window{Start: 22 * time.Hour, End: 4 * time.Hour}
+	weekend := golumn.Window{Days: []time.Weekday{time.Saturday, time.Sunday}, Start: 2 * time.Hour, End: 6 * time.Hour}
+
+	tests := []struct {
+		name     string
+		schedule golumn.Schedule
+		t        time.Time
+		wantOK   bool
+		wantNext time.Time
+	}{
+		{"before_nightly", nightly, at(3, 12, 0), false, at(3, 22, 0)},
+		{"in_nightly", nightly, at(3, 23, 0), true, time.Time{}},
+		{"nightly_past_midnight", nightly, at(4, 3, 59), true, time.Time{}},
+		{"after_nightly", nightly, at(4, 4, 0), false, at(4, 22, 0)},
+		{"weekend_open", weekend, at(1, 2, 0), true, time.Time{}},
+		{"weekend_closed_weekday", weekend, at(3, 3, 0), false, at(8, 2, 0)},
+		{"weekend_after_sunday", weekend, at(2, 7, 0), false, at(8, 2, 0)},
+		{"windows_any", golumn.Windows{weekend, nightly}, at(3, 22, 30), true, time.Time{}},
+		{"windows_earliest_next", golumn.Windows{weekend, nightly}, at(3, 12, 0), false, at(3, 22, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, next := tt.schedule.Check(tt.t)
+			if ok != tt.wantOK {
+				t.Errorf("ok: want %v, got %v", tt.wantOK, ok)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("next: want %s, got %s", tt.wantNext, next)
+			}
+		})
+	}
+}
+
+func TestWindow_Location(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	w := golumn.Window{Start: 1 * time.Hour, End: 3 * time.Hour, Location: loc}
+
+	if ok, _ := w.Check(time.Date(2024, 6, 1, 0, 30, 0, 0, time.UTC)); !ok {
+		t.Error("expected 02:30 local time to be inside the window")
+	}
+	if ok, _ := w.Check(time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC)); ok {
+		t.Error("expected 04:00 local time to be outside the window")
+	}
+}
+
+func TestMigrator_Schedule(t *testing.T) {
+	closed := golumn.ScheduleFunc(func(now time.Time) (bool, time.Time) {
+		return false, now.Add(time.Hour)
+	})
+
+	t.Run("refuses_outside_window", func(t *testing.T) {
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1), Schedule: closed}
+
+		if _, err := migrator.Up(context.Background(), 1); !errors.Is(err, golumn.ErrOutsideWindow) {
+			t.Fatalf("expected ErrOutsideWindow, got %v", err)
+		}
+		if store.initCalls != 0 {
+			t.Errorf("expected store to be untouched, got %d init calls", store.initCalls)
+		}
+	})
+
+	t.Run("waits_for_window", func(t *testing.T) {
+		opens := time.Now().Add(50 * time.Millisecond)
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{
+			Store:   store,
+			Sources: createMigrations(1),
+			Schedule: golumn.ScheduleFunc(func(now time.Time) (bool, time.Time) {
+				return !now.Before(opens), opens
+			}),
+			WaitForWindow: true,
+		}
+
+		if _, err := migrator.Up(context.Background(), 1); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+		if time.Now().Before(opens) {
+			t.Error("run started before window opened")
+		}
+		if len(store.applied) != 1 {
+			t.Errorf("expected migration to be applied, got %v", store.applied)
+		}
+	})
+
+	t.Run("wait_canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1), Schedule: closed, WaitForWindow: true}
+		_, err := migrator.Down(ctx, golumn.DownTargetInitial)
+		if !errors.Is(err, golumn.ErrOutsideWindow) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected outside window and deadline errors, got %v", err)
+		}
+	})
+}
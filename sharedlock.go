@@ -0,0 +1,33 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// lockShared takes the store's shared lock for a read if SharedReads is set
+// and returns a func releasing it. If SharedReads is unset or the store does
+// not support a shared lock, it takes nothing and returns a no-op. The store
+// must have been initialized.
+func (m *Migrator) lockShared(ctx context.Context) (release func() error, err error) {
+	noop := func() error { return nil }
+	locker, ok := m.store().(SharedLocker)
+	if !m.SharedReads || !ok {
+		return noop, nil
+	}
+	if err := m.acquire(ctx, locker.LockShared); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return noop, nil
+		}
+		return nil, fmt.Errorf("failed to get shared version store lock: %w", err)
+	}
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockReleaseTimeout)
+		defer cancel()
+		if err := locker.ReleaseShared(ctx); err != nil {
+			return fmt.Errorf("failed to release shared version store lock: %w", err)
+		}
+		return nil
+	}, nil
+}
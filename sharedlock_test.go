@@ -0,0 +1,94 @@
+package golumn_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestMigrator_SharedReads(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	other := sqlite3store.New(db)
+	if err := other.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	migrator := &golumn.Migrator{
+		Store:    sqlite3store.New(db),
+		Sources:  createMigrations(1, 2),
+		LockWait: 100 * time.Millisecond,
+	}
+	if _, err := migrator.Status(ctx); err != nil {
+		t.Fatalf("expected status to read past the lock without SharedReads, got %v", err)
+	}
+
+	migrator.SharedReads = true
+	if _, err := migrator.Status(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected status to wait out the run's lock, got %v", err)
+	}
+	if err := migrator.Export(ctx, &bytes.Buffer{}); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected export to wait out the run's lock, got %v", err)
+	}
+
+	if err := other.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	st, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if len(st.Pending) != 2 {
+		t.Errorf("expected 2 pending versions, got %v", st.Pending)
+	}
+	if err := other.Lock(ctx); err != nil {
+		t.Errorf("expected status to release the shared lock, got %v", err)
+	}
+	if err := other.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A reader holding the shared lock keeps runs out until it is done.
+	if err := other.LockShared(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Status(ctx); err != nil {
+		t.Errorf("expected readers to share the lock, got %v", err)
+	}
+	if _, err := migrator.Up(ctx, golumn.Latest); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected up to wait out the reader, got %v", err)
+	}
+	if err := other.ReleaseShared(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+}
+
+func TestMigrator_SharedReads_Unsupported(t *testing.T) {
+	store := &fakeStore{lockFunc: func(context.Context, *fakeStore) error { return golumn.ErrLocked }}
+	migrator := &golumn.Migrator{
+		Store:       store,
+		Sources:     createMigrations(1),
+		SharedReads: true,
+	}
+	if _, err := migrator.Status(context.Background()); err != nil {
+		t.Errorf("expected status to read without a shared lock, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var ErrStopped = errors.New("run stopped before completion")
+
+// lockReleaseTimeout bounds releasing the store lock at the end of a run.
+// Release ignores cancellation of the run's context, so that a canceled run
+// still releases the lock rather than leaving it orphaned.
+var lockReleaseTimeout = 10 * time.Second
+
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+type stopContextKey struct{}
+
+// withStop returns a context carrying stop. Once stop is closed, runs using
+// the context finish their current migration and return ErrStopped.
+func withStop(ctx context.Context, stop <-chan struct{}) context.Context {
+	return context.WithValue(ctx, stopContextKey{}, stop)
+}
+
+func stopRequested(ctx context.Context) bool {
+	stop, _ := ctx.Value(stopContextKey{}).(<-chan struct{})
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// RunWithSignals calls run, typically a closure around Up or Down, with
+// SIGINT and SIGTERM handled. The first signal lets the current migration
+// finish, then stops the run with ErrStopped and releases the store lock
+// even if HoldLockOnFailure is set. A second signal cancels the context
+// passed to run, aborting the current migration.
+func (m *Migrator) RunWithSignals(ctx context.Context, run func(context.Context) (*Result, error)) (*Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, stopSignals...)
+	defer signal.Stop(sigs)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case sig := <-sigs:
+			m.logger().Infof("received %s, stopping after the current migration", sig)
+			close(stop)
+		case <-done:
+			return
+		}
+		select {
+		case sig := <-sigs:
+			m.logger().Infof("received %s again, canceling the current migration", sig)
+			cancel()
+		case <-done:
+		}
+	}()
+
+	return run(withStop(ctx, stop))
+}
+
+// release releases the store lock on a context detached from ctx's
+// cancellation and bounded by lockReleaseTimeout.
+func (m *Migrator) release(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lockReleaseTimeout)
+	defer cancel()
+	if err := m.store().Release(ctx); err != nil {
+		return fmt.Errorf("failed to release version store lock: %w", err)
+	}
+	return nil
+}
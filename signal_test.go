@@ -0,0 +1,98 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func interruptSelf(t *testing.T) {
+	t.Helper()
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("failed to find own process: %v", err)
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot send interrupt: %v", err)
+	}
+	// Give the handler time to observe the signal.
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestMigrator_RunWithSignals(t *testing.T) {
+	store := &fakeStore{}
+	sources := createMigrations(1, 2, 3)
+	sources[1].UpFunc = func(context.Context, *sql.DB) error {
+		interruptSelf(t)
+		return nil
+	}
+	migrator := &golumn.Migrator{Store: store, Sources: sources, HoldLockOnFailure: true}
+
+	res, err := migrator.RunWithSignals(context.Background(), func(ctx context.Context) (*golumn.Result, error) {
+		return migrator.Up(ctx, 3)
+	})
+	if !errors.Is(err, golumn.ErrStopped) {
+		t.Fatalf("expected ErrStopped, got %v", err)
+	}
+	if !slices.Equal(res.Applied, []int64{1, 2}) {
+		t.Errorf("expected the current migration to finish, got %v", res.Applied)
+	}
+	if store.locked {
+		t.Error("expected lock to be released after stop")
+	}
+}
+
+func TestMigrator_RunWithSignalsDown(t *testing.T) {
+	store := &fakeStore{versions: []int64{1, 2, 3}}
+	sources := createMigrations(1, 2, 3)
+	sources[2].DownFunc = func(context.Context, *sql.DB) error {
+		interruptSelf(t)
+		return nil
+	}
+	migrator := &golumn.Migrator{Store: store, Sources: sources}
+
+	res, err := migrator.RunWithSignals(context.Background(), func(ctx context.Context) (*golumn.Result, error) {
+		return migrator.Down(ctx, golumn.DownTargetInitial)
+	})
+	if !errors.Is(err, golumn.ErrStopped) {
+		t.Fatalf("expected ErrStopped, got %v", err)
+	}
+	if !slices.Equal(res.Reverted, []int64{3}) {
+		t.Errorf("expected only the current migration to be reverted, got %v", res.Reverted)
+	}
+	if store.locked {
+		t.Error("expected lock to be released after stop")
+	}
+}
+
+func TestMigrator_RunWithSignalsSecondSignal(t *testing.T) {
+	store := &fakeStore{}
+	sources := createMigrations(1)
+	sources[0].UpFunc = func(ctx context.Context, _ *sql.DB) error {
+		interruptSelf(t)
+		interruptSelf(t)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return errors.New("context not canceled by second signal")
+		}
+	}
+	migrator := &golumn.Migrator{Store: store, Sources: sources}
+
+	_, err := migrator.RunWithSignals(context.Background(), func(ctx context.Context) (*golumn.Result, error) {
+		return migrator.Up(ctx, 1)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if store.locked {
+		t.Error("expected lock to be released despite canceled context")
+	}
+}
@@ -0,0 +1,175 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// SoakOptions configures Migrator.Soak.
+type SoakOptions struct {
+	// Cycles is the number of down and up cycles run after the initial Up.
+	// It must be positive.
+	Cycles int
+	// SlowdownFactor is how many times slower than in the first cycle a
+	// later cycle's down or up run may be before it is reported. Zero means
+	// 2.
+	SlowdownFactor float64
+	// Tolerance is how much slower than in the first cycle a run must also
+	// be to be reported, so that noise in fast runs is not. Zero means
+	// 50ms.
+	Tolerance time.Duration
+}
+
+// SoakCycle is the measurements of one Soak cycle. Cycle 0 is the initial
+// Up, which has no Down.
+type SoakCycle struct {
+	Down, Up time.Duration
+	// InUse is the number of the store database's connections in use after
+	// the cycle.
+	InUse int
+	// OpenFiles is the number of file descriptors the process has open
+	// after the cycle, or -1 where it cannot be counted.
+	OpenFiles int
+}
+
+// SoakIssueKind classifies a SoakIssue.
+type SoakIssueKind string
+
+const (
+	// SoakSlowdown is a down or up run much slower than in the first cycle.
+	SoakSlowdown SoakIssueKind = "slowdown"
+	// SoakConnLeak is a growth in database connections in use.
+	SoakConnLeak SoakIssueKind = "connections"
+	// SoakFileLeak is a growth in open file descriptors.
+	SoakFileLeak SoakIssueKind = "files"
+)
+
+// SoakIssue is a problem found by Soak.
+type SoakIssue struct {
+	Kind  SoakIssueKind
+	Cycle int
+	// Detail describes the issue, e.g. "up took 3s, 4.1x the first
+	// cycle's 732ms".
+	Detail string
+}
+
+func (i SoakIssue) String() string {
+	return fmt.Sprintf("cycle %d: %s: %s", i.Cycle, i.Kind, i.Detail)
+}
+
+// SoakReport is the outcome of Migrator.Soak.
+type SoakReport struct {
+	Cycles []SoakCycle
+	Issues []SoakIssue
+}
+
+// Soak applies every migration, then reverts and reapplies them all
+// opts.Cycles times, e.g. in a nightly job against a scratch database, to
+// check that the migration set stays reversible and fast. Each run takes
+// runOpts. It reports the down and up durations of each cycle, and as
+// issues the runs that got much slower than in the first cycle, and any
+// growth in the store database's connections in use or the process's open
+// files, which would point at migrations leaking rows, statements or
+// transactions.
+//
+// A failed run stops the soak, returning its error with the report so far.
+// Soak does not return an error for issues; check SoakReport.Issues.
+func (m *Migrator) Soak(ctx context.Context, opts SoakOptions, runOpts ...RunOption) (*SoakReport, error) {
+	if opts.Cycles <= 0 {
+		return nil, fmt.Errorf("soak cycles must be positive, got %d", opts.Cycles)
+	}
+	if m.Deterministic {
+		return nil, errors.New("soak measures durations and is not deterministic")
+	}
+	factor := opts.SlowdownFactor
+	if factor == 0 {
+		factor = 2
+	}
+	tolerance := opts.Tolerance
+	if tolerance == 0 {
+		tolerance = 50 * time.Millisecond
+	}
+
+	report := &SoakReport{}
+	res, err := m.Up(ctx, Latest, runOpts...)
+	if err != nil {
+		return report, fmt.Errorf("soak cycle 0: %w", err)
+	}
+	base := m.soakCycle(0, res.Duration)
+	report.Cycles = append(report.Cycles, base)
+
+	for i := 1; i <= opts.Cycles; i++ {
+		down, err := m.Down(ctx, Initial, runOpts...)
+		if err != nil {
+			return report, fmt.Errorf("soak cycle %d: %w", i, err)
+		}
+		up, err := m.Up(ctx, Latest, runOpts...)
+		if err != nil {
+			return report, fmt.Errorf("soak cycle %d: %w", i, err)
+		}
+		cycle := m.soakCycle(down.Duration, up.Duration)
+		report.Cycles = append(report.Cycles, cycle)
+
+		if i > 1 {
+			first := report.Cycles[1]
+			for _, run := range []struct {
+				name      string
+				got, want time.Duration
+			}{
+				{"down", cycle.Down, first.Down},
+				{"up", cycle.Up, first.Up},
+			} {
+				if float64(run.got) > factor*float64(run.want) && run.got-run.want > tolerance {
+					report.Issues = append(report.Issues, SoakIssue{
+						Kind:   SoakSlowdown,
+						Cycle:  i,
+						Detail: fmt.Sprintf("%s took %s, %.1fx the first cycle's %s", run.name, run.got, float64(run.got)/float64(run.want), run.want),
+					})
+				}
+			}
+		}
+		if cycle.InUse > base.InUse {
+			report.Issues = append(report.Issues, SoakIssue{
+				Kind:   SoakConnLeak,
+				Cycle:  i,
+				Detail: fmt.Sprintf("%d connections in use, %d after the initial up", cycle.InUse, base.InUse),
+			})
+		}
+		if base.OpenFiles >= 0 && cycle.OpenFiles > base.OpenFiles {
+			report.Issues = append(report.Issues, SoakIssue{
+				Kind:   SoakFileLeak,
+				Cycle:  i,
+				Detail: fmt.Sprintf("%d files open, %d after the initial up", cycle.OpenFiles, base.OpenFiles),
+			})
+		}
+	}
+
+	for _, issue := range report.Issues {
+		m.logger().Infof("soak: %s", issue)
+	}
+	return report, nil
+}
+
+// soakCycle measures the resources in use after a cycle with the given run
+// durations.
+func (m *Migrator) soakCycle(down, up time.Duration) SoakCycle {
+	cycle := SoakCycle{Down: down, Up: up, OpenFiles: openFiles()}
+	if db := m.store().DB(); db != nil {
+		cycle.InUse = db.Stats().InUse
+	}
+	return cycle
+}
+
+// openFiles counts the process's open file descriptors, or returns -1 on
+// systems without /proc/self/fd.
+func openFiles() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	// One descriptor is the directory being read.
+	return len(entries) - 1
+}
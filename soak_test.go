@@ -0,0 +1,89 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func openSoakStore(t *testing.T) *sqlite3store.Sqlite3Store {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return sqlite3store.New(db)
+}
+
+func TestMigrator_Soak(t *testing.T) {
+	migrator := &golumn.Migrator{Store: openSoakStore(t), Sources: createMigrations(1, 2, 3)}
+	report, err := migrator.Soak(context.Background(), golumn.SoakOptions{Cycles: 3})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.Cycles) != 4 || len(report.Issues) != 0 {
+		t.Errorf("expected 4 cycles and no issues, got %+v", report)
+	}
+	if report.Cycles[0].Down != 0 || report.Cycles[0].Up == 0 || report.Cycles[1].Down == 0 {
+		t.Errorf("unexpected durations %+v", report.Cycles)
+	}
+	if status, err := migrator.Status(context.Background()); err != nil || status.Version != 3 {
+		t.Errorf("expected the soak to end applied, got %v (err %v)", status, err)
+	}
+
+	if _, err := migrator.Soak(context.Background(), golumn.SoakOptions{}); err == nil {
+		t.Error("expected an error for zero cycles")
+	}
+}
+
+func TestMigrator_Soak_Issues(t *testing.T) {
+	ups := 0
+	var leaked []*sql.Tx
+	t.Cleanup(func() {
+		for _, tx := range leaked {
+			tx.Rollback()
+		}
+	})
+	migrations := createMigrations(1, 2)
+	// Version 1 gets slower from the third up, cycle 2, on.
+	migrations[0].UpFunc = func(context.Context, *sql.DB) error {
+		if ups++; ups >= 3 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return nil
+	}
+	// Version 2 leaves a transaction open each time it is reverted.
+	migrations[1].DownFunc = func(ctx context.Context, db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		leaked = append(leaked, tx)
+		return err
+	}
+
+	migrator := &golumn.Migrator{Store: openSoakStore(t), Sources: migrations}
+	report, err := migrator.Soak(context.Background(), golumn.SoakOptions{Cycles: 2, Tolerance: time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Each leaked connection also holds the database file open, which is
+	// reported as a files issue where open files can be counted.
+	var kinds []golumn.SoakIssueKind
+	for _, issue := range report.Issues {
+		if issue.Kind == golumn.SoakFileLeak {
+			continue
+		}
+		kinds = append(kinds, issue.Kind)
+		if issue.Kind == golumn.SoakConnLeak && issue.Cycle == 1 && issue.String() != "cycle 1: connections: 1 connections in use, 0 after the initial up" {
+			t.Errorf("unexpected message %q", issue)
+		}
+	}
+	want := []golumn.SoakIssueKind{golumn.SoakConnLeak, golumn.SoakSlowdown, golumn.SoakConnLeak}
+	if len(kinds) != len(want) || kinds[0] != want[0] || kinds[1] != want[1] || kinds[2] != want[2] {
+		t.Errorf("got issues %v, want kinds %v", report.Issues, want)
+	}
+}
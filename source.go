@@ -0,0 +1,66 @@
+package golumn
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+)
+
+// MigrationSource identifies a migration script without holding its parsed
+// form. Open is called each time the script is needed.
+type MigrationSource struct {
+	Version int64
+	Name    string
+	Open    func() (io.ReadCloser, error)
+
+	// Vars holds variables for a templated source, see Preprocess.
+	Vars map[string]string
+
+	// Normalize applies NormalizeSource before the source is preprocessed.
+	Normalize bool
+}
+
+// Load opens and parses the source.
+func (s MigrationSource) Load(ctx context.Context) (*Migration, error) {
+	rc, err := s.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	m, err := parseFile(ctx, bufio.NewReader(rc), s.Name, parseOptions{vars: s.Vars, normalize: s.Normalize})
+	if err != nil {
+		return nil, err
+	}
+	if m.Version != s.Version {
+		return nil, fmt.Errorf("%s: version %d does not match source version %d", s.Name, m.Version, s.Version)
+	}
+	return m, nil
+}
+
+// Migration returns a migration whose Up and Down funcs load the source on
+// each call, so nothing parsed is retained between runs. The MaxDuration of
+// the loaded migration bounds the call from when it is loaded.
+func (s MigrationSource) Migration() *Migration {
+	return &Migration{
+		Version: s.Version,
+		Name:    s.Name,
+		UpFunc: func(ctx context.Context, db *sql.DB) error {
+			m, err := s.Load(ctx)
+			if err != nil {
+				return err
+			}
+			return withMaxDuration(ctx, m, func(ctx context.Context) error { return m.Up(ctx, db) })
+		},
+		DownFunc: func(ctx context.Context, db *sql.DB) error {
+			m, err := s.Load(ctx)
+			if err != nil {
+				return err
+			}
+			return withMaxDuration(ctx, m, func(ctx context.Context) error { return m.Down(ctx, db) })
+		},
+		load: s.Load,
+	}
+}
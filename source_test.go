@@ -0,0 +1,167 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigrationSource_Migration(t *testing.T) {
+	opens := 0
+	src := golumn.MigrationSource{
+		Version: 1,
+		Name:    "0001_init.lua",
+		Open: func() (io.ReadCloser, error) {
+			opens++
+			return io.NopCloser(strings.NewReader("Version=1\nfunction Up() end\nfunction Down() end\n")), nil
+		},
+	}
+
+	m := src.Migration()
+	if opens != 0 {
+		t.Fatalf("expected no opens before run, got %d", opens)
+	}
+	if m.Version != 1 || m.Name != "0001_init.lua" {
+		t.Errorf("unexpected migration metadata: %d %q", m.Version, m.Name)
+	}
+
+	if err := m.Up(context.Background(), nil); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := m.Down(context.Background(), nil); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if opens != 2 {
+		t.Errorf("expected 2 opens, got %d", opens)
+	}
+}
+
+func TestMigrationSource_VersionMismatch(t *testing.T) {
+	src := golumn.MigrationSource{
+		Version: 2,
+		Name:    "0002_init.lua",
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("Version=3\nfunction Up() end\n")), nil
+		},
+	}
+
+	if _, err := src.Load(context.Background()); err == nil {
+		t.Error("expected version mismatch error")
+	}
+	if err := src.Migration().Up(context.Background(), nil); err == nil {
+		t.Error("expected version mismatch error")
+	}
+}
+
+func TestGlobLoader_Lazy(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"0010_second.lua": "Version=10\nfunction Up() error('boom') end\n",
+		"0002_first.sql":  "-- +golumn Up\nSELECT 1;\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	migrations, err := golumn.GlobLoader{Pattern: filepath.Join(dir, "*"), Lazy: true}.Load(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(migrations))
+	}
+	if migrations[0].Version != 2 || migrations[1].Version != 10 {
+		t.Errorf("expected versions [2 10], got [%d %d]", migrations[0].Version, migrations[1].Version)
+	}
+
+	if err := migrations[1].Up(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected script error, got %v", err)
+	}
+}
+
+func TestGlobLoader_LazyInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "init.lua"), []byte("Version=1"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if _, err := (golumn.GlobLoader{Pattern: filepath.Join(dir, "*.lua"), Lazy: true}).Load(context.Background()); err == nil {
+		t.Error("expected error for file name without version")
+	}
+}
+
+type countingLoader struct {
+	calls      int
+	migrations []*golumn.Migration
+	err        error
+}
+
+func (l *countingLoader) Load(context.Context) ([]*golumn.Migration, error) {
+	l.calls++
+	return l.migrations, l.err
+}
+
+func TestMigrator_Loader(t *testing.T) {
+	loader := &countingLoader{migrations: createMigrations(1, 2)}
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{Store: store, Loader: loader}
+
+	if _, err := migrator.Up(context.Background(), 2); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	st, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if st.Version != 2 || len(st.Pending) != 0 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+	if loader.calls != 1 {
+		t.Errorf("expected 1 load, got %d", loader.calls)
+	}
+
+	loader.migrations = createMigrations(1, 2, 3)
+	if err := migrator.Reload(context.Background()); err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	res, err := migrator.Up(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if len(res.Applied) != 1 || res.Applied[0] != 3 {
+		t.Errorf("expected 3 to be applied, got %v", res.Applied)
+	}
+	if loader.calls != 2 {
+		t.Errorf("expected 2 loads, got %d", loader.calls)
+	}
+}
+
+func TestMigrator_LoaderError(t *testing.T) {
+	loader := &countingLoader{err: errors.New("load error")}
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{Store: store, Loader: loader}
+
+	if _, err := migrator.Up(context.Background(), 1); !errors.Is(err, loader.err) {
+		t.Errorf("expected load error, got %v", err)
+	}
+	if store.initCalls != 0 {
+		t.Error("store should not be touched when loading fails")
+	}
+
+	loader.err = nil
+	loader.migrations = createMigrations(1)
+	if _, err := migrator.Up(context.Background(), 1); err != nil {
+		t.Fatalf("up failed after loader recovered: %v", err)
+	}
+	if loader.calls != 2 {
+		t.Errorf("expected a failed load to be retried, got %d calls", loader.calls)
+	}
+}
@@ -0,0 +1,567 @@
+package golumn
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sqlDirectivePrefix = "-- +golumn "
+
+// ParseSQL parses a SQL migration. The version is taken from the numeric
+// prefix of name, e.g. 0001_create_users.sql, and statements are read from
+// the sections following "-- +golumn Up" and "-- +golumn Down" comments.
+// Statements are split on semicolons, except that the lines between
+// "-- +golumn StatementBegin" and "-- +golumn StatementEnd" comments form a
+// single statement, e.g. a procedure body. Other comments set fields of the
+// migration:
+//
+//	-- +golumn DependsOn 3 4      DependsOn
+//	-- +golumn Tags billing slow  Tags
+//	-- +golumn Owner payments     Owner
+//	-- +golumn Env=prod,staging   Envs
+//	-- +golumn RequiresFlag name  RequiresFlag
+//	-- +golumn NoTransaction      NoTransaction
+//	-- +golumn MaxDuration 5m     MaxDuration
+//
+// A "-- +golumn Dialect mysql" comment before the Up and Down sections
+// makes a backslash escape the next character in single- and double-quoted
+// strings when splitting statements, as MySQL does by default; otherwise it
+// does so only in PostgreSQL's E'...' strings. A "-- +golumn Template"
+// comment, which marks the file for Preprocess, is ignored. A leading byte
+// order mark is skipped and CRLF line endings are read as LF; see
+// NormalizeSource for lone CRs. Checksum is computed as configured by
+// WithChecksum on ctx.
+func ParseSQL(ctx context.Context, r io.Reader, name string) (*Migration, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, &SourceError{File: name, Err: err}
+	}
+	f, err := parseSQLFile(bytes.NewReader(src), name)
+	if err != nil {
+		return nil, err
+	}
+	m := &Migration{
+		Version:       f.version,
+		Name:          name,
+		DependsOn:     f.dependsOn,
+		Tags:          f.tags,
+		Owner:         f.owner,
+		Envs:          f.envs,
+		RequiresFlag:  f.requiresFlag,
+		NoTransaction: f.noTransaction,
+		MaxDuration:   f.maxDuration,
+		Checksum:      checksumFromContext(ctx).Sum(src),
+		up:            f.up,
+		down:          f.down,
+	}
+	m.UpFunc = func(ctx context.Context, db *sql.DB) error {
+		return execSQL(ctx, db, name, f.up, m.NoTransaction)
+	}
+	m.DownFunc = func(ctx context.Context, db *sql.DB) error {
+		return execSQL(ctx, db, name, f.down, m.NoTransaction)
+	}
+	return m, nil
+}
+
+// SQLMigration returns a migration that executes pre-split up and down
+// statements in a transaction, or outside of one if NoTransaction is set on
+// the result. Unlike ParseSQL, it has no source positions, so a failed
+// statement is reported by index alone.
+func SQLMigration(version int64, name string, up, down []string) *Migration {
+	upStmts, downStmts := sqlStatements(up), sqlStatements(down)
+	m := &Migration{
+		Version: version,
+		Name:    name,
+		up:      upStmts,
+		down:    downStmts,
+	}
+	m.UpFunc = func(ctx context.Context, db *sql.DB) error {
+		return execSQL(ctx, db, name, upStmts, m.NoTransaction)
+	}
+	m.DownFunc = func(ctx context.Context, db *sql.DB) error {
+		return execSQL(ctx, db, name, downStmts, m.NoTransaction)
+	}
+	return m
+}
+
+// sqlStatement is a statement split from a SQL source, with the range of
+// lines it spans, or zero lines if unknown. A body is a statement defining
+// a routine, which may contain semicolons of its own.
+type sqlStatement struct {
+	sql           string
+	line, endLine int
+	body          bool
+}
+
+func sqlStatements(stmts []string) []sqlStatement {
+	out := make([]sqlStatement, len(stmts))
+	for i, stmt := range stmts {
+		out[i] = sqlStatement{sql: stmt}
+	}
+	return out
+}
+
+func statementSQL(stmts []sqlStatement) []string {
+	out := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		out[i] = stmt.sql
+	}
+	return out
+}
+
+// execSQL runs stmts from the source name in a transaction, or one by one
+// if noTx is set. A failed statement with a known position is reported as a
+// *SourceError at its first line.
+func execSQL(ctx context.Context, db *sql.DB, name string, stmts []sqlStatement, noTx bool) (err error) {
+	if noTx {
+		return execStatements(ctx, db, name, stmts)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("%w (rollback: %v)", err, rbErr)
+			}
+		}
+	}()
+
+	if err := execStatements(ctx, tx, name, stmts); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+type execer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
+
+func execStatements(ctx context.Context, db execer, name string, stmts []sqlStatement) error {
+	for i, stmt := range stmts {
+		if err := paceStatement(ctx); err != nil {
+			return err
+		}
+		res, err := db.ExecContext(ctx, stmt.sql)
+		if err != nil {
+			if stmt.line == 0 {
+				return fmt.Errorf("statement %d: %w", i+1, err)
+			}
+			return &SourceError{File: name, Line: stmt.line, Err: fmt.Errorf("statement %d (lines %d-%d): %w", i+1, stmt.line, stmt.endLine, err)}
+		}
+		recordStatement(ctx, stmt.sql, res)
+	}
+	return nil
+}
+
+type sqlFile struct {
+	version       int64
+	up, down      []sqlStatement
+	dependsOn     []int64
+	tags          []string
+	owner         string
+	envs          []string
+	requiresFlag  string
+	noTransaction bool
+	maxDuration   time.Duration
+	// mysql is set by a "Dialect mysql" directive.
+	mysql bool
+}
+
+// sqlSection accumulates the statements of an Up or Down section. Lines
+// are buffered, remembering the file line each came from, until they are
+// split into statements at the end of the section or of a block.
+type sqlSection struct {
+	src   strings.Builder
+	lines []int
+	stmts []sqlStatement
+	// block is the first line of an open StatementBegin block, or 0.
+	block int
+	// mysql makes backslashes escape characters in quoted strings.
+	mysql bool
+}
+
+func (s *sqlSection) add(line string, lineNo int) {
+	s.src.WriteString(line)
+	s.src.WriteByte('\n')
+	s.lines = append(s.lines, lineNo)
+}
+
+// flush moves the buffered lines to stmts, splitting them on semicolons
+// unless they form a block.
+func (s *sqlSection) flush() {
+	if s.block > 0 {
+		src := s.src.String()
+		// The block is dropped if splitting it finds no code, as for a
+		// block of comments.
+		if stmt := strings.TrimSpace(src); len(splitStatements(src, s.mysql)) > 0 {
+			first := strings.Count(src[:strings.Index(src, stmt)], "\n")
+			last := first + strings.Count(stmt, "\n")
+			s.stmts = append(s.stmts, sqlStatement{sql: stmt, line: s.lines[first], endLine: s.lines[last], body: true})
+		}
+	} else {
+		stmts := splitStatements(s.src.String(), s.mysql)
+		for i := range stmts {
+			stmts[i].line = s.lines[stmts[i].line-1]
+			stmts[i].endLine = s.lines[stmts[i].endLine-1]
+		}
+		s.stmts = append(s.stmts, stmts...)
+	}
+	s.src.Reset()
+	s.lines = nil
+}
+
+func parseSQLFile(r io.Reader, name string) (*sqlFile, error) {
+	version, err := versionFromName(name)
+	if err != nil {
+		return nil, err
+	}
+	f := &sqlFile{version: version}
+
+	var up, down sqlSection
+	var section *sqlSection
+	closed := func() error {
+		if section != nil && section.block > 0 {
+			return &SourceError{File: name, Line: section.block, Err: errors.New("StatementBegin without StatementEnd")}
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(skipBOM(r))
+	scanner.Buffer(nil, 1<<24)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if directive, ok := strings.CutPrefix(strings.TrimSpace(line), sqlDirectivePrefix); ok {
+			switch fields := strings.Fields(directive); {
+			case len(fields) == 1 && (fields[0] == "Up" || fields[0] == "Down"):
+				if err := closed(); err != nil {
+					return nil, err
+				}
+				section = &up
+				if fields[0] == "Down" {
+					section = &down
+				}
+			case len(fields) == 1 && fields[0] == "StatementBegin":
+				switch {
+				case section == nil:
+					return nil, &SourceError{File: name, Line: lineNo, Err: errors.New("StatementBegin outside of Up or Down section")}
+				case section.block > 0:
+					return nil, &SourceError{File: name, Line: lineNo, Err: fmt.Errorf("StatementBegin inside block begun on line %d", section.block)}
+				}
+				section.flush()
+				section.block = lineNo
+			case len(fields) == 1 && fields[0] == "StatementEnd":
+				if section == nil || section.block == 0 {
+					return nil, &SourceError{File: name, Line: lineNo, Err: errors.New("StatementEnd without StatementBegin")}
+				}
+				section.flush()
+				section.block = 0
+			case len(fields) == 1 && fields[0] == "NoTransaction":
+				f.noTransaction = true
+			case len(fields) == 2 && fields[0] == "Dialect":
+				switch {
+				case fields[1] != "mysql":
+					return nil, &SourceError{File: name, Line: lineNo, Err: fmt.Errorf("unknown dialect %q", fields[1])}
+				case section != nil:
+					return nil, &SourceError{File: name, Line: lineNo, Err: errors.New("Dialect after Up or Down section")}
+				}
+				f.mysql, up.mysql, down.mysql = true, true, true
+			case len(fields) == 1 && fields[0] == "Template":
+				// Expanded by Preprocess before parsing.
+			case len(fields) > 0 && fields[0] == "DependsOn":
+				for _, field := range fields[1:] {
+					v, err := strconv.ParseInt(field, 10, 64)
+					if err != nil {
+						return nil, &SourceError{File: name, Line: lineNo, Err: fmt.Errorf("invalid DependsOn version %q", field)}
+					}
+					f.dependsOn = append(f.dependsOn, v)
+				}
+			case len(fields) > 0 && fields[0] == "Tags":
+				f.tags = append(f.tags, fields[1:]...)
+			case len(fields) == 2 && fields[0] == "RequiresFlag":
+				f.requiresFlag = fields[1]
+			case len(fields) == 2 && fields[0] == "Owner":
+				f.owner = fields[1]
+			case len(fields) == 2 && fields[0] == "MaxDuration":
+				d, err := parseMaxDuration(fields[1])
+				if err != nil {
+					return nil, &SourceError{File: name, Line: lineNo, Err: err}
+				}
+				f.maxDuration = d
+			case len(fields) == 1 && strings.HasPrefix(fields[0], "Env="):
+				for _, env := range strings.Split(strings.TrimPrefix(fields[0], "Env="), ",") {
+					if env == "" {
+						return nil, &SourceError{File: name, Line: lineNo, Err: fmt.Errorf("empty environment in %q", directive)}
+					}
+					f.envs = append(f.envs, env)
+				}
+			default:
+				return nil, &SourceError{File: name, Line: lineNo, Err: fmt.Errorf("unknown directive %q", directive)}
+			}
+			continue
+		}
+
+		if section == nil {
+			if strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				return nil, &SourceError{File: name, Line: lineNo, Err: errors.New("statement outside of Up or Down section")}
+			}
+			continue
+		}
+		section.add(line, lineNo)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &SourceError{File: name, Err: err}
+	}
+	if err := closed(); err != nil {
+		return nil, err
+	}
+
+	up.flush()
+	down.flush()
+	f.up, f.down = up.stmts, down.stmts
+	return f, nil
+}
+
+func versionFromName(name string) (int64, error) {
+	end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' })
+	if end == -1 {
+		end = len(name)
+	}
+	if end == 0 {
+		return 0, &SourceError{File: name, Err: errors.New("file name must start with a version number")}
+	}
+	version, err := strconv.ParseInt(name[:end], 10, 64)
+	if err != nil {
+		return 0, &SourceError{File: name, Err: fmt.Errorf("invalid version: %w", err)}
+	}
+	return version, nil
+}
+
+// splitStatements splits src on semicolons that are not inside quoted
+// strings, dollar-quoted bodies or comments, dropping empty and
+// comment-only statements. Each statement's line range, 1-based within src,
+// runs from its first to its last line of SQL, ignoring surrounding
+// comments.
+//
+// Statements creating a trigger, procedure or function are bodies: they
+// are not split inside BEGIN ... END and CASE ... END blocks. A
+// "DELIMITER //" line, as used by MySQL clients, makes "//" the terminator
+// instead until a "DELIMITER ;" line, and statements it terminates are
+// bodies too. Quoted strings are scanned as quoteEnd does for mysql.
+func splitStatements(src string, mysql bool) []sqlStatement {
+	var stmts []sqlStatement
+	var buf strings.Builder
+	line, first, last := 1, 0, 0
+	delim := ";"
+	// create records whether the statement starts with CREATE, routine
+	// whether it creates a trigger, procedure or function, and depth its
+	// nesting of blocks.
+	var create, routine bool
+	var words, depth int
+
+	// write appends chunk to the current statement. Only code chunks, not
+	// comments, extend its line range.
+	write := func(chunk string, code bool) {
+		buf.WriteString(chunk)
+		if trimmed := strings.TrimSpace(chunk); code && trimmed != "" {
+			at := strings.Index(chunk, trimmed)
+			if first == 0 {
+				first = line + strings.Count(chunk[:at], "\n")
+			}
+			last = line + strings.Count(chunk[:at+len(trimmed)], "\n")
+		}
+		line += strings.Count(chunk, "\n")
+	}
+	flush := func() {
+		// A statement without a code chunk, such as a lone comment, is
+		// dropped.
+		if stmt := strings.TrimSpace(buf.String()); first != 0 {
+			stmts = append(stmts, sqlStatement{sql: stmt, line: first, endLine: last, body: routine || delim != ";"})
+		}
+		buf.Reset()
+		first, last = 0, 0
+		create, routine, words, depth = false, false, 0, 0
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if i == 0 || src[i-1] == '\n' {
+			if d, n, ok := delimiterLine(src[i:]); ok {
+				flush()
+				delim = d
+				line += strings.Count(src[i:i+n], "\n")
+				i += n - 1
+				continue
+			}
+		}
+		switch {
+		case delim != ";" && strings.HasPrefix(src[i:], delim):
+			flush()
+			i += len(delim) - 1
+		case c == '\'' || c == '"' || c == '`':
+			end := quoteEnd(src, i, mysql)
+			write(src[i:min(end+1, len(src))], true)
+			i = end
+		case c == '$' && dollarQuoteAt(src, i):
+			end := dollarQuoteEnd(src, i)
+			write(src[i:end], true)
+			i = end - 1
+		case c == '-' && strings.HasPrefix(src[i:], "--"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end == -1 {
+				end = len(src) - i
+			}
+			write(src[i:i+end], false)
+			i += end - 1
+		case c == '/' && strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				end = len(src) - i - 2
+			} else {
+				end += 2
+			}
+			write(src[i:i+2+end], false)
+			i += 1 + end
+		case c == ';' && delim == ";" && depth == 0:
+			flush()
+		case isWordStart(c):
+			end := i + 1
+			for end < len(src) && isWordChar(src[end]) {
+				end++
+			}
+			word := strings.ToUpper(src[i:end])
+			write(src[i:end], true)
+			switch {
+			case words == 0:
+				create = word == "CREATE"
+			case create && depth == 0 && (word == "TRIGGER" || word == "PROCEDURE" || word == "FUNCTION"):
+				routine = true
+			case routine && (word == "BEGIN" || word == "CASE"):
+				depth++
+			case routine && word == "END":
+				// END may name the block it closes, as in END CASE or
+				// END IF. Consume that word so that it opens no block.
+				block, n := blockAfterEnd(src[end:])
+				if depth > 0 && (block == "" || block == "CASE") {
+					depth--
+				}
+				write(src[end:end+n], true)
+				end += n
+			}
+			words++
+			i = end - 1
+		default:
+			write(src[i:i+1], true)
+		}
+	}
+	flush()
+
+	return stmts
+}
+
+// delimiterLine reports whether s starts with a "DELIMITER x" line,
+// returning x and the length of the line including its newline.
+func delimiterLine(s string) (delim string, n int, ok bool) {
+	n = strings.IndexByte(s, '\n') + 1
+	if n == 0 {
+		n = len(s)
+	}
+	fields := strings.Fields(s[:n])
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "DELIMITER") {
+		return "", 0, false
+	}
+	return fields[1], n, true
+}
+
+// quoteEnd returns the index of the quote closing the string or quoted
+// identifier opened by the quote at src[i], or len(src) if it is not closed.
+// A doubled quote does not close it. Nor does a quote escaped with a
+// backslash in a PostgreSQL E'...' string, or in a single- or double-quoted
+// string if mysql is set.
+func quoteEnd(src string, i int, mysql bool) int {
+	c := src[i]
+	escapeString := c == '\'' && i > 0 && (src[i-1] == 'E' || src[i-1] == 'e') && (i == 1 || !isWordChar(src[i-2]))
+	backslash := escapeString || mysql && c != '`'
+	for end := i + 1; end < len(src); end++ {
+		switch {
+		case backslash && src[end] == '\\':
+			end++
+		case src[end] == c && end+1 < len(src) && src[end+1] == c:
+			end++
+		case src[end] == c:
+			return end
+		}
+	}
+	return len(src)
+}
+
+// dollarQuoteAt reports whether a PostgreSQL dollar-quoted string starts at
+// src[i]. As in PostgreSQL, a $ within an identifier, as in a$b$c, does not
+// start one.
+func dollarQuoteAt(src string, i int) bool {
+	return (i == 0 || !isWordChar(src[i-1])) && dollarTag(src[i:]) != ""
+}
+
+// dollarTag returns the opening tag of a PostgreSQL dollar-quoted string at
+// the start of s, e.g. "$$" or "$body$", or "" if there is none.
+func dollarTag(s string) string {
+	j := 1
+	if j < len(s) && isWordStart(s[j]) {
+		for j++; j < len(s) && isWordChar(s[j]); j++ {
+		}
+	}
+	if j < len(s) && s[0] == '$' && s[j] == '$' {
+		return s[:j+1]
+	}
+	return ""
+}
+
+// dollarQuoteEnd returns the index just past the dollar-quoted string
+// starting at src[i], or len(src) if it is not closed.
+func dollarQuoteEnd(src string, i int) int {
+	tag := dollarTag(src[i:])
+	end := strings.Index(src[i+len(tag):], tag)
+	if end == -1 {
+		return len(src)
+	}
+	return i + len(tag) + end + len(tag)
+}
+
+// blockAfterEnd reports whether s, following an END, continues "END CASE",
+// "END IF", "END LOOP", "END WHILE" or "END REPEAT", returning the
+// uppercase block keyword and the length of s up to its end, or "" and 0.
+// Of these blocks only CASE is opened by a word that splitStatements counts.
+func blockAfterEnd(s string) (block string, n int) {
+	start := len(s) - len(strings.TrimLeft(s, " \t\r\n"))
+	n = start
+	for n < len(s) && isWordChar(s[n]) {
+		n++
+	}
+	switch block = strings.ToUpper(s[start:n]); block {
+	case "CASE", "IF", "LOOP", "WHILE", "REPEAT":
+		return block, n
+	}
+	return "", 0
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isWordChar(c byte) bool {
+	return isWordStart(c) || c >= '0' && c <= '9'
+}
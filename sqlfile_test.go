@@ -0,0 +1,525 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jonathonwebb/golumn"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestParseSQL(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		src         string
+		wantErr     bool
+		wantVersion int64
+		wantUp      int
+		wantDown    int
+		wantDeps    []int64
+	}{
+		{
+			name:        "up_and_down",
+			file:        "0001_init.sql",
+			src:         "-- +golumn Up\nCREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);\n-- +golumn Down\nDROP TABLE b;\nDROP TABLE a;\n",
+			wantVersion: 1,
+			wantUp:      2,
+			wantDown:    2,
+		},
+		{
+			name:        "leading_comments",
+			file:        "42.sql",
+			src:         "-- description\n\n-- +golumn Up\nCREATE TABLE a (id INTEGER)\n",
+			wantVersion: 42,
+			wantUp:      1,
+		},
+		{
+			name:        "depends_on",
+			file:        "3_index.sql",
+			src:         "-- +golumn DependsOn 1 2\n-- +golumn Up\nCREATE INDEX i ON a (id);\n",
+			wantVersion: 3,
+			wantUp:      1,
+			wantDeps:    []int64{1, 2},
+		},
+		{
+			name:    "invalid_depends_on",
+			file:    "3_index.sql",
+			src:     "-- +golumn DependsOn one\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing_version",
+			file:    "init.sql",
+			src:     "-- +golumn Up\nCREATE TABLE a (id INTEGER);\n",
+			wantErr: true,
+		},
+		{
+			name:    "statement_outside_section",
+			file:    "1_init.sql",
+			src:     "CREATE TABLE a (id INTEGER);\n",
+			wantErr: true,
+		},
+		{
+			name:        "dialect",
+			file:        "1_init.sql",
+			src:         "-- +golumn Dialect mysql\n-- +golumn Up\nINSERT INTO a VALUES ('it\\'s;');\n",
+			wantVersion: 1,
+			wantUp:      1,
+		},
+		{
+			name:    "unknown_dialect",
+			file:    "1_init.sql",
+			src:     "-- +golumn Dialect oracle\n",
+			wantErr: true,
+		},
+		{
+			name:    "dialect_after_up",
+			file:    "1_init.sql",
+			src:     "-- +golumn Up\n-- +golumn Dialect mysql\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown_directive",
+			file:    "1_init.sql",
+			src:     "-- +golumn Sideways\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := golumn.ParseSQL(context.Background(), strings.NewReader(tt.src), tt.file)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if m.Version != tt.wantVersion {
+				t.Errorf("version: want %d, got %d", tt.wantVersion, m.Version)
+			}
+			if m.Name != tt.file {
+				t.Errorf("name: want %q, got %q", tt.file, m.Name)
+			}
+			if !slices.Equal(m.DependsOn, tt.wantDeps) {
+				t.Errorf("depends on: want %v, got %v", tt.wantDeps, m.DependsOn)
+			}
+		})
+	}
+}
+
+func TestSQLMigration_Exec(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	src := `-- +golumn Up
+CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL);
+/* block comment; with a semicolon */
+INSERT INTO notes (body) VALUES ('semi;colon');
+INSERT INTO notes (body) VALUES ('it''s; quoted'); -- trailing; comment
+
+-- +golumn Down
+DROP TABLE notes;
+`
+	m, err := golumn.ParseSQL(context.Background(), strings.NewReader(src), "0001_notes.sql")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+
+	var bodies []string
+	rows, err := db.Query("SELECT body FROM notes ORDER BY id")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		bodies = append(bodies, body)
+	}
+	rows.Close()
+
+	want := []string{"semi;colon", "it's; quoted"}
+	if !slices.Equal(want, bodies) {
+		t.Errorf("want %q, got %q", want, bodies)
+	}
+
+	if err := m.Down(context.Background(), db); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if _, err := db.Exec("SELECT 1 FROM notes"); err == nil {
+		t.Error("expected notes table to be dropped")
+	}
+}
+
+func TestSQLMigration_Rollback(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	m := golumn.SQLMigration(1, "0001_bad.sql", []string{
+		"CREATE TABLE a (id INTEGER)",
+		"INSERT INTO missing (id) VALUES (1)",
+	}, nil)
+
+	if err := m.Up(context.Background(), db); err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if _, err := db.Exec("SELECT 1 FROM a"); err == nil {
+		t.Error("expected table creation to be rolled back")
+	}
+}
+
+func TestParseSQL_SourceError(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		src      string
+		wantLine int
+	}{
+		{"unknown_directive", "1_init.sql", "-- +golumn Up\nSELECT 1;\n-- +golumn Sideways\n", 3},
+		{"statement_outside_section", "1_init.sql", "-- header\nSELECT 1;\n", 2},
+		{"missing_version", "init.sql", "-- +golumn Up\n", 0},
+		{"unterminated_block", "1_init.sql", "-- +golumn Up\n-- +golumn StatementBegin\nSELECT 1;\n-- +golumn Down\n", 2},
+		{"nested_block", "1_init.sql", "-- +golumn Up\n-- +golumn StatementBegin\n-- +golumn StatementBegin\n", 3},
+		{"block_outside_section", "1_init.sql", "-- +golumn StatementBegin\n", 1},
+		{"end_without_begin", "1_init.sql", "-- +golumn Up\nSELECT 1;\n-- +golumn StatementEnd\n", 3},
+		{"empty_env", "1_init.sql", "-- +golumn Env=prod,\n", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := golumn.ParseSQL(context.Background(), strings.NewReader(tt.src), tt.file)
+			var se *golumn.SourceError
+			if !errors.As(err, &se) {
+				t.Fatalf("expected *SourceError, got %v", err)
+			}
+			if se.File != tt.file || se.Line != tt.wantLine {
+				t.Errorf("got %s:%d, want %s:%d", se.File, se.Line, tt.file, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestSQLMigration_StatementLines(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	src := `-- +golumn Down
+DROP TABLE missing;
+
+-- +golumn Up
+CREATE TABLE a (id INTEGER);
+
+-- leading comment
+INSERT INTO a (id)
+VALUES ('x', 'y'); -- trailing comment
+`
+	m, err := golumn.ParseSQL(context.Background(), strings.NewReader(src), "0001_a.sql")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		run      func(context.Context, *sql.DB) error
+		wantLine int
+		wantMsg  string
+	}{
+		{"up", m.Up, 8, "0001_a.sql:8: statement 2 (lines 8-9): "},
+		{"down", m.Down, 2, "0001_a.sql:2: statement 1 (lines 2-2): "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run(context.Background(), db)
+			var se *golumn.SourceError
+			if !errors.As(err, &se) {
+				t.Fatalf("expected *SourceError, got %v", err)
+			}
+			if se.Line != tt.wantLine || !strings.HasPrefix(err.Error(), tt.wantMsg) {
+				t.Errorf("got %v, want line %d and prefix %q", err, tt.wantLine, tt.wantMsg)
+			}
+		})
+	}
+
+	// Pre-split statements have no positions.
+	err = golumn.SQLMigration(1, "0001_a.sql", []string{"SELECT * FROM missing"}, nil).Up(context.Background(), db)
+	var se *golumn.SourceError
+	if err == nil || errors.As(err, &se) {
+		t.Errorf("expected plain statement error, got %v", err)
+	}
+}
+
+func TestParseSQL_Directives(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	src := `-- +golumn Env=prod,staging
+-- +golumn NoTransaction
+-- +golumn Up
+CREATE TABLE a (id INTEGER);
+CREATE TABLE b (id INTEGER);
+-- +golumn StatementBegin
+CREATE TRIGGER copy AFTER INSERT ON a BEGIN
+	INSERT INTO b (id) VALUES (new.id);
+END;
+-- +golumn StatementEnd
+INSERT INTO a (id) VALUES (1);
+INSERT INTO missing (id) VALUES (1);
+`
+	m, err := golumn.ParseSQL(context.Background(), strings.NewReader(src), "0001_a.sql")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if !slices.Equal(m.Envs, []string{"prod", "staging"}) || !m.NoTransaction {
+		t.Errorf("unexpected directives: envs %v, no transaction %v", m.Envs, m.NoTransaction)
+	}
+
+	// Migrations restricted to environments do not run outside of a run
+	// given one of them.
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := db.Exec("SELECT 1 FROM a"); err == nil {
+		t.Fatal("expected migration to be skipped")
+	}
+
+	m.Envs = nil
+	var se *golumn.SourceError
+	if err := m.Up(context.Background(), db); !errors.As(err, &se) || se.Line != 12 {
+		t.Fatalf("expected failure on line 12, got %v", err)
+	}
+	var copied int
+	if err := db.QueryRow("SELECT count(*) FROM b").Scan(&copied); err != nil || copied != 1 {
+		t.Errorf("expected statements before the failure to be kept and the trigger to copy 1 row, got %d (err %v)", copied, err)
+	}
+}
+
+func TestMigrator_Env(t *testing.T) {
+	var ran []string
+	migrations := createMigrations(1, 2)
+	migrations[1].Envs = []string{"prod"}
+	migrations[1].UpFunc = func(context.Context, *sql.DB) error {
+		ran = append(ran, "prod only")
+		return nil
+	}
+
+	for _, env := range []string{"dev", "prod"} {
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{Store: store, Sources: migrations}
+		if _, err := migrator.Up(context.Background(), golumn.Latest, golumn.WithEnv(env)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", env, err)
+		}
+		if !slices.Equal(store.versions, []int64{1, 2}) {
+			t.Errorf("%s: expected both versions recorded, got %v", env, store.versions)
+		}
+	}
+	if !slices.Equal(ran, []string{"prod only"}) {
+		t.Errorf("expected the restricted migration to run once, ran %v", ran)
+	}
+
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{Store: store, Sources: migrations}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err == nil || !strings.Contains(err.Error(), "sets none") {
+		t.Errorf("expected a run without an environment to fail, got %v", err)
+	}
+	if len(store.versions) != 0 {
+		t.Errorf("expected nothing recorded, got %v", store.versions)
+	}
+}
+
+func TestParseSQL_Bodies(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	src := `-- +golumn Up
+CREATE TABLE a (id INTEGER);
+CREATE TABLE b (kind TEXT);
+CREATE TRIGGER classify AFTER INSERT ON a BEGIN
+	INSERT INTO b (kind) VALUES (CASE WHEN new.id > 1 THEN 'big' ELSE 'small' END);
+	INSERT INTO b (kind) VALUES ('any');
+END;
+DELIMITER //
+CREATE TRIGGER audit AFTER DELETE ON a BEGIN
+	INSERT INTO b (kind) VALUES ('deleted');
+END //
+DELIMITER ;
+INSERT INTO a (id) VALUES (1), (2);
+DELETE FROM a WHERE id = 1;
+`
+	m, err := golumn.ParseSQL(context.Background(), strings.NewReader(src), "0001_a.sql")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var kinds string
+	if err := db.QueryRow("SELECT group_concat(kind, ',') FROM b").Scan(&kinds); err != nil {
+		t.Fatal(err)
+	}
+	if kinds != "small,any,big,any,deleted" {
+		t.Errorf("got %q, want triggers to have run", kinds)
+	}
+}
+
+func TestGenEmbed_Bodies(t *testing.T) {
+	src := `-- +golumn Up
+CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+	NEW.updated := now();
+	RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+SELECT '$$;' AS s, $1;
+DELIMITER $$
+CREATE PROCEDURE p()
+BEGIN
+	IF 1 THEN SELECT 1; END IF;
+	SELECT 2;
+END$$
+DELIMITER ;
+`
+	fsys := fstest.MapFS{"0001_routines.sql": {Data: []byte(src)}}
+	out, err := golumn.GenEmbed(context.Background(), fsys, "migrations", "All")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := strings.Count(string(out), "\n\t\t\t`"); n != 3 {
+		t.Errorf("expected 3 statements, got %d\n%s", n, out)
+	}
+	for _, want := range []string{
+		"`CREATE FUNCTION touch() RETURNS trigger AS $body$\nBEGIN\n\tNEW.updated := now();\n\tRETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql`",
+		"`SELECT '$$;' AS s, $1`",
+		"`CREATE PROCEDURE p()\nBEGIN\n\tIF 1 THEN SELECT 1; END IF;\n\tSELECT 2;\nEND`",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected generated source to contain %s\n%s", want, out)
+		}
+	}
+}
+
+func TestGenEmbed_Splitting(t *testing.T) {
+	tests := []struct {
+		name  string
+		mysql bool
+		src   string
+		want  []string
+	}{
+		{
+			name: "end_case",
+			src:  "CREATE PROCEDURE p() BEGIN CASE x WHEN 1 THEN SELECT 1; END CASE; END; SELECT 2;\n",
+			want: []string{"CREATE PROCEDURE p() BEGIN CASE x WHEN 1 THEN SELECT 1; END CASE; END", "SELECT 2"},
+		},
+		{
+			name: "end_case_after_block",
+			src:  "CREATE PROCEDURE p() BEGIN BEGIN SELECT 1; END; CASE x WHEN 1 THEN SELECT 2; END CASE; END; SELECT 3;\n",
+			want: []string{"CREATE PROCEDURE p() BEGIN BEGIN SELECT 1; END; CASE x WHEN 1 THEN SELECT 2; END CASE; END", "SELECT 3"},
+		},
+		{
+			name: "dollar_in_identifier",
+			src:  "CREATE TABLE a$b$c (id int); SELECT 1;\n",
+			want: []string{"CREATE TABLE a$b$c (id int)", "SELECT 1"},
+		},
+		{
+			name: "escape_string",
+			src:  "SELECT E'it\\'s;'; SELECT 5;\n",
+			want: []string{"SELECT E'it\\'s;'", "SELECT 5"},
+		},
+		{
+			name: "backslash_literal",
+			src:  "SELECT 'C:\\'; SELECT 5;\n",
+			want: []string{"SELECT 'C:\\'", "SELECT 5"},
+		},
+		{
+			name:  "mysql_escapes",
+			mysql: true,
+			src:   "SELECT 'it\\'s;', \"say \\\"hi\\\";\"; SELECT 5;\n",
+			want:  []string{"SELECT 'it\\'s;', \"say \\\"hi\\\";\"", "SELECT 5"},
+		},
+		{
+			name: "block_comment_only",
+			src:  "CREATE TABLE t (id int);\n/* just a comment */;\nSELECT 1;\n",
+			want: []string{"CREATE TABLE t (id int)", "SELECT 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "-- +golumn Up\n" + tt.src
+			if tt.mysql {
+				src = "-- +golumn Dialect mysql\n" + src
+			}
+			fsys := fstest.MapFS{"0001_a.sql": {Data: []byte(src)}}
+			out, err := golumn.GenEmbed(context.Background(), fsys, "migrations", "All")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n := strings.Count(string(out), "\n\t\t\t`"); n != len(tt.want) {
+				t.Errorf("expected %d statements, got %d\n%s", len(tt.want), n, out)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(out), "`"+want+"`") {
+					t.Errorf("expected statement %q\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSQL_Windows(t *testing.T) {
+	src := "\xef\xbb\xbf-- +golumn Tags core\r\n-- +golumn Up\r\nCREATE TABLE a (id INTEGER);\r\nCREATE TABLE b (id INTEGER);\r\n-- +golumn Down\r\nDROP TABLE b;\r\n"
+	m, err := golumn.ParseSQL(context.Background(), strings.NewReader(src), "0001_init.sql")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(m.Tags, []string{"core"}) {
+		t.Errorf("expected the directive after the byte order mark to be read, got tags %q", m.Tags)
+	}
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if err := m.Up(context.Background(), db); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := m.Down(context.Background(), db); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package golumn
+
+import (
+	"context"
+	"slices"
+)
+
+// StatisticsDialect generates the statement refreshing the planner
+// statistics of a table in one SQL dialect, for Migrator.RefreshStatistics.
+type StatisticsDialect interface {
+	// Analyze returns a statement refreshing the statistics of table, an
+	// identifier as written in the migration, quotes and schema included.
+	Analyze(table string) string
+}
+
+// PostgresStatistics refreshes statistics with ANALYZE.
+type PostgresStatistics struct{}
+
+func (PostgresStatistics) Analyze(table string) string {
+	return "ANALYZE " + table
+}
+
+// MySQLStatistics refreshes statistics with ANALYZE TABLE.
+type MySQLStatistics struct{}
+
+func (MySQLStatistics) Analyze(table string) string {
+	return "ANALYZE TABLE " + table
+}
+
+// SQLiteStatistics refreshes statistics with ANALYZE, which SQLite's
+// planner only uses once sqlite_stat1 exists, i.e. after the first ANALYZE.
+type SQLiteStatistics struct{}
+
+func (SQLiteStatistics) Analyze(table string) string {
+	return "ANALYZE " + table
+}
+
+// touchedTables lists, in the order first seen, the tables changed by the
+// statements of a run, less those it dropped.
+type touchedTables struct {
+	tables []string
+}
+
+type touchedTablesKey struct{}
+
+// withTouchedTables returns ctx collecting the tables changed by the
+// statements run under it, for refreshStatistics.
+func withTouchedTables(ctx context.Context) context.Context {
+	return context.WithValue(ctx, touchedTablesKey{}, new(touchedTables))
+}
+
+// record notes the tables changed by query.
+func (t *touchedTables) record(query string) {
+	c, ok := parseTableChange(query)
+	switch {
+	case !ok || c.table == "":
+	case c.renamed != "":
+		t.remove(c.table)
+		t.add(c.renamed)
+	case c.dropped && c.index == "":
+		t.remove(c.table)
+	default:
+		t.add(c.table)
+	}
+}
+
+func (t *touchedTables) add(table string) {
+	if !slices.ContainsFunc(t.tables, func(s string) bool { return sameTable(s, table) }) {
+		t.tables = append(t.tables, table)
+	}
+}
+
+func (t *touchedTables) remove(table string) {
+	t.tables = slices.DeleteFunc(t.tables, func(s string) bool { return sameTable(s, table) })
+}
+
+// refreshStatistics runs RefreshStatistics for the tables changed by the
+// run's statements. Statistics only guide the planner, so failures are
+// logged rather than failing a run whose migrations are already recorded.
+func (m *Migrator) refreshStatistics(ctx context.Context) {
+	touched, _ := ctx.Value(touchedTablesKey{}).(*touchedTables)
+	if m.RefreshStatistics == nil || touched == nil {
+		return
+	}
+	for _, table := range touched.tables {
+		m.logger().Verbosef("refreshing statistics: %s", table)
+		if _, err := m.store().DB().ExecContext(ctx, m.RefreshStatistics.Analyze(table)); err != nil {
+			m.logger().Infof("failed to refresh statistics of %s: %v", table, err)
+		}
+	}
+}
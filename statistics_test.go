@@ -0,0 +1,79 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+// recordingStatistics records the tables it is asked to analyze.
+type recordingStatistics struct {
+	golumn.SQLiteStatistics
+	tables []string
+}
+
+func (r *recordingStatistics) Analyze(table string) string {
+	r.tables = append(r.tables, table)
+	return r.SQLiteStatistics.Analyze(table)
+}
+
+func TestMigrator_RefreshStatistics(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sources := []*golumn.Migration{
+		golumn.SQLMigration(1, "0001_items.sql", []string{
+			`CREATE TABLE IF NOT EXISTS "items" (id INTEGER PRIMARY KEY, name TEXT)`,
+			`CREATE UNIQUE INDEX items_name ON items (name)`,
+			`INSERT INTO items (name) VALUES ('a'), ('b')`,
+			"UPDATE `items` SET name = 'c' WHERE name = 'b'",
+		}, []string{"DROP TABLE items"}),
+		golumn.SQLMigration(2, "0002_scratch.sql", []string{
+			`CREATE TABLE scratch (x INTEGER)`,
+			`DROP TABLE scratch`,
+			`CREATE TABLE old_name (x INTEGER)`,
+			`ALTER TABLE old_name RENAME TO new_name`,
+			`INSERT INTO new_name (x) VALUES (1)`,
+			`SELECT 1`,
+		}, []string{"DROP TABLE new_name"}),
+	}
+	stats := &recordingStatistics{}
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: sources, RefreshStatistics: stats}
+
+	if _, err := migrator.Up(context.Background(), golumn.Latest, golumn.WithDryRun()); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if len(stats.tables) != 0 {
+		t.Errorf("expected a dry run to refresh nothing, got %q", stats.tables)
+	}
+
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if want := []string{`"items"`, "new_name"}; !slices.Equal(stats.tables, want) {
+		t.Errorf("expected %q analyzed, got %q", want, stats.tables)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'items'").Scan(&n); err != nil {
+		t.Fatalf("failed to read statistics: %v", err)
+	}
+	if n == 0 {
+		t.Error("expected statistics for items")
+	}
+
+	stats.tables = nil
+	if _, err := migrator.Down(context.Background(), 1); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if len(stats.tables) != 0 {
+		t.Errorf("expected nothing analyzed after dropping new_name, got %q", stats.tables)
+	}
+}
@@ -0,0 +1,61 @@
+package golumn
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+)
+
+// Annotation keys under which Migrator.RecordStats stores a migration's
+// StatementStats.
+const (
+	AnnotationStatements   = "statements"
+	AnnotationRowsAffected = "rows_affected"
+)
+
+// StatementStats counts the statements a migration ran and the rows they
+// affected, as the driver reports them; go-sqlite3, for one, reports stale
+// counts for DDL. Only statements run through the Lua db module and SQL
+// files are counted; a Go migration's own use of its *sql.DB is not seen.
+type StatementStats struct {
+	Statements   int64
+	RowsAffected int64
+}
+
+type statementStatsKey struct{}
+
+// withStatementStats returns ctx collecting the statements run under it
+// into the returned StatementStats.
+func withStatementStats(ctx context.Context) (context.Context, *StatementStats) {
+	stats := new(StatementStats)
+	return context.WithValue(ctx, statementStatsKey{}, stats), stats
+}
+
+// recordStatement counts query, run successfully under ctx, with the rows
+// affected according to res if it is an exec whose driver reports them. An
+// exec, with a non-nil res, also records the tables it changed for
+// Migrator.RefreshStatistics. Failed statements are not recorded.
+func recordStatement(ctx context.Context, query string, res sql.Result) {
+	if touched, _ := ctx.Value(touchedTablesKey{}).(*touchedTables); touched != nil && res != nil {
+		touched.record(query)
+	}
+	stats, _ := ctx.Value(statementStatsKey{}).(*StatementStats)
+	if stats == nil {
+		return
+	}
+	stats.Statements++
+	if res == nil {
+		return
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		stats.RowsAffected += n
+	}
+}
+
+// annotations returns s as annotations for Annotator.
+func (s StatementStats) annotations() map[string]string {
+	return map[string]string{
+		AnnotationStatements:   strconv.FormatInt(s.Statements, 10),
+		AnnotationRowsAffected: strconv.FormatInt(s.RowsAffected, 10),
+	}
+}
@@ -0,0 +1,91 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+func TestMigrator_RecordStats(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// go-sqlite3 reports stale counts for DDL, so the migration only
+	// changes rows.
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := mustParse(t, `local db = require "db"
+Version=1
+function Up()
+    db.exec("INSERT INTO items (name) VALUES ('a'), ('b')")
+    db.exec_expect(2, "UPDATE items SET name = upper(name)")
+    for row in db.query("SELECT id FROM items") do end
+    -- A failed statement is not counted.
+    db.exec("INSERT INTO missing (id) VALUES (1)")
+end
+function Down()
+    db.exec("DELETE FROM items")
+end
+`)
+	var events []golumn.AuditEvent
+	migrator := &golumn.Migrator{
+		Store:       sqlite3store.New(db),
+		Sources:     []*golumn.Migration{m},
+		RecordStats: true,
+		Audit: golumn.AuditFunc(func(_ context.Context, ev golumn.AuditEvent) error {
+			events = append(events, ev)
+			return nil
+		}),
+	}
+	ctx := context.Background()
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+
+	st, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	notes := st.Annotations[1]
+	if notes[golumn.AnnotationStatements] != "3" || notes[golumn.AnnotationRowsAffected] != "4" {
+		t.Errorf("expected 3 statements affecting 4 rows, got %v", notes)
+	}
+
+	if _, err := migrator.Down(ctx, golumn.Initial); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	want := []golumn.StatementStats{{Statements: 3, RowsAffected: 4}, {Statements: 1, RowsAffected: 2}}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d audit events, got %d", len(want), len(events))
+	}
+	for i, ev := range events {
+		if ev.Stats != want[i] {
+			t.Errorf("event %d: expected stats %+v, got %+v", i, want[i], ev.Stats)
+		}
+	}
+}
+
+func TestMigrator_RecordStatsDisabled(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrator := &golumn.Migrator{Store: sqlite3store.New(db), Sources: createMigrations(1)}
+	ctx := context.Background()
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if st, err := migrator.Status(ctx); err != nil || st.Annotations != nil {
+		t.Errorf("expected no annotations, got %v (err %v)", st.Annotations, err)
+	}
+}
@@ -0,0 +1,165 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status describes the state of the version store relative to Sources.
+type Status struct {
+	// Version is the store version, or Initial if no migrations are
+	// recorded.
+	Version int64
+	// Applied lists every applied version if the store implements
+	// VersionLister, and is nil otherwise.
+	Applied []int64
+	// Pending lists the source versions not yet applied.
+	Pending []int64
+	// Annotations holds the notes attached to applied versions with
+	// Migrator.Annotate if the store implements Annotator, keyed by version
+	// and then note key.
+	Annotations map[int64]map[string]string
+	// Impact holds what the Up of each pending version touches, for the
+	// SQL migrations among them whose statements are known without
+	// loading them; see Migration.Impact.
+	Impact map[int64]*Impact
+	// Locked reports that the store lock is held, by a run in progress or,
+	// if it persists, by a run that failed with HoldLockOnFailure. It is
+	// always false if the store does not implement LockInspector.
+	Locked bool
+}
+
+func (st *Status) String() string {
+	return st.Format(DefaultCatalog)
+}
+
+// Format renders the status with messages from c.
+func (st *Status) Format(c Catalog) string {
+	return c.Sprintf(MsgStatus, st.Version, len(st.Pending))
+}
+
+// Status reads the store state without initializing or locking the store,
+// so it can be polled while another migrator holds the lock, unless
+// SharedReads is set. It reads from StatusStore if set.
+func (m *Migrator) Status(ctx context.Context) (st *Status, err error) {
+	if err := m.load(ctx); err != nil {
+		return nil, err
+	}
+	if m.SharedReads {
+		if err := m.store().Init(ctx); err != nil {
+			return nil, fmt.Errorf("failed to init version store: %w", err)
+		}
+	}
+	release, err := m.lockShared(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if rlErr := release(); rlErr != nil {
+			st, err = nil, errors.Join(err, rlErr)
+		}
+	}()
+	store := m.statusStore()
+
+	st = &Status{Version: Initial}
+	version, err := store.Version(ctx)
+	if err != nil {
+		if !errors.Is(err, ErrInitialVersion) {
+			return nil, fmt.Errorf("failed to get version store state: %w", err)
+		}
+	} else {
+		st.Version = version
+	}
+
+	lister, listed := store.(VersionLister)
+	applied := map[int64]bool{}
+	if listed {
+		versions, err := lister.Versions(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list applied versions: %w", err)
+		}
+		st.Applied = append([]int64{}, versions...)
+		for _, v := range versions {
+			applied[v] = true
+		}
+	}
+
+	if inspector, ok := store.(LockInspector); ok {
+		locked, err := inspector.Locked(ctx)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return nil, fmt.Errorf("failed to check version store lock: %w", err)
+		}
+		st.Locked = locked
+	}
+
+	if annotator, ok := store.(Annotator); ok {
+		notes, err := annotator.Annotations(ctx)
+		if err != nil && !errors.Is(err, errors.ErrUnsupported) {
+			return nil, fmt.Errorf("failed to list annotations: %w", err)
+		}
+		if len(notes) > 0 {
+			st.Annotations = notes
+		}
+	}
+
+	for _, migration := range m.migrations() {
+		if listed && !applied[migration.Version] || !listed && migration.Version > st.Version {
+			st.Pending = append(st.Pending, migration.Version)
+			if impact := migration.knownImpact(DirectionUp); impact != nil {
+				if st.Impact == nil {
+					st.Impact = map[int64]*Impact{}
+				}
+				st.Impact[migration.Version] = impact
+			}
+		}
+	}
+	return st, nil
+}
+
+func (m *Migrator) statusStore() Store {
+	if m.StatusStore != nil {
+		return m.wrappedStatusStore.get(m.StatusStore, m.Middleware)
+	}
+	return m.store()
+}
+
+type versionCache struct {
+	mu      sync.Mutex
+	version int64
+	expires time.Time
+}
+
+// CachedVersion returns the store version, or Initial if no migrations are
+// recorded, reusing a value read within the last ttl. It is meant for hot
+// paths such as health checks. Like Status it never locks the store and reads
+// from StatusStore if set. Runs by this Migrator invalidate the cache; runs by
+// other processes are seen once ttl expires.
+func (m *Migrator) CachedVersion(ctx context.Context, ttl time.Duration) (int64, error) {
+	m.versionCache.mu.Lock()
+	defer m.versionCache.mu.Unlock()
+
+	if time.Now().Before(m.versionCache.expires) {
+		return m.versionCache.version, nil
+	}
+
+	version, err := m.statusStore().Version(ctx)
+	if errors.Is(err, ErrInitialVersion) {
+		version, err = Initial, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get version store state: %w", err)
+	}
+
+	m.versionCache.version = version
+	m.versionCache.expires = time.Now().Add(ttl)
+	return version, nil
+}
+
+func (m *Migrator) invalidateVersionCache() {
+	m.versionCache.mu.Lock()
+	m.versionCache.expires = time.Time{}
+	m.versionCache.mu.Unlock()
+}
@@ -0,0 +1,147 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigrator_Status(t *testing.T) {
+	tests := []struct {
+		name        string
+		store       golumn.Store
+		wantVersion int64
+		wantApplied []int64
+		wantPending []int64
+	}{
+		{
+			name:        "initial",
+			store:       &fakeStore{},
+			wantVersion: -1,
+			wantPending: []int64{1, 2, 3},
+		},
+		{
+			name:        "partial",
+			store:       &fakeStore{versions: []int64{1, 2}},
+			wantVersion: 2,
+			wantPending: []int64{3},
+		},
+		{
+			name:        "lister_with_gap",
+			store:       newListingStore(1, 3),
+			wantVersion: 3,
+			wantApplied: []int64{1, 3},
+			wantPending: []int64{2},
+		},
+		{
+			name:        "lister_initial",
+			store:       newListingStore(),
+			wantVersion: -1,
+			wantApplied: []int64{},
+			wantPending: []int64{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrator := &golumn.Migrator{Store: tt.store, Sources: createMigrations(1, 2, 3)}
+			st, err := migrator.Status(context.Background())
+			if err != nil {
+				t.Fatalf("status failed: %v", err)
+			}
+			if st.Version != tt.wantVersion {
+				t.Errorf("version: want %d, got %d", tt.wantVersion, st.Version)
+			}
+			if !slices.Equal(st.Applied, tt.wantApplied) || (st.Applied == nil) != (tt.wantApplied == nil) {
+				t.Errorf("applied: want %#v, got %#v", tt.wantApplied, st.Applied)
+			}
+			if !slices.Equal(st.Pending, tt.wantPending) {
+				t.Errorf("pending: want %v, got %v", tt.wantPending, st.Pending)
+			}
+		})
+	}
+}
+
+func TestMigrator_StatusDoesNotLock(t *testing.T) {
+	store := &fakeStore{versions: []int64{1}, locked: true}
+	store.initFunc = func(context.Context, *fakeStore) error {
+		return errors.New("status must not init")
+	}
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2)}
+
+	st, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status failed while store locked: %v", err)
+	}
+	if st.Version != 1 {
+		t.Errorf("expected version 1, got %d", st.Version)
+	}
+	if store.lockCalls != 0 || store.initCalls != 0 || store.releaseCalls != 0 {
+		t.Errorf("expected no init, lock or release calls, got %d, %d, %d", store.initCalls, store.lockCalls, store.releaseCalls)
+	}
+}
+
+func TestMigrator_StatusStore(t *testing.T) {
+	primary := &fakeStore{versions: []int64{1}}
+	reader := &fakeStore{versions: []int64{1, 2}}
+	migrator := &golumn.Migrator{Store: primary, StatusStore: reader, Sources: createMigrations(1, 2)}
+
+	st, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if st.Version != 2 {
+		t.Errorf("expected version from status store, got %d", st.Version)
+	}
+	if primary.versionCalls != 0 {
+		t.Errorf("expected primary store to be unused, got %d version calls", primary.versionCalls)
+	}
+}
+
+func TestMigrator_CachedVersion(t *testing.T) {
+	store := &fakeStore{versions: []int64{1}}
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2)}
+
+	for range 3 {
+		v, err := migrator.CachedVersion(context.Background(), time.Hour)
+		if err != nil {
+			t.Fatalf("cached version failed: %v", err)
+		}
+		if v != 1 {
+			t.Errorf("expected version 1, got %d", v)
+		}
+	}
+	if store.versionCalls != 1 {
+		t.Errorf("expected one store read, got %d", store.versionCalls)
+	}
+
+	var seen int64
+	migrator.AfterSuccess = func(ctx context.Context, _ *golumn.Result) error {
+		var err error
+		seen, err = migrator.CachedVersion(ctx, time.Hour)
+		return err
+	}
+	if _, err := migrator.Up(context.Background(), 2); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if seen != 2 {
+		t.Errorf("expected run to invalidate the cache before AfterSuccess, got version %d", seen)
+	}
+}
+
+func TestMigrator_CachedVersionExpires(t *testing.T) {
+	store := &fakeStore{}
+	migrator := &golumn.Migrator{Store: store}
+
+	if v, err := migrator.CachedVersion(context.Background(), time.Millisecond); err != nil || v != -1 {
+		t.Fatalf("expected initial version -1, got %d (err %v)", v, err)
+	}
+	store.versions = []int64{7}
+	time.Sleep(5 * time.Millisecond)
+	if v, err := migrator.CachedVersion(context.Background(), time.Millisecond); err != nil || v != 7 {
+		t.Fatalf("expected refreshed version 7, got %d (err %v)", v, err)
+	}
+}
@@ -4,13 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"io"
+	"time"
 )
 
 var (
 	ErrLocked         = errors.New("version store is locked for writing")
+	ErrNotLocked      = errors.New("version store lock is not held")
 	ErrInitialVersion = errors.New("initial version is current")
+	ErrNotApplied     = errors.New("version is not applied")
 )
 
+// Store records applied migration versions and guards runs with a lock.
+// Versions are non-negative and 0 is an ordinary version; Insert must reject
+// negative versions, which would collide with Initial. Implementations can
+// verify their semantics with storetest.TestStore.
 type Store interface {
 	DB() *sql.DB
 	Init(context.Context) error
@@ -20,3 +28,127 @@ type Store interface {
 	Insert(context.Context, int64) error
 	Remove(context.Context, int64) error
 }
+
+// VersionLister is implemented by stores that can list every applied
+// version, not just the latest. It lets Migrator.UpOnly apply versions out of
+// order and later Up runs fill the gaps left behind.
+type VersionLister interface {
+	Versions(context.Context) ([]int64, error)
+}
+
+// ForceUnlocker is implemented by stores that can clear a lock held by
+// another process, e.g. one left behind by a crashed migrator.
+type ForceUnlocker interface {
+	ForceUnlock(context.Context) error
+}
+
+// ConnReserver is implemented by stores whose lock is held by a database
+// session, e.g. an advisory lock, on a connection kept out of DB's pool for
+// as long as the lock is held. ReserveConn takes that connection ahead of
+// Lock, which then uses it, and keeps it until the returned func is called,
+// so that Migrator.Conn can leave it outside its MaxOpenConns limit and
+// configure the connection the run's statements use instead.
+type ConnReserver interface {
+	ReserveConn(context.Context) (unreserve func() error, err error)
+}
+
+// Targeter is implemented by stores for databases that migrations do not
+// reach through database/sql, e.g. a document database, whose DB may return
+// nil. Their migrations set UpTarget and DownTarget in place of UpFunc and
+// DownFunc, which are passed Target. Target returns nil if there is none,
+// e.g. from a wrapper around a store that is not a Targeter.
+type Targeter interface {
+	Target() any
+}
+
+// LockInspector is implemented by stores that can report whether their lock
+// is held by anyone, without taking it.
+type LockInspector interface {
+	Locked(context.Context) (bool, error)
+}
+
+// SharedLocker is implemented by stores whose lock also has a shared mode,
+// held by any number of readers at once, e.g. migrators reading their
+// status or exporting their history, while Lock takes it exclusively.
+// LockShared fails with ErrLocked while the lock is held exclusively and
+// Lock fails with ErrLocked while it is held shared. A store instance may
+// take the shared lock more than once, releasing it with as many calls to
+// ReleaseShared, which follows ReleasePolicy like Release. Locked, if the
+// store is a LockInspector, reports only the exclusive mode. ForceUnlock, if
+// the store is a ForceUnlocker, clears the shared mode too, since a crashed
+// reader would otherwise keep writers out.
+type SharedLocker interface {
+	LockShared(context.Context) error
+	ReleaseShared(context.Context) error
+}
+
+// LockHolder describes who holds a store lock.
+type LockHolder struct {
+	// Owner identifies the holding process, as recorded by the store.
+	Owner string
+	// Since is when the lock was taken, or zero if the store does not
+	// record it.
+	Since time.Time
+}
+
+// LockHolderInspector is implemented by stores that record who holds their
+// lock. LockHolder returns nil if the lock is free.
+type LockHolderInspector interface {
+	LockHolder(context.Context) (*LockHolder, error)
+}
+
+// Explainer is implemented by stores that can show how the database would
+// execute a statement without running it, e.g. with EXPLAIN. Explain returns
+// errors.ErrUnsupported for statements it does not consider safe to explain.
+type Explainer interface {
+	Explain(ctx context.Context, stmt string) (string, error)
+}
+
+// Annotator is implemented by stores that can attach notes to applied
+// versions, e.g. "superseded by 100". Annotate sets key to value, or deletes
+// it if value is empty, and returns ErrNotApplied for versions the store does
+// not record. Notes are dropped when their version is removed.
+type Annotator interface {
+	Annotate(ctx context.Context, version int64, key, value string) error
+	Annotations(context.Context) (map[int64]map[string]string, error)
+}
+
+// CompatRegistry is implemented by stores that record the schema versions
+// running applications are compatible with, so that migrators in other
+// processes can refuse runs that would break them. See
+// Migrator.RegisterCompat.
+type CompatRegistry interface {
+	RegisterCompat(context.Context, Compat) error
+	UnregisterCompat(ctx context.Context, app string) error
+	Compats(context.Context) ([]Compat, error)
+}
+
+// Exporter is implemented by stores that can serialize their bookkeeping,
+// the applied versions with their annotations and compat registrations, so
+// that another store, possibly of a different engine, can take it over.
+// Export writes it with WriteHistory. Import reads it with ReadHistory and
+// replaces the store's bookkeeping with it atomically; it does not touch
+// the lock. See Migrator.Export and Migrator.Import.
+type Exporter interface {
+	Export(ctx context.Context, w io.Writer) error
+	Import(ctx context.Context, r io.Reader) error
+}
+
+// TableLister is implemented by stores that can name the tables they keep
+// their bookkeeping in, for diagnostics such as Migrator.Debug.
+type TableLister interface {
+	Tables() []string
+}
+
+// ReleasePolicy controls what a store does when Release is called without
+// the lock being held by that store instance, either because Lock was never
+// called or because the lock was since cleared by ForceUnlock.
+type ReleasePolicy int
+
+const (
+	// ReleaseIgnoreUnheld makes Release a no-op when the lock is not held.
+	ReleaseIgnoreUnheld ReleasePolicy = iota
+	// ReleaseErrorUnheld makes Release return ErrNotLocked when the lock is
+	// not held.
+	ReleaseErrorUnheld
+)
@@ -0,0 +1,265 @@
+// Package clickhousestore provides a golumn.Store for ClickHouse 23.3 or
+// later. It issues plain SQL through database/sql with ?-style parameters
+// and does not import a driver; github.com/ClickHouse/clickhouse-go/v2
+// works.
+//
+// ClickHouse has no transactions or unique keys, so the store only ever
+// appends rows. schema_migrations is a ReplacingMergeTree keyed by version
+// in which Insert and Remove each add a row and the latest row for a
+// version says whether it is applied. The store lock is a table of claims:
+// Lock adds one and holds the lock if its claim is the earliest, and
+// withdraws it otherwise. ClickHouse Keeper is not reachable through SQL,
+// so a Keeper lock is out of reach of a driver-agnostic store.
+//
+// On a replicated cluster, reads must see the writes of other migrators
+// for the lock to hold; set insert_quorum and
+// select_sequential_consistency in the DSN.
+package clickhousestore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type ClickHouseStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Lineage suffixes the store's tables, except the database-wide
+	// schema_identity, see golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store               = (*ClickHouseStore)(nil)
+	_ golumn.ForceUnlocker       = (*ClickHouseStore)(nil)
+	_ golumn.LockInspector       = (*ClickHouseStore)(nil)
+	_ golumn.VersionLister       = (*ClickHouseStore)(nil)
+	_ golumn.TableLister         = (*ClickHouseStore)(nil)
+	_ golumn.IdentityStore       = (*ClickHouseStore)(nil)
+	_ golumn.LockHolderInspector = (*ClickHouseStore)(nil)
+)
+
+func New(db *sql.DB) *ClickHouseStore {
+	return &ClickHouseStore{instance: db, owner: newOwnerID()}
+}
+
+func newOwnerID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *ClickHouseStore) DB() *sql.DB {
+	return s.instance
+}
+
+// table names one of the store's tables for its lineage.
+func (s *ClickHouseStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables, which schema_identity, shared by all
+// lineages, completes.
+func (s *ClickHouseStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_migrations"), "schema_identity"}
+}
+
+func (s *ClickHouseStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_lock") + " (owner String, claimed_at DateTime64(9) DEFAULT now64(9)) ENGINE = MergeTree ORDER BY claimed_at",
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (version_id Int64, is_applied UInt8, updated_at DateTime64(9) DEFAULT now64(9)) ENGINE = ReplacingMergeTree(updated_at) ORDER BY version_id",
+		"CREATE TABLE IF NOT EXISTS schema_identity (identity String, created_at DateTime64(9) DEFAULT now64(9)) ENGINE = MergeTree ORDER BY created_at",
+	} {
+		if _, err := s.instance.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Identity returns the database's identity, generating it on first use.
+// Without unique keys, concurrent first calls may each add one; like lock
+// claims, the earliest, ties broken by value, is the one every call
+// returns. Stores of every lineage share it.
+func (s *ClickHouseStore) Identity(ctx context.Context) (string, error) {
+	const query = "SELECT identity FROM schema_identity ORDER BY created_at, identity LIMIT 1"
+	var id string
+	err := s.instance.QueryRowContext(ctx, query).Scan(&id)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return id, err
+	}
+	if _, err := s.instance.ExecContext(ctx, "INSERT INTO schema_identity (identity) VALUES (?)", golumn.NewIdentity()); err != nil {
+		return "", err
+	}
+	err = s.instance.QueryRowContext(ctx, query).Scan(&id)
+	return id, err
+}
+
+// Lock claims the lock and holds it if no earlier claim exists. Ties in
+// claimed_at are broken by owner so that every migrator agrees on the
+// winner.
+func (s *ClickHouseStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	if _, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_lock")+" (owner) VALUES (?)", s.owner); err != nil {
+		return err
+	}
+	var winner string
+	if err := s.instance.QueryRowContext(ctx, "SELECT owner FROM "+s.table("schema_lock")+" ORDER BY claimed_at, owner LIMIT 1").Scan(&winner); err != nil {
+		return errors.Join(err, s.withdraw(ctx))
+	}
+	if winner != s.owner {
+		return errors.Join(golumn.ErrLocked, s.withdraw(ctx))
+	}
+	s.held = true
+	s.Log.Debugf("clickhousestore: acquired lock as %s", s.owner)
+	return nil
+}
+
+// withdraw deletes the store's claim. It is not bound to the caller's
+// context, which may be what failed the claim.
+func (s *ClickHouseStore) withdraw(ctx context.Context) error {
+	_, err := s.instance.ExecContext(context.WithoutCancel(ctx), "DELETE FROM "+s.table("schema_lock")+" WHERE owner = ?", s.owner)
+	return err
+}
+
+func (s *ClickHouseStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		// Lightweight deletes report no affected rows, so check whether the
+		// claim survived a ForceUnlock first.
+		var n int64
+		if err := s.instance.QueryRowContext(ctx, "SELECT count() FROM "+s.table("schema_lock")+" WHERE owner = ?", s.owner).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			if _, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE owner = ?", s.owner); err != nil {
+				return err
+			}
+		}
+		s.held = false
+
+		if n > 0 {
+			s.Log.Debugf("clickhousestore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("clickhousestore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *ClickHouseStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.instance.ExecContext(ctx, "TRUNCATE TABLE "+s.table("schema_lock")); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("clickhousestore: forcibly released lock")
+	return nil
+}
+
+func (s *ClickHouseStore) Locked(ctx context.Context) (bool, error) {
+	var n int64
+	err := s.instance.QueryRowContext(ctx, "SELECT count() FROM "+s.table("schema_lock")).Scan(&n)
+	return n > 0, err
+}
+
+// LockHolder returns the owner of the earliest claim, which holds the lock
+// or is about to, and when it was made.
+func (s *ClickHouseStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	var holder golumn.LockHolder
+	err := s.instance.QueryRowContext(ctx, "SELECT owner, claimed_at FROM "+s.table("schema_lock")+" ORDER BY claimed_at, owner LIMIT 1").Scan(&holder.Owner, &holder.Since)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &holder, nil
+}
+
+func (s *ClickHouseStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" FINAL WHERE is_applied = 1 ORDER BY version_id DESC LIMIT 1")
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *ClickHouseStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" FINAL WHERE is_applied = 1 ORDER BY version_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// Insert records v as applied. Without unique keys, the check for an
+// already applied version is only safe under the store lock.
+func (s *ClickHouseStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	var n int64
+	if err := s.instance.QueryRowContext(ctx, "SELECT count() FROM "+s.table("schema_migrations")+" FINAL WHERE version_id = ? AND is_applied = 1", v).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("version %d already applied", v)
+	}
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id, is_applied) VALUES (?, 1)", v)
+	return err
+}
+
+// Remove records v as not applied, superseding its row once ClickHouse
+// merges the table; FINAL applies the replacement at read time until then.
+func (s *ClickHouseStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id, is_applied) VALUES (?, 0)", v)
+	return err
+}
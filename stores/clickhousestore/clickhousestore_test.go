@@ -0,0 +1,179 @@
+package clickhousestore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/clickhousestore"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestClickHouseStore_Conformance runs against the database at
+// $GOLUMN_CLICKHOUSE_DSN with the driver named by
+// $GOLUMN_CLICKHOUSE_DRIVER, which the test binary must have registered,
+// e.g. through a blank import of github.com/ClickHouse/clickhouse-go/v2
+// added locally.
+func TestClickHouseStore_Conformance(t *testing.T) {
+	name, dsn := sqltest.Env(t, "CLICKHOUSE", "")
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, table := range []string{"schema_lock", "schema_migrations"} {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return clickhousestore.New(db)
+	})
+}
+
+// fakeServer interprets the store's statements against in-memory tables:
+// lock claims in insertion order and the latest is_applied of each version.
+type fakeServer struct {
+	sqltest.Server
+	claims     []string
+	applied    map[int64]bool
+	identities []string
+}
+
+// claimedAt is the claimed_at the fake reports for every claim.
+var claimedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{applied: make(map[int64]bool)}
+}
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query = srv.exec, srv.query
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (srv *fakeServer) exec(_ *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+	case strings.HasPrefix(query, "INSERT INTO schema_lock"):
+		srv.claims = append(srv.claims, args[0].(string))
+	case strings.HasPrefix(query, "DELETE FROM schema_lock"):
+		srv.claims = slices.DeleteFunc(srv.claims, func(owner string) bool { return owner == args[0] })
+	case strings.HasPrefix(query, "TRUNCATE TABLE schema_lock"):
+		srv.claims = nil
+	case strings.HasPrefix(query, "INSERT INTO schema_identity"):
+		srv.identities = append(srv.identities, args[0].(string))
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		srv.applied[args[0].(int64)] = strings.HasSuffix(query, "(?, 1)")
+	default:
+		return nil, errors.New("unexpected statement: " + query)
+	}
+	return nil, nil
+}
+
+func (srv *fakeServer) query(_ *sqltest.Conn, query string, args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT owner, claimed_at FROM schema_lock"):
+		if len(srv.claims) == 0 {
+			return sqltest.Rows([]string{"owner", "claimed_at"}), nil
+		}
+		return sqltest.Rows([]string{"owner", "claimed_at"}, []driver.Value{srv.claims[0], claimedAt}), nil
+	case strings.HasPrefix(query, "SELECT owner FROM schema_lock"):
+		return sqltest.Values(srv.claims[:min(len(srv.claims), 1)]...), nil
+	case strings.HasPrefix(query, "SELECT count() FROM schema_lock WHERE owner"):
+		n := len(slices.DeleteFunc(slices.Clone(srv.claims), func(owner string) bool { return owner != args[0] }))
+		return sqltest.Value(int64(n)), nil
+	case strings.HasPrefix(query, "SELECT identity FROM schema_identity"):
+		return sqltest.Values(srv.identities[:min(len(srv.identities), 1)]...), nil
+	case strings.HasPrefix(query, "SELECT count() FROM schema_lock"):
+		return sqltest.Value(int64(len(srv.claims))), nil
+	case strings.HasPrefix(query, "SELECT count() FROM schema_migrations FINAL"):
+		n := 0
+		if srv.applied[args[0].(int64)] {
+			n = 1
+		}
+		return sqltest.Value(int64(n)), nil
+	case strings.HasPrefix(query, "SELECT version_id FROM schema_migrations FINAL"):
+		var versions []int64
+		for v, applied := range srv.applied {
+			if applied {
+				versions = append(versions, v)
+			}
+		}
+		slices.Sort(versions)
+		if strings.Contains(query, "DESC LIMIT 1") {
+			slices.Reverse(versions)
+			versions = versions[:min(len(versions), 1)]
+		}
+		return sqltest.Values(versions...), nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func TestClickHouseStore_Fake(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		return clickhousestore.New(openFake(t, newFakeServer()))
+	})
+}
+
+func TestClickHouseStore_LockClaims(t *testing.T) {
+	srv := newFakeServer()
+	store := clickhousestore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	// A claim made concurrently, but earlier, wins.
+	srv.claims = []string{"other"}
+	if err := store.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Fatalf("expected ErrLocked behind an earlier claim, got %v", err)
+	}
+	if !slices.Equal(srv.claims, []string{"other"}) {
+		t.Errorf("expected the losing claim to be withdrawn, got %v", srv.claims)
+	}
+
+	srv.claims = nil
+	if err := store.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if len(srv.claims) != 1 {
+		t.Errorf("expected one claim, got %v", srv.claims)
+	}
+	if err := store.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if len(srv.claims) != 0 {
+		t.Errorf("expected the claim to be deleted, got %v", srv.claims)
+	}
+}
+
+func TestClickHouseStore_Lineage(t *testing.T) {
+	store := clickhousestore.New(openFake(t, newFakeServer()))
+	store.Lineage = "bad-lineage"
+	if err := store.Init(context.Background()); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+}
+
+func TestClickHouseStore_LockHolder(t *testing.T) {
+	srv := newFakeServer()
+	store := clickhousestore.New(openFake(t, srv))
+	ctx := context.Background()
+	if holder, err := store.LockHolder(ctx); err != nil || holder != nil {
+		t.Fatalf("expected no holder of a free lock, got %+v (err %v)", holder, err)
+	}
+
+	srv.claims = []string{"other", "late"}
+	holder, err := store.LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if holder == nil || holder.Owner != "other" || !holder.Since.Equal(claimedAt) {
+		t.Errorf("expected the earliest claim to hold the lock, got %+v", holder)
+	}
+}
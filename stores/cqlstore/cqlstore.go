@@ -0,0 +1,250 @@
+// Package cqlstore provides a golumn.Store for Cassandra and ScyllaDB. It
+// issues CQL through database/sql with ?-style parameters and does not
+// import a driver; a database/sql wrapper of gocql, such as
+// github.com/MichaelS11/go-cql-driver, works. The keyspace is chosen by the
+// driver's DSN.
+//
+// Rows are written with lightweight transactions: the lock row is claimed
+// with INSERT ... IF NOT EXISTS and released with DELETE ... IF owner = ?,
+// and Insert uses IF NOT EXISTS to refuse a version already applied. All
+// writes to the store's tables are conditional, as mixing them with plain
+// writes defeats their serialization. CQL cannot order a table by its
+// partition key, so versions are sorted by the store.
+package cqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type CQLStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Lineage suffixes the store's tables within the keyspace, see
+	// golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store               = (*CQLStore)(nil)
+	_ golumn.ForceUnlocker       = (*CQLStore)(nil)
+	_ golumn.LockInspector       = (*CQLStore)(nil)
+	_ golumn.VersionLister       = (*CQLStore)(nil)
+	_ golumn.TableLister         = (*CQLStore)(nil)
+	_ golumn.LockHolderInspector = (*CQLStore)(nil)
+)
+
+func New(db *sql.DB) *CQLStore {
+	return &CQLStore{instance: db, owner: golumn.NewIdentity()}
+}
+
+func (s *CQLStore) DB() *sql.DB {
+	return s.instance
+}
+
+// table names one of the store's tables for its lineage.
+func (s *CQLStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables.
+func (s *CQLStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_migrations")}
+}
+
+func (s *CQLStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_lock") + " (id int PRIMARY KEY, owner text)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (version_id bigint PRIMARY KEY, applied_at timestamp)",
+	} {
+		if _, err := s.instance.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lwt runs the lightweight transaction query and reports whether it was
+// applied. The result row holds [applied] and, if it was not, the values
+// that prevented it, so its width varies.
+func (s *CQLStore) lwt(ctx context.Context, query string, args ...any) (bool, error) {
+	rows, err := s.instance.QueryContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return false, err
+	}
+	i := slices.Index(cols, "[applied]")
+	if i < 0 {
+		return false, fmt.Errorf("no [applied] column in result of %q", query)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("no result row for %q", query)
+	}
+	vals := make([]any, len(cols))
+	dest := make([]any, len(cols))
+	for j := range vals {
+		dest[j] = &vals[j]
+	}
+	var applied bool
+	dest[i] = &applied
+	if err := rows.Scan(dest...); err != nil {
+		return false, err
+	}
+	return applied, rows.Close()
+}
+
+func (s *CQLStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	applied, err := s.lwt(ctx, "INSERT INTO "+s.table("schema_lock")+" (id, owner) VALUES (1, ?) IF NOT EXISTS", s.owner)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return golumn.ErrLocked
+	}
+	s.held = true
+	s.Log.Debugf("cqlstore: acquired lock as %s", s.owner)
+	return nil
+}
+
+func (s *CQLStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		applied, err := s.lwt(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1 IF owner = ?", s.owner)
+		if err != nil {
+			return err
+		}
+		s.held = false
+
+		if applied {
+			s.Log.Debugf("cqlstore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("cqlstore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *CQLStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.lwt(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1 IF EXISTS"); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("cqlstore: forcibly released lock")
+	return nil
+}
+
+func (s *CQLStore) Locked(ctx context.Context) (bool, error) {
+	var n int64
+	err := s.instance.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.table("schema_lock")+" WHERE id = 1").Scan(&n)
+	return n > 0, err
+}
+
+// LockHolder returns the owner recorded in the lock row and, from the
+// WRITETIME of the owner, when it took the lock.
+func (s *CQLStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	var owner string
+	var written int64
+	err := s.instance.QueryRowContext(ctx, "SELECT owner, WRITETIME(owner) FROM "+s.table("schema_lock")+" WHERE id = 1").Scan(&owner, &written)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &golumn.LockHolder{Owner: owner, Since: time.UnixMicro(written)}, nil
+}
+
+func (s *CQLStore) Version(ctx context.Context) (int64, error) {
+	versions, err := s.Versions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, golumn.ErrInitialVersion
+	}
+	return versions[len(versions)-1], nil
+}
+
+func (s *CQLStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+func (s *CQLStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	applied, err := s.lwt(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id, applied_at) VALUES (?, toTimestamp(now())) IF NOT EXISTS", v)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return fmt.Errorf("version %d already applied", v)
+	}
+	return nil
+}
+
+func (s *CQLStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.lwt(ctx, "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = ? IF EXISTS", v)
+	return err
+}
@@ -0,0 +1,166 @@
+package cqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/cqlstore"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestCQLStore_Conformance runs against the keyspace at $GOLUMN_CQL_DSN
+// with the driver named by $GOLUMN_CQL_DRIVER, which the test binary must
+// have registered, e.g. "cql" through a blank import of
+// github.com/MichaelS11/go-cql-driver added locally.
+func TestCQLStore_Conformance(t *testing.T) {
+	name, dsn := sqltest.Env(t, "CQL", "")
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, table := range []string{"schema_lock", "schema_migrations"} {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return cqlstore.New(db)
+	})
+}
+
+// fakeServer interprets the store's statements against in-memory tables,
+// answering lightweight transactions like Cassandra: with [applied] and,
+// when not applied, the existing row.
+type fakeServer struct {
+	sqltest.Server
+	owner   string
+	applied map[int64]bool
+}
+
+// lockWritten is the WRITETIME the fake reports for the lock row's owner.
+var lockWritten = time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{applied: make(map[int64]bool)}
+}
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query = srv.exec, srv.query
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (*fakeServer) exec(_ *sqltest.Conn, query string, _ []driver.Value) (driver.Result, error) {
+	if !strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS") {
+		return nil, errors.New("unexpected statement: " + query)
+	}
+	return nil, nil
+}
+
+// applied answers a lightweight transaction.
+func applied(ok bool, existing ...driver.Value) driver.Rows {
+	if ok {
+		return sqltest.Rows([]string{"[applied]"}, []driver.Value{true})
+	}
+	cols := []string{"[applied]"}
+	for range existing {
+		cols = append(cols, "existing")
+	}
+	return sqltest.Rows(cols, append([]driver.Value{false}, existing...))
+}
+
+func (srv *fakeServer) query(_ *sqltest.Conn, query string, args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO schema_lock"):
+		if srv.owner != "" {
+			return applied(false, int64(1), srv.owner), nil
+		}
+		srv.owner = args[0].(string)
+		return applied(true), nil
+	case strings.HasPrefix(query, "DELETE FROM schema_lock WHERE id = 1 IF owner = ?"):
+		if srv.owner != args[0] {
+			return applied(false, srv.owner), nil
+		}
+		srv.owner = ""
+		return applied(true), nil
+	case strings.HasPrefix(query, "DELETE FROM schema_lock WHERE id = 1 IF EXISTS"):
+		ok := srv.owner != ""
+		srv.owner = ""
+		return applied(ok), nil
+	case strings.HasPrefix(query, "SELECT owner, WRITETIME(owner) FROM schema_lock"):
+		if srv.owner == "" {
+			return sqltest.Rows([]string{"owner", "writetime(owner)"}), nil
+		}
+		return sqltest.Rows([]string{"owner", "writetime(owner)"}, []driver.Value{srv.owner, lockWritten.UnixMicro()}), nil
+	case strings.HasPrefix(query, "SELECT COUNT(*) FROM schema_lock"):
+		n := int64(0)
+		if srv.owner != "" {
+			n = 1
+		}
+		return sqltest.Rows([]string{"count"}, []driver.Value{n}), nil
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		v := args[0].(int64)
+		if srv.applied[v] {
+			return applied(false, v, "2024-01-01T00:00:00Z"), nil
+		}
+		srv.applied[v] = true
+		return applied(true), nil
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		v := args[0].(int64)
+		ok := srv.applied[v]
+		delete(srv.applied, v)
+		return applied(ok), nil
+	case strings.HasPrefix(query, "SELECT version_id FROM schema_migrations"):
+		// Partition order is arbitrary; the store must sort.
+		var rows [][]driver.Value
+		for v := range srv.applied {
+			rows = append(rows, []driver.Value{v})
+		}
+		return sqltest.Rows([]string{"version_id"}, rows...), nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func TestCQLStore_Fake(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		return cqlstore.New(openFake(t, newFakeServer()))
+	})
+}
+
+func TestCQLStore_LockHeldElsewhere(t *testing.T) {
+	srv := newFakeServer()
+	srv.owner = "other"
+	store := cqlstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if err := store.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if holder, err := store.LockHolder(ctx); err != nil || holder == nil || holder.Owner != "other" || !holder.Since.Equal(lockWritten) {
+		t.Errorf("expected the lock held by other since %s, got %+v (err %v)", lockWritten, holder, err)
+	}
+	if err := store.ForceUnlock(ctx); err != nil {
+		t.Fatalf("force unlock failed: %v", err)
+	}
+	if holder, err := store.LockHolder(ctx); err != nil || holder != nil {
+		t.Errorf("expected no holder after force unlock, got %+v (err %v)", holder, err)
+	}
+	if err := store.Lock(ctx); err != nil {
+		t.Errorf("lock failed after force unlock: %v", err)
+	}
+}
+
+func TestCQLStore_Lineage(t *testing.T) {
+	store := cqlstore.New(openFake(t, newFakeServer()))
+	store.Lineage = "bad-lineage"
+	if err := store.Init(context.Background()); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+}
@@ -0,0 +1,293 @@
+// Package crdbstore provides a golumn.Store for CockroachDB. It issues
+// plain SQL through database/sql and does not import a driver; any driver
+// whose errors report their SQLSTATE through a SQLState() string method,
+// such as pgx and lib/pq, works.
+//
+// Unlike pgstore it does not use advisory locks, which CockroachDB does not
+// implement, but a row in a lock table, as sqlite3store does. Under
+// contention CockroachDB aborts transactions with serialization failures
+// (SQLSTATE 40001) that the client is expected to retry, so every statement
+// that writes is retried with backoff on them.
+package crdbstore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// DefaultMaxRetries is the number of retries used unless
+// CrdbStore.MaxRetries is set.
+const DefaultMaxRetries = 10
+
+// serializationFailure is the SQLSTATE of serialization_failure, which
+// CockroachDB reports for transactions the client should retry.
+const serializationFailure = "40001"
+
+// Retry backoff bounds.
+var (
+	retryBackoffMin = 10 * time.Millisecond
+	retryBackoffMax = time.Second
+)
+
+type CrdbStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Production flags the store as production, requiring approved runs.
+	Production bool
+	// MaxRetries is how many times a write failing with a serialization
+	// failure is retried. Zero means DefaultMaxRetries; a negative value
+	// disables retries.
+	MaxRetries int
+	// Lineage suffixes the store's tables, except the database-wide
+	// schema_identity, see golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store           = (*CrdbStore)(nil)
+	_ golumn.ForceUnlocker   = (*CrdbStore)(nil)
+	_ golumn.ProductionStore = (*CrdbStore)(nil)
+	_ golumn.LockInspector   = (*CrdbStore)(nil)
+	_ golumn.VersionLister   = (*CrdbStore)(nil)
+	_ golumn.TableLister     = (*CrdbStore)(nil)
+	_ golumn.IdentityStore   = (*CrdbStore)(nil)
+)
+
+func New(db *sql.DB) *CrdbStore {
+	return &CrdbStore{instance: db, owner: newOwnerID()}
+}
+
+func newOwnerID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *CrdbStore) DB() *sql.DB {
+	return s.instance
+}
+
+func (s *CrdbStore) IsProduction() bool {
+	return s.Production
+}
+
+// table names one of the store's tables for its lineage.
+func (s *CrdbStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables, which schema_identity, shared by all
+// lineages, completes.
+func (s *CrdbStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_migrations"), "schema_identity"}
+}
+
+func (s *CrdbStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_lock") + " (id INT8 PRIMARY KEY, owner STRING NOT NULL, acquired_at TIMESTAMPTZ NOT NULL DEFAULT now())",
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (version_id INT8 PRIMARY KEY, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())",
+		"CREATE TABLE IF NOT EXISTS schema_identity (id INT8 PRIMARY KEY CHECK (id = 1), identity STRING NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now())",
+	} {
+		if err := s.exec(ctx, "init", stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *CrdbStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	var n int64
+	err := s.retry(ctx, "lock", func() error {
+		res, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_lock")+" (id, owner) VALUES (1, $1) ON CONFLICT (id) DO NOTHING", s.owner)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return golumn.ErrLocked
+	}
+	s.held = true
+	s.Log.Debugf("crdbstore: acquired lock as %s", s.owner)
+	return nil
+}
+
+func (s *CrdbStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		var n int64
+		err := s.retry(ctx, "release", func() error {
+			res, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1 AND owner = $1", s.owner)
+			if err != nil {
+				return err
+			}
+			n, err = res.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		s.held = false
+
+		if n == 1 {
+			s.Log.Debugf("crdbstore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("crdbstore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *CrdbStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.exec(ctx, "force unlock", "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1"); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("crdbstore: forcibly released lock")
+	return nil
+}
+
+func (s *CrdbStore) Locked(ctx context.Context) (bool, error) {
+	var locked bool
+	err := s.instance.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+s.table("schema_lock")+")").Scan(&locked)
+	return locked, err
+}
+
+func (s *CrdbStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id DESC LIMIT 1")
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *CrdbStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *CrdbStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	return s.exec(ctx, "insert", "INSERT INTO "+s.table("schema_migrations")+" (version_id) VALUES ($1)", v)
+}
+
+func (s *CrdbStore) Remove(ctx context.Context, v int64) error {
+	return s.exec(ctx, "remove", "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = $1", v)
+}
+
+// Identity returns the database's identity, inserting it unless a row,
+// perhaps from a concurrent call, exists. Stores of every lineage share it.
+func (s *CrdbStore) Identity(ctx context.Context) (string, error) {
+	if err := s.exec(ctx, "identity", "INSERT INTO schema_identity (id, identity) VALUES (1, $1) ON CONFLICT (id) DO NOTHING", golumn.NewIdentity()); err != nil {
+		return "", err
+	}
+	var id string
+	err := s.instance.QueryRowContext(ctx, "SELECT identity FROM schema_identity WHERE id = 1").Scan(&id)
+	return id, err
+}
+
+// exec runs a statement that writes, retrying it on serialization
+// failures.
+func (s *CrdbStore) exec(ctx context.Context, op, query string, args ...any) error {
+	return s.retry(ctx, op, func() error {
+		_, err := s.instance.ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
+// retry calls fn until it succeeds, fails with an error other than a
+// serialization failure, or has been retried MaxRetries times, backing off
+// exponentially between attempts. A serialization failure means the
+// statement's implicit transaction was aborted, so retrying it cannot apply
+// it twice.
+func (s *CrdbStore) retry(ctx context.Context, op string, fn func() error) error {
+	maxRetries := s.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultMaxRetries
+	}
+	backoff := retryBackoffMin
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if attempt >= maxRetries {
+			return fmt.Errorf("%s: giving up after %d retries: %w", op, attempt, err)
+		}
+		s.Log.Debugf("crdbstore: %s: retrying in %s after %v", op, backoff, err)
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+		backoff = min(2*backoff, retryBackoffMax)
+	}
+}
+
+// isRetryable reports whether err carries the SQLSTATE of a serialization
+// failure.
+func isRetryable(err error) bool {
+	var stateErr interface{ SQLState() string }
+	return errors.As(err, &stateErr) && stateErr.SQLState() == serializationFailure
+}
@@ -0,0 +1,38 @@
+//go:build integration
+
+package crdbstore_test
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/lib/pq"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/crdbstore"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestCrdbStore_Conformance runs against the database at $GOLUMN_CRDB_DSN
+// through lib/pq, or the driver named by $GOLUMN_CRDB_DRIVER if it is set. A
+// local container works:
+//
+//	docker run -d -p 26257:26257 cockroachdb/cockroach:latest start-single-node --insecure
+//	GOLUMN_CRDB_DSN='postgres://root@localhost:26257/defaultdb?sslmode=disable' go test -tags integration ./stores/crdbstore
+func TestCrdbStore_Conformance(t *testing.T) {
+	name, dsn := sqltest.Env(t, "CRDB", "postgres")
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, table := range []string{"schema_lock", "schema_migrations"} {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return crdbstore.New(db)
+	})
+}
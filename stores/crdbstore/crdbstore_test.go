@@ -0,0 +1,160 @@
+package crdbstore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/crdbstore"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+// fakeServer records the statements it executes, failing the first
+// failures of them with err.
+type fakeServer struct {
+	sqltest.Server
+	failures int
+	err      error
+	execs    []string
+	owner    string
+	identity string
+}
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query = srv.exec, srv.query
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (srv *fakeServer) exec(_ *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	srv.execs = append(srv.execs, query)
+	if srv.failures > 0 {
+		srv.failures--
+		return nil, srv.err
+	}
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO schema_lock"):
+		if srv.owner != "" {
+			return driver.RowsAffected(0), nil
+		}
+		srv.owner = args[0].(string)
+	case strings.HasPrefix(query, "INSERT INTO schema_identity"):
+		if srv.identity != "" {
+			return driver.RowsAffected(0), nil
+		}
+		srv.identity = args[0].(string)
+	case strings.HasPrefix(query, "DELETE FROM schema_lock"):
+		if srv.owner == "" || len(args) == 1 && srv.owner != args[0].(string) {
+			return driver.RowsAffected(0), nil
+		}
+		srv.owner = ""
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (srv *fakeServer) query(_ *sqltest.Conn, query string, _ []driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(query, "SELECT identity FROM schema_identity") {
+		return sqltest.Value(srv.identity), nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func TestCrdbStore_Retry(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name string
+		call func(*crdbstore.CrdbStore) error
+	}{
+		{"init", func(s *crdbstore.CrdbStore) error { return s.Init(ctx) }},
+		{"insert", func(s *crdbstore.CrdbStore) error { return s.Insert(ctx, 1) }},
+		{"remove", func(s *crdbstore.CrdbStore) error { return s.Remove(ctx, 1) }},
+		{"lock", func(s *crdbstore.CrdbStore) error { return s.Lock(ctx) }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &fakeServer{failures: 3, err: sqlStateError("40001")}
+			if err := tt.call(crdbstore.New(openFake(t, srv))); err != nil {
+				t.Fatalf("expected the serialization failures to be retried, got %v", err)
+			}
+			if srv.failures != 0 {
+				t.Errorf("expected all failures to be consumed, %d left", srv.failures)
+			}
+		})
+	}
+}
+
+func TestCrdbStore_RetryLimits(t *testing.T) {
+	ctx := context.Background()
+
+	srv := &fakeServer{failures: 5, err: sqlStateError("40001")}
+	store := crdbstore.New(openFake(t, srv))
+	store.MaxRetries = 2
+	err := store.Insert(ctx, 1)
+	if err == nil || !strings.Contains(err.Error(), "giving up after 2 retries") {
+		t.Errorf("expected to give up, got %v", err)
+	}
+	if len(srv.execs) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(srv.execs))
+	}
+
+	srv = &fakeServer{failures: 5, err: sqlStateError("23505")}
+	store = crdbstore.New(openFake(t, srv))
+	if err := store.Insert(ctx, 1); err == nil || len(srv.execs) != 1 {
+		t.Errorf("expected other errors to fail at once, got %v after %d attempts", err, len(srv.execs))
+	}
+
+	srv = &fakeServer{failures: 5, err: sqlStateError("40001")}
+	store = crdbstore.New(openFake(t, srv))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := store.Insert(cancelled, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation to stop retries, got %v", err)
+	}
+}
+
+func TestCrdbStore_Lock(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := crdbstore.New(openFake(t, srv)), crdbstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := b.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked from another store, got %v", err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := b.Lock(ctx); err != nil {
+		t.Fatalf("lock after release failed: %v", err)
+	}
+	if err := a.ForceUnlock(ctx); err != nil {
+		t.Fatalf("force unlock failed: %v", err)
+	}
+
+	b.ReleasePolicy = golumn.ReleaseErrorUnheld
+	if err := b.Release(ctx); !errors.Is(err, golumn.ErrNotLocked) {
+		t.Errorf("expected ErrNotLocked after force unlock, got %v", err)
+	}
+}
+
+func TestCrdbStore_Identity(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := crdbstore.New(openFake(t, srv)), crdbstore.New(openFake(t, srv))
+	ctx := context.Background()
+	id, err := a.Identity(ctx)
+	if err != nil || id == "" {
+		t.Fatalf("expected an identity, got %q (err %v)", id, err)
+	}
+	if other, err := b.Identity(ctx); err != nil || other != id {
+		t.Errorf("expected another store on the database to report %q, got %q (err %v)", id, other, err)
+	}
+}
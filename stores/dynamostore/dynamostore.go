@@ -0,0 +1,238 @@
+// Package dynamostore provides a golumn.Store for Amazon DynamoDB. It
+// issues PartiQL through database/sql with ?-style parameters and does not
+// import a driver; a database/sql driver for DynamoDB that runs PartiQL
+// statements, such as github.com/btnguyen2k/godynamo, works. Migrations
+// receive the same *sql.DB, so seeding items and backfills are written in
+// PartiQL too.
+//
+// PartiQL cannot create tables, so Init only checks that they exist; create
+// them beforehand, e.g. with the AWS CLI or infrastructure code:
+//
+//	schema_lock        partition key id (Number)
+//	schema_migrations  partition key version_id (Number)
+//
+// Every write is conditional. INSERT fails if an item with the key exists,
+// which claims the lock item and refuses a version already applied, and a
+// WHERE clause on a non-key attribute of DELETE is a condition on the
+// item, which releases the lock only while it is owned. DynamoDB cannot
+// order a table by its partition key, so versions are sorted by the store.
+package dynamostore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type DynamoStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Lineage suffixes the store's tables, see golumn.LineageTable. The
+	// store does not create tables, so the suffixed ones must exist.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store               = (*DynamoStore)(nil)
+	_ golumn.ForceUnlocker       = (*DynamoStore)(nil)
+	_ golumn.LockInspector       = (*DynamoStore)(nil)
+	_ golumn.VersionLister       = (*DynamoStore)(nil)
+	_ golumn.TableLister         = (*DynamoStore)(nil)
+	_ golumn.LockHolderInspector = (*DynamoStore)(nil)
+)
+
+func New(db *sql.DB) *DynamoStore {
+	return &DynamoStore{instance: db, owner: golumn.NewIdentity()}
+}
+
+func (s *DynamoStore) DB() *sql.DB {
+	return s.instance
+}
+
+// table names one of the store's tables for its lineage.
+func (s *DynamoStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables.
+func (s *DynamoStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_migrations")}
+}
+
+// Init checks that the store's tables exist by reading a key from each.
+func (s *DynamoStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, probe := range []struct{ table, query string }{
+		{s.table("schema_lock"), `SELECT id FROM "` + s.table("schema_lock") + `" WHERE id = 0`},
+		{s.table("schema_migrations"), `SELECT version_id FROM "` + s.table("schema_migrations") + `" WHERE version_id = -1`},
+	} {
+		rows, err := s.instance.QueryContext(ctx, probe.query)
+		if err != nil {
+			return fmt.Errorf("table %s must be created before use: %w", probe.table, err)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *DynamoStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	_, err := s.instance.ExecContext(ctx, `INSERT INTO "`+s.table("schema_lock")+`" VALUE {'id': 1, 'owner': ?}`, s.owner)
+	if err == nil {
+		s.held = true
+		s.Log.Debugf("dynamostore: acquired lock as %s", s.owner)
+		return nil
+	}
+	if isConditionFailed(err) {
+		return golumn.ErrLocked
+	}
+	return err
+}
+
+// isConditionFailed reports whether err is DynamoDB refusing a conditional
+// write. Drivers relay the service's error as text, naming its type, e.g.
+// "DuplicateItemException: Duplicate primary key exists in table".
+func isConditionFailed(err error) bool {
+	return strings.Contains(err.Error(), "DuplicateItem") || strings.Contains(err.Error(), "ConditionalCheckFailed")
+}
+
+func (s *DynamoStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		_, err := s.instance.ExecContext(ctx, `DELETE FROM "`+s.table("schema_lock")+`" WHERE id = 1 AND owner = ?`, s.owner)
+		if err != nil && !isConditionFailed(err) {
+			return err
+		}
+		s.held = false
+
+		if err == nil {
+			s.Log.Debugf("dynamostore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("dynamostore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *DynamoStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.instance.ExecContext(ctx, `DELETE FROM "`+s.table("schema_lock")+`" WHERE id = 1`); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("dynamostore: forcibly released lock")
+	return nil
+}
+
+func (s *DynamoStore) Locked(ctx context.Context) (bool, error) {
+	rows, err := s.instance.QueryContext(ctx, `SELECT id FROM "`+s.table("schema_lock")+`" WHERE id = 1`)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	locked := rows.Next()
+	return locked, rows.Err()
+}
+
+// LockHolder returns the owner recorded in the lock item. The item records
+// no time, so Since is zero.
+func (s *DynamoStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	rows, err := s.instance.QueryContext(ctx, `SELECT owner FROM "`+s.table("schema_lock")+`" WHERE id = 1`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	var holder golumn.LockHolder
+	if err := rows.Scan(&holder.Owner); err != nil {
+		return nil, err
+	}
+	return &holder, rows.Err()
+}
+
+func (s *DynamoStore) Version(ctx context.Context) (int64, error) {
+	versions, err := s.Versions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, golumn.ErrInitialVersion
+	}
+	return versions[len(versions)-1], nil
+}
+
+func (s *DynamoStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, `SELECT version_id FROM "`+s.table("schema_migrations")+`"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// Insert records v with the time it was applied, taken from the local
+// clock, as PartiQL has no function for the current time.
+func (s *DynamoStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	appliedAt := time.Now().UTC().Format(time.RFC3339)
+	_, err := s.instance.ExecContext(ctx, `INSERT INTO "`+s.table("schema_migrations")+`" VALUE {'version_id': ?, 'applied_at': ?}`, v, appliedAt)
+	if err != nil && isConditionFailed(err) {
+		return fmt.Errorf("version %d already applied", v)
+	}
+	return err
+}
+
+func (s *DynamoStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.instance.ExecContext(ctx, `DELETE FROM "`+s.table("schema_migrations")+`" WHERE version_id = ?`, v)
+	return err
+}
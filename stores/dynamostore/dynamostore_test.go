@@ -0,0 +1,168 @@
+package dynamostore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/dynamostore"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestDynamoStore_Conformance runs against the tables reached through
+// $GOLUMN_DYNAMODB_DSN with the driver named by $GOLUMN_DYNAMODB_DRIVER,
+// which the test binary must have registered, e.g. "godynamo" through a
+// blank import of github.com/btnguyen2k/godynamo added locally. The tables
+// must exist and are emptied before each test.
+func TestDynamoStore_Conformance(t *testing.T) {
+	name, dsn := sqltest.Env(t, "DYNAMODB", "")
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		store := dynamostore.New(db)
+		if err := store.ForceUnlock(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		versions, err := store.Versions(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range versions {
+			if err := store.Remove(context.Background(), v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dynamostore.New(db)
+	})
+}
+
+// fakeServer interprets the store's PartiQL against in-memory tables,
+// failing conditional writes with DynamoDB's error types.
+type fakeServer struct {
+	sqltest.Server
+	owner   string
+	applied map[int64]bool
+	queries []string
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{applied: make(map[int64]bool)}
+}
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query = srv.exec, srv.query
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (srv *fakeServer) exec(_ *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	srv.queries = append(srv.queries, query)
+	switch {
+	case strings.HasPrefix(query, `INSERT INTO "schema_lock" `):
+		if srv.owner != "" {
+			return nil, errors.New("DuplicateItemException: Duplicate primary key exists in table")
+		}
+		srv.owner = args[0].(string)
+	case strings.HasPrefix(query, `DELETE FROM "schema_lock" WHERE id = 1 AND owner = ?`):
+		if srv.owner != args[0] {
+			return nil, errors.New("ConditionalCheckFailedException: The conditional request failed")
+		}
+		srv.owner = ""
+	case strings.HasPrefix(query, `DELETE FROM "schema_lock" WHERE id = 1`):
+		srv.owner = ""
+	case strings.HasPrefix(query, `INSERT INTO "schema_migrations" `):
+		v := args[0].(int64)
+		if srv.applied[v] {
+			return nil, errors.New("DuplicateItemException: Duplicate primary key exists in table")
+		}
+		srv.applied[v] = true
+	case strings.HasPrefix(query, `DELETE FROM "schema_migrations" WHERE version_id = ?`):
+		delete(srv.applied, args[0].(int64))
+	default:
+		return nil, errors.New("unexpected statement: " + query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (srv *fakeServer) query(_ *sqltest.Conn, query string, _ []driver.Value) (driver.Rows, error) {
+	srv.queries = append(srv.queries, query)
+	switch {
+	case strings.HasPrefix(query, `SELECT id FROM "schema_lock" WHERE id = 0`),
+		strings.HasPrefix(query, `SELECT version_id FROM "schema_migrations" WHERE version_id = -1`):
+		return sqltest.Rows([]string{"id"}), nil
+	case strings.HasPrefix(query, `SELECT id FROM "schema_lock" WHERE id = 1`):
+		if srv.owner != "" {
+			return sqltest.Rows([]string{"id"}, []driver.Value{int64(1)}), nil
+		}
+		return sqltest.Rows([]string{"id"}), nil
+	case strings.HasPrefix(query, `SELECT owner FROM "schema_lock" WHERE id = 1`):
+		if srv.owner != "" {
+			return sqltest.Rows([]string{"owner"}, []driver.Value{srv.owner}), nil
+		}
+		return sqltest.Rows([]string{"owner"}), nil
+	case strings.HasPrefix(query, `SELECT version_id FROM "schema_migrations"`):
+		// Scan order is arbitrary; the store must sort.
+		var rows [][]driver.Value
+		for v := range srv.applied {
+			rows = append(rows, []driver.Value{v})
+		}
+		return sqltest.Rows([]string{"version_id"}, rows...), nil
+	}
+	return nil, errors.New("ResourceNotFoundException: Requested resource not found: " + query)
+}
+
+func TestDynamoStore_Fake(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		return dynamostore.New(openFake(t, newFakeServer()))
+	})
+}
+
+func TestDynamoStore_LockHeldElsewhere(t *testing.T) {
+	srv := newFakeServer()
+	srv.owner = "other"
+	store := dynamostore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if err := store.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if holder, err := store.LockHolder(ctx); err != nil || holder == nil || holder.Owner != "other" {
+		t.Errorf("expected the lock held by other, got %+v (err %v)", holder, err)
+	}
+	if err := store.ForceUnlock(ctx); err != nil {
+		t.Fatalf("force unlock failed: %v", err)
+	}
+	if holder, err := store.LockHolder(ctx); err != nil || holder != nil {
+		t.Errorf("expected no holder after force unlock, got %+v (err %v)", holder, err)
+	}
+	if err := store.Lock(ctx); err != nil {
+		t.Errorf("lock failed after force unlock: %v", err)
+	}
+}
+
+func TestDynamoStore_Lineage(t *testing.T) {
+	srv := newFakeServer()
+	store := dynamostore.New(openFake(t, srv))
+	store.Lineage = "bad-lineage"
+	if err := store.Init(context.Background()); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+
+	// The fake only has the default tables, so the lineage's are missing.
+	store.Lineage = "anonymize"
+	err := store.Init(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "table schema_lock_anonymize must be created") {
+		t.Errorf("expected Init to report the missing table, got %v", err)
+	}
+	if !slices.Contains(srv.queries, `SELECT id FROM "schema_lock_anonymize" WHERE id = 0`) {
+		t.Errorf("expected the lineage's table to be probed, got %q", srv.queries)
+	}
+}
@@ -0,0 +1,291 @@
+// Package genericstore provides a golumn.Store for databases without a
+// store of their own. It issues ANSI SQL through database/sql, adjusted by
+// a Dialect for what varies between databases, and does not import a
+// driver. The database must support CREATE TABLE IF NOT EXISTS and report
+// primary key violations as errors.
+//
+// The store lock is a row in schema_lock with a fixed primary key, which
+// only one migrator can insert.
+package genericstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// Dialect describes how a database differs from ANSI SQL. The zero Dialect
+// uses ? placeholders and CURRENT_TIMESTAMP, and recognizes conflicts by
+// their message.
+type Dialect struct {
+	// Placeholder returns the query parameter placeholder for the nth
+	// argument, counting from 1, e.g. DollarPlaceholder. If nil, ? is used.
+	Placeholder func(n int) string
+	// Now is the SQL expression for the current time. If empty,
+	// CURRENT_TIMESTAMP is used.
+	Now string
+	// IsConflict reports whether err is a primary key violation. If nil,
+	// IsConflictMessage is used.
+	IsConflict func(err error) bool
+}
+
+// DollarPlaceholder numbers placeholders like PostgreSQL: $1, $2, ...
+func DollarPlaceholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// ColonPlaceholder numbers placeholders like Oracle: :1, :2, ...
+func ColonPlaceholder(n int) string {
+	return ":" + strconv.Itoa(n)
+}
+
+// AtPlaceholder numbers placeholders like SQL Server: @p1, @p2, ...
+func AtPlaceholder(n int) string {
+	return "@p" + strconv.Itoa(n)
+}
+
+// IsConflictMessage reports whether the message of err mentions a
+// duplicate key or a unique or primary key constraint, as most drivers'
+// messages for conflicts do.
+func IsConflictMessage(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"duplicate", "unique", "primary key", "constraint"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type GenericStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Lineage suffixes the store's tables, see golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	dialect  Dialect
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store               = (*GenericStore)(nil)
+	_ golumn.ForceUnlocker       = (*GenericStore)(nil)
+	_ golumn.LockInspector       = (*GenericStore)(nil)
+	_ golumn.VersionLister       = (*GenericStore)(nil)
+	_ golumn.TableLister         = (*GenericStore)(nil)
+	_ golumn.LockHolderInspector = (*GenericStore)(nil)
+)
+
+func New(db *sql.DB, dialect Dialect) *GenericStore {
+	if dialect.Now == "" {
+		dialect.Now = "CURRENT_TIMESTAMP"
+	}
+	if dialect.IsConflict == nil {
+		dialect.IsConflict = IsConflictMessage
+	}
+	return &GenericStore{instance: db, dialect: dialect, owner: golumn.NewIdentity()}
+}
+
+func (s *GenericStore) DB() *sql.DB {
+	return s.instance
+}
+
+// q renames the store's tables in query for the lineage and rewrites its ?
+// placeholders and NOW() for the dialect. The store's queries contain no
+// other question marks.
+func (s *GenericStore) q(query string) string {
+	query = strings.ReplaceAll(query, "NOW()", s.dialect.Now)
+	if s.Lineage != "" {
+		suffix := "_" + s.Lineage
+		query = strings.NewReplacer(
+			"schema_lock", "schema_lock"+suffix,
+			"schema_migrations", "schema_migrations"+suffix,
+		).Replace(query)
+	}
+	if s.dialect.Placeholder == nil {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(s.dialect.Placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// table names one of the store's tables for its lineage.
+func (s *GenericStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables.
+func (s *GenericStore) Tables() []string {
+	return []string{s.q(s.table("schema_lock")), s.q(s.table("schema_migrations"))}
+}
+
+func (s *GenericStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_lock") + " (id INTEGER NOT NULL PRIMARY KEY, owner VARCHAR(64) NOT NULL, locked_at TIMESTAMP NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (version_id BIGINT NOT NULL PRIMARY KEY, applied_at TIMESTAMP NOT NULL)",
+	} {
+		if _, err := s.instance.ExecContext(ctx, s.q(stmt)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *GenericStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	_, err := s.instance.ExecContext(ctx, s.q("INSERT INTO "+s.table("schema_lock")+" (id, owner, locked_at) VALUES (1, ?, NOW())"), s.owner)
+	if err == nil {
+		s.held = true
+		s.Log.Debugf("genericstore: acquired lock as %s", s.owner)
+		return nil
+	}
+	if s.dialect.IsConflict(err) {
+		return golumn.ErrLocked
+	}
+	return err
+}
+
+func (s *GenericStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		res, err := s.instance.ExecContext(ctx, s.q("DELETE FROM "+s.table("schema_lock")+" WHERE id = 1 AND owner = ?"), s.owner)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		s.held = false
+
+		if n > 0 {
+			s.Log.Debugf("genericstore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("genericstore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *GenericStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.instance.ExecContext(ctx, s.q("DELETE FROM "+s.table("schema_lock")+" WHERE id = 1")); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("genericstore: forcibly released lock")
+	return nil
+}
+
+func (s *GenericStore) Locked(ctx context.Context) (bool, error) {
+	var n int64
+	err := s.instance.QueryRowContext(ctx, s.q("SELECT COUNT(*) FROM "+s.table("schema_lock"))).Scan(&n)
+	return n > 0, err
+}
+
+// LockHolder returns the owner recorded in the lock row and when it took
+// the lock. Since is zero if the driver does not scan locked_at as a
+// time.Time.
+func (s *GenericStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	var owner string
+	var lockedAt any
+	err := s.instance.QueryRowContext(ctx, s.q("SELECT owner, locked_at FROM "+s.table("schema_lock")+" WHERE id = 1")).Scan(&owner, &lockedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	holder := &golumn.LockHolder{Owner: owner}
+	if t, ok := lockedAt.(time.Time); ok {
+		holder.Since = t
+	}
+	return holder, nil
+}
+
+// Version reads the highest version with MAX rather than ORDER BY and
+// LIMIT, which not every database supports.
+func (s *GenericStore) Version(ctx context.Context) (int64, error) {
+	var version sql.NullInt64
+	if err := s.instance.QueryRowContext(ctx, s.q("SELECT MAX(version_id) FROM "+s.table("schema_migrations"))).Scan(&version); err != nil {
+		return 0, err
+	}
+	if !version.Valid {
+		return 0, golumn.ErrInitialVersion
+	}
+	return version.Int64, nil
+}
+
+func (s *GenericStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, s.q("SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *GenericStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	_, err := s.instance.ExecContext(ctx, s.q("INSERT INTO "+s.table("schema_migrations")+" (version_id, applied_at) VALUES (?, NOW())"), v)
+	if err != nil && s.dialect.IsConflict(err) {
+		return errors.Join(fmt.Errorf("version %d already applied", v), err)
+	}
+	return err
+}
+
+func (s *GenericStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.instance.ExecContext(ctx, s.q("DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = ?"), v)
+	return err
+}
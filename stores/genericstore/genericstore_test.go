@@ -0,0 +1,113 @@
+package genericstore_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/genericstore"
+	"github.com/jonathonwebb/golumn/storetest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestGenericStore_Conformance(t *testing.T) {
+	for name, dialect := range map[string]genericstore.Dialect{
+		"zero":   {},
+		"dollar": {Placeholder: genericstore.DollarPlaceholder, Now: "datetime('now')"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			storetest.TestStore(t, func() golumn.Store {
+				return genericstore.New(openDB(t), dialect)
+			})
+		})
+	}
+}
+
+func TestGenericStore_IsConflict(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	var classified []error
+	dialect := genericstore.Dialect{IsConflict: func(err error) bool {
+		classified = append(classified, err)
+		return false
+	}}
+
+	holder := genericstore.New(db, dialect)
+	if err := holder.Init(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if err := holder.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+
+	err := genericstore.New(db, dialect).Lock(ctx)
+	if err == nil || errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected the unclassified conflict to be returned, got %v", err)
+	}
+	if len(classified) != 1 {
+		t.Errorf("expected IsConflict to be consulted once, got %d", len(classified))
+	}
+}
+
+func TestIsConflictMessage(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"UNIQUE constraint failed: schema_lock.id":                          true,
+		"Error 1062: Duplicate entry '1' for key 'PRIMARY'":                 true,
+		`duplicate key value violates unique constraint "schema_lock_pkey"`: true,
+		"Violation of PRIMARY KEY constraint 'PK_schema_lock'":              true,
+		"connection refused": false,
+	} {
+		if got := genericstore.IsConflictMessage(errors.New(msg)); got != want {
+			t.Errorf("IsConflictMessage(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
+
+func TestGenericStore_Lineage(t *testing.T) {
+	store := genericstore.New(openDB(t), genericstore.Dialect{})
+	store.Lineage = "bad-lineage"
+	if err := store.Init(context.Background()); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+}
+
+func TestGenericStore_LockHolder(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	store := genericstore.New(db, genericstore.Dialect{})
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+
+	holder, err := store.LockHolder(ctx)
+	if err != nil || holder != nil {
+		t.Fatalf("expected no holder before Lock, got %v, %v", holder, err)
+	}
+
+	if err := store.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	holder, err = genericstore.New(db, genericstore.Dialect{}).LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("lock holder failed: %v", err)
+	}
+	if holder == nil || holder.Owner == "" {
+		t.Fatalf("expected the holder's owner, got %+v", holder)
+	}
+	if holder.Since.IsZero() {
+		t.Error("expected the time the lock was taken")
+	}
+}
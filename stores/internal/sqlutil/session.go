@@ -0,0 +1,109 @@
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// SessionConn tracks the connection whose session holds a store's
+// session-level lock, such as an advisory lock, and the connection reserved
+// for it ahead of the lock, see golumn.ConnReserver. The zero value is ready
+// to use.
+type SessionConn struct {
+	mu       sync.Mutex
+	reserved *sql.Conn
+	held     *sql.Conn
+}
+
+// Reserve takes a connection from db for the lock's session, which Get then
+// returns, and keeps it out of the pool until the returned func is called
+// or, if the lock is held by then, until it is released.
+func (c *SessionConn) Reserve(ctx context.Context, db *sql.DB) (unreserve func() error, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reserved != nil {
+		return nil, errors.New("lock connection already reserved")
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.reserved = conn
+	return func() error {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		if c.reserved != conn {
+			return nil
+		}
+		c.reserved = nil
+		if c.held == conn {
+			return nil
+		}
+		return conn.Close()
+	}, nil
+}
+
+// Get returns the connection to take the lock on: the reserved one, if
+// any, or else a new one from db.
+func (c *SessionConn) Get(ctx context.Context, db *sql.DB) (*sql.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.reserved != nil {
+		return c.reserved, nil
+	}
+	return db.Conn(ctx)
+}
+
+// Held returns the connection whose session holds the lock, or nil.
+func (c *SessionConn) Held() *sql.Conn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.held
+}
+
+// Hold records conn as holding the lock, or nil once it no longer does.
+func (c *SessionConn) Hold(conn *sql.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.held = conn
+}
+
+// Put gives back conn, whose session does not hold the lock, keeping it if
+// it is reserved and closing it otherwise. If err is not nil, the lock may
+// still be held, so Put ends the session with Discard instead and returns
+// err with any error doing so.
+func (c *SessionConn) Put(conn *sql.Conn, err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil {
+		return errors.Join(err, c.end(conn))
+	}
+	if c.reserved == conn {
+		return nil
+	}
+	return conn.Close()
+}
+
+// End ends conn's session with Discard, releasing any lock it holds, and
+// gives up its reservation.
+func (c *SessionConn) End(conn *sql.Conn) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.end(conn)
+}
+
+func (c *SessionConn) end(conn *sql.Conn) error {
+	if c.reserved == conn {
+		c.reserved = nil
+	}
+	if c.held == conn {
+		c.held = nil
+	}
+	return Discard(conn)
+}
@@ -0,0 +1,189 @@
+// Package sqltest provides a fake database/sql driver for the stores'
+// tests. Every statement run on a connection to a Server is answered by the
+// funcs a test gives it, so that each test scripts only the behavior of the
+// database its store relies on.
+package sqltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// Env returns the driver name and data source name of the database a
+// store's conformance tests run against, read from $GOLUMN_<name>_DRIVER,
+// or def if it is unset, and $GOLUMN_<name>_DSN. It skips t unless the
+// data source is set and the driver is registered.
+func Env(t testing.TB, name, def string) (driverName, dsn string) {
+	t.Helper()
+	prefix := "GOLUMN_" + name + "_"
+	driverName, dsn = os.Getenv(prefix+"DRIVER"), os.Getenv(prefix+"DSN")
+	if driverName == "" {
+		driverName = def
+	}
+	if dsn == "" || !slices.Contains(sql.Drivers(), driverName) {
+		t.Skip(prefix + "DSN and a registered " + prefix + "DRIVER are required")
+	}
+	return driverName, dsn
+}
+
+// Server is a fake database server. Its funcs are called with the server
+// locked, so they may share state without locking it themselves.
+type Server struct {
+	// Exec answers statements run with Exec. If it is nil, or returns a nil
+	// result, statements succeed with no rows affected.
+	Exec func(c *Conn, query string, args []driver.Value) (driver.Result, error)
+	// Query answers statements run with Query. If it is nil, queries fail.
+	Query func(c *Conn, query string, args []driver.Value) (driver.Rows, error)
+	// Begin answers a request for a transaction, which commits and rolls
+	// back as no-ops if begun. If it is nil, transactions are refused.
+	Begin func(c *Conn) error
+	// Close, if set, is called as a connection closes, e.g. to release the
+	// session locks it holds.
+	Close func(c *Conn)
+
+	mu     sync.Mutex
+	nextID int64
+}
+
+// Open returns a DB whose connections are to srv, closed when t ends.
+func Open(t testing.TB, srv *Server) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(connector{srv})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// Conn is a connection to a Server.
+type Conn struct {
+	srv *Server
+	// ID numbers the server's connections from 1 in the order they were
+	// opened, like a session or process id.
+	ID int64
+	// Configured is for Exec to record that the connection's session was
+	// configured, e.g. by a SET statement run for golumn.ConnConfig.
+	Configured bool
+}
+
+func (c *Conn) Prepare(query string) (driver.Stmt, error) { return &stmt{c, query}, nil }
+
+func (c *Conn) Begin() (driver.Tx, error) {
+	c.srv.mu.Lock()
+	defer c.srv.mu.Unlock()
+	if c.srv.Begin == nil {
+		return nil, errors.New("unexpected transaction")
+	}
+	if err := c.srv.Begin(c); err != nil {
+		return nil, err
+	}
+	return tx{}, nil
+}
+
+func (c *Conn) Close() error {
+	c.srv.mu.Lock()
+	defer c.srv.mu.Unlock()
+	if c.srv.Close != nil {
+		c.srv.Close(c)
+	}
+	return nil
+}
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type stmt struct {
+	conn  *Conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	srv := s.conn.srv
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	var res driver.Result
+	if srv.Exec != nil {
+		var err error
+		if res, err = srv.Exec(s.conn, s.query, args); err != nil {
+			return nil, err
+		}
+	}
+	if res == nil {
+		res = driver.RowsAffected(0)
+	}
+	return res, nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	srv := s.conn.srv
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.Query == nil {
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	return srv.Query(s.conn, s.query, args)
+}
+
+type connector struct{ srv *Server }
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	c.srv.mu.Lock()
+	defer c.srv.mu.Unlock()
+	c.srv.nextID++
+	return &Conn{srv: c.srv, ID: c.srv.nextID}, nil
+}
+
+func (c connector) Driver() driver.Driver { return fakeDriver(c) }
+
+type fakeDriver struct{ srv *Server }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return connector(d).Connect(context.Background())
+}
+
+// Rows returns the rows of a result with the named columns.
+func Rows(columns []string, rows ...[]driver.Value) driver.Rows {
+	return &rowSet{columns: columns, rows: rows}
+}
+
+// Value returns a result of a single row with a single column holding v,
+// which is nil for NULL.
+func Value(v driver.Value) driver.Rows {
+	return Rows([]string{"v"}, []driver.Value{v})
+}
+
+// Values returns a result of a single column holding each of vs in a row.
+func Values[T any](vs ...T) driver.Rows {
+	rows := make([][]driver.Value, len(vs))
+	for i, v := range vs {
+		rows[i] = []driver.Value{v}
+	}
+	return Rows([]string{"v"}, rows...)
+}
+
+type rowSet struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *rowSet) Columns() []string { return r.columns }
+func (r *rowSet) Close() error      { return nil }
+
+func (r *rowSet) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package sqlutil holds the database/sql helpers shared by the stores.
+package sqlutil
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// WithTx runs fn in a transaction on db, committing it if fn returns nil and
+// rolling it back otherwise.
+func WithTx(ctx context.Context, db *sql.DB, fn func(context.Context, *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				err = errors.Join(err, rollbackErr)
+			}
+		} else {
+			if commitErr := tx.Commit(); commitErr != nil {
+				err = errors.Join(err, commitErr)
+			}
+		}
+	}()
+
+	return fn(ctx, tx)
+}
+
+// Discard closes conn without returning it to the pool, ending its session
+// and with it any session-level lock the session holds. Stores whose lock
+// failed to release use it, since closing the session is the only other way
+// to release the lock.
+func Discard(conn *sql.Conn) error {
+	err := conn.Raw(func(any) error { return driver.ErrBadConn })
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) {
+		return nil
+	}
+	return err
+}
+
+// IsDML reports whether stmt, after any leading comments, is a query or data
+// change, the statements the stores' Explain methods describe.
+func IsDML(stmt string) bool {
+	for {
+		stmt = strings.TrimSpace(stmt)
+		switch {
+		case strings.HasPrefix(stmt, "--"):
+			_, stmt, _ = strings.Cut(stmt, "\n")
+		case strings.HasPrefix(stmt, "/*"):
+			_, stmt, _ = strings.Cut(stmt, "*/")
+		default:
+			keyword := stmt
+			if i := strings.IndexFunc(stmt, func(r rune) bool { return !unicode.IsLetter(r) }); i >= 0 {
+				keyword = stmt[:i]
+			}
+			switch strings.ToUpper(keyword) {
+			case "SELECT", "INSERT", "UPDATE", "DELETE", "REPLACE", "WITH":
+				return true
+			}
+			return false
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Package libsqlstore provides a golumn.Store for libSQL databases, such as
+// those hosted by Turso, reached through a remote driver like
+// github.com/tursodatabase/libsql-client-go. It issues plain SQL through
+// database/sql and does not import a driver.
+//
+// Remote drivers speak HTTP to the server, and some of database/sql
+// behaves differently than with a local SQLite database: interactive
+// transactions are unsupported or expire between requests, and the
+// sql.Result of a statement may not carry LastInsertId or RowsAffected.
+// The store therefore uses neither. Each operation is a single statement,
+// and statements that must report what they changed use RETURNING. Use
+// sqlitestore for local libSQL files.
+package libsqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type LibsqlStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Lineage suffixes the store's tables, see golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store         = (*LibsqlStore)(nil)
+	_ golumn.ForceUnlocker = (*LibsqlStore)(nil)
+	_ golumn.LockInspector = (*LibsqlStore)(nil)
+	_ golumn.VersionLister = (*LibsqlStore)(nil)
+	_ golumn.IdentityStore = (*LibsqlStore)(nil)
+	_ golumn.TableLister   = (*LibsqlStore)(nil)
+)
+
+func New(db *sql.DB) *LibsqlStore {
+	return &LibsqlStore{instance: db, owner: golumn.NewIdentity()}
+}
+
+func (s *LibsqlStore) DB() *sql.DB {
+	return s.instance
+}
+
+// table names one of the store's tables for its lineage.
+func (s *LibsqlStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables, which schema_identity, shared by all
+// lineages, completes.
+func (s *LibsqlStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_migrations"), "schema_identity"}
+}
+
+// Init creates the store's tables. Each statement is idempotent, so no
+// transaction is needed to make concurrent calls safe.
+func (s *LibsqlStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_lock") + " (id INTEGER PRIMARY KEY, owner TEXT NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (id INTEGER PRIMARY KEY, version_id INTEGER UNIQUE NOT NULL, applied_at DATETIME NOT NULL DEFAULT (datetime('now')))",
+		"CREATE TABLE IF NOT EXISTS schema_identity (id INTEGER PRIMARY KEY CHECK (id = 1), identity TEXT NOT NULL, created_at DATETIME NOT NULL DEFAULT (datetime('now')))",
+	} {
+		if _, err := s.instance.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *LibsqlStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_lock")+" (id, owner) VALUES (1, ?)", s.owner)
+	if err == nil {
+		s.held = true
+		s.Log.Debugf("libsqlstore: acquired lock as %s", s.owner)
+		return nil
+	}
+	if isConstraint(err) {
+		return golumn.ErrLocked
+	}
+	return err
+}
+
+// isConstraint reports whether err is a constraint violation. Remote
+// drivers relay the server's error as text, e.g. "SQLITE_CONSTRAINT:
+// UNIQUE constraint failed: schema_lock.id".
+func isConstraint(err error) bool {
+	return strings.Contains(err.Error(), "SQLITE_CONSTRAINT") || strings.Contains(err.Error(), "constraint failed")
+}
+
+func (s *LibsqlStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		var id int64
+		err := s.instance.QueryRowContext(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1 AND owner = ? RETURNING id", s.owner).Scan(&id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		s.held = false
+
+		if err == nil {
+			s.Log.Debugf("libsqlstore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("libsqlstore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *LibsqlStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1"); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("libsqlstore: forcibly released lock")
+	return nil
+}
+
+func (s *LibsqlStore) Locked(ctx context.Context) (bool, error) {
+	var n int
+	if err := s.instance.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.table("schema_lock")).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *LibsqlStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id DESC LIMIT 1")
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *LibsqlStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *LibsqlStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id) VALUES (?)", v)
+	return err
+}
+
+func (s *LibsqlStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = ?", v)
+	return err
+}
+
+// Identity returns the database's identity, generating it on first use.
+// The upsert returns the stored identity, so one round trip suffices.
+func (s *LibsqlStore) Identity(ctx context.Context) (string, error) {
+	var id string
+	err := s.instance.QueryRowContext(ctx, "INSERT INTO schema_identity (id, identity) VALUES (1, ?) ON CONFLICT (id) DO UPDATE SET identity = identity RETURNING identity", golumn.NewIdentity()).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,178 @@
+package libsqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/libsqlstore"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestLibsqlStore_Conformance runs against the database at
+// $GOLUMN_LIBSQL_DSN with the driver named by $GOLUMN_LIBSQL_DRIVER, which
+// the test binary must have registered, e.g. "libsql" through a blank
+// import of github.com/tursodatabase/libsql-client-go/libsql added locally.
+func TestLibsqlStore_Conformance(t *testing.T) {
+	name, dsn := sqltest.Env(t, "LIBSQL", "")
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, table := range []string{"schema_lock", "schema_migrations", "schema_identity"} {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return libsqlstore.New(db)
+	})
+}
+
+// fakeServer interprets the store's statements against in-memory tables
+// the way a remote libSQL server would through an HTTP driver: there are
+// no interactive transactions, and results carry neither LastInsertId nor
+// RowsAffected.
+type fakeServer struct {
+	sqltest.Server
+	owner    string
+	applied  map[int64]bool
+	identity string
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{applied: make(map[int64]bool)}
+}
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query = srv.exec, srv.query
+	srv.Begin = func(*sqltest.Conn) error {
+		return errors.New("interactive transactions are not supported")
+	}
+	return sqltest.Open(t, &srv.Server)
+}
+
+// fakeResult reports nothing, like HTTP drivers that do not relay them.
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) {
+	return 0, errors.New("LastInsertId is not supported")
+}
+func (fakeResult) RowsAffected() (int64, error) {
+	return 0, errors.New("RowsAffected is not supported")
+}
+
+func (srv *fakeServer) exec(_ *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+	case strings.HasPrefix(query, "INSERT INTO schema_lock"):
+		if srv.owner != "" {
+			return nil, errors.New("SQLITE_CONSTRAINT: SQLite error: UNIQUE constraint failed: schema_lock.id")
+		}
+		srv.owner = args[0].(string)
+	case strings.HasPrefix(query, "DELETE FROM schema_lock WHERE id = 1"):
+		srv.owner = ""
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		v := args[0].(int64)
+		if srv.applied[v] {
+			return nil, errors.New("SQLITE_CONSTRAINT: SQLite error: UNIQUE constraint failed: schema_migrations.version_id")
+		}
+		srv.applied[v] = true
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		delete(srv.applied, args[0].(int64))
+	default:
+		return nil, errors.New("unexpected statement: " + query)
+	}
+	return fakeResult{}, nil
+}
+
+func (srv *fakeServer) query(_ *sqltest.Conn, query string, args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "DELETE FROM schema_lock WHERE id = 1 AND owner = ? RETURNING"):
+		if srv.owner == "" || srv.owner != args[0] {
+			return sqltest.Rows([]string{"v"}), nil
+		}
+		srv.owner = ""
+		return sqltest.Value(int64(1)), nil
+	case strings.HasPrefix(query, "SELECT COUNT(*) FROM schema_lock"):
+		n := 0
+		if srv.owner != "" {
+			n = 1
+		}
+		return sqltest.Value(int64(n)), nil
+	case strings.HasPrefix(query, "SELECT version_id FROM schema_migrations"):
+		var versions []int64
+		for v := range srv.applied {
+			versions = append(versions, v)
+		}
+		slices.Sort(versions)
+		if strings.Contains(query, "DESC LIMIT 1") {
+			slices.Reverse(versions)
+			versions = versions[:min(len(versions), 1)]
+		}
+		return sqltest.Values(versions...), nil
+	case strings.HasPrefix(query, "INSERT INTO schema_identity"):
+		if srv.identity == "" {
+			srv.identity = args[0].(string)
+		}
+		return sqltest.Value(srv.identity), nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func TestLibsqlStore_Fake(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		return libsqlstore.New(openFake(t, newFakeServer()))
+	})
+}
+
+func TestLibsqlStore_ReleaseAfterForceUnlock(t *testing.T) {
+	srv := newFakeServer()
+	store := libsqlstore.New(openFake(t, srv))
+	store.ReleasePolicy = golumn.ReleaseErrorUnheld
+	ctx := context.Background()
+
+	if err := store.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	// Another migrator forces the lock and takes it.
+	srv.owner = "other"
+	if err := store.Release(ctx); !errors.Is(err, golumn.ErrNotLocked) {
+		t.Errorf("expected ErrNotLocked, got %v", err)
+	}
+	if srv.owner != "other" {
+		t.Errorf("expected the other owner's lock to survive, got %q", srv.owner)
+	}
+}
+
+func TestLibsqlStore_Identity(t *testing.T) {
+	store := libsqlstore.New(openFake(t, newFakeServer()))
+	ctx := context.Background()
+
+	first, err := store.Identity(ctx)
+	if err != nil {
+		t.Fatalf("identity failed: %v", err)
+	}
+	second, err := store.Identity(ctx)
+	if err != nil {
+		t.Fatalf("identity failed: %v", err)
+	}
+	if first == "" || first != second {
+		t.Errorf("expected a stable identity, got %q then %q", first, second)
+	}
+}
+
+func TestLibsqlStore_Lineage(t *testing.T) {
+	store := libsqlstore.New(openFake(t, newFakeServer()))
+	store.Lineage = "bad-lineage"
+	if err := store.Init(context.Background()); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+}
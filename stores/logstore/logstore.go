@@ -0,0 +1,340 @@
+// Package logstore provides a golumn.Store decorator that logs every store
+// operation with its outcome and duration.
+package logstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// Store wraps another golumn.Store, logging each call at LogVerbose.
+type Store struct {
+	Inner golumn.Store
+	Log   *golumn.Logger
+}
+
+var (
+	_ golumn.Store               = (*Store)(nil)
+	_ golumn.ForceUnlocker       = (*Store)(nil)
+	_ golumn.ConnReserver        = (*Store)(nil)
+	_ golumn.Targeter            = (*Store)(nil)
+	_ golumn.Explainer           = (*Store)(nil)
+	_ golumn.Annotator           = (*Store)(nil)
+	_ golumn.CompatRegistry      = (*Store)(nil)
+	_ golumn.IdentityStore       = (*Store)(nil)
+	_ golumn.TableLister         = (*Store)(nil)
+	_ golumn.LockInspector       = (*Store)(nil)
+	_ golumn.LockHolderInspector = (*Store)(nil)
+	_ golumn.SharedLocker        = (*Store)(nil)
+	_ golumn.Exporter            = (*Store)(nil)
+	_ golumn.ProductionStore     = (*Store)(nil)
+	_ golumn.VersionLister       = (*listingStore)(nil)
+)
+
+// New wraps inner. The result implements golumn.VersionLister only if inner
+// does, since the migrator changes how it computes pending versions for
+// stores that list them.
+func New(inner golumn.Store, log *golumn.Logger) golumn.Store {
+	s := &Store{Inner: inner, Log: log}
+	if _, ok := inner.(golumn.VersionLister); ok {
+		return &listingStore{s}
+	}
+	return s
+}
+
+// Middleware returns a golumn.StoreMiddleware that wraps stores with New.
+func Middleware(log *golumn.Logger) golumn.StoreMiddleware {
+	return func(inner golumn.Store) golumn.Store {
+		return New(inner, log)
+	}
+}
+
+func (s *Store) logf(op string, start time.Time, err error) {
+	if err != nil {
+		s.Log.Verbosef("logstore: %s failed in %s: %v", op, time.Since(start), err)
+		return
+	}
+	s.Log.Verbosef("logstore: %s ok in %s", op, time.Since(start))
+}
+
+func (s *Store) DB() *sql.DB {
+	return s.Inner.DB()
+}
+
+func (s *Store) Init(ctx context.Context) error {
+	start := time.Now()
+	err := s.Inner.Init(ctx)
+	s.logf("init", start, err)
+	return err
+}
+
+func (s *Store) Lock(ctx context.Context) error {
+	start := time.Now()
+	err := s.Inner.Lock(ctx)
+	s.logf("lock", start, err)
+	return err
+}
+
+func (s *Store) Release(ctx context.Context) error {
+	start := time.Now()
+	err := s.Inner.Release(ctx)
+	s.logf("release", start, err)
+	return err
+}
+
+func (s *Store) Version(ctx context.Context) (int64, error) {
+	start := time.Now()
+	v, err := s.Inner.Version(ctx)
+	if err == nil {
+		s.logf(fmt.Sprintf("version = %d", v), start, nil)
+	} else {
+		s.logf("version", start, err)
+	}
+	return v, err
+}
+
+func (s *Store) Insert(ctx context.Context, v int64) error {
+	start := time.Now()
+	err := s.Inner.Insert(ctx, v)
+	s.logf(fmt.Sprintf("insert %d", v), start, err)
+	return err
+}
+
+func (s *Store) Remove(ctx context.Context, v int64) error {
+	start := time.Now()
+	err := s.Inner.Remove(ctx, v)
+	s.logf(fmt.Sprintf("remove %d", v), start, err)
+	return err
+}
+
+// ForceUnlock forwards to the inner store, failing if it is not a
+// golumn.ForceUnlocker.
+func (s *Store) ForceUnlock(ctx context.Context) error {
+	start := time.Now()
+	var err error
+	if u, ok := s.Inner.(golumn.ForceUnlocker); ok {
+		err = u.ForceUnlock(ctx)
+	} else {
+		err = errors.ErrUnsupported
+	}
+	s.logf("force unlock", start, err)
+	return err
+}
+
+// LockShared forwards to the inner store, failing with
+// errors.ErrUnsupported if it is not a golumn.SharedLocker.
+func (s *Store) LockShared(ctx context.Context) error {
+	start := time.Now()
+	var err error
+	if sl, ok := s.Inner.(golumn.SharedLocker); ok {
+		err = sl.LockShared(ctx)
+	} else {
+		err = errors.ErrUnsupported
+	}
+	s.logf("lock shared", start, err)
+	return err
+}
+
+// ReleaseShared forwards to the inner store, failing with
+// errors.ErrUnsupported if it is not a golumn.SharedLocker.
+func (s *Store) ReleaseShared(ctx context.Context) error {
+	start := time.Now()
+	var err error
+	if sl, ok := s.Inner.(golumn.SharedLocker); ok {
+		err = sl.ReleaseShared(ctx)
+	} else {
+		err = errors.ErrUnsupported
+	}
+	s.logf("release shared", start, err)
+	return err
+}
+
+// ReserveConn forwards to the inner store, failing with
+// errors.ErrUnsupported if it is not a golumn.ConnReserver.
+func (s *Store) ReserveConn(ctx context.Context) (func() error, error) {
+	start := time.Now()
+	r, ok := s.Inner.(golumn.ConnReserver)
+	if !ok {
+		s.logf("reserve conn", start, errors.ErrUnsupported)
+		return nil, errors.ErrUnsupported
+	}
+	unreserve, err := r.ReserveConn(ctx)
+	s.logf("reserve conn", start, err)
+	return unreserve, err
+}
+
+// Locked forwards to the inner store, failing with errors.ErrUnsupported if
+// it is not a golumn.LockInspector.
+func (s *Store) Locked(ctx context.Context) (bool, error) {
+	li, ok := s.Inner.(golumn.LockInspector)
+	if !ok {
+		return false, errors.ErrUnsupported
+	}
+	return li.Locked(ctx)
+}
+
+// LockHolder forwards to the inner store, failing with
+// errors.ErrUnsupported if it is not a golumn.LockHolderInspector.
+func (s *Store) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	li, ok := s.Inner.(golumn.LockHolderInspector)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return li.LockHolder(ctx)
+}
+
+// Explain forwards to the inner store, failing with errors.ErrUnsupported
+// if it is not a golumn.Explainer.
+func (s *Store) Explain(ctx context.Context, stmt string) (string, error) {
+	e, ok := s.Inner.(golumn.Explainer)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	return e.Explain(ctx, stmt)
+}
+
+// Annotate forwards to the inner store, failing with errors.ErrUnsupported
+// if it is not a golumn.Annotator.
+func (s *Store) Annotate(ctx context.Context, v int64, key, value string) error {
+	start := time.Now()
+	var err error
+	if a, ok := s.Inner.(golumn.Annotator); ok {
+		err = a.Annotate(ctx, v, key, value)
+	} else {
+		err = errors.ErrUnsupported
+	}
+	s.logf(fmt.Sprintf("annotate %d %s", v, key), start, err)
+	return err
+}
+
+// Annotations forwards to the inner store, failing with
+// errors.ErrUnsupported if it is not a golumn.Annotator.
+func (s *Store) Annotations(ctx context.Context) (map[int64]map[string]string, error) {
+	a, ok := s.Inner.(golumn.Annotator)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return a.Annotations(ctx)
+}
+
+// RegisterCompat forwards to the inner store, failing with
+// errors.ErrUnsupported if it is not a golumn.CompatRegistry.
+func (s *Store) RegisterCompat(ctx context.Context, c golumn.Compat) error {
+	start := time.Now()
+	var err error
+	if r, ok := s.Inner.(golumn.CompatRegistry); ok {
+		err = r.RegisterCompat(ctx, c)
+	} else {
+		err = errors.ErrUnsupported
+	}
+	s.logf(fmt.Sprintf("register compat %s", c.App), start, err)
+	return err
+}
+
+// UnregisterCompat forwards to the inner store, failing with
+// errors.ErrUnsupported if it is not a golumn.CompatRegistry.
+func (s *Store) UnregisterCompat(ctx context.Context, app string) error {
+	start := time.Now()
+	var err error
+	if r, ok := s.Inner.(golumn.CompatRegistry); ok {
+		err = r.UnregisterCompat(ctx, app)
+	} else {
+		err = errors.ErrUnsupported
+	}
+	s.logf(fmt.Sprintf("unregister compat %s", app), start, err)
+	return err
+}
+
+// Compats forwards to the inner store, failing with errors.ErrUnsupported
+// if it is not a golumn.CompatRegistry.
+func (s *Store) Compats(ctx context.Context) ([]golumn.Compat, error) {
+	r, ok := s.Inner.(golumn.CompatRegistry)
+	if !ok {
+		return nil, errors.ErrUnsupported
+	}
+	return r.Compats(ctx)
+}
+
+// Identity forwards to the inner store, failing with errors.ErrUnsupported
+// if it is not a golumn.IdentityStore.
+func (s *Store) Identity(ctx context.Context) (string, error) {
+	is, ok := s.Inner.(golumn.IdentityStore)
+	if !ok {
+		return "", errors.ErrUnsupported
+	}
+	return is.Identity(ctx)
+}
+
+// Tables forwards to the inner store, returning nil if it is not a
+// golumn.TableLister.
+func (s *Store) Tables() []string {
+	if tl, ok := s.Inner.(golumn.TableLister); ok {
+		return tl.Tables()
+	}
+	return nil
+}
+
+// Target forwards to the inner store, returning nil if it is not a
+// golumn.Targeter.
+func (s *Store) Target() any {
+	if t, ok := s.Inner.(golumn.Targeter); ok {
+		return t.Target()
+	}
+	return nil
+}
+
+// Export forwards to the inner store, failing with errors.ErrUnsupported if
+// it is not a golumn.Exporter.
+func (s *Store) Export(ctx context.Context, w io.Writer) error {
+	start := time.Now()
+	var err error
+	if e, ok := s.Inner.(golumn.Exporter); ok {
+		err = e.Export(ctx, w)
+	} else {
+		err = errors.ErrUnsupported
+	}
+	s.logf("export", start, err)
+	return err
+}
+
+// Import forwards to the inner store, failing with errors.ErrUnsupported if
+// it is not a golumn.Exporter.
+func (s *Store) Import(ctx context.Context, r io.Reader) error {
+	start := time.Now()
+	var err error
+	if e, ok := s.Inner.(golumn.Exporter); ok {
+		err = e.Import(ctx, r)
+	} else {
+		err = errors.ErrUnsupported
+	}
+	s.logf("import", start, err)
+	return err
+}
+
+// IsProduction forwards to the inner store, reporting false if it is not a
+// golumn.ProductionStore.
+func (s *Store) IsProduction() bool {
+	ps, ok := s.Inner.(golumn.ProductionStore)
+	return ok && ps.IsProduction()
+}
+
+type listingStore struct {
+	*Store
+}
+
+func (s *listingStore) Versions(ctx context.Context) ([]int64, error) {
+	start := time.Now()
+	versions, err := s.Inner.(golumn.VersionLister).Versions(ctx)
+	if err == nil {
+		s.logf(fmt.Sprintf("versions = %v", versions), start, nil)
+	} else {
+		s.logf("versions", start, err)
+	}
+	return versions, err
+}
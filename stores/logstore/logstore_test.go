@@ -0,0 +1,93 @@
+package logstore_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/logstore"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+	"github.com/jonathonwebb/golumn/storetest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLogstore_Conformance(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		return logstore.New(sqlite3store.New(openDB(t)), nil)
+	})
+}
+
+func TestLogstore_LogsOperations(t *testing.T) {
+	var buf bytes.Buffer
+	store := logstore.New(sqlite3store.New(openDB(t)), golumn.NewLogger(&buf, golumn.LogVerbose))
+
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if err := store.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := store.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if err := store.Insert(ctx, 3); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if _, err := store.Version(ctx); err != nil {
+		t.Fatalf("version failed: %v", err)
+	}
+	if err := store.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"logstore: init ok in ",
+		"logstore: lock ok in ",
+		"logstore: lock failed in ",
+		golumn.ErrLocked.Error(),
+		"logstore: insert 3 ok in ",
+		"logstore: version = 3 ok in ",
+		"logstore: release ok in ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected log to contain %q\n%s", want, out)
+		}
+	}
+}
+
+type minimalStore struct{ golumn.Store }
+
+func TestLogstore_OptionalInterfaces(t *testing.T) {
+	full := logstore.New(sqlite3store.New(openDB(t)), nil)
+	if _, ok := full.(golumn.VersionLister); !ok {
+		t.Error("expected wrapper of a VersionLister to implement VersionLister")
+	}
+
+	minimal := logstore.New(minimalStore{sqlite3store.New(openDB(t))}, nil)
+	if _, ok := minimal.(golumn.VersionLister); ok {
+		t.Error("expected wrapper of a plain Store not to implement VersionLister")
+	}
+	if err := minimal.(golumn.ForceUnlocker).ForceUnlock(context.Background()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if err := minimal.(golumn.SharedLocker).LockShared(context.Background()); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported from LockShared, got %v", err)
+	}
+}
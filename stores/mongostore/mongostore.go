@@ -0,0 +1,357 @@
+// Package mongostore provides a golumn.Store for MongoDB. Applied versions
+// are documents of a schema_migrations collection keyed by version, so a
+// version cannot be recorded twice, and the lock is a document of a
+// schema_lock collection that Lock claims and Release frees with
+// findAndModify, matching only while it is free or owned by the store.
+//
+// The store does not import a driver. It works through Database, which a
+// few lines adapt the official driver to, e.g. for go.mongodb.org/mongo-driver:
+//
+//	type database struct{ db *mongo.Database }
+//
+//	func (d database) Collection(name string) mongostore.Collection {
+//		return collection{d.db.Collection(name)}
+//	}
+//
+//	type collection struct{ c *mongo.Collection }
+//
+//	func (c collection) InsertOne(ctx context.Context, doc mongostore.Doc) error {
+//		_, err := c.c.InsertOne(ctx, bson.M(doc))
+//		return err
+//	}
+//
+//	func (c collection) DeleteOne(ctx context.Context, filter mongostore.Doc) (int64, error) {
+//		res, err := c.c.DeleteOne(ctx, bson.M(filter))
+//		if err != nil {
+//			return 0, err
+//		}
+//		return res.DeletedCount, nil
+//	}
+//
+//	func (c collection) FindOneAndUpdate(ctx context.Context, filter, update mongostore.Doc, upsert bool) (bool, error) {
+//		err := c.c.FindOneAndUpdate(ctx, bson.M(filter), bson.M(update), options.FindOneAndUpdate().SetUpsert(upsert)).Err()
+//		if errors.Is(err, mongo.ErrNoDocuments) {
+//			return false, nil
+//		}
+//		return err == nil, err
+//	}
+//
+//	func (c collection) Find(ctx context.Context, filter mongostore.Doc) ([]mongostore.Doc, error) {
+//		cur, err := c.c.Find(ctx, bson.M(filter))
+//		if err != nil {
+//			return nil, err
+//		}
+//		var docs []mongostore.Doc
+//		return docs, cur.All(ctx, &docs)
+//	}
+//
+// Migrations run against the store's target, e.g. the *mongo.Database
+// itself, rather than a *sql.DB; build them with Migration:
+//
+//	store := mongostore.New(database{db}, db)
+//	sources := []*golumn.Migration{
+//		mongostore.Migration(1, "users_email", func(ctx context.Context, db *mongo.Database) error {
+//			_, err := db.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{"email", 1}}})
+//			return err
+//		}, nil),
+//	}
+package mongostore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// Doc is a document, filter or update, e.g. a bson.M of the official
+// driver, which has the same underlying type.
+type Doc = map[string]any
+
+// Collection is the part of a MongoDB collection the store uses.
+type Collection interface {
+	// InsertOne inserts doc. Inserting a second document with the same _id
+	// must fail with an error reporting MongoDB's DuplicateKey code, 11000,
+	// through a HasErrorCode(int) bool method, as the official driver's
+	// errors do, or a Code() int method. FindOneAndUpdate must do the same
+	// when an upsert races another.
+	InsertOne(ctx context.Context, doc Doc) error
+	// DeleteOne deletes the first document matching filter and reports how
+	// many documents it deleted.
+	DeleteOne(ctx context.Context, filter Doc) (int64, error)
+	// FindOneAndUpdate applies update to the first document matching
+	// filter, or with upsert set inserts one if none matches, and reports
+	// whether a document matched.
+	FindOneAndUpdate(ctx context.Context, filter, update Doc, upsert bool) (bool, error)
+	Find(ctx context.Context, filter Doc) ([]Doc, error)
+}
+
+// Database is the part of a MongoDB database the store uses.
+type Database interface {
+	Collection(name string) Collection
+}
+
+// lockID is the _id of the lock document.
+const lockID = "lock"
+
+type MongoStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Lineage suffixes the store's collections, see golumn.LineageTable.
+	Lineage string
+
+	db     Database
+	target any
+	owner  string
+	mu     sync.Mutex
+	held   bool
+}
+
+var (
+	_ golumn.Store               = (*MongoStore)(nil)
+	_ golumn.Targeter            = (*MongoStore)(nil)
+	_ golumn.ForceUnlocker       = (*MongoStore)(nil)
+	_ golumn.LockInspector       = (*MongoStore)(nil)
+	_ golumn.VersionLister       = (*MongoStore)(nil)
+	_ golumn.TableLister         = (*MongoStore)(nil)
+	_ golumn.LockHolderInspector = (*MongoStore)(nil)
+)
+
+// New returns a store recording versions in db. target is what migrations
+// built with Migration receive, typically the driver's database that db
+// adapts.
+func New(db Database, target any) *MongoStore {
+	return &MongoStore{db: db, target: target, owner: golumn.NewIdentity()}
+}
+
+// DB returns nil: migrations reach MongoDB through Target.
+func (s *MongoStore) DB() *sql.DB {
+	return nil
+}
+
+// Target returns the target passed to New.
+func (s *MongoStore) Target() any {
+	return s.target
+}
+
+// collection names one of the store's collections for its lineage.
+func (s *MongoStore) collection(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's collections.
+func (s *MongoStore) Tables() []string {
+	return []string{s.collection("schema_lock"), s.collection("schema_migrations")}
+}
+
+// Init creates the lock document if it does not exist. MongoDB creates
+// collections on first write, so there is nothing else to create.
+func (s *MongoStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	locks := s.db.Collection(s.collection("schema_lock"))
+	_, err := locks.FindOneAndUpdate(ctx, Doc{"_id": lockID}, Doc{"$setOnInsert": Doc{"owner": ""}}, true)
+	if err != nil && isDuplicateKey(err) {
+		// A concurrent Init inserted the document first.
+		return nil
+	}
+	return err
+}
+
+// duplicateKey is the code of MongoDB's DuplicateKey error.
+const duplicateKey = 11000
+
+// isDuplicateKey reports whether err is MongoDB refusing a second document
+// with the same _id. The official driver's server errors report their codes
+// through HasErrorCode; errors of other drivers and adapters may report
+// theirs through a Code() int method.
+func isDuplicateKey(err error) bool {
+	var serverErr interface{ HasErrorCode(int) bool }
+	if errors.As(err, &serverErr) && serverErr.HasErrorCode(duplicateKey) {
+		return true
+	}
+	var codeErr interface{ Code() int }
+	return errors.As(err, &codeErr) && codeErr.Code() == duplicateKey
+}
+
+func (s *MongoStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	locks := s.db.Collection(s.collection("schema_lock"))
+	claimed, err := locks.FindOneAndUpdate(ctx,
+		Doc{"_id": lockID, "owner": ""},
+		Doc{"$set": Doc{"owner": s.owner, "locked_at": time.Now().UTC()}},
+		false)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return golumn.ErrLocked
+	}
+	s.held = true
+	s.Log.Debugf("mongostore: acquired lock as %s", s.owner)
+	return nil
+}
+
+func (s *MongoStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		locks := s.db.Collection(s.collection("schema_lock"))
+		released, err := locks.FindOneAndUpdate(ctx, Doc{"_id": lockID, "owner": s.owner}, Doc{"$set": Doc{"owner": ""}}, false)
+		if err != nil {
+			return err
+		}
+		s.held = false
+
+		if released {
+			s.Log.Debugf("mongostore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("mongostore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *MongoStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	locks := s.db.Collection(s.collection("schema_lock"))
+	if _, err := locks.FindOneAndUpdate(ctx, Doc{"_id": lockID}, Doc{"$set": Doc{"owner": ""}}, false); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("mongostore: forcibly released lock")
+	return nil
+}
+
+func (s *MongoStore) Locked(ctx context.Context) (bool, error) {
+	docs, err := s.db.Collection(s.collection("schema_lock")).Find(ctx, Doc{"_id": lockID, "owner": Doc{"$ne": ""}})
+	return len(docs) > 0, err
+}
+
+// LockHolder returns the owner recorded in the lock document and its
+// locked_at, which is zero if the driver decodes it as neither a time.Time
+// nor a value with a Time() time.Time method, such as primitive.DateTime.
+func (s *MongoStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	docs, err := s.db.Collection(s.collection("schema_lock")).Find(ctx, Doc{"_id": lockID, "owner": Doc{"$ne": ""}})
+	if err != nil || len(docs) == 0 {
+		return nil, err
+	}
+	owner, _ := docs[0]["owner"].(string)
+	holder := &golumn.LockHolder{Owner: owner}
+	switch t := docs[0]["locked_at"].(type) {
+	case time.Time:
+		holder.Since = t
+	case interface{ Time() time.Time }:
+		holder.Since = t.Time()
+	}
+	return holder, nil
+}
+
+func (s *MongoStore) Version(ctx context.Context) (int64, error) {
+	versions, err := s.Versions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, golumn.ErrInitialVersion
+	}
+	return versions[len(versions)-1], nil
+}
+
+func (s *MongoStore) Versions(ctx context.Context) ([]int64, error) {
+	docs, err := s.db.Collection(s.collection("schema_migrations")).Find(ctx, Doc{})
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]int64, 0, len(docs))
+	for _, doc := range docs {
+		v, err := toVersion(doc["_id"])
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// toVersion converts a decoded _id to a version. Drivers decode BSON
+// integers as int32 or int64 depending on their size.
+func toVersion(id any) (int64, error) {
+	switch v := id.(type) {
+	case int64:
+		return v, nil
+	case int32:
+		return int64(v), nil
+	case int:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("unexpected version _id %v (%T)", id, id)
+}
+
+// Insert records v with the time it was applied, taken from the local
+// clock.
+func (s *MongoStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	err := s.db.Collection(s.collection("schema_migrations")).InsertOne(ctx, Doc{"_id": v, "applied_at": time.Now().UTC()})
+	if err != nil && isDuplicateKey(err) {
+		return fmt.Errorf("version %d already applied", v)
+	}
+	return err
+}
+
+func (s *MongoStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.db.Collection(s.collection("schema_migrations")).DeleteOne(ctx, Doc{"_id": v})
+	return err
+}
+
+// Migration returns a migration whose up and down run against the store's
+// target, which must be a T, e.g. a *mongo.Database. down may be nil for a
+// migration that cannot be reverted.
+func Migration[T any](version int64, name string, up, down func(context.Context, T) error) *golumn.Migration {
+	return &golumn.Migration{
+		Version:    version,
+		Name:       name,
+		UpTarget:   targetFunc(version, up),
+		DownTarget: targetFunc(version, down),
+	}
+}
+
+func targetFunc[T any](version int64, fn func(context.Context, T) error) func(context.Context, any) error {
+	if fn == nil {
+		return nil
+	}
+	return func(ctx context.Context, target any) error {
+		t, ok := target.(T)
+		if !ok {
+			return fmt.Errorf("migration %d: target is %T, not %s", version, target, reflect.TypeFor[T]())
+		}
+		return fn(ctx, t)
+	}
+}
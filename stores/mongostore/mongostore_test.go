@@ -0,0 +1,308 @@
+package mongostore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/mongostore"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// fakeDB is an in-memory database supporting the filters and updates the
+// store issues: equality and $ne filters, and $set and $setOnInsert
+// updates.
+type fakeDB struct {
+	mu          sync.Mutex
+	collections map[string][]mongostore.Doc
+	// ran records the migrations run against the database as a target.
+	ran []string
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{collections: make(map[string][]mongostore.Doc)}
+}
+
+func (db *fakeDB) Collection(name string) mongostore.Collection {
+	return &fakeCollection{db, name}
+}
+
+type fakeCollection struct {
+	db   *fakeDB
+	name string
+}
+
+func matches(doc, filter mongostore.Doc) bool {
+	for k, want := range filter {
+		if op, ok := want.(mongostore.Doc); ok {
+			if ne, ok := op["$ne"]; ok && doc[k] == ne {
+				return false
+			}
+			continue
+		}
+		if doc[k] != want {
+			return false
+		}
+	}
+	return true
+}
+
+// serverError reports a code the way the official driver's server errors
+// do.
+type serverError struct {
+	code int
+	msg  string
+}
+
+func (e serverError) Error() string              { return e.msg }
+func (e serverError) HasErrorCode(code int) bool { return code == e.code }
+
+// codedError reports a code through a Code method.
+type codedError int
+
+func (e codedError) Error() string { return fmt.Sprintf("error %d", int(e)) }
+func (e codedError) Code() int     { return int(e) }
+
+// failingDB fails every insert into its collections with err.
+type failingDB struct {
+	*fakeDB
+	err error
+}
+
+func (db failingDB) Collection(name string) mongostore.Collection {
+	return failingCollection{db.fakeDB.Collection(name), db.err}
+}
+
+type failingCollection struct {
+	mongostore.Collection
+	err error
+}
+
+func (c failingCollection) InsertOne(context.Context, mongostore.Doc) error { return c.err }
+
+func (c *fakeCollection) insert(doc mongostore.Doc) error {
+	for _, d := range c.db.collections[c.name] {
+		if d["_id"] == doc["_id"] {
+			return serverError{11000, "E11000 duplicate key error collection: " + c.name}
+		}
+	}
+	c.db.collections[c.name] = append(c.db.collections[c.name], doc)
+	return nil
+}
+
+func (c *fakeCollection) InsertOne(ctx context.Context, doc mongostore.Doc) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	return c.insert(doc)
+}
+
+func (c *fakeCollection) DeleteOne(ctx context.Context, filter mongostore.Doc) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	docs := c.db.collections[c.name]
+	if i := slices.IndexFunc(docs, func(d mongostore.Doc) bool { return matches(d, filter) }); i >= 0 {
+		c.db.collections[c.name] = slices.Delete(docs, i, i+1)
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (c *fakeCollection) FindOneAndUpdate(ctx context.Context, filter, update mongostore.Doc, upsert bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	set, _ := update["$set"].(mongostore.Doc)
+	for _, doc := range c.db.collections[c.name] {
+		if matches(doc, filter) {
+			for k, v := range set {
+				doc[k] = v
+			}
+			return true, nil
+		}
+	}
+	if !upsert {
+		return false, nil
+	}
+	doc := mongostore.Doc{}
+	for k, v := range filter {
+		if _, ok := v.(mongostore.Doc); !ok {
+			doc[k] = v
+		}
+	}
+	onInsert, _ := update["$setOnInsert"].(mongostore.Doc)
+	for _, fields := range []mongostore.Doc{onInsert, set} {
+		for k, v := range fields {
+			doc[k] = v
+		}
+	}
+	return false, c.insert(doc)
+}
+
+func (c *fakeCollection) Find(ctx context.Context, filter mongostore.Doc) ([]mongostore.Doc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	var docs []mongostore.Doc
+	for _, doc := range c.db.collections[c.name] {
+		if matches(doc, filter) {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+func TestMongoStore_Conformance(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		db := newFakeDB()
+		return mongostore.New(db, db)
+	})
+}
+
+func TestMongoStore_Lock(t *testing.T) {
+	db := newFakeDB()
+	a, b := mongostore.New(db, db), mongostore.New(db, db)
+	ctx := context.Background()
+	for _, s := range []*mongostore.MongoStore{a, b} {
+		if err := s.Init(ctx); err != nil {
+			t.Fatalf("init failed: %v", err)
+		}
+	}
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := b.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked from another store, got %v", err)
+	}
+	b.ReleasePolicy = golumn.ReleaseErrorUnheld
+	if err := b.Release(ctx); !errors.Is(err, golumn.ErrNotLocked) {
+		t.Errorf("expected another store's release to leave the lock, got %v", err)
+	}
+
+	other := mongostore.New(db, db)
+	other.Lineage = "anonymize"
+	if err := other.Init(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if err := other.Lock(ctx); err != nil {
+		t.Errorf("expected a lineage to have a lock of its own, got %v", err)
+	}
+	if !slices.Equal(other.Tables(), []string{"schema_lock_anonymize", "schema_migrations_anonymize"}) {
+		t.Errorf("unexpected lineage collections %v", other.Tables())
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := b.Lock(ctx); err != nil {
+		t.Errorf("lock after release failed: %v", err)
+	}
+}
+
+func TestMongoStore_DuplicateKey(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		err       error
+		duplicate bool
+	}{
+		{"server error", serverError{11000, "E11000 duplicate key error"}, true},
+		{"other server error", serverError{11600, "interrupted at shutdown"}, false},
+		{"wrapped code", fmt.Errorf("insert: %w", codedError(11000)), true},
+		{"other code", codedError(50), false},
+		{"message", errors.New("E11000 duplicate key error"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := failingDB{newFakeDB(), tt.err}
+			err := mongostore.New(db, db).Insert(context.Background(), 1)
+			if duplicate := err != nil && strings.Contains(err.Error(), "already applied"); duplicate != tt.duplicate {
+				t.Errorf("expected a duplicate key to be %v, got %v", tt.duplicate, err)
+			}
+		})
+	}
+}
+
+func TestMongoStore_Migrations(t *testing.T) {
+	db := newFakeDB()
+	record := func(step string) func(context.Context, *fakeDB) error {
+		return func(_ context.Context, db *fakeDB) error {
+			db.ran = append(db.ran, step)
+			return nil
+		}
+	}
+	migrator := &golumn.Migrator{
+		Store: mongostore.New(db, db),
+		Sources: []*golumn.Migration{
+			mongostore.Migration(1, "users", record("up 1"), record("down 1")),
+			mongostore.Migration(2, "users_email", record("up 2"), record("down 2")),
+		},
+	}
+	ctx := context.Background()
+
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if _, err := migrator.Down(ctx, 1); err != nil {
+		t.Fatalf("down failed: %v", err)
+	}
+	if want := []string{"up 1", "up 2", "down 2"}; !slices.Equal(db.ran, want) {
+		t.Errorf("expected %v to run, got %v", want, db.ran)
+	}
+	if v, err := migrator.Store.Version(ctx); err != nil || v != 1 {
+		t.Errorf("expected version 1, got %d (err %v)", v, err)
+	}
+
+	migrator = &golumn.Migrator{
+		Store:   mongostore.New(db, "not a database"),
+		Sources: []*golumn.Migration{mongostore.Migration(3, "", record("up 3"), nil)},
+	}
+	if _, err := migrator.Up(ctx, golumn.Latest); err == nil || !strings.Contains(err.Error(), "target is string") {
+		t.Errorf("expected a mismatched target to fail, got %v", err)
+	}
+}
+
+func TestMongoStore_LockHolder(t *testing.T) {
+	db := newFakeDB()
+	a, b := mongostore.New(db, db), mongostore.New(db, db)
+	ctx := context.Background()
+	if err := a.Init(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if holder, err := b.LockHolder(ctx); err != nil || holder != nil {
+		t.Fatalf("expected no holder of a free lock, got %+v (err %v)", holder, err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	holder, err := b.LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if holder == nil || holder.Owner == "" || holder.Since.Before(before) || holder.Since.After(time.Now()) {
+		t.Errorf("unexpected holder: %+v", holder)
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if holder, err := b.LockHolder(ctx); err != nil || holder != nil {
+		t.Errorf("expected no holder after release, got %+v (err %v)", holder, err)
+	}
+}
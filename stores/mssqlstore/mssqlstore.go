@@ -0,0 +1,282 @@
+// Package mssqlstore provides a golumn.Store for SQL Server. It issues
+// plain SQL through database/sql with @p1-style parameters and does not
+// import a driver; github.com/microsoft/go-mssqldb works.
+//
+// The store lock is a session-owned application lock taken with
+// sp_getapplock rather than a row in a lock table, so a migrator that
+// crashes or loses its connection releases it with its session instead of
+// leaving the store locked until ForceUnlock.
+package mssqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil"
+)
+
+// DefaultLockName is the application lock resource used unless
+// MSSQLStore.LockName is set.
+const DefaultLockName = "golumn"
+
+type MSSQLStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Production flags the store as production, requiring approved runs.
+	Production bool
+	// LockName is the application lock resource, which migrators sharing a
+	// database but not a schema_migrations table must not share. Empty
+	// means DefaultLockName, suffixed with Lineage if that is set.
+	LockName string
+	// Lineage names the store's migration set, see golumn.LineageTable.
+	// It suffixes the schema_migrations table and the default lock name.
+	Lineage string
+
+	instance *sql.DB
+	mu       sync.Mutex
+	// session tracks the connection whose session holds the application
+	// lock.
+	session sqlutil.SessionConn
+}
+
+var (
+	_ golumn.Store           = (*MSSQLStore)(nil)
+	_ golumn.ForceUnlocker   = (*MSSQLStore)(nil)
+	_ golumn.ProductionStore = (*MSSQLStore)(nil)
+	_ golumn.ConnReserver    = (*MSSQLStore)(nil)
+	_ golumn.LockInspector   = (*MSSQLStore)(nil)
+	_ golumn.VersionLister   = (*MSSQLStore)(nil)
+	_ golumn.TableLister     = (*MSSQLStore)(nil)
+	_ golumn.IdentityStore   = (*MSSQLStore)(nil)
+)
+
+func New(db *sql.DB) *MSSQLStore {
+	return &MSSQLStore{instance: db}
+}
+
+func (s *MSSQLStore) DB() *sql.DB {
+	return s.instance
+}
+
+func (s *MSSQLStore) IsProduction() bool {
+	return s.Production
+}
+
+// ReserveConn reserves the connection Lock takes the application lock on,
+// see golumn.ConnReserver.
+func (s *MSSQLStore) ReserveConn(ctx context.Context) (func() error, error) {
+	return s.session.Reserve(ctx, s.instance)
+}
+
+func (s *MSSQLStore) lockName() string {
+	switch {
+	case s.LockName != "":
+		return s.LockName
+	case s.Lineage != "":
+		return DefaultLockName + "_" + s.Lineage
+	}
+	return DefaultLockName
+}
+
+// table names one of the store's tables for its lineage.
+func (s *MSSQLStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables, which schema_identity, shared by all
+// lineages, completes.
+func (s *MSSQLStore) Tables() []string {
+	return []string{s.table("schema_migrations"), "schema_identity"}
+}
+
+// Init creates the schema_migrations table. SQL Server has no CREATE TABLE
+// IF NOT EXISTS, and concurrent existence checks can race, so Init
+// serializes on a transaction-owned application lock for the length of its
+// transaction.
+func (s *MSSQLStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		var status int
+		if err := tx.QueryRowContext(tCtx, "DECLARE @status int; EXEC @status = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Transaction'; SELECT @status", s.lockName()+".init").Scan(&status); err != nil {
+			return err
+		}
+		if status < 0 {
+			return fmt.Errorf("sp_getapplock failed with status %d", status)
+		}
+		if _, err := tx.ExecContext(tCtx, "IF OBJECT_ID(N'"+s.table("schema_migrations")+"', N'U') IS NULL CREATE TABLE "+s.table("schema_migrations")+" (id BIGINT IDENTITY(1,1) PRIMARY KEY, version_id BIGINT NOT NULL UNIQUE, applied_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME())"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "IF OBJECT_ID(N'schema_identity', N'U') IS NULL CREATE TABLE schema_identity (id INT PRIMARY KEY CHECK (id = 1), [identity] CHAR(36) NOT NULL, created_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME())")
+		return err
+	})
+}
+
+// Identity returns the database's identity, generating it on first use.
+// The insert holds a key-range lock while it checks for an existing row, so
+// concurrent first calls agree. Stores of every lineage share it.
+func (s *MSSQLStore) Identity(ctx context.Context) (string, error) {
+	if _, err := s.instance.ExecContext(ctx, "INSERT INTO schema_identity (id, [identity]) SELECT 1, @p1 WHERE NOT EXISTS (SELECT 1 FROM schema_identity WITH (UPDLOCK, HOLDLOCK) WHERE id = 1)", golumn.NewIdentity()); err != nil {
+		return "", err
+	}
+	var id string
+	err := s.instance.QueryRowContext(ctx, "SELECT [identity] FROM schema_identity WHERE id = 1").Scan(&id)
+	return id, err
+}
+
+func (s *MSSQLStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.session.Held() != nil {
+		return golumn.ErrLocked
+	}
+
+	conn, err := s.session.Get(ctx, s.instance)
+	if err != nil {
+		return err
+	}
+	// sp_getapplock returns 0 or 1 once granted, -1 when the timeout of 0
+	// passes with the lock held elsewhere, and other negative statuses on
+	// errors.
+	var status int
+	if err := conn.QueryRowContext(ctx, "DECLARE @status int; EXEC @status = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 0; SELECT @status", s.lockName()).Scan(&status); err != nil {
+		return s.session.Put(conn, err)
+	}
+	switch {
+	case status == -1:
+		return errors.Join(golumn.ErrLocked, s.session.Put(conn, nil))
+	case status < 0:
+		return errors.Join(fmt.Errorf("sp_getapplock failed with status %d", status), s.session.Put(conn, nil))
+	}
+	s.session.Hold(conn)
+	s.Log.Debugf("mssqlstore: acquired application lock %q", s.lockName())
+	return nil
+}
+
+func (s *MSSQLStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn := s.session.Held(); conn != nil {
+		s.session.Hold(nil)
+
+		// sp_releaseapplock returns 0 once released and -999 if the
+		// session no longer held the lock.
+		var status int
+		if err := conn.QueryRowContext(ctx, "DECLARE @status int; EXEC @status = sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'; SELECT @status", s.lockName()).Scan(&status); err != nil {
+			return s.session.Put(conn, err)
+		}
+		if err := s.session.Put(conn, nil); err != nil {
+			return err
+		}
+		if status == 0 {
+			s.Log.Debugf("mssqlstore: released application lock %q", s.lockName())
+			return nil
+		}
+		s.Log.Debugf("mssqlstore: application lock %q no longer held", s.lockName())
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+// ForceUnlock releases the application lock wherever it is held, killing
+// the session of any other process holding it.
+func (s *MSSQLStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn := s.session.Held(); conn != nil {
+		if err := s.session.End(conn); err != nil {
+			return err
+		}
+	}
+
+	rows, err := s.instance.QueryContext(ctx, "SELECT DISTINCT request_session_id FROM sys.dm_tran_locks WHERE resource_type = 'APPLICATION' AND request_owner_type = 'SESSION' AND resource_database_id = DB_ID() AND CHARINDEX(':[' + @p1 + ']:', resource_description) > 0 AND request_session_id <> @@SPID", s.lockName())
+	if err != nil {
+		return err
+	}
+	var sessions []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		sessions = append(sessions, id)
+	}
+	if err := errors.Join(rows.Err(), rows.Close()); err != nil {
+		return err
+	}
+	for _, id := range sessions {
+		// KILL takes no parameters; id is a scanned integer.
+		if _, err := s.instance.ExecContext(ctx, fmt.Sprintf("KILL %d", id)); err != nil {
+			return err
+		}
+	}
+	s.Log.Infof("mssqlstore: forcibly released application lock %q", s.lockName())
+	return nil
+}
+
+// Locked reports whether another session holds the application lock.
+// APPLOCK_TEST reports 1 if the lock could be granted.
+func (s *MSSQLStore) Locked(ctx context.Context) (bool, error) {
+	var grantable int
+	err := s.instance.QueryRowContext(ctx, "SELECT APPLOCK_TEST('public', @p1, 'Exclusive', 'Session')", s.lockName()).Scan(&grantable)
+	return grantable == 0, err
+}
+
+func (s *MSSQLStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, "SELECT TOP 1 version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id DESC")
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *MSSQLStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *MSSQLStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id) VALUES (@p1)", v)
+	return err
+}
+
+func (s *MSSQLStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = @p1", v)
+	return err
+}
@@ -0,0 +1,213 @@
+package mssqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/mssqlstore"
+)
+
+// fakeServer stands in for SQL Server's session-owned application locks: a
+// lock held by at most one connection and released when it closes.
+type fakeServer struct {
+	sqltest.Server
+	holders    map[string]*sqltest.Conn
+	getStatus  int
+	releaseErr error
+	created    int
+	initLocks  int
+	// writes records, for each INSERT INTO t, whether its connection ran
+	// the SET statement and whether it held an application lock.
+	writes   []fakeWrite
+	identity string
+}
+
+type fakeWrite struct{ configured, holder bool }
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{holders: make(map[string]*sqltest.Conn)}
+}
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query, srv.Close = srv.exec, srv.query, srv.closed
+	srv.Begin = func(*sqltest.Conn) error { return nil }
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (srv *fakeServer) closed(c *sqltest.Conn) {
+	for name, holder := range srv.holders {
+		if holder == c {
+			delete(srv.holders, name)
+		}
+	}
+}
+
+func (srv *fakeServer) exec(c *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.Contains(query, "CREATE TABLE"):
+		srv.created++
+	case strings.HasPrefix(query, "SET "):
+		c.Configured = true
+	case strings.HasPrefix(query, "INSERT INTO schema_identity") && srv.identity == "":
+		srv.identity = args[0].(string)
+	case strings.HasPrefix(query, "INSERT INTO t "):
+		holder := false
+		for _, h := range srv.holders {
+			holder = holder || h == c
+		}
+		srv.writes = append(srv.writes, fakeWrite{c.Configured, holder})
+	}
+	return nil, nil
+}
+
+func (srv *fakeServer) query(c *sqltest.Conn, query string, args []driver.Value) (driver.Rows, error) {
+	if strings.Contains(query, "version_id FROM schema_migrations") {
+		return sqltest.Rows([]string{"version_id"}), nil
+	}
+	if strings.HasPrefix(query, "SELECT [identity] FROM schema_identity") {
+		return sqltest.Value(srv.identity), nil
+	}
+	name, _ := args[0].(string)
+	holder := srv.holders[name]
+	switch {
+	case strings.Contains(query, "@LockOwner = 'Transaction'"):
+		srv.initLocks++
+		return sqltest.Value(int64(0)), nil
+	case strings.Contains(query, "sp_getapplock"):
+		if srv.getStatus != 0 {
+			return sqltest.Value(int64(srv.getStatus)), nil
+		}
+		if holder != nil && holder != c {
+			return sqltest.Value(int64(-1)), nil
+		}
+		srv.holders[name] = c
+		return sqltest.Value(int64(0)), nil
+	case strings.Contains(query, "sp_releaseapplock"):
+		if srv.releaseErr != nil {
+			return nil, srv.releaseErr
+		}
+		if holder != c {
+			return sqltest.Value(int64(-999)), nil
+		}
+		delete(srv.holders, name)
+		return sqltest.Value(int64(0)), nil
+	case strings.HasPrefix(query, "SELECT APPLOCK_TEST"):
+		if holder != nil {
+			return sqltest.Value(int64(0)), nil
+		}
+		return sqltest.Value(int64(1)), nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func TestMSSQLStore_ConnConfig(t *testing.T) {
+	srv := newFakeServer()
+	db := openFake(t, srv)
+	migrator := &golumn.Migrator{
+		Store: mssqlstore.New(db),
+		Sources: []*golumn.Migration{{
+			Version: 1,
+			UpFunc: func(ctx context.Context, db *sql.DB) error {
+				_, err := db.ExecContext(ctx, "INSERT INTO t (id) VALUES (1)")
+				return err
+			},
+		}},
+		Conn: &golumn.ConnConfig{
+			MaxOpenConns: 1,
+			Setup:        []string{"SET LOCK_TIMEOUT 5000"},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("expected up to run beside the application lock's connection, got %v", err)
+	}
+	if want := []fakeWrite{{configured: true}}; !slices.Equal(srv.writes, want) {
+		t.Errorf("expected the migration on the configured connection, not the lock's, got %+v", srv.writes)
+	}
+	if len(srv.holders) != 0 {
+		t.Error("expected the lock to be released")
+	}
+}
+
+func TestMSSQLStore_AppLock(t *testing.T) {
+	srv := newFakeServer()
+	a, b := mssqlstore.New(openFake(t, srv)), mssqlstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := a.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked relocking, got %v", err)
+	}
+	if err := b.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked from another store, got %v", err)
+	}
+	if locked, err := b.Locked(ctx); err != nil || !locked {
+		t.Errorf("Locked() = %v, %v, want true", locked, err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := b.Lock(ctx); err != nil {
+		t.Fatalf("lock after release failed: %v", err)
+	}
+
+	// A failed release ends the session, and the lock with it.
+	srv.releaseErr = errors.New("connection reset")
+	if err := b.Release(ctx); err == nil {
+		t.Error("expected the failed release to be reported")
+	}
+	srv.releaseErr = nil
+	if locked, err := a.Locked(ctx); err != nil || locked {
+		t.Errorf("Locked() = %v, %v, want false", locked, err)
+	}
+
+	a.ReleasePolicy = golumn.ReleaseErrorUnheld
+	if err := a.Release(ctx); !errors.Is(err, golumn.ErrNotLocked) {
+		t.Errorf("expected ErrNotLocked, got %v", err)
+	}
+
+	srv.getStatus = -3
+	if err := a.Lock(ctx); err == nil || errors.Is(err, golumn.ErrLocked) || !strings.Contains(err.Error(), "status -3") {
+		t.Errorf("expected a deadlock status to be reported as an error, got %v", err)
+	}
+}
+
+func TestMSSQLStore_Init(t *testing.T) {
+	srv := newFakeServer()
+	store := mssqlstore.New(openFake(t, srv))
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if srv.initLocks != 1 || srv.created != 2 {
+		t.Errorf("expected the tables to be created under the init lock, got %d locks and %d creates", srv.initLocks, srv.created)
+	}
+
+	store.Lineage = "1bad"
+	if err := store.Init(context.Background()); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+}
+
+func TestMSSQLStore_Identity(t *testing.T) {
+	srv := newFakeServer()
+	a, b := mssqlstore.New(openFake(t, srv)), mssqlstore.New(openFake(t, srv))
+	ctx := context.Background()
+	id, err := a.Identity(ctx)
+	if err != nil || id == "" {
+		t.Fatalf("expected an identity, got %q (err %v)", id, err)
+	}
+	if other, err := b.Identity(ctx); err != nil || other != id {
+		t.Errorf("expected another store on the database to report %q, got %q (err %v)", id, other, err)
+	}
+}
@@ -0,0 +1,385 @@
+// Package mysqlstore provides a golumn.Store for MySQL and MariaDB. It
+// issues plain SQL through database/sql and does not import a driver; any
+// MySQL driver, such as github.com/go-sql-driver/mysql, works.
+//
+// The store lock is a named lock taken with GET_LOCK rather than a row in a
+// lock table, so a migrator that crashes or loses its connection releases
+// it with its session instead of leaving the store locked until
+// ForceUnlock. TiDB before 5.4 lacks GET_LOCK; use tidbstore there.
+//
+// The store does not implement golumn.SharedLocker. Named locks have no
+// shared mode. A shared mode built from a table of readers would outlive a
+// crashed reader, so it would keep migrators out until ForceUnlock, which is
+// what the named lock avoids.
+package mysqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil"
+)
+
+// DefaultLockName is the GET_LOCK name used unless MySQLStore.LockName is
+// set.
+const DefaultLockName = "golumn"
+
+type MySQLStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Production flags the store as production, requiring approved runs.
+	Production bool
+	// LockName is the GET_LOCK name, which migrators sharing a server but
+	// not a schema_migrations table must not share. Lock names are
+	// server-wide, not per database. Empty means DefaultLockName, suffixed
+	// with Lineage if that is set.
+	LockName string
+	// Lineage names the store's migration set, see golumn.LineageTable.
+	// It suffixes the schema_migrations table and the default lock name.
+	Lineage string
+
+	instance *sql.DB
+	mu       sync.Mutex
+	// session tracks the connection whose session holds the named lock.
+	session sqlutil.SessionConn
+}
+
+var (
+	_ golumn.Store               = (*MySQLStore)(nil)
+	_ golumn.ForceUnlocker       = (*MySQLStore)(nil)
+	_ golumn.ProductionStore     = (*MySQLStore)(nil)
+	_ golumn.ConnReserver        = (*MySQLStore)(nil)
+	_ golumn.LockInspector       = (*MySQLStore)(nil)
+	_ golumn.VersionLister       = (*MySQLStore)(nil)
+	_ golumn.TableLister         = (*MySQLStore)(nil)
+	_ golumn.IdentityStore       = (*MySQLStore)(nil)
+	_ golumn.Annotator           = (*MySQLStore)(nil)
+	_ golumn.Explainer           = (*MySQLStore)(nil)
+	_ golumn.LockHolderInspector = (*MySQLStore)(nil)
+)
+
+func New(db *sql.DB) *MySQLStore {
+	return &MySQLStore{instance: db}
+}
+
+func (s *MySQLStore) DB() *sql.DB {
+	return s.instance
+}
+
+func (s *MySQLStore) IsProduction() bool {
+	return s.Production
+}
+
+// ReserveConn reserves the connection Lock takes the named lock on, see
+// golumn.ConnReserver.
+func (s *MySQLStore) ReserveConn(ctx context.Context) (func() error, error) {
+	return s.session.Reserve(ctx, s.instance)
+}
+
+func (s *MySQLStore) lockName() string {
+	switch {
+	case s.LockName != "":
+		return s.LockName
+	case s.Lineage != "":
+		return DefaultLockName + "_" + s.Lineage
+	}
+	return DefaultLockName
+}
+
+// table names one of the store's tables for its lineage.
+func (s *MySQLStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables, which schema_identity, shared by all
+// lineages, completes.
+func (s *MySQLStore) Tables() []string {
+	return []string{s.table("schema_migrations"), s.table("schema_annotations"), "schema_identity"}
+}
+
+// Init creates the schema_migrations and schema_annotations tables. MySQL
+// commits DDL implicitly, so it runs outside a transaction. KEY is reserved
+// in MySQL, so the annotation key column is quoted.
+func (s *MySQLStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (id BIGINT AUTO_INCREMENT PRIMARY KEY, version_id BIGINT NOT NULL UNIQUE, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_annotations") + " (version_id BIGINT NOT NULL, `key` VARCHAR(255) NOT NULL, value TEXT NOT NULL, PRIMARY KEY (version_id, `key`))",
+		"CREATE TABLE IF NOT EXISTS schema_identity (id INT PRIMARY KEY, identity CHAR(36) NOT NULL, created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+	} {
+		if _, err := s.instance.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Identity returns the database's identity, generating it on first use. The
+// insert leaves an existing row alone, so concurrent first calls agree.
+// Stores of every lineage share it.
+func (s *MySQLStore) Identity(ctx context.Context) (string, error) {
+	if _, err := s.instance.ExecContext(ctx, "INSERT INTO schema_identity (id, identity) VALUES (1, ?) ON DUPLICATE KEY UPDATE id = id", golumn.NewIdentity()); err != nil {
+		return "", err
+	}
+	var id string
+	err := s.instance.QueryRowContext(ctx, "SELECT identity FROM schema_identity WHERE id = 1").Scan(&id)
+	return id, err
+}
+
+func (s *MySQLStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.session.Held() != nil {
+		return golumn.ErrLocked
+	}
+
+	conn, err := s.session.Get(ctx, s.instance)
+	if err != nil {
+		return err
+	}
+	// GET_LOCK returns 1 once acquired, 0 when the timeout of 0 seconds
+	// passes with the lock held elsewhere, and NULL on errors.
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", s.lockName()).Scan(&acquired); err != nil {
+		return s.session.Put(conn, err)
+	}
+	if !acquired.Valid {
+		return errors.Join(fmt.Errorf("GET_LOCK(%q) failed", s.lockName()), s.session.Put(conn, nil))
+	}
+	if acquired.Int64 != 1 {
+		return errors.Join(golumn.ErrLocked, s.session.Put(conn, nil))
+	}
+	s.session.Hold(conn)
+	s.Log.Debugf("mysqlstore: acquired lock %q", s.lockName())
+	return nil
+}
+
+func (s *MySQLStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn := s.session.Held(); conn != nil {
+		s.session.Hold(nil)
+
+		// RELEASE_LOCK returns 1 once released, and 0 or NULL if the
+		// session no longer held the lock.
+		var released sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", s.lockName()).Scan(&released); err != nil {
+			return s.session.Put(conn, err)
+		}
+		if err := s.session.Put(conn, nil); err != nil {
+			return err
+		}
+		if released.Int64 == 1 {
+			s.Log.Debugf("mysqlstore: released lock %q", s.lockName())
+			return nil
+		}
+		s.Log.Debugf("mysqlstore: lock %q no longer held", s.lockName())
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+// ForceUnlock releases the named lock wherever it is held, killing the
+// connection of any other process holding it.
+func (s *MySQLStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn := s.session.Held(); conn != nil {
+		if err := s.session.End(conn); err != nil {
+			return err
+		}
+	}
+
+	var holder sql.NullInt64
+	if err := s.instance.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", s.lockName()).Scan(&holder); err != nil {
+		return err
+	}
+	if holder.Valid {
+		// KILL takes no placeholders; holder is a scanned integer.
+		if _, err := s.instance.ExecContext(ctx, fmt.Sprintf("KILL %d", holder.Int64)); err != nil {
+			return err
+		}
+	}
+	s.Log.Infof("mysqlstore: forcibly released lock %q", s.lockName())
+	return nil
+}
+
+func (s *MySQLStore) Locked(ctx context.Context) (bool, error) {
+	var holder sql.NullInt64
+	err := s.instance.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", s.lockName()).Scan(&holder)
+	return holder.Valid, err
+}
+
+// LockHolder returns the connection holding the named lock, with its user
+// and host from the process list. Since is when the connection ran its
+// last statement, which for a migrator's lock connection is GET_LOCK. A
+// user without the PROCESS privilege cannot see other users' connections,
+// so for those only the connection ID is reported.
+func (s *MySQLStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	var id sql.NullInt64
+	var user, host sql.NullString
+	var idle sql.NullInt64
+	err := s.instance.QueryRowContext(ctx, "SELECT l.id, p.USER, p.HOST, p.TIME FROM (SELECT IS_USED_LOCK(?) AS id) l LEFT JOIN information_schema.PROCESSLIST p ON p.ID = l.id", s.lockName()).Scan(&id, &user, &host, &idle)
+	if err != nil {
+		return nil, err
+	}
+	if !id.Valid {
+		return nil, nil
+	}
+	if !user.Valid {
+		return &golumn.LockHolder{Owner: fmt.Sprintf("connection %d", id.Int64)}, nil
+	}
+	return &golumn.LockHolder{
+		Owner: fmt.Sprintf("connection %d (%s@%s)", id.Int64, user.String, host.String),
+		Since: time.Now().Add(-time.Duration(idle.Int64) * time.Second),
+	}, nil
+}
+
+// Explain returns the EXPLAIN output of a DML statement, one row per line
+// listing its non-NULL columns, e.g. "id=1 select_type=SIMPLE table=t
+// type=ALL rows=1000", where type=ALL marks a full table scan. The columns
+// differ between MySQL and MariaDB versions. Other statements return
+// errors.ErrUnsupported.
+func (s *MySQLStore) Explain(ctx context.Context, stmt string) (string, error) {
+	if !sqlutil.IsDML(stmt) {
+		return "", errors.ErrUnsupported
+	}
+	rows, err := s.instance.QueryContext(ctx, "EXPLAIN "+stmt)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	var lines []string
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return "", err
+		}
+		var fields []string
+		for i, v := range values {
+			if v.Valid {
+				fields = append(fields, columns[i]+"="+v.String)
+			}
+		}
+		lines = append(lines, strings.Join(fields, " "))
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (s *MySQLStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id DESC LIMIT 1")
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *MySQLStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *MySQLStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id) VALUES (?)", v)
+	return err
+}
+
+func (s *MySQLStore) Remove(ctx context.Context, v int64) error {
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = ?", v); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_annotations")+" WHERE version_id = ?", v)
+		return err
+	})
+}
+
+func (s *MySQLStore) Annotate(ctx context.Context, v int64, key, value string) error {
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		var applied int
+		if err := tx.QueryRowContext(tCtx, "SELECT COUNT(*) FROM "+s.table("schema_migrations")+" WHERE version_id = ?", v).Scan(&applied); err != nil {
+			return err
+		}
+		if applied == 0 {
+			return golumn.ErrNotApplied
+		}
+		if value == "" {
+			_, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_annotations")+" WHERE version_id = ? AND `key` = ?", v, key)
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_annotations")+" (version_id, `key`, value) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE value = VALUES(value)", v, key, value)
+		return err
+	})
+}
+
+func (s *MySQLStore) Annotations(ctx context.Context) (map[int64]map[string]string, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id, `key`, value FROM "+s.table("schema_annotations"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := map[int64]map[string]string{}
+	for rows.Next() {
+		var v int64
+		var key, value string
+		if err := rows.Scan(&v, &key, &value); err != nil {
+			return nil, err
+		}
+		if notes[v] == nil {
+			notes[v] = map[string]string{}
+		}
+		notes[v][key] = value
+	}
+	return notes, rows.Err()
+}
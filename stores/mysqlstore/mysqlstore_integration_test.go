@@ -0,0 +1,36 @@
+//go:build integration
+
+package mysqlstore_test
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/mysqlstore"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestMySQLStore_Conformance runs against the database at $GOLUMN_MYSQL_DSN
+// through go-sql-driver/mysql, or the driver named by $GOLUMN_MYSQL_DRIVER
+// if it is set. A local container works:
+//
+//	docker run -d -e MYSQL_ROOT_PASSWORD=golumn -e MYSQL_DATABASE=golumn -p 3306:3306 mysql:8
+//	GOLUMN_MYSQL_DSN='root:golumn@tcp(localhost:3306)/golumn' go test -tags integration ./stores/mysqlstore
+func TestMySQLStore_Conformance(t *testing.T) {
+	name, dsn := sqltest.Env(t, "MYSQL", "mysql")
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if _, err := db.Exec("DROP TABLE IF EXISTS schema_migrations"); err != nil {
+			t.Fatal(err)
+		}
+		return mysqlstore.New(db)
+	})
+}
@@ -0,0 +1,403 @@
+package mysqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/mysqlstore"
+)
+
+// fakeServer stands in for MySQL's named locks: a lock held by at most one
+// connection and released when it closes.
+type fakeServer struct {
+	sqltest.Server
+	holders    map[string]*sqltest.Conn
+	killed     []int64
+	releaseErr error
+	// writes records, for each INSERT INTO t, whether its connection ran
+	// the SET statement and whether it held a named lock.
+	writes   []fakeWrite
+	identity string
+	// versions and annotations hold the rows of schema_migrations and
+	// schema_annotations.
+	versions    []int64
+	annotations map[int64]map[string]string
+	// explained records the EXPLAIN queries run.
+	explained []string
+	// hiddenProcesses hides other connections from the process list, as
+	// for a user without the PROCESS privilege.
+	hiddenProcesses bool
+}
+
+type fakeWrite struct{ configured, holder bool }
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{holders: make(map[string]*sqltest.Conn)}
+}
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query, srv.Close = srv.exec, srv.query, srv.closed
+	srv.Begin = func(*sqltest.Conn) error { return nil }
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (srv *fakeServer) closed(c *sqltest.Conn) {
+	for name, holder := range srv.holders {
+		if holder == c {
+			delete(srv.holders, name)
+		}
+	}
+}
+
+func (srv *fakeServer) exec(c *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	if arg, ok := strings.CutPrefix(query, "KILL "); ok {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		for name, holder := range srv.holders {
+			if holder.ID == id {
+				delete(srv.holders, name)
+			}
+		}
+		srv.killed = append(srv.killed, id)
+	}
+	switch {
+	case strings.HasPrefix(query, "SET SESSION"):
+		c.Configured = true
+	case strings.HasPrefix(query, "INSERT INTO schema_identity") && srv.identity == "":
+		srv.identity = args[0].(string)
+	case strings.HasPrefix(query, "INSERT INTO t "):
+		holder := false
+		for _, h := range srv.holders {
+			holder = holder || h == c
+		}
+		srv.writes = append(srv.writes, fakeWrite{c.Configured, holder})
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		srv.versions = append(srv.versions, args[0].(int64))
+		slices.Sort(srv.versions)
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations WHERE"):
+		srv.versions = slices.DeleteFunc(srv.versions, func(v int64) bool { return v == args[0].(int64) })
+	case strings.HasPrefix(query, "DELETE FROM schema_annotations WHERE version_id = ? AND"):
+		delete(srv.annotations[args[0].(int64)], args[1].(string))
+	case strings.HasPrefix(query, "DELETE FROM schema_annotations WHERE"):
+		delete(srv.annotations, args[0].(int64))
+	case strings.HasPrefix(query, "INSERT INTO schema_annotations"):
+		if srv.annotations == nil {
+			srv.annotations = map[int64]map[string]string{}
+		}
+		v := args[0].(int64)
+		if srv.annotations[v] == nil {
+			srv.annotations[v] = map[string]string{}
+		}
+		srv.annotations[v][args[1].(string)] = args[2].(string)
+	}
+	return nil, nil
+}
+
+func (srv *fakeServer) query(c *sqltest.Conn, query string, args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT version_id FROM schema_migrations ORDER BY version_id DESC LIMIT 1"):
+		if len(srv.versions) == 0 {
+			return sqltest.Rows([]string{"version_id"}), nil
+		}
+		return sqltest.Value(srv.versions[len(srv.versions)-1]), nil
+	case strings.HasPrefix(query, "SELECT version_id FROM schema_migrations"):
+		return sqltest.Values(srv.versions...), nil
+	case strings.HasPrefix(query, "SELECT COUNT(*) FROM schema_migrations"):
+		var n int64
+		if slices.Contains(srv.versions, args[0].(int64)) {
+			n = 1
+		}
+		return sqltest.Value(n), nil
+	case strings.HasPrefix(query, "EXPLAIN "):
+		srv.explained = append(srv.explained, query)
+		return sqltest.Rows([]string{"id", "select_type", "table", "type", "key", "rows", "Extra"},
+			[]driver.Value{int64(1), "SIMPLE", "t", "ALL", nil, int64(1000), "Using where"}), nil
+	case strings.HasPrefix(query, "SELECT version_id, `key`, value FROM schema_annotations"):
+		var rows [][]driver.Value
+		for _, v := range slices.Sorted(maps.Keys(srv.annotations)) {
+			for _, key := range slices.Sorted(maps.Keys(srv.annotations[v])) {
+				rows = append(rows, []driver.Value{v, key, srv.annotations[v][key]})
+			}
+		}
+		return sqltest.Rows([]string{"version_id", "key", "value"}, rows...), nil
+	}
+	if strings.HasPrefix(query, "SELECT identity FROM schema_identity") {
+		return sqltest.Value(srv.identity), nil
+	}
+	name, _ := args[0].(string)
+	holder := srv.holders[name]
+	switch {
+	case strings.HasPrefix(query, "SELECT GET_LOCK"):
+		if holder != nil && holder != c {
+			return sqltest.Value(int64(0)), nil
+		}
+		srv.holders[name] = c
+		return sqltest.Value(int64(1)), nil
+	case strings.HasPrefix(query, "SELECT RELEASE_LOCK"):
+		if srv.releaseErr != nil {
+			return nil, srv.releaseErr
+		}
+		if holder != c {
+			return sqltest.Value(int64(0)), nil
+		}
+		delete(srv.holders, name)
+		return sqltest.Value(int64(1)), nil
+	case strings.HasPrefix(query, "SELECT IS_USED_LOCK"):
+		if holder == nil {
+			return sqltest.Value(nil), nil
+		}
+		return sqltest.Value(holder.ID), nil
+	case strings.HasPrefix(query, "SELECT l.id, p.USER, p.HOST, p.TIME FROM (SELECT IS_USED_LOCK(?)"):
+		cols := []string{"id", "USER", "HOST", "TIME"}
+		switch {
+		case holder == nil:
+			return sqltest.Rows(cols, []driver.Value{nil, nil, nil, nil}), nil
+		case srv.hiddenProcesses:
+			return sqltest.Rows(cols, []driver.Value{holder.ID, nil, nil, nil}), nil
+		}
+		return sqltest.Rows(cols, []driver.Value{holder.ID, "migrator", "10.0.0.7:51234", int64(90)}), nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func TestMySQLStore_ConnConfig(t *testing.T) {
+	srv := newFakeServer()
+	db := openFake(t, srv)
+	migrator := &golumn.Migrator{
+		Store: mysqlstore.New(db),
+		Sources: []*golumn.Migration{{
+			Version: 1,
+			UpFunc: func(ctx context.Context, db *sql.DB) error {
+				_, err := db.ExecContext(ctx, "INSERT INTO t (id) VALUES (1)")
+				return err
+			},
+		}},
+		Conn: &golumn.ConnConfig{
+			MaxOpenConns: 1,
+			Setup:        []string{"SET SESSION sql_mode = 'ANSI'"},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("expected up to run beside the named lock's connection, got %v", err)
+	}
+	if want := []fakeWrite{{configured: true}}; !slices.Equal(srv.writes, want) {
+		t.Errorf("expected the migration on the configured connection, not the lock's, got %+v", srv.writes)
+	}
+	if len(srv.holders) != 0 {
+		t.Error("expected the lock to be released")
+	}
+}
+
+func TestMySQLStore_NamedLock(t *testing.T) {
+	srv := newFakeServer()
+	a, b := mysqlstore.New(openFake(t, srv)), mysqlstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := a.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked relocking, got %v", err)
+	}
+	if err := b.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked from another store, got %v", err)
+	}
+	if locked, err := b.Locked(ctx); err != nil || !locked {
+		t.Errorf("Locked() = %v, %v, want true", locked, err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if locked, err := b.Locked(ctx); err != nil || locked {
+		t.Errorf("Locked() = %v, %v, want false", locked, err)
+	}
+	if err := b.Lock(ctx); err != nil {
+		t.Fatalf("lock after release failed: %v", err)
+	}
+
+	// A failed release ends the session, and the lock with it.
+	srv.releaseErr = errors.New("connection reset")
+	if err := b.Release(ctx); err == nil {
+		t.Error("expected the failed release to be reported")
+	}
+	srv.releaseErr = nil
+	if err := a.Lock(ctx); err != nil {
+		t.Errorf("lock after the holder's session ended failed: %v", err)
+	}
+
+	a.ReleasePolicy = golumn.ReleaseErrorUnheld
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := a.Release(ctx); !errors.Is(err, golumn.ErrNotLocked) {
+		t.Errorf("expected ErrNotLocked, got %v", err)
+	}
+}
+
+func TestMySQLStore_ForceUnlock(t *testing.T) {
+	srv := newFakeServer()
+	a, b := mysqlstore.New(openFake(t, srv)), mysqlstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := b.ForceUnlock(ctx); err != nil {
+		t.Fatalf("force unlock failed: %v", err)
+	}
+	if len(srv.killed) != 1 {
+		t.Errorf("expected the holder's connection to be killed, got %v", srv.killed)
+	}
+	if err := b.Lock(ctx); err != nil {
+		t.Errorf("lock after force unlock failed: %v", err)
+	}
+}
+
+func TestMySQLStore_LockHolder(t *testing.T) {
+	srv := newFakeServer()
+	a, b := mysqlstore.New(openFake(t, srv)), mysqlstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if holder, err := b.LockHolder(ctx); err != nil || holder != nil {
+		t.Fatalf("expected no holder before Lock, got %v, %v", holder, err)
+	}
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	id := srv.holders[mysqlstore.DefaultLockName].ID
+
+	holder, err := b.LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("lock holder failed: %v", err)
+	}
+	if want := fmt.Sprintf("connection %d (migrator@10.0.0.7:51234)", id); holder == nil || holder.Owner != want {
+		t.Fatalf("expected holder %q, got %+v", want, holder)
+	}
+	if ago := time.Since(holder.Since); ago < 90*time.Second || ago > 2*time.Minute {
+		t.Errorf("expected the holder's idle time of 90s, got %v", ago)
+	}
+
+	srv.hiddenProcesses = true
+	holder, err = b.LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("lock holder failed: %v", err)
+	}
+	if want := fmt.Sprintf("connection %d", id); holder == nil || holder.Owner != want || !holder.Since.IsZero() {
+		t.Errorf("expected only the connection ID %q, got %+v", want, holder)
+	}
+}
+
+func TestMySQLStore_LockName(t *testing.T) {
+	srv := newFakeServer()
+	main, staging := mysqlstore.New(openFake(t, srv)), mysqlstore.New(openFake(t, srv))
+	staging.Lineage = "anonymize"
+	ctx := context.Background()
+
+	if err := main.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := staging.Lock(ctx); err != nil {
+		t.Fatalf("expected lineages to lock separately, got %v", err)
+	}
+	if _, ok := srv.holders[mysqlstore.DefaultLockName+"_anonymize"]; !ok {
+		t.Errorf("expected lock %q to be held, got %v", mysqlstore.DefaultLockName+"_anonymize", srv.holders)
+	}
+
+	staging.Lineage = "bad-lineage"
+	if err := staging.Init(ctx); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+}
+
+func TestMySQLStore_Identity(t *testing.T) {
+	srv := newFakeServer()
+	a, b := mysqlstore.New(openFake(t, srv)), mysqlstore.New(openFake(t, srv))
+	ctx := context.Background()
+	id, err := a.Identity(ctx)
+	if err != nil || id == "" {
+		t.Fatalf("expected an identity, got %q (err %v)", id, err)
+	}
+	if other, err := b.Identity(ctx); err != nil || other != id {
+		t.Errorf("expected another store on the database to report %q, got %q (err %v)", id, other, err)
+	}
+}
+
+func TestMySQLStore_Annotations(t *testing.T) {
+	store := mysqlstore.New(openFake(t, newFakeServer()))
+	ctx := context.Background()
+	if err := store.Annotate(ctx, 1, "note", "x"); !errors.Is(err, golumn.ErrNotApplied) {
+		t.Errorf("expected ErrNotApplied, got %v", err)
+	}
+
+	migrator := &golumn.Migrator{
+		Store:       store,
+		Sources:     []*golumn.Migration{{Version: 1, UpFunc: func(context.Context, *sql.DB) error { return nil }}},
+		RecordStats: true,
+	}
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := store.Annotate(ctx, 1, "note", "superseded by 2"); err != nil {
+		t.Fatalf("annotate failed: %v", err)
+	}
+	notes, err := store.Annotations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{golumn.AnnotationStatements: "0", golumn.AnnotationRowsAffected: "0", "note": "superseded by 2"}
+	if !maps.Equal(notes[1], want) {
+		t.Errorf("expected recorded stats and the note, got %v", notes)
+	}
+
+	if err := store.Annotate(ctx, 1, "note", ""); err != nil {
+		t.Fatalf("annotate failed: %v", err)
+	}
+	if notes, _ := store.Annotations(ctx); notes[1]["note"] != "" {
+		t.Errorf("expected the note deleted, got %v", notes)
+	}
+	if err := store.Remove(ctx, 1); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if notes, _ := store.Annotations(ctx); len(notes) != 0 {
+		t.Errorf("expected annotations removed with their version, got %v", notes)
+	}
+}
+
+func TestMySQLStore_Explain(t *testing.T) {
+	srv := newFakeServer()
+	store := mysqlstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	plan, err := store.Explain(ctx, "UPDATE t SET y = 2 WHERE x = 1")
+	if err != nil {
+		t.Fatalf("explain failed: %v", err)
+	}
+	if want := "id=1 select_type=SIMPLE table=t type=ALL rows=1000 Extra=Using where"; plan != want {
+		t.Errorf("expected plan %q, got %q", want, plan)
+	}
+	if want := []string{"EXPLAIN UPDATE t SET y = 2 WHERE x = 1"}; !slices.Equal(srv.explained, want) {
+		t.Errorf("expected %q, got %q", want, srv.explained)
+	}
+
+	if _, err := store.Explain(ctx, "ALTER TABLE t ADD INDEX t_x (x)"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for DDL, got %v", err)
+	}
+	if len(srv.explained) != 1 {
+		t.Errorf("expected DDL not to be explained, got %q", srv.explained)
+	}
+}
@@ -0,0 +1,609 @@
+// Package pgstore provides a golumn.Store for PostgreSQL. It issues plain
+// SQL through database/sql and does not import a driver; any driver whose
+// errors report their SQLSTATE through a SQLState() string method, such as
+// pgx and lib/pq, works.
+//
+// The store lock is a session-level advisory lock rather than a row in a
+// lock table, so a migrator that crashes or loses its connection releases
+// it with its session instead of leaving the store locked until
+// ForceUnlock. LockShared takes the same advisory lock in shared mode, on a
+// session of its own.
+package pgstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil"
+)
+
+// DefaultLockID is the advisory lock key used unless PgStore.LockID is set,
+// the bytes of "golumn" read as an integer.
+const DefaultLockID int64 = 0x676f6c756d6e
+
+// uniqueViolation is the SQLSTATE of unique_violation.
+const uniqueViolation = "23505"
+
+type PgStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Production flags the store as production, requiring approved runs.
+	Production bool
+	// LockID is the advisory lock key, which migrators sharing a database
+	// but not a schema_migrations table must not share. Zero means
+	// DefaultLockID, or a key derived from Lineage if that is set.
+	LockID int64
+	// Lineage names the store's migration set, see golumn.LineageTable.
+	// It suffixes the schema_migrations table and, unless LockID is set,
+	// picks the advisory lock.
+	Lineage string
+
+	instance *sql.DB
+	mu       sync.Mutex
+	// session tracks the connection whose session holds the advisory lock.
+	session sqlutil.SessionConn
+	// sharedSession tracks the connection whose session holds the lock in
+	// shared mode, taken shared times by this instance.
+	sharedSession sqlutil.SessionConn
+	shared        int
+}
+
+var (
+	_ golumn.Store               = (*PgStore)(nil)
+	_ golumn.ForceUnlocker       = (*PgStore)(nil)
+	_ golumn.ProductionStore     = (*PgStore)(nil)
+	_ golumn.ConnReserver        = (*PgStore)(nil)
+	_ golumn.LockInspector       = (*PgStore)(nil)
+	_ golumn.VersionLister       = (*PgStore)(nil)
+	_ golumn.TableLister         = (*PgStore)(nil)
+	_ golumn.Exporter            = (*PgStore)(nil)
+	_ golumn.IdentityStore       = (*PgStore)(nil)
+	_ golumn.LockHolderInspector = (*PgStore)(nil)
+	_ golumn.SharedLocker        = (*PgStore)(nil)
+	_ golumn.Annotator           = (*PgStore)(nil)
+	_ golumn.Explainer           = (*PgStore)(nil)
+	_ golumn.CompatRegistry      = (*PgStore)(nil)
+)
+
+func New(db *sql.DB) *PgStore {
+	return &PgStore{instance: db}
+}
+
+func (s *PgStore) DB() *sql.DB {
+	return s.instance
+}
+
+func (s *PgStore) IsProduction() bool {
+	return s.Production
+}
+
+// ReserveConn reserves the connection Lock takes the advisory lock on, see
+// golumn.ConnReserver.
+func (s *PgStore) ReserveConn(ctx context.Context) (func() error, error) {
+	return s.session.Reserve(ctx, s.instance)
+}
+
+func (s *PgStore) lockID() int64 {
+	switch {
+	case s.LockID != 0:
+		return s.LockID
+	case s.Lineage != "":
+		h := fnv.New64a()
+		h.Write([]byte(s.Lineage))
+		return DefaultLockID ^ int64(h.Sum64())
+	}
+	return DefaultLockID
+}
+
+// table names one of the store's tables for its lineage.
+func (s *PgStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables, which schema_identity, shared by all
+// lineages, completes.
+func (s *PgStore) Tables() []string {
+	return []string{s.table("schema_migrations"), s.table("schema_annotations"), s.table("schema_compat"), "schema_identity"}
+}
+
+// Init creates the schema_migrations, schema_annotations and schema_compat
+// tables. Concurrent CREATE TABLE IF NOT
+// EXISTS statements can race in PostgreSQL, so Init serializes on an
+// advisory lock of its own for the length of its transaction, and reports a
+// unique violation from such a race as golumn.ErrLocked. It does not wait
+// for a migrator holding the store lock.
+func (s *PgStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	key1, key2 := initLockKeys(s.lockID())
+	err := sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(tCtx, "SELECT pg_advisory_xact_lock($1, $2)", key1, key2); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS "+s.table("schema_migrations")+" (id BIGSERIAL PRIMARY KEY, version_id BIGINT UNIQUE NOT NULL, applied_at TIMESTAMPTZ NOT NULL DEFAULT now())"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS "+s.table("schema_annotations")+" (version_id BIGINT NOT NULL, key TEXT NOT NULL, value TEXT NOT NULL, PRIMARY KEY (version_id, key))"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS "+s.table("schema_compat")+" (app TEXT PRIMARY KEY, min_version BIGINT NOT NULL, max_version BIGINT NOT NULL, registered_at TIMESTAMPTZ NOT NULL DEFAULT now())"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS schema_identity (id INT PRIMARY KEY CHECK (id = 1), identity TEXT NOT NULL, created_at TIMESTAMPTZ NOT NULL DEFAULT now())")
+		return err
+	})
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%w: %w", golumn.ErrLocked, err)
+	}
+	return err
+}
+
+func (s *PgStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.session.Held() != nil {
+		return golumn.ErrLocked
+	}
+
+	conn, err := s.session.Get(ctx, s.instance)
+	if err != nil {
+		return err
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", s.lockID()).Scan(&acquired); err != nil {
+		return s.session.Put(conn, err)
+	}
+	if !acquired {
+		return errors.Join(golumn.ErrLocked, s.session.Put(conn, nil))
+	}
+	s.session.Hold(conn)
+	s.Log.Debugf("pgstore: acquired advisory lock %d", s.lockID())
+	return nil
+}
+
+func (s *PgStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn := s.session.Held(); conn != nil {
+		s.session.Hold(nil)
+
+		var released bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", s.lockID()).Scan(&released); err != nil {
+			return s.session.Put(conn, err)
+		}
+		if err := s.session.Put(conn, nil); err != nil {
+			return err
+		}
+		if released {
+			s.Log.Debugf("pgstore: released advisory lock %d", s.lockID())
+			return nil
+		}
+		s.Log.Debugf("pgstore: advisory lock %d no longer held", s.lockID())
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+// LockShared takes the advisory lock in shared mode, on a session of its
+// own unless this instance already holds it shared.
+func (s *PgStore) LockShared(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shared > 0 {
+		s.shared++
+		return nil
+	}
+
+	conn, err := s.sharedSession.Get(ctx, s.instance)
+	if err != nil {
+		return err
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock_shared($1)", s.lockID()).Scan(&acquired); err != nil {
+		return s.sharedSession.Put(conn, err)
+	}
+	if !acquired {
+		return errors.Join(golumn.ErrLocked, s.sharedSession.Put(conn, nil))
+	}
+	s.sharedSession.Hold(conn)
+	s.shared = 1
+	s.Log.Debugf("pgstore: acquired shared advisory lock %d", s.lockID())
+	return nil
+}
+
+func (s *PgStore) ReleaseShared(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shared > 1 {
+		s.shared--
+		return nil
+	}
+	if conn := s.sharedSession.Held(); conn != nil {
+		s.sharedSession.Hold(nil)
+		s.shared = 0
+
+		var released bool
+		if err := conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock_shared($1)", s.lockID()).Scan(&released); err != nil {
+			return s.sharedSession.Put(conn, err)
+		}
+		if err := s.sharedSession.Put(conn, nil); err != nil {
+			return err
+		}
+		if released {
+			s.Log.Debugf("pgstore: released shared advisory lock %d", s.lockID())
+			return nil
+		}
+		s.Log.Debugf("pgstore: shared advisory lock %d no longer held", s.lockID())
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+// ForceUnlock releases the advisory lock wherever it is held, in either
+// mode, terminating the session of any other process holding it.
+func (s *PgStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn := s.session.Held(); conn != nil {
+		if err := s.session.End(conn); err != nil {
+			return err
+		}
+	}
+	if conn := s.sharedSession.Held(); conn != nil {
+		s.shared = 0
+		if err := s.sharedSession.End(conn); err != nil {
+			return err
+		}
+	}
+
+	classID, objID := lockKeys(s.lockID())
+	if _, err := s.instance.ExecContext(ctx, "SELECT pg_terminate_backend(pid) FROM pg_locks WHERE locktype = 'advisory' AND classid::bigint = $1 AND objid::bigint = $2 AND objsubid = 1 AND granted AND pid <> pg_backend_pid()", classID, objID); err != nil {
+		return err
+	}
+	s.Log.Infof("pgstore: forcibly released advisory lock %d", s.lockID())
+	return nil
+}
+
+// Locked reports whether the advisory lock is held exclusively.
+func (s *PgStore) Locked(ctx context.Context) (bool, error) {
+	classID, objID := lockKeys(s.lockID())
+	var locked bool
+	err := s.instance.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM pg_locks WHERE locktype = 'advisory' AND classid::bigint = $1 AND objid::bigint = $2 AND objsubid = 1 AND mode = 'ExclusiveLock' AND granted)", classID, objID).Scan(&locked)
+	return locked, err
+}
+
+// LockHolder describes the session holding the advisory lock exclusively
+// by its backend pid, application_name, user and client address, as
+// pg_stat_activity reports them. PostgreSQL does not record when an
+// advisory lock was granted, so Since is zero.
+func (s *PgStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	classID, objID := lockKeys(s.lockID())
+	var pid int64
+	var app, user, addr string
+	err := s.instance.QueryRowContext(ctx, "SELECT a.pid, coalesce(a.application_name, ''), coalesce(a.usename::text, ''), coalesce(host(a.client_addr), 'local') FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid WHERE l.locktype = 'advisory' AND l.classid::bigint = $1 AND l.objid::bigint = $2 AND l.objsubid = 1 AND l.mode = 'ExclusiveLock' AND l.granted", classID, objID).Scan(&pid, &app, &user, &addr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	owner := fmt.Sprintf("pid %d (%s@%s)", pid, user, addr)
+	if app != "" {
+		owner = fmt.Sprintf("%s %s", app, owner)
+	}
+	return &golumn.LockHolder{Owner: owner}, nil
+}
+
+func (s *PgStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id DESC LIMIT 1")
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *PgStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *PgStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	if _, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id) VALUES ($1)", v); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("version %d is already applied: %w", v, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *PgStore) Remove(ctx context.Context, v int64) error {
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = $1", v); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_annotations")+" WHERE version_id = $1", v)
+		return err
+	})
+}
+
+func (s *PgStore) Annotate(ctx context.Context, v int64, key, value string) error {
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		var applied int
+		if err := tx.QueryRowContext(tCtx, "SELECT COUNT(*) FROM "+s.table("schema_migrations")+" WHERE version_id = $1", v).Scan(&applied); err != nil {
+			return err
+		}
+		if applied == 0 {
+			return golumn.ErrNotApplied
+		}
+		if value == "" {
+			_, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_annotations")+" WHERE version_id = $1 AND key = $2", v, key)
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_annotations")+" (version_id, key, value) VALUES ($1, $2, $3) ON CONFLICT (version_id, key) DO UPDATE SET value = excluded.value", v, key, value)
+		return err
+	})
+}
+
+func (s *PgStore) Annotations(ctx context.Context) (map[int64]map[string]string, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id, key, value FROM "+s.table("schema_annotations"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := map[int64]map[string]string{}
+	for rows.Next() {
+		var v int64
+		var key, value string
+		if err := rows.Scan(&v, &key, &value); err != nil {
+			return nil, err
+		}
+		if notes[v] == nil {
+			notes[v] = map[string]string{}
+		}
+		notes[v][key] = value
+	}
+	return notes, rows.Err()
+}
+
+// Explain returns the EXPLAIN (FORMAT TEXT) plan of a DML statement. It
+// does not ANALYZE, so the statement is planned but not run. Other
+// statements return errors.ErrUnsupported.
+func (s *PgStore) Explain(ctx context.Context, stmt string) (string, error) {
+	if !sqlutil.IsDML(stmt) {
+		return "", errors.ErrUnsupported
+	}
+	rows, err := s.instance.QueryContext(ctx, "EXPLAIN (FORMAT TEXT) "+stmt)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var lines []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Identity returns the database's identity, inserting it unless a row,
+// perhaps from a concurrent call, exists. Stores of every lineage share it.
+func (s *PgStore) Identity(ctx context.Context) (string, error) {
+	if _, err := s.instance.ExecContext(ctx, "INSERT INTO schema_identity (id, identity) VALUES (1, $1) ON CONFLICT (id) DO NOTHING", golumn.NewIdentity()); err != nil {
+		return "", err
+	}
+	var id string
+	err := s.instance.QueryRowContext(ctx, "SELECT identity FROM schema_identity WHERE id = 1").Scan(&id)
+	return id, err
+}
+
+func (s *PgStore) RegisterCompat(ctx context.Context, c golumn.Compat) error {
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_compat")+" (app, min_version, max_version) VALUES ($1, $2, $3) ON CONFLICT (app) DO UPDATE SET min_version = excluded.min_version, max_version = excluded.max_version, registered_at = now()", c.App, c.MinVersion, c.MaxVersion)
+	return err
+}
+
+func (s *PgStore) UnregisterCompat(ctx context.Context, app string) error {
+	_, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_compat")+" WHERE app = $1", app)
+	return err
+}
+
+func (s *PgStore) Compats(ctx context.Context) ([]golumn.Compat, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT app, min_version, max_version, registered_at FROM "+s.table("schema_compat")+" ORDER BY app")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var compats []golumn.Compat
+	for rows.Next() {
+		var c golumn.Compat
+		if err := rows.Scan(&c.App, &c.MinVersion, &c.MaxVersion, &c.Registered); err != nil {
+			return nil, err
+		}
+		compats = append(compats, c)
+	}
+	return compats, rows.Err()
+}
+
+// Export writes the applied versions with the times they were applied and
+// their annotations, and the compat registrations, read in one transaction.
+func (s *PgStore) Export(ctx context.Context, w io.Writer) error {
+	var h golumn.History
+	if err := sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		rows, err := tx.QueryContext(tCtx, "SELECT version_id, applied_at FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		index := map[int64]int{}
+		for rows.Next() {
+			var v golumn.AppliedVersion
+			if err := rows.Scan(&v.Version, &v.AppliedAt); err != nil {
+				return err
+			}
+			index[v.Version] = len(h.Versions)
+			h.Versions = append(h.Versions, v)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(tCtx, "SELECT version_id, key, value FROM "+s.table("schema_annotations")+" ORDER BY version_id, key")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v int64
+			var key, value string
+			if err := rows.Scan(&v, &key, &value); err != nil {
+				return err
+			}
+			i, ok := index[v]
+			if !ok {
+				continue
+			}
+			if h.Versions[i].Annotations == nil {
+				h.Versions[i].Annotations = map[string]string{}
+			}
+			h.Versions[i].Annotations[key] = value
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(tCtx, "SELECT app, min_version, max_version, registered_at FROM "+s.table("schema_compat")+" ORDER BY app")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var c golumn.Compat
+			if err := rows.Scan(&c.App, &c.MinVersion, &c.MaxVersion, &c.Registered); err != nil {
+				return err
+			}
+			h.Compats = append(h.Compats, c)
+		}
+		return rows.Err()
+	}); err != nil {
+		return err
+	}
+	return golumn.WriteHistory(w, &h)
+}
+
+// Import replaces the applied versions, their annotations and the compat
+// registrations with those read from r.
+func (s *PgStore) Import(ctx context.Context, r io.Reader) error {
+	h, err := golumn.ReadHistory(r)
+	if err != nil {
+		return err
+	}
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		for _, table := range []string{s.table("schema_migrations"), s.table("schema_annotations"), s.table("schema_compat")} {
+			if _, err := tx.ExecContext(tCtx, "DELETE FROM "+table); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		for _, v := range h.Versions {
+			appliedAt := v.AppliedAt
+			if appliedAt.IsZero() {
+				appliedAt = now
+			}
+			if _, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_migrations")+" (version_id, applied_at) VALUES ($1, $2)", v.Version, appliedAt.UTC()); err != nil {
+				return err
+			}
+			for key, value := range v.Annotations {
+				if _, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_annotations")+" (version_id, key, value) VALUES ($1, $2, $3)", v.Version, key, value); err != nil {
+					return err
+				}
+			}
+		}
+		for _, c := range h.Compats {
+			registered := c.Registered
+			if registered.IsZero() {
+				registered = now
+			}
+			if _, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_compat")+" (app, min_version, max_version, registered_at) VALUES ($1, $2, $3, $4)", c.App, c.MinVersion, c.MaxVersion, registered.UTC()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lockKeys splits an advisory lock key into the classid and objid columns
+// pg_locks shows it as.
+func lockKeys(id int64) (classID, objID int64) {
+	return int64(uint64(id) >> 32), int64(uint32(id))
+}
+
+// initLockKeys derives the key Init locks from the store's lock key: its two
+// halves as the keys of a two-key advisory lock, which PostgreSQL keeps
+// apart from the single-key lock Lock takes.
+func initLockKeys(id int64) (key1, key2 int32) {
+	return int32(uint64(id) >> 32), int32(uint32(id))
+}
+
+// isUniqueViolation reports whether err carries the SQLSTATE of a unique
+// violation.
+func isUniqueViolation(err error) bool {
+	var stateErr interface{ SQLState() string }
+	return errors.As(err, &stateErr) && stateErr.SQLState() == uniqueViolation
+}
@@ -0,0 +1,521 @@
+package pgstore_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/pgstore"
+	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+)
+
+// fakeServer stands in for PostgreSQL's advisory locks: a session-level
+// lock held exclusively by at most one connection, or shared by any number
+// of them, and released when they close.
+type fakeServer struct {
+	sqltest.Server
+	holder    *sqltest.Conn
+	sharers   map[*sqltest.Conn]bool
+	initErr   error
+	unlockErr error
+	// writes records, for each INSERT INTO t, whether its connection ran
+	// SET application_name and whether it held the lock.
+	writes []fakeWrite
+	// versions holds the rows of schema_migrations, by version_id.
+	versions map[int64]time.Time
+	// annotations holds the rows of schema_annotations.
+	annotations map[int64]map[string]string
+	// explained records the EXPLAIN queries run.
+	explained []string
+	// compats holds the rows of schema_compat, by app.
+	compats  map[string]golumn.Compat
+	identity string
+}
+
+type fakeWrite struct{ configured, holder bool }
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query, srv.Close = srv.exec, srv.query, srv.closed
+	srv.Begin = func(*sqltest.Conn) error { return nil }
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (srv *fakeServer) closed(c *sqltest.Conn) {
+	if srv.holder == c {
+		srv.holder = nil
+	}
+	delete(srv.sharers, c)
+}
+
+func (srv *fakeServer) exec(c *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE") && srv.initErr != nil:
+		return nil, srv.initErr
+	case strings.HasPrefix(query, "SELECT pg_advisory_xact_lock($1)") && srv.holder != nil && srv.holder != c:
+		// PostgreSQL would block until the holder's session released it.
+		return nil, errors.New("blocked on the session lock")
+	case strings.HasPrefix(query, "SET application_name"):
+		c.Configured = true
+	case strings.HasPrefix(query, "RESET application_name"):
+		c.Configured = false
+	case strings.HasPrefix(query, "INSERT INTO t "):
+		srv.writes = append(srv.writes, fakeWrite{c.Configured, srv.holder == c})
+	case strings.HasPrefix(query, "INSERT INTO schema_identity") && srv.identity == "":
+		srv.identity = args[0].(string)
+	case query == "DELETE FROM schema_migrations":
+		srv.versions = nil
+	case query == "DELETE FROM schema_annotations":
+		srv.annotations = nil
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations (version_id, applied_at)"):
+		srv.insertVersion(args[0].(int64), args[1].(time.Time))
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations (version_id)"):
+		srv.insertVersion(args[0].(int64), time.Now())
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations WHERE"):
+		delete(srv.versions, args[0].(int64))
+	case strings.HasPrefix(query, "DELETE FROM schema_annotations WHERE version_id = $1 AND key = $2"):
+		delete(srv.annotations[args[0].(int64)], args[1].(string))
+	case strings.HasPrefix(query, "DELETE FROM schema_annotations WHERE"):
+		delete(srv.annotations, args[0].(int64))
+	case query == "DELETE FROM schema_compat":
+		srv.compats = nil
+	case strings.HasPrefix(query, "DELETE FROM schema_compat WHERE"):
+		delete(srv.compats, args[0].(string))
+	case strings.HasPrefix(query, "INSERT INTO schema_compat"):
+		if srv.compats == nil {
+			srv.compats = map[string]golumn.Compat{}
+		}
+		c := golumn.Compat{App: args[0].(string), MinVersion: args[1].(int64), MaxVersion: args[2].(int64), Registered: time.Now()}
+		if len(args) > 3 {
+			c.Registered = args[3].(time.Time)
+		}
+		srv.compats[c.App] = c
+	case strings.HasPrefix(query, "INSERT INTO schema_annotations"):
+		if srv.annotations == nil {
+			srv.annotations = map[int64]map[string]string{}
+		}
+		v := args[0].(int64)
+		if srv.annotations[v] == nil {
+			srv.annotations[v] = map[string]string{}
+		}
+		srv.annotations[v][args[1].(string)] = args[2].(string)
+	}
+	return nil, nil
+}
+
+func (srv *fakeServer) insertVersion(v int64, appliedAt time.Time) {
+	if srv.versions == nil {
+		srv.versions = map[int64]time.Time{}
+	}
+	srv.versions[v] = appliedAt
+}
+
+func (srv *fakeServer) query(c *sqltest.Conn, query string, args []driver.Value) (driver.Rows, error) {
+	switch {
+	case strings.HasPrefix(query, "SELECT pg_try_advisory_lock_shared"):
+		if srv.holder != nil {
+			return sqltest.Value(false), nil
+		}
+		if srv.sharers == nil {
+			srv.sharers = make(map[*sqltest.Conn]bool)
+		}
+		srv.sharers[c] = true
+		return sqltest.Value(true), nil
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock_shared"):
+		held := srv.sharers[c]
+		delete(srv.sharers, c)
+		return sqltest.Value(held), nil
+	case strings.HasPrefix(query, "SELECT pg_try_advisory_lock"):
+		if srv.holder != nil || len(srv.sharers) > 0 {
+			return sqltest.Value(false), nil
+		}
+		srv.holder = c
+		return sqltest.Value(true), nil
+	case strings.HasPrefix(query, "SELECT pg_advisory_unlock"):
+		if srv.unlockErr != nil {
+			return nil, srv.unlockErr
+		}
+		held := srv.holder == c
+		if held {
+			srv.holder = nil
+		}
+		return sqltest.Value(held), nil
+	case strings.HasPrefix(query, "SELECT version_id FROM schema_migrations ORDER BY version_id DESC LIMIT 1"):
+		if len(srv.versions) == 0 {
+			return sqltest.Rows([]string{"version_id"}), nil
+		}
+		return sqltest.Value(slices.Max(slices.Collect(maps.Keys(srv.versions)))), nil
+	case strings.HasPrefix(query, "SELECT version_id FROM schema_migrations"):
+		return sqltest.Values(slices.Sorted(maps.Keys(srv.versions))...), nil
+	case strings.HasPrefix(query, "SELECT COUNT(*) FROM schema_migrations"):
+		var n int64
+		if _, ok := srv.versions[args[0].(int64)]; ok {
+			n = 1
+		}
+		return sqltest.Value(n), nil
+	case strings.HasPrefix(query, "SELECT version_id, key, value FROM schema_annotations"):
+		var rows [][]driver.Value
+		for _, v := range slices.Sorted(maps.Keys(srv.annotations)) {
+			for _, key := range slices.Sorted(maps.Keys(srv.annotations[v])) {
+				rows = append(rows, []driver.Value{v, key, srv.annotations[v][key]})
+			}
+		}
+		return sqltest.Rows([]string{"version_id", "key", "value"}, rows...), nil
+	case strings.HasPrefix(query, "SELECT a.pid"):
+		columns := []string{"pid", "application_name", "usename", "client_addr"}
+		if srv.holder == nil {
+			return sqltest.Rows(columns), nil
+		}
+		return sqltest.Rows(columns, []driver.Value{int64(4242), "deploy", "golumn", "10.0.0.5"}), nil
+	case strings.HasPrefix(query, "SELECT app, min_version, max_version, registered_at FROM schema_compat"):
+		var rows [][]driver.Value
+		for _, app := range slices.Sorted(maps.Keys(srv.compats)) {
+			c := srv.compats[app]
+			rows = append(rows, []driver.Value{c.App, c.MinVersion, c.MaxVersion, c.Registered})
+		}
+		return sqltest.Rows([]string{"app", "min_version", "max_version", "registered_at"}, rows...), nil
+	case strings.HasPrefix(query, "EXPLAIN "):
+		srv.explained = append(srv.explained, query)
+		return sqltest.Rows([]string{"QUERY PLAN"}, []driver.Value{"Seq Scan on t  (cost=0.00..35.50 rows=10 width=4)"}, []driver.Value{"  Filter: (x = 1)"}), nil
+	case strings.HasPrefix(query, "SELECT identity FROM schema_identity"):
+		return sqltest.Value(srv.identity), nil
+	case strings.HasPrefix(query, "SELECT version_id, applied_at FROM schema_migrations"):
+		var rows [][]driver.Value
+		for _, v := range slices.Sorted(maps.Keys(srv.versions)) {
+			rows = append(rows, []driver.Value{v, srv.versions[v]})
+		}
+		return sqltest.Rows([]string{"version_id", "applied_at"}, rows...), nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func TestPgStore_ConnConfig(t *testing.T) {
+	srv := &fakeServer{}
+	db := openFake(t, srv)
+	migrator := &golumn.Migrator{
+		Store: pgstore.New(db),
+		Sources: []*golumn.Migration{{
+			Version: 1,
+			UpFunc: func(ctx context.Context, db *sql.DB) error {
+				_, err := db.ExecContext(ctx, "INSERT INTO t (id) VALUES (1)")
+				return err
+			},
+		}},
+		Conn: golumn.PostgresConnConfig("golumn", ""),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("expected up to run beside the advisory lock's connection, got %v", err)
+	}
+	if want := []fakeWrite{{configured: true}}; !slices.Equal(srv.writes, want) {
+		t.Errorf("expected the migration on the configured connection, not the lock's, got %+v", srv.writes)
+	}
+	if srv.holder != nil {
+		t.Error("expected the lock to be released")
+	}
+	if n := db.Stats().MaxOpenConnections; n != 0 {
+		t.Errorf("expected the pool limit to be restored, got %d", n)
+	}
+}
+
+func TestPgStore_AdvisoryLock(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := pgstore.New(openFake(t, srv)), pgstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := a.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked relocking, got %v", err)
+	}
+	if err := b.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked from another store, got %v", err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := b.Lock(ctx); err != nil {
+		t.Fatalf("lock after release failed: %v", err)
+	}
+
+	// A failed unlock ends the session, and the lock with it.
+	srv.unlockErr = errors.New("connection reset")
+	if err := b.Release(ctx); err == nil {
+		t.Error("expected the failed unlock to be reported")
+	}
+	srv.unlockErr = nil
+	if err := a.Lock(ctx); err != nil {
+		t.Errorf("lock after the holder's session ended failed: %v", err)
+	}
+
+	a.ReleasePolicy = golumn.ReleaseErrorUnheld
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := a.Release(ctx); !errors.Is(err, golumn.ErrNotLocked) {
+		t.Errorf("expected ErrNotLocked, got %v", err)
+	}
+}
+
+func TestPgStore_SharedLock(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := pgstore.New(openFake(t, srv)), pgstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	for range 2 {
+		if err := a.LockShared(ctx); err != nil {
+			t.Fatalf("shared lock failed: %v", err)
+		}
+	}
+	if err := b.LockShared(ctx); err != nil {
+		t.Fatalf("shared lock from another store failed: %v", err)
+	}
+	if err := a.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked while held shared, got %v", err)
+	}
+	for range 2 {
+		if err := a.ReleaseShared(ctx); err != nil {
+			t.Fatalf("shared release failed: %v", err)
+		}
+	}
+	if err := a.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked while another store holds it shared, got %v", err)
+	}
+	if err := b.ReleaseShared(ctx); err != nil {
+		t.Fatalf("shared release failed: %v", err)
+	}
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock after the shared releases failed: %v", err)
+	}
+	if err := b.LockShared(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked while held exclusively, got %v", err)
+	}
+
+	b.ReleasePolicy = golumn.ReleaseErrorUnheld
+	if err := b.ReleaseShared(ctx); !errors.Is(err, golumn.ErrNotLocked) {
+		t.Errorf("expected ErrNotLocked, got %v", err)
+	}
+}
+
+func TestPgStore_InitRace(t *testing.T) {
+	srv := &fakeServer{initErr: sqlStateError("23505")}
+	store := pgstore.New(openFake(t, srv))
+	if err := store.Init(context.Background()); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected a unique violation to report ErrLocked, got %v", err)
+	}
+
+	srv.initErr = sqlStateError("42501")
+	if err := store.Init(context.Background()); err == nil || errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected other errors to pass through, got %v", err)
+	}
+}
+
+func TestPgStore_InitWhileLocked(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := pgstore.New(openFake(t, srv)), pgstore.New(openFake(t, srv))
+	ctx := context.Background()
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := b.Init(ctx); err != nil {
+		t.Errorf("expected init not to wait for the store lock, got %v", err)
+	}
+	if err := b.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+}
+
+func TestPgStore_Production(t *testing.T) {
+	store := pgstore.New(openFake(t, &fakeServer{}))
+	store.Production = true
+	migrator := &golumn.Migrator{
+		Store: store,
+		Sources: []*golumn.Migration{{
+			Version: 1,
+			Tags:    []string{golumn.TagAnonymize},
+			UpFunc:  func(context.Context, *sql.DB) error { return nil },
+		}},
+	}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); !errors.Is(err, golumn.ErrAnonymizeProduction) {
+		t.Errorf("expected ErrAnonymizeProduction, got %v", err)
+	}
+
+	migrator.Sources[0].Tags = nil
+	if _, err := migrator.Up(context.Background(), golumn.Latest); !errors.Is(err, golumn.ErrApprovalRequired) {
+		t.Errorf("expected ErrApprovalRequired, got %v", err)
+	}
+}
+
+func TestPgStore_TransferHistory(t *testing.T) {
+	ctx := context.Background()
+	openSqlite := func(name string) *golumn.Migrator {
+		db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return &golumn.Migrator{Store: sqlite3store.New(db)}
+	}
+	noop := func(context.Context, *sql.DB) error { return nil }
+	src := openSqlite("src.db")
+	src.Sources = []*golumn.Migration{{Version: 1, UpFunc: noop}, {Version: 2, UpFunc: noop}}
+	src.RecordStats = true
+	if _, err := src.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := src.Annotate(ctx, 1, "note", "superseded by 2"); err != nil {
+		t.Fatalf("annotate failed: %v", err)
+	}
+	if err := src.RegisterCompat(ctx, "api", 1, golumn.Latest); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	srv := &fakeServer{
+		versions:    map[int64]time.Time{9: time.Now()},
+		annotations: map[int64]map[string]string{9: {"note": "stale"}},
+		compats:     map[string]golumn.Compat{"old": {App: "old"}},
+	}
+	pg := &golumn.Migrator{Store: pgstore.New(openFake(t, srv))}
+	if err := golumn.TransferHistory(ctx, pg, src); err != nil {
+		t.Fatalf("transfer to pgstore failed: %v", err)
+	}
+	if got := slices.Sorted(maps.Keys(srv.versions)); !slices.Equal(got, []int64{1, 2}) {
+		t.Errorf("expected versions [1 2] in pgstore, got %v", got)
+	}
+	if got := srv.annotations[1]["note"]; got != "superseded by 2" || len(srv.annotations) != 2 {
+		t.Errorf("expected the note and stats of both versions in pgstore, got %v", srv.annotations)
+	}
+	if got := slices.Collect(maps.Keys(srv.compats)); !slices.Equal(got, []string{"api"}) {
+		t.Errorf("expected only the api registration in pgstore, got %v", got)
+	}
+
+	dst := openSqlite("dst.db")
+	if err := golumn.TransferHistory(ctx, dst, pg); err != nil {
+		t.Fatalf("transfer from pgstore failed: %v", err)
+	}
+	var want, got bytes.Buffer
+	if err := src.Export(ctx, &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Export(ctx, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("expected the history to round trip through pgstore\nwant: %s\ngot:  %s", want.String(), got.String())
+	}
+}
+
+func TestPgStore_Identity(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := pgstore.New(openFake(t, srv)), pgstore.New(openFake(t, srv))
+	ctx := context.Background()
+	id, err := a.Identity(ctx)
+	if err != nil || id == "" {
+		t.Fatalf("expected an identity, got %q (err %v)", id, err)
+	}
+	if other, err := b.Identity(ctx); err != nil || other != id {
+		t.Errorf("expected another store on the database to report %q, got %q (err %v)", id, other, err)
+	}
+}
+
+func TestPgStore_LockHolder(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := pgstore.New(openFake(t, srv)), pgstore.New(openFake(t, srv))
+	ctx := context.Background()
+	if holder, err := b.LockHolder(ctx); err != nil || holder != nil {
+		t.Fatalf("expected no holder of a free lock, got %+v (err %v)", holder, err)
+	}
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	holder, err := b.LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "deploy pid 4242 (golumn@10.0.0.5)"; holder == nil || holder.Owner != want {
+		t.Errorf("expected holder %q, got %+v", want, holder)
+	}
+}
+
+func TestPgStore_Annotations(t *testing.T) {
+	srv := &fakeServer{}
+	store := pgstore.New(openFake(t, srv))
+	ctx := context.Background()
+	if err := store.Annotate(ctx, 1, "note", "x"); !errors.Is(err, golumn.ErrNotApplied) {
+		t.Errorf("expected ErrNotApplied, got %v", err)
+	}
+
+	migrator := &golumn.Migrator{
+		Store:       store,
+		Sources:     []*golumn.Migration{{Version: 1, UpFunc: func(context.Context, *sql.DB) error { return nil }}},
+		RecordStats: true,
+	}
+	if _, err := migrator.Up(ctx, golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if err := store.Annotate(ctx, 1, "note", "superseded by 2"); err != nil {
+		t.Fatalf("annotate failed: %v", err)
+	}
+	notes, err := store.Annotations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{golumn.AnnotationStatements: "0", golumn.AnnotationRowsAffected: "0", "note": "superseded by 2"}
+	if !maps.Equal(notes[1], want) {
+		t.Errorf("expected recorded stats and the note, got %v", notes)
+	}
+
+	if err := store.Annotate(ctx, 1, "note", ""); err != nil {
+		t.Fatalf("annotate failed: %v", err)
+	}
+	if notes, _ := store.Annotations(ctx); notes[1]["note"] != "" {
+		t.Errorf("expected the note deleted, got %v", notes)
+	}
+	if err := store.Remove(ctx, 1); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if notes, _ := store.Annotations(ctx); len(notes) != 0 {
+		t.Errorf("expected annotations removed with their version, got %v", notes)
+	}
+}
+
+func TestPgStore_Explain(t *testing.T) {
+	srv := &fakeServer{}
+	store := pgstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	plan, err := store.Explain(ctx, "UPDATE t SET y = 2 WHERE x = 1")
+	if err != nil {
+		t.Fatalf("explain failed: %v", err)
+	}
+	if want := "Seq Scan on t  (cost=0.00..35.50 rows=10 width=4)\n  Filter: (x = 1)"; plan != want {
+		t.Errorf("expected plan %q, got %q", want, plan)
+	}
+	if want := []string{"EXPLAIN (FORMAT TEXT) UPDATE t SET y = 2 WHERE x = 1"}; !slices.Equal(srv.explained, want) {
+		t.Errorf("expected %q without ANALYZE, got %q", want, srv.explained)
+	}
+
+	if _, err := store.Explain(ctx, "CREATE INDEX t_x ON t (x)"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for DDL, got %v", err)
+	}
+	if len(srv.explained) != 1 {
+		t.Errorf("expected DDL not to be explained, got %q", srv.explained)
+	}
+}
@@ -0,0 +1,422 @@
+package rqlitestore
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Connector opens connections to an rqlite cluster over its HTTP API, for
+// sql.OpenDB. A connection is a client of the node at URL, or of the
+// leader that node redirects to; connections share what they learn of the
+// leader.
+//
+// rqlite has no interactive transactions. A transaction begun on a
+// connection buffers its statements and sends them in one request, run in
+// a single transaction, when committed; their results are not known before
+// then, and queries are refused until then.
+type Connector struct {
+	// URL is the node's HTTP API, e.g. http://localhost:4001, with any
+	// basic auth credentials as its userinfo.
+	URL string
+	// Client sends the requests, or http.DefaultClient if nil. Redirects
+	// are followed by the connector, not the client.
+	Client *http.Client
+	// Level is the read consistency of queries, "none", "weak" or
+	// "strong", or the node's default, "weak", if empty.
+	Level string
+	// Retries is how many times a request refused because the cluster has
+	// no leader, e.g. during an election, is retried, waiting RetryDelay
+	// between attempts. Requests whose outcome is unknown, such as writes
+	// that lost leadership while committing, are not retried.
+	Retries    int
+	RetryDelay time.Duration
+
+	mu     sync.Mutex
+	leader string
+}
+
+// NewConnector returns a Connector for the node at url that retries
+// leaderless requests for up to 10 seconds.
+func NewConnector(url string) *Connector {
+	return &Connector{URL: url, Retries: 20, RetryDelay: 500 * time.Millisecond}
+}
+
+var _ driver.Connector = (*Connector)(nil)
+
+func (c *Connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{c: c}, nil
+}
+
+func (c *Connector) Driver() driver.Driver {
+	return Driver{}
+}
+
+// Driver opens connections to the node whose HTTP API is named by the
+// data source name, with the settings of NewConnector. It is not
+// registered with database/sql; use sql.OpenDB with a Connector.
+type Driver struct{}
+
+func (Driver) Open(name string) (driver.Conn, error) {
+	return &conn{c: NewConnector(name)}, nil
+}
+
+// base returns the URL requests are sent to: the last leader redirected
+// to, or URL.
+func (c *Connector) base() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.leader != "" {
+		return c.leader
+	}
+	return c.URL
+}
+
+func (c *Connector) setLeader(leader string) {
+	c.mu.Lock()
+	c.leader = leader
+	c.mu.Unlock()
+}
+
+// result is the outcome of one statement in an rqlite response.
+type result struct {
+	Columns      []string `json:"columns"`
+	Types        []string `json:"types"`
+	Values       [][]any  `json:"values"`
+	LastInsertID int64    `json:"last_insert_id"`
+	RowsAffected int64    `json:"rows_affected"`
+	Error        string   `json:"error"`
+}
+
+type response struct {
+	Results []result `json:"results"`
+	Error   string   `json:"error"`
+}
+
+// errNoLeader marks a request refused because the cluster has no leader.
+var errNoLeader = errors.New("rqlite: no leader")
+
+// do posts stmts to the endpoint, /db/execute or /db/query, with query
+// parameters params, following redirects to the leader and retrying while
+// there is none.
+func (c *Connector) do(ctx context.Context, endpoint string, params url.Values, stmts [][]any) ([]result, error) {
+	body, err := json.Marshal(stmts)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for attempt := 0; attempt <= c.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.RetryDelay):
+			}
+		}
+		results, err := c.post(ctx, c.base(), endpoint, params, body, len(stmts), 0)
+		if !errors.Is(err, errNoLeader) {
+			return results, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// maxRedirects bounds the redirects followed by one request.
+const maxRedirects = 5
+
+// post sends a request of n statements to the node at base.
+func (c *Connector) post(ctx context.Context, base, endpoint string, params url.Values, body []byte, n, redirects int) ([]result, error) {
+	u, err := url.Parse(strings.TrimSuffix(base, "/") + endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = params.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if user := u.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+
+	client := http.DefaultClient
+	if c.Client != nil {
+		client = c.Client
+	}
+	// Redirects are followed here, as http.Client would resend a POST as
+	// a GET without its body.
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := noRedirect.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusMovedPermanently || resp.StatusCode == http.StatusTemporaryRedirect || resp.StatusCode == http.StatusPermanentRedirect:
+		if redirects == maxRedirects {
+			return nil, fmt.Errorf("rqlite: too many redirects")
+		}
+		loc, err := resp.Location()
+		if err != nil {
+			return nil, fmt.Errorf("rqlite: redirect without location: %w", err)
+		}
+		leader := (&url.URL{Scheme: loc.Scheme, User: u.User, Host: loc.Host}).String()
+		c.setLeader(leader)
+		return c.post(ctx, leader, endpoint, params, body, n, redirects+1)
+	case resp.StatusCode == http.StatusServiceUnavailable || strings.Contains(string(data), "not leader"):
+		c.setLeader("")
+		return nil, fmt.Errorf("%w: %s", errNoLeader, strings.TrimSpace(string(data)))
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("rqlite: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var r response
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("rqlite: invalid response: %w", err)
+	}
+	if r.Error != "" {
+		return nil, errors.New(r.Error)
+	}
+	if len(r.Results) != n {
+		return nil, fmt.Errorf("rqlite: got %d results for %d statements", len(r.Results), n)
+	}
+	return r.Results, nil
+}
+
+// statement encodes query and args as an rqlite parameterized statement.
+func statement(query string, args []driver.NamedValue) ([]any, error) {
+	stmt := []any{query}
+	for _, arg := range args {
+		if arg.Name != "" {
+			return nil, fmt.Errorf("rqlite: named parameter %q is not supported", arg.Name)
+		}
+		switch v := arg.Value.(type) {
+		case []byte:
+			stmt = append(stmt, string(v))
+		case time.Time:
+			stmt = append(stmt, v.UTC().Format(time.RFC3339Nano))
+		default:
+			stmt = append(stmt, v)
+		}
+	}
+	return stmt, nil
+}
+
+type conn struct {
+	c  *Connector
+	tx *tx
+}
+
+var (
+	_ driver.ExecerContext  = (*conn)(nil)
+	_ driver.QueryerContext = (*conn)(nil)
+	_ driver.ConnBeginTx    = (*conn)(nil)
+)
+
+func (cn *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{cn: cn, query: query}, nil
+}
+
+func (cn *conn) Close() error {
+	return nil
+}
+
+func (cn *conn) Begin() (driver.Tx, error) {
+	return cn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (cn *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if cn.tx != nil {
+		return nil, errors.New("rqlite: transaction already begun")
+	}
+	if opts.ReadOnly {
+		return nil, errors.New("rqlite: read-only transactions are not supported")
+	}
+	cn.tx = &tx{cn: cn, ctx: ctx}
+	return cn.tx, nil
+}
+
+func (cn *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	s, err := statement(query, args)
+	if err != nil {
+		return nil, err
+	}
+	if cn.tx != nil {
+		cn.tx.stmts = append(cn.tx.stmts, s)
+		return pendingResult{}, nil
+	}
+	results, err := cn.c.do(ctx, "/db/execute", nil, [][]any{s})
+	if err != nil {
+		return nil, err
+	}
+	if results[0].Error != "" {
+		return nil, errors.New(results[0].Error)
+	}
+	return execResult{results[0].LastInsertID, results[0].RowsAffected}, nil
+}
+
+func (cn *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if cn.tx != nil {
+		return nil, errors.New("rqlite: queries cannot run in a transaction, whose statements are only sent on commit")
+	}
+	s, err := statement(query, args)
+	if err != nil {
+		return nil, err
+	}
+	var params url.Values
+	if cn.c.Level != "" {
+		params = url.Values{"level": {cn.c.Level}}
+	}
+	results, err := cn.c.do(ctx, "/db/query", params, [][]any{s})
+	if err != nil {
+		return nil, err
+	}
+	if results[0].Error != "" {
+		return nil, errors.New(results[0].Error)
+	}
+	return &rows{result: results[0]}, nil
+}
+
+type stmt struct {
+	cn    *conn
+	query string
+}
+
+var (
+	_ driver.StmtExecContext  = (*stmt)(nil)
+	_ driver.StmtQueryContext = (*stmt)(nil)
+)
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.cn.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.cn.QueryContext(ctx, s.query, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	nv := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		nv[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return nv
+}
+
+// tx buffers the statements of a transaction until Commit.
+type tx struct {
+	cn    *conn
+	ctx   context.Context
+	stmts [][]any
+}
+
+func (t *tx) Commit() error {
+	t.cn.tx = nil
+	if len(t.stmts) == 0 {
+		return nil
+	}
+	results, err := t.cn.c.do(t.ctx, "/db/execute", url.Values{"transaction": {"true"}}, t.stmts)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		if r.Error != "" {
+			return fmt.Errorf("statement %d: %s", i+1, r.Error)
+		}
+	}
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.cn.tx = nil
+	return nil
+}
+
+type execResult struct {
+	lastInsertID, rowsAffected int64
+}
+
+func (r execResult) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r execResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+// pendingResult is the result of a statement buffered in a transaction.
+type pendingResult struct{}
+
+var errPending = errors.New("rqlite: result unknown until the transaction commits")
+
+func (pendingResult) LastInsertId() (int64, error) { return 0, errPending }
+func (pendingResult) RowsAffected() (int64, error) { return 0, errPending }
+
+type rows struct {
+	result result
+	next   int
+}
+
+func (r *rows) Columns() []string { return r.result.Columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next == len(r.result.Values) {
+		return io.EOF
+	}
+	row := r.result.Values[r.next]
+	r.next++
+	for i := range dest {
+		if i >= len(row) {
+			dest[i] = nil
+			continue
+		}
+		v, err := value(row[i])
+		if err != nil {
+			return err
+		}
+		dest[i] = v
+	}
+	return nil
+}
+
+// value converts a value decoded from a response to a driver.Value.
+func value(v any) (driver.Value, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case string, bool, nil:
+		return v, nil
+	default:
+		return nil, fmt.Errorf("rqlite: unsupported value %T", v)
+	}
+}
@@ -0,0 +1,193 @@
+// Package rqlitestore provides a golumn.Store for rqlite, the distributed
+// database built on SQLite, reached through rqlite's HTTP API. Connector
+// implements the API as a database/sql connector, following redirects to
+// the cluster leader and retrying requests while a new one is elected:
+//
+//	db := sql.OpenDB(rqlitestore.NewConnector("http://localhost:4001"))
+//	store := rqlitestore.New(db)
+//
+// The store issues plain SQLite SQL, one statement per request, so it also
+// works through another database/sql driver for rqlite. Migrations run
+// through the same *sql.DB; see Connector for how transactions behave.
+package rqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type RqliteStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Lineage suffixes the store's tables, see golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store         = (*RqliteStore)(nil)
+	_ golumn.ForceUnlocker = (*RqliteStore)(nil)
+	_ golumn.LockInspector = (*RqliteStore)(nil)
+	_ golumn.VersionLister = (*RqliteStore)(nil)
+	_ golumn.TableLister   = (*RqliteStore)(nil)
+)
+
+func New(db *sql.DB) *RqliteStore {
+	return &RqliteStore{instance: db, owner: golumn.NewIdentity()}
+}
+
+func (s *RqliteStore) DB() *sql.DB {
+	return s.instance
+}
+
+// table names one of the store's tables for its lineage.
+func (s *RqliteStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables.
+func (s *RqliteStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_migrations")}
+}
+
+func (s *RqliteStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_lock") + " (id INTEGER PRIMARY KEY CHECK (id = 1), owner TEXT NOT NULL)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (id INTEGER PRIMARY KEY, version_id INTEGER UNIQUE NOT NULL, applied_at DATETIME NOT NULL DEFAULT (datetime('now')))",
+	} {
+		if _, err := s.instance.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RqliteStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_lock")+" (id, owner) VALUES (1, ?)", s.owner)
+	if err == nil {
+		s.held = true
+		s.Log.Debugf("rqlitestore: acquired lock as %s", s.owner)
+		return nil
+	}
+	if isConstraint(err) {
+		return golumn.ErrLocked
+	}
+	return err
+}
+
+// isConstraint reports whether err is a constraint violation, which rqlite
+// relays as SQLite's message, e.g. "UNIQUE constraint failed: schema_lock.id".
+func isConstraint(err error) bool {
+	return strings.Contains(err.Error(), "constraint failed")
+}
+
+func (s *RqliteStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		res, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1 AND owner = ?", s.owner)
+		if err != nil {
+			return err
+		}
+		s.held = false
+
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			s.Log.Debugf("rqlitestore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("rqlitestore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *RqliteStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1"); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("rqlitestore: forcibly released lock")
+	return nil
+}
+
+func (s *RqliteStore) Locked(ctx context.Context) (bool, error) {
+	var n int
+	if err := s.instance.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.table("schema_lock")).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func (s *RqliteStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id DESC LIMIT 1")
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *RqliteStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *RqliteStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id) VALUES (?)", v)
+	return err
+}
+
+func (s *RqliteStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = ?", v)
+	return err
+}
@@ -0,0 +1,277 @@
+package rqlitestore_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/rqlitestore"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestRqliteStore_Conformance runs against the rqlite node at
+// $GOLUMN_RQLITE_URL, e.g. http://localhost:4001.
+func TestRqliteStore_Conformance(t *testing.T) {
+	u := os.Getenv("GOLUMN_RQLITE_URL")
+	if u == "" {
+		t.Skip("GOLUMN_RQLITE_URL is required")
+	}
+	storetest.TestStore(t, func() golumn.Store {
+		db := sql.OpenDB(rqlitestore.NewConnector(u))
+		t.Cleanup(func() { db.Close() })
+		for _, table := range []string{"schema_lock", "schema_migrations"} {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rqlitestore.New(db)
+	})
+}
+
+// fakeNode serves rqlite's /db/execute and /db/query endpoints from a
+// SQLite database.
+type fakeNode struct {
+	db *sql.DB
+}
+
+func newFakeNode(t *testing.T) *httptest.Server {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "db.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	srv := httptest.NewServer(&fakeNode{db: db})
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type fakeResult struct {
+	Columns      []string `json:"columns,omitempty"`
+	Types        []string `json:"types,omitempty"`
+	Values       [][]any  `json:"values,omitempty"`
+	LastInsertID int64    `json:"last_insert_id,omitempty"`
+	RowsAffected int64    `json:"rows_affected,omitempty"`
+	Error        string   `json:"error,omitempty"`
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var stmts [][]any
+	if err := json.NewDecoder(r.Body).Decode(&stmts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	type execer interface {
+		ExecContext(context.Context, string, ...any) (sql.Result, error)
+		QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+	}
+	var q execer = n.db
+	var tx *sql.Tx
+	if r.URL.Query().Get("transaction") == "true" {
+		var err error
+		if tx, err = n.db.Begin(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		q = tx
+	}
+
+	results := make([]fakeResult, 0, len(stmts))
+	for _, stmt := range stmts {
+		query, args := stmt[0].(string), stmt[1:]
+		for i, arg := range args {
+			if f, ok := arg.(float64); ok && f == float64(int64(f)) {
+				args[i] = int64(f)
+			}
+		}
+		var res fakeResult
+		if r.URL.Path == "/db/query" {
+			res = query1(r.Context(), q, query, args)
+		} else if sr, err := q.ExecContext(r.Context(), query, args...); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.LastInsertID, _ = sr.LastInsertId()
+			res.RowsAffected, _ = sr.RowsAffected()
+		}
+		results = append(results, res)
+		if res.Error != "" && tx != nil {
+			break
+		}
+	}
+	if tx != nil {
+		if results[len(results)-1].Error != "" {
+			tx.Rollback()
+		} else if err := tx.Commit(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{"results": results})
+}
+
+func query1(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...any) (*sql.Rows, error)
+}, query string, args []any) fakeResult {
+	var res fakeResult
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	defer rows.Close()
+	res.Columns, _ = rows.Columns()
+	for rows.Next() {
+		vals := make([]any, len(res.Columns))
+		ptrs := make([]any, len(vals))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		for i, v := range vals {
+			if b, ok := v.([]byte); ok {
+				vals[i] = string(b)
+			}
+		}
+		res.Values = append(res.Values, vals)
+	}
+	if err := rows.Err(); err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func openNode(t *testing.T, c *rqlitestore.Connector) *sql.DB {
+	t.Helper()
+	db := sql.OpenDB(c)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRqliteStore_Fake(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		return rqlitestore.New(openNode(t, rqlitestore.NewConnector(newFakeNode(t).URL)))
+	})
+}
+
+func TestRqliteStore_Lineage(t *testing.T) {
+	db := openNode(t, rqlitestore.NewConnector(newFakeNode(t).URL))
+	store := rqlitestore.New(db)
+	store.Lineage = "bad-lineage"
+	if err := store.Init(context.Background()); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+	store.Lineage = "anonymize"
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations_anonymize").Scan(&n); err != nil {
+		t.Errorf("expected the lineage's table to exist: %v", err)
+	}
+}
+
+func TestConnector_FollowsLeader(t *testing.T) {
+	leader := newFakeNode(t)
+	var followerHits atomic.Int32
+	follower := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		followerHits.Add(1)
+		http.Redirect(w, r, leader.URL+r.URL.RequestURI(), http.StatusMovedPermanently)
+	}))
+	defer follower.Close()
+
+	db := openNode(t, rqlitestore.NewConnector(follower.URL))
+	store := rqlitestore.New(db)
+	ctx := context.Background()
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("init through follower failed: %v", err)
+	}
+	if err := store.Insert(ctx, 3); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if v, err := store.Version(ctx); err != nil || v != 3 {
+		t.Errorf("expected version 3, got %d, %v", v, err)
+	}
+	if n := followerHits.Load(); n != 1 {
+		t.Errorf("expected the follower to be asked once before the leader was learned, got %d", n)
+	}
+}
+
+func TestConnector_RetriesWithoutLeader(t *testing.T) {
+	node := newFakeNode(t)
+	var refusals atomic.Int32
+	electing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if refusals.Add(1) <= 2 {
+			http.Error(w, "not leader", http.StatusServiceUnavailable)
+			return
+		}
+		node.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer electing.Close()
+
+	c := rqlitestore.NewConnector(electing.URL)
+	c.RetryDelay = time.Millisecond
+	if err := rqlitestore.New(openNode(t, c)).Init(context.Background()); err != nil {
+		t.Fatalf("expected init to succeed once a leader was elected: %v", err)
+	}
+
+	refusals.Store(-100)
+	c = rqlitestore.NewConnector(electing.URL)
+	c.Retries, c.RetryDelay = 2, time.Millisecond
+	err := rqlitestore.New(openNode(t, c)).Init(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no leader") {
+		t.Errorf("expected a no leader error once retries ran out, got %v", err)
+	}
+}
+
+func TestConnector_Transaction(t *testing.T) {
+	db := openNode(t, rqlitestore.NewConnector(newFakeNode(t).URL))
+	migration := golumn.SQLMigration(1, "0001_items.sql", []string{
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO items (name) VALUES ('a')",
+	}, []string{"DROP TABLE items"})
+	migrator := &golumn.Migrator{Store: rqlitestore.New(db), Sources: []*golumn.Migration{migration}}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	var name string
+	if err := db.QueryRow("SELECT name FROM items").Scan(&name); err != nil || name != "a" {
+		t.Errorf("expected the migration's row, got %q, %v", name, err)
+	}
+
+	// A failed statement rolls back the whole batch.
+	failing := golumn.SQLMigration(2, "0002_bad.sql", []string{
+		"INSERT INTO items (name) VALUES ('b')",
+		"INSERT INTO missing (name) VALUES ('c')",
+	}, nil)
+	migrator.Sources = append(migrator.Sources, failing)
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err == nil || !strings.Contains(err.Error(), "statement 2") {
+		t.Errorf("expected the second statement to fail, got %v", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM items").Scan(&n); err != nil || n != 1 {
+		t.Errorf("expected the batch to be rolled back, got %d rows, %v", n, err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Query("SELECT 1"); err == nil {
+		t.Error("expected a query in a transaction to be refused")
+	}
+}
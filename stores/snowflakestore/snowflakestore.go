@@ -0,0 +1,223 @@
+// Package snowflakestore provides a golumn.Store for Snowflake. It issues
+// plain SQL through database/sql with ?-style parameters and does not
+// import a driver; github.com/snowflakedb/gosnowflake works.
+//
+// Snowflake folds unquoted identifiers to upper case, so the store quotes
+// its tables and columns to keep them lower case like every other store's.
+// It has no advisory locks and does not enforce primary or unique keys, so
+// the store lock is a single row in schema_lock whose owner is claimed with
+// a conditional UPDATE, and Insert only adds a version that is absent. DML
+// on a table is serialized by Snowflake's table locks, which makes both
+// statements atomic.
+package snowflakestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+type SnowflakeStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Lineage suffixes the store's tables, see golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store               = (*SnowflakeStore)(nil)
+	_ golumn.ForceUnlocker       = (*SnowflakeStore)(nil)
+	_ golumn.LockInspector       = (*SnowflakeStore)(nil)
+	_ golumn.VersionLister       = (*SnowflakeStore)(nil)
+	_ golumn.TableLister         = (*SnowflakeStore)(nil)
+	_ golumn.LockHolderInspector = (*SnowflakeStore)(nil)
+)
+
+func New(db *sql.DB) *SnowflakeStore {
+	return &SnowflakeStore{instance: db, owner: golumn.NewIdentity()}
+}
+
+func (s *SnowflakeStore) DB() *sql.DB {
+	return s.instance
+}
+
+// table names one of the store's tables for its lineage.
+func (s *SnowflakeStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables, which must be quoted to be referred to.
+func (s *SnowflakeStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_migrations")}
+}
+
+// Init creates the store's tables and the lock row. The MERGE adds the
+// row only if it is missing, so concurrent calls are safe.
+func (s *SnowflakeStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS "` + s.table("schema_lock") + `" ("id" INTEGER NOT NULL, "owner" VARCHAR, "locked_at" TIMESTAMP_LTZ)`,
+		`CREATE TABLE IF NOT EXISTS "` + s.table("schema_migrations") + `" ("version_id" INTEGER NOT NULL, "applied_at" TIMESTAMP_LTZ NOT NULL DEFAULT CURRENT_TIMESTAMP())`,
+		`MERGE INTO "` + s.table("schema_lock") + `" t USING (SELECT 1 AS "id") s ON t."id" = s."id" WHEN NOT MATCHED THEN INSERT ("id") VALUES (1)`,
+	} {
+		if _, err := s.instance.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lock claims the lock row if it has no owner.
+func (s *SnowflakeStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	res, err := s.instance.ExecContext(ctx, `UPDATE "`+s.table("schema_lock")+`" SET "owner" = ?, "locked_at" = CURRENT_TIMESTAMP() WHERE "id" = 1 AND "owner" IS NULL`, s.owner)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return golumn.ErrLocked
+	}
+	s.held = true
+	s.Log.Debugf("snowflakestore: acquired lock as %s", s.owner)
+	return nil
+}
+
+func (s *SnowflakeStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		res, err := s.instance.ExecContext(ctx, `UPDATE "`+s.table("schema_lock")+`" SET "owner" = NULL, "locked_at" = NULL WHERE "id" = 1 AND "owner" = ?`, s.owner)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		s.held = false
+
+		if n > 0 {
+			s.Log.Debugf("snowflakestore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("snowflakestore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *SnowflakeStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.instance.ExecContext(ctx, `UPDATE "`+s.table("schema_lock")+`" SET "owner" = NULL, "locked_at" = NULL WHERE "id" = 1`); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("snowflakestore: forcibly released lock")
+	return nil
+}
+
+func (s *SnowflakeStore) Locked(ctx context.Context) (bool, error) {
+	var n int64
+	err := s.instance.QueryRowContext(ctx, `SELECT COUNT(*) FROM "`+s.table("schema_lock")+`" WHERE "owner" IS NOT NULL`).Scan(&n)
+	return n > 0, err
+}
+
+// LockHolder returns the owner recorded in the lock row and its locked_at.
+func (s *SnowflakeStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	var owner string
+	var lockedAt sql.NullTime
+	err := s.instance.QueryRowContext(ctx, `SELECT "owner", "locked_at" FROM "`+s.table("schema_lock")+`" WHERE "id" = 1 AND "owner" IS NOT NULL`).Scan(&owner, &lockedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &golumn.LockHolder{Owner: owner, Since: lockedAt.Time}, nil
+}
+
+func (s *SnowflakeStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, `SELECT "version_id" FROM "`+s.table("schema_migrations")+`" ORDER BY "version_id" DESC LIMIT 1`)
+	var version int64
+	if err := row.Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *SnowflakeStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, `SELECT "version_id" FROM "`+s.table("schema_migrations")+`" ORDER BY "version_id"`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// Insert records v as applied, failing if it already is; Snowflake does not
+// enforce unique keys, so the statement checks for itself.
+func (s *SnowflakeStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	res, err := s.instance.ExecContext(ctx, `INSERT INTO "`+s.table("schema_migrations")+`" ("version_id") SELECT ? WHERE NOT EXISTS (SELECT 1 FROM "`+s.table("schema_migrations")+`" WHERE "version_id" = ?)`, v, v)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("version %d already applied", v)
+	}
+	return nil
+}
+
+func (s *SnowflakeStore) Remove(ctx context.Context, v int64) error {
+	_, err := s.instance.ExecContext(ctx, `DELETE FROM "`+s.table("schema_migrations")+`" WHERE "version_id" = ?`, v)
+	return err
+}
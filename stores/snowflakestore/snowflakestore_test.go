@@ -0,0 +1,185 @@
+package snowflakestore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/snowflakestore"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestSnowflakeStore_Conformance runs against the database at
+// $GOLUMN_SNOWFLAKE_DSN with the driver named by
+// $GOLUMN_SNOWFLAKE_DRIVER, which the test binary must have registered,
+// e.g. "snowflake" through a blank import of
+// github.com/snowflakedb/gosnowflake added locally.
+func TestSnowflakeStore_Conformance(t *testing.T) {
+	name, dsn := sqltest.Env(t, "SNOWFLAKE", "")
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, table := range []string{"schema_lock", "schema_migrations"} {
+			if _, err := db.Exec(`DROP TABLE IF EXISTS "` + table + `"`); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return snowflakestore.New(db)
+	})
+}
+
+// fakeServer interprets the store's statements against in-memory tables:
+// the lock rows, each an owner or "" if unowned, and the applied versions.
+type fakeServer struct {
+	sqltest.Server
+	lock    []string
+	applied []int64
+	queries []string
+}
+
+// lockedAt is the locked_at the fake reports for an owned lock row.
+var lockedAt = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query = srv.exec, srv.query
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (srv *fakeServer) exec(_ *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	srv.queries = append(srv.queries, query)
+	n := 0
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+	case strings.HasPrefix(query, `MERGE INTO "schema_lock"`):
+		if len(srv.lock) == 0 {
+			srv.lock = append(srv.lock, "")
+			n = 1
+		}
+	case strings.HasPrefix(query, `UPDATE "schema_lock" SET "owner" = ?`):
+		for i, owner := range srv.lock {
+			if owner == "" {
+				srv.lock[i] = args[0].(string)
+				n++
+			}
+		}
+	case strings.HasPrefix(query, `UPDATE "schema_lock" SET "owner" = NULL`):
+		for i, owner := range srv.lock {
+			if len(args) == 0 || owner == args[0] {
+				srv.lock[i] = ""
+				n++
+			}
+		}
+	case strings.HasPrefix(query, `INSERT INTO "schema_migrations"`):
+		if v := args[0].(int64); !slices.Contains(srv.applied, v) {
+			srv.applied = append(srv.applied, v)
+			n = 1
+		}
+	case strings.HasPrefix(query, `DELETE FROM "schema_migrations"`):
+		srv.applied = slices.DeleteFunc(srv.applied, func(v int64) bool { return v == args[0] })
+	default:
+		return nil, errors.New("unexpected statement: " + query)
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (srv *fakeServer) query(_ *sqltest.Conn, query string, _ []driver.Value) (driver.Rows, error) {
+	srv.queries = append(srv.queries, query)
+	switch {
+	case strings.HasPrefix(query, `SELECT COUNT(*) FROM "schema_lock"`):
+		n := len(slices.DeleteFunc(slices.Clone(srv.lock), func(owner string) bool { return owner == "" }))
+		return sqltest.Value(int64(n)), nil
+	case strings.HasPrefix(query, `SELECT "owner", "locked_at" FROM "schema_lock"`):
+		var rows [][]driver.Value
+		for _, owner := range srv.lock {
+			if owner != "" {
+				rows = append(rows, []driver.Value{owner, lockedAt})
+			}
+		}
+		return sqltest.Rows([]string{"owner", "locked_at"}, rows...), nil
+	case strings.HasPrefix(query, `SELECT "version_id" FROM "schema_migrations"`):
+		versions := slices.Sorted(slices.Values(srv.applied))
+		if strings.Contains(query, "DESC LIMIT 1") {
+			slices.Reverse(versions)
+			versions = versions[:min(len(versions), 1)]
+		}
+		return sqltest.Values(versions...), nil
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func TestSnowflakeStore_Fake(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		return snowflakestore.New(openFake(t, &fakeServer{}))
+	})
+}
+
+func TestSnowflakeStore_InitOnce(t *testing.T) {
+	srv := &fakeServer{}
+	store := snowflakestore.New(openFake(t, srv))
+	ctx := context.Background()
+	for range 2 {
+		if err := store.Init(ctx); err != nil {
+			t.Fatalf("init failed: %v", err)
+		}
+	}
+	if len(srv.lock) != 1 {
+		t.Errorf("expected one lock row, got %d", len(srv.lock))
+	}
+}
+
+func TestSnowflakeStore_QuotedIdentifiers(t *testing.T) {
+	srv := &fakeServer{}
+	store := snowflakestore.New(openFake(t, srv))
+	store.Lineage = "anonymize"
+	// The fake only interprets the default tables, but records every
+	// statement before failing.
+	store.Init(context.Background())
+	if len(srv.queries) != 3 {
+		t.Fatalf("expected 3 statements, got %q", srv.queries)
+	}
+	for _, q := range srv.queries {
+		if !strings.Contains(q, `"schema_lock_anonymize"`) && !strings.Contains(q, `"schema_migrations_anonymize"`) {
+			t.Errorf("expected a quoted lineage table in %q", q)
+		}
+	}
+}
+
+func TestSnowflakeStore_Lineage(t *testing.T) {
+	store := snowflakestore.New(openFake(t, &fakeServer{}))
+	store.Lineage = "bad-lineage"
+	if err := store.Init(context.Background()); err == nil {
+		t.Error("expected an invalid lineage to fail Init")
+	}
+}
+
+func TestSnowflakeStore_LockHolder(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := snowflakestore.New(openFake(t, srv)), snowflakestore.New(openFake(t, srv))
+	ctx := context.Background()
+	if err := a.Init(ctx); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	if holder, err := b.LockHolder(ctx); err != nil || holder != nil {
+		t.Fatalf("expected no holder of a free lock, got %+v (err %v)", holder, err)
+	}
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	holder, err := b.LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if holder == nil || holder.Owner != srv.lock[0] || !holder.Since.Equal(lockedAt) {
+		t.Errorf("expected the lock row's owner and time, got %+v", holder)
+	}
+}
@@ -3,13 +3,27 @@ package sqlite3store_test
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jonathonwebb/golumn"
 	"github.com/jonathonwebb/golumn/stores/sqlite3store"
+	"github.com/jonathonwebb/golumn/storetest"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+func TestSqlite3Store_Conformance(t *testing.T) {
+	storetest.TestStore(t, func() golumn.Store {
+		db := createTestDB(t)
+		t.Cleanup(func() { closeTestDB(t, db) })
+		return sqlite3store.New(db)
+	})
+}
+
 func TestSqlite3Store_New(t *testing.T) {
 	db := createTestDB(t)
 	defer closeTestDB(t, db)
@@ -214,6 +228,165 @@ func TestSqlite3Store_Release(t *testing.T) {
 	}
 }
 
+func TestSqlite3Store_ReleasePolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    golumn.ReleasePolicy
+		setupFunc func(*sqlite3store.Sqlite3Store) error
+		wantErr   error
+		wantLock  bool
+	}{
+		{
+			name:    "ignore_never_locked",
+			policy:  golumn.ReleaseIgnoreUnheld,
+			wantErr: nil,
+		},
+		{
+			name:    "error_never_locked",
+			policy:  golumn.ReleaseErrorUnheld,
+			wantErr: golumn.ErrNotLocked,
+		},
+		{
+			name:   "ignore_double_release",
+			policy: golumn.ReleaseIgnoreUnheld,
+			setupFunc: func(store *sqlite3store.Sqlite3Store) error {
+				if err := store.Lock(context.Background()); err != nil {
+					return err
+				}
+				return store.Release(context.Background())
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "error_double_release",
+			policy: golumn.ReleaseErrorUnheld,
+			setupFunc: func(store *sqlite3store.Sqlite3Store) error {
+				if err := store.Lock(context.Background()); err != nil {
+					return err
+				}
+				return store.Release(context.Background())
+			},
+			wantErr: golumn.ErrNotLocked,
+		},
+		{
+			name:   "error_after_force_unlock",
+			policy: golumn.ReleaseErrorUnheld,
+			setupFunc: func(store *sqlite3store.Sqlite3Store) error {
+				if err := store.Lock(context.Background()); err != nil {
+					return err
+				}
+				return store.ForceUnlock(context.Background())
+			},
+			wantErr: golumn.ErrNotLocked,
+		},
+		{
+			name:   "ignore_lock_held_elsewhere",
+			policy: golumn.ReleaseIgnoreUnheld,
+			setupFunc: func(store *sqlite3store.Sqlite3Store) error {
+				other := sqlite3store.New(store.DB())
+				return other.Lock(context.Background())
+			},
+			wantErr:  nil,
+			wantLock: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := createTestDB(t)
+			defer closeTestDB(t, db)
+
+			store := sqlite3store.New(db)
+			store.ReleasePolicy = tt.policy
+			if err := store.Init(context.Background()); err != nil {
+				t.Fatalf("failed to init store: %v", err)
+			}
+
+			if tt.setupFunc != nil {
+				if err := tt.setupFunc(store); err != nil {
+					t.Fatalf("setup failed: %v", err)
+				}
+			}
+
+			err := store.Release(context.Background())
+			if err != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			var count int
+			if err := db.QueryRow("SELECT COUNT(*) FROM schema_lock WHERE id = 1").Scan(&count); err != nil {
+				t.Errorf("failed to check lock state: %v", err)
+			}
+			if (count == 1) != tt.wantLock {
+				t.Errorf("lock state mismatch: want %v, got %v", tt.wantLock, count == 1)
+			}
+		})
+	}
+}
+
+func TestSqlite3Store_ForceUnlock(t *testing.T) {
+	db := createTestDB(t)
+	defer closeTestDB(t, db)
+
+	holder := sqlite3store.New(db)
+	other := sqlite3store.New(db)
+	if err := holder.Init(context.Background()); err != nil {
+		t.Fatalf("failed to init store: %v", err)
+	}
+
+	if err := holder.Lock(context.Background()); err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	if err := other.Lock(context.Background()); err != golumn.ErrLocked {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	if err := other.ForceUnlock(context.Background()); err != nil {
+		t.Fatalf("failed to force unlock: %v", err)
+	}
+	if err := other.Lock(context.Background()); err != nil {
+		t.Fatalf("should be able to acquire lock after force unlock: %v", err)
+	}
+
+	// The previous holder must not release the lock now owned by other.
+	if err := holder.Release(context.Background()); err != nil {
+		t.Fatalf("unexpected error releasing stale lock: %v", err)
+	}
+	if err := holder.Lock(context.Background()); err != golumn.ErrLocked {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+}
+
+func TestSqlite3Store_Explain(t *testing.T) {
+	db := createTestDB(t)
+	defer closeTestDB(t, db)
+
+	store := sqlite3store.New(db)
+	if _, err := db.Exec("CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+
+	plan, err := store.Explain(context.Background(), "-- backfill\nUPDATE notes SET body = 'x' WHERE body IS NULL")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(plan, "SCAN notes") {
+		t.Errorf("expected a full scan of notes, got %q", plan)
+	}
+
+	if _, err := store.Explain(context.Background(), "CREATE INDEX notes_body ON notes (body)"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for DDL, got %v", err)
+	}
+	if _, err := store.Explain(context.Background(), "SELECT * FROM missing"); err == nil {
+		t.Error("expected error explaining a query on a missing table")
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'notes_body'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("explain must not run statements: %d, %v", n, err)
+	}
+}
+
 func TestSqlite3Store_Version(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -533,6 +706,77 @@ func TestSqlite3Store_Workflow(t *testing.T) {
 	}
 }
 
+func TestSqlite3Store_ConcurrentMigrators(t *testing.T) {
+	const numMigrators = 4
+
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	defer closeTestDB(t, db)
+
+	if _, err := db.Exec("CREATE TABLE applies (version_id INTEGER NOT NULL)"); err != nil {
+		t.Fatalf("failed to create applies table: %v", err)
+	}
+
+	migrations := make([]*golumn.Migration, 10)
+	for i := range migrations {
+		v := int64(i + 1)
+		migrations[i] = &golumn.Migration{
+			Version: v,
+			UpFunc: func(ctx context.Context, db *sql.DB) error {
+				_, err := db.ExecContext(ctx, "INSERT INTO applies (version_id) VALUES (?)", v)
+				return err
+			},
+			DownFunc: func(ctx context.Context, db *sql.DB) error { return nil },
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, numMigrators)
+	for i := range numMigrators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			migrator := &golumn.Migrator{
+				Store:    sqlite3store.New(db),
+				Sources:  migrations,
+				LockWait: 30 * time.Second,
+			}
+			_, errs[i] = migrator.Up(context.Background(), 10)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("migrator %d failed: %v", i, err)
+		}
+	}
+
+	rows, err := db.Query("SELECT version_id, COUNT(*) FROM applies GROUP BY version_id")
+	if err != nil {
+		t.Fatalf("failed to query applies: %v", err)
+	}
+	defer rows.Close()
+
+	seen := 0
+	for rows.Next() {
+		var v, count int64
+		if err := rows.Scan(&v, &count); err != nil {
+			t.Fatalf("failed to scan applies: %v", err)
+		}
+		if count != 1 {
+			t.Errorf("version %d applied %d times, want 1", v, count)
+		}
+		seen++
+	}
+	if seen != len(migrations) {
+		t.Errorf("expected %d versions applied, got %d", len(migrations), seen)
+	}
+}
+
 func TestSqlite3Store_ContextCancellation(t *testing.T) {
 	db := createTestDB(t)
 	defer closeTestDB(t, db)
@@ -617,6 +861,48 @@ func TestSqlite3Store_SchemaValidation(t *testing.T) {
 	}
 }
 
+func TestSqlite3Store_Lineage(t *testing.T) {
+	db := createTestDB(t)
+	defer closeTestDB(t, db)
+	ctx := context.Background()
+
+	main := sqlite3store.New(db)
+	staging := sqlite3store.New(db)
+	staging.Lineage = "anonymize"
+	for _, store := range []*sqlite3store.Sqlite3Store{main, staging} {
+		if err := store.Init(ctx); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+	}
+
+	if err := main.Lock(ctx); err != nil {
+		t.Fatalf("main Lock() error = %v", err)
+	}
+	if err := staging.Lock(ctx); err != nil {
+		t.Fatalf("staging Lock() error = %v, want lineages to lock separately", err)
+	}
+	if err := staging.Insert(ctx, 7); err != nil {
+		t.Fatalf("Insert() error = %v", err)
+	}
+	if _, err := main.Version(ctx); !errors.Is(err, golumn.ErrInitialVersion) {
+		t.Errorf("main Version() error = %v, want ErrInitialVersion", err)
+	}
+	if v, err := staging.Version(ctx); err != nil || v != 7 {
+		t.Errorf("staging Version() = %d, %v, want 7", v, err)
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM schema_migrations_anonymize").Scan(&n); err != nil || n != 1 {
+		t.Errorf("schema_migrations_anonymize rows = %d, %v, want 1", n, err)
+	}
+
+	invalid := sqlite3store.New(db)
+	invalid.Lineage = "x; DROP TABLE schema_migrations"
+	if err := invalid.Init(ctx); err == nil {
+		t.Error("Init() with an invalid lineage succeeded")
+	}
+}
+
 func createTestDB(t *testing.T) *sql.DB {
 	t.Helper()
 
@@ -638,3 +924,41 @@ func closeTestDB(t *testing.T, db *sql.DB) {
 		t.Errorf("failed to close test database: %v", err)
 	}
 }
+
+func TestSqlite3Store_LockHolder(t *testing.T) {
+	db := createTestDB(t)
+	defer closeTestDB(t, db)
+	ctx := context.Background()
+
+	store := sqlite3store.New(db)
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+	if holder, err := store.LockHolder(ctx); err != nil || holder != nil {
+		t.Fatalf("expected no holder of a free lock, got %+v, %v", holder, err)
+	}
+
+	before := time.Now().Add(-time.Second)
+	if err := store.Lock(ctx); err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	holder, err := sqlite3store.New(db).LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("failed to read holder: %v", err)
+	}
+	if holder == nil || holder.Owner == "" || holder.Since.Before(before) || holder.Since.After(time.Now()) {
+		t.Errorf("unexpected holder: %+v", holder)
+	}
+
+	// Locks taken before locked_at was added have no time.
+	if err := store.ForceUnlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO schema_lock (id, owner) VALUES (1, 'legacy')"); err != nil {
+		t.Fatal(err)
+	}
+	holder, err = store.LockHolder(ctx)
+	if err != nil || holder == nil || holder.Owner != "legacy" || !holder.Since.IsZero() {
+		t.Errorf("unexpected legacy holder: %+v, %v", holder, err)
+	}
+}
@@ -0,0 +1,574 @@
+// Package sqlitestore provides a golumn.Store for SQLite. It issues plain
+// SQL through database/sql and does not import a driver, so it works with
+// the pure Go modernc.org/sqlite, for builds without cgo, as well as with
+// github.com/mattn/go-sqlite3. sqlite3store is this store with the latter
+// driver registered.
+package sqlitestore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil"
+)
+
+type SqliteStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// Production flags the store as production, requiring approved runs.
+	Production bool
+	// Lineage suffixes the store's tables, except the database-wide
+	// schema_identity, see golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+	// shared counts this instance's holds of the shared lock.
+	shared int
+}
+
+var (
+	_ golumn.Store               = (*SqliteStore)(nil)
+	_ golumn.ForceUnlocker       = (*SqliteStore)(nil)
+	_ golumn.ProductionStore     = (*SqliteStore)(nil)
+	_ golumn.VersionLister       = (*SqliteStore)(nil)
+	_ golumn.Explainer           = (*SqliteStore)(nil)
+	_ golumn.Annotator           = (*SqliteStore)(nil)
+	_ golumn.CompatRegistry      = (*SqliteStore)(nil)
+	_ golumn.LockInspector       = (*SqliteStore)(nil)
+	_ golumn.LockHolderInspector = (*SqliteStore)(nil)
+	_ golumn.SharedLocker        = (*SqliteStore)(nil)
+	_ golumn.Exporter            = (*SqliteStore)(nil)
+	_ golumn.IdentityStore       = (*SqliteStore)(nil)
+	_ golumn.TableLister         = (*SqliteStore)(nil)
+)
+
+func New(db *sql.DB) *SqliteStore {
+	return &SqliteStore{instance: db, owner: newOwnerID()}
+}
+
+func newOwnerID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *SqliteStore) DB() *sql.DB {
+	return s.instance
+}
+
+func (s *SqliteStore) IsProduction() bool {
+	return s.Production
+}
+
+// table names one of the store's tables for its lineage.
+func (s *SqliteStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables, which schema_identity, shared by all
+// lineages, completes.
+func (s *SqliteStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_shared_locks"), s.table("schema_migrations"), s.table("schema_annotations"), s.table("schema_compat"), "schema_identity"}
+}
+
+func (s *SqliteStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	if err := sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS "+s.table("schema_lock")+" (id INTEGER PRIMARY KEY, owner TEXT)"); err != nil {
+			return err
+		}
+
+		for _, column := range []string{"owner", "locked_at"} {
+			var has int
+			if err := tx.QueryRowContext(tCtx, "SELECT COUNT(*) FROM pragma_table_info('"+s.table("schema_lock")+"') WHERE name = ?", column).Scan(&has); err != nil {
+				return err
+			}
+			if has == 0 {
+				if _, err := tx.ExecContext(tCtx, "ALTER TABLE "+s.table("schema_lock")+" ADD COLUMN "+column+" TEXT"); err != nil {
+					return err
+				}
+			}
+		}
+
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS "+s.table("schema_shared_locks")+" (owner TEXT PRIMARY KEY, locked_at TEXT NOT NULL)"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS "+s.table("schema_migrations")+" (id INTEGER PRIMARY KEY, version_id INTEGER UNIQUE NOT NULL, applied_at DATETIME NOT NULL DEFAULT (datetime('now')))"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS "+s.table("schema_annotations")+" (version_id INTEGER NOT NULL, key TEXT NOT NULL, value TEXT NOT NULL, PRIMARY KEY (version_id, key))"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS "+s.table("schema_compat")+" (app TEXT PRIMARY KEY, min_version INTEGER NOT NULL, max_version INTEGER NOT NULL, registered_at DATETIME NOT NULL DEFAULT (datetime('now')))"); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(tCtx, "CREATE TABLE IF NOT EXISTS schema_identity (id INTEGER PRIMARY KEY CHECK (id = 1), identity TEXT NOT NULL, created_at DATETIME NOT NULL DEFAULT (datetime('now')))"); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SqliteStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	res, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_lock")+" (id, owner, locked_at) SELECT 1, ?, strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE NOT EXISTS (SELECT 1 FROM "+s.table("schema_shared_locks")+")", s.owner)
+	if err != nil {
+		if isConstraint(err) {
+			return golumn.ErrLocked
+		}
+		return err
+	}
+	// No row is inserted while the lock is held shared.
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return golumn.ErrLocked
+	}
+	s.held = true
+	s.Log.Debugf("sqlitestore: acquired lock as %s", s.owner)
+	return nil
+}
+
+// LockShared takes the lock in shared mode, recording this instance as one
+// of its holders unless it already is.
+func (s *SqliteStore) LockShared(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shared > 0 {
+		s.shared++
+		return nil
+	}
+
+	res, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_shared_locks")+" (owner, locked_at) SELECT ?, strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE NOT EXISTS (SELECT 1 FROM "+s.table("schema_lock")+")", s.owner)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return golumn.ErrLocked
+	}
+	s.shared = 1
+	s.Log.Debugf("sqlitestore: acquired shared lock as %s", s.owner)
+	return nil
+}
+
+func (s *SqliteStore) ReleaseShared(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shared > 1 {
+		s.shared--
+		return nil
+	}
+	if s.shared == 1 {
+		res, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_shared_locks")+" WHERE owner = ?", s.owner)
+		if err != nil {
+			return err
+		}
+		s.shared = 0
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 1 {
+			s.Log.Debugf("sqlitestore: released shared lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("sqlitestore: shared lock no longer held by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+// sqliteConstraint is the primary result code of SQLITE_CONSTRAINT.
+const sqliteConstraint = 19
+
+// isConstraint reports whether err is a constraint violation. Drivers
+// report result codes differently: modernc.org/sqlite through a Code
+// method returning the extended code, go-sqlite3 through a struct field,
+// which only the message, e.g. "UNIQUE constraint failed: schema_lock.id",
+// exposes without importing it.
+func isConstraint(err error) bool {
+	var coded interface{ Code() int }
+	if errors.As(err, &coded) {
+		return coded.Code()&0xff == sqliteConstraint
+	}
+	return strings.Contains(err.Error(), "constraint failed")
+}
+
+func (s *SqliteStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		res, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1 AND owner = ?", s.owner)
+		if err != nil {
+			return err
+		}
+		s.held = false
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 1 {
+			s.Log.Debugf("sqlitestore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("sqlitestore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *SqliteStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1"); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_shared_locks"))
+		return err
+	}); err != nil {
+		return err
+	}
+	s.held = false
+	s.shared = 0
+	s.Log.Infof("sqlitestore: forcibly released lock")
+	return nil
+}
+
+func (s *SqliteStore) Locked(ctx context.Context) (bool, error) {
+	var n int
+	if err := s.instance.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+s.table("schema_lock")).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// LockHolder returns the owner of the lock and when it was taken, which is
+// zero for locks taken before the store recorded it.
+func (s *SqliteStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	var owner, lockedAt sql.NullString
+	err := s.instance.QueryRowContext(ctx, "SELECT owner, locked_at FROM "+s.table("schema_lock")+" WHERE id = 1").Scan(&owner, &lockedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	holder := &golumn.LockHolder{Owner: owner.String}
+	if lockedAt.Valid {
+		holder.Since, _ = time.Parse(time.RFC3339, lockedAt.String)
+	}
+	return holder, nil
+}
+
+func (s *SqliteStore) Version(ctx context.Context) (int64, error) {
+	row := s.instance.QueryRowContext(ctx, `SELECT version_id FROM `+s.table("schema_migrations")+` ORDER BY version_id DESC LIMIT 1`)
+	var version int64
+	err := row.Scan(&version)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, err
+}
+
+func (s *SqliteStore) Versions(ctx context.Context) ([]int64, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int64
+	for rows.Next() {
+		var v int64
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func (s *SqliteStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	if _, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_migrations")+" (version_id) VALUES (?)", v); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SqliteStore) Remove(ctx context.Context, v int64) error {
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = ?", v); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_annotations")+" WHERE version_id = ?", v)
+		return err
+	})
+}
+
+func (s *SqliteStore) Annotate(ctx context.Context, v int64, key, value string) error {
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		var applied int
+		if err := tx.QueryRowContext(tCtx, "SELECT COUNT(*) FROM "+s.table("schema_migrations")+" WHERE version_id = ?", v).Scan(&applied); err != nil {
+			return err
+		}
+		if applied == 0 {
+			return golumn.ErrNotApplied
+		}
+		if value == "" {
+			_, err := tx.ExecContext(tCtx, "DELETE FROM "+s.table("schema_annotations")+" WHERE version_id = ? AND key = ?", v, key)
+			return err
+		}
+		_, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_annotations")+" (version_id, key, value) VALUES (?, ?, ?) ON CONFLICT (version_id, key) DO UPDATE SET value = excluded.value", v, key, value)
+		return err
+	})
+}
+
+func (s *SqliteStore) Annotations(ctx context.Context) (map[int64]map[string]string, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT version_id, key, value FROM "+s.table("schema_annotations"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := map[int64]map[string]string{}
+	for rows.Next() {
+		var v int64
+		var key, value string
+		if err := rows.Scan(&v, &key, &value); err != nil {
+			return nil, err
+		}
+		if notes[v] == nil {
+			notes[v] = map[string]string{}
+		}
+		notes[v][key] = value
+	}
+	return notes, rows.Err()
+}
+
+// Identity returns the database's identity, generating it on first use.
+// It belongs to the database, so stores of every lineage share it.
+func (s *SqliteStore) Identity(ctx context.Context) (string, error) {
+	if _, err := s.instance.ExecContext(ctx, "INSERT INTO schema_identity (id, identity) VALUES (1, ?) ON CONFLICT (id) DO NOTHING", golumn.NewIdentity()); err != nil {
+		return "", err
+	}
+	var id string
+	err := s.instance.QueryRowContext(ctx, "SELECT identity FROM schema_identity WHERE id = 1").Scan(&id)
+	return id, err
+}
+
+func (s *SqliteStore) RegisterCompat(ctx context.Context, c golumn.Compat) error {
+	_, err := s.instance.ExecContext(ctx, "INSERT INTO "+s.table("schema_compat")+" (app, min_version, max_version) VALUES (?, ?, ?) ON CONFLICT (app) DO UPDATE SET min_version = excluded.min_version, max_version = excluded.max_version, registered_at = datetime('now')", c.App, c.MinVersion, c.MaxVersion)
+	return err
+}
+
+func (s *SqliteStore) UnregisterCompat(ctx context.Context, app string) error {
+	_, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_compat")+" WHERE app = ?", app)
+	return err
+}
+
+func (s *SqliteStore) Compats(ctx context.Context) ([]golumn.Compat, error) {
+	rows, err := s.instance.QueryContext(ctx, "SELECT app, min_version, max_version, registered_at FROM "+s.table("schema_compat")+" ORDER BY app")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var compats []golumn.Compat
+	for rows.Next() {
+		var c golumn.Compat
+		if err := rows.Scan(&c.App, &c.MinVersion, &c.MaxVersion, &c.Registered); err != nil {
+			return nil, err
+		}
+		compats = append(compats, c)
+	}
+	return compats, rows.Err()
+}
+
+// timeLayout is the format of datetime('now'), used for imported times.
+const timeLayout = "2006-01-02 15:04:05"
+
+func (s *SqliteStore) Export(ctx context.Context, w io.Writer) error {
+	var h golumn.History
+	if err := sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		rows, err := tx.QueryContext(tCtx, "SELECT version_id, applied_at FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		index := map[int64]int{}
+		for rows.Next() {
+			var v golumn.AppliedVersion
+			if err := rows.Scan(&v.Version, &v.AppliedAt); err != nil {
+				return err
+			}
+			index[v.Version] = len(h.Versions)
+			h.Versions = append(h.Versions, v)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(tCtx, "SELECT version_id, key, value FROM "+s.table("schema_annotations")+" ORDER BY version_id, key")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var v int64
+			var key, value string
+			if err := rows.Scan(&v, &key, &value); err != nil {
+				return err
+			}
+			i, ok := index[v]
+			if !ok {
+				continue
+			}
+			if h.Versions[i].Annotations == nil {
+				h.Versions[i].Annotations = map[string]string{}
+			}
+			h.Versions[i].Annotations[key] = value
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		rows, err = tx.QueryContext(tCtx, "SELECT app, min_version, max_version, registered_at FROM "+s.table("schema_compat")+" ORDER BY app")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var c golumn.Compat
+			if err := rows.Scan(&c.App, &c.MinVersion, &c.MaxVersion, &c.Registered); err != nil {
+				return err
+			}
+			h.Compats = append(h.Compats, c)
+		}
+		return rows.Err()
+	}); err != nil {
+		return err
+	}
+	return golumn.WriteHistory(w, &h)
+}
+
+func (s *SqliteStore) Import(ctx context.Context, r io.Reader) error {
+	h, err := golumn.ReadHistory(r)
+	if err != nil {
+		return err
+	}
+	return sqlutil.WithTx(ctx, s.instance, func(tCtx context.Context, tx *sql.Tx) error {
+		for _, table := range []string{s.table("schema_migrations"), s.table("schema_annotations"), s.table("schema_compat")} {
+			if _, err := tx.ExecContext(tCtx, "DELETE FROM "+table); err != nil {
+				return err
+			}
+		}
+		now := time.Now()
+		for _, v := range h.Versions {
+			appliedAt := v.AppliedAt
+			if appliedAt.IsZero() {
+				appliedAt = now
+			}
+			if _, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_migrations")+" (version_id, applied_at) VALUES (?, ?)", v.Version, appliedAt.UTC().Format(timeLayout)); err != nil {
+				return err
+			}
+			for key, value := range v.Annotations {
+				if _, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_annotations")+" (version_id, key, value) VALUES (?, ?, ?)", v.Version, key, value); err != nil {
+					return err
+				}
+			}
+		}
+		for _, c := range h.Compats {
+			registered := c.Registered
+			if registered.IsZero() {
+				registered = now
+			}
+			if _, err := tx.ExecContext(tCtx, "INSERT INTO "+s.table("schema_compat")+" (app, min_version, max_version, registered_at) VALUES (?, ?, ?, ?)", c.App, c.MinVersion, c.MaxVersion, registered.UTC().Format(timeLayout)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Explain returns the EXPLAIN QUERY PLAN of a DML statement, one step per
+// line indented by depth. Other statements return errors.ErrUnsupported.
+func (s *SqliteStore) Explain(ctx context.Context, stmt string) (string, error) {
+	if !sqlutil.IsDML(stmt) {
+		return "", errors.ErrUnsupported
+	}
+	rows, err := s.instance.QueryContext(ctx, "EXPLAIN QUERY PLAN "+stmt)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	depth := map[int]int{}
+	var lines []string
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+			return "", err
+		}
+		depth[id] = depth[parent] + 1
+		lines = append(lines, strings.Repeat("  ", depth[id]-1)+detail)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
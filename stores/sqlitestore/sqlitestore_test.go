@@ -0,0 +1,105 @@
+package sqlitestore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/sqlitestore"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestSqliteStore_Conformance runs with the driver named by
+// $GOLUMN_SQLITE_DRIVER, which the test binary must have registered, e.g.
+// "sqlite" through a blank import of modernc.org/sqlite added locally. The
+// suite runs with go-sqlite3 in sqlite3store.
+func TestSqliteStore_Conformance(t *testing.T) {
+	name := os.Getenv("GOLUMN_SQLITE_DRIVER")
+	if !slices.Contains(sql.Drivers(), name) {
+		t.Skip("a registered GOLUMN_SQLITE_DRIVER is required")
+	}
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, filepath.Join(t.TempDir(), "db.sqlite"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return sqlitestore.New(db)
+	})
+}
+
+// codedError reports an extended result code the way modernc.org/sqlite
+// does.
+type codedError int
+
+func (e codedError) Error() string { return "sqlite error" }
+func (e codedError) Code() int     { return int(e) }
+
+// failing returns a server failing every statement with err.
+func failing(err error) *sqltest.Server {
+	return &sqltest.Server{
+		Exec: func(*sqltest.Conn, string, []driver.Value) (driver.Result, error) {
+			return nil, err
+		},
+		Query: func(*sqltest.Conn, string, []driver.Value) (driver.Rows, error) {
+			return nil, err
+		},
+		Begin: func(*sqltest.Conn) error { return err },
+	}
+}
+
+func TestSqliteStore_LockConstraint(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		err    error
+		locked bool
+	}{
+		// SQLITE_CONSTRAINT_PRIMARYKEY.
+		{"coded constraint", codedError(1555), true},
+		// SQLITE_BUSY.
+		{"coded other", codedError(5), false},
+		{"message", errors.New("UNIQUE constraint failed: schema_lock.id"), true},
+		{"other message", errors.New("database is locked"), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sqlitestore.New(sqltest.Open(t, failing(tt.err))).Lock(context.Background())
+			if locked := errors.Is(err, golumn.ErrLocked); locked != tt.locked {
+				t.Errorf("expected ErrLocked to be %v, got %v", tt.locked, err)
+			}
+		})
+	}
+}
+
+func TestSqliteStore_ExplainDML(t *testing.T) {
+	errQueried := errors.New("queried")
+	for _, tt := range []struct {
+		stmt string
+		dml  bool
+	}{
+		{"SELECT 1", true},
+		{"select\n\t1", true},
+		{"-- note\nUPDATE t SET x = 1", true},
+		{"/* note */\tWITH x AS (SELECT 1) SELECT * FROM x", true},
+		{"SELECT(1)", true},
+		{"CREATE TABLE t (x)", false},
+		{"CREATE\nTABLE t (x)", false},
+		{"SELECTED", false},
+	} {
+		t.Run(tt.stmt, func(t *testing.T) {
+			_, err := sqlitestore.New(sqltest.Open(t, failing(errQueried))).Explain(context.Background(), tt.stmt)
+			if tt.dml && !errors.Is(err, errQueried) {
+				t.Errorf("expected statement to be explained, got %v", err)
+			}
+			if !tt.dml && !errors.Is(err, errors.ErrUnsupported) {
+				t.Errorf("expected ErrUnsupported, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+// Package tidbstore provides a golumn.Store for TiDB. It issues plain SQL
+// through database/sql and does not import a driver; any MySQL driver whose
+// errors begin "Error <number>", such as github.com/go-sql-driver/mysql,
+// works.
+//
+// Unlike mysqlstore it does not use GET_LOCK, which TiDB before 5.4 does not
+// implement, but a row in a lock table, as crdbstore does. TiDB completes
+// DDL asynchronously: a table another TiDB server just created may not yet
+// exist for this one, and statements racing a schema change fail with
+// "Information schema is changed". Init therefore waits for its tables to
+// become visible, and every statement is retried with backoff on schema
+// changes for up to DDLWait.
+package tidbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// DefaultDDLWait is how long statements wait out schema changes unless
+// TiDBStore.DDLWait is set. It is twice TiDB's default schema lease, the
+// longest a schema change takes to reach every server.
+const DefaultDDLWait = 90 * time.Second
+
+// Error numbers TiDB reports for statements that ran against a schema that
+// has since changed, information schema expired and information schema
+// changed, or that has not yet arrived, table doesn't exist. Only Init waits
+// out missing tables; elsewhere they mean the store was never initialized.
+var (
+	schemaChanged = []int{8027, 8028}
+	schemaMissing = []int{1146, 8027, 8028}
+)
+
+// Retry backoff bounds.
+var (
+	retryBackoffMin = 10 * time.Millisecond
+	retryBackoffMax = time.Second
+)
+
+type TiDBStore struct {
+	ReleasePolicy golumn.ReleasePolicy
+	Log           *golumn.Logger
+	// DDLWait is how long a statement failing because of a schema change
+	// still in progress is retried. Zero means DefaultDDLWait; a negative
+	// value disables retries.
+	DDLWait time.Duration
+	// Lineage suffixes the store's tables, see golumn.LineageTable.
+	Lineage string
+
+	instance *sql.DB
+	owner    string
+	mu       sync.Mutex
+	held     bool
+}
+
+var (
+	_ golumn.Store               = (*TiDBStore)(nil)
+	_ golumn.ForceUnlocker       = (*TiDBStore)(nil)
+	_ golumn.LockInspector       = (*TiDBStore)(nil)
+	_ golumn.VersionLister       = (*TiDBStore)(nil)
+	_ golumn.TableLister         = (*TiDBStore)(nil)
+	_ golumn.LockHolderInspector = (*TiDBStore)(nil)
+)
+
+func New(db *sql.DB) *TiDBStore {
+	return &TiDBStore{instance: db, owner: golumn.NewIdentity()}
+}
+
+func (s *TiDBStore) DB() *sql.DB {
+	return s.instance
+}
+
+// table names one of the store's tables for its lineage.
+func (s *TiDBStore) table(name string) string {
+	return golumn.LineageTable(name, s.Lineage)
+}
+
+// Tables names the store's tables.
+func (s *TiDBStore) Tables() []string {
+	return []string{s.table("schema_lock"), s.table("schema_migrations")}
+}
+
+// Init creates the store's tables and waits until they can be read, so
+// that a migrator starting on another TiDB server right after finds them.
+func (s *TiDBStore) Init(ctx context.Context) error {
+	if err := golumn.CheckLineage(s.Lineage); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_lock") + " (id INT PRIMARY KEY, owner VARCHAR(64) NOT NULL, acquired_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+		"CREATE TABLE IF NOT EXISTS " + s.table("schema_migrations") + " (version_id BIGINT PRIMARY KEY, applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+	} {
+		if err := s.exec(ctx, "init", stmt); err != nil {
+			return err
+		}
+	}
+	for _, table := range s.Tables() {
+		err := s.retryOn(ctx, "init", schemaMissing, func() error {
+			rows, err := s.instance.QueryContext(ctx, "SELECT 1 FROM "+table+" LIMIT 1")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TiDBStore) Lock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		return golumn.ErrLocked
+	}
+
+	var n int64
+	err := s.retry(ctx, "lock", func() error {
+		res, err := s.instance.ExecContext(ctx, "INSERT IGNORE INTO "+s.table("schema_lock")+" (id, owner) VALUES (1, ?)", s.owner)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return golumn.ErrLocked
+	}
+	s.held = true
+	s.Log.Debugf("tidbstore: acquired lock as %s", s.owner)
+	return nil
+}
+
+func (s *TiDBStore) Release(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held {
+		var n int64
+		err := s.retry(ctx, "release", func() error {
+			res, err := s.instance.ExecContext(ctx, "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1 AND owner = ?", s.owner)
+			if err != nil {
+				return err
+			}
+			n, err = res.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		s.held = false
+
+		if n == 1 {
+			s.Log.Debugf("tidbstore: released lock as %s", s.owner)
+			return nil
+		}
+		s.Log.Debugf("tidbstore: lock no longer owned by %s", s.owner)
+	}
+
+	if s.ReleasePolicy == golumn.ReleaseErrorUnheld {
+		return golumn.ErrNotLocked
+	}
+	return nil
+}
+
+func (s *TiDBStore) ForceUnlock(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.exec(ctx, "force unlock", "DELETE FROM "+s.table("schema_lock")+" WHERE id = 1"); err != nil {
+		return err
+	}
+	s.held = false
+	s.Log.Infof("tidbstore: forcibly released lock")
+	return nil
+}
+
+func (s *TiDBStore) Locked(ctx context.Context) (bool, error) {
+	var locked bool
+	err := s.retry(ctx, "locked", func() error {
+		return s.instance.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+s.table("schema_lock")+")").Scan(&locked)
+	})
+	return locked, err
+}
+
+// LockHolder returns the owner recorded in the lock row and when it took
+// the lock.
+func (s *TiDBStore) LockHolder(ctx context.Context) (*golumn.LockHolder, error) {
+	var owner string
+	var since int64
+	err := s.retry(ctx, "lock holder", func() error {
+		return s.instance.QueryRowContext(ctx, "SELECT owner, UNIX_TIMESTAMP(acquired_at) FROM "+s.table("schema_lock")+" WHERE id = 1").Scan(&owner, &since)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &golumn.LockHolder{Owner: owner, Since: time.Unix(since, 0)}, nil
+}
+
+func (s *TiDBStore) Version(ctx context.Context) (int64, error) {
+	var version int64
+	err := s.retry(ctx, "version", func() error {
+		return s.instance.QueryRowContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id DESC LIMIT 1").Scan(&version)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, golumn.ErrInitialVersion
+		}
+		return 0, err
+	}
+	return version, nil
+}
+
+func (s *TiDBStore) Versions(ctx context.Context) ([]int64, error) {
+	var versions []int64
+	err := s.retry(ctx, "versions", func() error {
+		versions = nil
+		rows, err := s.instance.QueryContext(ctx, "SELECT version_id FROM "+s.table("schema_migrations")+" ORDER BY version_id")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var v int64
+			if err := rows.Scan(&v); err != nil {
+				return err
+			}
+			versions = append(versions, v)
+		}
+		return rows.Err()
+	})
+	return versions, err
+}
+
+func (s *TiDBStore) Insert(ctx context.Context, v int64) error {
+	if v < 0 {
+		return fmt.Errorf("negative version: %d", v)
+	}
+	return s.exec(ctx, "insert", "INSERT INTO "+s.table("schema_migrations")+" (version_id) VALUES (?)", v)
+}
+
+func (s *TiDBStore) Remove(ctx context.Context, v int64) error {
+	return s.exec(ctx, "remove", "DELETE FROM "+s.table("schema_migrations")+" WHERE version_id = ?", v)
+}
+
+// exec runs a statement, retrying it on schema changes.
+func (s *TiDBStore) exec(ctx context.Context, op, query string, args ...any) error {
+	return s.retry(ctx, op, func() error {
+		_, err := s.instance.ExecContext(ctx, query, args...)
+		return err
+	})
+}
+
+// retry calls fn until it succeeds, fails with an error other than a schema
+// change, or has been retried for DDLWait, backing off exponentially between
+// attempts. A schema change aborts the statement's implicit transaction, so
+// retrying it cannot apply it twice.
+func (s *TiDBStore) retry(ctx context.Context, op string, fn func() error) error {
+	return s.retryOn(ctx, op, schemaChanged, fn)
+}
+
+// retryOn is retry for errors numbered codes.
+func (s *TiDBStore) retryOn(ctx context.Context, op string, codes []int, fn func() error) error {
+	wait := s.DDLWait
+	if wait == 0 {
+		wait = DefaultDDLWait
+	}
+	deadline := time.Now().Add(wait)
+	backoff := retryBackoffMin
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !hasErrorNumber(err, codes) {
+			return err
+		}
+		if wait < 0 || time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("%s: schema still changing after %d retries: %w", op, attempt, err)
+		}
+		s.Log.Debugf("tidbstore: %s: retrying in %s after %v", op, backoff, err)
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+		backoff = min(2*backoff, retryBackoffMax)
+	}
+}
+
+// hasErrorNumber reports whether err, or an error it wraps, is a MySQL
+// protocol error whose number is one of codes.
+func hasErrorNumber(err error, codes []int) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		rest, ok := strings.CutPrefix(err.Error(), "Error ")
+		if !ok {
+			continue
+		}
+		digits, _, _ := strings.Cut(rest, " ")
+		digits = strings.TrimSuffix(digits, ":")
+		if n, err := strconv.Atoi(digits); err == nil && slices.Contains(codes, n) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+//go:build integration
+
+package tidbstore_test
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/tidbstore"
+	"github.com/jonathonwebb/golumn/storetest"
+)
+
+// TestTiDBStore_Conformance runs against the database at $GOLUMN_TIDB_DSN
+// through go-sql-driver/mysql, or the driver named by $GOLUMN_TIDB_DRIVER if
+// it is set. A local container works:
+//
+//	docker run -d -p 4000:4000 pingcap/tidb:latest
+//	GOLUMN_TIDB_DSN='root@tcp(localhost:4000)/test' go test -tags integration ./stores/tidbstore
+func TestTiDBStore_Conformance(t *testing.T) {
+	name, dsn := sqltest.Env(t, "TIDB", "mysql")
+	storetest.TestStore(t, func() golumn.Store {
+		db, err := sql.Open(name, dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, table := range []string{"schema_lock", "schema_migrations"} {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return tidbstore.New(db)
+	})
+}
@@ -0,0 +1,213 @@
+package tidbstore_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+	"github.com/jonathonwebb/golumn/stores/internal/sqlutil/sqltest"
+	"github.com/jonathonwebb/golumn/stores/tidbstore"
+)
+
+// mysqlError formats like the errors of github.com/go-sql-driver/mysql.
+type mysqlError int
+
+func (e mysqlError) Error() string { return fmt.Sprintf("Error %d (HY000): schema error", int(e)) }
+
+// fakeServer records the statements it runs, failing the first failures
+// of them, or of those starting with only if it is set, with err.
+type fakeServer struct {
+	sqltest.Server
+	failures int
+	only     string
+	err      error
+	stmts    []string
+	owner    string
+}
+
+// fail records query and returns the error it should fail with, if any.
+func (srv *fakeServer) fail(query string) error {
+	srv.stmts = append(srv.stmts, query)
+	if srv.failures > 0 && strings.HasPrefix(query, srv.only) {
+		srv.failures--
+		return srv.err
+	}
+	return nil
+}
+
+func openFake(t *testing.T, srv *fakeServer) *sql.DB {
+	srv.Exec, srv.Query = srv.exec, srv.query
+	return sqltest.Open(t, &srv.Server)
+}
+
+func (srv *fakeServer) exec(_ *sqltest.Conn, query string, args []driver.Value) (driver.Result, error) {
+	if err := srv.fail(query); err != nil {
+		return nil, err
+	}
+	switch {
+	case strings.HasPrefix(query, "INSERT IGNORE INTO schema_lock"):
+		if srv.owner != "" {
+			return driver.RowsAffected(0), nil
+		}
+		srv.owner = args[0].(string)
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "DELETE FROM schema_lock"):
+		if srv.owner == "" || len(args) == 1 && srv.owner != args[0].(string) {
+			return driver.RowsAffected(0), nil
+		}
+		srv.owner = ""
+		return driver.RowsAffected(1), nil
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (srv *fakeServer) query(_ *sqltest.Conn, query string, _ []driver.Value) (driver.Rows, error) {
+	if err := srv.fail(query); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(query, "SELECT ") {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	if strings.HasPrefix(query, "SELECT owner, UNIX_TIMESTAMP(acquired_at) FROM schema_lock") {
+		if srv.owner == "" {
+			return sqltest.Rows([]string{"owner", "acquired_at"}), nil
+		}
+		return sqltest.Rows([]string{"owner", "acquired_at"}, []driver.Value{srv.owner, int64(1700000000)}), nil
+	}
+	return sqltest.Rows([]string{"1"}), nil
+}
+
+func TestTiDBStore_Retry(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name string
+		call func(*tidbstore.TiDBStore) error
+	}{
+		{"init", func(s *tidbstore.TiDBStore) error { return s.Init(ctx) }},
+		{"insert", func(s *tidbstore.TiDBStore) error { return s.Insert(ctx, 1) }},
+		{"remove", func(s *tidbstore.TiDBStore) error { return s.Remove(ctx, 1) }},
+		{"lock", func(s *tidbstore.TiDBStore) error { return s.Lock(ctx) }},
+		{"versions", func(s *tidbstore.TiDBStore) error { _, err := s.Versions(ctx); return err }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &fakeServer{failures: 3, err: mysqlError(8028)}
+			if err := tt.call(tidbstore.New(openFake(t, srv))); err != nil {
+				t.Fatalf("expected the schema changes to be retried, got %v", err)
+			}
+			if srv.failures != 0 {
+				t.Errorf("expected all failures to be consumed, %d left", srv.failures)
+			}
+		})
+	}
+}
+
+func TestTiDBStore_InitWaitsForTables(t *testing.T) {
+	ctx := context.Background()
+
+	srv := &fakeServer{failures: 3, only: "SELECT 1 FROM", err: fmt.Errorf("probe: %w", mysqlError(1146))}
+	store := tidbstore.New(openFake(t, srv))
+	store.Lineage = "x"
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("expected init to wait for its tables, got %v", err)
+	}
+	if srv.failures != 0 {
+		t.Errorf("expected all failures to be consumed, %d left", srv.failures)
+	}
+	for _, table := range store.Tables() {
+		if !slices.Contains(srv.stmts, "SELECT 1 FROM "+table+" LIMIT 1") {
+			t.Errorf("expected %s to be probed, got %q", table, srv.stmts)
+		}
+	}
+
+	srv = &fakeServer{failures: 5, err: mysqlError(1146)}
+	store = tidbstore.New(openFake(t, srv))
+	if err := store.Insert(ctx, 1); err == nil || len(srv.stmts) != 1 {
+		t.Errorf("expected a missing table to fail outside init at once, got %v after %d attempts", err, len(srv.stmts))
+	}
+}
+
+func TestTiDBStore_RetryLimits(t *testing.T) {
+	ctx := context.Background()
+
+	srv := &fakeServer{failures: 1000, err: mysqlError(8028)}
+	store := tidbstore.New(openFake(t, srv))
+	store.DDLWait = 50 * time.Millisecond
+	err := store.Insert(ctx, 1)
+	if err == nil || !strings.Contains(err.Error(), "schema still changing") {
+		t.Errorf("expected to give up, got %v", err)
+	}
+
+	srv = &fakeServer{failures: 5, err: mysqlError(8028)}
+	store = tidbstore.New(openFake(t, srv))
+	store.DDLWait = -1
+	if err := store.Insert(ctx, 1); err == nil || len(srv.stmts) != 1 {
+		t.Errorf("expected a negative DDLWait to disable retries, got %v after %d attempts", err, len(srv.stmts))
+	}
+
+	srv = &fakeServer{failures: 5, err: mysqlError(1062)}
+	store = tidbstore.New(openFake(t, srv))
+	if err := store.Insert(ctx, 1); err == nil || len(srv.stmts) != 1 {
+		t.Errorf("expected other errors to fail at once, got %v after %d attempts", err, len(srv.stmts))
+	}
+
+	srv = &fakeServer{failures: 5, err: mysqlError(8028)}
+	store = tidbstore.New(openFake(t, srv))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := store.Insert(cancelled, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation to stop retries, got %v", err)
+	}
+}
+
+func TestTiDBStore_Lock(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := tidbstore.New(openFake(t, srv)), tidbstore.New(openFake(t, srv))
+	ctx := context.Background()
+
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	if err := b.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+		t.Errorf("expected ErrLocked from another store, got %v", err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("release failed: %v", err)
+	}
+	if err := b.Lock(ctx); err != nil {
+		t.Fatalf("lock after release failed: %v", err)
+	}
+	if err := a.ForceUnlock(ctx); err != nil {
+		t.Fatalf("force unlock failed: %v", err)
+	}
+
+	b.ReleasePolicy = golumn.ReleaseErrorUnheld
+	if err := b.Release(ctx); !errors.Is(err, golumn.ErrNotLocked) {
+		t.Errorf("expected ErrNotLocked after force unlock, got %v", err)
+	}
+}
+
+func TestTiDBStore_LockHolder(t *testing.T) {
+	srv := &fakeServer{}
+	a, b := tidbstore.New(openFake(t, srv)), tidbstore.New(openFake(t, srv))
+	ctx := context.Background()
+	if holder, err := b.LockHolder(ctx); err != nil || holder != nil {
+		t.Fatalf("expected no holder of a free lock, got %+v (err %v)", holder, err)
+	}
+	if err := a.Lock(ctx); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	holder, err := b.LockHolder(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if holder == nil || holder.Owner != srv.owner || !holder.Since.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("expected the lock row's owner and time, got %+v", holder)
+	}
+}
@@ -0,0 +1,487 @@
+// Package storetest provides a conformance suite for golumn.Store
+// implementations.
+package storetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// TestStore runs the conformance suite against stores returned by newStore.
+// Each call to newStore must return a store backed by fresh, empty state that
+// has not yet been initialized.
+func TestStore(t *testing.T, newStore func() golumn.Store) {
+	t.Helper()
+
+	t.Run("init_idempotent", func(t *testing.T) {
+		store := newStore()
+		for i := range 2 {
+			if err := store.Init(context.Background()); err != nil {
+				t.Fatalf("init %d failed: %v", i+1, err)
+			}
+		}
+	})
+
+	t.Run("initial_version", func(t *testing.T) {
+		store := initStore(t, newStore)
+		if _, err := store.Version(context.Background()); !errors.Is(err, golumn.ErrInitialVersion) {
+			t.Errorf("expected ErrInitialVersion, got %v", err)
+		}
+	})
+
+	t.Run("lock_contention", func(t *testing.T) {
+		store := initStore(t, newStore)
+		if err := store.Lock(context.Background()); err != nil {
+			t.Fatalf("failed to acquire lock: %v", err)
+		}
+		if err := store.Lock(context.Background()); !errors.Is(err, golumn.ErrLocked) {
+			t.Errorf("expected ErrLocked, got %v", err)
+		}
+		if err := store.Release(context.Background()); err != nil {
+			t.Fatalf("failed to release lock: %v", err)
+		}
+		if err := store.Lock(context.Background()); err != nil {
+			t.Errorf("should be able to acquire lock after release: %v", err)
+		}
+		if err := store.Release(context.Background()); err != nil {
+			t.Errorf("failed to release lock again: %v", err)
+		}
+	})
+
+	t.Run("release_unheld", func(t *testing.T) {
+		store := initStore(t, newStore)
+		if err := store.Release(context.Background()); err != nil && !errors.Is(err, golumn.ErrNotLocked) {
+			t.Errorf("expected nil or ErrNotLocked, got %v", err)
+		}
+
+		if err := store.Lock(context.Background()); err != nil {
+			t.Fatalf("failed to acquire lock: %v", err)
+		}
+		if err := store.Release(context.Background()); err != nil {
+			t.Fatalf("failed to release lock: %v", err)
+		}
+		if err := store.Release(context.Background()); err != nil && !errors.Is(err, golumn.ErrNotLocked) {
+			t.Errorf("expected nil or ErrNotLocked on double release, got %v", err)
+		}
+		if err := store.Lock(context.Background()); err != nil {
+			t.Errorf("double release should leave the lock acquirable: %v", err)
+		}
+	})
+
+	t.Run("force_unlock", func(t *testing.T) {
+		store := initStore(t, newStore)
+		unlocker, ok := store.(golumn.ForceUnlocker)
+		if !ok {
+			t.Skip("store does not implement golumn.ForceUnlocker")
+		}
+
+		if err := store.Lock(context.Background()); err != nil {
+			t.Fatalf("failed to acquire lock: %v", err)
+		}
+		if err := unlocker.ForceUnlock(context.Background()); err != nil {
+			t.Fatalf("failed to force unlock: %v", err)
+		}
+		if err := store.Release(context.Background()); err != nil && !errors.Is(err, golumn.ErrNotLocked) {
+			t.Errorf("expected nil or ErrNotLocked after force unlock, got %v", err)
+		}
+		if err := store.Lock(context.Background()); err != nil {
+			t.Errorf("should be able to acquire lock after force unlock: %v", err)
+		}
+	})
+
+	t.Run("inspect_lock", func(t *testing.T) {
+		store := initStore(t, newStore)
+		inspector, ok := store.(golumn.LockInspector)
+		if !ok {
+			t.Skip("store does not implement golumn.LockInspector")
+		}
+
+		wantLocked := func(want bool) {
+			t.Helper()
+			if locked, err := inspector.Locked(context.Background()); err != nil || locked != want {
+				t.Errorf("expected locked %v, got %v (err %v)", want, locked, err)
+			}
+		}
+		wantLocked(false)
+		if err := store.Lock(context.Background()); err != nil {
+			t.Fatalf("failed to acquire lock: %v", err)
+		}
+		wantLocked(true)
+		if err := store.Release(context.Background()); err != nil {
+			t.Fatalf("failed to release lock: %v", err)
+		}
+		wantLocked(false)
+	})
+
+	t.Run("shared_lock", func(t *testing.T) {
+		store := initStore(t, newStore)
+		locker, ok := store.(golumn.SharedLocker)
+		if !ok {
+			t.Skip("store does not implement golumn.SharedLocker")
+		}
+		ctx := context.Background()
+
+		for i := range 2 {
+			if err := locker.LockShared(ctx); err != nil {
+				t.Fatalf("failed to acquire shared lock %d: %v", i+1, err)
+			}
+		}
+		if inspector, ok := store.(golumn.LockInspector); ok {
+			if locked, err := inspector.Locked(ctx); err != nil || locked {
+				t.Errorf("expected a shared lock not to be reported as locked, got %v (err %v)", locked, err)
+			}
+		}
+		if err := store.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+			t.Errorf("expected ErrLocked while held shared, got %v", err)
+		}
+		if err := locker.ReleaseShared(ctx); err != nil {
+			t.Fatalf("failed to release shared lock: %v", err)
+		}
+		if err := store.Lock(ctx); !errors.Is(err, golumn.ErrLocked) {
+			t.Errorf("expected ErrLocked while still held shared once, got %v", err)
+		}
+		if err := locker.ReleaseShared(ctx); err != nil {
+			t.Fatalf("failed to release shared lock again: %v", err)
+		}
+
+		if err := store.Lock(ctx); err != nil {
+			t.Fatalf("failed to acquire lock after shared release: %v", err)
+		}
+		if err := locker.LockShared(ctx); !errors.Is(err, golumn.ErrLocked) {
+			t.Errorf("expected ErrLocked while held exclusively, got %v", err)
+		}
+		if err := store.Release(ctx); err != nil {
+			t.Fatalf("failed to release lock: %v", err)
+		}
+		if err := locker.LockShared(ctx); err != nil {
+			t.Errorf("failed to acquire shared lock after release: %v", err)
+		}
+
+		if unlocker, ok := store.(golumn.ForceUnlocker); ok {
+			if err := unlocker.ForceUnlock(ctx); err != nil {
+				t.Fatalf("failed to force unlock: %v", err)
+			}
+			if err := store.Lock(ctx); err != nil {
+				t.Errorf("expected force unlock to clear the shared lock: %v", err)
+			}
+		}
+	})
+
+	t.Run("version_ordering", func(t *testing.T) {
+		store := initStore(t, newStore)
+		for _, v := range []int64{1, 3, 2, 5, 4} {
+			if err := store.Insert(context.Background(), v); err != nil {
+				t.Fatalf("failed to insert version %d: %v", v, err)
+			}
+		}
+		wantVersion(t, store, 5)
+	})
+
+	t.Run("list_versions", func(t *testing.T) {
+		store := initStore(t, newStore)
+		lister, ok := store.(golumn.VersionLister)
+		if !ok {
+			t.Skip("store does not implement golumn.VersionLister")
+		}
+
+		if versions, err := lister.Versions(context.Background()); err != nil || len(versions) != 0 {
+			t.Fatalf("expected no versions, got %v (err %v)", versions, err)
+		}
+		for _, v := range []int64{3, 1, 2} {
+			if err := store.Insert(context.Background(), v); err != nil {
+				t.Fatalf("failed to insert version %d: %v", v, err)
+			}
+		}
+		if err := store.Remove(context.Background(), 2); err != nil {
+			t.Fatalf("failed to remove version 2: %v", err)
+		}
+
+		versions, err := lister.Versions(context.Background())
+		if err != nil {
+			t.Fatalf("failed to list versions: %v", err)
+		}
+		if !slices.Equal(versions, []int64{1, 3}) {
+			t.Errorf("expected versions [1 3], got %v", versions)
+		}
+	})
+
+	t.Run("insert_duplicate", func(t *testing.T) {
+		store := initStore(t, newStore)
+		if err := store.Insert(context.Background(), 1); err != nil {
+			t.Fatalf("failed to insert version: %v", err)
+		}
+		if err := store.Insert(context.Background(), 1); err == nil {
+			t.Error("expected error inserting duplicate version")
+		}
+		wantVersion(t, store, 1)
+	})
+
+	t.Run("insert_zero", func(t *testing.T) {
+		store := initStore(t, newStore)
+		if err := store.Insert(context.Background(), 0); err != nil {
+			t.Fatalf("failed to insert version 0: %v", err)
+		}
+		wantVersion(t, store, 0)
+	})
+
+	t.Run("insert_negative", func(t *testing.T) {
+		store := initStore(t, newStore)
+		if err := store.Insert(context.Background(), golumn.Initial); err == nil {
+			t.Error("expected error inserting a negative version")
+		}
+		if _, err := store.Version(context.Background()); !errors.Is(err, golumn.ErrInitialVersion) {
+			t.Errorf("expected ErrInitialVersion, got %v", err)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		store := initStore(t, newStore)
+		for _, v := range []int64{1, 2, 3} {
+			if err := store.Insert(context.Background(), v); err != nil {
+				t.Fatalf("failed to insert version %d: %v", v, err)
+			}
+		}
+
+		if err := store.Remove(context.Background(), 5); err != nil {
+			t.Errorf("removing an unknown version should not fail: %v", err)
+		}
+		wantVersion(t, store, 3)
+
+		if err := store.Remove(context.Background(), 2); err != nil {
+			t.Fatalf("failed to remove version 2: %v", err)
+		}
+		wantVersion(t, store, 3)
+
+		if err := store.Remove(context.Background(), 3); err != nil {
+			t.Fatalf("failed to remove version 3: %v", err)
+		}
+		wantVersion(t, store, 1)
+
+		if err := store.Remove(context.Background(), 1); err != nil {
+			t.Fatalf("failed to remove version 1: %v", err)
+		}
+		if _, err := store.Version(context.Background()); !errors.Is(err, golumn.ErrInitialVersion) {
+			t.Errorf("expected ErrInitialVersion after removing all versions, got %v", err)
+		}
+	})
+
+	t.Run("annotate", func(t *testing.T) {
+		store := initStore(t, newStore)
+		annotator, ok := store.(golumn.Annotator)
+		if !ok {
+			t.Skip("store does not implement golumn.Annotator")
+		}
+
+		if err := annotator.Annotate(context.Background(), 1, "note", "x"); !errors.Is(err, golumn.ErrNotApplied) {
+			t.Errorf("expected ErrNotApplied annotating an unapplied version, got %v", err)
+		}
+		for _, v := range []int64{1, 2} {
+			if err := store.Insert(context.Background(), v); err != nil {
+				t.Fatalf("failed to insert version %d: %v", v, err)
+			}
+		}
+		for _, note := range []struct {
+			version    int64
+			key, value string
+		}{
+			{1, "note", "first"},
+			{1, "note", "second"},
+			{1, "ticket", "OPS-1"},
+			{1, "ticket", ""},
+			{2, "note", "removed"},
+		} {
+			if err := annotator.Annotate(context.Background(), note.version, note.key, note.value); err != nil {
+				t.Fatalf("failed to annotate version %d: %v", note.version, err)
+			}
+		}
+		if err := store.Remove(context.Background(), 2); err != nil {
+			t.Fatalf("failed to remove version 2: %v", err)
+		}
+
+		notes, err := annotator.Annotations(context.Background())
+		if err != nil {
+			t.Fatalf("failed to list annotations: %v", err)
+		}
+		if len(notes) != 1 || len(notes[1]) != 1 || notes[1]["note"] != "second" {
+			t.Errorf("expected {1: {note: second}}, got %v", notes)
+		}
+	})
+
+	t.Run("compat_registry", func(t *testing.T) {
+		store := initStore(t, newStore)
+		registry, ok := store.(golumn.CompatRegistry)
+		if !ok {
+			t.Skip("store does not implement golumn.CompatRegistry")
+		}
+
+		for _, c := range []golumn.Compat{
+			{App: "api", MinVersion: 1, MaxVersion: 2},
+			{App: "api", MinVersion: 3, MaxVersion: golumn.Latest},
+			{App: "worker", MinVersion: golumn.Initial, MaxVersion: 5},
+			{App: "cron", MinVersion: 0, MaxVersion: 0},
+		} {
+			if err := registry.RegisterCompat(context.Background(), c); err != nil {
+				t.Fatalf("failed to register %s: %v", c.App, err)
+			}
+		}
+		if err := registry.UnregisterCompat(context.Background(), "cron"); err != nil {
+			t.Fatalf("failed to unregister cron: %v", err)
+		}
+		if err := registry.UnregisterCompat(context.Background(), "unknown"); err != nil {
+			t.Errorf("unregistering an unknown app should not fail: %v", err)
+		}
+
+		compats, err := registry.Compats(context.Background())
+		if err != nil {
+			t.Fatalf("failed to list registrations: %v", err)
+		}
+		slices.SortFunc(compats, func(a, b golumn.Compat) int { return strings.Compare(a.App, b.App) })
+		if len(compats) != 2 ||
+			compats[0].App != "api" || compats[0].MinVersion != 3 || compats[0].MaxVersion != golumn.Latest ||
+			compats[1].App != "worker" || compats[1].MinVersion != golumn.Initial || compats[1].MaxVersion != 5 {
+			t.Errorf("unexpected registrations: %+v", compats)
+		}
+	})
+
+	t.Run("identity", func(t *testing.T) {
+		store := initStore(t, newStore)
+		is, ok := store.(golumn.IdentityStore)
+		if !ok {
+			t.Skip("store does not implement golumn.IdentityStore")
+		}
+
+		id, err := is.Identity(context.Background())
+		if err != nil {
+			t.Fatalf("failed to get identity: %v", err)
+		}
+		if id == "" {
+			t.Fatal("expected a non-empty identity")
+		}
+		if err := store.Init(context.Background()); err != nil {
+			t.Fatalf("init failed: %v", err)
+		}
+		if again, err := is.Identity(context.Background()); err != nil || again != id {
+			t.Errorf("expected identity %q to be stable, got %q (err %v)", id, again, err)
+		}
+	})
+
+	t.Run("export_import", func(t *testing.T) {
+		store := initStore(t, newStore)
+		exporter, ok := store.(golumn.Exporter)
+		if !ok {
+			t.Skip("store does not implement golumn.Exporter")
+		}
+
+		for _, v := range []int64{1, 2, 3} {
+			if err := store.Insert(context.Background(), v); err != nil {
+				t.Fatalf("failed to insert version %d: %v", v, err)
+			}
+		}
+		if annotator, ok := store.(golumn.Annotator); ok {
+			if err := annotator.Annotate(context.Background(), 2, "ticket", "OPS-2"); err != nil {
+				t.Fatalf("failed to annotate version 2: %v", err)
+			}
+		}
+		var buf bytes.Buffer
+		if err := exporter.Export(context.Background(), &buf); err != nil {
+			t.Fatalf("failed to export: %v", err)
+		}
+		exported := buf.String()
+
+		if err := store.Remove(context.Background(), 3); err != nil {
+			t.Fatalf("failed to remove version 3: %v", err)
+		}
+		if err := store.Insert(context.Background(), 4); err != nil {
+			t.Fatalf("failed to insert version 4: %v", err)
+		}
+		if err := exporter.Import(context.Background(), strings.NewReader(exported)); err != nil {
+			t.Fatalf("failed to import: %v", err)
+		}
+		wantVersion(t, store, 3)
+		if lister, ok := store.(golumn.VersionLister); ok {
+			versions, err := lister.Versions(context.Background())
+			if err != nil {
+				t.Fatalf("failed to list versions: %v", err)
+			}
+			slices.Sort(versions)
+			if !slices.Equal(versions, []int64{1, 2, 3}) {
+				t.Errorf("expected versions [1 2 3] after import, got %v", versions)
+			}
+		}
+		if annotator, ok := store.(golumn.Annotator); ok {
+			notes, err := annotator.Annotations(context.Background())
+			if err != nil {
+				t.Fatalf("failed to list annotations: %v", err)
+			}
+			if notes[2]["ticket"] != "OPS-2" {
+				t.Errorf("expected annotation to survive import, got %v", notes)
+			}
+		}
+
+		buf.Reset()
+		if err := exporter.Export(context.Background(), &buf); err != nil {
+			t.Fatalf("failed to export after import: %v", err)
+		}
+		if buf.String() != exported {
+			t.Errorf("export after import differs:\n%s\nwant:\n%s", buf.String(), exported)
+		}
+
+		if err := exporter.Import(context.Background(), strings.NewReader(`{"schema":"unknown"}`)); err == nil {
+			t.Error("expected an error importing an unknown schema")
+		}
+		wantVersion(t, store, 3)
+	})
+
+	t.Run("context_cancellation", func(t *testing.T) {
+		store := initStore(t, newStore)
+
+		ops := []struct {
+			name string
+			op   func(context.Context) error
+		}{
+			{"init", store.Init},
+			{"lock", store.Lock},
+			{"release", store.Release},
+			{"version", func(ctx context.Context) error { _, err := store.Version(ctx); return err }},
+			{"insert", func(ctx context.Context) error { return store.Insert(ctx, 1) }},
+			{"remove", func(ctx context.Context) error { return store.Remove(ctx, 1) }},
+		}
+
+		for _, tt := range ops {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+
+				if err := tt.op(ctx); err == nil {
+					t.Error("expected error due to cancelled context")
+				}
+			})
+		}
+	})
+}
+
+func initStore(t *testing.T, newStore func() golumn.Store) golumn.Store {
+	t.Helper()
+	store := newStore()
+	if err := store.Init(context.Background()); err != nil {
+		t.Fatalf("failed to init store: %v", err)
+	}
+	return store
+}
+
+func wantVersion(t *testing.T, store golumn.Store, want int64) {
+	t.Helper()
+	got, err := store.Version(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get version: %v", err)
+	}
+	if got != want {
+		t.Errorf("expected version %d, got %d", want, got)
+	}
+}
@@ -0,0 +1,32 @@
+package golumn
+
+import (
+	"context"
+	"fmt"
+)
+
+type targetKey struct{}
+
+// storeTarget returns the Target of s, or nil if s is not a Targeter.
+func storeTarget(s Store) any {
+	if t, ok := s.(Targeter); ok {
+		return t.Target()
+	}
+	return nil
+}
+
+// withTarget returns ctx carrying target, the Target of the run's store, for
+// migrations setting UpTarget and DownTarget.
+func withTarget(ctx context.Context, target any) context.Context {
+	return context.WithValue(ctx, targetKey{}, target)
+}
+
+// runTarget calls fn, m's UpTarget or DownTarget, with the target carried by
+// ctx.
+func (m *Migration) runTarget(ctx context.Context, fn func(context.Context, any) error) error {
+	target := ctx.Value(targetKey{})
+	if target == nil {
+		return fmt.Errorf("migration %d: store has no migration target", m.Version)
+	}
+	return fn(ctx, target)
+}
@@ -0,0 +1,48 @@
+package golumn_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+// targetStore is a fakeStore whose migrations run against target.
+type targetStore struct {
+	*fakeStore
+	target any
+}
+
+func (s *targetStore) Target() any { return s.target }
+
+func targetMigration(version int64, got *[]any) *golumn.Migration {
+	return &golumn.Migration{
+		Version: version,
+		UpTarget: func(_ context.Context, target any) error {
+			*got = append(*got, target)
+			return nil
+		},
+	}
+}
+
+func TestMigrator_Target(t *testing.T) {
+	var got []any
+	migrator := &golumn.Migrator{
+		Store:   &targetStore{&fakeStore{}, "db"},
+		Sources: []*golumn.Migration{targetMigration(1, &got)},
+	}
+	if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if len(got) != 1 || got[0] != "db" {
+		t.Errorf("expected the migration to receive the store's target, got %v", got)
+	}
+
+	for _, store := range []golumn.Store{&fakeStore{}, &targetStore{&fakeStore{}, nil}} {
+		migrator := &golumn.Migrator{Store: store, Sources: []*golumn.Migration{targetMigration(1, &got)}}
+		if err := migrator.Validate(); err == nil || !strings.Contains(err.Error(), "migration target") {
+			t.Errorf("expected a store without a target to be refused, got %v", err)
+		}
+	}
+}
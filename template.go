@@ -0,0 +1,99 @@
+package golumn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// templateDirective opts a .sql or .lua migration into preprocessing. It is
+// a comment in both formats.
+const templateDirective = sqlDirectivePrefix + "Template"
+
+// Preprocess expands a migration source containing a "-- +golumn Template"
+// line as a text/template, and returns any other source unchanged. Templates
+// can call:
+//
+//	env "NAME"              the variable NAME, failing if it is undefined
+//	default "NAME" "value"  the variable NAME, or value if it is undefined
+//	iif cond a b            a if cond is true, otherwise b
+//
+// Variables are looked up in vars, then in the process environment. Errors
+// are *SourceError values for name; line numbers in later parse and
+// execution errors refer to the expanded source.
+func Preprocess(src []byte, name string, vars map[string]string) ([]byte, error) {
+	if !hasTemplateDirective(src) {
+		return src, nil
+	}
+
+	lookup := func(key string) (string, bool) {
+		if v, ok := vars[key]; ok {
+			return v, true
+		}
+		return os.LookupEnv(key)
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"env": func(key string) (string, error) {
+			v, ok := lookup(key)
+			if !ok {
+				return "", fmt.Errorf("undefined variable %q", key)
+			}
+			return v, nil
+		},
+		"default": func(key, fallback string) string {
+			if v, ok := lookup(key); ok {
+				return v
+			}
+			return fallback
+		},
+		"iif": func(cond bool, a, b any) any {
+			if cond {
+				return a
+			}
+			return b
+		},
+	}).Parse(string(src))
+	if err != nil {
+		return nil, templateError(name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, templateError(name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func hasTemplateDirective(src []byte) bool {
+	for line := range bytes.Lines(bytes.TrimPrefix(src, utf8BOM)) {
+		if strings.TrimSpace(string(line)) == templateDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// templateError converts a text/template error, which reads
+// "template: name:line[:col]: message", to a *SourceError.
+func templateError(name string, err error) error {
+	rest, ok := strings.CutPrefix(err.Error(), "template: "+name+":")
+	if !ok {
+		return &SourceError{File: name, Err: err}
+	}
+	lineStr, msg, ok := strings.Cut(rest, ":")
+	line, convErr := strconv.Atoi(lineStr)
+	if !ok || convErr != nil {
+		return &SourceError{File: name, Err: err}
+	}
+	// Execution errors also carry a column and the failing action.
+	if col, after, ok := strings.Cut(msg, ":"); ok {
+		if _, err := strconv.Atoi(col); err == nil {
+			msg = after
+		}
+	}
+	return &SourceError{File: name, Line: line, Err: errors.New(strings.TrimSpace(msg))}
+}
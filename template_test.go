@@ -0,0 +1,103 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestPreprocess(t *testing.T) {
+	t.Setenv("GOLUMN_TEST_ENV", "prod")
+	vars := map[string]string{"SCHEMA": "app"}
+
+	tests := []struct {
+		name     string
+		src      string
+		want     string
+		wantErr  string
+		wantLine int
+	}{
+		{
+			name: "untemplated",
+			src:  "-- +golumn Up\nSELECT '{{ env \"SCHEMA\" }}';\n",
+			want: "-- +golumn Up\nSELECT '{{ env \"SCHEMA\" }}';\n",
+		},
+		{
+			name: "vars_and_environment",
+			src:  "-- +golumn Template\nCREATE TABLE {{ env \"SCHEMA\" }}.t ({{ iif (eq (env \"GOLUMN_TEST_ENV\") \"prod\") \"id BIGINT\" \"id INTEGER\" }});\n",
+			want: "-- +golumn Template\nCREATE TABLE app.t (id BIGINT);\n",
+		},
+		{
+			name: "default",
+			src:  "-- +golumn Template\n{{ default \"SCHEMA\" \"public\" }} {{ default \"GOLUMN_TEST_MISSING\" \"public\" }}\n",
+			want: "-- +golumn Template\napp public\n",
+		},
+		{
+			name:     "undefined_variable",
+			src:      "-- +golumn Template\n\n{{ env \"GOLUMN_TEST_MISSING\" }}\n",
+			wantErr:  `undefined variable "GOLUMN_TEST_MISSING"`,
+			wantLine: 3,
+		},
+		{
+			name:     "unknown_function",
+			src:      "-- +golumn Template\n{{ upper \"x\" }}\n",
+			wantErr:  `function "upper" not defined`,
+			wantLine: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := golumn.Preprocess([]byte(tt.src), "0001_t.sql", vars)
+			if tt.wantErr != "" {
+				var se *golumn.SourceError
+				if !errors.As(err, &se) {
+					t.Fatalf("expected *SourceError, got %v", err)
+				}
+				if se.File != "0001_t.sql" || se.Line != tt.wantLine || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("got %v (line %d), want %q at line %d", err, se.Line, tt.wantErr, tt.wantLine)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobLoader_Template(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"0001_a.sql": "-- +golumn Template\n-- +golumn Up\nCREATE TABLE {{ env \"TABLE\" }} (id INTEGER);\n",
+		"0002_b.lua": "-- +golumn Template\nVersion={{ env \"VERSION\" }}\n",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, lazy := range []bool{false, true} {
+		loader := golumn.GlobLoader{Pattern: filepath.Join(dir, "*"), Lazy: lazy, Vars: map[string]string{"TABLE": "notes", "VERSION": "2"}}
+		migrations, err := loader.Load(context.Background())
+		if err != nil {
+			t.Fatalf("lazy=%v: unexpected error: %v", lazy, err)
+		}
+		if len(migrations) != 2 || migrations[1].Version != 2 {
+			t.Fatalf("lazy=%v: unexpected migrations: %v", lazy, migrations)
+		}
+	}
+
+	loader := golumn.GlobLoader{Pattern: filepath.Join(dir, "*")}
+	if _, err := loader.Load(context.Background()); err == nil || !strings.Contains(err.Error(), "undefined variable") {
+		t.Errorf("expected undefined variable error, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrMigrationTimeout matches the error of a migration that ran past its
+// MaxDuration.
+var ErrMigrationTimeout = errors.New("migration exceeded its max duration")
+
+// timeoutError is the failure of a migration cancelled at its MaxDuration.
+type timeoutError struct {
+	limit time.Duration
+	err   error
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("exceeded max duration of %s: %v", e.limit, e.err)
+}
+
+func (e *timeoutError) Unwrap() []error {
+	return []error{e.err, ErrMigrationTimeout}
+}
+
+// parseMaxDuration parses the MaxDuration of a migration source.
+func parseMaxDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid MaxDuration %q, want a positive duration such as 5m", s)
+	}
+	return d, nil
+}
+
+// withMaxDuration calls fn with ctx bounded by migration's MaxDuration, if
+// it has one, marking the error of a call cut short by the deadline, rather
+// than by ctx, as a timeout.
+func withMaxDuration(ctx context.Context, migration *Migration, fn func(context.Context) error) error {
+	if migration.MaxDuration <= 0 {
+		return fn(ctx)
+	}
+	bounded, cancel := context.WithTimeout(ctx, migration.MaxDuration)
+	defer cancel()
+	err := fn(bounded)
+	if err != nil && ctx.Err() == nil && errors.Is(bounded.Err(), context.DeadlineExceeded) {
+		return &timeoutError{limit: migration.MaxDuration, err: err}
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package golumn_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestParse_MaxDuration(t *testing.T) {
+	sqlM, err := golumn.ParseSQL(context.Background(), strings.NewReader("-- +golumn MaxDuration 5m\n-- +golumn Up\nSELECT 1;\n"), "0001_a.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sqlM.MaxDuration != 5*time.Minute {
+		t.Errorf("expected SQL max duration 5m, got %s", sqlM.MaxDuration)
+	}
+
+	luaM := mustParse(t, "Version=1\nMaxDuration=\"90s\"\nfunction Up() end\n")
+	if luaM.MaxDuration != 90*time.Second {
+		t.Errorf("expected Lua max duration 90s, got %s", luaM.MaxDuration)
+	}
+	if got := golumn.Metadata(luaM).MaxDuration; got != "1m30s" {
+		t.Errorf("expected max duration 1m30s in metadata, got %q", got)
+	}
+
+	for _, src := range []string{"Version=1\nMaxDuration=5\n", "Version=1\nMaxDuration=\"soon\"\n", "Version=1\nMaxDuration=\"-1s\"\n"} {
+		if _, err := golumn.Parse(context.Background(), strings.NewReader(src), "bad.lua"); err == nil || !strings.Contains(err.Error(), "MaxDuration") {
+			t.Errorf("expected a MaxDuration error for %q, got %v", src, err)
+		}
+	}
+	if _, err := golumn.ParseSQL(context.Background(), strings.NewReader("-- +golumn MaxDuration 0s\n-- +golumn Up\nSELECT 1;\n"), "0001_a.sql"); err == nil {
+		t.Error("expected a zero MaxDuration to be rejected")
+	}
+}
+
+func TestMigrator_MaxDuration(t *testing.T) {
+	waitForCancel := func(ctx context.Context, _ *sql.DB) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.Run("go", func(t *testing.T) {
+		m := &golumn.Migration{Version: 1, UpFunc: waitForCancel, MaxDuration: 20 * time.Millisecond}
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{Store: store, Sources: []*golumn.Migration{m}}
+		_, err := migrator.Up(context.Background(), golumn.Latest)
+		if !errors.Is(err, golumn.ErrMigrationTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected ErrMigrationTimeout, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "exceeded max duration of 20ms") {
+			t.Errorf("expected the limit in the error, got %v", err)
+		}
+		if len(store.applied) != 0 {
+			t.Errorf("expected nothing recorded, got %v", store.applied)
+		}
+	})
+
+	t.Run("lua", func(t *testing.T) {
+		m := mustParse(t, "Version=1\nMaxDuration=\"20ms\"\nfunction Up() while true do end end\n")
+		migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: []*golumn.Migration{m}}
+		if _, err := migrator.Up(context.Background(), golumn.Latest); !errors.Is(err, golumn.ErrMigrationTimeout) {
+			t.Fatalf("expected ErrMigrationTimeout, got %v", err)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		// Cancellation of the run is not the migration's timeout.
+		m := &golumn.Migration{Version: 1, UpFunc: waitForCancel, MaxDuration: time.Hour}
+		migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: []*golumn.Migration{m}}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := migrator.Up(ctx, golumn.Latest)
+		if err == nil || errors.Is(err, golumn.ErrMigrationTimeout) {
+			t.Fatalf("expected a failure other than ErrMigrationTimeout, got %v", err)
+		}
+	})
+
+	t.Run("within", func(t *testing.T) {
+		m := createMigrations(1)[0]
+		m.MaxDuration = time.Minute
+		migrator := &golumn.Migrator{Store: &fakeStore{}, Sources: []*golumn.Migration{m}}
+		if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+	})
+}
+
+func TestGenEmbed_MaxDuration(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0001_a.sql": {Data: []byte("-- +golumn MaxDuration 5m\n-- +golumn Up\nSELECT 1;\n")},
+	}
+	src, err := golumn.GenEmbed(context.Background(), fsys, "migrations", "All")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "m.MaxDuration = 300000000000 // 5m0s"; !strings.Contains(string(src), want) {
+		t.Errorf("expected generated source to contain %q, got:\n%s", want, src)
+	}
+}
@@ -0,0 +1,98 @@
+package golumn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Validate checks the Migrator's configuration and returns every problem
+// found, joined, or nil if it is usable. Up, UpOnly, Down and DownOnly call
+// it before doing anything else. Migrations from Loader are only checked once
+// a run or Status has loaded them.
+func (m *Migrator) Validate() error {
+	return m.validate(context.Background())
+}
+
+// validate is Validate with the run options carried by ctx applied.
+func (m *Migrator) validate(ctx context.Context) error {
+	cfg := m.config(ctx)
+	var errs []error
+
+	if m.Store == nil {
+		errs = append(errs, errors.New("no version store configured"))
+	}
+	if m.Log != nil && m.Log.W == nil && m.Log.Level > LogQuiet {
+		errs = append(errs, errors.New("logger has no writer"))
+	}
+	for i, mw := range m.Middleware {
+		if mw == nil {
+			errs = append(errs, fmt.Errorf("nil store middleware at index %d", i))
+		}
+	}
+
+	if m.Loader != nil && m.Sources != nil {
+		errs = append(errs, errors.New("Sources and Loader are mutually exclusive"))
+	}
+	if cfg.holdLockOnFailure && cfg.autoRevertOnFailure {
+		errs = append(errs, errors.New("HoldLockOnFailure and AutoRevertOnFailure are mutually exclusive"))
+	}
+	if cfg.lockWait < 0 {
+		errs = append(errs, fmt.Errorf("negative LockWait: %s", cfg.lockWait))
+	}
+	if m.WaitForWindow && m.Schedule == nil {
+		errs = append(errs, errors.New("WaitForWindow set without Schedule"))
+	}
+	if m.LagProbe == nil && (m.MaxLag != 0 || m.LagPollInterval != 0) {
+		errs = append(errs, errors.New("MaxLag or LagPollInterval set without LagProbe"))
+	}
+	if m.MaxLag < 0 {
+		errs = append(errs, fmt.Errorf("negative MaxLag: %s", m.MaxLag))
+	}
+	if m.LagPollInterval < 0 {
+		errs = append(errs, fmt.Errorf("negative LagPollInterval: %s", m.LagPollInterval))
+	}
+	if m.RecordStats && m.Store != nil {
+		if _, ok := m.store().(Annotator); !ok {
+			errs = append(errs, errors.New("RecordStats set but the version store is not an Annotator"))
+		}
+	}
+	if m.Deterministic && runOptionsFromContext(ctx).luaProfile != nil {
+		errs = append(errs, errors.New("WithLuaProfile is not deterministic"))
+	}
+	if m.Conn != nil {
+		if err := m.Conn.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid Conn: %w", err))
+		}
+	}
+
+	sourcesOK := true
+	targeter := storeTarget(m.store()) != nil
+	for i, migration := range m.migrations() {
+		switch {
+		case migration == nil:
+			errs = append(errs, fmt.Errorf("invalid sources: nil migration at index %d", i))
+			sourcesOK = false
+		case migration.UpFunc == nil && migration.UpTarget == nil:
+			errs = append(errs, fmt.Errorf("invalid sources: migration %d has no up func", migration.Version))
+		case migration.UpFunc == nil && !targeter && m.Store != nil:
+			errs = append(errs, fmt.Errorf("invalid sources: migration %d needs a store providing a migration target, see Targeter", migration.Version))
+		}
+	}
+	if sourcesOK {
+		if err := m.check(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sources: %w", err))
+		}
+		if err := m.checkAnonymizeLineage(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sources: %w", err))
+		}
+		if err := m.checkFlags(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sources: %w", err))
+		}
+		if err := m.checkOwners(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid sources: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package golumn_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigrator_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		migrator *golumn.Migrator
+		wantErrs []string
+	}{
+		{
+			name:     "valid",
+			migrator: &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1, 2)},
+		},
+		{
+			name:     "nil_store",
+			migrator: &golumn.Migrator{Sources: createMigrations(1)},
+			wantErrs: []string{"no version store configured"},
+		},
+		{
+			name: "all_problems",
+			migrator: &golumn.Migrator{
+				Log:                 &golumn.Logger{Level: golumn.LogInfo},
+				HoldLockOnFailure:   true,
+				AutoRevertOnFailure: true,
+				LockWait:            -time.Second,
+				WaitForWindow:       true,
+				MaxLag:              time.Second,
+				Sources:             createMigrations(2, 1),
+			},
+			wantErrs: []string{
+				"no version store configured",
+				"logger has no writer",
+				"mutually exclusive",
+				"negative LockWait",
+				"WaitForWindow set without Schedule",
+				"MaxLag or LagPollInterval set without LagProbe",
+				"invalid sources: migration order",
+			},
+		},
+		{
+			name: "conn_setup_without_pin",
+			migrator: &golumn.Migrator{
+				Store:   &fakeStore{},
+				Sources: createMigrations(1),
+				Conn:    &golumn.ConnConfig{MaxOpenConns: 2, Setup: []string{"SET x = 1"}},
+			},
+			wantErrs: []string{"invalid Conn: connection setup requires MaxOpenConns of 1"},
+		},
+		{
+			name: "nil_migration",
+			migrator: &golumn.Migrator{
+				Store:   &fakeStore{},
+				Sources: []*golumn.Migration{nil, {Version: 1}},
+			},
+			wantErrs: []string{"nil migration at index 0", "migration 1 has no up func"},
+		},
+		{
+			name: "record_stats_without_annotator",
+			migrator: &golumn.Migrator{
+				Store:       &fakeStore{},
+				Sources:     createMigrations(1),
+				RecordStats: true,
+			},
+			wantErrs: []string{"RecordStats set but the version store is not an Annotator"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.migrator.Validate()
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected error but got none")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("expected error containing %q, got:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrator_Up_NilStore(t *testing.T) {
+	migrator := &golumn.Migrator{Sources: createMigrations(1)}
+	res, err := migrator.Up(context.Background(), 1)
+	if err == nil || !strings.Contains(err.Error(), "no version store configured") {
+		t.Errorf("expected missing store error, got %v", err)
+	}
+	if res == nil {
+		t.Error("expected result")
+	}
+}
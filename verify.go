@@ -0,0 +1,110 @@
+package golumn
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// AnomalyKind classifies an Anomaly.
+type AnomalyKind string
+
+const (
+	// AnomalyExtra is an applied version with no source.
+	AnomalyExtra AnomalyKind = "extra"
+	// AnomalyGap is a source version below the highest applied version that
+	// is not applied.
+	AnomalyGap AnomalyKind = "gap"
+	// AnomalyVersion is a store version other than the highest applied
+	// version.
+	AnomalyVersion AnomalyKind = "version"
+)
+
+// Anomaly is a discrepancy between the versions a store records as applied
+// and the prefix of Sources up to the highest of them, found with
+// Migrator.VerifyAfterRun. Gaps left deliberately, by UpOnly, DownOnly or
+// by Up to a target below versions applied with UpOnly, are reported too.
+type Anomaly struct {
+	Kind AnomalyKind
+	// Version is the version concerned, or for AnomalyVersion the version
+	// the store reports.
+	Version int64
+}
+
+func (a Anomaly) String() string {
+	switch a.Kind {
+	case AnomalyExtra:
+		return fmt.Sprintf("version %d is applied but has no source", a.Version)
+	case AnomalyGap:
+		return fmt.Sprintf("version %d is not applied but later versions are", a.Version)
+	case AnomalyVersion:
+		return fmt.Sprintf("store reports version %d, which is not the highest applied version", a.Version)
+	}
+	return fmt.Sprintf("%s anomaly at version %d", a.Kind, a.Version)
+}
+
+// verify records the anomalies in the store after the successful run res
+// in res.Anomalies if VerifyAfterRun is in effect. It is called with the
+// store lock held.
+func (m *Migrator) verify(ctx context.Context, res *Result) error {
+	if res.DryRun || !m.config(ctx).verifyAfterRun {
+		return nil
+	}
+	lister, ok := m.store().(VersionLister)
+	if !ok {
+		m.logger().Debugf("version store cannot list applied versions, skipping verification")
+		return nil
+	}
+	versions, err := lister.Versions(ctx)
+	if errors.Is(err, errors.ErrUnsupported) {
+		m.logger().Debugf("version store cannot list applied versions, skipping verification")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify applied versions: %w", err)
+	}
+	version, err := m.store().Version(ctx)
+	if errors.Is(err, ErrInitialVersion) {
+		version, err = Initial, nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify version store state: %w", err)
+	}
+
+	res.Anomalies = anomalies(m.migrations(), versions, version)
+	for _, a := range res.Anomalies {
+		m.logger().Infof("anomaly: %s", a)
+	}
+	return nil
+}
+
+// anomalies compares the applied versions and store version with sources,
+// returning the anomalies ordered by version.
+func anomalies(sources []*Migration, applied []int64, version int64) []Anomaly {
+	var found []Anomaly
+	highest := int64(Initial)
+	if len(applied) > 0 {
+		highest = slices.Max(applied)
+	}
+	if version != highest {
+		found = append(found, Anomaly{Kind: AnomalyVersion, Version: version})
+	}
+
+	known := make(map[int64]bool, len(sources))
+	for _, migration := range sources {
+		known[migration.Version] = true
+		if migration.Version < highest && !slices.Contains(applied, migration.Version) {
+			found = append(found, Anomaly{Kind: AnomalyGap, Version: migration.Version})
+		}
+	}
+	for _, v := range applied {
+		if !known[v] {
+			found = append(found, Anomaly{Kind: AnomalyExtra, Version: v})
+		}
+	}
+
+	slices.SortStableFunc(found, func(a, b Anomaly) int { return cmp.Compare(a.Version, b.Version) })
+	return found
+}
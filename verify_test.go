@@ -0,0 +1,67 @@
+package golumn_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMigrator_VerifyAfterRun(t *testing.T) {
+	store := newListingStore(1, 99)
+	// The store loses the insert of version 2 and reports a stale version.
+	store.insertFunc = func(_ context.Context, v int64, s *fakeStore) error {
+		if v != 2 {
+			s.versions = append(s.versions, v)
+		}
+		return nil
+	}
+	store.versionFunc = func(context.Context, *fakeStore) (int64, error) {
+		return 4, nil
+	}
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(1, 2, 3, 4), VerifyAfterRun: true}
+
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []golumn.Anomaly{
+		{Kind: golumn.AnomalyGap, Version: 2},
+		{Kind: golumn.AnomalyVersion, Version: 4},
+		{Kind: golumn.AnomalyExtra, Version: 99},
+	}
+	if !slices.Equal(res.Anomalies, want) {
+		t.Errorf("got anomalies %v, want %v", res.Anomalies, want)
+	}
+	if got := res.Anomalies[0].String(); got != "version 2 is not applied but later versions are" {
+		t.Errorf("unexpected message %q", got)
+	}
+
+	res, err = migrator.Up(context.Background(), golumn.Latest, golumn.WithVerifyAfterRun(false))
+	if err != nil || res.Anomalies != nil {
+		t.Errorf("expected no verification when overridden, got %v (err %v)", res.Anomalies, err)
+	}
+	res, err = migrator.Up(context.Background(), golumn.Latest, golumn.WithDryRun())
+	if err != nil || res.Anomalies != nil {
+		t.Errorf("expected no verification of a dry run, got %v (err %v)", res.Anomalies, err)
+	}
+}
+
+func TestMigrator_VerifyAfterRun_Consistent(t *testing.T) {
+	migrator := &golumn.Migrator{Store: newListingStore(), Sources: createMigrations(1, 2, 3), VerifyAfterRun: true}
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil || len(res.Anomalies) != 0 {
+		t.Errorf("expected no anomalies, got %v (err %v)", res.Anomalies, err)
+	}
+	res, err = migrator.Down(context.Background(), 1)
+	if err != nil || len(res.Anomalies) != 0 {
+		t.Errorf("expected no anomalies, got %v (err %v)", res.Anomalies, err)
+	}
+
+	// Stores that cannot list versions are not verified.
+	migrator = &golumn.Migrator{Store: &fakeStore{}, Sources: createMigrations(1), VerifyAfterRun: true}
+	if res, err := migrator.Up(context.Background(), golumn.Latest); err != nil || res.Anomalies != nil {
+		t.Errorf("expected verification to be skipped, got %v (err %v)", res.Anomalies, err)
+	}
+}
@@ -0,0 +1,78 @@
+package golumn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	// Initial is the version of a store with no migrations recorded. It is
+	// reported by Result.Version, Status.Version and CachedVersion, and as
+	// the target of Down reverts every applied migration.
+	Initial = -1
+
+	// Latest as the target of Up applies every pending migration. Up still
+	// reads -1, the former value of UpTargetLatest, as Latest until the next
+	// release.
+	Latest = math.MaxInt64
+
+	// Pinned as the target of Up applies pending migrations up to the
+	// version in Migrator.PinFile.
+	Pinned = math.MaxInt64 - 1
+)
+
+const (
+	// Deprecated: use Latest.
+	UpTargetLatest = Latest
+	// Deprecated: use Initial.
+	DownTargetInitial = Initial
+)
+
+// ErrInvalidTarget is returned by Up and Down for a target outside the range
+// from Initial to the highest source version. Up also accepts Latest and
+// Pinned.
+var ErrInvalidTarget = errors.New("invalid target version")
+
+// MaxVersion returns the highest version in sources, or Initial if sources is
+// empty.
+func MaxVersion(sources []*Migration) int64 {
+	var v int64 = Initial
+	for _, migration := range sources {
+		v = max(v, migration.Version)
+	}
+	return v
+}
+
+// MinVersion returns the lowest version in sources, or Initial if sources is
+// empty.
+func MinVersion(sources []*Migration) int64 {
+	if len(sources) == 0 {
+		return Initial
+	}
+	v := sources[0].Version
+	for _, migration := range sources[1:] {
+		v = min(v, migration.Version)
+	}
+	return v
+}
+
+// checkTarget reports whether to is a valid target for a run in direction
+// dir. Within the range, to need not be a source version: Up applies the
+// sources at or below it and Down reverts those above it.
+func (m *Migrator) checkTarget(dir Direction, to int64) error {
+	if to == Latest || to == Pinned {
+		if dir == DirectionUp {
+			return nil
+		}
+		name := "Latest"
+		if to == Pinned {
+			name = "Pinned"
+		}
+		return fmt.Errorf("%w: Down cannot target %s", ErrInvalidTarget, name)
+	}
+	if hi := MaxVersion(m.migrations()); to < Initial || to > hi {
+		return fmt.Errorf("%w: %d is outside the range %d to %d", ErrInvalidTarget, to, Initial, hi)
+	}
+	return nil
+}
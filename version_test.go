@@ -0,0 +1,117 @@
+package golumn_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jonathonwebb/golumn"
+)
+
+func TestMaxMinVersion(t *testing.T) {
+	if v := golumn.MaxVersion(nil); v != golumn.Initial {
+		t.Errorf("expected Initial for no sources, got %d", v)
+	}
+	if v := golumn.MinVersion(nil); v != golumn.Initial {
+		t.Errorf("expected Initial for no sources, got %d", v)
+	}
+
+	sources := createMigrations(3, 1, 7, 0)
+	if v := golumn.MaxVersion(sources); v != 7 {
+		t.Errorf("expected max 7, got %d", v)
+	}
+	if v := golumn.MinVersion(sources); v != 0 {
+		t.Errorf("expected min 0, got %d", v)
+	}
+}
+
+func TestMigrator_UpTargetLatest(t *testing.T) {
+	store := &fakeStore{versions: []int64{1}}
+	var approved []golumn.Approval
+	migrator := &golumn.Migrator{
+		Store:         productionStore{store, true},
+		Sources:       createMigrations(1, 2, 3),
+		ApprovalToken: "t",
+		VerifyApproval: func(_ context.Context, a golumn.Approval) error {
+			approved = append(approved, a)
+			return nil
+		},
+	}
+
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(res.Applied, []int64{2, 3}) || res.Version != 3 {
+		t.Errorf("unexpected result: %+v", res)
+	}
+	if len(approved) != 1 || approved[0].Target != 3 {
+		t.Errorf("expected approval for target 3, got %v", approved)
+	}
+}
+
+func TestMigrator_InvalidTarget(t *testing.T) {
+	migrator := &golumn.Migrator{Store: &fakeStore{versions: []int64{1}}, Sources: createMigrations(1)}
+
+	if _, err := migrator.Up(context.Background(), -2); !errors.Is(err, golumn.ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget for Up target below Initial, got %v", err)
+	}
+	if _, err := migrator.Down(context.Background(), -2); !errors.Is(err, golumn.ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget for Down target below Initial, got %v", err)
+	}
+	if _, err := migrator.Down(context.Background(), golumn.Latest); !errors.Is(err, golumn.ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget for Down to Latest, got %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 2); !errors.Is(err, golumn.ErrInvalidTarget) {
+		t.Errorf("expected ErrInvalidTarget for Up above the last source, got %v", err)
+	}
+}
+
+func TestMigrator_VersionZero(t *testing.T) {
+	t.Run("unlisted_store", func(t *testing.T) {
+		store := &fakeStore{}
+		migrator := &golumn.Migrator{Store: store, Sources: createMigrations(0, 1)}
+		if _, err := migrator.Up(context.Background(), golumn.Latest); err != nil {
+			t.Fatalf("up failed: %v", err)
+		}
+		if !slices.Equal(store.applied, []int64{0, 1}) {
+			t.Errorf("expected [0 1] applied, got %v", store.applied)
+		}
+	})
+
+	store := newListingStore()
+	migrator := &golumn.Migrator{Store: store, Sources: createMigrations(0, 1, 2)}
+
+	res, err := migrator.Up(context.Background(), golumn.Latest)
+	if err != nil {
+		t.Fatalf("up failed: %v", err)
+	}
+	if !slices.Equal(res.Applied, []int64{0, 1, 2}) {
+		t.Errorf("expected [0 1 2] applied, got %v", res.Applied)
+	}
+
+	res, err = migrator.Down(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("down to 0 failed: %v", err)
+	}
+	if !slices.Equal(res.Reverted, []int64{2, 1}) || res.Version != 0 {
+		t.Errorf("expected [2 1] reverted leaving version 0, got %v and version %d", res.Reverted, res.Version)
+	}
+
+	st, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if st.Version != 0 || !slices.Equal(st.Applied, []int64{0}) || !slices.Equal(st.Pending, []int64{1, 2}) {
+		t.Errorf("unexpected status: %+v", st)
+	}
+
+	res, err = migrator.Down(context.Background(), golumn.Initial)
+	if err != nil {
+		t.Fatalf("down to Initial failed: %v", err)
+	}
+	if !slices.Equal(res.Reverted, []int64{0}) || res.Version != golumn.Initial {
+		t.Errorf("expected [0] reverted leaving Initial, got %v and version %d", res.Reverted, res.Version)
+	}
+}